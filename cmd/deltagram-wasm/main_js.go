@@ -0,0 +1,78 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"syscall/js"
+)
+
+func main() {
+	js.Global().Set("deltagram", js.ValueOf(map[string]interface{}{
+		"parse": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			return respond(parse(stringArg(args, 0), repairArg(args, 1)))
+		}),
+		"plan": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			files, err := filesArg(args, 1)
+			if err != nil {
+				return respond(nil, err)
+			}
+			return respond(plan(stringArg(args, 0), files, repairArg(args, 2)))
+		}),
+		"apply": js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+			files, err := filesArg(args, 1)
+			if err != nil {
+				return respond(nil, err)
+			}
+			return respond(apply(context.Background(), stringArg(args, 0), files, repairArg(args, 2)))
+		}),
+	}))
+
+	// Keep the functions available for the lifetime of the page
+	select {}
+}
+
+// respond turns a result into a JavaScript object: the result itself, or {error: message}
+func respond(result interface{}, err error) interface{} {
+	if err == nil {
+		var data []byte
+		data, err = json.Marshal(result)
+		if err == nil {
+			return js.Global().Get("JSON").Call("parse", string(data))
+		}
+	}
+	return js.ValueOf(map[string]interface{}{"error": err.Error()})
+}
+
+func stringArg(args []js.Value, i int) string {
+	if i >= len(args) || args[i].Type() != js.TypeString {
+		return ""
+	}
+	return args[i].String()
+}
+
+// repairArg reads an optional {repair: true} options argument
+func repairArg(args []js.Value, i int) bool {
+	if i >= len(args) || args[i].Type() != js.TypeObject {
+		return false
+	}
+	return args[i].Get("repair").Truthy()
+}
+
+// filesArg reads an object mapping file paths to their contents
+func filesArg(args []js.Value, i int) (map[string]string, error) {
+	files := make(map[string]string)
+	if i >= len(args) || args[i].IsUndefined() || args[i].IsNull() {
+		return files, nil
+	}
+	if args[i].Type() != js.TypeObject {
+		return nil, fmt.Errorf("files must be an object mapping paths to contents")
+	}
+	data := js.Global().Get("JSON").Call("stringify", args[i]).String()
+	if err := json.Unmarshal([]byte(data), &files); err != nil {
+		return nil, fmt.Errorf("files must be an object mapping paths to contents: %v", err)
+	}
+	return files, nil
+}
@@ -0,0 +1,116 @@
+// Command deltagram-wasm builds the deltagram parser and applier for the browser, so that
+// a web playground can validate and preview deltagrams without a server. Built with
+// GOOS=js GOARCH=wasm, it defines a global deltagram object whose parse, plan, and apply
+// functions take the deltagram text and, for plan and apply, an object mapping
+// slash-separated file paths to their contents.
+package main
+
+import (
+	"context"
+
+	"github.com/developingjames/deltagrams/pkg/operations"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// parseResult describes a parsed deltagram
+type parseResult struct {
+	UUID    string          `json:"uuid"`
+	Version int             `json:"version"`
+	Parts   []partSummary   `json:"parts"`
+	Repairs []parser.Repair `json:"repairs,omitempty"`
+}
+
+type partSummary struct {
+	Path        string `json:"path,omitempty"`
+	Operation   string `json:"operation"`
+	ContentType string `json:"content_type,omitempty"`
+	Line        int    `json:"line,omitempty"`
+}
+
+// applyResult is the tree after an apply along with what changed
+type applyResult struct {
+	Files    map[string]string    `json:"files"`
+	Changes  []changeSummary      `json:"changes"`
+	Diff     string               `json:"diff"`
+	Warnings []operations.Warning `json:"warnings"`
+}
+
+type changeSummary struct {
+	Path   string `json:"path"`
+	Action string `json:"action"` // created, modified, or deleted
+}
+
+// parseDeltagram parses text, fixing common formatting mistakes when repair is set
+func parseDeltagram(text string, repair bool) (*parser.Deltagram, error) {
+	return parser.NewParserWithOptions(parser.Options{Repair: repair}).Parse(text)
+}
+
+func parse(text string, repair bool) (*parseResult, error) {
+	deltagram, err := parseDeltagram(text, repair)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &parseResult{UUID: deltagram.UUID, Version: deltagram.EffectiveVersion(), Parts: []partSummary{}, Repairs: deltagram.Repairs}
+	for _, part := range deltagram.Parts {
+		summary := partSummary{Path: part.ContentLocation, Operation: part.DeltaOperation, ContentType: part.ContentType, Line: part.Line}
+		switch {
+		case part.IsMessage():
+			summary.Operation = "message"
+		case summary.Operation == "":
+			summary.Operation = "create"
+		}
+		result.Parts = append(result.Parts, summary)
+	}
+	return result, nil
+}
+
+func plan(text string, files map[string]string, repair bool) (*operations.Plan, error) {
+	deltagram, err := parseDeltagram(text, repair)
+	if err != nil {
+		return nil, err
+	}
+	p, err := operations.PlanMemoryFS(deltagram, toBytes(files))
+	if err != nil {
+		return nil, err
+	}
+	p.Deltagram = text
+	return p, nil
+}
+
+func apply(ctx context.Context, text string, files map[string]string, repair bool) (*applyResult, error) {
+	deltagram, err := parseDeltagram(text, repair)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := operations.ApplyToMemoryFS(ctx, deltagram, toBytes(files), operations.Options{})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &applyResult{Files: make(map[string]string), Changes: []changeSummary{}, Diff: applied.Diff, Warnings: applied.Warnings}
+	if result.Warnings == nil {
+		result.Warnings = []operations.Warning{}
+	}
+	for name, data := range applied.Files {
+		result.Files[name] = string(data)
+	}
+	for _, change := range applied.Changes {
+		action := "modified"
+		if !change.Existed {
+			action = "created"
+		} else if !change.Exists {
+			action = "deleted"
+		}
+		result.Changes = append(result.Changes, changeSummary{Path: change.Path, Action: action})
+	}
+	return result, nil
+}
+
+func toBytes(files map[string]string) map[string][]byte {
+	result := make(map[string][]byte, len(files))
+	for name, content := range files {
+		result[name] = []byte(content)
+	}
+	return result
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+const playgroundDeltagram = `--====DELTAGRAM_abc12345====
+Content-Location: hello.txt
+Content-Type: text/plain
+Delta-Operation: content
+
+@@ -1,1 +1,1 @@
+-hello
++hello, world
+--====DELTAGRAM_abc12345====--
+`
+
+func TestParse(t *testing.T) {
+	result, err := parse(playgroundDeltagram, false)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result.UUID != "abc12345" || len(result.Parts) != 1 {
+		t.Fatalf("Expected one part with boundary abc12345, got %+v", result)
+	}
+	if part := result.Parts[0]; part.Path != "hello.txt" || part.Operation != "content" {
+		t.Errorf("Expected a content part for hello.txt, got %+v", part)
+	}
+
+	if _, err := parse("not a deltagram", false); err == nil {
+		t.Error("Expected an error for text without a boundary")
+	}
+}
+
+func TestPlan(t *testing.T) {
+	result, err := plan(playgroundDeltagram, map[string]string{"hello.txt": "hello\n"}, false)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result.Deltagram != playgroundDeltagram || len(result.Steps) != 1 {
+		t.Fatalf("Expected a plan with one step carrying the deltagram, got %+v", result)
+	}
+	if pre := result.Steps[0].Preconditions; len(pre) != 1 || !pre[0].Exists {
+		t.Errorf("Expected hello.txt to be recorded as existing, got %+v", pre)
+	}
+}
+
+func TestApply(t *testing.T) {
+	result, err := apply(context.Background(), playgroundDeltagram, map[string]string{"hello.txt": "hello\n", "other.txt": "other"}, false)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if result.Files["hello.txt"] != "hello, world\n" {
+		t.Errorf("Expected hello.txt to be patched, got %q", result.Files["hello.txt"])
+	}
+	if result.Files["other.txt"] != "other" {
+		t.Errorf("Expected other.txt to be kept, got %q", result.Files["other.txt"])
+	}
+	if len(result.Changes) != 1 || result.Changes[0] != (changeSummary{Path: "hello.txt", Action: "modified"}) {
+		t.Errorf("Expected hello.txt to be modified, got %+v", result.Changes)
+	}
+	if !strings.Contains(result.Diff, "+hello, world") {
+		t.Errorf("Expected a diff, got:\n%s", result.Diff)
+	}
+
+	if _, err := apply(context.Background(), playgroundDeltagram, map[string]string{"hello.txt": "goodbye\n"}, false); err == nil {
+		t.Error("Expected an error when the hunk does not match")
+	}
+}
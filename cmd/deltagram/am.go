@@ -0,0 +1,158 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/config"
+	"github.com/developingjames/deltagrams/pkg/mailbox"
+	"github.com/developingjames/deltagrams/pkg/operations"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+var amCommand = &command{
+	name:    "am",
+	args:    "<mbox|eml>...",
+	summary: "Apply the deltagrams in emails, read from .eml files or mbox mailboxes, in order",
+	dryRun:  true,
+	setup: func(flags *flag.FlagSet) func(g *globals, args []string) error {
+		extract := flags.String("extract", "", "Write each deltagram to this directory as NNNN-subject.deltagram instead of applying")
+		noLock := flags.Bool("no-lock", false, "Do not take the .deltagram/lock file that keeps concurrent applies apart")
+
+		return func(g *globals, args []string) error {
+			if len(args) == 0 {
+				return fmt.Errorf("usage: deltagram am [options] <mbox|eml>...")
+			}
+
+			var messages []mailbox.Message
+			for _, name := range args {
+				file, err := os.Open(name)
+				if err != nil {
+					return fmt.Errorf("failed to read %s: %v", name, err)
+				}
+				found, err := mailbox.Read(file)
+				file.Close()
+				if err != nil {
+					return fmt.Errorf("failed to read %s: %v", name, err)
+				}
+				messages = append(messages, found...)
+			}
+
+			if *extract != "" {
+				return extractMessages(g, messages, *extract)
+			}
+
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("failed to get current working directory: %v", err)
+			}
+			cfg, err := config.Load(cwd)
+			if err != nil {
+				return err
+			}
+
+			var fs operations.FileSystem = operations.NewRealFileSystem()
+			baseDir := cwd
+			if g.DryRun {
+				fs = operations.NewOverlayFileSystem(os.DirFS(cwd))
+				baseDir = operations.OverlayRoot
+			}
+
+			release, err := acquireLock(cwd, g.DryRun || *noLock)
+			if err != nil {
+				return err
+			}
+			defer release()
+
+			applied := 0
+			for i, message := range messages {
+				if message.Deltagram == "" {
+					fmt.Fprintf(g.stderr, "Skipping message %d (%s): no deltagram found\n", i+1, message.Subject)
+					continue
+				}
+				fmt.Fprintf(g.stdout, "Applying: %s\n", message.Subject)
+
+				deltagram, err := parser.NewParser().ParseContext(g.ctx, message.Deltagram)
+				if err != nil {
+					return parseError(fmt.Errorf("failed to parse deltagram in message %d (%s): %w", i+1, message.Subject, err))
+				}
+				if !g.DryRun {
+					warnIfApplied(g.stderr, cfg, cwd, deltagram)
+				}
+				recorder := operations.NewRecordingFileSystem(fs)
+				err = operations.NewApplierWithOptions(recorder, configOptions(cfg)).ApplyContext(g.ctx, deltagram, baseDir)
+				if err != nil {
+					if restoreErr := operations.RestoreChanges(fs, recorder.Changes()); restoreErr != nil {
+						err = fmt.Errorf("failed to apply message %d (%s): %v (rollback also failed: %v)", i+1, message.Subject, err, restoreErr)
+					} else {
+						err = fmt.Errorf("failed to apply message %d (%s): %w; %d earlier message(s) stay applied", i+1, message.Subject, err, applied)
+					}
+				}
+				if !g.DryRun {
+					err = auditApply(cfg, cwd, "am", cwd, deltagram, recorder.Changes(), baseDir, err)
+				}
+				if err != nil {
+					return err
+				}
+				applied++
+			}
+
+			if applied == 0 {
+				return fmt.Errorf("no deltagrams found in %s", strings.Join(args, ", "))
+			}
+			if g.DryRun {
+				fmt.Fprintf(g.stdout, "Dry run: %d deltagram(s) would apply; no files were changed\n", applied)
+			} else {
+				fmt.Fprintf(g.stdout, "Applied %d deltagram(s)\n", applied)
+			}
+			return nil
+		}
+	},
+}
+
+// nonSlug matches runs of characters left out of file names made from titles
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// numberedName returns a file name such as 0001-fix-parser.deltagram for the nth
+// deltagram, made from its title
+func numberedName(n int, title string) string {
+	slug := strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(title), "-"), "-")
+	if len(slug) > 50 {
+		slug = strings.TrimRight(slug[:50], "-")
+	}
+	name := fmt.Sprintf("%04d", n)
+	if slug != "" {
+		name += "-" + slug
+	}
+	return name + ".deltagram"
+}
+
+// extractMessages writes the deltagram of each message to dir, numbered in order and
+// named after the subject, so that they can be reviewed or dropped into an inbox
+func extractMessages(g *globals, messages []mailbox.Message, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", dir, err)
+	}
+
+	count := 0
+	for _, message := range messages {
+		if message.Deltagram == "" {
+			continue
+		}
+		count++
+		path := filepath.Join(dir, numberedName(count, message.Subject))
+		if err := os.WriteFile(path, []byte(message.Deltagram), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %v", path, err)
+		}
+		fmt.Fprintln(g.stdout, path)
+	}
+
+	if count == 0 {
+		return fmt.Errorf("no deltagrams found")
+	}
+	return nil
+}
@@ -0,0 +1,904 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/clipboard"
+	"github.com/developingjames/deltagrams/pkg/config"
+	"github.com/developingjames/deltagrams/pkg/diff"
+	"github.com/developingjames/deltagrams/pkg/encrypt"
+	"github.com/developingjames/deltagrams/pkg/lock"
+	"github.com/developingjames/deltagrams/pkg/operations"
+	"github.com/developingjames/deltagrams/pkg/parser"
+	"github.com/developingjames/deltagrams/pkg/remote"
+	"github.com/developingjames/deltagrams/pkg/resolve"
+	"github.com/developingjames/deltagrams/pkg/variables"
+)
+
+var applyCommand = &command{
+	name:    "apply",
+	args:    "[file]",
+	summary: "Apply deltagram from clipboard or file to current directory",
+	dryRun:  true,
+	json:    true,
+	setup: func(flags *flag.FlagSet) func(g *globals, args []string) error {
+		allowIgnored := flags.Bool("allow-ignored", false, "Allow writing to gitignored paths and protected directories like .git/")
+		noAtomic := flags.Bool("no-atomic", false, "Write files in place instead of via a temporary file and rename")
+		allowSymlinkEscape := flags.Bool("allow-symlink-escape", false, "Warn instead of refusing when a symlink leads outside the directory")
+		showDiff := flags.Bool("show-diff", false, "Print a unified diff of all changes after applying")
+		tabWidth := flags.Int("tab-width", 0, "Expand tabs to this many columns in --show-diff and conflict output (default from config, 8)")
+		wordDiff := flags.Bool("word-diff", false, "Mark the words that changed within each line in --show-diff and conflict output")
+		preserveMtime := flags.Bool("preserve-mtime", false, "Keep the modification time of files changed by content operations")
+		interactive := flags.Bool("interactive", false, "Ask how to resolve content hunks that do not match instead of aborting")
+		fromPlan := flags.String("from-plan", "", "Apply exactly the plan in this file, written by 'deltagram plan'")
+		flags.StringVar(fromPlan, "plan", "", "Same as --from-plan")
+		noLock := flags.Bool("no-lock", false, "Do not take the .deltagram/lock file that keeps concurrent applies apart")
+		quiet := flags.Bool("quiet", false, "Do not show a progress bar for large deltagrams")
+		warningsAsErrors := flags.Bool("warnings-as-errors", false, "Refuse to apply, writing nothing, if the deltagram causes any warnings")
+		parseOpts := parseFlags(flags)
+		format := flags.Bool("format", false, "Run the configured formatter on each changed file, such as gofmt for .go files")
+		fixWhitespace := flags.Bool("fix-whitespace", false, "Strip trailing whitespace from added lines and end each written file with exactly one newline")
+		checkSyntax := flags.Bool("check-syntax", false, "Warn about changed Go and JSON files that no longer parse")
+		verifySyntax := flags.Bool("verify-syntax", false, "Like --check-syntax, but fail and roll back the apply if any file no longer parses")
+		verifyCmd := flags.String("verify-cmd", "", "Run this shell command after applying, such as \"go test ./...\", and roll back if it fails")
+		targetName := flags.String("target", "", "Apply to this .zip, .tar, or .tar.gz archive, ssh://[user@]host/path, or s3:// or gs://bucket/prefix, instead of the directory")
+		container := flags.String("container", "", "Apply inside this running Docker container instead of the directory")
+		containerDir := flags.String("dir", ".", "Directory inside the container to apply to (with --container); the default is its working directory")
+		configMap := flags.String("configmap", "", "Apply to the keys of this Kubernetes ConfigMap, given as namespace/name, as if they were files")
+		secret := flags.String("secret", "", "Apply to the keys of this Kubernetes Secret, given as namespace/name, as if they were files")
+		kubeContext := flags.String("kubecontext", "", "kubeconfig context for --configmap and --secret")
+		workspaceFile := flags.String("workspace", "", "Route each path to the directory its prefix is mapped to in this JSON mapping file")
+		outputArchive := flags.String("output-archive", "", "Write the changed files to this .zip, .tar, or .tar.gz archive with a manifest instead of the directory")
+		allowRun := flags.Bool("allow-run", false, "Offer to run the commands in the deltagram's deltagram://run part after applying")
+		var vars stringList
+		flags.Var(&vars, "var", "Set a template variable as NAME=value (repeatable)")
+		pathOpts := pathFlags(flags)
+		var filter operations.PartFilter
+		flags.Var((*stringList)(&filter.Only), "only", "Apply only the operations whose paths match this glob, such as 'src/**' (repeatable)")
+		flags.Var((*stringList)(&filter.Exclude), "exclude", "Skip the operations that write a path matching this glob, such as '**/*_test.go' (repeatable)")
+		ops := flags.String("ops", "", "Apply only these operations, as a comma-separated list such as content,create")
+		branch := flags.String("branch", "", "In a git repository, switch to this branch, creating it if needed, before applying")
+		commit := flags.Bool("commit", false, "Commit the changed files with the deltagram's message after applying")
+		fromHistory := flags.Int("from-history", 0, "Apply the Nth most recent deltagram read from the clipboard, 1 being the latest, instead of the clipboard")
+		autostash := flags.Bool("autostash", false, "In a git repository, stash local changes before applying and restore them afterwards")
+
+		return func(g *globals, args []string) (err error) {
+			if *ops != "" {
+				filter.Ops = strings.Split(*ops, ",")
+			}
+
+			// Get current working directory
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("failed to get current working directory: %v", err)
+			}
+
+			cfg, err := config.Load(cwd)
+			if err != nil {
+				return err
+			}
+
+			containerDirSet := false
+			flags.Visit(func(f *flag.Flag) { containerDirSet = containerDirSet || f.Name == "dir" })
+			if containerDirSet && *container == "" {
+				return fmt.Errorf("apply --dir names a directory inside a container and needs --container; put the global --dir before the command")
+			}
+			targets := 0
+			for _, name := range []string{*targetName, *container, *configMap, *secret, *workspaceFile, *outputArchive} {
+				if name != "" {
+					targets++
+				}
+			}
+			if targets > 1 {
+				return fmt.Errorf("only one of --target, --container, --configmap, --secret, --workspace, and --output-archive can be given")
+			}
+			if *outputArchive != "" {
+				if _, err := operations.ArchiveFormat(*outputArchive); err != nil {
+					return err
+				}
+			}
+
+			if (*branch != "" || *commit || *autostash) && (g.DryRun || targets > 0) {
+				return fmt.Errorf("--branch, --commit, and --autostash apply to the directory and cannot be combined with --dry-run or another target")
+			}
+
+			// Create dependencies; a dry run or an archive writes to an in-memory overlay
+			// of the directory, and --target to a copy of the target loaded into memory
+			overlay := g.DryRun || targets > 0
+			var fs operations.FileSystem = operations.NewRealFileSystemWithOptions(operations.FileSystemOptions{
+				DisableAtomicWrites: *noAtomic,
+			})
+			baseDir := cwd
+			var applyTarget *target
+			switch {
+			case *container != "":
+				if applyTarget, err = openRemote(g, "container "+*container+":"+*containerDir, remote.Docker(*container), *containerDir); err != nil {
+					return err
+				}
+				fs, baseDir = applyTarget.fs, applyTarget.baseDir
+			case *targetName != "":
+				if applyTarget, err = openTarget(g, *targetName); err != nil {
+					return err
+				}
+				fs, baseDir = applyTarget.fs, applyTarget.baseDir
+			case *configMap != "" || *secret != "":
+				if applyTarget, err = openKube(g, *configMap, *secret, *kubeContext); err != nil {
+					return err
+				}
+				fs, baseDir = applyTarget.fs, applyTarget.baseDir
+			case *workspaceFile != "":
+				if applyTarget, err = openWorkspace(*workspaceFile); err != nil {
+					return err
+				}
+				fs, baseDir = applyTarget.fs, applyTarget.baseDir
+			case overlay:
+				fs = operations.NewOverlayFileSystem(os.DirFS(cwd))
+				baseDir = operations.OverlayRoot
+			}
+			recorder := operations.NewRecordingFileSystem(fs)
+
+			opts := configOptions(cfg)
+			opts.AllowIgnored = *allowIgnored
+			opts.PreserveModTime = *preserveMtime
+			opts.FixWhitespace = opts.FixWhitespace || *fixWhitespace
+			opts.AllowSymlinkEscape = *allowSymlinkEscape
+			if *interactive {
+				prompter := resolve.NewPrompter(g.stdin, g.out())
+				prompter.Display = displayOptions(cfg, flags, *tabWidth, *wordDiff, g.out())
+				opts.ConflictResolver = prompter
+			}
+
+			if *warningsAsErrors && *interactive {
+				return fmt.Errorf("--warnings-as-errors cannot be combined with --interactive")
+			}
+
+			var deltagram *parser.Deltagram
+			if *fromPlan != "" {
+				if len(args) > 0 || *fromHistory != 0 || len(vars) > 0 || pathOpts.set() || !filter.IsEmpty() {
+					return fmt.Errorf("--from-plan cannot be combined with a file argument, --from-history, --var, --path-rewrite, --strip, or filters")
+				}
+				if deltagram, err = loadPlan(fs, baseDir, *fromPlan); err != nil {
+					return err
+				}
+			} else {
+				if *fromHistory != 0 {
+					if len(args) > 0 {
+						return fmt.Errorf("--from-history cannot be combined with a file argument")
+					}
+					if args, err = historyArgs(*fromHistory); err != nil {
+						return err
+					}
+				}
+				if (*interactive || *allowRun) && readsStdin(g, args) {
+					return fmt.Errorf("--interactive and --allow-run ask for answers on stdin, which carries the deltagram; pass the deltagram as a file")
+				}
+				if deltagram, err = readDeltagramWith(g, args, *parseOpts); err != nil {
+					return err
+				}
+				if deltagram, err = expandVariables(deltagram, vars); err != nil {
+					return err
+				}
+				if deltagram, err = rewritePaths(deltagram, *pathOpts); err != nil {
+					return err
+				}
+				var skipped int
+				if deltagram, skipped, err = operations.FilterParts(deltagram, filter); err != nil {
+					return err
+				}
+				if skipped > 0 {
+					fmt.Fprintf(g.stderr, "Skipping %d operation(s) left out by --only, --exclude, or --ops\n", skipped)
+				}
+			}
+
+			if applyTarget != nil && applyTarget.check != nil {
+				if err := applyTarget.check(deltagram); err != nil {
+					return err
+				}
+			}
+
+			// A dry run writes nothing, so it cannot interleave with another apply
+			release, err := acquireLock(cwd, overlay || *noLock)
+			if err != nil {
+				return err
+			}
+			defer release()
+
+			// Local changes come back after the apply, the commit, and the audit record
+			if *autostash {
+				restore, stashErr := stashChanges(g, cwd)
+				if stashErr != nil {
+					return stashErr
+				}
+				defer func() { err = restore(err) }()
+			}
+			if *branch != "" {
+				if err := switchBranch(g, cwd, *branch); err != nil {
+					return err
+				}
+			}
+
+			// Every apply that may have written something is recorded, including failed ones
+			if !g.DryRun {
+				warnIfApplied(g.stderr, cfg, cwd, deltagram)
+				auditTarget := cwd
+				if applyTarget != nil {
+					auditTarget = applyTarget.name
+				} else if *outputArchive != "" {
+					auditTarget = *outputArchive
+				}
+				defer func() {
+					err = auditApply(cfg, cwd, "apply", auditTarget, deltagram, recorder.Changes(), baseDir, err)
+				}()
+			}
+
+			// Warnings are listed after the apply instead of among the per-file messages
+			var warnings []operations.Warning
+			opts.Warn = func(w operations.Warning) { warnings = append(warnings, w) }
+
+			if *warningsAsErrors && !overlay {
+				// Look for warnings on an in-memory copy so that nothing is written if any occur
+				found, err := preflightWarnings(g.ctx, cwd, deltagram, opts)
+				if err != nil {
+					return fmt.Errorf("failed to apply deltagram: %w", err)
+				}
+				if err := refuseWarnings(g, found); err != nil {
+					return err
+				}
+			}
+
+			// Interactive prompts and JSON output would be garbled by a redrawn bar
+			done := func() {}
+			if !*quiet && !*interactive && !g.JSON {
+				done = attachProgressBar(g.stderr, deltagram, &opts)
+			}
+
+			// Apply deltagram to current directory
+			err = operations.NewApplierWithOptions(recorder, opts).ApplyContext(g.ctx, deltagram, baseDir)
+			done()
+			if errors.Is(err, context.Canceled) {
+				reportInterrupted(g, recorder.Changes(), baseDir)
+			}
+			if err != nil {
+				err = fmt.Errorf("failed to apply deltagram: %w", err)
+				// Files changed by earlier parts stay changed
+				if !overlay && len(recorder.Changes()) > 0 {
+					return &exitError{err: err, code: exitPartial}
+				}
+				return err
+			}
+			if *warningsAsErrors {
+				// Only an apply to the overlay gets here with warnings; a real apply was refused above
+				if err := refuseWarnings(g, warnings); err != nil {
+					return err
+				}
+			}
+
+			if *checkSyntax || *verifySyntax {
+				broken := operations.CheckSyntax(recorder.Changes(), baseDir)
+				if *verifySyntax && len(broken) > 0 {
+					printWarnings(g.stderr, broken)
+					if err := operations.RestoreChanges(fs, recorder.Changes()); err != nil {
+						// The changed files stay changed
+						return &exitError{err: fmt.Errorf("failed to apply deltagram: %d changed file(s) no longer parse, and rolling back failed: %v", len(broken), err), code: exitPartial}
+					}
+					return fmt.Errorf("failed to apply deltagram: %d changed file(s) no longer parse; all changes were rolled back: %w", len(broken), operations.ErrSyntax)
+				}
+				warnings = append(warnings, broken...)
+			}
+
+			// Formatters, verification, and follow-up commands would act on files that were
+			// only written to the overlay
+			var formatted []string
+			if (*format || cfg.Format.OnApply) && !overlay {
+				formatted = formatChanged(g, cfg.Format, recorder.Changes(), cwd)
+			}
+			if *verifyCmd != "" && !overlay {
+				if err := runVerify(g, *verifyCmd, cwd); err != nil {
+					if restoreErr := operations.RestoreChanges(fs, recorder.Changes()); restoreErr != nil {
+						return &exitError{err: fmt.Errorf("failed to apply deltagram: %v, and rolling back failed: %v", err, restoreErr), code: exitPartial}
+					}
+					return fmt.Errorf("failed to apply deltagram: %w; all changes were rolled back", err)
+				}
+			}
+			committed := ""
+			if *commit {
+				if committed, err = commitChanges(g, cwd, deltagram, recorder.Changes()); err != nil {
+					return fmt.Errorf("deltagram applied, but committing failed: %w", err)
+				}
+				if committed != "" {
+					fmt.Fprintf(g.out(), "Committed %s\n", committed)
+				}
+			}
+
+			followUp := func() ([]string, error) {
+				if overlay {
+					return nil, nil
+				}
+				ran, err := runFollowUp(g, deltagram, cwd, *allowRun)
+				if err != nil {
+					return nil, fmt.Errorf("deltagram applied, but %w", err)
+				}
+				return ran, nil
+			}
+
+			changes := recorder.Changes()
+			wroteTo := ""
+			switch {
+			case g.DryRun:
+			case *outputArchive != "":
+				if err := writeArchive(*outputArchive, deltagram, changes, baseDir); err != nil {
+					return err
+				}
+				wroteTo = *outputArchive
+			case applyTarget != nil:
+				if err := applyTarget.save(changes); err != nil {
+					return err
+				}
+				wroteTo = applyTarget.name
+			}
+			if g.JSON {
+				ran, err := followUp()
+				if err != nil {
+					return err
+				}
+				return writeJSON(g.stdout, applyResult{DryRun: g.DryRun, Changes: changeSummaries(changes, baseDir), Warnings: nonNil(warnings),
+					Repairs: deltagram.Repairs, Notes: notes(deltagram), Formatted: formatted, Ran: ran, WroteTo: wroteTo, Commit: committed})
+			}
+			printWarnings(g.stderr, warnings)
+
+			switch {
+			case g.DryRun:
+				fmt.Fprintln(g.stdout, "Dry run: no files were changed")
+			case wroteTo != "":
+				fmt.Fprintf(g.stdout, "Wrote %d changed file(s) to %s; the directory was not changed\n", len(changes), wroteTo)
+			default:
+				fmt.Fprintln(g.stdout, "Deltagram applied successfully")
+			}
+
+			if *showDiff {
+				fmt.Fprintln(g.stdout)
+				unified := operations.FormatChanges(changes, baseDir)
+				// Piped output stays a plain diff that patch can apply, unless asked otherwise
+				flagged := false
+				flags.Visit(func(f *flag.Flag) { flagged = flagged || f.Name == "tab-width" || f.Name == "word-diff" })
+				if flagged || isTerminal(g.stdout) {
+					unified = diff.Display(unified, displayOptions(cfg, flags, *tabWidth, *wordDiff, g.stdout))
+				}
+				fmt.Fprint(g.stdout, unified)
+			}
+			_, err = followUp()
+			return err
+		}
+	},
+}
+
+// displayOptions returns how diffs are rendered for review: the --tab-width and
+// --word-diff flags override the configuration, and colors are used when w is a
+// terminal and NO_COLOR is not set
+func displayOptions(cfg *config.Config, flags *flag.FlagSet, tabWidth int, wordDiff bool, w io.Writer) diff.DisplayOptions {
+	opts := diff.DisplayOptions{
+		TabWidth: cfg.Display.TabWidth,
+		WordDiff: cfg.Display.WordDiff,
+		Color:    isTerminal(w) && os.Getenv("NO_COLOR") == "",
+	}
+	flags.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "tab-width":
+			opts.TabWidth = tabWidth
+		case "word-diff":
+			opts.WordDiff = wordDiff
+		}
+	})
+	return opts
+}
+
+// applyResult is the JSON output of apply
+type applyResult struct {
+	DryRun    bool                 `json:"dry_run"`
+	Changes   []changeSummary      `json:"changes"`
+	Warnings  []operations.Warning `json:"warnings"`
+	Repairs   []parser.Repair      `json:"repairs,omitempty"` // Mistakes fixed by --repair
+	Notes     []note               `json:"notes,omitempty"`
+	Formatted []string             `json:"formatted,omitempty"` // Files run through a formatter
+	Ran       []string             `json:"ran,omitempty"`       // Follow-up commands that were run
+	WroteTo   string               `json:"wrote_to,omitempty"`  // Archive or target written instead of the directory
+	Commit    string               `json:"commit,omitempty"`    // Commit made by --commit
+}
+
+// note is a deltagram://note part: commentary for reviewers about one file's change
+type note struct {
+	Path string `json:"path"`
+	Text string `json:"text"`
+}
+
+// notes returns the note parts of the deltagram
+func notes(deltagram *parser.Deltagram) []note {
+	var result []note
+	for _, part := range deltagram.Parts {
+		if part.IsNote() {
+			result = append(result, note{Path: part.NoteTarget(), Text: strings.TrimSpace(part.Content)})
+		}
+	}
+	return result
+}
+
+// preflightWarnings applies the deltagram to an in-memory copy of dir and returns the
+// warnings it causes
+func preflightWarnings(ctx context.Context, dir string, deltagram *parser.Deltagram, opts operations.Options) ([]operations.Warning, error) {
+	warnings := []operations.Warning{}
+	opts.Warn = func(w operations.Warning) { warnings = append(warnings, w) }
+	opts.Progress = nil
+
+	restore := silenceStdout()
+	defer restore()
+	overlay := operations.NewOverlayFileSystem(os.DirFS(dir))
+	err := operations.NewApplierWithOptions(overlay, opts).ApplyContext(ctx, deltagram, operations.OverlayRoot)
+	return warnings, err
+}
+
+// refuseWarnings prints warnings and fails when there are any, for --warnings-as-errors
+func refuseWarnings(g *globals, warnings []operations.Warning) error {
+	if len(warnings) == 0 {
+		return nil
+	}
+	printWarnings(g.stderr, warnings)
+	return &exitError{err: fmt.Errorf("refusing to apply: %d warning(s) treated as errors", len(warnings)), code: exitValidation}
+}
+
+// printWarnings lists warnings after the output of an apply
+func printWarnings(w io.Writer, warnings []operations.Warning) {
+	for _, warning := range warnings {
+		fmt.Fprintf(w, "Warning: %s\n", warning.Message)
+	}
+}
+
+// nonNil returns warnings, or an empty slice so that JSON shows [] rather than null
+func nonNil(warnings []operations.Warning) []operations.Warning {
+	if warnings == nil {
+		return []operations.Warning{}
+	}
+	return warnings
+}
+
+// changeSummary describes one file changed by an apply
+type changeSummary struct {
+	Path   string `json:"path"`
+	Action string `json:"action"` // created, modified, or deleted
+}
+
+func changeSummaries(changes []operations.FileChange, baseDir string) []changeSummary {
+	summaries := make([]changeSummary, 0, len(changes))
+	for _, change := range changes {
+		action := "modified"
+		if !change.Existed {
+			action = "created"
+		} else if !change.Exists {
+			action = "deleted"
+		}
+		summaries = append(summaries, changeSummary{Path: relativeTo(baseDir, change.Path), Action: action})
+	}
+	return summaries
+}
+
+// reportInterrupted lists the files an interrupted apply had already changed, since the
+// deltagram was only partly applied
+func reportInterrupted(g *globals, changes []operations.FileChange, baseDir string) {
+	if len(changes) == 0 {
+		fmt.Fprintln(g.stderr, "Interrupted before any files were changed")
+		return
+	}
+	fmt.Fprintln(g.stderr, "Interrupted; these files were already changed:")
+	for _, change := range changeSummaries(changes, baseDir) {
+		fmt.Fprintf(g.stderr, "  %s %s\n", change.Action, change.Path)
+	}
+}
+
+var checkCommand = &command{
+	name:    "check",
+	args:    "[file]",
+	summary: "Verify content hunks against current directory without applying",
+	json:    true,
+	setup: func(flags *flag.FlagSet) func(g *globals, args []string) error {
+		var vars stringList
+		flags.Var(&vars, "var", "Set a template variable as NAME=value (repeatable)")
+
+		pathOpts := pathFlags(flags)
+		parseOpts := parseFlags(flags)
+
+		return func(g *globals, args []string) error {
+			fs := operations.NewRealFileSystem()
+
+			deltagram, err := readDeltagramWith(g, args, *parseOpts)
+			if err != nil {
+				return err
+			}
+			if deltagram, err = expandVariables(deltagram, vars); err != nil {
+				return err
+			}
+			if deltagram, err = rewritePaths(deltagram, *pathOpts); err != nil {
+				return err
+			}
+
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("failed to get current working directory: %v", err)
+			}
+
+			out := g.out()
+			var report checkReport
+			for _, part := range deltagram.Parts {
+				if part.DeltaOperation != "content" {
+					continue
+				}
+
+				fmt.Fprintf(out, "%s:\n", part.ContentLocation)
+				partReport := checkPart{Path: part.ContentLocation, Line: part.Line}
+				results, err := operations.CheckContentPart(fs, cwd, part)
+				if err != nil {
+					fmt.Fprintf(out, "  FAILED: %v\n", err)
+					partReport.Error = err.Error()
+					partReport.Kind = errorKind(err)
+					report.Failed++
+					report.Parts = append(report.Parts, partReport)
+					continue
+				}
+				if results == nil {
+					fmt.Fprintln(out, "  new file")
+					partReport.NewFile = true
+					report.Parts = append(report.Parts, partReport)
+					continue
+				}
+
+				for _, result := range results {
+					hunk := checkHunk{Index: result.Index, Line: result.OldStart, Offset: result.Offset}
+					switch result.Status {
+					case operations.HunkExact:
+						fmt.Fprintf(out, "  hunk %d (line %d): exact\n", result.Index, result.OldStart)
+						hunk.Status = "exact"
+						report.Exact++
+					case operations.HunkFuzzy:
+						fmt.Fprintf(out, "  hunk %d (line %d): fuzz %+d\n", result.Index, result.OldStart, result.Offset)
+						hunk.Status = "fuzzy"
+						report.Fuzzy++
+					case operations.HunkFailed:
+						fmt.Fprintf(out, "  hunk %d (line %d): FAILED: %v\n", result.Index, result.OldStart, result.Err)
+						hunk.Status = "failed"
+						hunk.Error = result.Err.Error()
+						hunk.Kind = errorKind(result.Err)
+						report.Failed++
+					}
+					partReport.Hunks = append(partReport.Hunks, hunk)
+				}
+				report.Parts = append(report.Parts, partReport)
+			}
+
+			fmt.Fprintf(out, "\n%d exact, %d fuzzy, %d failed\n", report.Exact, report.Fuzzy, report.Failed)
+			if g.JSON {
+				if err := writeJSON(g.stdout, report); err != nil {
+					return err
+				}
+			}
+			if report.Failed > 0 {
+				return &exitError{err: fmt.Errorf("%d hunk(s) would fail to apply", report.Failed), code: exitValidation}
+			}
+			return nil
+		}
+	},
+}
+
+// checkReport is the JSON output of check
+type checkReport struct {
+	Parts  []checkPart `json:"parts"`
+	Exact  int         `json:"exact"`
+	Fuzzy  int         `json:"fuzzy"`
+	Failed int         `json:"failed"`
+}
+
+type checkPart struct {
+	Path    string      `json:"path"`
+	Line    int         `json:"line,omitempty"` // Line of the deltagram text where the part starts
+	NewFile bool        `json:"new_file,omitempty"`
+	Error   string      `json:"error,omitempty"`
+	Kind    string      `json:"kind,omitempty"` // Error kind, as in errorResult
+	Hunks   []checkHunk `json:"hunks,omitempty"`
+}
+
+type checkHunk struct {
+	Index  int    `json:"index"`
+	Line   int    `json:"line"`
+	Status string `json:"status"`
+	Offset int    `json:"offset,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Kind   string `json:"kind,omitempty"`
+}
+
+// writeJSON prints v as indented JSON
+func writeJSON(w io.Writer, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// relativeTo returns path relative to baseDir with forward slashes, or path unchanged
+// if it is not beneath baseDir
+func relativeTo(baseDir, path string) string {
+	rel, err := filepath.Rel(baseDir, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// acquireLock takes the apply lock for dir unless skip is set, returning a function
+// that releases it
+func acquireLock(dir string, skip bool) (func(), error) {
+	if skip {
+		return func() {}, nil
+	}
+	held, err := lock.Acquire(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%w (pass --no-lock to skip locking)", err)
+	}
+	return func() { held.Release() }, nil
+}
+
+// configOptions converts the configured path policy, limits, and whitespace policy into
+// apply options
+func configOptions(cfg *config.Config) operations.Options {
+	return operations.Options{
+		AllowPaths:    cfg.Paths.Allow,
+		DenyPaths:     cfg.Paths.Deny,
+		MaxParts:      max(cfg.Limits.MaxParts, 0),
+		MaxFileSize:   max(cfg.Limits.MaxFileSize, 0),
+		MaxTotalBytes: max(cfg.Limits.MaxTotalBytes, 0),
+		// Negative disables streaming in both places
+		StreamThreshold: cfg.Limits.StreamThreshold,
+		FixWhitespace:   cfg.Format.FixWhitespace,
+		Sanitize: operations.SanitizePolicy{
+			Normalize: cfg.Paths.Normalize,
+			ASCII:     cfg.Paths.ASCII,
+			Lowercase: cfg.Paths.Lowercase,
+		},
+	}
+}
+
+// readInput reads raw deltagram text from the file argument, stdin, or the clipboard.
+// Stdin is read when the argument is "-" or, without an argument, when input is piped in.
+func readInput(g *globals, args []string) (string, error) {
+	if readsStdin(g, args) {
+		contentBytes, err := io.ReadAll(g.stdin)
+		if err != nil {
+			return "", fmt.Errorf("failed to read stdin: %w", err)
+		}
+		if len(bytes.TrimSpace(contentBytes)) == 0 {
+			return "", fmt.Errorf("no deltagram on stdin")
+		}
+		return string(contentBytes), nil
+	}
+
+	// Check if file path is provided as argument
+	if len(args) > 0 {
+		// Read deltagram from file
+		filePath := args[0]
+		contentBytes, err := os.ReadFile(filePath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read file %s: %w", filePath, err)
+		}
+		return string(contentBytes), nil
+	}
+
+	// Read deltagram from clipboard
+	reader, err := clipboardReader()
+	if err != nil {
+		return "", err
+	}
+	content, err := reader.ReadContext(g.ctx)
+	switch {
+	case errors.Is(err, clipboard.ErrTimeout):
+		return "", fmt.Errorf("failed to read clipboard: %w; raise clipboard.timeout in the configuration or pass the deltagram as a file", err)
+	case errors.Is(err, clipboard.ErrTooLarge):
+		return "", fmt.Errorf("failed to read clipboard: %w; raise clipboard.max_bytes in the configuration or pass the deltagram as a file", err)
+	case errors.Is(err, clipboard.ErrUnavailable):
+		return "", fmt.Errorf("failed to read clipboard: %w; %s", err, clipboardHint(runtime.GOOS))
+	case err != nil:
+		return "", fmt.Errorf("failed to read clipboard: %w", err)
+	}
+	return content, nil
+}
+
+// readsStdin reports whether readInput takes the deltagram from stdin
+func readsStdin(g *globals, args []string) bool {
+	if len(args) > 0 {
+		return args[0] == "-"
+	}
+	return stdinPiped(g.stdin)
+}
+
+// stdinPiped reports whether stdin carries input from a pipe or file rather than being a
+// terminal. Readers other than files, as given by tests and embedders, always count as
+// piped.
+func stdinPiped(stdin io.Reader) bool {
+	file, ok := stdin.(*os.File)
+	if !ok {
+		return stdin != nil
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice == 0
+}
+
+// clipboardHint suggests the other ways of passing a deltagram when the clipboard cannot
+// be reached on goos
+func clipboardHint(goos string) string {
+	hint := "pass the deltagram as a file (deltagram apply patch.txt) or pipe it in (deltagram apply < patch.txt)"
+	if goos == "linux" {
+		hint = "install xclip or xsel (for example with 'sudo apt install xclip'), " + strings.Replace(hint, " or pipe", ", or pipe", 1)
+	}
+	return hint
+}
+
+// clipboardReader returns a clipboard reader guarded by the configured timeout and size
+// limit
+func clipboardReader() (clipboard.Reader, error) {
+	cfg, err := loadWorkingConfig()
+	if err != nil {
+		return nil, err
+	}
+	opts := clipboard.Options{Timeout: cfg.Clipboard.TimeoutDuration(), MaxBytes: cfg.Clipboard.MaxBytes, Method: cfg.Clipboard.Method}
+	if opts.MaxBytes < 0 {
+		opts.MaxBytes = 0
+	}
+	return clipboard.NewReaderWithOptions(opts), nil
+}
+
+// copyToClipboard writes text to the clipboard with the configured timeout and method
+func copyToClipboard(g *globals, text string) error {
+	cfg, err := loadWorkingConfig()
+	if err != nil {
+		return err
+	}
+	opts := clipboard.Options{Timeout: cfg.Clipboard.TimeoutDuration(), Method: cfg.Clipboard.Method}
+	if err := clipboard.NewWriterWithOptions(opts).WriteContext(g.ctx, text); err != nil {
+		return fmt.Errorf("failed to write clipboard: %w", err)
+	}
+	fmt.Fprintf(g.stderr, "Copied %d bytes to the clipboard\n", len(text))
+	return nil
+}
+
+// clipboardHistory returns the history of deltagrams read from the clipboard, or nil when
+// the configuration turns it off
+func clipboardHistory() (*clipboard.History, error) {
+	cfg, err := loadWorkingConfig()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Clipboard.History < 0 {
+		return nil, nil
+	}
+	dir, err := clipboard.DefaultHistoryDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate clipboard history: %w", err)
+	}
+	return &clipboard.History{Dir: dir, Size: cfg.Clipboard.History}, nil
+}
+
+// historyArgs returns arguments naming the file of the nth most recent deltagram read
+// from the clipboard, for commands that read a deltagram from their file argument
+func historyArgs(n int) ([]string, error) {
+	history, err := clipboardHistory()
+	if err != nil {
+		return nil, err
+	}
+	if history == nil {
+		return nil, fmt.Errorf("--from-history needs the clipboard history, which clipboard.history turns off")
+	}
+	path, err := history.Entry(n)
+	if err != nil {
+		return nil, err
+	}
+	return []string{path}, nil
+}
+
+// recordClipboard adds a deltagram read from the clipboard to the history. The history
+// is a convenience, so failing to record is not an error.
+func recordClipboard(content string) {
+	if history, err := clipboardHistory(); err == nil && history != nil {
+		history.Add(content)
+	}
+}
+
+// loadWorkingConfig loads the configuration for the current working directory
+func loadWorkingConfig() (*config.Config, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current working directory: %w", err)
+	}
+	return config.Load(cwd)
+}
+
+// readDeltagram reads and parses a deltagram from the file argument or the clipboard,
+// decrypting it first if it is encrypted
+func readDeltagram(g *globals, args []string) (*parser.Deltagram, error) {
+	_, deltagram, err := readDeltagramSource(g, args, parser.Options{})
+	return deltagram, err
+}
+
+// readDeltagramSource is like readDeltagram but also returns the decrypted source text,
+// parsing with the given options
+func readDeltagramSource(g *globals, args []string, opts parser.Options) (string, *parser.Deltagram, error) {
+	content, err := readInput(g, args)
+	if err != nil {
+		return "", nil, err
+	}
+	raw := content
+
+	if encrypt.IsEncrypted(content) {
+		content, err = decryptInput(content)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	// Parse deltagram
+	deltagram, err := parser.NewParserWithOptions(opts).ParseContext(g.ctx, content)
+	if err != nil {
+		return "", nil, parseError(fmt.Errorf("failed to parse deltagram: %w", err))
+	}
+	if len(args) == 0 && !readsStdin(g, args) {
+		recordClipboard(raw)
+	}
+
+	return content, deltagram, nil
+}
+
+// expandVariables substitutes the deltagram's declared template variables using
+// --var flags, DELTAGRAM_VAR_* environment variables, and declared defaults
+func expandVariables(deltagram *parser.Deltagram, vars stringList) (*parser.Deltagram, error) {
+	resolved, err := resolveVariables(deltagram, vars)
+	if err != nil {
+		return nil, err
+	}
+	return variables.Expand(deltagram, resolved), nil
+}
+
+// resolveVariables returns the value of each template variable the deltagram declares
+func resolveVariables(deltagram *parser.Deltagram, vars stringList) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, v := range vars {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid --var %q: expected NAME=value", v)
+		}
+		values[name] = value
+	}
+
+	declared, err := variables.Declared(deltagram)
+	if err != nil {
+		return nil, err
+	}
+	return variables.Resolve(declared, values, os.LookupEnv)
+}
+
+// stringList collects the values of a repeatable flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
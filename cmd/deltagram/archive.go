@@ -0,0 +1,50 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/developingjames/deltagrams/pkg/operations"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// archiveManifestName is the name of the manifest inside an --output-archive
+const archiveManifestName = "deltagram-manifest.json"
+
+// archiveManifest lists every change in an --output-archive, including deletions, which
+// have no file in the archive
+type archiveManifest struct {
+	UUID    string         `json:"uuid,omitempty"` // Boundary identifier of the deltagram
+	Changes []archiveEntry `json:"changes"`
+}
+
+type archiveEntry struct {
+	Path   string `json:"path"`
+	Action string `json:"action"`           // created, modified, or deleted
+	SHA256 string `json:"sha256,omitempty"` // Of the file in the archive; empty for deleted files
+}
+
+// writeArchive writes the files that exist after the changes, relative to baseDir, and a
+// manifest of all the changes to the archive at name
+func writeArchive(name string, deltagram *parser.Deltagram, changes []operations.FileChange, baseDir string) error {
+	manifest := archiveManifest{UUID: deltagram.UUID, Changes: []archiveEntry{}}
+	var entries []operations.ArchiveEntry
+	now := time.Now()
+	for i, summary := range changeSummaries(changes, baseDir) {
+		entry := archiveEntry{Path: summary.Path, Action: summary.Action}
+		if changes[i].Exists {
+			sum := sha256.Sum256(changes[i].After)
+			entry.SHA256 = hex.EncodeToString(sum[:])
+			entries = append(entries, operations.ArchiveEntry{Path: summary.Path, Data: changes[i].After, Mode: 0644, ModTime: now})
+		}
+		manifest.Changes = append(manifest.Changes, entry)
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	entries = append(entries, operations.ArchiveEntry{Path: archiveManifestName, Data: append(data, '\n'), Mode: 0644, ModTime: now})
+	return operations.WriteArchive(name, entries)
+}
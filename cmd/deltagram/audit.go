@@ -0,0 +1,158 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/developingjames/deltagrams/pkg/audit"
+	"github.com/developingjames/deltagrams/pkg/config"
+	"github.com/developingjames/deltagrams/pkg/operations"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+var auditCommand = &command{
+	name:    "audit",
+	args:    "<verify>",
+	summary: "Check that the audit log of applies has not been modified",
+	json:    true,
+	setup: func(flags *flag.FlagSet) func(g *globals, args []string) error {
+		return func(g *globals, args []string) error {
+			if len(args) < 1 {
+				return fmt.Errorf("usage: deltagram audit verify [--log file] [--head hash]")
+			}
+
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("failed to get current working directory: %v", err)
+			}
+			cfg, err := config.Load(cwd)
+			if err != nil {
+				return err
+			}
+
+			subcommand := args[0]
+			subflags := flag.NewFlagSet("audit "+subcommand, flag.ContinueOnError)
+			subflags.SetOutput(g.stderr)
+			switch subcommand {
+			case "verify":
+				logFile := subflags.String("log", auditLogPath(cfg, cwd), "Audit log to verify")
+				head := subflags.String("head", "", "Also check that the log still contains the entry with this hash, printed by an earlier verify")
+				if err := subflags.Parse(args[1:]); err != nil {
+					return err
+				}
+				return auditVerify(g, *logFile, *head)
+			default:
+				return fmt.Errorf("unknown audit command: %s", subcommand)
+			}
+		}
+	},
+}
+
+// auditVerifyResult is the JSON output of `audit verify`
+type auditVerifyResult struct {
+	Log     string `json:"log"`
+	Entries int    `json:"entries"`
+	Head    string `json:"head"`
+}
+
+func auditVerify(g *globals, path, head string) error {
+	count, last, err := audit.Verify(path)
+	if err == nil && head != "" {
+		err = audit.Contains(path, head)
+	}
+	if err != nil {
+		return err
+	}
+	if g.JSON {
+		return writeJSON(g.stdout, auditVerifyResult{Log: path, Entries: count, Head: last})
+	}
+	fmt.Fprintf(g.stdout, "%d entries verified in %s\n", count, path)
+	if last != "" {
+		fmt.Fprintf(g.stdout, "Head: %s\n", last)
+	}
+	return nil
+}
+
+// auditLogPath returns the configured audit log, relative paths being taken from dir
+func auditLogPath(cfg *config.Config, dir string) string {
+	path := cfg.Audit.Log
+	if path == "" {
+		path = filepath.FromSlash(audit.DefaultFile)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	return path
+}
+
+// currentIdentity returns the configured identity, or else the operating system user
+func currentIdentity(cfg *config.Config) string {
+	if identity := cfg.Identity.String(); identity != "" {
+		return identity
+	}
+	name, _ := audit.Identity()
+	return name
+}
+
+// warnIfApplied warns when the audit log is enabled and records an earlier apply of a
+// deltagram with the same patch-id, which may have had another boundary identifier
+func warnIfApplied(w io.Writer, cfg *config.Config, dir string, deltagram *parser.Deltagram) {
+	if !cfg.Audit.Enabled {
+		return
+	}
+	entry, err := audit.FindApplied(auditLogPath(cfg, dir), operations.PatchID(deltagram))
+	if err != nil {
+		fmt.Fprintf(w, "Warning: cannot check the audit log for duplicates: %v\n", err)
+		return
+	}
+	if entry != nil {
+		fmt.Fprintf(w, "Warning: the same changes were already applied to %s at %s (audit entry %d)\n",
+			entry.Target, entry.Time.Format(time.RFC3339), entry.Seq)
+	}
+}
+
+// auditApply records an apply in the audit log when it is enabled and returns applyErr.
+// An apply that succeeded but could not be recorded is reported as an error, since a
+// regulated environment must not have changes missing from the log.
+func auditApply(cfg *config.Config, dir, command, target string, deltagram *parser.Deltagram, changes []operations.FileChange, baseDir string, applyErr error) error {
+	if !cfg.Audit.Enabled {
+		return applyErr
+	}
+
+	entry := &audit.Entry{
+		Time:      time.Now().UTC(),
+		Command:   command,
+		Target:    target,
+		Deltagram: audit.Sum([]byte(parser.Serialize(deltagram))),
+		UUID:      deltagram.UUID,
+		PatchID:   operations.PatchID(deltagram),
+		Result:    audit.Applied,
+	}
+	entry.User = currentIdentity(cfg)
+	_, entry.Host = audit.Identity()
+	if deltagram.Metadata != nil {
+		entry.Author = deltagram.Metadata.Author
+	}
+	if applyErr != nil {
+		entry.Result, entry.Error = audit.Failed, applyErr.Error()
+	}
+	for i, summary := range changeSummaries(changes, baseDir) {
+		change := audit.Change{Path: summary.Path, Action: summary.Action}
+		if changes[i].Exists {
+			change.SHA256 = audit.Sum(changes[i].After)
+		}
+		entry.Changes = append(entry.Changes, change)
+	}
+
+	if err := audit.Append(auditLogPath(cfg, dir), entry); err != nil {
+		if applyErr != nil {
+			return fmt.Errorf("%w (also failed to record it in the audit log: %v)", applyErr, err)
+		}
+		return fmt.Errorf("deltagram applied, but %v", err)
+	}
+	return applyErr
+}
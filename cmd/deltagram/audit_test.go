@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/developingjames/deltagrams/pkg/audit"
+	"github.com/developingjames/deltagrams/pkg/config"
+)
+
+func TestAudit_ApplyAndVerify(t *testing.T) {
+	dir, file := writeDeltagram(t)
+	os.MkdirAll(filepath.Join(dir, ".deltagram"), 0755)
+	os.WriteFile(filepath.Join(dir, ".deltagram", "config.json"), []byte(`{"audit": {"enabled": true}}`), 0644)
+	userFile, err := config.UserFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Dir(userFile), 0755)
+	os.WriteFile(userFile, []byte(`{"identity": {"name": "Sam Lee"}}`), 0644)
+
+	if code, _, stderr := runCLI(t, "--dry-run", "--dir", dir, "apply", file); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr)
+	}
+	logPath := filepath.Join(dir, filepath.FromSlash(audit.DefaultFile))
+	if _, err := os.Stat(logPath); !os.IsNotExist(err) {
+		t.Fatalf("Expected a dry run not to be recorded")
+	}
+
+	if code, _, stderr := runCLI(t, "--dir", dir, "apply", file); code != 0 || strings.Contains(stderr, "already applied") {
+		t.Fatalf("Expected exit code 0 and no duplicate warning, got %d: %s", code, stderr)
+	}
+	if code, _, stderr := runCLI(t, "--dir", dir, "apply", file); code != 0 || !strings.Contains(stderr, "already applied") {
+		t.Fatalf("Expected exit code 0 and a duplicate warning, got %d: %s", code, stderr)
+	}
+
+	code, stdout, stderr := runCLI(t, "--json", "--dir", dir, "audit", "verify")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d: %s", code, stderr)
+	}
+	var result auditVerifyResult
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("Expected JSON output, got: %v", err)
+	}
+	if result.Entries != 2 || result.Head == "" {
+		t.Fatalf("Expected verified entries and a head, got %+v", result)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry audit.Entry
+	if err := json.Unmarshal([]byte(strings.SplitN(string(data), "\n", 2)[0]), &entry); err != nil {
+		t.Fatalf("Expected a JSON entry, got: %v", err)
+	}
+	if entry.Command != "apply" || entry.User != "Sam Lee" || entry.Result != audit.Applied || entry.Deltagram == "" || entry.PatchID == "" || len(entry.Changes) != 1 ||
+		entry.Changes[0].Path != "hello.txt" || entry.Changes[0].SHA256 != audit.Sum([]byte("hello")) {
+		t.Errorf("Expected the apply creating hello.txt, got %+v", entry)
+	}
+
+	os.WriteFile(logPath, []byte(strings.Replace(string(data), `"result":"applied"`, `"result":"failed"`, 1)), 0644)
+	code, stdout, _ = runCLI(t, "--json", "--dir", dir, "audit", "verify")
+	if code == 0 || !strings.Contains(stdout, `"audit_tampered"`) {
+		t.Errorf("Expected the modified log to fail verification, got %d: %s", code, stdout)
+	}
+}
+
+func TestAudit_VerifyHead(t *testing.T) {
+	dir, _ := writeDeltagram(t)
+	logPath := filepath.Join(dir, "audit.log")
+	for i := 0; i < 2; i++ {
+		if err := audit.Append(logPath, &audit.Entry{Result: audit.Applied}); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+	}
+	_, head, err := audit.Verify(logPath)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	code, stdout, stderr := runCLI(t, "--dir", dir, "audit", "verify", "--log", logPath, "--head", head)
+	if code != 0 || !strings.Contains(stdout, "2 entries verified") {
+		t.Fatalf("Expected the log to verify, got %d: %s%s", code, stdout, stderr)
+	}
+
+	if code, _, _ := runCLI(t, "--dir", dir, "audit", "verify", "--log", logPath, "--head", strings.Repeat("0", 64)); code == 0 {
+		t.Error("Expected an unknown head to fail verification")
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var contextCommand = &command{
+	name:    "context",
+	args:    "<file>...",
+	summary: "Print files in a deltagram-style envelope with line numbers for LLM context",
+	setup: func(flags *flag.FlagSet) func(g *globals, args []string) error {
+		copyOutput := flags.Bool("copy", false, "Copy the envelope to the clipboard instead of printing it")
+
+		return func(g *globals, args []string) error {
+			if len(args) == 0 {
+				return fmt.Errorf("context requires at least one file")
+			}
+			var w io.Writer = g.stdout
+			var copied strings.Builder
+			if *copyOutput {
+				w = &copied
+			}
+
+			// Every content line starts with its number, so no line can be mistaken for
+			// the boundary
+			boundary, err := contextBoundary()
+			if err != nil {
+				return err
+			}
+
+			for _, path := range args {
+				content, err := os.ReadFile(path)
+				if err != nil {
+					return fmt.Errorf("failed to read file %s: %w", path, err)
+				}
+				text := strings.TrimSuffix(strings.ReplaceAll(string(content), "\r\n", "\n"), "\n")
+				lines := 0
+				if text != "" {
+					lines = strings.Count(text, "\n") + 1
+				}
+
+				fmt.Fprintln(w, boundary)
+				fmt.Fprintf(w, "Content-Location: %s\n", filepath.ToSlash(path))
+				fmt.Fprintln(w, "Content-Type: text/plain; charset=utf-8; linesep=LF; line-numbers=true")
+				fmt.Fprintf(w, "Line-Count: %d\n\n", lines)
+				writeNumbered(w, text)
+			}
+			fmt.Fprintln(w, boundary+"--")
+			return finishCopy(g, *copyOutput, copied.String())
+		}
+	},
+}
+
+// contextBoundary returns a boundary line with a random identifier
+func contextBoundary() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate boundary: %v", err)
+	}
+	return "--====DELTAGRAM_context_" + hex.EncodeToString(id) + "====", nil
+}
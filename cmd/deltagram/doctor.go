@@ -0,0 +1,174 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/clipboard"
+	"github.com/developingjames/deltagrams/pkg/config"
+)
+
+// Statuses of a doctor check
+const (
+	doctorOK   = "ok"
+	doctorWarn = "warn"
+	doctorFail = "fail"
+)
+
+// doctorCheck is the outcome of one check of the environment
+type doctorCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail"`
+	Fix    string `json:"fix,omitempty"` // What to do about a warning or failure
+}
+
+var doctorCommand = &command{
+	name:    "doctor",
+	summary: "Check the clipboard, directory permissions, git, and formatters, and suggest fixes",
+	json:    true,
+	setup: func(flags *flag.FlagSet) func(g *globals, args []string) error {
+		return func(g *globals, args []string) error {
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("failed to get current working directory: %w", err)
+			}
+
+			checks := runDoctorChecks(g, cwd)
+			failed, warned := 0, 0
+			for _, check := range checks {
+				fmt.Fprintf(g.out(), "%-5s %s: %s\n", strings.ToUpper(check.Status), check.Name, check.Detail)
+				if check.Fix != "" {
+					fmt.Fprintf(g.out(), "      fix: %s\n", check.Fix)
+				}
+				switch check.Status {
+				case doctorFail:
+					failed++
+				case doctorWarn:
+					warned++
+				}
+			}
+			fmt.Fprintf(g.out(), "\n%d ok, %d warning(s), %d failure(s)\n", len(checks)-failed-warned, warned, failed)
+
+			if g.JSON {
+				if err := writeJSON(g.stdout, checks); err != nil {
+					return err
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d check(s) failed", failed)
+			}
+			return nil
+		}
+	},
+}
+
+// runDoctorChecks checks the environment deltagram runs in for dir. Later checks use the
+// configuration, so a configuration that does not load skips them.
+func runDoctorChecks(g *globals, dir string) []doctorCheck {
+	cfg, err := config.Load(dir)
+	if err != nil {
+		return []doctorCheck{{Name: "config", Status: doctorFail, Detail: err.Error(),
+			Fix: "correct the configuration file named in the error"}}
+	}
+
+	checks := []doctorCheck{
+		{Name: "config", Status: doctorOK, Detail: "loaded"},
+		checkClipboard(cfg),
+		checkWriteAccess(dir),
+	}
+	checks = append(checks, checkGit(g, dir)...)
+	return append(checks, checkFormatters(cfg)...)
+}
+
+// checkClipboard reports whether the configured clipboard method can work
+func checkClipboard(cfg *config.Config) doctorCheck {
+	tool, err := clipboard.Check(clipboard.Options{Method: cfg.Clipboard.Method})
+	if err != nil {
+		return doctorCheck{Name: "clipboard", Status: doctorWarn, Detail: err.Error(), Fix: clipboardHint(runtime.GOOS)}
+	}
+	return doctorCheck{Name: "clipboard", Status: doctorOK, Detail: "read with " + tool}
+}
+
+// checkWriteAccess creates and removes a file in dir, as applying a deltagram would
+func checkWriteAccess(dir string) doctorCheck {
+	file, err := os.CreateTemp(dir, ".deltagram-doctor-*")
+	if err != nil {
+		return doctorCheck{Name: "write access", Status: doctorFail, Detail: fmt.Sprintf("cannot create files in %s: %v", dir, err),
+			Fix: "run deltagram in a directory you can write to, or fix the directory's permissions"}
+	}
+	file.Close()
+	if err := os.Remove(file.Name()); err != nil {
+		return doctorCheck{Name: "write access", Status: doctorFail, Detail: fmt.Sprintf("cannot remove files in %s: %v", dir, err),
+			Fix: "fix the directory's permissions so that files can be deleted and renamed"}
+	}
+	return doctorCheck{Name: "write access", Status: doctorOK, Detail: dir + " is writable"}
+}
+
+// checkGit reports whether git is installed and dir is in a repository, and lists the
+// hooks that `apply --commit` runs
+func checkGit(g *globals, dir string) []doctorCheck {
+	if _, err := exec.LookPath("git"); err != nil {
+		return []doctorCheck{{Name: "git", Status: doctorWarn, Detail: "git not found",
+			Fix: "install git to use apply --branch, --commit, and --autostash"}}
+	}
+	version, err := runGit(g, dir, "--version")
+	if err != nil {
+		return []doctorCheck{{Name: "git", Status: doctorWarn, Detail: err.Error(), Fix: "reinstall git"}}
+	}
+	if _, err := runGit(g, dir, "rev-parse", "--is-inside-work-tree"); err != nil {
+		return []doctorCheck{{Name: "git", Status: doctorOK, Detail: version + "; not a git repository, so apply --branch and --commit are unavailable"}}
+	}
+	checks := []doctorCheck{{Name: "git", Status: doctorOK, Detail: version + " in a git repository"}}
+
+	hooksDir, err := runGit(g, dir, "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return checks
+	}
+	if !filepath.IsAbs(hooksDir) {
+		hooksDir = filepath.Join(dir, hooksDir)
+	}
+	for _, hook := range []string{"pre-commit", "prepare-commit-msg", "commit-msg", "post-commit"} {
+		info, err := os.Stat(filepath.Join(hooksDir, hook))
+		if err != nil {
+			continue
+		}
+		if runtime.GOOS != "windows" && info.Mode()&0111 == 0 {
+			checks = append(checks, doctorCheck{Name: "git hook " + hook, Status: doctorWarn, Detail: "present but not executable, so git skips it",
+				Fix: "chmod +x " + filepath.Join(hooksDir, hook)})
+			continue
+		}
+		checks = append(checks, doctorCheck{Name: "git hook " + hook, Status: doctorOK, Detail: "runs on apply --commit"})
+	}
+	return checks
+}
+
+// checkFormatters reports whether the program of each configured formatter is installed
+func checkFormatters(cfg *config.Config) []doctorCheck {
+	exts := make([]string, 0, len(cfg.Format.Formatters))
+	for ext, command := range cfg.Format.Formatters {
+		if len(command) > 0 {
+			exts = append(exts, ext)
+		}
+	}
+	sort.Strings(exts)
+
+	var checks []doctorCheck
+	for _, ext := range exts {
+		command := cfg.Format.Formatters[ext]
+		name := "formatter " + ext
+		if _, err := exec.LookPath(command[0]); err != nil {
+			checks = append(checks, doctorCheck{Name: name, Status: doctorWarn, Detail: command[0] + " not found, so apply --format leaves " + ext + " files alone",
+				Fix: fmt.Sprintf("install %s, or set format.formatters[%q] to [] in the user configuration", command[0], ext)})
+			continue
+		}
+		checks = append(checks, doctorCheck{Name: name, Status: doctorOK, Detail: strings.Join(command, " ")})
+	}
+	return checks
+}
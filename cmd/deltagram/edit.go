@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/developingjames/deltagrams/pkg/operations"
+	"github.com/developingjames/deltagrams/pkg/parser"
+	"github.com/developingjames/deltagrams/pkg/resolve"
+)
+
+// editInstructions is shown above each hunk opened in the editor
+const editInstructions = `# Part %d, hunk %d of %d in %s
+# Save the hunk unchanged to keep it, or edit it; line counts are recomputed.
+# A line holding only @@ starts a new hunk. Delete every line to drop the hunk.
+# Lines starting with # are ignored.
+`
+
+var editCommand = &command{
+	name:    "edit",
+	args:    "[file]",
+	summary: "Edit or split the content hunks of a deltagram, then check them against the current files",
+	setup: func(flags *flag.FlagSet) func(g *globals, args []string) error {
+		split := flags.Bool("split", false, "Split hunks wherever context lines separate their changes instead of opening an editor")
+		only := flags.Int("part", 0, "Edit only the hunks of this part, counting from 1")
+		parseOpts := parseFlags(flags)
+
+		return func(g *globals, args []string) error {
+			if *split && *only != 0 {
+				return fmt.Errorf("--part cannot be combined with --split")
+			}
+			if len(args) > 0 {
+				if err := checkRewritable(args[0]); err != nil {
+					return err
+				}
+			}
+			deltagram, err := readDeltagramWith(g, args, *parseOpts)
+			if err != nil {
+				return err
+			}
+			if *only < 0 || *only > len(deltagram.Parts) {
+				return fmt.Errorf("--part %d is out of range: the deltagram has %d part(s)", *only, len(deltagram.Parts))
+			}
+
+			var edited *parser.Deltagram
+			if *split {
+				var added int
+				if edited, added, err = operations.SplitHunks(deltagram); err != nil {
+					return err
+				}
+				fmt.Fprintf(g.stderr, "Split into %d more hunk(s)\n", added)
+			} else {
+				edited, err = operations.EditHunks(deltagram, func(hunk operations.HunkEdit) (string, error) {
+					if *only != 0 && hunk.Part != *only {
+						return hunk.Text, nil
+					}
+					header := fmt.Sprintf(editInstructions, hunk.Part, hunk.Hunk, hunk.Hunks, hunk.Path)
+					text, err := resolve.EditInEditor(header + hunk.Text)
+					if err != nil || text == header+hunk.Text {
+						return hunk.Text, err
+					}
+					return text, nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			formatted := edited.String()
+			if formatted == deltagram.String() {
+				fmt.Fprintln(g.stderr, "No changes")
+				return nil
+			}
+			// Re-validate the result as apply would read it
+			reparsed, err := parser.NewParser().ParseContext(g.ctx, formatted)
+			if err != nil {
+				return parseError(fmt.Errorf("edited deltagram is invalid: %w", err))
+			}
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("failed to get current working directory: %v", err)
+			}
+			warnUnmatchedHunks(g, cwd, reparsed)
+
+			if len(args) == 0 {
+				fmt.Fprint(g.stdout, formatted)
+				return nil
+			}
+			if err := os.WriteFile(args[0], []byte(formatted), 0644); err != nil {
+				return fmt.Errorf("failed to write file %s: %v", args[0], err)
+			}
+			return nil
+		}
+	},
+}
+
+// warnUnmatchedHunks reports the content hunks that do not match the files under dir, so
+// that an edit that broke a hunk is noticed before the deltagram is applied
+func warnUnmatchedHunks(g *globals, dir string, deltagram *parser.Deltagram) {
+	fs := operations.NewRealFileSystem()
+	for i, part := range deltagram.Parts {
+		if part.DeltaOperation != "content" || part.IsMessage() {
+			continue
+		}
+		results, err := operations.CheckContentPart(fs, dir, part)
+		if err != nil {
+			fmt.Fprintf(g.stderr, "Warning: part %d (%s): %v\n", i+1, part.ContentLocation, err)
+			continue
+		}
+		for _, result := range results {
+			if result.Status == operations.HunkFailed {
+				fmt.Fprintf(g.stderr, "Warning: part %d (%s), hunk %d does not match: %v\n", i+1, part.ContentLocation, result.Index, result.Err)
+			}
+		}
+	}
+}
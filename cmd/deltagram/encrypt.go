@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/developingjames/deltagrams/pkg/encrypt"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+var encryptCommand = &command{
+	name:    "encrypt",
+	args:    "[file]",
+	summary: "Encrypt deltagram from clipboard or file to --recipient keys",
+	setup: func(flags *flag.FlagSet) func(g *globals, args []string) error {
+		var recipientKeys stringList
+		flags.Var(&recipientKeys, "recipient", "Public key to encrypt to (repeatable)")
+
+		return func(g *globals, args []string) error {
+			var recipients []*encrypt.Recipient
+			for _, key := range recipientKeys {
+				recipient, err := encrypt.ParseRecipient(key)
+				if err != nil {
+					return err
+				}
+				recipients = append(recipients, recipient)
+			}
+			if len(recipients) == 0 {
+				return fmt.Errorf("at least one --recipient is required")
+			}
+
+			content, err := readInput(g, args)
+			if err != nil {
+				return err
+			}
+
+			// Refuse to encrypt something that would not apply on the other end
+			if _, err := parser.NewParser().Parse(content); err != nil {
+				return parseError(fmt.Errorf("failed to parse deltagram: %w", err))
+			}
+
+			armored, err := encrypt.Encrypt([]byte(content), recipients)
+			if err != nil {
+				return fmt.Errorf("failed to encrypt deltagram: %v", err)
+			}
+
+			fmt.Fprint(g.stdout, armored)
+			return nil
+		}
+	},
+}
+
+var keygenCommand = &command{
+	name:    "keygen",
+	summary: "Create an identity for receiving encrypted deltagrams",
+	setup: func(flags *flag.FlagSet) func(g *globals, args []string) error {
+		output := flags.String("o", "", "Identity file to write (defaults to the user identity file)")
+
+		return func(g *globals, args []string) error {
+			path := *output
+			if path == "" {
+				var err error
+				if path, err = identityPath(); err != nil {
+					return fmt.Errorf("failed to locate identity file: %v", err)
+				}
+			}
+
+			if _, err := os.Stat(path); err == nil {
+				return fmt.Errorf("identity file %s already exists", path)
+			}
+
+			identity, err := encrypt.GenerateIdentity()
+			if err != nil {
+				return err
+			}
+			recipient := identity.Recipient().String()
+
+			if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+				return fmt.Errorf("failed to create directory for %s: %v", path, err)
+			}
+			content := fmt.Sprintf("# public key: %s\n%s\n", recipient, identity)
+			if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+				return fmt.Errorf("failed to write identity file %s: %v", path, err)
+			}
+
+			fmt.Fprintf(g.stdout, "Identity written to %s\n", path)
+			fmt.Fprintf(g.stdout, "Public key: %s\n", recipient)
+			return nil
+		}
+	},
+}
+
+// identityPath returns the identity file named by DELTAGRAM_IDENTITY or the default one
+func identityPath() (string, error) {
+	if path := os.Getenv("DELTAGRAM_IDENTITY"); path != "" {
+		return path, nil
+	}
+	return encrypt.IdentityFile()
+}
+
+// decryptInput decrypts an encrypted deltagram with the user's identities
+func decryptInput(content string) (string, error) {
+	path, err := identityPath()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate identity file: %v", err)
+	}
+	identities, err := encrypt.LoadIdentities(path)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := encrypt.Decrypt(content, identities)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt deltagram: %v", err)
+	}
+	return string(plaintext), nil
+}
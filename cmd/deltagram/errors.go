@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	iofs "io/fs"
+	"os"
+
+	"github.com/developingjames/deltagrams/pkg/audit"
+	"github.com/developingjames/deltagrams/pkg/lock"
+	"github.com/developingjames/deltagrams/pkg/objectstore"
+	"github.com/developingjames/deltagrams/pkg/operations"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// Exit codes of the CLI by class of failure, so that scripts and editor plugins can branch
+// on them without reading the message
+const (
+	exitSuccess    = 0
+	exitUsage      = 1 // Bad command line, or a failure that fits no other class
+	exitParse      = 2 // The deltagram could not be parsed
+	exitValidation = 3 // The deltagram was refused or does not match the files
+	exitPartial    = 4 // Applying stopped after some files had been changed
+	exitIO         = 5 // Reading or writing a file failed
+)
+
+// exitError gives an error the exit code of its class when nothing it wraps determines one
+type exitError struct {
+	err  error
+	code int
+}
+
+func (e *exitError) Error() string { return e.err.Error() }
+
+func (e *exitError) Unwrap() error { return e.err }
+
+// exitCode returns the exit code for the error a command returned
+func exitCode(err error) int {
+	var classified *exitError
+	var mismatch *operations.ErrContextMismatch
+	var validation *operations.ValidationError
+	var pathErr *iofs.PathError
+	var linkErr *os.LinkError
+	switch {
+	case err == nil:
+		return exitSuccess
+	case errors.As(err, &classified):
+		return classified.code
+	case errors.Is(err, parser.ErrInvalidBoundary), errors.Is(err, operations.ErrInvalidHunk):
+		return exitParse
+	case errors.As(err, &mismatch), errors.As(err, &validation),
+		errors.Is(err, operations.ErrConflict),
+		errors.Is(err, operations.ErrFileNotFound),
+		errors.Is(err, operations.ErrRequirementNotMet),
+		errors.Is(err, operations.ErrSyntax),
+		errors.Is(err, operations.ErrPreconditionFailed),
+		errors.Is(err, objectstore.ErrPreconditionFailed),
+		errors.Is(err, errVerifyFailed),
+		errors.Is(err, audit.ErrTampered),
+		errors.Is(err, lock.ErrLocked):
+		return exitValidation
+	case errors.As(err, &pathErr), errors.As(err, &linkErr):
+		return exitIO
+	default:
+		return exitUsage
+	}
+}
+
+// parseError marks a failure to parse a deltagram
+func parseError(err error) error {
+	return &exitError{err: err, code: exitParse}
+}
+
+// errorResult is the JSON output of a command that fails before writing its own result
+type errorResult struct {
+	Error    string                         `json:"error"`
+	Kind     string                         `json:"kind"`
+	Part     int                            `json:"part,omitempty"` // 1-based index of the failing part
+	Line     int                            `json:"line,omitempty"` // Line of the deltagram text where that part starts
+	Mismatch *operations.ErrContextMismatch `json:"mismatch,omitempty"`
+}
+
+// newErrorResult describes err for JSON output
+func newErrorResult(err error) errorResult {
+	result := errorResult{Error: err.Error(), Kind: errorKind(err)}
+	var partErr *parser.PartError
+	if errors.As(err, &partErr) {
+		result.Part, result.Line = partErr.Index, partErr.Line
+	}
+	var mismatch *operations.ErrContextMismatch
+	if errors.As(err, &mismatch) {
+		result.Mismatch = mismatch
+	}
+	return result
+}
+
+// errorKind classifies err so that JSON consumers can branch on it without matching
+// messages
+func errorKind(err error) string {
+	var mismatch *operations.ErrContextMismatch
+	switch {
+	case errors.As(err, &mismatch):
+		return "context_mismatch"
+	case errors.Is(err, operations.ErrConflict):
+		return "conflict"
+	case errors.Is(err, operations.ErrFileNotFound):
+		return "file_not_found"
+	case errors.Is(err, operations.ErrRequirementNotMet):
+		return "requirement_not_met"
+	case errors.Is(err, operations.ErrSyntax):
+		return "syntax_error"
+	case errors.Is(err, objectstore.ErrPreconditionFailed), errors.Is(err, operations.ErrPreconditionFailed):
+		return "precondition_failed"
+	case errors.Is(err, errVerifyFailed):
+		return "verify_failed"
+	case errors.Is(err, parser.ErrInvalidBoundary):
+		return "invalid_boundary"
+	case errors.Is(err, operations.ErrInvalidHunk):
+		return "invalid_hunk"
+	case errors.Is(err, audit.ErrTampered):
+		return "audit_tampered"
+	case errors.Is(err, lock.ErrLocked):
+		return "locked"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	default:
+		return "error"
+	}
+}
+
+// writeTracker records whether anything was written, so that a command which already
+// printed its JSON result is not followed by a second document
+type writeTracker struct {
+	w     io.Writer
+	wrote bool
+}
+
+func (t *writeTracker) Write(p []byte) (int, error) {
+	t.wrote = true
+	return t.w.Write(p)
+}
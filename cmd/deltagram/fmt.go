@@ -0,0 +1,101 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/textproto"
+	"os"
+
+	"github.com/developingjames/deltagrams/pkg/config"
+	"github.com/developingjames/deltagrams/pkg/encrypt"
+	"github.com/developingjames/deltagrams/pkg/operations"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+var fmtCommand = &command{
+	name:    "fmt",
+	args:    "[file]",
+	summary: "Print a deltagram in canonical form so that changes to it diff cleanly",
+	setup: func(flags *flag.FlagSet) func(g *globals, args []string) error {
+		write := flags.Bool("w", false, "Write the result back to the file instead of printing it")
+		stampAuthor := flags.Bool("stamp-author", false, "Set the Author header to the configured identity, or else the current user")
+		parseOpts := parseFlags(flags)
+
+		return func(g *globals, args []string) error {
+			if *write && len(args) == 0 {
+				return fmt.Errorf("-w requires a file")
+			}
+			if *write {
+				if err := checkRewritable(args[0]); err != nil {
+					return err
+				}
+			}
+
+			deltagram, err := readDeltagramWith(g, args, *parseOpts)
+			if err != nil {
+				return err
+			}
+			deltagram = canonicalize(deltagram)
+			if *stampAuthor {
+				cwd, err := os.Getwd()
+				if err != nil {
+					return fmt.Errorf("failed to get current working directory: %v", err)
+				}
+				cfg, err := config.Load(cwd)
+				if err != nil {
+					return err
+				}
+				author := currentIdentity(cfg)
+				if author == "" {
+					return fmt.Errorf("--stamp-author needs an identity: set identity.name in the user configuration")
+				}
+				deltagram.SetAuthor(author)
+			}
+			formatted := deltagram.String()
+
+			if !*write {
+				fmt.Fprint(g.stdout, formatted)
+				return nil
+			}
+			if err := os.WriteFile(args[0], []byte(formatted), 0644); err != nil {
+				return fmt.Errorf("failed to write file %s: %v", args[0], err)
+			}
+			return nil
+		}
+	},
+}
+
+// checkRewritable refuses to rewrite an encrypted deltagram file, which would replace
+// the ciphertext with the decrypted deltagram
+func checkRewritable(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", path, err)
+	}
+	if encrypt.IsEncrypted(string(content)) {
+		return fmt.Errorf("cannot rewrite encrypted deltagram %s", path)
+	}
+	return nil
+}
+
+// canonicalize returns a copy of the deltagram with custom header names in canonical
+// case and the line counts of content hunks recomputed; the serializer takes care of
+// header order and line endings
+func canonicalize(deltagram *parser.Deltagram) *parser.Deltagram {
+	result := *deltagram
+	result.Parts = make([]parser.DeltagramPart, len(deltagram.Parts))
+	for i, part := range deltagram.Parts {
+		if part.Headers != nil {
+			headers := make(map[string]string, len(part.Headers))
+			for name, value := range part.Headers {
+				headers[textproto.CanonicalMIMEHeaderKey(name)] = value
+			}
+			part.Headers = headers
+		}
+		if part.DeltaOperation == "content" {
+			part.Content = operations.RecountHunks(part.Content)
+		}
+		result.Parts[i] = part
+	}
+	return &result
+}
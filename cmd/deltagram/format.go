@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/config"
+	"github.com/developingjames/deltagrams/pkg/operations"
+)
+
+// formatChanged runs the configured formatter for each changed file that still exists,
+// once per formatter with all of its files. A formatter that is not installed or that
+// fails is reported as a warning, since the deltagram itself applied. It returns the
+// files that were formatted, relative to dir.
+func formatChanged(g *globals, cfg config.FormatConfig, changes []operations.FileChange, dir string) []string {
+	byExt := make(map[string][]string)
+	for _, change := range changes {
+		if !change.Exists {
+			continue
+		}
+		ext := strings.ToLower(filepath.Ext(change.Path))
+		if len(cfg.Formatters[ext]) == 0 {
+			continue
+		}
+		byExt[ext] = append(byExt[ext], relativeTo(dir, change.Path))
+	}
+
+	exts := make([]string, 0, len(byExt))
+	for ext := range byExt {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+
+	var formatted []string
+	for _, ext := range exts {
+		command, files := cfg.Formatters[ext], byExt[ext]
+		if _, err := exec.LookPath(command[0]); err != nil {
+			fmt.Fprintf(g.stderr, "Warning: %s not found; %d %s file(s) left unformatted\n", command[0], len(files), ext)
+			continue
+		}
+
+		cmd := exec.CommandContext(g.ctx, command[0], append(command[1:], files...)...)
+		cmd.Dir = dir
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+		if err := cmd.Run(); err != nil {
+			fmt.Fprintf(g.stderr, "Warning: %s failed on %s: %v\n%s", command[0], strings.Join(files, " "), err, output.String())
+			continue
+		}
+		fmt.Fprintf(g.out(), "Formatted %d %s file(s) with %s\n", len(files), ext, command[0])
+		formatted = append(formatted, files...)
+	}
+	return formatted
+}
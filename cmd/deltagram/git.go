@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/operations"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// runGit runs git with args in dir and returns its trimmed output. A failure includes
+// what git printed.
+func runGit(g *globals, dir string, args ...string) (string, error) {
+	cmd := exec.CommandContext(g.ctx, "git", args...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("git %s failed: %s", args[0], message)
+		}
+		return "", fmt.Errorf("git %s failed: %v", args[0], err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// switchBranch switches the repository in dir to branch, creating the branch from the
+// current commit when it does not exist yet
+func switchBranch(g *globals, dir, branch string) error {
+	if _, err := runGit(g, dir, "rev-parse", "--is-inside-work-tree"); err != nil {
+		return fmt.Errorf("--branch needs a git repository: %v", err)
+	}
+	if _, err := runGit(g, dir, "check-ref-format", "--branch", branch); err != nil {
+		return fmt.Errorf("invalid branch name %q", branch)
+	}
+
+	if _, err := runGit(g, dir, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch); err == nil {
+		if _, err := runGit(g, dir, "switch", branch); err != nil {
+			return err
+		}
+		fmt.Fprintf(g.out(), "Switched to branch %s\n", branch)
+		return nil
+	}
+	if _, err := runGit(g, dir, "switch", "-c", branch); err != nil {
+		return err
+	}
+	fmt.Fprintf(g.out(), "Switched to a new branch %s\n", branch)
+	return nil
+}
+
+// commitChanges stages the files the apply changed, and only those, and commits them
+// with the deltagram's message. It returns the abbreviated hash of the commit, or "" when
+// nothing changed.
+func commitChanges(g *globals, dir string, deltagram *parser.Deltagram, changes []operations.FileChange) (string, error) {
+	if len(changes) == 0 {
+		return "", nil
+	}
+	args := []string{"add", "-A", "--"}
+	for _, change := range changes {
+		args = append(args, filepath.FromSlash(relativeTo(dir, change.Path)))
+	}
+	if _, err := runGit(g, dir, args...); err != nil {
+		return "", err
+	}
+
+	args = []string{"commit", "--quiet", "-m", commitMessage(deltagram)}
+	if metadata := deltagram.Metadata; metadata != nil && strings.Contains(metadata.Author, "<") {
+		args = append(args, "--author", metadata.Author)
+	}
+	if _, err := runGit(g, dir, args...); err != nil {
+		return "", err
+	}
+	return runGit(g, dir, "rev-parse", "--short", "HEAD")
+}
+
+// commitMessage returns the deltagram's description as a commit message, with its ticket
+// as a trailer
+func commitMessage(deltagram *parser.Deltagram) string {
+	metadata := deltagram.Metadata
+	if metadata == nil || metadata.Description == "" {
+		return "Apply deltagram"
+	}
+	message := metadata.Description
+	if metadata.Ticket != "" {
+		message += "\n\nTicket: " + metadata.Ticket
+	}
+	return message
+}
+
+// autostashMessage names the stash entries made by apply --autostash
+const autostashMessage = "deltagram autostash"
+
+// stashChanges stashes the local modifications and untracked files of the repository in
+// dir, if there are any, and returns a function that restores them. The function takes
+// the result of the apply and returns it, combined with any failure to restore the
+// stash; conflicting files are listed and the stash is kept so nothing is lost.
+func stashChanges(g *globals, dir string) (func(error) error, error) {
+	status, err := runGit(g, dir, "status", "--porcelain")
+	if err != nil {
+		return nil, fmt.Errorf("--autostash needs a git repository: %v", err)
+	}
+	if status == "" {
+		return func(err error) error { return err }, nil
+	}
+	if _, err := runGit(g, dir, "stash", "push", "--include-untracked", "-m", autostashMessage); err != nil {
+		return nil, err
+	}
+	fmt.Fprintln(g.out(), "Stashed local changes")
+
+	return func(applyErr error) error {
+		if _, err := runGit(g, dir, "stash", "pop"); err != nil {
+			conflicts, _ := runGit(g, dir, "diff", "--name-only", "--diff-filter=U")
+			message := fmt.Sprintf("restoring the stashed local changes failed: %v", err)
+			if conflicts != "" {
+				message += "; these files conflict: " + strings.Join(strings.Split(conflicts, "\n"), ", ")
+			}
+			message += "; the changes are still in the stash (see git stash list)"
+			if applyErr != nil {
+				return fmt.Errorf("%w, and %s", applyErr, message)
+			}
+			return fmt.Errorf("deltagram applied, but %s", message)
+		}
+		fmt.Fprintln(g.out(), "Restored stashed local changes")
+		return applyErr
+	}, nil
+}
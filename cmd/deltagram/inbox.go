@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/developingjames/deltagrams/pkg/config"
+	"github.com/developingjames/deltagrams/pkg/operations"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// Layout of an inbox: the deltagrams waiting in it, the subdirectories they are moved to
+// once processed, and the suffix of the result log written beside each
+const (
+	inboxApplied      = "applied"
+	inboxQuarantine   = "quarantine"
+	inboxPattern      = "*.deltagram"
+	inboxResultSuffix = ".result.json"
+)
+
+var inboxCommand = &command{
+	name:    "inbox",
+	summary: "Apply *.deltagram files as they arrive in a directory, quarantining any that fail",
+	setup: func(flags *flag.FlagSet) func(g *globals, args []string) error {
+		dir := flags.String("dir", "incoming", "Directory to watch for *.deltagram files")
+		once := flags.Bool("once", false, "Process the files already in the directory and exit instead of watching")
+		interval := flags.Duration("interval", 2*time.Second, "How often to look for new files")
+		noLock := flags.Bool("no-lock", false, "Do not take the .deltagram/lock file while applying")
+
+		return func(g *globals, args []string) error {
+			if len(args) > 0 {
+				return fmt.Errorf("usage: deltagram inbox [--dir directory] [--once]")
+			}
+
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("failed to get current working directory: %v", err)
+			}
+			cfg, err := config.Load(cwd)
+			if err != nil {
+				return err
+			}
+			inboxDir, err := filepath.Abs(*dir)
+			if err != nil {
+				return err
+			}
+			if info, err := os.Stat(inboxDir); err != nil || !info.IsDir() {
+				return fmt.Errorf("inbox directory %s does not exist", *dir)
+			}
+
+			box := &inbox{g: g, dir: inboxDir, target: cwd, cfg: cfg, opts: configOptions(cfg), noLock: *noLock}
+			if !*once {
+				fmt.Fprintf(g.stdout, "Watching %s for deltagrams; press Ctrl-C to stop\n", *dir)
+			}
+			for {
+				if err := box.processAll(); err != nil {
+					if errors.Is(err, context.Canceled) {
+						return nil
+					}
+					return err
+				}
+				if *once {
+					return nil
+				}
+				select {
+				case <-g.ctx.Done():
+					return nil
+				case <-time.After(*interval):
+				}
+			}
+		}
+	},
+}
+
+// inbox applies the deltagrams that appear in dir to target
+type inbox struct {
+	g      *globals
+	dir    string
+	target string
+	cfg    *config.Config
+	opts   operations.Options
+	noLock bool
+}
+
+// inboxResult is the per-file log written next to each processed deltagram
+type inboxResult struct {
+	File        string               `json:"file"`
+	Applied     bool                 `json:"applied"`
+	Error       string               `json:"error,omitempty"`
+	Kind        string               `json:"kind,omitempty"` // Same values as the --json error kind
+	Changes     []changeSummary      `json:"changes"`
+	Warnings    []operations.Warning `json:"warnings"`
+	ProcessedAt time.Time            `json:"processed_at"`
+}
+
+// processAll processes the deltagrams in the inbox in name order. Producers should write
+// a deltagram under another name and rename it into place, so that a file is never seen
+// half written.
+func (b *inbox) processAll() error {
+	names, err := filepath.Glob(filepath.Join(b.dir, inboxPattern))
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := b.g.ctx.Err(); err != nil {
+			return err
+		}
+		if err := b.process(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// process applies one deltagram and moves it to the applied or quarantine directory with
+// its result log. A deltagram that fails part way has its changes rolled back. The error
+// is returned only when the inbox itself cannot be updated or the apply was interrupted.
+func (b *inbox) process(path string) error {
+	result := inboxResult{File: filepath.Base(path), Warnings: []operations.Warning{}}
+
+	changes, err := b.apply(path, &result.Warnings)
+	if errors.Is(err, context.Canceled) {
+		// Leave the deltagram in the inbox to be tried again
+		return err
+	}
+	result.Changes = changeSummaries(changes, b.target)
+	result.ProcessedAt = time.Now().UTC()
+
+	dest := inboxApplied
+	if err != nil {
+		result.Error, result.Kind = err.Error(), errorKind(err)
+		dest = inboxQuarantine
+		fmt.Fprintf(b.g.stderr, "Quarantined %s: %v\n", result.File, err)
+	} else {
+		result.Applied = true
+		fmt.Fprintf(b.g.stdout, "Applied %s (%d file(s) changed)\n", result.File, len(changes))
+	}
+
+	destDir := filepath.Join(b.dir, dest)
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", destDir, err)
+	}
+	if err := os.Rename(path, filepath.Join(destDir, result.File)); err != nil {
+		return fmt.Errorf("failed to move %s: %v", result.File, err)
+	}
+
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(destDir, result.File+inboxResultSuffix), append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write result for %s: %v", result.File, err)
+	}
+	return nil
+}
+
+// apply validates and applies the deltagram at path, restoring the files it changed if it
+// fails. It returns the changes that were kept.
+func (b *inbox) apply(path string, warnings *[]operations.Warning) (changes []operations.FileChange, err error) {
+	_, deltagram, err := readDeltagramSource(b.g, []string{path}, parser.Options{})
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := acquireLock(b.target, b.noLock)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	warnIfApplied(b.g.stderr, b.cfg, b.target, deltagram)
+
+	fs := operations.NewRealFileSystem()
+	recorder := operations.NewRecordingFileSystem(fs)
+	defer func() {
+		err = auditApply(b.cfg, b.target, "inbox", b.target, deltagram, recorder.Changes(), b.target, err)
+	}()
+	opts := b.opts
+	opts.Warn = func(w operations.Warning) { *warnings = append(*warnings, w) }
+
+	err = operations.NewApplierWithOptions(recorder, opts).ApplyContext(b.g.ctx, deltagram, b.target)
+	if err == nil {
+		return recorder.Changes(), nil
+	}
+	if restoreErr := operations.RestoreChanges(fs, recorder.Changes()); restoreErr != nil {
+		return recorder.Changes(), fmt.Errorf("%w (rollback also failed: %v)", err, restoreErr)
+	}
+	return nil, err
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/developingjames/deltagrams/pkg/operations"
+)
+
+var lintCommand = &command{
+	name:    "lint",
+	args:    "[file]",
+	summary: "Report likely mistakes in a deltagram, such as wrong hunk counts or absolute paths",
+	json:    true,
+	setup: func(flags *flag.FlagSet) func(g *globals, args []string) error {
+		fix := flags.Bool("fix", false, "Correct fixable issues by rewriting the file")
+
+		return func(g *globals, args []string) error {
+			if *fix {
+				if len(args) == 0 {
+					return fmt.Errorf("--fix requires a file")
+				}
+				if err := checkRewritable(args[0]); err != nil {
+					return err
+				}
+			}
+
+			deltagram, err := readDeltagram(g, args)
+			if err != nil {
+				return err
+			}
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("failed to get current working directory: %v", err)
+			}
+			fs := operations.NewRealFileSystem()
+
+			out := g.out()
+			report := lintReport{Issues: operations.Lint(fs, cwd, deltagram)}
+			if *fix {
+				fixable := 0
+				for _, issue := range report.Issues {
+					if issue.Fixable {
+						fixable++
+					}
+				}
+				if fixable > 0 {
+					deltagram = operations.FixLint(deltagram)
+					if err := os.WriteFile(args[0], []byte(deltagram.String()), 0644); err != nil {
+						return fmt.Errorf("failed to write file %s: %v", args[0], err)
+					}
+					fmt.Fprintf(out, "Fixed %d issue(s) in %s\n", fixable, args[0])
+					report.Fixed = fixable
+					report.Issues = operations.Lint(fs, cwd, deltagram)
+				}
+			}
+
+			for _, issue := range report.Issues {
+				location := "deltagram"
+				if issue.Part > 0 {
+					location = fmt.Sprintf("part %d", issue.Part)
+				}
+				fixable := ""
+				if issue.Fixable {
+					fixable = " (fixable)"
+				}
+				fmt.Fprintf(out, "%s: %s [%s]%s\n", location, issue.Message, issue.Rule, fixable)
+			}
+
+			if g.JSON {
+				if report.Issues == nil {
+					report.Issues = []operations.LintIssue{}
+				}
+				if err := writeJSON(g.stdout, report); err != nil {
+					return err
+				}
+			}
+			if len(report.Issues) > 0 {
+				return fmt.Errorf("%d lint issue(s)", len(report.Issues))
+			}
+			return nil
+		}
+	},
+}
+
+// lintReport is the JSON output of lint
+type lintReport struct {
+	Issues []operations.LintIssue `json:"issues"`
+	Fixed  int                    `json:"fixed,omitempty"`
+}
@@ -1,12 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
-
-	"github.com/developingjames/deltagrams/pkg/clipboard"
-	"github.com/developingjames/deltagrams/pkg/operations"
-	"github.com/developingjames/deltagrams/pkg/parser"
+	"os/signal"
+	"strings"
 )
 
 // Version information (set by build flags)
@@ -17,93 +19,287 @@ var (
 )
 
 func main() {
-	if len(os.Args) < 2 {
-		showUsage()
-		os.Exit(1)
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// globals holds the options given before the command name, along with the streams
+// commands read from and write to
+type globals struct {
+	Dir    string
+	DryRun bool
+	JSON   bool
+
+	// ctx is canceled on Ctrl-C so that long operations can stop cleanly
+	ctx    context.Context
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// out returns where human-readable output goes; with --json it moves to stderr so that
+// stdout carries only the JSON document
+func (g *globals) out() io.Writer {
+	if g.JSON {
+		return g.stderr
 	}
+	return g.stdout
+}
+
+// command is a subcommand of the CLI
+type command struct {
+	name    string
+	aliases []string
+	args    string // Positional arguments shown in help
+	summary string
+	dryRun  bool // Whether the command honors --dry-run
+	json    bool // Whether the command honors --json
+	// setup registers the command's flags and returns the function that runs it
+	setup func(flags *flag.FlagSet) func(g *globals, args []string) error
+}
 
-	command := os.Args[1]
-	switch command {
-	case "apply":
-		if err := applyDeltagram(); err != nil {
-			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-			os.Exit(1)
+// commands lists every subcommand in the order shown by help
+var commands []*command
+
+func init() {
+	commands = []*command{
+		applyCommand,
+		checkCommand,
+		statCommand,
+		planCommand,
+		fmtCommand,
+		rebaseCommand,
+		splitCommand,
+		editCommand,
+		lintCommand,
+		initCommand,
+		seriesCommand,
+		inboxCommand,
+		amCommand,
+		serveCommand,
+		auditCommand,
+		pushCommand,
+		pullCommand,
+		encryptCommand,
+		keygenCommand,
+		specCommand,
+		exampleCommand,
+		promptCommand,
+		contextCommand,
+		selftestCommand,
+		doctorCommand,
+		versionCommand,
+		{
+			name:    "help",
+			aliases: []string{"--help", "-h"},
+			args:    "[command]",
+			summary: "Show help for deltagram or a command",
+			setup: func(flags *flag.FlagSet) func(g *globals, args []string) error {
+				return func(g *globals, args []string) error {
+					if len(args) == 0 {
+						showUsage(g.stdout)
+						return nil
+					}
+					cmd := findCommand(args[0])
+					if cmd == nil {
+						return fmt.Errorf("unknown command: %s", args[0])
+					}
+					showCommandHelp(g.stdout, cmd)
+					return nil
+				}
+			},
+		},
+	}
+}
+
+// findCommand returns the command with the given name or alias
+func findCommand(name string) *command {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd
+		}
+		for _, alias := range cmd.aliases {
+			if alias == name {
+				return cmd
+			}
 		}
-	case "version", "--version", "-v":
-		showVersion()
-	case "help", "--help", "-h":
-		showUsage()
-	default:
-		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", command)
-		showUsage()
-		os.Exit(1)
 	}
+	return nil
 }
 
-func applyDeltagram() error {
-	// Create dependencies
-	clipboardReader := clipboard.NewReader()
-	parser := parser.NewParser()
-	fs := operations.NewRealFileSystem()
-	applier := operations.NewApplier(fs)
-
-	var content string
-	var err error
-
-	// Check if file path is provided as argument
-	if len(os.Args) > 2 {
-		// Read deltagram from file
-		filePath := os.Args[2]
-		contentBytes, err := os.ReadFile(filePath)
-		if err != nil {
-			return fmt.Errorf("failed to read file %s: %v", filePath, err)
+// globalFlags registers the options accepted before the command name
+func globalFlags(g *globals) *flag.FlagSet {
+	flags := flag.NewFlagSet("deltagram", flag.ContinueOnError)
+	flags.StringVar(&g.Dir, "dir", "", "Run as if started in this directory")
+	flags.StringVar(&g.Dir, "C", "", "Shorthand for --dir")
+	flags.BoolVar(&g.DryRun, "dry-run", false, "Show what would change without writing any files")
+	flags.BoolVar(&g.JSON, "json", false, "Print machine-readable JSON to stdout")
+	return flags
+}
+
+// run parses global options, routes to a command, and returns the process exit code, one
+// of the exit constants
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	g := &globals{ctx: ctx, stdin: stdin, stdout: stdout, stderr: stderr}
+
+	// Aliases such as --version and --help are commands, not global options
+	if len(args) == 0 || findCommand(args[0]) == nil {
+		flags := globalFlags(g)
+		flags.SetOutput(stderr)
+		flags.Usage = func() { showUsage(stderr) }
+		if err := flags.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return exitSuccess
+			}
+			return exitUsage
 		}
-		content = string(contentBytes)
-	} else {
-		// Read deltagram from clipboard
-		content, err = clipboardReader.Read()
-		if err != nil {
-			return fmt.Errorf("failed to read clipboard: %v", err)
+		args = flags.Args()
+	}
+
+	if len(args) == 0 {
+		showUsage(stderr)
+		return exitUsage
+	}
+
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		fmt.Fprintf(stderr, "Unknown command: %s\n", args[0])
+		showUsage(stderr)
+		return exitUsage
+	}
+	if g.DryRun && !cmd.dryRun {
+		fmt.Fprintf(stderr, "Error: --dry-run is not supported by %s\n", cmd.name)
+		return exitUsage
+	}
+	if g.JSON && !cmd.json {
+		fmt.Fprintf(stderr, "Error: --json is not supported by %s\n", cmd.name)
+		return exitUsage
+	}
+
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	runner := cmd.setup(flags)
+	flags.Usage = func() { showCommandHelp(stderr, cmd) }
+	if err := flags.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitSuccess
 		}
+		return exitUsage
 	}
 
-	// Parse deltagram
-	deltagram, err := parser.Parse(content)
-	if err != nil {
-		return fmt.Errorf("failed to parse deltagram: %v", err)
+	if g.Dir != "" {
+		previous, err := os.Getwd()
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: failed to get current working directory: %v\n", err)
+			return exitIO
+		}
+		if err := os.Chdir(g.Dir); err != nil {
+			fmt.Fprintf(stderr, "Error: cannot change to directory %s: %v\n", g.Dir, err)
+			return exitUsage
+		}
+		defer os.Chdir(previous)
 	}
 
-	// Get current working directory
-	cwd, err := os.Getwd()
-	if err != nil {
-		return fmt.Errorf("failed to get current working directory: %v", err)
+	var tracker *writeTracker
+	if g.JSON {
+		// Operation handlers report progress on stdout; keep it out of the JSON document
+		original := os.Stdout
+		os.Stdout = os.Stderr
+		defer func() { os.Stdout = original }()
+
+		tracker = &writeTracker{w: stdout}
+		g.stdout = tracker
 	}
 
-	// Apply deltagram to current directory
-	if err := applier.Apply(deltagram, cwd); err != nil {
-		return fmt.Errorf("failed to apply deltagram: %v", err)
+	if err := runner(g, flags.Args()); err != nil {
+		if tracker != nil && !tracker.wrote {
+			writeJSON(stdout, newErrorResult(err))
+		}
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+		return exitCode(err)
 	}
+	return exitSuccess
+}
 
-	fmt.Println("Deltagram applied successfully")
-	return nil
+func showUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: deltagram [global options] <command> [options] [args]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands {
+		usage := cmd.name
+		if cmd.args != "" {
+			usage += " " + cmd.args
+		}
+		fmt.Fprintf(w, "  %-26s %s\n", usage, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Global options:")
+	fmt.Fprintf(w, "  %-26s %s\n", "--dir, -C <path>", "Run as if started in this directory")
+	fmt.Fprintf(w, "  %-26s %s\n", "--dry-run", "Show what would change without writing any files")
+	fmt.Fprintf(w, "  %-26s %s\n", "--json", "Print machine-readable JSON to stdout")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'deltagram help <command>' for the options of a command.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Examples:")
+	fmt.Fprintln(w, "  deltagram apply                    # Apply deltagram from clipboard")
+	fmt.Fprintln(w, "  deltagram apply file.txt           # Apply deltagram from file")
+	fmt.Fprintln(w, "  deltagram --dry-run apply file.txt # Preview changes without writing")
+	fmt.Fprintln(w, "  deltagram check file.txt           # Preflight content hunks from file")
+	fmt.Fprintln(w, "  deltagram -C ../other apply        # Apply in another directory")
+	fmt.Fprintln(w, "  deltagram version                  # Show version")
 }
 
-func showUsage() {
-	fmt.Println("Usage: deltagram <command> [file]")
-	fmt.Println()
-	fmt.Println("Commands:")
-	fmt.Println("  apply [file]    Apply deltagram from clipboard or file to current directory")
-	fmt.Println("  version, -v     Show version information")
-	fmt.Println("  help, -h        Show this help message")
-	fmt.Println()
-	fmt.Println("Examples:")
-	fmt.Println("  deltagram apply              # Apply deltagram from clipboard")
-	fmt.Println("  deltagram apply file.txt     # Apply deltagram from file")
-	fmt.Println("  deltagram version            # Show version")
+func showCommandHelp(w io.Writer, cmd *command) {
+	usage := "deltagram " + cmd.name
+	if cmd.args != "" {
+		usage += " [options] " + cmd.args
+	}
+	fmt.Fprintf(w, "Usage: %s\n\n%s\n", usage, cmd.summary)
+	if len(cmd.aliases) > 0 {
+		fmt.Fprintf(w, "\nAliases: %s\n", strings.Join(cmd.aliases, ", "))
+	}
+
+	var global []string
+	if cmd.dryRun {
+		global = append(global, "--dry-run")
+	}
+	if cmd.json {
+		global = append(global, "--json")
+	}
+	if len(global) > 0 {
+		fmt.Fprintf(w, "\nSupports global options: %s\n", strings.Join(global, ", "))
+	}
+
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	cmd.setup(flags)
+	hasFlags := false
+	flags.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		fmt.Fprintln(w, "\nOptions:")
+		flags.SetOutput(w)
+		flags.PrintDefaults()
+	}
 }
 
-func showVersion() {
-	fmt.Printf("deltagram %s\n", Version)
-	fmt.Printf("  commit: %s\n", CommitHash)
-	fmt.Printf("  built:  %s\n", BuildTime)
+var versionCommand = &command{
+	name:    "version",
+	aliases: []string{"--version", "-v"},
+	summary: "Show version information",
+	json:    true,
+	setup: func(flags *flag.FlagSet) func(g *globals, args []string) error {
+		return func(g *globals, args []string) error {
+			if g.JSON {
+				return writeJSON(g.stdout, map[string]string{
+					"version": Version,
+					"commit":  CommitHash,
+					"built":   BuildTime,
+				})
+			}
+			fmt.Fprintf(g.stdout, "deltagram %s\n", Version)
+			fmt.Fprintf(g.stdout, "  commit: %s\n", CommitHash)
+			fmt.Fprintf(g.stdout, "  built:  %s\n", BuildTime)
+			return nil
+		}
+	},
 }
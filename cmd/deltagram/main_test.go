@@ -0,0 +1,1674 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/developingjames/deltagrams"
+	"github.com/developingjames/deltagrams/pkg/clipboard"
+	"github.com/developingjames/deltagrams/pkg/config"
+	"github.com/developingjames/deltagrams/pkg/lock"
+	"github.com/developingjames/deltagrams/pkg/operations"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+const testDeltagram = "--====DELTAGRAM_0123456789abcdef====\n" +
+	"Content-Location: hello.txt\n" +
+	"Content-Type: text/plain\n" +
+	"Delta-Operation: create\n" +
+	"\n" +
+	"+++ hello.txt\n" +
+	"hello\n" +
+	"--====DELTAGRAM_0123456789abcdef====--\n"
+
+// runCLI runs the CLI with args and returns the exit code and captured output
+func runCLI(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	return runCLIWithInput(t, "", args...)
+}
+
+// runCLIWithInput is runCLI with the given text on stdin
+func runCLIWithInput(t *testing.T, input string, args ...string) (int, string, string) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(input), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// writeDeltagram stores the test deltagram in a new project directory
+func writeDeltagram(t *testing.T) (string, string) {
+	t.Helper()
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	dir := t.TempDir()
+	file := filepath.Join(t.TempDir(), "change.txt")
+	if err := os.WriteFile(file, []byte(testDeltagram), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir, file
+}
+
+func TestRun_Routing(t *testing.T) {
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{"no command", nil, 1, "", "Usage: deltagram"},
+		{"unknown command", []string{"frobnicate"}, 1, "", "Unknown command: frobnicate"},
+		{"help", []string{"help"}, 0, "Commands:", ""},
+		{"help alias", []string{"--help"}, 0, "Global options:", ""},
+		{"help for command", []string{"help", "apply"}, 0, "-show-diff", ""},
+		{"help for unknown command", []string{"help", "nope"}, 1, "", "unknown command: nope"},
+		{"version", []string{"version"}, 0, "deltagram dev", ""},
+		{"version alias", []string{"-v"}, 0, "deltagram dev", ""},
+		{"spec", []string{"spec"}, 0, "DELTAGRAM_", ""},
+		{"example", []string{"example"}, 0, "Delta-Operation: content", ""},
+		{"unknown global flag", []string{"--bogus", "version"}, 1, "", "flag provided but not defined"},
+		{"unknown command flag", []string{"apply", "--bogus"}, 1, "", "flag provided but not defined"},
+		{"dry-run unsupported", []string{"--dry-run", "keygen"}, 1, "", "--dry-run is not supported by keygen"},
+		{"json unsupported", []string{"--json", "encrypt"}, 1, "", "--json is not supported by encrypt"},
+		{"missing dir", []string{"--dir", "/nonexistent/deltagram", "version"}, 1, "", "cannot change to directory"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, stdout, stderr := runCLI(t, tt.args...)
+			if code != tt.code {
+				t.Errorf("Expected exit code %d, got %d (stderr: %s)", tt.code, code, stderr)
+			}
+			if !strings.Contains(stdout, tt.stdout) {
+				t.Errorf("Expected stdout to contain %q, got:\n%s", tt.stdout, stdout)
+			}
+			if !strings.Contains(stderr, tt.stderr) {
+				t.Errorf("Expected stderr to contain %q, got:\n%s", tt.stderr, stderr)
+			}
+		})
+	}
+}
+
+func TestRun_VersionJSON(t *testing.T) {
+	code, stdout, _ := runCLI(t, "--json", "version")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d", code)
+	}
+
+	var info map[string]string
+	if err := json.Unmarshal([]byte(stdout), &info); err != nil {
+		t.Fatalf("Expected JSON output, got %q: %v", stdout, err)
+	}
+	if info["version"] != Version {
+		t.Errorf("Expected version %q, got %q", Version, info["version"])
+	}
+}
+
+func TestRun_ApplyWithDir(t *testing.T) {
+	dir, file := writeDeltagram(t)
+
+	code, stdout, stderr := runCLI(t, "--dir", dir, "apply", file)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	if !strings.Contains(stdout, "Deltagram applied successfully") {
+		t.Errorf("Expected success message, got:\n%s", stdout)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "hello.txt"))
+	if err != nil {
+		t.Fatalf("Expected hello.txt to be created in --dir: %v", err)
+	}
+	if string(content) != "hello" {
+		t.Errorf("Expected %q, got %q", "hello", content)
+	}
+}
+
+func TestRun_ApplyErrorJSON(t *testing.T) {
+	dir, file := writeDeltagram(t)
+	if err := os.WriteFile(filepath.Join(dir, "main.txt"), []byte("one\ntwo"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gram := strings.Replace(testDeltagram,
+		"Delta-Operation: create\n\n+++ hello.txt\nhello\n",
+		"Delta-Operation: content\n\n@@ -1,2 +1,2 @@\n one\n-deux\n+TWO\n", 1)
+	gram = strings.Replace(gram, "Content-Location: hello.txt", "Content-Location: main.txt", 1)
+	if err := os.WriteFile(file, []byte(gram), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, stdout, _ := runCLI(t, "-C", dir, "--json", "apply", "--no-lock", file)
+	if code != 3 {
+		t.Fatalf("Expected exit code 3, got %d", code)
+	}
+
+	var result errorResult
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("Expected JSON output, got %q: %v", stdout, err)
+	}
+	if result.Kind != "context_mismatch" || result.Mismatch == nil {
+		t.Fatalf("Expected a context_mismatch error, got %+v", result)
+	}
+	if result.Part != 1 || result.Line != 2 {
+		t.Errorf("Expected part 1 starting at line 2, got part %d at line %d", result.Part, result.Line)
+	}
+	if result.Mismatch.File != "main.txt" || result.Mismatch.Line != 2 || result.Mismatch.Got != "two" {
+		t.Errorf("Unexpected mismatch details: %+v", *result.Mismatch)
+	}
+}
+
+func TestRun_ApplyExitCodes(t *testing.T) {
+	second := "--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: main.txt\n" +
+		"Content-Type: text/plain\n" +
+		"Delta-Operation: content\n" +
+		"\n" +
+		"@@ -1 +1 @@\n" +
+		"-one\n" +
+		"+ONE\n"
+	partial := strings.Replace(testDeltagram, "--====DELTAGRAM_0123456789abcdef====--\n", second+"--====DELTAGRAM_0123456789abcdef====--\n", 1)
+	mismatch := strings.Replace(partial, "Content-Location: hello.txt\nContent-Type: text/plain\nDelta-Operation: create\n\n+++ hello.txt\nhello\n", "", 1)
+
+	tests := []struct {
+		name      string
+		deltagram string
+		main      string // Content of main.txt, if it exists
+		code      int
+		created   bool // Whether hello.txt is left behind
+	}{
+		{"success", testDeltagram, "", 0, true},
+		{"parse error", "not a deltagram\n", "", 2, false},
+		{"validation", mismatch, "two\n", 3, false},
+		{"partial apply", partial, "", 4, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, file := writeDeltagram(t)
+			if err := os.WriteFile(file, []byte(tt.deltagram), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.main != "" {
+				if err := os.WriteFile(filepath.Join(dir, "main.txt"), []byte(tt.main), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			code, _, stderr := runCLI(t, "-C", dir, "apply", "--no-lock", file)
+			if code != tt.code {
+				t.Fatalf("Expected exit code %d, got %d (stderr: %s)", tt.code, code, stderr)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "hello.txt")); (err == nil) != tt.created {
+				t.Errorf("Expected hello.txt created=%v, got %v", tt.created, err == nil)
+			}
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"none", nil, exitSuccess},
+		{"other", errors.New("something else"), exitUsage},
+		{"invalid boundary", fmt.Errorf("failed: %w", parser.ErrInvalidBoundary), exitParse},
+		{"parse", parseError(errors.New("bad header")), exitParse},
+		{"invalid hunk", fmt.Errorf("failed to apply diff: %w: invalid hunk header format", operations.ErrInvalidHunk), exitParse},
+		{"mismatch", fmt.Errorf("failed: %w", &operations.ErrContextMismatch{Line: 1}), exitValidation},
+		{"conflict", fmt.Errorf("failed: %w: context line extends beyond original file", operations.ErrConflict), exitValidation},
+		{"refused", &operations.ValidationError{Err: errors.New("outside the base directory")}, exitValidation},
+		{"missing file", fmt.Errorf("failed: %w", operations.ErrFileNotFound), exitValidation},
+		{"locked", fmt.Errorf("%w (pass --no-lock to skip locking)", lock.ErrLocked), exitValidation},
+		{"partial", &exitError{err: fmt.Errorf("failed: %w", operations.ErrFileNotFound), code: exitPartial}, exitPartial},
+		{"io", fmt.Errorf("failed to write file: %w", &os.PathError{Op: "write", Path: "a", Err: errors.New("disk full")}), exitIO},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := exitCode(tt.err); code != tt.code {
+				t.Errorf("Expected exit code %d, got %d", tt.code, code)
+			}
+		})
+	}
+}
+
+func TestRun_ApplyWarnings(t *testing.T) {
+	dir, file := writeDeltagram(t)
+	original := "zero\none\ntwo"
+	if err := os.WriteFile(filepath.Join(dir, "main.txt"), []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The hunk names line 1 but matches at line 2
+	gram := strings.Replace(testDeltagram,
+		"Content-Location: hello.txt\nContent-Type: text/plain\nDelta-Operation: create\n\n+++ hello.txt\nhello\n",
+		"Content-Location: main.txt\nContent-Type: text/plain\nDelta-Operation: content\n\n@@ -1,2 +1,2 @@\n one\n-two\n+TWO\n", 1)
+	if err := os.WriteFile(file, []byte(gram), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, stderr := runCLI(t, "-C", dir, "apply", "--no-lock", "--warnings-as-errors", file)
+	if code != 3 || !strings.Contains(stderr, "1 warning(s) treated as errors") {
+		t.Fatalf("Expected apply to be refused, got exit %d: %s", code, stderr)
+	}
+	if content, _ := os.ReadFile(filepath.Join(dir, "main.txt")); string(content) != original {
+		t.Errorf("Expected main.txt to be untouched, got %q", content)
+	}
+
+	code, stdout, stderr := runCLI(t, "-C", dir, "--json", "apply", "--no-lock", file)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	var result applyResult
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("Expected JSON output, got %q: %v", stdout, err)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].Kind != operations.WarnFuzzy || result.Warnings[0].Part != 1 {
+		t.Errorf("Expected one fuzzy warning for part 1, got %+v", result.Warnings)
+	}
+}
+
+func TestRun_ApplyDryRunJSON(t *testing.T) {
+	dir, file := writeDeltagram(t)
+
+	code, stdout, stderr := runCLI(t, "-C", dir, "--dry-run", "--json", "apply", file)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+
+	var result applyResult
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("Expected JSON output, got %q: %v", stdout, err)
+	}
+	if !result.DryRun {
+		t.Errorf("Expected dry_run to be true")
+	}
+	if len(result.Changes) != 1 || result.Changes[0].Path != "hello.txt" || result.Changes[0].Action != "created" {
+		t.Errorf("Expected hello.txt to be reported as created, got %+v", result.Changes)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "hello.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected dry run not to create hello.txt")
+	}
+}
+
+func TestRun_SelftestJSON(t *testing.T) {
+	code, stdout, stderr := runCLI(t, "--json", "selftest")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+
+	var results []selftestResult
+	if err := json.Unmarshal([]byte(stdout), &results); err != nil {
+		t.Fatalf("Expected JSON output, got %q: %v", stdout, err)
+	}
+	if len(results) != len(selftestCases) {
+		t.Fatalf("Expected %d results, got %d", len(selftestCases), len(results))
+	}
+	for _, result := range results {
+		if !result.Passed {
+			t.Errorf("Expected %s to pass, got: %s", result.Name, result.Error)
+		}
+	}
+}
+
+func TestRun_PromptWithFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	code, stdout, stderr := runCLI(t, "-C", dir, "prompt", "--files", "main.go")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	if !strings.HasPrefix(stdout, deltagrams.Spec) {
+		t.Errorf("Expected output to start with the specification")
+	}
+	for _, want := range []string{"### main.go", "1 | package main\n2 | \n3 | func main() {}\n"} {
+		if !strings.Contains(stdout, want) {
+			t.Errorf("Expected output to contain %q, got:\n%s", want, stdout[len(deltagrams.Spec):])
+		}
+	}
+
+	code, _, stderr = runCLI(t, "-C", dir, "prompt", "--files", "missing.go")
+	if code != 5 || !strings.Contains(stderr, "failed to read file missing.go") {
+		t.Errorf("Expected missing file error, got exit %d: %s", code, stderr)
+	}
+}
+
+func TestRun_Context(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("first\r\nsecond\n"), 0644); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "empty.txt"), nil, 0644); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	code, stdout, stderr := runCLI(t, "-C", dir, "context", "a.txt", "empty.txt")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(stdout, "\n"), "\n")
+	boundary := lines[0]
+	if !strings.HasPrefix(boundary, "--====DELTAGRAM_context_") {
+		t.Fatalf("Expected a boundary line first, got %q", boundary)
+	}
+	expected := []string{
+		boundary,
+		"Content-Location: a.txt",
+		"Content-Type: text/plain; charset=utf-8; linesep=LF; line-numbers=true",
+		"Line-Count: 2",
+		"",
+		"1 | first",
+		"2 | second",
+		boundary,
+		"Content-Location: empty.txt",
+		"Content-Type: text/plain; charset=utf-8; linesep=LF; line-numbers=true",
+		"Line-Count: 0",
+		"",
+		boundary + "--",
+	}
+	if strings.Join(lines, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), stdout)
+	}
+
+	code, _, stderr = runCLI(t, "context")
+	if code != 1 || !strings.Contains(stderr, "at least one file") {
+		t.Errorf("Expected missing argument error, got exit %d: %s", code, stderr)
+	}
+}
+
+func TestRun_StatJSON(t *testing.T) {
+	dir, file := writeDeltagram(t)
+
+	code, stdout, stderr := runCLI(t, "-C", dir, "--json", "stat", file)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+
+	var report struct {
+		Operations map[string]int `json:"operations"`
+		Added      int            `json:"added"`
+		NewFiles   int            `json:"new_files"`
+		Risk       string         `json:"risk"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+		t.Fatalf("Expected JSON output, got %q: %v", stdout, err)
+	}
+	if report.Operations["create"] != 1 || report.Added != 1 || report.NewFiles != 1 || report.Risk != "low" {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+}
+
+func TestRun_Fmt(t *testing.T) {
+	dir, _ := writeDeltagram(t)
+	file := filepath.Join(dir, "messy.txt")
+	messy := "--====DELTAGRAM_0123456789abcdef====\r\n" +
+		"x-reviewer: sam\r\n" +
+		"Delta-Operation: content\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"Content-Location: a.txt\r\n" +
+		"\r\n" +
+		"@@ -1,5 +1,5 @@\r\n" +
+		" one\r\n" +
+		"-two\r\n" +
+		"+TWO\r\n" +
+		"--====DELTAGRAM_0123456789abcdef====--\r\n"
+	if err := os.WriteFile(file, []byte(messy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: a.txt\n" +
+		"Content-Type: text/plain\n" +
+		"Delta-Operation: content\n" +
+		"X-Reviewer: sam\n" +
+		"\n" +
+		"@@ -1,2 +1,2 @@\n" +
+		" one\n" +
+		"-two\n" +
+		"+TWO\n" +
+		"--====DELTAGRAM_0123456789abcdef====--\n"
+
+	code, stdout, stderr := runCLI(t, "fmt", file)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	if stdout != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, stdout)
+	}
+
+	if code, _, stderr := runCLI(t, "fmt", "-w", file); code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	written, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(written) != expected {
+		t.Errorf("Expected file rewritten in canonical form, got:\n%s", written)
+	}
+}
+
+func TestRun_Rebase(t *testing.T) {
+	dir, file := writeDeltagram(t)
+	if err := os.WriteFile(filepath.Join(dir, "main.txt"), []byte("added\none\ntwo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gram := strings.Replace(testDeltagram,
+		"Content-Location: hello.txt\nContent-Type: text/plain\nDelta-Operation: create\n\n+++ hello.txt\nhello\n",
+		"Content-Location: main.txt\nContent-Type: text/plain\nDelta-Operation: content\n\n@@ -5,2 +5,2 @@\n one\n-two\n+TWO\n", 1)
+	if err := os.WriteFile(file, []byte(gram), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, stderr := runCLI(t, "-C", dir, "rebase", "-w", file)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	if !strings.Contains(stderr, "hunk 1 of main.txt: line 5 -> 2") {
+		t.Errorf("Expected the moved hunk to be reported, got:\n%s", stderr)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "main.txt")); string(data) != "added\none\ntwo\n" {
+		t.Errorf("Expected rebase to leave main.txt untouched, got %q", data)
+	}
+
+	if code, _, stderr := runCLI(t, "-C", dir, "apply", "--no-lock", "--warnings-as-errors", file); code != 0 {
+		t.Fatalf("Expected the rebased deltagram to apply cleanly, got exit %d: %s", code, stderr)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "main.txt")); string(data) != "added\none\nTWO\n" {
+		t.Errorf("Expected the change applied, got %q", data)
+	}
+}
+
+func TestRun_Edit(t *testing.T) {
+	dir, file := writeDeltagram(t)
+	if err := os.WriteFile(filepath.Join(dir, "main.txt"), []byte("one\ntwo\nthree\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gram := strings.Replace(testDeltagram,
+		"Content-Location: hello.txt\nContent-Type: text/plain\nDelta-Operation: create\n\n+++ hello.txt\nhello\n",
+		"Content-Location: main.txt\nContent-Type: text/plain\nDelta-Operation: content\n\n@@ -1,3 +1,3 @@\n one\n-TWO\n+2\n three\n", 1)
+	if err := os.WriteFile(file, []byte(gram), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The hunk removes a line that is not in the file; the editor fixes it
+	t.Setenv("VISUAL", "")
+	t.Setenv("EDITOR", "sed -i s/^-TWO/-two/")
+	code, _, stderr := runCLI(t, "-C", dir, "edit", file)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	if strings.Contains(stderr, "Warning") {
+		t.Errorf("Expected the edited hunk to match, got:\n%s", stderr)
+	}
+	if code, _, stderr := runCLI(t, "-C", dir, "apply", "--no-lock", file); code != 0 {
+		t.Fatalf("Expected the edited deltagram to apply, got exit %d: %s", code, stderr)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "main.txt")); string(data) != "one\n2\nthree\n" {
+		t.Errorf("Expected the change applied, got %q", data)
+	}
+
+	// A hunk that does not match, here because it is already applied, is reported but
+	// the edit is still written
+	t.Setenv("EDITOR", "sed -i s/^.three/+3/")
+	code, _, stderr = runCLI(t, "-C", dir, "edit", file)
+	if code != 0 || !strings.Contains(stderr, "Warning: part 1 (main.txt), hunk 1 does not match") {
+		t.Errorf("Expected a warning about the unmatched hunk, got %d: %s", code, stderr)
+	}
+	if data, _ := os.ReadFile(file); !strings.Contains(string(data), "@@ -1,2 +1,3 @@\n one\n-two\n+2\n+3\n") {
+		t.Errorf("Expected the edit written with recounted lines, got:\n%s", data)
+	}
+}
+
+func TestRun_Split(t *testing.T) {
+	dir, file := writeDeltagram(t)
+	second := strings.Replace(testDeltagram, "--====DELTAGRAM_0123456789abcdef====--\n",
+		"--====DELTAGRAM_0123456789abcdef====\nContent-Location: docs/guide.md\nContent-Type: text/plain\nDelta-Operation: create\n\n+++ docs/guide.md\nguide\n--====DELTAGRAM_0123456789abcdef====--\n", 1)
+	if err := os.WriteFile(file, []byte(second), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "pieces")
+
+	code, stdout, stderr := runCLI(t, "split", "-o", out, file)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	for _, name := range []string{"0001-hello-txt.deltagram", "0002-docs-guide-md.deltagram"} {
+		if !strings.Contains(stdout, name) {
+			t.Errorf("Expected %s to be listed, got:\n%s", name, stdout)
+		}
+		if _, err := os.Stat(filepath.Join(out, name)); err != nil {
+			t.Errorf("Expected %s to be written: %v", name, err)
+		}
+	}
+
+	if code, _, stderr := runCLI(t, "-C", dir, "apply", filepath.Join(out, "0002-docs-guide-md.deltagram")); code != 0 {
+		t.Fatalf("Expected a piece to apply on its own, got exit %d: %s", code, stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "hello.txt")); !os.IsNotExist(err) {
+		t.Error("Expected only the piece's own file to be created")
+	}
+}
+
+func TestRun_FmtStampAuthor(t *testing.T) {
+	_, file := writeDeltagram(t)
+	userFile, err := config.UserFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Dir(userFile), 0755)
+	os.WriteFile(userFile, []byte(`{"identity": {"name": "Sam Lee", "email": "sam@example.com"}}`), 0644)
+
+	code, stdout, stderr := runCLI(t, "fmt", "--stamp-author", file)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	deltagram, err := parser.NewParser().Parse(stdout)
+	if err != nil {
+		t.Fatalf("Expected the stamped deltagram to parse, got: %v", err)
+	}
+	if deltagram.Metadata == nil || deltagram.Metadata.Author != "Sam Lee <sam@example.com>" {
+		t.Errorf("Expected the configured identity as author, got %+v", deltagram.Metadata)
+	}
+}
+
+func TestRun_LintFix(t *testing.T) {
+	dir, _ := writeDeltagram(t)
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one\ntwo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "change.txt")
+	content := "--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: /a.txt\n" +
+		"Content-Type: text/plain\n" +
+		"Delta-Operation: content\n" +
+		"\n" +
+		"@@ -1,9 +1,9 @@\n" +
+		" one\n" +
+		"-two\n" +
+		"+TWO\n" +
+		"--====DELTAGRAM_0123456789abcdef====--\n"
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, stdout, _ := runCLI(t, "-C", dir, "lint", file)
+	if code == 0 {
+		t.Fatalf("Expected lint issues to fail, got exit 0")
+	}
+	for _, rule := range []string{"[absolute_path]", "[hunk_count]", "[missing_message]"} {
+		if !strings.Contains(stdout, rule) {
+			t.Errorf("Expected %s in output, got:\n%s", rule, stdout)
+		}
+	}
+
+	code, stdout, stderr := runCLI(t, "-C", dir, "--json", "lint", "--fix", file)
+	if code == 0 {
+		t.Fatalf("Expected the unfixable issue to remain, got exit 0")
+	}
+	var report struct {
+		Issues []struct {
+			Rule string `json:"rule"`
+		} `json:"issues"`
+		Fixed int `json:"fixed"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &report); err != nil {
+		t.Fatalf("Expected JSON output, got %q (stderr: %s): %v", stdout, stderr, err)
+	}
+	if report.Fixed != 2 || len(report.Issues) != 1 || report.Issues[0].Rule != "missing_message" {
+		t.Errorf("Unexpected report: %+v", report)
+	}
+
+	fixed, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(fixed), "Content-Location: a.txt\n") || !strings.Contains(string(fixed), "@@ -1,2 +1,2 @@") {
+		t.Errorf("Expected fixed deltagram, got:\n%s", fixed)
+	}
+}
+
+func TestRun_ApplyRepair(t *testing.T) {
+	dir, _ := writeDeltagram(t)
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("one\ntwo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "change.txt")
+	content := "--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: a.txt\n" +
+		"Content-Type: text/plain\n" +
+		"Delta-Operation: modify\n" +
+		"@@ -1,5 +1,5 @@\n" +
+		" one\n" +
+		"-two\n" +
+		"+TWO\n" +
+		"--====DELTAGRAM_0123456789abcdef====--\n"
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, stderr := runCLI(t, "-C", dir, "apply", "--repair", file)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	for _, want := range []string{"missing blank line", `"modify" as "content"`, "recounted"} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("Expected repair %q on stderr, got:\n%s", want, stderr)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "one\nTWO\n" {
+		t.Errorf("Expected repaired deltagram to apply, got %q", data)
+	}
+}
+
+func TestRun_StrictOperations(t *testing.T) {
+	dir, _ := writeDeltagram(t)
+	file := filepath.Join(t.TempDir(), "change.txt")
+	content := strings.Replace(testDeltagram, "Delta-Operation: create", "Delta-Operation: add", 1)
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, stderr := runCLI(t, "-C", dir, "apply", "--strict-operations", file)
+	if code == 0 || !strings.Contains(stderr, `use "create"`) {
+		t.Fatalf("Expected the alias to be rejected, got exit %d: %s", code, stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "hello.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be written, got: %v", err)
+	}
+
+	if code, _, stderr := runCLI(t, "-C", dir, "apply", file); code != 0 {
+		t.Fatalf("Expected the alias to be accepted, got exit %d: %s", code, stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "hello.txt")); err != nil {
+		t.Errorf("Expected hello.txt to be created, got: %v", err)
+	}
+}
+
+func TestRun_ApplyNotesJSON(t *testing.T) {
+	dir, _ := writeDeltagram(t)
+	file := filepath.Join(t.TempDir(), "change.txt")
+	content := "--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: deltagram://note/hello.txt\n" +
+		"Content-Type: text/plain\n" +
+		"\n" +
+		"Greets the reader\n" +
+		testDeltagram
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, stdout, stderr := runCLI(t, "-C", dir, "--json", "apply", file)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	var result struct {
+		Notes []struct {
+			Path string `json:"path"`
+			Text string `json:"text"`
+		} `json:"notes"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("Expected JSON output, got %q: %v", stdout, err)
+	}
+	if len(result.Notes) != 1 || result.Notes[0].Path != "hello.txt" || result.Notes[0].Text != "Greets the reader" {
+		t.Errorf("Unexpected notes: %+v", result.Notes)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "deltagram:")); !os.IsNotExist(err) {
+		t.Errorf("Expected the note not to be written as a file")
+	}
+}
+
+func TestRun_ApplyAllowRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell command")
+	}
+	dir, _ := writeDeltagram(t)
+	file := filepath.Join(t.TempDir(), "change.txt")
+	content := "--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: deltagram://run\n" +
+		"Content-Type: text/plain\n" +
+		"\n" +
+		"# Record that the follow-up ran\n" +
+		"cp hello.txt ran.txt\n" +
+		testDeltagram
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		args  []string
+		input string
+		ran   bool
+	}{
+		{"not allowed", nil, "y\n", false},
+		{"declined", []string{"--allow-run"}, "n\n", false},
+		{"confirmed", []string{"--allow-run"}, "y\n", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(filepath.Join(dir, "hello.txt"))
+			os.Remove(filepath.Join(dir, "ran.txt"))
+
+			args := append(append([]string{"-C", dir, "apply"}, tt.args...), file)
+			code, stdout, stderr := runCLIWithInput(t, tt.input, args...)
+			if code != 0 {
+				t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+			}
+			_, err := os.Stat(filepath.Join(dir, "ran.txt"))
+			if ran := err == nil; ran != tt.ran {
+				t.Errorf("Expected command run to be %v, got %v\n%s", tt.ran, ran, stdout)
+			}
+		})
+	}
+}
+
+func TestRun_ApplyFormat(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell command as the formatter")
+	}
+	dir, file := writeDeltagram(t)
+
+	// A stand-in formatter that marks each file it is given
+	userFile, err := config.UserFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Dir(userFile), 0755)
+	formatter := `{"format": {"formatters": {".txt": ["sh", "-c", "for f; do echo formatted >> \"$f\"; done", "sh"]}}}`
+	if err := os.WriteFile(userFile, []byte(formatter), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, stdout, stderr := runCLI(t, "-C", dir, "--json", "apply", "--format", file)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	var result struct {
+		Formatted []string `json:"formatted"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil {
+		t.Fatalf("Expected JSON output, got %q: %v", stdout, err)
+	}
+	if len(result.Formatted) != 1 || result.Formatted[0] != "hello.txt" {
+		t.Errorf("Expected hello.txt to be formatted, got %v", result.Formatted)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "hello.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(data), "formatted\n") {
+		t.Errorf("Expected the formatter to run on hello.txt, got %q", data)
+	}
+}
+
+func TestRun_ApplyVerifySyntax(t *testing.T) {
+	dir, _ := writeDeltagram(t)
+	file := filepath.Join(t.TempDir(), "change.txt")
+	content := "--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: config.json\n" +
+		"Content-Type: application/json\n" +
+		"Delta-Operation: create\n" +
+		"\n" +
+		"+++ config.json\n" +
+		"{\"debug\": true,}\n" +
+		testDeltagram
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, stderr := runCLI(t, "-C", dir, "--dry-run", "apply", "--check-syntax", file)
+	if code != 0 || !strings.Contains(stderr, "config.json no longer parses") {
+		t.Fatalf("Expected a syntax warning, got exit code %d (stderr: %s)", code, stderr)
+	}
+
+	code, stdout, _ := runCLI(t, "-C", dir, "--json", "apply", "--verify-syntax", file)
+	if code == 0 {
+		t.Fatal("Expected a nonzero exit code")
+	}
+	var result struct {
+		Kind string `json:"kind"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &result); err != nil || result.Kind != "syntax_error" {
+		t.Errorf("Expected a syntax_error result, got %q", stdout)
+	}
+	for _, name := range []string{"config.json", "hello.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be rolled back", name)
+		}
+	}
+}
+
+func TestRun_ApplyVerifyCmd(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+
+	tests := []struct {
+		name    string
+		command string
+		code    int
+		kept    bool
+	}{
+		{"passing", "test -f hello.txt", 0, true},
+		{"failing", "echo tests failed; exit 1", 3, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, file := writeDeltagram(t)
+
+			code, _, stderr := runCLI(t, "-C", dir, "apply", "--verify-cmd", tt.command, file)
+			if code != tt.code {
+				t.Fatalf("Expected exit code %d, got %d (stderr: %s)", tt.code, code, stderr)
+			}
+			_, err := os.Stat(filepath.Join(dir, "hello.txt"))
+			if kept := err == nil; kept != tt.kept {
+				t.Errorf("Expected hello.txt kept=%v, got %v", tt.kept, kept)
+			}
+			if !tt.kept && !strings.Contains(stderr, "tests failed") {
+				t.Errorf("Expected the command output on stderr, got %q", stderr)
+			}
+		})
+	}
+}
+
+func TestRun_ApplyVerifyCmd_RollsBackTruncate(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell commands")
+	}
+	dir, file := writeDeltagram(t)
+	if err := os.WriteFile(filepath.Join(dir, "app.log"), []byte("one\ntwo\nthree\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	gram := strings.Replace(testDeltagram,
+		"Content-Location: hello.txt\nContent-Type: text/plain\nDelta-Operation: create\n\n+++ hello.txt\nhello\n",
+		"Content-Location: app.log\nContent-Type: text/plain\nDelta-Operation: truncate\nTruncate-Lines: 1\n\n--- app.log\n", 1)
+	if err := os.WriteFile(file, []byte(gram), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, stderr := runCLI(t, "-C", dir, "apply", "--verify-cmd", "false", file)
+	if code != exitValidation {
+		t.Fatalf("Expected exit code %d, got %d (stderr: %s)", exitValidation, code, stderr)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "app.log")); string(data) != "one\ntwo\nthree\n" {
+		t.Errorf("Expected the truncate to be rolled back, got %q", data)
+	}
+
+	code, stdout, stderr := runCLI(t, "-C", dir, "--json", "apply", file)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	if !strings.Contains(stdout, `"app.log"`) {
+		t.Errorf("Expected app.log among the changes, got %s", stdout)
+	}
+}
+
+func TestRun_InitWithoutTerminal(t *testing.T) {
+	dir, _ := writeDeltagram(t)
+	template := filepath.Join(t.TempDir(), "service.deltagram")
+	gram := "--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: deltagram://message\n" +
+		"Content-Type: text/plain\n" +
+		"\n" +
+		"Deltagram-Variables:\n" +
+		"  NAME\n" +
+		"  GREETING=hello\n" +
+		"--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: hello.txt\n" +
+		"Content-Type: text/plain\n" +
+		"Delta-Operation: create\n" +
+		"\n" +
+		"+++ hello.txt\n" +
+		"${GREETING} ${NAME}\n" +
+		"--====DELTAGRAM_0123456789abcdef====--\n"
+	if err := os.WriteFile(template, []byte(gram), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Piped input is not read as answers, and nothing is prompted for on stdout
+	code, stdout, stderr := runCLIWithInput(t, "world\n", "init", "--dir", dir, template)
+	if code == 0 || !strings.Contains(stderr, "missing value for variable(s): NAME") {
+		t.Fatalf("Expected NAME to be reported missing, got exit code %d (stderr: %s)", code, stderr)
+	}
+	if strings.Contains(stdout+stderr, "NAME: ") || strings.Contains(stdout+stderr, "GREETING [hello]") {
+		t.Errorf("Expected no prompts, got stdout %q and stderr %q", stdout, stderr)
+	}
+
+	code, _, stderr = runCLIWithInput(t, "", "init", "--dir", dir, "--var", "NAME=world", template)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "hello.txt")); string(data) != "hello world" {
+		t.Errorf("Expected the default and the flag value to be used, got %q", data)
+	}
+}
+
+func TestRun_InboxOnce(t *testing.T) {
+	dir, _ := writeDeltagram(t)
+	incoming := filepath.Join(dir, "incoming")
+	os.MkdirAll(incoming, 0755)
+	os.WriteFile(filepath.Join(incoming, "1-good.deltagram"), []byte(testDeltagram), 0644)
+	os.WriteFile(filepath.Join(incoming, "2-bad.deltagram"), []byte("not a deltagram"), 0644)
+	os.WriteFile(filepath.Join(incoming, "notes.txt"), []byte("ignored"), 0644)
+
+	code, stdout, stderr := runCLI(t, "-C", dir, "inbox", "--dir", "incoming", "--once")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	if !strings.Contains(stdout, "Applied 1-good.deltagram") || !strings.Contains(stderr, "Quarantined 2-bad.deltagram") {
+		t.Errorf("Unexpected output: %q / %q", stdout, stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "hello.txt")); err != nil {
+		t.Errorf("Expected hello.txt to be created: %v", err)
+	}
+
+	tests := []struct {
+		path    string
+		applied bool
+	}{
+		{"applied/1-good.deltagram", true},
+		{"quarantine/2-bad.deltagram", false},
+	}
+	for _, tt := range tests {
+		if _, err := os.Stat(filepath.Join(incoming, tt.path)); err != nil {
+			t.Errorf("Expected %s: %v", tt.path, err)
+		}
+		data, err := os.ReadFile(filepath.Join(incoming, tt.path+".result.json"))
+		if err != nil {
+			t.Fatalf("Expected a result log for %s: %v", tt.path, err)
+		}
+		var result struct {
+			Applied bool   `json:"applied"`
+			Error   string `json:"error"`
+		}
+		if err := json.Unmarshal(data, &result); err != nil || result.Applied != tt.applied || (result.Error == "") == !tt.applied {
+			t.Errorf("Unexpected result log for %s: %s", tt.path, data)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(incoming, "notes.txt")); err != nil {
+		t.Errorf("Expected other files to be left alone: %v", err)
+	}
+}
+
+func TestRun_Am(t *testing.T) {
+	dir, _ := writeDeltagram(t)
+	mbox := filepath.Join(t.TempDir(), "patches.mbox")
+	content := "From ada@example.com Mon Jan  2 15:04:05 2006\n" +
+		"From: Ada <ada@example.com>\n" +
+		"Subject: Add greeting\n" +
+		"\n" +
+		"On Monday Ada wrote:\n"
+	for _, line := range strings.Split(strings.TrimSuffix(testDeltagram, "\n"), "\n") {
+		content += "> " + line + "\n"
+	}
+	if err := os.WriteFile(mbox, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	extracted := filepath.Join(t.TempDir(), "out")
+	code, stdout, stderr := runCLI(t, "-C", dir, "am", "--extract", extracted, mbox)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	data, err := os.ReadFile(filepath.Join(extracted, "0001-add-greeting.deltagram"))
+	if err != nil || string(data) != testDeltagram {
+		t.Errorf("Expected the unquoted deltagram to be extracted, got %q (%v; stdout: %s)", data, err, stdout)
+	}
+
+	code, stdout, stderr = runCLI(t, "-C", dir, "am", mbox)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	if !strings.Contains(stdout, "Applying: Add greeting") {
+		t.Errorf("Expected the subject to be shown, got %q", stdout)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "hello.txt")); err != nil {
+		t.Errorf("Expected hello.txt to be created: %v", err)
+	}
+}
+
+func TestRun_ApplyOutputArchive(t *testing.T) {
+	tests := []struct {
+		name string
+		read func(t *testing.T, path string) map[string]string
+	}{
+		{"out.zip", readZip},
+		{"out.tar.gz", readTarGz},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, file := writeDeltagram(t)
+			archive := filepath.Join(t.TempDir(), tt.name)
+
+			code, stdout, stderr := runCLI(t, "-C", dir, "apply", "--output-archive", archive, file)
+			if code != 0 {
+				t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+			}
+			if !strings.Contains(stdout, "Wrote 1 changed file(s)") {
+				t.Errorf("Unexpected output: %q", stdout)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "hello.txt")); !os.IsNotExist(err) {
+				t.Error("Expected the directory to be left unchanged")
+			}
+
+			files := tt.read(t, archive)
+			if files["hello.txt"] != "hello" {
+				t.Errorf("Expected hello.txt in the archive, got %q", files)
+			}
+			if !strings.Contains(files["deltagram-manifest.json"], `"action": "created"`) {
+				t.Errorf("Expected a manifest, got %q", files["deltagram-manifest.json"])
+			}
+		})
+	}
+}
+
+func TestRun_ApplyTargetArchive(t *testing.T) {
+	dir, file := writeDeltagram(t)
+	archive := filepath.Join(t.TempDir(), "project.zip")
+	if err := operations.WriteArchive(archive, []operations.ArchiveEntry{{Path: "README", Data: []byte("readme"), Mode: 0644}}); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, stderr := runCLI(t, "-C", dir, "apply", "--target", archive, file)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	files := readZip(t, archive)
+	if files["hello.txt"] != "hello" || files["README"] != "readme" {
+		t.Errorf("Expected the archive to be updated, got %q", files)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "hello.txt")); !os.IsNotExist(err) {
+		t.Error("Expected the directory to be left unchanged")
+	}
+}
+
+func TestRun_ApplyWorkspace(t *testing.T) {
+	dir, _ := writeDeltagram(t)
+	workspace := t.TempDir()
+	os.MkdirAll(filepath.Join(workspace, "api"), 0755)
+	os.MkdirAll(filepath.Join(workspace, "web"), 0755)
+	mapping := filepath.Join(workspace, "workspace.json")
+	os.WriteFile(mapping, []byte(`{"roots": {"backend": "api", "frontend": "web"}}`), 0644)
+
+	file := filepath.Join(t.TempDir(), "change.txt")
+	os.WriteFile(file, []byte("--====DELTAGRAM_0123456789abcdef====\n"+
+		"Content-Location: backend/a.txt\n"+
+		"Content-Type: text/plain\n"+
+		"\n"+
+		"a\n"+
+		"--====DELTAGRAM_0123456789abcdef====\n"+
+		"Content-Location: frontend/b.txt\n"+
+		"Content-Type: text/plain\n"+
+		"\n"+
+		"b\n"+
+		"--====DELTAGRAM_0123456789abcdef====--\n"), 0644)
+
+	code, stdout, stderr := runCLI(t, "-C", dir, "apply", "--workspace", mapping, file)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	if !strings.Contains(stdout, "workspace "+mapping) {
+		t.Errorf("Expected the workspace to be named, got: %s", stdout)
+	}
+	if data, _ := os.ReadFile(filepath.Join(workspace, "api", "a.txt")); string(data) != "a" {
+		t.Errorf("Expected a.txt in the api directory, got %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(workspace, "web", "b.txt")); string(data) != "b" {
+		t.Errorf("Expected b.txt in the web directory, got %q", data)
+	}
+
+	// A path outside every root is refused before anything is applied
+	_, file = writeDeltagram(t)
+	code, _, stderr = runCLI(t, "-C", dir, "apply", "--workspace", mapping, file)
+	if code == 0 || !strings.Contains(stderr, "not under any workspace root") {
+		t.Errorf("Expected hello.txt to be refused, got %d: %s", code, stderr)
+	}
+}
+
+func TestRun_ApplyPathRewrite(t *testing.T) {
+	dir, file := writeDeltagram(t)
+
+	code, _, stderr := runCLI(t, "-C", dir, "apply", "--path-rewrite", "=>src/", file)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "src", "hello.txt")); err != nil || string(data) != "hello" {
+		t.Errorf("Expected hello.txt under src/, got %q, %v", data, err)
+	}
+
+	if code, _, _ := runCLI(t, "-C", dir, "apply", "--path-rewrite", "src", file); code == 0 {
+		t.Error("Expected a rule without => to be rejected")
+	}
+}
+
+func TestRun_ApplyStrip(t *testing.T) {
+	dir, _ := writeDeltagram(t)
+	file := filepath.Join(t.TempDir(), "git.txt")
+	content := strings.ReplaceAll(testDeltagram, "hello.txt", "b/hello.txt")
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if code, _, _ := runCLI(t, "-C", dir, "apply", "--strip", "2", file); code == 0 {
+		t.Error("Expected stripping every component of b/hello.txt to fail")
+	}
+	code, _, stderr := runCLI(t, "-C", dir, "apply", "--strip", "1", file)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "hello.txt")); err != nil || string(data) != "hello" {
+		t.Errorf("Expected hello.txt without the b/ prefix, got %q, %v", data, err)
+	}
+}
+
+func TestRun_ApplyFilters(t *testing.T) {
+	dir, file := writeDeltagram(t)
+	gram := strings.Replace(testDeltagram, "--====DELTAGRAM_0123456789abcdef====--\n",
+		"--====DELTAGRAM_0123456789abcdef====\nContent-Location: src/main_test.go\nContent-Type: text/plain\nDelta-Operation: create\n\n+++ src/main_test.go\ntest\n--====DELTAGRAM_0123456789abcdef====--\n", 1)
+	if err := os.WriteFile(file, []byte(gram), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, stderr := runCLI(t, "-C", dir, "apply", "--exclude", "**/*_test.go", "--ops", "create", file)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	if !strings.Contains(stderr, "Skipping 1 operation(s)") {
+		t.Errorf("Expected the skipped operation to be reported, got:\n%s", stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "hello.txt")); err != nil {
+		t.Errorf("Expected hello.txt to be created: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "src", "main_test.go")); !os.IsNotExist(err) {
+		t.Error("Expected the excluded test file not to be created")
+	}
+
+	if code, _, _ := runCLI(t, "-C", dir, "apply", "--only", "vendor", file); code == 0 {
+		t.Error("Expected filters that leave nothing to apply to fail")
+	}
+}
+
+// initGitRepo makes dir a git repository with an empty initial commit on main and
+// returns a function that runs git in it
+func initGitRepo(t *testing.T, dir string) func(args ...string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "--quiet", "-b", "main")
+	git("config", "user.name", "Test")
+	git("config", "user.email", "test@example.com")
+	git("commit", "--quiet", "--allow-empty", "-m", "Initial")
+	return git
+}
+
+func TestRun_ApplyBranchCommit(t *testing.T) {
+	dir, file := writeDeltagram(t)
+	git := initGitRepo(t, dir)
+
+	code, stdout, stderr := runCLI(t, "-C", dir, "apply", "--no-lock", "--branch", "llm/hello", "--commit", file)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	if !strings.Contains(stdout, "Switched to a new branch llm/hello") || !strings.Contains(stdout, "Committed ") {
+		t.Errorf("Expected the branch and commit to be reported, got:\n%s", stdout)
+	}
+	if branch := git("branch", "--show-current"); branch != "llm/hello" {
+		t.Errorf("Expected to be on llm/hello, got %s", branch)
+	}
+	if files := git("show", "--name-only", "--format=%s", "HEAD"); files != "Apply deltagram\n\nhello.txt" {
+		t.Errorf("Expected a commit of hello.txt, got:\n%s", files)
+	}
+	if status := git("status", "--porcelain"); status != "" {
+		t.Errorf("Expected a clean worktree, got:\n%s", status)
+	}
+
+	if code, _, _ := runCLI(t, "-C", dir, "--dry-run", "apply", "--branch", "other", file); code == 0 {
+		t.Error("Expected --branch to be refused with --dry-run")
+	}
+}
+
+func TestRun_ApplyAutostash(t *testing.T) {
+	dir, file := writeDeltagram(t)
+	git := initGitRepo(t, dir)
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("work in progress"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, stdout, stderr := runCLI(t, "-C", dir, "apply", "--no-lock", "--autostash", "--commit", file)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	if !strings.Contains(stdout, "Stashed local changes") || !strings.Contains(stdout, "Restored stashed local changes") {
+		t.Errorf("Expected the stash to be reported, got:\n%s", stdout)
+	}
+	if files := git("show", "--name-only", "--format=", "HEAD"); files != "hello.txt" {
+		t.Errorf("Expected only hello.txt to be committed, got %q", files)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "notes.txt")); err != nil || string(data) != "work in progress" {
+		t.Errorf("Expected the local change to be restored, got %q, %v", data, err)
+	}
+	if stashes := git("stash", "list"); stashes != "" {
+		t.Errorf("Expected the stash to be dropped, got %q", stashes)
+	}
+
+	// A local file in the way of the deltagram is kept in the stash
+	git("rm", "--quiet", "hello.txt")
+	git("commit", "--quiet", "-m", "Remove hello.txt")
+	if err := os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("mine"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	code, _, stderr = runCLI(t, "-C", dir, "apply", "--no-lock", "--autostash", file)
+	if code != 1 || !strings.Contains(stderr, "still in the stash") {
+		t.Errorf("Expected the failed restore to be reported, got exit %d: %s", code, stderr)
+	}
+	if stashes := git("stash", "list"); !strings.Contains(stashes, "deltagram autostash") {
+		t.Errorf("Expected the stash to be kept, got %q", stashes)
+	}
+}
+
+func TestRun_ApplyContainer(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell script as docker")
+	}
+	dir, file := writeDeltagram(t)
+
+	// A stand-in docker that runs "docker exec -i ID sh -c SCRIPT" on this machine
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "docker"), []byte("#!/bin/sh\nshift 3\nexec \"$@\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	app := t.TempDir()
+
+	code, stdout, stderr := runCLI(t, "-C", dir, "apply", "--container", "web", "--dir", app, file)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	if !strings.Contains(stdout, "container web:") {
+		t.Errorf("Expected the container to be named, got %q", stdout)
+	}
+	if data, err := os.ReadFile(filepath.Join(app, "hello.txt")); err != nil || string(data) != "hello" {
+		t.Errorf("Expected hello.txt in the container directory, got %q (%v)", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "hello.txt")); !os.IsNotExist(err) {
+		t.Error("Expected the current directory to be left unchanged")
+	}
+
+	if code, _, stderr := runCLI(t, "-C", dir, "apply", "--dir", app, file); code == 0 || !strings.Contains(stderr, "needs --container") {
+		t.Errorf("Expected --dir without --container to fail, got %d (stderr: %s)", code, stderr)
+	}
+}
+
+func readZip(t *testing.T, path string) map[string]string {
+	t.Helper()
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("Expected a zip archive: %v", err)
+	}
+	defer zr.Close()
+	files := make(map[string]string)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(r)
+		r.Close()
+		files[f.Name] = string(data)
+	}
+	return files
+}
+
+func readTarGz(t *testing.T, path string) map[string]string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("Expected a gzip archive: %v", err)
+	}
+	tr := tar.NewReader(gz)
+	files := make(map[string]string)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(tr)
+		files[header.Name] = string(data)
+	}
+}
+
+func TestRun_PlanAndApplyFromPlan(t *testing.T) {
+	dir, file := writeDeltagram(t)
+
+	code, stdout, stderr := runCLI(t, "-C", dir, "plan", file)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	var plan operations.Plan
+	if err := json.Unmarshal([]byte(stdout), &plan); err != nil {
+		t.Fatalf("Expected JSON output, got %q: %v", stdout, err)
+	}
+	if len(plan.Steps) != 1 || plan.Steps[0].Operation != "create" || plan.Steps[0].Target != "hello.txt" {
+		t.Fatalf("Unexpected plan steps: %+v", plan.Steps)
+	}
+	planFile := filepath.Join(t.TempDir(), "plan.json")
+	if err := os.WriteFile(planFile, []byte(stdout), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A file appearing after planning breaks the plan's preconditions
+	if err := os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("mine"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	code, _, stderr = runCLI(t, "-C", dir, "apply", "--from-plan", planFile)
+	if code != 3 || !strings.Contains(stderr, "precondition failed") {
+		t.Fatalf("Expected precondition failure, got exit %d: %s", code, stderr)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "hello.txt")); err != nil {
+		t.Fatal(err)
+	}
+	code, _, stderr = runCLI(t, "-C", dir, "apply", "--from-plan", planFile)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "hello.txt"))
+	if err != nil || string(content) != "hello" {
+		t.Errorf("Expected hello.txt to contain %q, got %q (%v)", "hello", content, err)
+	}
+}
+
+func TestRun_ApplyPlanRefusesChangedFiles(t *testing.T) {
+	dir, file := writeDeltagram(t)
+	if err := os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("before"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, stdout, stderr := runCLI(t, "-C", dir, "plan", file)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	planFile := filepath.Join(t.TempDir(), "plan.json")
+	if err := os.WriteFile(planFile, []byte(stdout), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "hello.txt"), []byte("edited"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	code, _, stderr = runCLI(t, "-C", dir, "apply", "--plan", planFile)
+	if code != 3 || !strings.Contains(stderr, "hello.txt changed since the plan was made") {
+		t.Fatalf("Expected changed file to be refused, got exit %d: %s", code, stderr)
+	}
+
+	content, _ := os.ReadFile(filepath.Join(dir, "hello.txt"))
+	if string(content) != "edited" {
+		t.Errorf("Expected hello.txt to be left alone, got %q", content)
+	}
+}
+
+func TestRun_ApplyLocked(t *testing.T) {
+	dir, file := writeDeltagram(t)
+
+	held, err := lock.Acquire(dir)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	defer held.Release()
+
+	code, _, stderr := runCLI(t, "-C", dir, "apply", file)
+	if code != 3 || !strings.Contains(stderr, "--no-lock") {
+		t.Fatalf("Expected apply to refuse a locked directory, got exit %d: %s", code, stderr)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "hello.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected nothing to be written while locked")
+	}
+
+	code, _, stderr = runCLI(t, "-C", dir, "apply", "--no-lock", file)
+	if code != 0 {
+		t.Fatalf("Expected --no-lock to apply, got exit %d: %s", code, stderr)
+	}
+}
+
+func TestRun_ApplyShowDiffWordDiff(t *testing.T) {
+	gram := strings.Replace(testDeltagram,
+		"Content-Location: hello.txt\nContent-Type: text/plain\nDelta-Operation: create\n\n+++ hello.txt\nhello\n",
+		"Content-Location: main.go\nContent-Type: text/plain\nDelta-Operation: content\n\n@@ -1,1 +1,1 @@\n-\tx := f(a)\n+\tx := f(b)\n", 1)
+
+	tests := []struct {
+		name     string
+		flags    []string
+		expected string
+	}{
+		// Output that is not a terminal stays a diff patch can apply
+		{"plain", nil, "-\tx := f(a)\n+\tx := f(b)\n"},
+		{"word diff", []string{"--word-diff", "--tab-width", "2"}, "-  x := f([-a-])\n+  x := f({+b+})\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, file := writeDeltagram(t)
+			if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("\tx := f(a)\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(file, []byte(gram), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			args := append([]string{"-C", dir, "apply", "--no-lock", "--show-diff"}, tt.flags...)
+			code, stdout, stderr := runCLI(t, append(args, file)...)
+			if code != 0 {
+				t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+			}
+			if !strings.Contains(stdout, tt.expected) {
+				t.Errorf("Expected stdout to contain %q, got:\n%s", tt.expected, stdout)
+			}
+		})
+	}
+}
+
+func TestRun_ApplyFromHistory(t *testing.T) {
+	dir, file := writeDeltagram(t)
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+
+	code, _, stderr := runCLI(t, "-C", dir, "apply", "--from-history", "1")
+	if code == 0 || !strings.Contains(stderr, "clipboard history holds 0 deltagram(s)") {
+		t.Fatalf("Expected an empty history to be refused, got exit %d: %s", code, stderr)
+	}
+
+	historyDir, err := clipboard.DefaultHistoryDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := &clipboard.History{Dir: historyDir, Size: 20}
+	older := strings.Replace(testDeltagram, "hello\n", "older\n", 1)
+	for _, content := range []string{older, testDeltagram} {
+		if err := history.Add(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	code, _, stderr = runCLI(t, "-C", dir, "apply", "--from-history", "1", file)
+	if code == 0 || !strings.Contains(stderr, "cannot be combined with a file argument") {
+		t.Fatalf("Expected a file argument to be refused, got exit %d: %s", code, stderr)
+	}
+
+	code, _, stderr = runCLI(t, "-C", dir, "apply", "--from-history", "2")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	content, _ := os.ReadFile(filepath.Join(dir, "hello.txt"))
+	if string(content) != "older" {
+		t.Errorf("Expected the older deltagram to be applied, got %q", content)
+	}
+}
+
+func TestRun_ApplyStdin(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		args  []string
+		code  int
+		err   string
+	}{
+		{"piped", testDeltagram, nil, 0, ""},
+		{"dash argument", testDeltagram, []string{"-"}, 0, ""},
+		{"empty", "\n", nil, 1, "no deltagram on stdin"},
+		{"interactive", testDeltagram, []string{"--interactive"}, 1, "pass the deltagram as a file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, _ := writeDeltagram(t)
+			args := append([]string{"-C", dir, "apply", "--no-lock"}, tt.args...)
+			code, _, stderr := runCLIWithInput(t, tt.input, args...)
+			if code != tt.code || !strings.Contains(stderr, tt.err) {
+				t.Fatalf("Expected exit code %d with %q, got %d: %s", tt.code, tt.err, code, stderr)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "hello.txt")); (err == nil) != (tt.code == 0) {
+				t.Errorf("Expected hello.txt to be created only on success, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestStdinPiped(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the null device is not a character device on Windows")
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	defer w.Close()
+	if !stdinPiped(r) {
+		t.Error("Expected a pipe to count as piped")
+	}
+
+	null, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer null.Close()
+	if stdinPiped(null) {
+		t.Error("Expected the null device not to count as piped")
+	}
+}
+
+func TestClipboardHint(t *testing.T) {
+	if hint := clipboardHint("linux"); !strings.Contains(hint, "install xclip or xsel") || !strings.Contains(hint, "deltagram apply < patch.txt") {
+		t.Errorf("Expected Linux hint to suggest installing xclip and piping, got %q", hint)
+	}
+	if hint := clipboardHint("darwin"); strings.Contains(hint, "xclip") || !strings.Contains(hint, "deltagram apply patch.txt") {
+		t.Errorf("Expected macOS hint to suggest a file without xclip, got %q", hint)
+	}
+}
+
+func TestRun_DoctorJSON(t *testing.T) {
+	dir, _ := writeDeltagram(t)
+	userConfig := filepath.Join(os.Getenv("XDG_CONFIG_HOME"), "deltagram", "config.json")
+	os.MkdirAll(filepath.Dir(userConfig), 0755)
+	os.WriteFile(userConfig, []byte(`{"format": {"formatters": {".go": [], ".ts": [], ".tsx": [], ".py": [], ".x": ["deltagram-no-such-formatter"]}}}`), 0644)
+
+	code, stdout, stderr := runCLI(t, "--json", "-C", dir, "doctor")
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	var checks []doctorCheck
+	if err := json.Unmarshal([]byte(stdout), &checks); err != nil {
+		t.Fatalf("Expected JSON on stdout, got %q: %v", stdout, err)
+	}
+	statuses := make(map[string]doctorCheck)
+	for _, check := range checks {
+		statuses[check.Name] = check
+	}
+	if statuses["config"].Status != doctorOK || statuses["write access"].Status != doctorOK {
+		t.Errorf("Expected the config and write access checks to pass, got %+v", checks)
+	}
+	if _, ok := statuses["clipboard"]; !ok {
+		t.Errorf("Expected a clipboard check, got %+v", checks)
+	}
+	formatter := statuses["formatter .x"]
+	if formatter.Status != doctorWarn || !strings.Contains(formatter.Fix, "install deltagram-no-such-formatter") {
+		t.Errorf("Expected a missing formatter warning with a fix, got %+v", formatter)
+	}
+	if _, ok := statuses["formatter .go"]; ok {
+		t.Errorf("Expected a turned off formatter not to be checked, got %+v", checks)
+	}
+}
+
+func TestRun_DoctorInvalidConfig(t *testing.T) {
+	dir, _ := writeDeltagram(t)
+	os.MkdirAll(filepath.Join(dir, ".deltagram"), 0755)
+	os.WriteFile(filepath.Join(dir, filepath.FromSlash(config.ProjectFile)), []byte("{"), 0644)
+
+	code, stdout, _ := runCLI(t, "-C", dir, "doctor")
+	if code == 0 || !strings.Contains(stdout, "FAIL  config: invalid config") {
+		t.Fatalf("Expected the config check to fail, got exit %d: %s", code, stdout)
+	}
+}
+
+func TestRun_ApplyFixWhitespace(t *testing.T) {
+	dir, file := writeDeltagram(t)
+	noisy := strings.Replace(testDeltagram, "hello\n", "hello  \n\n\n", 1)
+	if err := os.WriteFile(file, []byte(noisy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, stderr := runCLI(t, "-C", dir, "apply", "--no-lock", "--fix-whitespace", file)
+	if code != 0 {
+		t.Fatalf("Expected exit code 0, got %d (stderr: %s)", code, stderr)
+	}
+	content, _ := os.ReadFile(filepath.Join(dir, "hello.txt"))
+	if string(content) != "hello\n" {
+		t.Errorf("Expected the whitespace to be fixed, got %q", content)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/developingjames/deltagrams/pkg/operations"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// parseFlags registers the options that control how a command reads a deltagram
+func parseFlags(flags *flag.FlagSet) *parser.Options {
+	opts := &parser.Options{}
+	flags.BoolVar(&opts.Repair, "repair", false, "Fix recoverable formatting mistakes, such as a missing blank line after headers, wrong hunk counts, or a mistyped operation")
+	flags.BoolVar(&opts.StrictOperations, "strict-operations", false, "Reject operation aliases such as 'modify' or 'rm' instead of accepting them")
+	return opts
+}
+
+// readDeltagramWith is readDeltagram with the given parser options. In repair mode it
+// also fixes hunk counts and lists each repair on stderr.
+func readDeltagramWith(g *globals, args []string, opts parser.Options) (*parser.Deltagram, error) {
+	_, deltagram, err := readDeltagramSource(g, args, opts)
+	if err != nil || !opts.Repair {
+		return deltagram, err
+	}
+
+	operations.RepairHunkCounts(deltagram)
+	for _, r := range deltagram.Repairs {
+		if r.Line > 0 {
+			fmt.Fprintf(g.stderr, "Repaired line %d: %s\n", r.Line, r.Message)
+		} else {
+			fmt.Fprintf(g.stderr, "Repaired: %s\n", r.Message)
+		}
+	}
+	return deltagram, nil
+}
+
+// pathOptions holds the options that change the paths in a deltagram before it is used
+type pathOptions struct {
+	rewrites stringList
+	strip    int
+}
+
+// pathFlags registers the repeatable --path-rewrite option and --strip
+func pathFlags(flags *flag.FlagSet) *pathOptions {
+	opts := &pathOptions{}
+	flags.Var(&opts.rewrites, "path-rewrite", "Rewrite paths starting with a prefix as 'old-prefix=>new-prefix', such as '=>src/' (repeatable; the first matching rule wins)")
+	flags.IntVar(&opts.strip, "strip", 0, "Remove this many leading components from every path, like patch -p; use 1 for a/ and b/ paths from git diff")
+	return opts
+}
+
+// set reports whether any option that changes paths was given
+func (o *pathOptions) set() bool {
+	return len(o.rewrites) > 0 || o.strip != 0
+}
+
+// rewritePaths strips leading components from every path in the deltagram and then
+// applies the --path-rewrite rules
+func rewritePaths(deltagram *parser.Deltagram, opts pathOptions) (*parser.Deltagram, error) {
+	var parsed []operations.PathRewrite
+	for _, rule := range opts.rewrites {
+		r, err := operations.ParsePathRewrite(rule)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, r)
+	}
+	deltagram, err := operations.StripPaths(deltagram, opts.strip)
+	if err != nil {
+		return nil, err
+	}
+	return operations.RewritePaths(deltagram, parsed), nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/developingjames/deltagrams/pkg/operations"
+	"github.com/developingjames/deltagrams/pkg/parser"
+	"github.com/developingjames/deltagrams/pkg/variables"
+)
+
+var planCommand = &command{
+	name:    "plan",
+	args:    "[file]",
+	summary: "Print the operations a deltagram would perform as JSON for review",
+	setup: func(flags *flag.FlagSet) func(g *globals, args []string) error {
+		var vars stringList
+		flags.Var(&vars, "var", "Set a template variable as NAME=value (repeatable)")
+
+		return func(g *globals, args []string) error {
+			source, deltagram, err := readDeltagramSource(g, args, parser.Options{})
+			if err != nil {
+				return err
+			}
+			values, err := resolveVariables(deltagram, vars)
+			if err != nil {
+				return err
+			}
+
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("failed to get current working directory: %v", err)
+			}
+
+			plan, err := operations.BuildPlan(operations.NewRealFileSystem(), cwd, variables.Expand(deltagram, values))
+			if err != nil {
+				return err
+			}
+			plan.Deltagram = source
+			if len(values) > 0 {
+				plan.Variables = values
+			}
+			return writeJSON(g.stdout, plan)
+		}
+	},
+}
+
+// loadPlan reads a plan file, checks it against the files under baseDir, and returns
+// the deltagram it describes
+func loadPlan(fs operations.FileSystem, baseDir, path string) (*parser.Deltagram, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plan %s: %w", path, err)
+	}
+
+	var plan operations.Plan
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("invalid plan %s: %v", path, err)
+	}
+
+	deltagram, err := parser.NewParser().Parse(plan.Deltagram)
+	if err != nil {
+		return nil, parseError(fmt.Errorf("failed to parse deltagram in plan: %w", err))
+	}
+	deltagram = variables.Expand(deltagram, plan.Variables)
+
+	if err := operations.CheckPlan(fs, baseDir, &plan, deltagram); err != nil {
+		return nil, fmt.Errorf("refusing to apply plan %s: %w", path, err)
+	}
+	return deltagram, nil
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/developingjames/deltagrams/pkg/operations"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// A progress bar is shown only for deltagrams at least this large
+const (
+	progressMinParts = 20
+	progressMinBytes = 1 << 20
+)
+
+// progressInterval limits how often the bar is redrawn
+const progressInterval = 100 * time.Millisecond
+
+// progressBar draws apply progress on a single terminal line
+type progressBar struct {
+	w     io.Writer
+	start time.Time
+	drawn time.Time
+	now   func() time.Time
+}
+
+// newProgressBar returns a progress bar for the deltagram, or nil when w is not a
+// terminal or the deltagram is too small for progress to be worth showing
+func newProgressBar(w io.Writer, deltagram *parser.Deltagram) *progressBar {
+	if !isTerminal(w) {
+		return nil
+	}
+
+	parts, size := 0, 0
+	for _, part := range deltagram.Parts {
+		if !part.IsMessage() {
+			parts++
+			size += len(part.Content)
+		}
+	}
+	if parts < progressMinParts && size < progressMinBytes {
+		return nil
+	}
+	return &progressBar{w: w, now: time.Now}
+}
+
+// attachProgressBar sets opts.Progress to draw a bar on w when newProgressBar allows one.
+// The bar stands in for the per-file messages on stdout, which would break up its line,
+// until the returned function removes it.
+func attachProgressBar(w io.Writer, deltagram *parser.Deltagram, opts *operations.Options) func() {
+	bar := newProgressBar(w, deltagram)
+	if bar == nil {
+		return func() {}
+	}
+	opts.Progress = bar.Update
+
+	restore := silenceStdout()
+	return func() {
+		bar.Finish()
+		restore()
+	}
+}
+
+// silenceStdout discards what operation handlers print to stdout until the returned
+// function is called
+func silenceStdout() func() {
+	original := os.Stdout
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		return func() {}
+	}
+	os.Stdout = devNull
+	return func() {
+		os.Stdout = original
+		devNull.Close()
+	}
+}
+
+// Update redraws the bar; it is used as operations.Options.Progress
+func (b *progressBar) Update(p operations.Progress) {
+	now := b.now()
+	if p.Done == 0 {
+		b.start = now
+	}
+	if p.Done > 0 && p.Done < p.Total && now.Sub(b.drawn) < progressInterval {
+		return
+	}
+	b.drawn = now
+	fmt.Fprintf(b.w, "\r\033[K%s", formatProgress(p, now.Sub(b.start)))
+}
+
+// Finish removes the bar so that later output starts on a clean line
+func (b *progressBar) Finish() {
+	fmt.Fprint(b.w, "\r\033[K")
+}
+
+// formatProgress renders a progress line such as
+// "[#########-----------]  12/40 parts  1.2 MB/3.4 MB  ETA 5s"
+func formatProgress(p operations.Progress, elapsed time.Duration) string {
+	const width = 20
+
+	fraction := 1.0
+	switch {
+	case p.TotalBytes > 0:
+		fraction = float64(p.Bytes) / float64(p.TotalBytes)
+	case p.Total > 0:
+		fraction = float64(p.Done) / float64(p.Total)
+	}
+	fraction = min(max(fraction, 0), 1)
+
+	filled := int(fraction * width)
+	line := fmt.Sprintf("[%s%s] %*d/%d parts  %s/%s",
+		strings.Repeat("#", filled), strings.Repeat("-", width-filled),
+		len(fmt.Sprint(p.Total)), p.Done, p.Total, formatBytes(p.Bytes), formatBytes(p.TotalBytes))
+
+	if fraction > 0 && fraction < 1 {
+		remaining := time.Duration(float64(elapsed) * (1 - fraction) / fraction)
+		line += "  ETA " + remaining.Round(time.Second).String()
+	}
+	return line
+}
+
+// formatBytes renders a byte count with a decimal unit
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value, exp := float64(n)/unit, 0
+	for value >= unit && exp < 3 {
+		value /= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", value, "kMGT"[exp])
+}
+
+// isTerminal reports whether w is a character device such as a terminal
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/developingjames/deltagrams/pkg/operations"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func TestFormatProgress(t *testing.T) {
+	tests := []struct {
+		name     string
+		progress operations.Progress
+		elapsed  time.Duration
+		expected string
+	}{
+		{
+			name:     "start",
+			progress: operations.Progress{Total: 40, TotalBytes: 3400000},
+			expected: "[--------------------]  0/40 parts  0 B/3.4 MB",
+		},
+		{
+			name:     "halfway by bytes",
+			progress: operations.Progress{Done: 10, Total: 40, Bytes: 1700000, TotalBytes: 3400000},
+			elapsed:  5 * time.Second,
+			expected: "[##########----------] 10/40 parts  1.7 MB/3.4 MB  ETA 5s",
+		},
+		{
+			name:     "by parts when nothing is written",
+			progress: operations.Progress{Done: 1, Total: 4},
+			elapsed:  time.Second,
+			expected: "[#####---------------] 1/4 parts  0 B/0 B  ETA 3s",
+		},
+		{
+			name:     "done",
+			progress: operations.Progress{Done: 4, Total: 4, Bytes: 999, TotalBytes: 999},
+			elapsed:  time.Second,
+			expected: "[####################] 4/4 parts  999 B/999 B",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if line := formatProgress(tt.progress, tt.elapsed); line != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, line)
+			}
+		})
+	}
+}
+
+func TestProgressBar_Update(t *testing.T) {
+	var out bytes.Buffer
+	clock := time.Unix(0, 0)
+	bar := &progressBar{w: &out, now: func() time.Time { return clock }}
+
+	bar.Update(operations.Progress{Total: 3})
+	clock = clock.Add(10 * time.Millisecond)
+	bar.Update(operations.Progress{Done: 1, Total: 3}) // Throttled
+	clock = clock.Add(progressInterval)
+	bar.Update(operations.Progress{Done: 2, Total: 3})
+	bar.Update(operations.Progress{Done: 3, Total: 3}) // The last update is always drawn
+	bar.Finish()
+
+	draws := strings.Split(out.String(), "\r\033[K")
+	if len(draws) != 5 || !strings.Contains(draws[1], "0/3") || !strings.Contains(draws[2], "2/3") ||
+		!strings.Contains(draws[3], "3/3") || draws[4] != "" {
+		t.Errorf("Unexpected draws: %q", draws)
+	}
+}
+
+func TestNewProgressBar_NotTerminal(t *testing.T) {
+	parts := make([]parser.DeltagramPart, progressMinParts)
+	for i := range parts {
+		parts[i] = parser.DeltagramPart{ContentLocation: "file.txt", DeltaOperation: "create"}
+	}
+	if bar := newProgressBar(&bytes.Buffer{}, &parser.Deltagram{Parts: parts}); bar != nil {
+		t.Errorf("Expected no progress bar when output is not a terminal")
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/config"
+	"github.com/developingjames/deltagrams/pkg/encrypt"
+	"github.com/developingjames/deltagrams/pkg/operations"
+	"github.com/developingjames/deltagrams/pkg/parser"
+	"github.com/developingjames/deltagrams/pkg/templates"
+	"github.com/developingjames/deltagrams/pkg/variables"
+)
+
+var initCommand = &command{
+	name:    "init",
+	args:    "<template>",
+	summary: "Create a project from a template deltagram, prompting for variables",
+	setup: func(flags *flag.FlagSet) func(g *globals, args []string) error {
+		dir := flags.String("dir", ".", "Directory to create the project in")
+		var vars stringList
+		flags.Var(&vars, "var", "Set a template variable as NAME=value (repeatable)")
+
+		return func(g *globals, args []string) error {
+			if len(args) != 1 {
+				return fmt.Errorf("usage: deltagram init [--dir path] [--var NAME=value] <template>")
+			}
+
+			target, err := filepath.Abs(*dir)
+			if err != nil {
+				return fmt.Errorf("failed to resolve directory %s: %v", *dir, err)
+			}
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return fmt.Errorf("failed to create directory %s: %v", target, err)
+			}
+
+			cfg, err := config.Load(target)
+			if err != nil {
+				return err
+			}
+
+			source := &templates.Source{Dir: cfg.Templates.Dir, Registry: cfg.Templates.Registry}
+			if source.Dir == "" {
+				if source.Dir, err = templates.DefaultDir(); err != nil {
+					return fmt.Errorf("failed to locate template directory: %v", err)
+				}
+			}
+
+			content, err := source.Fetch(args[0])
+			if err != nil {
+				return err
+			}
+			if encrypt.IsEncrypted(content) {
+				if content, err = decryptInput(content); err != nil {
+					return err
+				}
+			}
+
+			deltagram, err := parser.NewParser().ParseContext(g.ctx, content)
+			if err != nil {
+				return fmt.Errorf("failed to parse template: %v", err)
+			}
+
+			values, err := promptVariables(g, deltagram, vars)
+			if err != nil {
+				return err
+			}
+			if deltagram, err = expandVariables(deltagram, values); err != nil {
+				return err
+			}
+
+			applier := operations.NewApplierWithOptions(operations.NewRealFileSystem(), configOptions(cfg))
+			if err := applier.ApplyContext(g.ctx, deltagram, target); err != nil {
+				return fmt.Errorf("failed to apply template: %v", err)
+			}
+
+			fmt.Fprintf(g.stdout, "Project created in %s\n", target)
+			return nil
+		}
+	},
+}
+
+// promptVariables asks on stdin for each declared variable not already set by a flag
+// or the environment, keeping the default when the answer is empty. Prompts go to stderr
+// so that stdout stays clean. When stdin is not a terminal nothing is asked: variables
+// with a default keep it and any others are reported as missing.
+func promptVariables(g *globals, deltagram *parser.Deltagram, vars stringList) (stringList, error) {
+	declared, err := variables.Declared(deltagram)
+	if err != nil {
+		return nil, err
+	}
+	if !stdinTerminal(g.stdin) {
+		return vars, nil
+	}
+
+	set := make(map[string]bool)
+	for _, v := range vars {
+		name, _, _ := strings.Cut(v, "=")
+		set[name] = true
+	}
+
+	reader := bufio.NewReader(g.stdin)
+	for _, v := range declared {
+		if set[v.Name] {
+			continue
+		}
+		if _, ok := os.LookupEnv(variables.EnvPrefix + v.Name); ok {
+			continue
+		}
+
+		if v.HasDefault {
+			fmt.Fprintf(g.stderr, "%s [%s]: ", v.Name, v.Default)
+		} else {
+			fmt.Fprintf(g.stderr, "%s: ", v.Name)
+		}
+		answer, err := reader.ReadString('\n')
+		answer = strings.TrimSpace(answer)
+		if err != nil && answer == "" {
+			// Leave the variable unset so the missing value is reported
+			fmt.Fprintln(g.stderr)
+			continue
+		}
+		if answer != "" {
+			vars = append(vars, v.Name+"="+answer)
+		}
+	}
+	return vars, nil
+}
+
+// stdinTerminal reports whether stdin is a terminal that a user can answer prompts on
+func stdinTerminal(stdin io.Reader) bool {
+	file, ok := stdin.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
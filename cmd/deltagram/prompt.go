@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/developingjames/deltagrams"
+)
+
+var promptCommand = &command{
+	name:    "prompt",
+	summary: "Print a system prompt describing the deltagram format, optionally with file contents",
+	setup: func(flags *flag.FlagSet) func(g *globals, args []string) error {
+		files := flags.String("files", "", "Comma-separated files to include with line numbers")
+		copyOutput := flags.Bool("copy", false, "Copy the prompt to the clipboard instead of printing it")
+
+		return func(g *globals, args []string) error {
+			var w io.Writer = g.stdout
+			var copied strings.Builder
+			if *copyOutput {
+				w = &copied
+			}
+
+			var paths []string
+			for _, path := range strings.Split(*files, ",") {
+				if path = strings.TrimSpace(path); path != "" {
+					paths = append(paths, path)
+				}
+			}
+
+			fmt.Fprint(w, deltagrams.Spec)
+			if len(paths) == 0 {
+				return finishCopy(g, *copyOutput, copied.String())
+			}
+
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "## Current Files")
+			fmt.Fprintln(w)
+			fmt.Fprintln(w, "The files below are shown with line numbers for reference only. The numbers and the")
+			fmt.Fprintln(w, "separator after them are not part of the file and must not appear in hunks.")
+			for _, path := range paths {
+				content, err := os.ReadFile(path)
+				if err != nil {
+					return fmt.Errorf("failed to read file %s: %w", path, err)
+				}
+				fmt.Fprintf(w, "\n### %s\n\n```\n", path)
+				writeNumbered(w, string(content))
+				fmt.Fprintln(w, "```")
+			}
+			return finishCopy(g, *copyOutput, copied.String())
+		}
+	},
+}
+
+// writeNumbered prints content with a right-aligned line number and a bar before each line
+func writeNumbered(w io.Writer, content string) {
+	content = strings.TrimSuffix(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	if content == "" {
+		return
+	}
+	lines := strings.Split(content, "\n")
+	width := len(fmt.Sprint(len(lines)))
+	for i, line := range lines {
+		fmt.Fprintf(w, "%*d | %s\n", width, i+1, line)
+	}
+}
+
+// finishCopy copies the output of a command run with --copy to the clipboard
+func finishCopy(g *globals, copyOutput bool, text string) error {
+	if !copyOutput {
+		return nil
+	}
+	return copyToClipboard(g, text)
+}
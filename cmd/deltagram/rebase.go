@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/developingjames/deltagrams/pkg/operations"
+)
+
+var rebaseCommand = &command{
+	name:    "rebase",
+	args:    "[file]",
+	summary: "Re-anchor the content hunks of a stale deltagram against the current files",
+	setup: func(flags *flag.FlagSet) func(g *globals, args []string) error {
+		write := flags.Bool("w", false, "Write the result back to the file instead of printing it")
+		parseOpts := parseFlags(flags)
+
+		return func(g *globals, args []string) error {
+			if *write && len(args) == 0 {
+				return fmt.Errorf("-w requires a file")
+			}
+			if *write {
+				if err := checkRewritable(args[0]); err != nil {
+					return err
+				}
+			}
+
+			deltagram, err := readDeltagramWith(g, args, *parseOpts)
+			if err != nil {
+				return err
+			}
+
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("failed to get current working directory: %v", err)
+			}
+
+			// Parts are applied in memory so that each is rebased onto the ones before it
+			overlay := operations.NewOverlayFileSystem(os.DirFS(cwd))
+			rebased, moved, err := operations.Rebase(overlay, operations.OverlayRoot, deltagram)
+			if err != nil {
+				return err
+			}
+			for _, hunk := range moved {
+				fmt.Fprintf(g.stderr, "Part %d, hunk %d of %s: line %d -> %d", hunk.Part, hunk.Hunk, hunk.Path, hunk.OldLine, hunk.NewLine)
+				if hunk.Context > 0 {
+					fmt.Fprintf(g.stderr, ", %d context line(s) refreshed", hunk.Context)
+				}
+				fmt.Fprintln(g.stderr)
+			}
+			formatted := rebased.String()
+
+			if !*write {
+				fmt.Fprint(g.stdout, formatted)
+				return nil
+			}
+			if err := os.WriteFile(args[0], []byte(formatted), 0644); err != nil {
+				return fmt.Errorf("failed to write file %s: %v", args[0], err)
+			}
+			return nil
+		}
+	},
+}
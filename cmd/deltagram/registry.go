@@ -0,0 +1,143 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/developingjames/deltagrams/pkg/config"
+	"github.com/developingjames/deltagrams/pkg/encrypt"
+	"github.com/developingjames/deltagrams/pkg/parser"
+	"github.com/developingjames/deltagrams/pkg/registry"
+)
+
+var pushCommand = &command{
+	name:    "push",
+	args:    "<name> [file]",
+	summary: "Share a deltagram as the next version of name in the registry",
+	json:    true,
+	setup: func(flags *flag.FlagSet) func(g *globals, args []string) error {
+		return func(g *globals, args []string) error {
+			if len(args) < 1 {
+				return fmt.Errorf("usage: deltagram push <name> [file]")
+			}
+			name := args[0]
+
+			content, err := readInput(g, args[1:])
+			if err != nil {
+				return err
+			}
+			if !encrypt.IsEncrypted(content) {
+				if _, err := parser.NewParser().Parse(content); err != nil {
+					return parseError(fmt.Errorf("failed to parse deltagram: %w", err))
+				}
+			}
+
+			client, err := registryClient()
+			if err != nil {
+				return err
+			}
+			entry, err := client.Push(g.ctx, name, []byte(content))
+			if err != nil {
+				return err
+			}
+
+			if g.JSON {
+				return writeJSON(g.stdout, entry)
+			}
+			fmt.Fprintf(g.stdout, "Pushed %s@%d (sha256:%s)\n", name, entry.Version, entry.SHA256)
+			return nil
+		}
+	},
+}
+
+var pullCommand = &command{
+	name:    "pull",
+	args:    "<name>[@version]",
+	summary: "Download a deltagram from the registry (latest by default)",
+	json:    true,
+	setup: func(flags *flag.FlagSet) func(g *globals, args []string) error {
+		output := flags.String("o", "", "Write the deltagram to a file instead of stdout")
+		list := flags.Bool("list", false, "List available versions instead of downloading")
+
+		return func(g *globals, args []string) error {
+			if len(args) != 1 {
+				return fmt.Errorf("usage: deltagram pull [-o file] [--list] <name>[@version]")
+			}
+
+			name, versionText, hasVersion := strings.Cut(args[0], "@")
+			version := 0
+			if hasVersion {
+				var err error
+				if version, err = strconv.Atoi(versionText); err != nil || version < 1 {
+					return fmt.Errorf("invalid version %q", versionText)
+				}
+			}
+
+			client, err := registryClient()
+			if err != nil {
+				return err
+			}
+
+			if *list {
+				index, err := client.Versions(g.ctx, name)
+				if err != nil {
+					return err
+				}
+				if g.JSON {
+					return writeJSON(g.stdout, index)
+				}
+				for _, entry := range index.Versions {
+					fmt.Fprintf(g.stdout, "%s@%d  %s  sha256:%s\n", name, entry.Version, entry.Created.Format(time.RFC3339), entry.SHA256)
+				}
+				return nil
+			}
+
+			if g.JSON && *output == "" {
+				return fmt.Errorf("--json requires -o or --list, since the deltagram itself is written to stdout")
+			}
+
+			content, entry, err := client.Pull(g.ctx, name, version)
+			if err != nil {
+				return err
+			}
+
+			if *output == "" {
+				fmt.Fprint(g.stdout, string(content))
+				return nil
+			}
+			if err := os.WriteFile(*output, content, 0644); err != nil {
+				return fmt.Errorf("failed to write %s: %v", *output, err)
+			}
+			if g.JSON {
+				return writeJSON(g.stdout, entry)
+			}
+			fmt.Fprintf(g.stdout, "Pulled %s@%d to %s\n", name, entry.Version, *output)
+			return nil
+		}
+	},
+}
+
+// registryClient returns a client for the configured registry, taking the URL from
+// DELTAGRAM_REGISTRY or the configuration and the token from DELTAGRAM_REGISTRY_TOKEN
+func registryClient() (*registry.Client, error) {
+	endpoint := os.Getenv("DELTAGRAM_REGISTRY")
+	if endpoint == "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get current working directory: %v", err)
+		}
+		cfg, err := config.Load(cwd)
+		if err != nil {
+			return nil, err
+		}
+		endpoint = cfg.Registry.URL
+	}
+	if endpoint == "" {
+		return nil, fmt.Errorf("no registry configured: set registry.url in the config or DELTAGRAM_REGISTRY")
+	}
+	return &registry.Client{Endpoint: endpoint, Token: os.Getenv("DELTAGRAM_REGISTRY_TOKEN")}, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// followUpCommands returns the commands listed in the deltagram's deltagram://run parts,
+// skipping blank lines and # comments
+func followUpCommands(deltagram *parser.Deltagram) []string {
+	var commands []string
+	for _, part := range deltagram.Parts {
+		if !part.IsRun() {
+			continue
+		}
+		for _, line := range strings.Split(part.Content, "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !strings.HasPrefix(line, "#") {
+				commands = append(commands, line)
+			}
+		}
+	}
+	return commands
+}
+
+// runFollowUp runs the deltagram's follow-up commands in dir once the user has passed
+// --allow-run and confirmed them, stopping at the first that fails. It returns the
+// commands that were run.
+func runFollowUp(g *globals, deltagram *parser.Deltagram, dir string, allow bool) ([]string, error) {
+	commands := followUpCommands(deltagram)
+	if len(commands) == 0 {
+		return nil, nil
+	}
+
+	out := g.out()
+	if !allow {
+		fmt.Fprintf(out, "Skipped %d follow-up command(s) from deltagram://run; pass --allow-run to run them\n", len(commands))
+		return nil, nil
+	}
+
+	fmt.Fprintln(out, "The deltagram asks to run:")
+	for _, command := range commands {
+		fmt.Fprintf(out, "  %s\n", command)
+	}
+	fmt.Fprintf(out, "Run these commands in %s? [y/N] ", dir)
+	answer, _ := bufio.NewReader(g.stdin).ReadString('\n')
+	if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+		fmt.Fprintln(out, "Follow-up commands not run")
+		return nil, nil
+	}
+
+	var ran []string
+	for _, command := range commands {
+		fmt.Fprintf(out, "$ %s\n", command)
+		cmd := shellCommand(g, command)
+		cmd.Dir = dir
+		cmd.Stdout = out
+		cmd.Stderr = g.stderr
+		if err := cmd.Run(); err != nil {
+			return ran, fmt.Errorf("follow-up command %q failed: %w", command, err)
+		}
+		ran = append(ran, command)
+	}
+	return ran, nil
+}
+
+// shellCommand returns a command that runs line with the platform's shell
+func shellCommand(g *globals, line string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(g.ctx, "cmd", "/C", line)
+	}
+	return exec.CommandContext(g.ctx, "sh", "-c", line)
+}
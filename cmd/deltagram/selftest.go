@@ -0,0 +1,151 @@
+package main
+
+import (
+	"embed"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/developingjames/deltagrams/pkg/operations"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+//go:embed selftest/*.deltagram
+var selftestFixtures embed.FS
+
+// selftestCase applies one embedded fixture to a directory seeded with setup files
+type selftestCase struct {
+	name   string
+	setup  map[string]string
+	expect map[string]string // Files that must exist afterwards, with their content
+	absent []string          // Files that must not exist afterwards
+}
+
+var selftestCases = []selftestCase{
+	{
+		name:   "create",
+		expect: map[string]string{"src/hello.txt": "hello\nworld"},
+	},
+	{
+		name:   "content",
+		setup:  map[string]string{"notes.txt": "one\ntwo\nthree\n"},
+		expect: map[string]string{"notes.txt": "one\nTWO\nthree\nfour\n"},
+	},
+	{
+		name:   "delete",
+		setup:  map[string]string{"obsolete.txt": "old\n"},
+		absent: []string{"obsolete.txt"},
+	},
+	{
+		name:   "copy",
+		setup:  map[string]string{"original.txt": "same\n"},
+		expect: map[string]string{"original.txt": "same\n", "copy.txt": "same\n"},
+	},
+	{
+		name:   "move",
+		setup:  map[string]string{"old.txt": "keep\nchange\n"},
+		expect: map[string]string{"new/name.txt": "keep\nchanged\n"},
+		absent: []string{"old.txt"},
+	},
+}
+
+// selftestResult is the outcome of one case
+type selftestResult struct {
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	Error  string `json:"error,omitempty"`
+}
+
+var selftestCommand = &command{
+	name:    "selftest",
+	summary: "Apply built-in fixtures for every operation in a temporary directory",
+	json:    true,
+	setup: func(flags *flag.FlagSet) func(g *globals, args []string) error {
+		return func(g *globals, args []string) error {
+			var results []selftestResult
+			failed := 0
+			for _, tc := range selftestCases {
+				result := selftestResult{Name: tc.name, Passed: true}
+				if err := runSelftestCase(tc); err != nil {
+					result.Passed = false
+					result.Error = err.Error()
+					failed++
+					fmt.Fprintf(g.out(), "FAIL  %s: %v\n", tc.name, err)
+				} else {
+					fmt.Fprintf(g.out(), "PASS  %s\n", tc.name)
+				}
+				results = append(results, result)
+			}
+
+			fmt.Fprintf(g.out(), "\n%d passed, %d failed\n", len(results)-failed, failed)
+			if g.JSON {
+				if err := writeJSON(g.stdout, results); err != nil {
+					return err
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d self-test(s) failed", failed)
+			}
+			return nil
+		}
+	},
+}
+
+// runSelftestCase applies a fixture in a fresh temporary directory on the real file
+// system and checks the resulting files
+func runSelftestCase(tc selftestCase) error {
+	dir, err := os.MkdirTemp("", "deltagram-selftest-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, content := range tc.setup {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+
+	fixture, err := selftestFixtures.ReadFile("selftest/" + tc.name + ".deltagram")
+	if err != nil {
+		return fmt.Errorf("missing fixture: %v", err)
+	}
+	deltagram, err := parser.NewParser().Parse(string(fixture))
+	if err != nil {
+		return fmt.Errorf("failed to parse fixture: %v", err)
+	}
+
+	// Operation handlers report progress on stdout, which would clutter the report
+	stdout := os.Stdout
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err == nil {
+		os.Stdout = devNull
+		defer devNull.Close()
+	}
+	err = operations.NewApplier(operations.NewRealFileSystem()).Apply(deltagram, dir)
+	os.Stdout = stdout
+	if err != nil {
+		return fmt.Errorf("apply failed: %v", err)
+	}
+
+	for name, expected := range tc.expect {
+		content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			return fmt.Errorf("expected %s to exist: %v", name, err)
+		}
+		if string(content) != expected {
+			return fmt.Errorf("expected %s to contain %q, got %q", name, expected, content)
+		}
+	}
+	for _, name := range tc.absent {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); !os.IsNotExist(err) {
+			return fmt.Errorf("expected %s to be removed", name)
+		}
+	}
+	return nil
+}
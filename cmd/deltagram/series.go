@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/config"
+	"github.com/developingjames/deltagrams/pkg/operations"
+	"github.com/developingjames/deltagrams/pkg/series"
+)
+
+var seriesCommand = &command{
+	name:    "series",
+	args:    "<apply|status|pop>",
+	summary: "Apply or revert the deltagrams listed in .deltagram/series in order",
+	json:    true,
+	setup: func(flags *flag.FlagSet) func(g *globals, args []string) error {
+		return func(g *globals, args []string) error {
+			if len(args) < 1 {
+				return fmt.Errorf("usage: deltagram series <apply|status|pop> [options]")
+			}
+
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("failed to get current working directory: %v", err)
+			}
+			cfg, err := config.Load(cwd)
+			if err != nil {
+				return err
+			}
+			stack := series.NewStack(operations.NewRealFileSystem(), cwd, configOptions(cfg))
+
+			subcommand := args[0]
+			subflags := flag.NewFlagSet("series "+subcommand, flag.ContinueOnError)
+			subflags.SetOutput(g.stderr)
+			switch subcommand {
+			case "apply":
+				count := subflags.Int("n", 0, "Apply at most this many deltagrams (default all remaining)")
+				noLock := subflags.Bool("no-lock", false, "Do not take the .deltagram/lock file")
+				if err := subflags.Parse(args[1:]); err != nil {
+					return err
+				}
+				release, err := acquireLock(cwd, *noLock)
+				if err != nil {
+					return err
+				}
+				defer release()
+				return seriesApply(g, stack, *count)
+			case "status":
+				if err := subflags.Parse(args[1:]); err != nil {
+					return err
+				}
+				return seriesStatus(g, stack)
+			case "pop":
+				all := subflags.Bool("all", false, "Revert every applied deltagram")
+				force := subflags.Bool("force", false, "Revert even if files were modified after apply")
+				noLock := subflags.Bool("no-lock", false, "Do not take the .deltagram/lock file")
+				if err := subflags.Parse(args[1:]); err != nil {
+					return err
+				}
+				release, err := acquireLock(cwd, *noLock)
+				if err != nil {
+					return err
+				}
+				defer release()
+				return seriesPop(g, stack, *all, *force)
+			default:
+				return fmt.Errorf("unknown series command: %s", subcommand)
+			}
+		}
+	},
+}
+
+func seriesApply(g *globals, stack *series.Stack, count int) error {
+	statuses, err := stack.Status()
+	if err != nil {
+		return err
+	}
+	remaining := 0
+	for _, status := range statuses {
+		if !status.Applied {
+			remaining++
+		}
+	}
+	if count > 0 && count < remaining {
+		remaining = count
+	}
+
+	applied := []string{}
+	for i := 0; i < remaining; i++ {
+		// An interrupted deltagram is rolled back, so the series stays consistent
+		name, err := stack.PushContext(g.ctx)
+		if errors.Is(err, context.Canceled) {
+			return fmt.Errorf("interrupted after applying %d of %d deltagrams: %v", len(applied), remaining, err)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(g.out(), "Applied %s\n", name)
+		applied = append(applied, name)
+	}
+
+	if g.JSON {
+		return writeJSON(g.stdout, map[string][]string{"applied": applied})
+	}
+	if len(applied) == 0 {
+		fmt.Fprintln(g.stdout, "All deltagrams in the series are applied")
+	}
+	return nil
+}
+
+func seriesStatus(g *globals, stack *series.Stack) error {
+	statuses, err := stack.Status()
+	if err != nil {
+		return err
+	}
+
+	if g.JSON {
+		type entry struct {
+			Name     string `json:"name"`
+			Applied  bool   `json:"applied"`
+			Modified bool   `json:"modified"`
+			Changed  bool   `json:"changed"`
+		}
+		entries := make([]entry, 0, len(statuses))
+		for _, status := range statuses {
+			entries = append(entries, entry(status))
+		}
+		return writeJSON(g.stdout, entries)
+	}
+
+	for _, status := range statuses {
+		state := "unapplied"
+		if status.Applied {
+			state = "applied"
+		}
+		var notes []string
+		if status.Modified {
+			notes = append(notes, "files modified since apply")
+		}
+		if status.Changed {
+			notes = append(notes, "deltagram changed since apply")
+		}
+		if len(notes) > 0 {
+			fmt.Fprintf(g.stdout, "%-10s %s (%s)\n", state, status.Name, strings.Join(notes, "; "))
+		} else {
+			fmt.Fprintf(g.stdout, "%-10s %s\n", state, status.Name)
+		}
+	}
+	return nil
+}
+
+func seriesPop(g *globals, stack *series.Stack, all, force bool) error {
+	reverted := []string{}
+	for {
+		name, err := stack.Pop(force)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(g.out(), "Reverted %s\n", name)
+		reverted = append(reverted, name)
+
+		state, err := stack.State()
+		if err != nil {
+			return err
+		}
+		if !all || len(state.Applied) == 0 {
+			break
+		}
+	}
+
+	if g.JSON {
+		return writeJSON(g.stdout, map[string][]string{"reverted": reverted})
+	}
+	return nil
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/config"
+	"github.com/developingjames/deltagrams/pkg/operations"
+	"github.com/developingjames/deltagrams/pkg/parser"
+	"github.com/developingjames/deltagrams/pkg/rpc"
+)
+
+var serveCommand = &command{
+	name:    "serve",
+	summary: "Serve parse, validate, plan, and apply over gRPC (see pkg/rpc/deltagram.proto)",
+	setup: func(flags *flag.FlagSet) func(g *globals, args []string) error {
+		listen := flags.String("listen", "localhost:50051", "Address to listen on")
+		tlsCert := flags.String("tls-cert", "", "Serve over TLS with this PEM certificate file")
+		tlsKey := flags.String("tls-key", "", "Private key file for --tls-cert")
+		noLock := flags.Bool("no-lock", false, "Do not take the .deltagram/lock file while applying")
+		tokenFile := flags.String("token-file", "", "Read the bearer token clients must send from this file instead of $DELTAGRAM_SERVE_TOKEN")
+
+		return func(g *globals, args []string) error {
+			if len(args) > 0 {
+				return fmt.Errorf("usage: deltagram serve [--listen address] [--tls-cert file --tls-key file] [--token-file file]")
+			}
+			if (*tlsCert == "") != (*tlsKey == "") {
+				return fmt.Errorf("--tls-cert and --tls-key must be given together")
+			}
+
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("failed to get current working directory: %v", err)
+			}
+			cfg, err := config.Load(cwd)
+			if err != nil {
+				return err
+			}
+
+			var tlsConfig *tls.Config
+			if *tlsCert != "" {
+				cert, err := tls.LoadX509KeyPair(*tlsCert, *tlsKey)
+				if err != nil {
+					return fmt.Errorf("failed to load TLS certificate: %v", err)
+				}
+				tlsConfig = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+			}
+
+			token, generated, err := serveToken(*tokenFile, os.Getenv)
+			if err != nil {
+				return err
+			}
+
+			l, err := net.Listen("tcp", *listen)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(g.stdout, "Serving %s for %s on %s; press Ctrl-C to stop\n", rpc.ServiceName, cwd, l.Addr())
+			if generated {
+				fmt.Fprintf(g.stdout, "Clients must send the metadata authorization: Bearer %s\n", token)
+			}
+			opts := rpc.ServeOptions{TLSConfig: tlsConfig, Token: token}
+			return rpc.Serve(g.ctx, l, &grpcService{dir: cwd, cfg: cfg, noLock: *noLock}, opts)
+		}
+	},
+}
+
+// serveToken returns the bearer token every call must send: the contents of file when one
+// is given, else $DELTAGRAM_SERVE_TOKEN, else a new random token, which generated reports
+// so that it can be shown
+func serveToken(file string, getenv func(string) string) (token string, generated bool, err error) {
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", false, fmt.Errorf("failed to read token file: %v", err)
+		}
+		if token = strings.TrimSpace(string(data)); token == "" {
+			return "", false, fmt.Errorf("token file %s is empty", file)
+		}
+		return token, false, nil
+	}
+	if token = strings.TrimSpace(getenv("DELTAGRAM_SERVE_TOKEN")); token != "" {
+		return token, false, nil
+	}
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", false, fmt.Errorf("failed to generate a token: %v", err)
+	}
+	return hex.EncodeToString(random), true, nil
+}
+
+// grpcService runs the methods of deltagram.proto against the directory the server was
+// started in, or against the files given in a request
+type grpcService struct {
+	dir    string
+	cfg    *config.Config
+	noLock bool
+}
+
+func (s *grpcService) parse(req *rpc.Request) (*parser.Deltagram, error) {
+	deltagram, err := parser.NewParserWithOptions(parser.Options{Repair: req.Repair}).Parse(req.Deltagram)
+	if err != nil {
+		return nil, grpcError(err, rpc.InvalidArgument)
+	}
+	if deltagram, err = expandVariables(deltagram, nil); err != nil {
+		return nil, grpcError(err, rpc.InvalidArgument)
+	}
+	return deltagram, nil
+}
+
+// options returns the configured apply options; what handlers print is discarded, since
+// the server has no terminal to show it on
+func (s *grpcService) options() operations.Options {
+	opts := configOptions(s.cfg)
+	opts.Output = io.Discard
+	return opts
+}
+
+func (s *grpcService) Parse(ctx context.Context, req *rpc.Request) (*rpc.ParseResponse, error) {
+	deltagram, err := parser.NewParserWithOptions(parser.Options{Repair: req.Repair}).ParseContext(ctx, req.Deltagram)
+	if err != nil {
+		return nil, grpcError(err, rpc.InvalidArgument)
+	}
+
+	resp := &rpc.ParseResponse{UUID: deltagram.UUID, Version: deltagram.EffectiveVersion()}
+	for _, part := range deltagram.Parts {
+		summary := rpc.Part{Path: part.ContentLocation, Operation: part.DeltaOperation, ContentType: part.ContentType, Line: part.Line}
+		switch {
+		case part.IsMessage():
+			summary.Operation = "message"
+		case summary.Operation == "":
+			summary.Operation = "create"
+		}
+		resp.Parts = append(resp.Parts, summary)
+	}
+	for _, repair := range deltagram.Repairs {
+		resp.Repairs = append(resp.Repairs, repair.Message)
+	}
+	return resp, nil
+}
+
+// Validate applies the deltagram in memory; a deltagram that does not apply is reported
+// in the response rather than as an error
+func (s *grpcService) Validate(ctx context.Context, req *rpc.Request) (*rpc.ValidateResponse, error) {
+	deltagram, err := s.parse(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &rpc.ValidateResponse{}
+	opts := s.options()
+	opts.Warn = func(w operations.Warning) { resp.Warnings = append(resp.Warnings, rpcWarning(w)) }
+
+	var summaries []changeSummary
+	if req.UsesFiles() {
+		var applied *operations.MemoryResult
+		if applied, err = operations.ApplyToMemoryFS(ctx, deltagram, req.Files, opts); err == nil {
+			summaries = changeSummaries(applied.Changes, "")
+		}
+	} else {
+		recorder := operations.NewRecordingFileSystem(operations.NewOverlayFileSystem(os.DirFS(s.dir)))
+		if err = operations.NewApplierWithOptions(recorder, opts).ApplyContext(ctx, deltagram, operations.OverlayRoot); err == nil {
+			summaries = changeSummaries(recorder.Changes(), operations.OverlayRoot)
+		}
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return nil, err
+	}
+	if err != nil {
+		result := newErrorResult(err)
+		resp.Error, resp.ErrorKind, resp.Part = result.Error, result.Kind, result.Part
+		return resp, nil
+	}
+
+	resp.Valid = true
+	resp.Changes = rpcChanges(summaries)
+	return resp, nil
+}
+
+func (s *grpcService) Plan(ctx context.Context, req *rpc.Request) (*rpc.PlanResponse, error) {
+	deltagram, err := s.parse(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var plan *operations.Plan
+	if req.UsesFiles() {
+		plan, err = operations.PlanMemoryFS(deltagram, req.Files)
+	} else {
+		plan, err = operations.BuildPlan(operations.NewRealFileSystem(), s.dir, deltagram)
+	}
+	if err != nil {
+		return nil, grpcError(err, rpc.FailedPrecondition)
+	}
+	plan.Deltagram = req.Deltagram
+
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	resp := &rpc.PlanResponse{PlanJSON: string(data)}
+	for _, step := range plan.Steps {
+		rpcStep := rpc.PlanStep{Part: step.Part, Operation: step.Operation, Source: step.Source, Target: step.Target, Hunks: step.Hunks}
+		for _, pre := range step.Preconditions {
+			rpcStep.Preconditions = append(rpcStep.Preconditions, rpc.Precondition{Path: pre.Path, Exists: pre.Exists, SHA256: pre.SHA256})
+		}
+		resp.Steps = append(resp.Steps, rpcStep)
+	}
+	return resp, nil
+}
+
+// Apply applies to the given files in memory, to an overlay of the directory for a dry
+// run, or to the directory itself under the apply lock
+func (s *grpcService) Apply(ctx context.Context, req *rpc.Request, send func(*rpc.ApplyEvent) error) error {
+	deltagram, err := s.parse(req)
+	if err != nil {
+		return err
+	}
+
+	// Progress and warnings cannot fail the apply; once the client is gone the request's
+	// context is canceled, which stops it between parts
+	var sendErr error
+	emit := func(event *rpc.ApplyEvent) {
+		if sendErr == nil {
+			sendErr = send(event)
+		}
+	}
+	opts := s.options()
+	opts.Progress = func(p operations.Progress) {
+		emit(&rpc.ApplyEvent{Progress: &rpc.Progress{Done: p.Done, Total: p.Total, Bytes: p.Bytes, TotalBytes: p.TotalBytes, Path: p.Path}})
+	}
+	opts.Warn = func(w operations.Warning) {
+		warning := rpcWarning(w)
+		emit(&rpc.ApplyEvent{Warning: &warning})
+	}
+
+	result := &rpc.ApplyResult{}
+	if req.UsesFiles() {
+		applied, err := operations.ApplyToMemoryFS(ctx, deltagram, req.Files, opts)
+		if err != nil {
+			return grpcError(fmt.Errorf("failed to apply deltagram: %w", err), rpc.FailedPrecondition)
+		}
+		result.Changes = rpcChanges(changeSummaries(applied.Changes, ""))
+		result.Diff, result.Files = applied.Diff, applied.Files
+	} else {
+		var fs operations.FileSystem = operations.NewRealFileSystem()
+		baseDir := s.dir
+		if req.DryRun {
+			fs, baseDir = operations.NewOverlayFileSystem(os.DirFS(s.dir)), operations.OverlayRoot
+		}
+		release, err := acquireLock(s.dir, req.DryRun || s.noLock)
+		if err != nil {
+			return grpcError(err, rpc.Aborted)
+		}
+		defer release()
+
+		recorder := operations.NewRecordingFileSystem(fs)
+		err = operations.NewApplierWithOptions(recorder, opts).ApplyContext(ctx, deltagram, baseDir)
+		if err != nil {
+			err = fmt.Errorf("failed to apply deltagram: %w", err)
+		}
+		if !req.DryRun {
+			err = auditApply(s.cfg, s.dir, "serve", s.dir, deltagram, recorder.Changes(), baseDir, err)
+		}
+		if err != nil {
+			return grpcError(err, rpc.FailedPrecondition)
+		}
+		changes := recorder.Changes()
+		result.Changes = rpcChanges(changeSummaries(changes, baseDir))
+		result.Diff = operations.FormatChanges(changes, baseDir)
+	}
+
+	if sendErr != nil {
+		return sendErr
+	}
+	return send(&rpc.ApplyEvent{Result: result})
+}
+
+// grpcError attaches a status code and the CLI's error kind to err. Errors of a known
+// kind get the code matching it, and others the given code.
+func grpcError(err error, code rpc.Code) error {
+	kind := errorKind(err)
+	switch kind {
+	case "invalid_boundary", "invalid_hunk":
+		code = rpc.InvalidArgument
+	case "context_mismatch", "conflict", "file_not_found", "requirement_not_met", "syntax_error":
+		code = rpc.FailedPrecondition
+	case "locked":
+		code = rpc.Aborted
+	case "canceled":
+		code = rpc.Canceled
+	}
+	return &rpc.Error{Code: code, Kind: kind, Err: err}
+}
+
+func rpcWarning(w operations.Warning) rpc.Warning {
+	return rpc.Warning{Part: w.Part, Path: w.Path, Kind: w.Kind, Message: w.Message}
+}
+
+func rpcChanges(summaries []changeSummary) []rpc.Change {
+	changes := make([]rpc.Change, 0, len(summaries))
+	for _, summary := range summaries {
+		changes = append(changes, rpc.Change{Path: summary.Path, Action: summary.Action})
+	}
+	return changes
+}
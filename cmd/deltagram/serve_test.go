@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/developingjames/deltagrams/pkg/config"
+	"github.com/developingjames/deltagrams/pkg/rpc"
+)
+
+func newGRPCService(t *testing.T) *grpcService {
+	t.Helper()
+	dir, _ := writeDeltagram(t)
+	cfg, err := config.Load(dir)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	return &grpcService{dir: dir, cfg: cfg}
+}
+
+func TestGRPCService_Parse(t *testing.T) {
+	s := newGRPCService(t)
+
+	resp, err := s.Parse(context.Background(), &rpc.Request{Deltagram: testDeltagram})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if resp.UUID != "0123456789abcdef" || len(resp.Parts) != 1 || resp.Parts[0].Operation != "create" {
+		t.Errorf("Expected one create part, got %+v", resp)
+	}
+
+	_, err = s.Parse(context.Background(), &rpc.Request{Deltagram: "not a deltagram"})
+	var rpcErr *rpc.Error
+	if !errors.As(err, &rpcErr) || rpcErr.Code != rpc.InvalidArgument || rpcErr.Kind != "invalid_boundary" {
+		t.Errorf("Expected an invalid_boundary InvalidArgument error, got: %v", err)
+	}
+}
+
+func TestGRPCService_Validate(t *testing.T) {
+	s := newGRPCService(t)
+
+	resp, err := s.Validate(context.Background(), &rpc.Request{Deltagram: testDeltagram})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !resp.Valid || len(resp.Changes) != 1 || resp.Changes[0] != (rpc.Change{Path: "hello.txt", Action: "created"}) {
+		t.Errorf("Expected hello.txt to be created, got %+v", resp)
+	}
+	if _, err := os.Stat(filepath.Join(s.dir, "hello.txt")); !os.IsNotExist(err) {
+		t.Error("Expected Validate to write nothing")
+	}
+
+	mismatch := "--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: hello.txt\n" +
+		"Content-Type: text/plain\n" +
+		"Delta-Operation: content\n" +
+		"\n" +
+		"@@ -1,1 +1,1 @@\n" +
+		"-hello\n" +
+		"+goodbye\n" +
+		"--====DELTAGRAM_0123456789abcdef====--\n"
+	resp, err = s.Validate(context.Background(), &rpc.Request{Deltagram: mismatch, Files: map[string][]byte{"hello.txt": []byte("other\n")}})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if resp.Valid || resp.ErrorKind != "context_mismatch" || resp.Part != 1 {
+		t.Errorf("Expected a context mismatch in part 1, got %+v", resp)
+	}
+}
+
+func TestGRPCService_Plan(t *testing.T) {
+	s := newGRPCService(t)
+
+	resp, err := s.Plan(context.Background(), &rpc.Request{Deltagram: testDeltagram})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(resp.Steps) != 1 || resp.Steps[0].Target != "hello.txt" || resp.Steps[0].Preconditions[0].Exists {
+		t.Errorf("Expected one step creating hello.txt, got %+v", resp.Steps)
+	}
+	if resp.PlanJSON == "" {
+		t.Error("Expected the plan as JSON")
+	}
+}
+
+func TestGRPCService_Apply(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     rpc.Request
+		written bool
+	}{
+		{name: "directory", req: rpc.Request{Deltagram: testDeltagram}, written: true},
+		{name: "dry run", req: rpc.Request{Deltagram: testDeltagram, DryRun: true}},
+		{name: "files", req: rpc.Request{Deltagram: testDeltagram, Files: map[string][]byte{"other.txt": []byte("other")}}},
+		{name: "empty tree in memory", req: rpc.Request{Deltagram: testDeltagram, InMemory: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newGRPCService(t)
+
+			var events []*rpc.ApplyEvent
+			err := s.Apply(context.Background(), &tt.req, func(event *rpc.ApplyEvent) error {
+				events = append(events, event)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if len(events) < 2 || events[0].Progress == nil {
+				t.Fatalf("Expected progress events before the result, got %+v", events)
+			}
+			result := events[len(events)-1].Result
+			if result == nil || len(result.Changes) != 1 || result.Changes[0].Path != "hello.txt" {
+				t.Fatalf("Expected a result creating hello.txt, got %+v", events[len(events)-1])
+			}
+
+			_, err = os.Stat(filepath.Join(s.dir, "hello.txt"))
+			if written := err == nil; written != tt.written {
+				t.Errorf("Expected hello.txt written to the directory: %v, got %v", tt.written, written)
+			}
+			if tt.req.UsesFiles() {
+				expected := map[string][]byte{"hello.txt": []byte("hello")}
+				for name, data := range tt.req.Files {
+					expected[name] = data
+				}
+				if !reflect.DeepEqual(result.Files, expected) {
+					t.Errorf("Expected the resulting tree %v, got %v", expected, result.Files)
+				}
+			}
+		})
+	}
+}
+
+func TestServeToken(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(file, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(t.TempDir(), "empty")
+	if err := os.WriteFile(empty, []byte("\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	env := func(value string) func(string) string {
+		return func(string) string { return value }
+	}
+
+	token, generated, err := serveToken(file, env("from-env"))
+	if err != nil || token != "from-file" || generated {
+		t.Errorf("Expected the token file to win, got %q, %v, %v", token, generated, err)
+	}
+	token, generated, err = serveToken("", env("from-env"))
+	if err != nil || token != "from-env" || generated {
+		t.Errorf("Expected the environment token, got %q, %v, %v", token, generated, err)
+	}
+	token, generated, err = serveToken("", env(""))
+	if err != nil || len(token) != 64 || !generated {
+		t.Errorf("Expected a generated token, got %q, %v, %v", token, generated, err)
+	}
+	if _, _, err := serveToken(empty, env("")); err == nil {
+		t.Error("Expected an error for an empty token file")
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/developingjames/deltagrams"
+)
+
+var specCommand = &command{
+	name:    "spec",
+	summary: "Print the deltagram format specification for pasting into an LLM prompt",
+	setup: func(flags *flag.FlagSet) func(g *globals, args []string) error {
+		return func(g *globals, args []string) error {
+			_, err := fmt.Fprint(g.stdout, deltagrams.Spec)
+			return err
+		}
+	},
+}
+
+var exampleCommand = &command{
+	name:    "example",
+	summary: "Print a worked example deltagram",
+	setup: func(flags *flag.FlagSet) func(g *globals, args []string) error {
+		return func(g *globals, args []string) error {
+			_, err := fmt.Fprint(g.stdout, deltagrams.Example)
+			return err
+		}
+	},
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/developingjames/deltagrams/pkg/operations"
+)
+
+var splitCommand = &command{
+	name:    "split",
+	args:    "[file]",
+	summary: "Split a deltagram into one deltagram per file or per operation",
+	setup: func(flags *flag.FlagSet) func(g *globals, args []string) error {
+		byFile := flags.Bool("by-file", false, "Write one deltagram per target path (the default)")
+		byOp := flags.Bool("by-op", false, "Write one deltagram per operation, such as all creates and all content changes")
+		output := flags.String("o", ".", "Directory to write the deltagrams to, named NNNN-file-or-operation.deltagram")
+		parseOpts := parseFlags(flags)
+
+		return func(g *globals, args []string) error {
+			if *byFile && *byOp {
+				return fmt.Errorf("--by-file and --by-op cannot be combined")
+			}
+			by := operations.SplitByFile
+			if *byOp {
+				by = operations.SplitByOperation
+			}
+
+			deltagram, err := readDeltagramWith(g, args, *parseOpts)
+			if err != nil {
+				return err
+			}
+			pieces, err := operations.Split(deltagram, by)
+			if err != nil {
+				return err
+			}
+
+			if err := os.MkdirAll(*output, 0755); err != nil {
+				return fmt.Errorf("failed to create %s: %v", *output, err)
+			}
+			for i, piece := range pieces {
+				path := filepath.Join(*output, numberedName(i+1, piece.Key))
+				if err := os.WriteFile(path, []byte(piece.Deltagram.String()), 0644); err != nil {
+					return fmt.Errorf("failed to write %s: %v", path, err)
+				}
+				fmt.Fprintln(g.stdout, path)
+			}
+			return nil
+		}
+	},
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/developingjames/deltagrams/pkg/operations"
+)
+
+var statCommand = &command{
+	name:    "stat",
+	args:    "[file]",
+	summary: "Summarize a deltagram's operations, changed lines, and risk against current directory",
+	json:    true,
+	setup: func(flags *flag.FlagSet) func(g *globals, args []string) error {
+		var vars stringList
+		flags.Var(&vars, "var", "Set a template variable as NAME=value (repeatable)")
+		parseOpts := parseFlags(flags)
+
+		return func(g *globals, args []string) error {
+			deltagram, err := readDeltagramWith(g, args, *parseOpts)
+			if err != nil {
+				return err
+			}
+			if deltagram, err = expandVariables(deltagram, vars); err != nil {
+				return err
+			}
+
+			cwd, err := os.Getwd()
+			if err != nil {
+				return fmt.Errorf("failed to get current working directory: %v", err)
+			}
+
+			stats := operations.Summarize(operations.NewRealFileSystem(), cwd, deltagram)
+			if g.JSON {
+				return writeJSON(g.stdout, statReport{Stats: stats, Risk: stats.Risk()})
+			}
+
+			for _, part := range stats.Parts {
+				path := part.Path
+				if part.Source != "" {
+					path = part.Source + " -> " + part.Path
+				}
+				fmt.Fprintf(g.stdout, "  %-8s %s  +%d -%d%s\n", part.Operation, path, part.Added, part.Removed, partNotes(part))
+			}
+
+			ops := make([]string, 0, len(stats.Operations))
+			for op := range stats.Operations {
+				ops = append(ops, op)
+			}
+			sort.Strings(ops)
+			fmt.Fprintln(g.stdout)
+			fmt.Fprint(g.stdout, "Operations:")
+			for _, op := range ops {
+				fmt.Fprintf(g.stdout, " %d %s", stats.Operations[op], op)
+			}
+			fmt.Fprintln(g.stdout)
+			fmt.Fprintf(g.stdout, "Lines: +%d -%d, %d new file(s)\n", stats.Added, stats.Removed, stats.NewFiles)
+			fmt.Fprintf(g.stdout, "Risk: %s (%d fuzzy hunk(s), %d failed hunk(s), %d missing target(s))\n",
+				stats.Risk(), stats.Fuzzy, stats.Failed, stats.Missing)
+			fmt.Fprintf(g.stdout, "Patch-ID: %s\n", stats.PatchID)
+			return nil
+		}
+	},
+}
+
+// statReport is the JSON output of stat
+type statReport struct {
+	*operations.Stats
+	Risk string `json:"risk"`
+}
+
+// partNotes describes anything notable about a part for the stat listing
+func partNotes(part operations.PartStat) string {
+	var notes string
+	if part.NewFile {
+		notes += ", new file"
+	}
+	if part.Fuzzy > 0 {
+		notes += fmt.Sprintf(", %d fuzzy", part.Fuzzy)
+	}
+	if part.Failed > 0 {
+		notes += fmt.Sprintf(", %d FAILED", part.Failed)
+	}
+	if part.Missing {
+		notes += ", MISSING target"
+	}
+	if notes == "" {
+		return ""
+	}
+	return " (" + notes[2:] + ")"
+}
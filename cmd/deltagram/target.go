@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/kube"
+	"github.com/developingjames/deltagrams/pkg/objectstore"
+	"github.com/developingjames/deltagrams/pkg/operations"
+	"github.com/developingjames/deltagrams/pkg/parser"
+	"github.com/developingjames/deltagrams/pkg/remote"
+	"github.com/developingjames/deltagrams/pkg/workspace"
+)
+
+// target is somewhere other than the current directory that apply can write to. It is
+// loaded into memory and applied to there, and save writes back the changes.
+type target struct {
+	name    string // Shown in place of the directory in output
+	fs      operations.FileSystem
+	baseDir string
+	save    func(changes []operations.FileChange) error
+	// check, when set, refuses a deltagram before it is applied
+	check func(deltagram *parser.Deltagram) error
+}
+
+// openTarget loads the target named by --target: an ssh://, s3://, or gs:// URL, or an
+// archive
+func openTarget(g *globals, name string) (*target, error) {
+	if strings.HasPrefix(name, "s3://") || strings.HasPrefix(name, "gs://") {
+		return openBucket(g, name)
+	}
+	if strings.HasPrefix(name, "ssh://") {
+		shell, dir, err := remote.SSH(name)
+		if err != nil {
+			return nil, err
+		}
+		return openRemote(g, name, shell, dir)
+	}
+
+	archive, err := operations.OpenArchive(name)
+	if err != nil {
+		return nil, err
+	}
+	return &target{
+		name:    name,
+		fs:      archive,
+		baseDir: operations.ArchiveRoot,
+		save:    func([]operations.FileChange) error { return archive.Save() },
+	}, nil
+}
+
+// openRemote copies the remote directory dir into memory
+func openRemote(g *globals, name string, shell remote.Shell, dir string) (*target, error) {
+	fs, err := remote.Open(g.ctx, shell, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &target{
+		name:    name,
+		fs:      fs,
+		baseDir: remote.Root,
+		save:    func(changes []operations.FileChange) error { return fs.Push(g.ctx, changes) },
+	}, nil
+}
+
+// openKube loads the keys of the ConfigMap or Secret named by --configmap or --secret
+func openKube(g *globals, configMap, secret, kubeContext string) (*target, error) {
+	kind, ref := kube.ConfigMap, configMap
+	if secret != "" {
+		kind, ref = kube.Secret, secret
+	}
+	obj, err := kube.ParseObject(kind, ref, kubeContext)
+	if err != nil {
+		return nil, err
+	}
+	fs, err := kube.Open(g.ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+	return &target{
+		name:    obj.String(),
+		fs:      fs,
+		baseDir: kube.Root,
+		save:    func(changes []operations.FileChange) error { return fs.Save(g.ctx, changes) },
+	}, nil
+}
+
+// openBucket lists the objects under an s3://bucket/prefix or gs://bucket/prefix URL
+func openBucket(g *globals, name string) (*target, error) {
+	scheme, rest, _ := strings.Cut(name, "://")
+	bucket, prefix, _ := strings.Cut(rest, "/")
+	if bucket == "" {
+		return nil, fmt.Errorf("invalid target %s: use %s://bucket/prefix", name, scheme)
+	}
+
+	var store *objectstore.S3
+	var err error
+	if scheme == "gs" {
+		store, err = objectstore.NewGCSFromEnv(bucket)
+	} else {
+		store, err = objectstore.NewS3FromEnv(bucket)
+	}
+	if err != nil {
+		return nil, err
+	}
+	fs, err := objectstore.Open(g.ctx, store, prefix)
+	if err != nil {
+		return nil, err
+	}
+	return &target{
+		name:    name,
+		fs:      fs,
+		baseDir: objectstore.Root,
+		save: func(changes []operations.FileChange) error {
+			written, err := fs.Save(g.ctx, changes)
+			if err != nil && len(written) > 0 {
+				return fmt.Errorf("%w (already written: %s)", err, strings.Join(written, ", "))
+			}
+			return err
+		},
+	}, nil
+}
+
+// openWorkspace reads the mapping file named by --workspace; files are read from the
+// mapped directories as the deltagram touches them
+func openWorkspace(name string) (*target, error) {
+	m, err := workspace.Load(name)
+	if err != nil {
+		return nil, err
+	}
+	fs := workspace.Open(m)
+	return &target{
+		name:    "workspace " + name,
+		fs:      fs,
+		baseDir: workspace.Root,
+		check:   func(deltagram *parser.Deltagram) error { return m.Check(locations(deltagram)) },
+		save: func(changes []operations.FileChange) error {
+			written, err := fs.Save(changes)
+			if err != nil && len(written) > 0 {
+				return fmt.Errorf("%w (already written: %s)", err, strings.Join(written, ", "))
+			}
+			return err
+		},
+	}, nil
+}
+
+// locations returns the Content-Location of each part that writes a file
+func locations(deltagram *parser.Deltagram) []string {
+	var paths []string
+	for _, part := range deltagram.Parts {
+		if !part.IsMessage() {
+			paths = append(paths, part.ContentLocation)
+		}
+	}
+	return paths
+}
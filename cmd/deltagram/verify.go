@@ -0,0 +1,26 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// errVerifyFailed is returned, wrapped, when the --verify-cmd command fails
+var errVerifyFailed = errors.New("verification command failed")
+
+// runVerify runs command with the platform's shell in dir. Its output is shown only when
+// it fails, in which case the returned error wraps errVerifyFailed.
+func runVerify(g *globals, command, dir string) error {
+	fmt.Fprintf(g.out(), "Verifying with: %s\n", command)
+	cmd := shellCommand(g, command)
+	cmd.Dir = dir
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		g.stderr.Write(output.Bytes())
+		return fmt.Errorf("%w: %q: %v", errVerifyFailed, command, err)
+	}
+	return nil
+}
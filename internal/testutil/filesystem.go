@@ -3,25 +3,36 @@ package testutil
 import (
 	"fmt"
 	"io"
+	iofs "io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 )
 
+// maxSymlinkDepth bounds symlink resolution to detect loops
+const maxSymlinkDepth = 40
+
 // MockFileSystem implements a fake file system for testing
 type MockFileSystem struct {
-	mu    sync.RWMutex
-	files map[string][]byte
-	dirs  map[string]bool
+	mu       sync.RWMutex
+	files    map[string][]byte
+	dirs     map[string]bool
+	symlinks map[string]string
+	modes    map[string]os.FileMode
+	modTimes map[string]time.Time
 }
 
 // NewMockFileSystem creates a new mock file system
 func NewMockFileSystem() *MockFileSystem {
 	return &MockFileSystem{
-		files: make(map[string][]byte),
-		dirs:  make(map[string]bool),
+		files:    make(map[string][]byte),
+		dirs:     make(map[string]bool),
+		symlinks: make(map[string]string),
+		modes:    make(map[string]os.FileMode),
+		modTimes: make(map[string]time.Time),
 	}
 }
 
@@ -47,6 +58,20 @@ func (fs *MockFileSystem) AddDir(path string) {
 	fs.dirs[path] = true
 }
 
+// AddSymlink adds a symlink at path pointing to target to the mock file system
+func (fs *MockFileSystem) AddSymlink(target, path string) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.symlinks[path] = target
+
+	dir := filepath.Dir(path)
+	for dir != "." && dir != "/" {
+		fs.dirs[dir] = true
+		dir = filepath.Dir(dir)
+	}
+}
+
 // GetFiles returns all files in the mock file system
 func (fs *MockFileSystem) GetFiles() map[string][]byte {
 	fs.mu.RLock()
@@ -64,7 +89,7 @@ func (fs *MockFileSystem) GetFiles() map[string][]byte {
 func (fs *MockFileSystem) FileExists(path string) bool {
 	fs.mu.RLock()
 	defer fs.mu.RUnlock()
-	_, exists := fs.files[path]
+	_, exists := fs.files[fs.resolve(path)]
 	return exists
 }
 
@@ -73,7 +98,7 @@ func (fs *MockFileSystem) ReadFile(filename string) ([]byte, error) {
 	fs.mu.RLock()
 	defer fs.mu.RUnlock()
 
-	content, exists := fs.files[filename]
+	content, exists := fs.files[fs.resolve(filename)]
 	if !exists {
 		return nil, fmt.Errorf("file not found: %s", filename)
 	}
@@ -88,14 +113,93 @@ func (fs *MockFileSystem) WriteFile(filename string, data []byte, perm os.FileMo
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
+	filename = fs.resolve(filename)
+
 	// Ensure directory exists
 	dir := filepath.Dir(filename)
 	if dir != "." && dir != "/" && !fs.dirs[dir] {
 		return fmt.Errorf("directory does not exist: %s", dir)
 	}
 
+	// Like os.WriteFile, the permissions only apply when the file is created
+	if _, exists := fs.files[filename]; !exists {
+		fs.modes[filename] = perm
+	}
+
 	fs.files[filename] = make([]byte, len(data))
 	copy(fs.files[filename], data)
+	fs.modTimes[filename] = time.Now()
+	return nil
+}
+
+// Chtimes changes the modification time of a file in the mock file system
+func (fs *MockFileSystem) Chtimes(name string, atime, mtime time.Time) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	name = fs.resolve(name)
+	if _, exists := fs.files[name]; !exists {
+		return fmt.Errorf("file not found: %s", name)
+	}
+
+	fs.modTimes[name] = mtime
+	return nil
+}
+
+// Chmod changes the permission bits of a file in the mock file system
+func (fs *MockFileSystem) Chmod(name string, mode os.FileMode) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	name = fs.resolve(name)
+	if _, exists := fs.files[name]; !exists && !fs.dirs[name] {
+		return fmt.Errorf("file not found: %s", name)
+	}
+
+	fs.modes[name] = mode.Perm()
+	return nil
+}
+
+// Truncate changes the size of a file in the mock file system
+func (fs *MockFileSystem) Truncate(name string, size int64) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	name = fs.resolve(name)
+	content, exists := fs.files[name]
+	if !exists {
+		return fmt.Errorf("file not found: %s", name)
+	}
+
+	data := make([]byte, size)
+	copy(data, content)
+	fs.files[name] = data
+	fs.modTimes[name] = time.Now()
+	return nil
+}
+
+// SetMode sets the permission bits of a file in the mock file system
+func (fs *MockFileSystem) SetMode(name string, mode os.FileMode) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	fs.modes[name] = mode
+}
+
+// Symlink creates newname as a symlink to oldname in the mock file system
+func (fs *MockFileSystem) Symlink(oldname, newname string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.exists(newname) {
+		return fmt.Errorf("file already exists: %s", newname)
+	}
+
+	dir := filepath.Dir(newname)
+	if dir != "." && dir != "/" && !fs.dirs[fs.resolve(dir)] {
+		return fmt.Errorf("directory does not exist: %s", dir)
+	}
+
+	fs.symlinks[newname] = oldname
 	return nil
 }
 
@@ -104,11 +208,20 @@ func (fs *MockFileSystem) Remove(name string) error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
+	// Like os.Remove, a symlink itself is removed rather than its target
+	if _, exists := fs.symlinks[name]; exists {
+		delete(fs.symlinks, name)
+		return nil
+	}
+
+	name = fs.resolve(name)
 	if _, exists := fs.files[name]; !exists {
 		return fmt.Errorf("file not found: %s", name)
 	}
 
 	delete(fs.files, name)
+	delete(fs.modes, name)
+	delete(fs.modTimes, name)
 	return nil
 }
 
@@ -117,19 +230,30 @@ func (fs *MockFileSystem) Rename(oldpath, newpath string) error {
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
-	content, exists := fs.files[oldpath]
-	if !exists {
-		return fmt.Errorf("file not found: %s", oldpath)
-	}
-
 	// Ensure destination directory exists
 	dir := filepath.Dir(newpath)
-	if dir != "." && dir != "/" && !fs.dirs[dir] {
+	if dir != "." && dir != "/" && !fs.dirs[fs.resolve(dir)] {
 		return fmt.Errorf("directory does not exist: %s", dir)
 	}
 
+	if target, exists := fs.symlinks[oldpath]; exists {
+		fs.symlinks[newpath] = target
+		delete(fs.symlinks, oldpath)
+		return nil
+	}
+
+	oldpath = fs.resolve(oldpath)
+	content, exists := fs.files[oldpath]
+	if !exists {
+		return fmt.Errorf("file not found: %s", oldpath)
+	}
+
 	fs.files[newpath] = content
+	fs.modes[newpath] = fs.modes[oldpath]
+	fs.modTimes[newpath] = fs.modTimes[oldpath]
 	delete(fs.files, oldpath)
+	delete(fs.modes, oldpath)
+	delete(fs.modTimes, oldpath)
 	return nil
 }
 
@@ -139,7 +263,7 @@ func (fs *MockFileSystem) MkdirAll(path string, perm os.FileMode) error {
 	defer fs.mu.Unlock()
 
 	// Create all parent directories
-	current := path
+	current := fs.resolve(path)
 	for current != "." && current != "/" {
 		fs.dirs[current] = true
 		current = filepath.Dir(current)
@@ -147,20 +271,74 @@ func (fs *MockFileSystem) MkdirAll(path string, perm os.FileMode) error {
 	return nil
 }
 
-// Stat returns file info for the mock file system
+// Stat returns file info for the mock file system, following symlinks
 func (fs *MockFileSystem) Stat(name string) (os.FileInfo, error) {
 	fs.mu.RLock()
 	defer fs.mu.RUnlock()
 
-	if _, exists := fs.files[name]; exists {
-		return &mockFileInfo{name: filepath.Base(name), isDir: false}, nil
+	return fs.stat(fs.resolve(name), filepath.Base(name))
+}
+
+// Lstat returns file info for the mock file system without following a final symlink
+func (fs *MockFileSystem) Lstat(name string) (os.FileInfo, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	dir := fs.resolve(filepath.Dir(name))
+	path := filepath.Join(dir, filepath.Base(name))
+	if target, exists := fs.symlinks[path]; exists {
+		return &mockFileInfo{name: filepath.Base(name), size: int64(len(target)), mode: os.ModeSymlink | 0777}, nil
+	}
+
+	return fs.stat(path, filepath.Base(name))
+}
+
+// ReadDir lists the entries of a directory in the mock file system sorted by name
+func (fs *MockFileSystem) ReadDir(name string) ([]os.DirEntry, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	dir := fs.resolve(name)
+	if !fs.dirs[dir] {
+		return nil, os.ErrNotExist
 	}
 
-	if fs.dirs[name] {
-		return &mockFileInfo{name: filepath.Base(name), isDir: true}, nil
+	seen := make(map[string]bool)
+	var entries []os.DirEntry
+	add := func(path string, info os.FileInfo) {
+		if filepath.Dir(path) != dir || seen[path] {
+			return
+		}
+		seen[path] = true
+		entries = append(entries, iofs.FileInfoToDirEntry(info))
 	}
 
-	return nil, os.ErrNotExist
+	for path := range fs.files {
+		info, _ := fs.stat(path, filepath.Base(path))
+		add(path, info)
+	}
+	for path := range fs.dirs {
+		info, _ := fs.stat(path, filepath.Base(path))
+		add(path, info)
+	}
+	for path, target := range fs.symlinks {
+		add(path, &mockFileInfo{name: filepath.Base(path), size: int64(len(target)), mode: os.ModeSymlink | 0777})
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// EvalSymlinks resolves all symlinks in a path of the mock file system
+func (fs *MockFileSystem) EvalSymlinks(path string) (string, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	resolved := fs.resolve(path)
+	if _, exists := fs.files[resolved]; !exists && !fs.dirs[resolved] {
+		return "", os.ErrNotExist
+	}
+	return resolved, nil
 }
 
 // Open opens a file in the mock file system
@@ -177,16 +355,75 @@ func (fs *MockFileSystem) Create(name string) (io.WriteCloser, error) {
 	return &mockWriteFile{fs: fs, name: name}, nil
 }
 
+// stat returns info for an already resolved path; callers must hold the lock
+func (fs *MockFileSystem) stat(path, name string) (os.FileInfo, error) {
+	if content, exists := fs.files[path]; exists {
+		mode, ok := fs.modes[path]
+		if !ok {
+			mode = 0644
+		}
+		return &mockFileInfo{name: name, size: int64(len(content)), mode: mode, modTime: fs.modTimes[path]}, nil
+	}
+
+	if fs.dirs[path] {
+		return &mockFileInfo{name: name, mode: os.ModeDir | 0755, isDir: true}, nil
+	}
+
+	return nil, os.ErrNotExist
+}
+
+// exists reports whether anything exists at the unresolved path; callers must hold the lock
+func (fs *MockFileSystem) exists(path string) bool {
+	if _, ok := fs.symlinks[path]; ok {
+		return true
+	}
+	_, ok := fs.files[path]
+	return ok || fs.dirs[path]
+}
+
+// resolve follows symlinks in every component of path; callers must hold the lock
+func (fs *MockFileSystem) resolve(path string) string {
+	if len(fs.symlinks) == 0 {
+		return path
+	}
+
+	path = filepath.Clean(path)
+	for depth := 0; depth < maxSymlinkDepth; depth++ {
+		changed := false
+		for prefix := path; ; prefix = filepath.Dir(prefix) {
+			if target, ok := fs.symlinks[prefix]; ok {
+				if !filepath.IsAbs(target) {
+					target = filepath.Join(filepath.Dir(prefix), target)
+				}
+				rest := strings.TrimPrefix(path, prefix)
+				path = filepath.Clean(target + rest)
+				changed = true
+				break
+			}
+			if parent := filepath.Dir(prefix); parent == prefix {
+				break
+			}
+		}
+		if !changed {
+			return path
+		}
+	}
+	return path
+}
+
 // mockFileInfo implements os.FileInfo for testing
 type mockFileInfo struct {
-	name  string
-	isDir bool
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+	isDir   bool
 }
 
 func (fi *mockFileInfo) Name() string       { return fi.name }
-func (fi *mockFileInfo) Size() int64        { return 0 }
-func (fi *mockFileInfo) Mode() os.FileMode  { return 0644 }
-func (fi *mockFileInfo) ModTime() time.Time { return time.Time{} }
+func (fi *mockFileInfo) Size() int64        { return fi.size }
+func (fi *mockFileInfo) Mode() os.FileMode  { return fi.mode }
+func (fi *mockFileInfo) ModTime() time.Time { return fi.modTime }
 func (fi *mockFileInfo) IsDir() bool        { return fi.isDir }
 func (fi *mockFileInfo) Sys() interface{}   { return nil }
 
@@ -0,0 +1,302 @@
+// Package audit keeps an append-only log of applies for regulated environments. Each
+// entry is a line of JSON carrying the hash of the entry before it and a hash of itself,
+// so that editing, removing, or reordering entries breaks the chain and is found by
+// Verify.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+)
+
+// DefaultFile is the log used when none is configured, relative to the working directory
+const DefaultFile = ".deltagram/audit.log"
+
+// Results of an apply
+const (
+	Applied = "applied"
+	Failed  = "failed"
+)
+
+// ErrTampered is returned, wrapped, when Verify finds an entry that does not match its
+// hash or does not follow the entry before it
+var ErrTampered = errors.New("audit log has been modified")
+
+// lockWait is how long Append waits for another process appending to the same log, and
+// staleLock the age at which a lock is taken to be left by a process that died
+var (
+	lockWait  = 10 * time.Second
+	staleLock = time.Minute
+)
+
+// Entry records one apply
+type Entry struct {
+	Seq       int       `json:"seq"` // 1 for the first entry of the log
+	Time      time.Time `json:"time"`
+	User      string    `json:"user"` // Who applied the deltagram
+	Host      string    `json:"host"`
+	Command   string    `json:"command"`            // The deltagram command that applied it
+	Target    string    `json:"target"`             // Directory or other target applied to
+	Deltagram string    `json:"deltagram_sha256"`   // Hash of the deltagram as applied
+	UUID      string    `json:"uuid,omitempty"`     // Boundary identifier of the deltagram
+	PatchID   string    `json:"patch_id,omitempty"` // Fingerprint of the changes, the same for duplicates
+	Author    string    `json:"author,omitempty"`   // Author header of the deltagram, who wrote it
+	Result    string    `json:"result"`             // Applied or Failed
+	Error     string    `json:"error,omitempty"`    // Why a failed apply failed
+	Changes   []Change  `json:"changes,omitempty"`  // Files left changed, even by a failed apply
+	Prev      string    `json:"prev"`               // Hash of the previous entry; empty for the first
+	Hash      string    `json:"hash"`               // Hash of this entry; always the last field
+}
+
+// Change is a file changed by an apply
+type Change struct {
+	Path   string `json:"path"`
+	Action string `json:"action"`           // created, modified, or deleted
+	SHA256 string `json:"sha256,omitempty"` // Of the new content; empty for deleted files
+}
+
+// Sum returns the hex SHA-256 of data, as used for the hashes in entries
+func Sum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Identity returns the operating system user and host names for an entry
+func Identity() (string, string) {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	host, _ := os.Hostname()
+	return name, host
+}
+
+// Append adds entry to the log at path, creating it if needed. It fills in Seq, Prev, and
+// Hash, chaining the entry to the last one in the log.
+func Append(path string, entry *Entry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create audit log directory: %v", err)
+	}
+	unlock, err := lockLog(path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	last, err := lastEntry(path)
+	if err != nil {
+		return err
+	}
+	entry.Seq, entry.Prev = 1, ""
+	if last != nil {
+		entry.Seq, entry.Prev = last.Seq+1, last.Hash
+	}
+	line, err := seal(entry)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %v", err)
+	}
+	_, err = file.Write(append(line, '\n'))
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %v", err)
+	}
+	return nil
+}
+
+// seal encodes entry and sets its hash, which covers the encoded line with an empty hash
+func seal(entry *Entry) ([]byte, error) {
+	entry.Hash = ""
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	entry.Hash = Sum(line)
+	return append(line[:len(line)-len(`"}`)], entry.Hash+`"}`...), nil
+}
+
+// Verify checks every entry of the log at path against its hash and the entry before it.
+// It returns the number of entries and the hash of the last one, which can be recorded
+// elsewhere so that a later Verify can tell that no entries were removed from the end.
+func Verify(path string) (int, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to open audit log: %v", err)
+	}
+	defer file.Close()
+
+	var prev Entry
+	count := 0
+	err = eachLine(file, func(number int, line []byte) error {
+		entry, err := check(line)
+		if err == nil && entry.Seq != prev.Seq+1 {
+			err = fmt.Errorf("sequence number %d follows %d", entry.Seq, prev.Seq)
+		}
+		if err == nil && entry.Prev != prev.Hash {
+			err = fmt.Errorf("previous hash does not match entry %d", prev.Seq)
+		}
+		if err != nil {
+			return fmt.Errorf("%w: line %d: %v", ErrTampered, number, err)
+		}
+		prev = *entry
+		count++
+		return nil
+	})
+	return count, prev.Hash, err
+}
+
+// Contains reports an error unless the log at path has an entry with the given hash. A
+// hash printed by an earlier Verify and kept elsewhere shows that the log was not cut back
+// or rewritten from scratch since, which the chain alone cannot show.
+func Contains(path, hash string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %v", err)
+	}
+	defer file.Close()
+
+	found := false
+	err = eachLine(file, func(number int, line []byte) error {
+		var entry Entry
+		if json.Unmarshal(line, &entry) == nil && entry.Hash == hash {
+			found = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("%w: no entry has hash %s", ErrTampered, hash)
+	}
+	return nil
+}
+
+// FindApplied returns the last entry of the log at path that applied a deltagram with
+// the given patch-id, or nil when there is none or the log does not exist
+func FindApplied(path, patchID string) (*Entry, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %v", err)
+	}
+	defer file.Close()
+
+	var found *Entry
+	err = eachLine(file, func(number int, line []byte) error {
+		var entry Entry
+		if json.Unmarshal(line, &entry) == nil && entry.Result == Applied && entry.PatchID == patchID {
+			found = &entry
+		}
+		return nil
+	})
+	return found, err
+}
+
+// check decodes a line and confirms that it matches its own hash
+func check(line []byte) (*Entry, error) {
+	var entry Entry
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return nil, fmt.Errorf("invalid entry: %v", err)
+	}
+	suffix := []byte(`"hash":"` + entry.Hash + `"}`)
+	if entry.Hash == "" || !bytes.HasSuffix(line, suffix) {
+		return nil, fmt.Errorf("entry %d does not end with its hash", entry.Seq)
+	}
+	unsealed := append(bytes.Clone(line[:len(line)-len(suffix)]), `"hash":""}`...)
+	if Sum(unsealed) != entry.Hash {
+		return nil, fmt.Errorf("entry %d does not match its hash", entry.Seq)
+	}
+	return &entry, nil
+}
+
+// lastEntry returns the last entry of the log, or nil when it is missing or empty
+func lastEntry(path string) (*Entry, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %v", err)
+	}
+	defer file.Close()
+
+	var last []byte
+	err = eachLine(file, func(number int, line []byte) error {
+		last = line
+		return nil
+	})
+	if err != nil || last == nil {
+		return nil, err
+	}
+	var entry Entry
+	if err := json.Unmarshal(last, &entry); err != nil {
+		return nil, fmt.Errorf("%w: last entry is invalid: %v", ErrTampered, err)
+	}
+	return &entry, nil
+}
+
+// eachLine calls fn with every non-empty line of r and its 1-based number
+func eachLine(r io.Reader, fn func(number int, line []byte) error) error {
+	reader := bufio.NewReader(r)
+	for number := 1; ; number++ {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			if fnErr := fn(number, bytes.TrimRight(line, "\r\n")); fnErr != nil {
+				return fnErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read audit log: %v", err)
+		}
+	}
+}
+
+// lockLog keeps two processes from chaining entries to the same last entry, using a lock
+// file next to the log. A lock older than staleLock is taken over.
+func lockLog(path string) (func(), error) {
+	lockPath := path + ".lock"
+	deadline := time.Now().Add(lockWait)
+	for {
+		file, err := os.OpenFile(lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to lock audit log: %v", err)
+		}
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > staleLock {
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("failed to lock audit log: %s is held by another process", lockPath)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
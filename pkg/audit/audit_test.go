@@ -0,0 +1,222 @@
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeLog appends n entries to a new log and returns its path
+func writeLog(t *testing.T, n int) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "logs", "audit.log")
+	for i := 0; i < n; i++ {
+		entry := &Entry{
+			Time:      time.Date(2024, 1, 1, 0, 0, i, 0, time.UTC),
+			User:      "alice",
+			Host:      "build-1",
+			Command:   "apply",
+			Target:    "/srv/app",
+			Deltagram: Sum([]byte{byte(i)}),
+			Result:    Applied,
+			Changes:   []Change{{Path: "a.txt", Action: "modified", SHA256: Sum([]byte("a"))}},
+		}
+		if err := Append(path, entry); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if entry.Seq != i+1 || entry.Hash == "" {
+			t.Fatalf("Expected entry %d to be sealed, got %+v", i+1, entry)
+		}
+	}
+	return path
+}
+
+func readLines(t *testing.T, path string) [][]byte {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.SplitAfter(bytes.TrimSuffix(data, []byte("\n")), []byte("\n"))
+}
+
+func TestAppend_Chain(t *testing.T) {
+	path := writeLog(t, 3)
+
+	count, head, err := Verify(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 entries, got %d", count)
+	}
+
+	lines := readLines(t, path)
+	second, err := check(bytes.TrimSpace(lines[1]))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	first, _ := check(bytes.TrimSpace(lines[0]))
+	if second.Prev != first.Hash || first.Prev != "" {
+		t.Errorf("Expected the second entry to chain to the first")
+	}
+	last, _ := check(bytes.TrimSpace(lines[2]))
+	if head != last.Hash {
+		t.Errorf("Expected head %s, got %s", last.Hash, head)
+	}
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Error("Expected the lock file to be removed")
+	}
+}
+
+func TestVerify_Tampered(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(lines [][]byte) [][]byte
+	}{
+		{
+			name: "edited field",
+			modify: func(lines [][]byte) [][]byte {
+				lines[1] = bytes.Replace(lines[1], []byte(`"user":"alice"`), []byte(`"user":"mallory"`), 1)
+				return lines
+			},
+		},
+		{
+			name: "edited and rehashed without the chain",
+			modify: func(lines [][]byte) [][]byte {
+				entry, _ := check(bytes.TrimSpace(lines[1]))
+				entry.Result = Failed
+				line, _ := seal(entry)
+				lines[1] = append(line, '\n')
+				return lines
+			},
+		},
+		{
+			name: "removed entry",
+			modify: func(lines [][]byte) [][]byte {
+				return append(lines[:1], lines[2:]...)
+			},
+		},
+		{
+			name: "reordered entries",
+			modify: func(lines [][]byte) [][]byte {
+				lines[1], lines[2] = lines[2], lines[1]
+				return lines
+			},
+		},
+		{
+			name: "extra field",
+			modify: func(lines [][]byte) [][]byte {
+				lines[0] = bytes.Replace(lines[0], []byte(`{"seq":1,`), []byte(`{"seq":1,"note":"x",`), 1)
+				return lines
+			},
+		},
+		{
+			name: "garbage line",
+			modify: func(lines [][]byte) [][]byte {
+				return append(lines, []byte("not json\n"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeLog(t, 3)
+			lines := readLines(t, path)
+			lines[len(lines)-1] = append(lines[len(lines)-1], '\n')
+			if err := os.WriteFile(path, bytes.Join(tt.modify(lines), nil), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, _, err := Verify(path)
+			if !errors.Is(err, ErrTampered) {
+				t.Errorf("Expected ErrTampered, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestVerify_Empty(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	os.WriteFile(path, nil, 0644)
+
+	count, head, err := Verify(path)
+	if err != nil || count != 0 || head != "" {
+		t.Errorf("Expected an empty log to verify, got %d, %q, %v", count, head, err)
+	}
+	if _, _, err := Verify(filepath.Join(t.TempDir(), "missing.log")); err == nil {
+		t.Error("Expected an error for a missing log")
+	}
+}
+
+func TestContains(t *testing.T) {
+	path := writeLog(t, 3)
+	_, head, err := Verify(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := Contains(path, head); err != nil {
+		t.Errorf("Expected the head to be found, got: %v", err)
+	}
+
+	// Cutting entries off the end leaves a valid chain, which only the recorded head catches
+	lines := readLines(t, path)
+	os.WriteFile(path, bytes.Join(lines[:2], nil), 0644)
+	if _, _, err := Verify(path); err != nil {
+		t.Fatalf("Expected the shortened chain to verify, got: %v", err)
+	}
+	if err := Contains(path, head); !errors.Is(err, ErrTampered) || !strings.Contains(err.Error(), head) {
+		t.Errorf("Expected ErrTampered naming the head, got: %v", err)
+	}
+}
+
+func TestAppend_StaleLock(t *testing.T) {
+	oldWait, oldStale := lockWait, staleLock
+	lockWait, staleLock = 100*time.Millisecond, 500*time.Millisecond
+	defer func() { lockWait, staleLock = oldWait, oldStale }()
+
+	path := filepath.Join(t.TempDir(), "audit.log")
+	os.WriteFile(path+".lock", nil, 0644)
+	stale := time.Now().Add(-time.Second)
+	os.Chtimes(path+".lock", stale, stale)
+
+	if err := Append(path, &Entry{Result: Applied}); err != nil {
+		t.Fatalf("Expected a stale lock to be taken over, got: %v", err)
+	}
+
+	os.WriteFile(path+".lock", nil, 0644)
+	if err := Append(path, &Entry{Result: Applied}); err == nil {
+		t.Error("Expected a held lock to time out")
+	}
+}
+
+func TestFindApplied(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	if entry, err := FindApplied(path, "abc"); err != nil || entry != nil {
+		t.Fatalf("Expected nothing found in a missing log, got %+v, %v", entry, err)
+	}
+	for _, entry := range []*Entry{
+		{Command: "apply", PatchID: "abc", Result: Failed},
+		{Command: "apply", PatchID: "def", Result: Applied},
+		{Command: "inbox", PatchID: "abc", Result: Applied},
+	} {
+		if err := Append(path, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entry, err := FindApplied(path, "abc")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if entry == nil || entry.Seq != 3 || entry.Command != "inbox" {
+		t.Errorf("Expected the successful apply in entry 3, got %+v", entry)
+	}
+	if entry, _ := FindApplied(path, "xyz"); entry != nil {
+		t.Errorf("Expected no entry for an unknown patch-id, got %+v", entry)
+	}
+}
@@ -1,51 +1,354 @@
 package clipboard
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
 	"runtime"
 	"strings"
+	"time"
+)
+
+// PowerShell scripts that move the clipboard as base64 of its UTF-8 bytes. Plain
+// Get-Clipboard output is encoded with the console's code page, which loses characters
+// outside it, and gains a line terminator; base64 is the same in every code page.
+const (
+	powershellRead  = "[Convert]::ToBase64String([Text.Encoding]::UTF8.GetBytes([string](Get-Clipboard -Raw)))"
+	powershellWrite = "Set-Clipboard -Value ([Text.Encoding]::UTF8.GetString([Convert]::FromBase64String([Console]::In.ReadToEnd())))"
+)
+
+const (
+	// DefaultTimeout is how long NewReader waits for the clipboard command
+	DefaultTimeout = 10 * time.Second
+	// DefaultMaxBytes is the most output NewReader accepts from the clipboard command
+	DefaultMaxBytes = 32 << 20
+)
+
+var (
+	// ErrTimeout is returned when the clipboard command or terminal does not answer in time
+	ErrTimeout = errors.New("clipboard timed out")
+	// ErrTooLarge is returned when the clipboard holds more than the maximum size
+	ErrTooLarge = errors.New("clipboard contents too large")
+	// ErrUnavailable is returned when there is no way to reach the clipboard, such as when
+	// no clipboard command is installed
+	ErrUnavailable = errors.New("no clipboard available")
 )
 
 // Reader defines the interface for reading from clipboard
 type Reader interface {
 	Read() (string, error)
+	// ReadContext is like Read but kills the clipboard command once ctx is done
+	ReadContext(ctx context.Context) (string, error)
+}
+
+// Writer defines the interface for writing to the clipboard
+type Writer interface {
+	Write(text string) error
+	// WriteContext is like Write but kills the clipboard command once ctx is done
+	WriteContext(ctx context.Context, text string) error
+}
+
+// Methods of reaching the clipboard for Options.Method
+const (
+	// MethodAuto uses tmux paste buffers inside tmux when there is no clipboard command,
+	// OSC 52 in an SSH session without a display server, and the platform's clipboard
+	// command otherwise
+	MethodAuto = "auto"
+	// MethodNative runs the platform's clipboard command, such as pbpaste or xclip
+	MethodNative = "native"
+	// MethodOSC52 asks the terminal with OSC 52 escape sequences
+	MethodOSC52 = "osc52"
+	// MethodTmux uses tmux paste buffers
+	MethodTmux = "tmux"
+)
+
+// Options guards a clipboard read against commands that hang or print without end, as
+// PowerShell can when a profile script waits for input and xclip can over a stalled X
+// forwarding connection. A zero Timeout or MaxBytes turns that guard off.
+type Options struct {
+	Timeout  time.Duration
+	MaxBytes int64
+	// Method is how the clipboard is reached; empty is MethodAuto
+	Method string
 }
 
 // DefaultReader implements clipboard reading for multiple platforms
-type DefaultReader struct{}
+type DefaultReader struct {
+	opts Options
+}
 
-// NewReader creates a new clipboard reader
+// NewReader creates a new clipboard reader with the default timeout and size limit
 func NewReader() Reader {
-	return &DefaultReader{}
+	return NewReaderWithOptions(Options{Timeout: DefaultTimeout, MaxBytes: DefaultMaxBytes})
+}
+
+// NewReaderWithOptions creates a new clipboard reader with the given guards and method
+func NewReaderWithOptions(opts Options) Reader {
+	switch selectMethod(opts.Method, os.Getenv, exec.LookPath) {
+	case MethodOSC52:
+		return NewOSC52(opts)
+	case MethodTmux:
+		return NewTmux(opts)
+	}
+	return &DefaultReader{opts: opts}
 }
 
 // Read reads content from the system clipboard
 func (r *DefaultReader) Read() (string, error) {
-	var cmd *exec.Cmd
+	return r.ReadContext(context.Background())
+}
+
+// ReadContext reads content from the system clipboard, giving up when ctx is done
+func (r *DefaultReader) ReadContext(ctx context.Context) (string, error) {
+	var args []string
+	opts := r.opts
 
 	switch runtime.GOOS {
 	case "windows":
-		cmd = exec.Command("powershell", "-command", "Get-Clipboard")
+		args = powershellArgs(powershellRead)
+		// The limit applies to the clipboard, not its base64
+		if opts.MaxBytes > 0 {
+			opts.MaxBytes = int64(base64.StdEncoding.EncodedLen(int(opts.MaxBytes))) + 2
+		}
 	case "darwin":
-		cmd = exec.Command("pbpaste")
+		args = []string{"pbpaste"}
 	case "linux":
 		// Try xclip first, then xsel as fallback
 		if _, err := exec.LookPath("xclip"); err == nil {
-			cmd = exec.Command("xclip", "-selection", "clipboard", "-o")
+			args = []string{"xclip", "-selection", "clipboard", "-o"}
 		} else if _, err := exec.LookPath("xsel"); err == nil {
-			cmd = exec.Command("xsel", "--clipboard", "--output")
+			args = []string{"xsel", "--clipboard", "--output"}
 		} else {
-			return "", fmt.Errorf("clipboard access requires xclip or xsel on Linux")
+			return "", fmt.Errorf("%w: clipboard access requires xclip or xsel on Linux, or OSC 52 in a terminal that supports it", ErrUnavailable)
 		}
 	default:
-		return "", fmt.Errorf("unsupported operating system: %s", runtime.GOOS)
+		return "", fmt.Errorf("%w: unsupported operating system: %s", ErrUnavailable, runtime.GOOS)
 	}
 
-	output, err := cmd.Output()
+	output, err := runCommand(ctx, opts, args, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to execute clipboard command: %v", err)
+		return "", err
+	}
+	return clipboardText(runtime.GOOS, output)
+}
+
+// DefaultWriter implements clipboard writing for multiple platforms
+type DefaultWriter struct {
+	opts Options
+}
+
+// NewWriter creates a new clipboard writer with the default timeout
+func NewWriter() Writer {
+	return NewWriterWithOptions(Options{Timeout: DefaultTimeout})
+}
+
+// NewWriterWithOptions creates a new clipboard writer with the given timeout and method
+func NewWriterWithOptions(opts Options) Writer {
+	switch selectMethod(opts.Method, os.Getenv, exec.LookPath) {
+	case MethodOSC52:
+		return NewOSC52(opts)
+	case MethodTmux:
+		return NewTmux(opts)
+	}
+	return &DefaultWriter{opts: opts}
+}
+
+// Write replaces the contents of the system clipboard with text
+func (w *DefaultWriter) Write(text string) error {
+	return w.WriteContext(context.Background(), text)
+}
+
+// WriteContext replaces the contents of the system clipboard, giving up when ctx is done
+func (w *DefaultWriter) WriteContext(ctx context.Context, text string) error {
+	var args []string
+	input := text
+
+	switch runtime.GOOS {
+	case "windows":
+		args = powershellArgs(powershellWrite)
+		input = base64.StdEncoding.EncodeToString([]byte(text))
+	case "darwin":
+		args = []string{"pbcopy"}
+	case "linux":
+		if _, err := exec.LookPath("xclip"); err == nil {
+			args = []string{"xclip", "-selection", "clipboard", "-i"}
+		} else if _, err := exec.LookPath("xsel"); err == nil {
+			args = []string{"xsel", "--clipboard", "--input"}
+		} else {
+			return fmt.Errorf("%w: clipboard access requires xclip or xsel on Linux, or OSC 52 in a terminal that supports it", ErrUnavailable)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported operating system: %s", ErrUnavailable, runtime.GOOS)
+	}
+
+	_, err := runCommand(ctx, w.opts, args, &input)
+	return err
+}
+
+// Check describes how NewReaderWithOptions would reach the clipboard with opts and
+// reports, without touching the clipboard, why that cannot work here. The error wraps
+// ErrUnavailable.
+func Check(opts Options) (string, error) {
+	switch selectMethod(opts.Method, os.Getenv, exec.LookPath) {
+	case MethodOSC52:
+		tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+		if err != nil {
+			return "OSC 52", fmt.Errorf("%w: OSC 52 needs a terminal: %v", ErrUnavailable, err)
+		}
+		tty.Close()
+		return "OSC 52", nil
+	case MethodTmux:
+		if _, err := exec.LookPath("tmux"); err != nil {
+			return "tmux", fmt.Errorf("%w: tmux is not installed", ErrUnavailable)
+		}
+		if os.Getenv("TMUX") == "" {
+			return "tmux", fmt.Errorf("%w: not inside a tmux session", ErrUnavailable)
+		}
+		return "tmux", nil
+	}
+
+	var names []string
+	switch runtime.GOOS {
+	case "windows":
+		names = []string{"powershell"}
+	case "darwin":
+		names = []string{"pbpaste"}
+	case "linux":
+		names = []string{"xclip", "xsel"}
+	default:
+		return "", fmt.Errorf("%w: unsupported operating system: %s", ErrUnavailable, runtime.GOOS)
+	}
+	for _, name := range names {
+		if _, err := exec.LookPath(name); err != nil {
+			continue
+		}
+		if runtime.GOOS == "linux" && os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+			return name, fmt.Errorf("%w: %s needs a display server, but DISPLAY is not set", ErrUnavailable, name)
+		}
+		return name, nil
+	}
+	return "", fmt.Errorf("%w: %s not found", ErrUnavailable, strings.Join(names, " or "))
+}
+
+// selectMethod returns the method to use for method, with the environment looked up by
+// getenv and programs found by lookPath. Automatically that is tmux inside tmux when the
+// platform has no clipboard command, and OSC 52 in an SSH session without a display
+// server, where the clipboard commands would reach the server's clipboard, if any, rather
+// than the user's.
+func selectMethod(method string, getenv func(string) string, lookPath func(string) (string, error)) string {
+	if method != "" && method != MethodAuto {
+		return method
+	}
+	if runtime.GOOS == "windows" {
+		return MethodNative
+	}
+	if getenv("TMUX") != "" && !hasClipboardCommand(lookPath) {
+		return MethodTmux
+	}
+	ssh := getenv("SSH_TTY") != "" || getenv("SSH_CONNECTION") != ""
+	display := getenv("DISPLAY") != "" || getenv("WAYLAND_DISPLAY") != ""
+	if ssh && !display {
+		return MethodOSC52
+	}
+	return MethodNative
+}
+
+// hasClipboardCommand reports whether a program the native method runs is installed
+func hasClipboardCommand(lookPath func(string) (string, error)) bool {
+	for _, name := range []string{"xclip", "xsel", "pbpaste"} {
+		if _, err := lookPath(name); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// runCommand runs a clipboard command and returns what it printed, killing it when it
+// outlives the timeout or prints more than the size limit. With input the command reads
+// it on stdin and its output is discarded, since clipboard tools that stay behind to own
+// the selection would otherwise hold the output open.
+func runCommand(ctx context.Context, opts Options, args []string, input *string) ([]byte, error) {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if opts.Timeout > 0 {
+		runCtx, cancel = context.WithTimeout(runCtx, opts.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(runCtx, args[0], args[1:]...)
+	// A child that inherited the output pipe must not keep the read open after the kill
+	cmd.WaitDelay = time.Second
+	stdout := &limitedBuffer{max: opts.MaxBytes, cancel: cancel}
+	stderr := &limitedBuffer{max: 4096}
+	if input != nil {
+		cmd.Stdin = strings.NewReader(*input)
+	} else {
+		cmd.Stdout = stdout
+	}
+	cmd.Stderr = stderr
+
+	err := cmd.Run()
+	switch {
+	case stdout.exceeded:
+		return nil, fmt.Errorf("%w: %s printed more than %d bytes", ErrTooLarge, args[0], opts.MaxBytes)
+	case ctx.Err() != nil:
+		return nil, ctx.Err()
+	case errors.Is(runCtx.Err(), context.DeadlineExceeded):
+		return nil, fmt.Errorf("%w: %s did not finish within %s", ErrTimeout, args[0], opts.Timeout)
+	case errors.Is(err, exec.ErrNotFound):
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	case err != nil:
+		if message := strings.TrimSpace(stderr.buf.String()); message != "" {
+			return nil, fmt.Errorf("failed to execute clipboard command: %v: %s", err, message)
+		}
+		return nil, fmt.Errorf("failed to execute clipboard command: %v", err)
+	}
+	return stdout.buf.Bytes(), nil
+}
+
+// limitedBuffer collects command output up to max bytes; a write past the limit stops
+// the command through cancel, or is dropped when there is no cancel. It does not embed
+// bytes.Buffer, whose ReadFrom would let exec copy around the limit.
+type limitedBuffer struct {
+	buf      bytes.Buffer
+	max      int64
+	cancel   context.CancelFunc
+	exceeded bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.max > 0 && int64(b.buf.Len()+len(p)) > b.max {
+		if b.cancel == nil {
+			b.buf.Write(p[:b.max-int64(b.buf.Len())])
+			return len(p), nil
+		}
+		b.exceeded = true
+		b.cancel()
+		return 0, ErrTooLarge
 	}
+	return b.buf.Write(p)
+}
 
-	return strings.TrimSpace(string(output)), nil
+// powershellArgs returns the command line that runs script in PowerShell. Profiles can
+// prompt or load slow modules, so they are skipped.
+func powershellArgs(script string) []string {
+	return []string{"powershell", "-NoProfile", "-NonInteractive", "-command", script}
+}
+
+// clipboardText returns the clipboard contents from the output of the clipboard command
+// on goos. Other commands print the clipboard as it is, so that trailing blank lines of a
+// deltagram's final part survive; PowerShell prints its base64.
+func clipboardText(goos string, output []byte) (string, error) {
+	if goos != "windows" {
+		return string(output), nil
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(output)))
+	if err != nil {
+		return "", fmt.Errorf("unexpected output from PowerShell: %w", err)
+	}
+	return string(data), nil
 }
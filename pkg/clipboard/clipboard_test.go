@@ -0,0 +1,175 @@
+package clipboard
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClipboardText(t *testing.T) {
+	tests := []struct {
+		name     string
+		goos     string
+		output   string
+		expected string
+	}{
+		{"trailing blank lines kept", "linux", "+hello\n\n\n", "+hello\n\n\n"},
+		{"leading whitespace kept", "darwin", "\n  indented\n", "\n  indented\n"},
+		{"powershell base64", "windows", "K2hlbGxvDQoNCg==\r\n", "+hello\r\n\r\n"},
+		{"powershell empty clipboard", "windows", "\r\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := clipboardText(tt.goos, []byte(tt.output))
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestClipboardText_UnicodeFidelity(t *testing.T) {
+	// Text that the console code pages of common locales cannot all represent
+	tests := []string{
+		"+café naïve résumé\n",
+		"+日本語のテキスト\n+中文\n",
+		"+emoji 🎉 and ZWJ 👩‍💻\n",
+		"+combining e\u0301 and ñ\n",
+		"\ufeff+byte order mark kept\r\n",
+		"+Ελληνικά, русский, עברית\r\n\r\n",
+	}
+
+	for _, text := range tests {
+		// PowerShell ends its output with a line terminator
+		output := base64.StdEncoding.EncodeToString([]byte(text)) + "\r\n"
+		got, err := clipboardText("windows", []byte(output))
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if got != text {
+			t.Errorf("Expected %q, got %q", text, got)
+		}
+	}
+
+	if _, err := clipboardText("windows", []byte("Get-Clipboard : not base64\r\n")); err == nil {
+		t.Error("Expected an error for output that is not base64")
+	}
+}
+
+func TestPowerShellScripts(t *testing.T) {
+	if !strings.Contains(powershellRead, "Get-Clipboard -Raw") {
+		t.Errorf("Expected the clipboard to be read with -Raw, got %q", powershellRead)
+	}
+	for _, script := range []string{powershellRead, powershellWrite} {
+		if !strings.Contains(script, "[Text.Encoding]::UTF8") {
+			t.Errorf("Expected the clipboard to be converted as UTF-8, got %q", script)
+		}
+	}
+	if args := powershellArgs(powershellRead); args[1] != "-NoProfile" {
+		t.Errorf("Expected profiles to be skipped, got %q", args)
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	for _, name := range []string{"sh", "sleep", "yes"} {
+		if _, err := exec.LookPath(name); err != nil {
+			t.Skipf("%s is not available", name)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		opts     Options
+		args     []string
+		expected string
+		err      error
+	}{
+		{"output", Options{Timeout: 5 * time.Second, MaxBytes: 16}, []string{"sh", "-c", "printf '+hello\\n'"}, "+hello\n", nil},
+		{"no guards", Options{}, []string{"sh", "-c", "printf '+hello\\n'"}, "+hello\n", nil},
+		{"timeout", Options{Timeout: 100 * time.Millisecond}, []string{"sleep", "10"}, "", ErrTimeout},
+		{"too large", Options{Timeout: 5 * time.Second, MaxBytes: 1024}, []string{"yes"}, "", ErrTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			output, err := runCommand(context.Background(), tt.opts, tt.args, nil)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Expected error %v, got: %v", tt.err, err)
+			}
+			if string(output) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, output)
+			}
+			if elapsed := time.Since(start); elapsed > 3*time.Second {
+				t.Errorf("Expected the command to be stopped promptly, took %s", elapsed)
+			}
+		})
+	}
+}
+
+func TestRunCommand_Error(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	_, err := runCommand(context.Background(), Options{}, []string{"sh", "-c", "echo 'Error: Can'\"'\"'t open display' >&2; exit 1"}, nil)
+	if err == nil || err.Error() != "failed to execute clipboard command: exit status 1: Error: Can't open display" {
+		t.Errorf("Expected the command's error output, got: %v", err)
+	}
+}
+
+func TestSelectMethod(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows always uses PowerShell")
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		env      map[string]string
+		tools    bool // Whether xclip, xsel, or pbpaste is installed
+		expected string
+	}{
+		{"local", "", map[string]string{"DISPLAY": ":0"}, true, MethodNative},
+		{"ssh without display", "", map[string]string{"SSH_TTY": "/dev/pts/1"}, true, MethodOSC52},
+		{"ssh connection", MethodAuto, map[string]string{"SSH_CONNECTION": "10.0.0.1 22 10.0.0.2 22"}, true, MethodOSC52},
+		{"ssh with X forwarding", "", map[string]string{"SSH_TTY": "/dev/pts/1", "DISPLAY": "localhost:10.0"}, true, MethodNative},
+		{"ssh with wayland", "", map[string]string{"SSH_TTY": "/dev/pts/1", "WAYLAND_DISPLAY": "wayland-0"}, true, MethodNative},
+		{"tmux without tools", "", map[string]string{"TMUX": "/tmp/tmux-0/default,1,0", "SSH_TTY": "/dev/pts/1"}, false, MethodTmux},
+		{"tmux with tools", "", map[string]string{"TMUX": "/tmp/tmux-0/default,1,0", "DISPLAY": ":0"}, true, MethodNative},
+		{"forced osc52", MethodOSC52, nil, true, MethodOSC52},
+		{"forced tmux", MethodTmux, nil, true, MethodTmux},
+		{"native", MethodNative, map[string]string{"SSH_TTY": "/dev/pts/1"}, true, MethodNative},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(key string) string { return tt.env[key] }
+			lookPath := func(name string) (string, error) {
+				if tt.tools {
+					return "/usr/bin/" + name, nil
+				}
+				return "", exec.ErrNotFound
+			}
+			if got := selectMethod(tt.method, getenv, lookPath); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRunCommand_NotFound(t *testing.T) {
+	_, err := runCommand(context.Background(), Options{}, []string{"deltagram-no-such-clipboard-tool"}, nil)
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Expected ErrUnavailable, got: %v", err)
+	}
+}
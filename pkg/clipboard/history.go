@@ -0,0 +1,96 @@
+package clipboard
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultHistorySize is how many deltagrams a History keeps unless told otherwise
+const DefaultHistorySize = 20
+
+// History keeps the deltagrams most recently read from the clipboard as files in Dir, so
+// that one can still be applied after something else has been copied over it. Entries
+// are named by the time they were added; the oldest are removed beyond Size.
+type History struct {
+	Dir  string
+	Size int
+}
+
+// DefaultHistoryDir returns the per-user directory the history is kept in
+func DefaultHistoryDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "deltagram", "clipboard-history"), nil
+}
+
+// Add records content as the most recent entry. Content equal to the current most
+// recent entry is not recorded twice.
+func (h *History) Add(content string) error {
+	entries, err := h.Entries()
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		if latest, err := os.ReadFile(entries[0]); err == nil && string(latest) == content {
+			return nil
+		}
+	}
+
+	// The clipboard may hold secrets, so only the user can read the history
+	if err := os.MkdirAll(h.Dir, 0700); err != nil {
+		return fmt.Errorf("failed to create clipboard history directory: %w", err)
+	}
+	name := time.Now().UTC().Format("20060102T150405.000000000") + ".deltagram"
+	path := filepath.Join(h.Dir, name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		return fmt.Errorf("failed to write clipboard history: %w", err)
+	}
+
+	entries = append([]string{path}, entries...)
+	for _, old := range entries[min(len(entries), max(h.Size, 1)):] {
+		if err := os.Remove(old); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to prune clipboard history: %w", err)
+		}
+	}
+	return nil
+}
+
+// Entries returns the paths of the recorded deltagrams, most recent first
+func (h *History) Entries() ([]string, error) {
+	files, err := os.ReadDir(h.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read clipboard history: %w", err)
+	}
+	var entries []string
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), ".deltagram") {
+			entries = append(entries, filepath.Join(h.Dir, file.Name()))
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(entries)))
+	return entries, nil
+}
+
+// Entry returns the path of the nth most recent deltagram, 1 being the latest
+func (h *History) Entry(n int) (string, error) {
+	if n < 1 {
+		return "", fmt.Errorf("invalid clipboard history entry %d: entries are numbered from 1, the latest", n)
+	}
+	entries, err := h.Entries()
+	if err != nil {
+		return "", err
+	}
+	if n > len(entries) {
+		return "", fmt.Errorf("clipboard history holds %d deltagram(s), not %d", len(entries), n)
+	}
+	return entries[n-1], nil
+}
@@ -0,0 +1,46 @@
+package clipboard
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	h := &History{Dir: filepath.Join(t.TempDir(), "history"), Size: 3}
+
+	if _, err := h.Entry(1); err == nil || !strings.Contains(err.Error(), "holds 0 deltagram(s)") {
+		t.Fatalf("Expected an empty history error, got: %v", err)
+	}
+
+	for _, content := range []string{"first", "second", "second", "third", "fourth"} {
+		if err := h.Add(content); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+	}
+
+	entries, err := h.Entries()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries, got %d", len(entries))
+	}
+	for n, expected := range []string{"fourth", "third", "second"} {
+		path, err := h.Entry(n + 1)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if data, _ := os.ReadFile(path); string(data) != expected {
+			t.Errorf("Expected entry %d to be %q, got %q", n+1, expected, data)
+		}
+	}
+
+	if _, err := h.Entry(4); err == nil || !strings.Contains(err.Error(), "holds 3 deltagram(s), not 4") {
+		t.Errorf("Expected an out of range error, got: %v", err)
+	}
+	if _, err := h.Entry(0); err == nil {
+		t.Error("Expected an error for entry 0")
+	}
+}
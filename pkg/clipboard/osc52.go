@@ -0,0 +1,184 @@
+package clipboard
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// osc52Query asks the terminal for the contents of its clipboard
+const osc52Query = "\x1b]52;c;?\a"
+
+// OSC52 reaches the clipboard of the terminal the user is typing in with OSC 52 escape
+// sequences, which travel over SSH like any other output, so it works on headless servers
+// without X forwarding. Writing is widely supported; reading must be allowed by the
+// terminal, and many refuse it by default. Inside tmux the sequences are handled by tmux
+// itself when its set-clipboard option is on: writes reach the outer terminal and reads
+// return tmux's newest paste buffer.
+type OSC52 struct {
+	opts Options
+	tty  string // Terminal device to talk to
+}
+
+// NewOSC52 creates a clipboard reader and writer that uses the controlling terminal
+func NewOSC52(opts Options) *OSC52 {
+	return &OSC52{opts: opts, tty: "/dev/tty"}
+}
+
+// Read asks the terminal for the contents of its clipboard
+func (o *OSC52) Read() (string, error) {
+	return o.ReadContext(context.Background())
+}
+
+// ReadContext asks the terminal for the contents of its clipboard, giving up when ctx is
+// done or the terminal does not answer within the timeout
+func (o *OSC52) ReadContext(ctx context.Context) (string, error) {
+	tty, err := os.OpenFile(o.tty, os.O_RDWR, 0)
+	if err != nil {
+		return "", fmt.Errorf("%w: OSC 52 needs a terminal: %v", ErrUnavailable, err)
+	}
+	defer tty.Close()
+
+	// The answer arrives as input, which must neither wait for Enter nor be echoed
+	restore, err := rawMode(tty)
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+
+	if _, err := io.WriteString(tty, osc52Query); err != nil {
+		return "", fmt.Errorf("failed to write to the terminal: %w", err)
+	}
+
+	chunks := make(chan []byte)
+	failed := make(chan error, 1)
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			buf := make([]byte, 4096)
+			n, err := tty.Read(buf)
+			if err != nil {
+				failed <- err
+				return
+			}
+			select {
+			case chunks <- buf[:n]:
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	var timeout <-chan time.Time
+	if o.opts.Timeout > 0 {
+		timer := time.NewTimer(o.opts.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var answer []byte
+	for {
+		select {
+		case chunk := <-chunks:
+			answer = append(answer, chunk...)
+			if text, done, err := parseOSC52(answer); done || err != nil {
+				return text, err
+			}
+			// Base64 takes four bytes for every three of the clipboard
+			if o.opts.MaxBytes > 0 && int64(len(answer)) > o.opts.MaxBytes/3*4+64 {
+				return "", fmt.Errorf("%w: the terminal sent more than %d bytes", ErrTooLarge, o.opts.MaxBytes)
+			}
+		case err := <-failed:
+			return "", fmt.Errorf("failed to read the terminal's answer: %w", err)
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-timeout:
+			return "", fmt.Errorf("%w: the terminal did not answer the OSC 52 query within %s; it may only allow writing the clipboard", ErrTimeout, o.opts.Timeout)
+		}
+	}
+}
+
+// Write sets the terminal's clipboard to text
+func (o *OSC52) Write(text string) error {
+	return o.WriteContext(context.Background(), text)
+}
+
+// WriteContext sets the terminal's clipboard to text. The terminal does not answer, so
+// there is nothing to wait for and ctx is only checked before writing.
+func (o *OSC52) WriteContext(ctx context.Context, text string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tty, err := os.OpenFile(o.tty, os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("%w: OSC 52 needs a terminal: %v", ErrUnavailable, err)
+	}
+	defer tty.Close()
+
+	if _, err := io.WriteString(tty, osc52Sequence(text)); err != nil {
+		return fmt.Errorf("failed to write to the terminal: %w", err)
+	}
+	return nil
+}
+
+// osc52Sequence returns the escape sequence that sets the clipboard to text
+func osc52Sequence(text string) string {
+	return "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+}
+
+// parseOSC52 finds the terminal's answer to an OSC 52 query in input and returns the
+// clipboard it holds. done is false while the answer is incomplete. The answer ends with
+// BEL or ST, depending on the terminal.
+func parseOSC52(input []byte) (text string, done bool, err error) {
+	start := bytes.Index(input, []byte("\x1b]52;"))
+	if start < 0 {
+		return "", false, nil
+	}
+	rest := input[start+len("\x1b]52;"):]
+
+	end := bytes.IndexByte(rest, '\a')
+	if st := bytes.Index(rest, []byte("\x1b\\")); st >= 0 && (end < 0 || st < end) {
+		end = st
+	}
+	if end < 0 {
+		return "", false, nil
+	}
+
+	_, data, ok := bytes.Cut(rest[:end], []byte(";"))
+	if !ok {
+		return "", true, fmt.Errorf("the terminal's OSC 52 answer is malformed")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return "", true, fmt.Errorf("the terminal's OSC 52 answer is not valid base64: %w", err)
+	}
+	return string(decoded), true, nil
+}
+
+// rawMode switches the terminal to raw mode without echo and returns a function that
+// restores its previous settings. It runs stty, which is present wherever /dev/tty is.
+func rawMode(tty *os.File) (func(), error) {
+	saved, err := stty(tty, "-g")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the terminal settings: %w", err)
+	}
+	if _, err := stty(tty, "raw", "-echo"); err != nil {
+		return nil, fmt.Errorf("failed to switch the terminal to raw mode: %w", err)
+	}
+	return func() { stty(tty, strings.TrimSpace(saved)) }, nil
+}
+
+// stty runs stty on the terminal and returns what it printed
+func stty(tty *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = tty
+	output, err := cmd.Output()
+	return string(output), err
+}
@@ -0,0 +1,52 @@
+package clipboard
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseOSC52(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		done     bool
+		err      string
+	}{
+		{"BEL terminated", "\x1b]52;c;K2hlbGxvCg==\a", "+hello\n", true, ""},
+		{"ST terminated", "\x1b]52;c;K2hlbGxvCg==\x1b\\", "+hello\n", true, ""},
+		{"after other input", "typed\x1b]52;p;K2hlbGxvCg==\a", "+hello\n", true, ""},
+		{"empty clipboard", "\x1b]52;c;\a", "", true, ""},
+		{"incomplete", "\x1b]52;c;K2hl", "", false, ""},
+		{"incomplete ST", "\x1b]52;c;K2hlbGxvCg==\x1b", "", false, ""},
+		{"no answer yet", "typed", "", false, ""},
+		{"not base64", "\x1b]52;c;!!\a", "", true, "not valid base64"},
+		{"malformed", "\x1b]52;K2hl\a", "", true, "malformed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, done, err := parseOSC52([]byte(tt.input))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("Expected error containing %q, got: %v", tt.err, err)
+				}
+			} else if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if text != tt.expected || done != tt.done {
+				t.Errorf("Expected %q (done %v), got %q (done %v)", tt.expected, tt.done, text, done)
+			}
+		})
+	}
+}
+
+func TestOSC52Sequence(t *testing.T) {
+	sequence := osc52Sequence("+hello\n")
+	if sequence != "\x1b]52;c;K2hlbGxvCg==\a" {
+		t.Errorf("Expected the base64 clipboard in an OSC 52 sequence, got %q", sequence)
+	}
+	if text, done, err := parseOSC52([]byte(sequence)); err != nil || !done || text != "+hello\n" {
+		t.Errorf("Expected the sequence to parse back, got %q, %v, %v", text, done, err)
+	}
+}
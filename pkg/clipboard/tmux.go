@@ -0,0 +1,40 @@
+package clipboard
+
+import "context"
+
+// Tmux reads and writes tmux paste buffers, the clipboard of a tmux session, so that
+// deltagrams can be passed around on servers without a system clipboard. Reading takes
+// the newest buffer and writing adds a new one; either fails outside a tmux session.
+type Tmux struct {
+	opts Options
+}
+
+// NewTmux creates a clipboard reader and writer that uses tmux paste buffers
+func NewTmux(opts Options) *Tmux {
+	return &Tmux{opts: opts}
+}
+
+// Read returns the newest tmux paste buffer
+func (t *Tmux) Read() (string, error) {
+	return t.ReadContext(context.Background())
+}
+
+// ReadContext returns the newest tmux paste buffer, giving up when ctx is done
+func (t *Tmux) ReadContext(ctx context.Context) (string, error) {
+	output, err := runCommand(ctx, t.opts, []string{"tmux", "show-buffer"}, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
+// Write adds text as the newest tmux paste buffer
+func (t *Tmux) Write(text string) error {
+	return t.WriteContext(context.Background(), text)
+}
+
+// WriteContext adds text as the newest tmux paste buffer, giving up when ctx is done
+func (t *Tmux) WriteContext(ctx context.Context, text string) error {
+	_, err := runCommand(ctx, t.opts, []string{"tmux", "load-buffer", "-"}, &text)
+	return err
+}
@@ -0,0 +1,40 @@
+package clipboard
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestTmux(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux is not available")
+	}
+
+	// Run a private server and point the tmux commands at it as if inside its session
+	socket := filepath.Join(t.TempDir(), "tmux.sock")
+	if output, err := exec.Command("tmux", "-S", socket, "-f", "/dev/null", "new-session", "-d", "sleep 60").CombinedOutput(); err != nil {
+		t.Skipf("cannot start a tmux server: %v: %s", err, output)
+	}
+	defer exec.Command("tmux", "-S", socket, "kill-server").Run()
+	t.Setenv("TMUX", socket+",0,0")
+
+	tmux := NewTmux(Options{Timeout: DefaultTimeout, MaxBytes: DefaultMaxBytes})
+	if _, err := tmux.Read(); err == nil || !strings.Contains(err.Error(), "no buffers") {
+		t.Errorf("Expected an error without buffers, got: %v", err)
+	}
+
+	for _, text := range []string{"older\n", "+hello\n\n"} {
+		if err := tmux.Write(text); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+	}
+	text, err := tmux.Read()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if text != "+hello\n\n" {
+		t.Errorf("Expected the newest buffer, got %q", text)
+	}
+}
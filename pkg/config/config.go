@@ -0,0 +1,294 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/developingjames/deltagrams/pkg/pathglob"
+)
+
+// ProjectFile is the location of the project configuration relative to the target directory
+const ProjectFile = ".deltagram/config.json"
+
+// Config holds user and project settings for deltagram
+type Config struct {
+	Paths     PathsConfig     `json:"paths"`
+	Limits    LimitsConfig    `json:"limits"`
+	Templates TemplatesConfig `json:"templates"`
+	Registry  RegistryConfig  `json:"registry"`
+	Format    FormatConfig    `json:"format"`
+	Audit     AuditConfig     `json:"audit"`
+	Identity  IdentityConfig  `json:"identity"`
+	Display   DisplayConfig   `json:"display"`
+	Clipboard ClipboardConfig `json:"clipboard"`
+}
+
+// ClipboardConfig guards reading a deltagram from the clipboard against clipboard
+// commands that hang or print without end
+type ClipboardConfig struct {
+	// Timeout is how long to wait for the clipboard command, such as "10s"; "0" waits
+	// without limit
+	Timeout string `json:"timeout,omitempty"`
+	// MaxBytes is the largest clipboard accepted; zero keeps the inherited value and a
+	// negative value disables the limit
+	MaxBytes int64 `json:"max_bytes,omitempty"`
+	// Method is how the clipboard is reached: "native" for the platform's clipboard
+	// command, "osc52" for OSC 52 escape sequences through the terminal, "tmux" for tmux
+	// paste buffers, or "auto", the default, for tmux inside tmux when there is no
+	// clipboard command and OSC 52 in an SSH session without a display server
+	Method string `json:"method,omitempty"`
+	// History is how many deltagrams read from the clipboard are kept for `apply
+	// --from-history`; zero keeps the inherited value and a negative value keeps none
+	History int `json:"history,omitempty"`
+}
+
+// TimeoutDuration returns the clipboard timeout, or zero when there is none
+func (c ClipboardConfig) TimeoutDuration() time.Duration {
+	timeout, err := time.ParseDuration(c.Timeout)
+	if err != nil || timeout < 0 {
+		return 0
+	}
+	return timeout
+}
+
+// DisplayConfig controls how diffs are rendered for review by `apply --show-diff` and
+// the conflict prompt
+type DisplayConfig struct {
+	// TabWidth is the number of columns between tab stops when tabs are expanded
+	TabWidth int `json:"tab_width,omitempty"`
+	// WordDiff marks the words that changed within each changed line
+	WordDiff bool `json:"word_diff,omitempty"`
+}
+
+// IdentityConfig names the person using deltagram, for the audit log and the Author
+// header of deltagrams stamped with `fmt --stamp-author`. Only the user configuration may
+// set it, so that a project cannot attribute changes to someone else.
+type IdentityConfig struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
+// String returns the identity as "Name <email>", either part being optional, or "" when
+// no identity is configured
+func (i IdentityConfig) String() string {
+	switch {
+	case i.Email == "":
+		return i.Name
+	case i.Name == "":
+		return "<" + i.Email + ">"
+	}
+	return i.Name + " <" + i.Email + ">"
+}
+
+// AuditConfig turns on the tamper-evident log of applies checked by `deltagram audit verify`
+type AuditConfig struct {
+	Enabled bool `json:"enabled,omitempty"`
+	// Log is the log file, relative to the working directory; defaults to .deltagram/audit.log
+	Log string `json:"log,omitempty"`
+}
+
+// FormatConfig chooses the formatters `apply --format` runs on the files it changed
+type FormatConfig struct {
+	// OnApply formats after every apply, as if --format were given
+	OnApply bool `json:"on_apply,omitempty"`
+	// FixWhitespace strips trailing whitespace from added lines and ends written files
+	// with exactly one newline on every apply, as if --fix-whitespace were given
+	FixWhitespace bool `json:"fix_whitespace,omitempty"`
+	// Formatters maps a file extension such as ".go" to the command that formats files
+	// with it; the paths of the changed files are appended to the command. An empty
+	// command turns off the default for that extension. Only the user configuration may
+	// set formatters, so that a project cannot make apply run arbitrary programs.
+	Formatters map[string][]string `json:"formatters,omitempty"`
+}
+
+// RegistryConfig points `deltagram push` and `deltagram pull` at a shared registry
+type RegistryConfig struct {
+	URL string `json:"url,omitempty"`
+}
+
+// TemplatesConfig tells `deltagram init` where to find named templates
+type TemplatesConfig struct {
+	// Dir holds local templates as <name>.deltagram; defaults to a templates directory
+	// next to the user configuration file
+	Dir string `json:"dir,omitempty"`
+	// Registry is a base URL serving templates as <registry>/<name>.deltagram
+	Registry string `json:"registry,omitempty"`
+}
+
+// LimitsConfig bounds the size of a deltagram; zero keeps the inherited value and a
+// negative value disables the limit
+type LimitsConfig struct {
+	MaxParts      int   `json:"max_parts,omitempty"`
+	MaxFileSize   int64 `json:"max_file_size,omitempty"`
+	MaxTotalBytes int64 `json:"max_total_bytes,omitempty"`
+	// StreamThreshold is the file size above which content operations stream the file
+	// instead of loading it into memory
+	StreamThreshold int64 `json:"stream_threshold,omitempty"`
+}
+
+// Default returns the configuration used when no files override it
+func Default() *Config {
+	return &Config{
+		Limits: LimitsConfig{
+			MaxParts:      1000,
+			MaxFileSize:   50 << 20,
+			MaxTotalBytes: 200 << 20,
+		},
+		Format: FormatConfig{
+			Formatters: map[string][]string{
+				".go":  {"gofmt", "-w"},
+				".ts":  {"prettier", "--write"},
+				".tsx": {"prettier", "--write"},
+				".py":  {"black", "-q"},
+			},
+		},
+		Display: DisplayConfig{TabWidth: 8},
+		Clipboard: ClipboardConfig{
+			Timeout:  "10s",
+			MaxBytes: 32 << 20,
+			History:  20,
+		},
+	}
+}
+
+// PathsConfig restricts which paths a deltagram may write to
+type PathsConfig struct {
+	// Allow lists glob patterns a path must match (or be beneath) to be written;
+	// an empty list allows every path not otherwise denied
+	Allow []string `json:"allow,omitempty"`
+	// Deny lists glob patterns that may never be written
+	Deny []string `json:"deny,omitempty"`
+	// Normalize is "nfc" to compose letters and combining accents in paths into single
+	// accented letters
+	Normalize string `json:"normalize,omitempty"`
+	// ASCII refuses paths with characters outside ASCII, such as lookalike letters
+	ASCII bool `json:"ascii,omitempty"`
+	// Lowercase changes every path to lowercase before it is written
+	Lowercase bool `json:"lowercase,omitempty"`
+}
+
+// UserFile returns the path of the per-user configuration file
+func UserFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "deltagram", "config.json"), nil
+}
+
+// Load reads the user configuration followed by the project configuration in baseDir on
+// top of the defaults. Missing files are not an error; list settings from both files are
+// combined and scalar settings from later files win.
+func Load(baseDir string) (*Config, error) {
+	cfg := Default()
+
+	if userFile, err := UserFile(); err == nil {
+		if err := cfg.merge(userFile, true); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.merge(filepath.Join(baseDir, filepath.FromSlash(ProjectFile)), false); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// merge reads a configuration file and combines it into c. Settings that run programs
+// are only taken from the user's own configuration.
+func (c *Config) merge(path string, user bool) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read config %s: %v", path, err)
+	}
+
+	var other Config
+	if err := json.Unmarshal(data, &other); err != nil {
+		return fmt.Errorf("invalid config %s: %v", path, err)
+	}
+
+	for _, pattern := range append(append([]string(nil), other.Paths.Allow...), other.Paths.Deny...) {
+		if err := pathglob.Validate(pattern); err != nil {
+			return fmt.Errorf("invalid config %s: path pattern %q: %v", path, pattern, err)
+		}
+	}
+	c.Paths.Allow = append(c.Paths.Allow, other.Paths.Allow...)
+	c.Paths.Deny = append(c.Paths.Deny, other.Paths.Deny...)
+	if other.Paths.Normalize != "" {
+		c.Paths.Normalize = other.Paths.Normalize
+	}
+	c.Paths.ASCII = c.Paths.ASCII || other.Paths.ASCII
+	c.Paths.Lowercase = c.Paths.Lowercase || other.Paths.Lowercase
+
+	if other.Limits.MaxParts != 0 {
+		c.Limits.MaxParts = other.Limits.MaxParts
+	}
+	if other.Limits.MaxFileSize != 0 {
+		c.Limits.MaxFileSize = other.Limits.MaxFileSize
+	}
+	if other.Limits.MaxTotalBytes != 0 {
+		c.Limits.MaxTotalBytes = other.Limits.MaxTotalBytes
+	}
+	if other.Limits.StreamThreshold != 0 {
+		c.Limits.StreamThreshold = other.Limits.StreamThreshold
+	}
+	if other.Templates.Dir != "" {
+		c.Templates.Dir = other.Templates.Dir
+	}
+	if other.Templates.Registry != "" {
+		c.Templates.Registry = other.Templates.Registry
+	}
+	if other.Registry.URL != "" {
+		c.Registry.URL = other.Registry.URL
+	}
+	if other.Display.TabWidth != 0 {
+		c.Display.TabWidth = other.Display.TabWidth
+	}
+	c.Display.WordDiff = c.Display.WordDiff || other.Display.WordDiff
+	if other.Clipboard.Timeout != "" {
+		if _, err := time.ParseDuration(other.Clipboard.Timeout); err != nil {
+			return fmt.Errorf("invalid config %s: clipboard.timeout %q is not a duration such as \"10s\"", path, other.Clipboard.Timeout)
+		}
+		c.Clipboard.Timeout = other.Clipboard.Timeout
+	}
+	if other.Clipboard.MaxBytes != 0 {
+		c.Clipboard.MaxBytes = other.Clipboard.MaxBytes
+	}
+	switch other.Clipboard.Method {
+	case "":
+	case "auto", "native", "osc52", "tmux":
+		c.Clipboard.Method = other.Clipboard.Method
+	default:
+		return fmt.Errorf("invalid config %s: clipboard.method %q must be auto, native, osc52, or tmux", path, other.Clipboard.Method)
+	}
+	if other.Clipboard.History != 0 {
+		c.Clipboard.History = other.Clipboard.History
+	}
+	c.Format.OnApply = c.Format.OnApply || other.Format.OnApply
+	c.Format.FixWhitespace = c.Format.FixWhitespace || other.Format.FixWhitespace
+	c.Audit.Enabled = c.Audit.Enabled || other.Audit.Enabled
+	if other.Audit.Log != "" {
+		c.Audit.Log = other.Audit.Log
+	}
+	if user {
+		for ext, command := range other.Format.Formatters {
+			c.Format.Formatters[ext] = command
+		}
+	} else if len(other.Format.Formatters) > 0 {
+		return fmt.Errorf("invalid config %s: format.formatters may only be set in the user configuration", path)
+	}
+	if other.Identity != (IdentityConfig{}) {
+		if !user {
+			return fmt.Errorf("invalid config %s: identity may only be set in the user configuration", path)
+		}
+		c.Identity = other.Identity
+	}
+	return nil
+}
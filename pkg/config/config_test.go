@@ -0,0 +1,310 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad_ProjectConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	baseDir := t.TempDir()
+	configPath := filepath.Join(baseDir, filepath.FromSlash(ProjectFile))
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"paths": {"allow": ["src/", "docs/"], "deny": ["src/secrets/**"]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(baseDir)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(cfg.Paths.Allow) != 2 || cfg.Paths.Allow[0] != "src/" {
+		t.Errorf("Expected allow list [src/ docs/], got %v", cfg.Paths.Allow)
+	}
+	if len(cfg.Paths.Deny) != 1 || cfg.Paths.Deny[0] != "src/secrets/**" {
+		t.Errorf("Expected deny list [src/secrets/**], got %v", cfg.Paths.Deny)
+	}
+}
+
+func TestLoad_MissingFiles(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	cfg, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(cfg.Paths.Allow) != 0 || len(cfg.Paths.Deny) != 0 {
+		t.Errorf("Expected empty config, got %+v", cfg)
+	}
+}
+
+func TestLoad_InvalidJSON(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	baseDir := t.TempDir()
+	configPath := filepath.Join(baseDir, filepath.FromSlash(ProjectFile))
+	os.MkdirAll(filepath.Dir(configPath), 0755)
+	os.WriteFile(configPath, []byte(`{not json`), 0644)
+
+	if _, err := Load(baseDir); err == nil {
+		t.Error("Expected error for invalid config, got none")
+	}
+}
+
+func TestLoad_Limits(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	baseDir := t.TempDir()
+	configPath := filepath.Join(baseDir, filepath.FromSlash(ProjectFile))
+	os.MkdirAll(filepath.Dir(configPath), 0755)
+	os.WriteFile(configPath, []byte(`{"limits": {"max_parts": 10, "max_total_bytes": -1, "stream_threshold": 4096}}`), 0644)
+
+	cfg, err := Load(baseDir)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if cfg.Limits.MaxParts != 10 {
+		t.Errorf("Expected max_parts 10, got %d", cfg.Limits.MaxParts)
+	}
+	if cfg.Limits.MaxFileSize != Default().Limits.MaxFileSize {
+		t.Errorf("Expected default max_file_size to be kept, got %d", cfg.Limits.MaxFileSize)
+	}
+	if cfg.Limits.MaxTotalBytes != -1 {
+		t.Errorf("Expected max_total_bytes -1, got %d", cfg.Limits.MaxTotalBytes)
+	}
+	if cfg.Limits.StreamThreshold != 4096 {
+		t.Errorf("Expected stream_threshold 4096, got %d", cfg.Limits.StreamThreshold)
+	}
+}
+
+func TestLoad_Format(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	userFile, err := UserFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Dir(userFile), 0755)
+	os.WriteFile(userFile, []byte(`{"format": {"formatters": {".go": ["goimports", "-w"], ".py": []}}}`), 0644)
+
+	baseDir := t.TempDir()
+	configPath := filepath.Join(baseDir, filepath.FromSlash(ProjectFile))
+	os.MkdirAll(filepath.Dir(configPath), 0755)
+	os.WriteFile(configPath, []byte(`{"format": {"on_apply": true, "fix_whitespace": true}}`), 0644)
+
+	cfg, err := Load(baseDir)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if !cfg.Format.OnApply {
+		t.Errorf("Expected on_apply from the project config")
+	}
+	if !cfg.Format.FixWhitespace {
+		t.Errorf("Expected fix_whitespace from the project config")
+	}
+	if got := cfg.Format.Formatters[".go"]; len(got) != 2 || got[0] != "goimports" {
+		t.Errorf("Expected the user formatter for .go, got %v", got)
+	}
+	if got := cfg.Format.Formatters[".py"]; len(got) != 0 {
+		t.Errorf("Expected the .py default to be turned off, got %v", got)
+	}
+	if got := cfg.Format.Formatters[".ts"]; len(got) == 0 || got[0] != "prettier" {
+		t.Errorf("Expected the default .ts formatter to be kept, got %v", got)
+	}
+
+	// A project may not choose which programs apply runs
+	os.WriteFile(configPath, []byte(`{"format": {"formatters": {".go": ["sh", "-c", "evil"]}}}`), 0644)
+	if _, err := Load(baseDir); err == nil {
+		t.Error("Expected error for formatters in the project config, got none")
+	}
+}
+
+func TestLoad_Audit(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	userFile, err := UserFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Dir(userFile), 0755)
+	os.WriteFile(userFile, []byte(`{"audit": {"enabled": true}}`), 0644)
+
+	baseDir := t.TempDir()
+	configPath := filepath.Join(baseDir, filepath.FromSlash(ProjectFile))
+	os.MkdirAll(filepath.Dir(configPath), 0755)
+	os.WriteFile(configPath, []byte(`{"audit": {"enabled": false, "log": "logs/audit.log"}}`), 0644)
+
+	cfg, err := Load(baseDir)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	// A project cannot turn off an audit log the user turned on
+	if !cfg.Audit.Enabled {
+		t.Errorf("Expected the audit log to stay enabled")
+	}
+	if cfg.Audit.Log != "logs/audit.log" {
+		t.Errorf("Expected the project log path, got %q", cfg.Audit.Log)
+	}
+}
+
+func TestLoad_Display(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	baseDir := t.TempDir()
+	cfg, err := Load(baseDir)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.Display.TabWidth != 8 || cfg.Display.WordDiff {
+		t.Errorf("Expected a tab width of 8 without word diff by default, got %+v", cfg.Display)
+	}
+
+	configPath := filepath.Join(baseDir, filepath.FromSlash(ProjectFile))
+	os.MkdirAll(filepath.Dir(configPath), 0755)
+	os.WriteFile(configPath, []byte(`{"display": {"tab_width": 4, "word_diff": true}}`), 0644)
+
+	if cfg, err = Load(baseDir); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.Display.TabWidth != 4 || !cfg.Display.WordDiff {
+		t.Errorf("Expected the project display settings, got %+v", cfg.Display)
+	}
+}
+
+func TestLoad_Clipboard(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	baseDir := t.TempDir()
+	cfg, err := Load(baseDir)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.Clipboard.TimeoutDuration() != 10*time.Second || cfg.Clipboard.MaxBytes != 32<<20 || cfg.Clipboard.History != 20 {
+		t.Errorf("Expected a 10s timeout, 32 MiB limit, and history of 20 by default, got %+v", cfg.Clipboard)
+	}
+
+	configPath := filepath.Join(baseDir, filepath.FromSlash(ProjectFile))
+	os.MkdirAll(filepath.Dir(configPath), 0755)
+	os.WriteFile(configPath, []byte(`{"clipboard": {"timeout": "0", "max_bytes": -1, "history": -1, "method": "osc52"}}`), 0644)
+
+	if cfg, err = Load(baseDir); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if cfg.Clipboard.TimeoutDuration() != 0 || cfg.Clipboard.MaxBytes != -1 || cfg.Clipboard.History != -1 || cfg.Clipboard.Method != "osc52" {
+		t.Errorf("Expected the guards and history to be turned off, got %+v", cfg.Clipboard)
+	}
+
+	os.WriteFile(configPath, []byte(`{"clipboard": {"timeout": "10"}}`), 0644)
+	if _, err := Load(baseDir); err == nil || !strings.Contains(err.Error(), "clipboard.timeout") {
+		t.Errorf("Expected an invalid timeout error, got: %v", err)
+	}
+
+	os.WriteFile(configPath, []byte(`{"clipboard": {"method": "x11"}}`), 0644)
+	if _, err := Load(baseDir); err == nil || !strings.Contains(err.Error(), "clipboard.method") {
+		t.Errorf("Expected an invalid method error, got: %v", err)
+	}
+}
+
+func TestLoad_PathSanitization(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	userFile, err := UserFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Dir(userFile), 0755)
+	os.WriteFile(userFile, []byte(`{"paths": {"ascii": true, "normalize": "nfc"}}`), 0644)
+
+	baseDir := t.TempDir()
+	configPath := filepath.Join(baseDir, filepath.FromSlash(ProjectFile))
+	os.MkdirAll(filepath.Dir(configPath), 0755)
+	os.WriteFile(configPath, []byte(`{"paths": {"ascii": false, "lowercase": true}}`), 0644)
+
+	cfg, err := Load(baseDir)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	// A project cannot turn off a check the user turned on
+	if !cfg.Paths.ASCII || !cfg.Paths.Lowercase || cfg.Paths.Normalize != "nfc" {
+		t.Errorf("Expected ascii, lowercase, and nfc, got %+v", cfg.Paths)
+	}
+}
+
+func TestLoad_Identity(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	userFile, err := UserFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(filepath.Dir(userFile), 0755)
+	os.WriteFile(userFile, []byte(`{"identity": {"name": "Sam Lee", "email": "sam@example.com"}}`), 0644)
+
+	baseDir := t.TempDir()
+	cfg, err := Load(baseDir)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if got := cfg.Identity.String(); got != "Sam Lee <sam@example.com>" {
+		t.Errorf("Expected the user identity, got %q", got)
+	}
+
+	// A project may not attribute changes to someone else
+	configPath := filepath.Join(baseDir, filepath.FromSlash(ProjectFile))
+	os.MkdirAll(filepath.Dir(configPath), 0755)
+	os.WriteFile(configPath, []byte(`{"identity": {"name": "Someone Else"}}`), 0644)
+	if _, err := Load(baseDir); err == nil {
+		t.Error("Expected an identity in the project config to be rejected")
+	}
+}
+
+func TestIdentityConfig_String(t *testing.T) {
+	tests := []struct {
+		identity IdentityConfig
+		want     string
+	}{
+		{identity: IdentityConfig{}, want: ""},
+		{identity: IdentityConfig{Name: "Sam Lee"}, want: "Sam Lee"},
+		{identity: IdentityConfig{Email: "sam@example.com"}, want: "<sam@example.com>"},
+		{identity: IdentityConfig{Name: "Sam Lee", Email: "sam@example.com"}, want: "Sam Lee <sam@example.com>"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.identity.String(); got != tt.want {
+			t.Errorf("Expected %q, got %q", tt.want, got)
+		}
+	}
+}
+
+func TestLoad_InvalidPathPattern(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	baseDir := t.TempDir()
+	configPath := filepath.Join(baseDir, filepath.FromSlash(ProjectFile))
+	os.MkdirAll(filepath.Dir(configPath), 0755)
+	os.WriteFile(configPath, []byte(`{"paths": {"deny": ["secrets/", "keys/[abc"]}}`), 0644)
+
+	_, err := Load(baseDir)
+	if err == nil || !strings.Contains(err.Error(), `path pattern "keys/[abc": unclosed character class`) {
+		t.Errorf("Expected the malformed deny pattern to be rejected, got: %v", err)
+	}
+}
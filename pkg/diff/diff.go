@@ -0,0 +1,249 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OpType identifies the kind of a line edit
+type OpType byte
+
+const (
+	// Equal marks a line present in both texts
+	Equal OpType = ' '
+	// Delete marks a line only present in the old text
+	Delete OpType = '-'
+	// Insert marks a line only present in the new text
+	Insert OpType = '+'
+)
+
+// Edit is a single line of an edit script
+type Edit struct {
+	Type OpType
+	Line string
+}
+
+// Hunk is a group of edits with surrounding context, as in a unified diff
+type Hunk struct {
+	OldStart int // 1-based; 0 when OldCount is 0 at the start of the file
+	OldCount int
+	NewStart int
+	NewCount int
+	Edits    []Edit
+}
+
+// DefaultContext is the number of context lines used by unified diffs
+const DefaultContext = 3
+
+// Lines computes a minimal edit script turning a into b using Myers' algorithm
+func Lines(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+
+	offset := max
+	v := make([]int, 2*max+2)
+	var trace [][]int
+
+	for d := 0; d <= max; d++ {
+		snapshot := make([]int, len(v))
+		copy(snapshot, v)
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b, offset)
+			}
+		}
+	}
+
+	return nil
+}
+
+// backtrack walks the recorded search frontiers backwards to build the edit script
+func backtrack(trace [][]int, a, b []string, offset int) []Edit {
+	x, y := len(a), len(b)
+	var edits []Edit
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, Edit{Type: Equal, Line: a[x]})
+		}
+
+		if d > 0 {
+			if x == prevX {
+				y--
+				edits = append(edits, Edit{Type: Insert, Line: b[y]})
+			} else {
+				x--
+				edits = append(edits, Edit{Type: Delete, Line: a[x]})
+			}
+		}
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// Hunks groups an edit script into hunks with the given number of context lines
+func Hunks(edits []Edit, context int) []Hunk {
+	var hunks []Hunk
+
+	i := 0
+	for i < len(edits) {
+		if edits[i].Type == Equal {
+			i++
+			continue
+		}
+
+		// Extend the group while the gap between changes fits in shared context
+		first, last := i, i
+		for j := i + 1; j < len(edits); j++ {
+			if edits[j].Type == Equal {
+				continue
+			}
+			if j-last-1 > 2*context {
+				break
+			}
+			last = j
+		}
+
+		start := first - context
+		if start < 0 {
+			start = 0
+		}
+		end := last + 1 + context
+		if end > len(edits) {
+			end = len(edits)
+		}
+
+		hunk := Hunk{
+			OldStart: countSide(edits[:start], Delete) + 1,
+			NewStart: countSide(edits[:start], Insert) + 1,
+			Edits:    edits[start:end],
+		}
+		hunk.OldCount = countSide(hunk.Edits, Delete)
+		hunk.NewCount = countSide(hunk.Edits, Insert)
+
+		// An empty range is reported as starting at the line before it
+		if hunk.OldCount == 0 {
+			hunk.OldStart--
+		}
+		if hunk.NewCount == 0 {
+			hunk.NewStart--
+		}
+
+		hunks = append(hunks, hunk)
+		i = end
+	}
+
+	return hunks
+}
+
+// countSide counts the lines of one side of an edit script: equal lines plus edits of
+// type t (Delete for the old side, Insert for the new side)
+func countSide(edits []Edit, t OpType) int {
+	count := 0
+	for _, edit := range edits {
+		if edit.Type == t || edit.Type == Equal {
+			count++
+		}
+	}
+	return count
+}
+
+// SplitLines splits text into lines, reporting whether the final line lacked a newline
+func SplitLines(text string) (lines []string, noFinalNewline bool) {
+	if text == "" {
+		return nil, false
+	}
+	lines = strings.Split(text, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1], false
+	}
+	return lines, true
+}
+
+// Unified renders a unified diff between two texts. Empty names default to the usual
+// a/ and b/ labels; /dev/null should be passed for a missing side.
+func Unified(oldName, newName, oldText, newText string, context int) string {
+	oldLines, oldNoEOL := SplitLines(oldText)
+	newLines, newNoEOL := SplitLines(newText)
+
+	// Distinguish a missing final newline so that it shows up as a change
+	if oldNoEOL && len(oldLines) > 0 {
+		oldLines[len(oldLines)-1] += noNewlineMarker
+	}
+	if newNoEOL && len(newLines) > 0 {
+		newLines[len(newLines)-1] += noNewlineMarker
+	}
+
+	hunks := Hunks(Lines(oldLines, newLines), context)
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+	for _, hunk := range hunks {
+		b.WriteString(FormatHunkHeader(hunk))
+		b.WriteString("\n")
+		for _, edit := range hunk.Edits {
+			line := strings.TrimSuffix(edit.Line, noNewlineMarker)
+			b.WriteByte(byte(edit.Type))
+			b.WriteString(line)
+			b.WriteString("\n")
+			if line != edit.Line {
+				b.WriteString("\\ No newline at end of file\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+// noNewlineMarker tags the last line of a text without a trailing newline while diffing;
+// it contains a NUL byte so that it cannot collide with real content
+const noNewlineMarker = "\x00noeol"
+
+// FormatHunkHeader renders the @@ header line of a hunk
+func FormatHunkHeader(h Hunk) string {
+	return fmt.Sprintf("@@ -%s +%s @@", formatRange(h.OldStart, h.OldCount), formatRange(h.NewStart, h.NewCount))
+}
+
+// formatRange renders a start,count pair, omitting a count of one
+func formatRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
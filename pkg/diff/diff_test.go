@@ -0,0 +1,77 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLines(t *testing.T) {
+	a := []string{"a", "b", "c", "d"}
+	b := []string{"a", "c", "d", "e"}
+
+	edits := Lines(a, b)
+
+	var rendered []string
+	for _, edit := range edits {
+		rendered = append(rendered, string(edit.Type)+edit.Line)
+	}
+
+	expected := []string{" a", "-b", " c", " d", "+e"}
+	if strings.Join(rendered, "|") != strings.Join(expected, "|") {
+		t.Errorf("Expected %v, got %v", expected, rendered)
+	}
+}
+
+func TestUnified(t *testing.T) {
+	oldText := "line 1\nline 2\nline 3\nline 4\nline 5\nline 6\nline 7\nline 8\nline 9\nline 10\n"
+	newText := "line 1\nline 2 changed\nline 3\nline 4\nline 5\nline 6\nline 7\nline 8\nline 9\nline 10\nline 11\n"
+
+	expected := `--- a/file.txt
++++ b/file.txt
+@@ -1,5 +1,5 @@
+ line 1
+-line 2
++line 2 changed
+ line 3
+ line 4
+ line 5
+@@ -8,3 +8,4 @@
+ line 8
+ line 9
+ line 10
++line 11
+`
+
+	got := Unified("a/file.txt", "b/file.txt", oldText, newText, DefaultContext)
+	if got != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, got)
+	}
+}
+
+func TestUnified_NewAndDeletedFiles(t *testing.T) {
+	created := Unified("/dev/null", "b/new.txt", "", "one\ntwo", DefaultContext)
+	expected := "--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1,2 @@\n+one\n+two\n\\ No newline at end of file\n"
+	if created != expected {
+		t.Errorf("Expected:\n%q\nGot:\n%q", expected, created)
+	}
+
+	deleted := Unified("a/old.txt", "/dev/null", "gone\n", "", DefaultContext)
+	expected = "--- a/old.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-gone\n"
+	if deleted != expected {
+		t.Errorf("Expected:\n%q\nGot:\n%q", expected, deleted)
+	}
+}
+
+func TestUnified_NoChanges(t *testing.T) {
+	if got := Unified("a/x", "b/x", "same\n", "same\n", DefaultContext); got != "" {
+		t.Errorf("Expected empty diff, got %q", got)
+	}
+}
+
+func TestUnified_FinalNewlineChange(t *testing.T) {
+	got := Unified("a/x", "b/x", "one\ntwo", "one\ntwo\n", DefaultContext)
+	expected := "--- a/x\n+++ b/x\n@@ -1,2 +1,2 @@\n one\n-two\n\\ No newline at end of file\n+two\n"
+	if got != expected {
+		t.Errorf("Expected:\n%q\nGot:\n%q", expected, got)
+	}
+}
@@ -0,0 +1,258 @@
+package diff
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DisplayOptions controls how Display renders a diff for people to read. The result is
+// for review only: with tabs expanded or words marked it no longer applies as a patch.
+type DisplayOptions struct {
+	// TabWidth expands tabs to stops this many columns apart, counted from the start of
+	// each line's content so that the +, -, or space in front does not shift them; 0
+	// leaves tabs alone
+	TabWidth int
+	// WordDiff marks the words that changed between a removed line and the added line
+	// that replaces it
+	WordDiff bool
+	// Color uses ANSI colors: removed lines red, added lines green, hunk headers cyan, and
+	// changed words in reverse video. Without it, changed words are marked [-old-] and
+	// {+new+} as in git diff --word-diff=plain.
+	Color bool
+}
+
+// ANSI escape sequences used by Display
+const (
+	ansiReset   = "\x1b[0m"
+	ansiBold    = "\x1b[1m"
+	ansiRed     = "\x1b[31m"
+	ansiGreen   = "\x1b[32m"
+	ansiCyan    = "\x1b[36m"
+	ansiReverse = "\x1b[7m"
+	ansiNoRev   = "\x1b[27m"
+)
+
+// segment is a run of a line's text, changed when word diff found it only on one side
+type segment struct {
+	text    string
+	changed bool
+}
+
+// Display renders unified diff text for review as the options ask
+func Display(unified string, opts DisplayOptions) string {
+	if unified == "" {
+		return ""
+	}
+	lines := strings.Split(strings.TrimSuffix(unified, "\n"), "\n")
+
+	var b strings.Builder
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case isFileHeader(lines, i):
+			b.WriteString(opts.paint(ansiBold, line) + "\n")
+			b.WriteString(opts.paint(ansiBold, lines[i+1]) + "\n")
+			i++
+		case strings.HasPrefix(line, "@@"):
+			b.WriteString(opts.paint(ansiCyan, line) + "\n")
+		case strings.HasPrefix(line, "-") || strings.HasPrefix(line, "+"):
+			// A run of removed lines and the added lines after it are compared pairwise
+			removedEnd := i
+			for removedEnd < len(lines) && strings.HasPrefix(lines[removedEnd], "-") && !isFileHeader(lines, removedEnd) {
+				removedEnd++
+			}
+			addedEnd := removedEnd
+			for addedEnd < len(lines) && strings.HasPrefix(lines[addedEnd], "+") {
+				addedEnd++
+			}
+			removed, added := lines[i:removedEnd], lines[removedEnd:addedEnd]
+			for k, line := range removed {
+				var segments []segment
+				if k < len(added) && opts.WordDiff {
+					segments, _ = wordSegments(line[1:], added[k][1:])
+				}
+				b.WriteString(opts.renderLine('-', line[1:], segments) + "\n")
+			}
+			for k, line := range added {
+				var segments []segment
+				if k < len(removed) && opts.WordDiff {
+					_, segments = wordSegments(removed[k][1:], line[1:])
+				}
+				b.WriteString(opts.renderLine('+', line[1:], segments) + "\n")
+			}
+			i = addedEnd - 1
+		case strings.HasPrefix(line, " "):
+			b.WriteString(opts.renderLine(' ', line[1:], nil) + "\n")
+		default:
+			b.WriteString(line + "\n")
+		}
+	}
+	return b.String()
+}
+
+// DisplayPair renders two versions of a line as Display would render them as a removed
+// and an added line, without the leading - and +
+func DisplayPair(old, new string, opts DisplayOptions) (string, string) {
+	var oldSegments, newSegments []segment
+	if opts.WordDiff {
+		oldSegments, newSegments = wordSegments(old, new)
+	}
+	return opts.renderText("", old, oldSegments, ansiRed, "[-", "-]"), opts.renderText("", new, newSegments, ansiGreen, "{+", "+}")
+}
+
+// ExpandTabs replaces each tab in line with spaces up to the next multiple of width
+// columns; a width of 0 or less leaves the line alone
+func ExpandTabs(line string, width int) string {
+	expanded, _ := expandTabs(line, 0, width)
+	return expanded
+}
+
+// wordSegments splits two versions of a line into words and marks the words found in
+// only one of them. Nothing is marked when the lines share no words other than
+// whitespace, since then the whole line changed.
+func wordSegments(old, new string) ([]segment, []segment) {
+	oldWords, newWords := words(old), words(new)
+	edits := Lines(oldWords, newWords)
+
+	shared := false
+	for _, edit := range edits {
+		if edit.Type == Equal && strings.TrimSpace(edit.Line) != "" {
+			shared = true
+			break
+		}
+	}
+	if !shared {
+		return nil, nil
+	}
+
+	var oldSegments, newSegments []segment
+	for _, edit := range edits {
+		switch edit.Type {
+		case Equal:
+			oldSegments = appendSegment(oldSegments, edit.Line, false)
+			newSegments = appendSegment(newSegments, edit.Line, false)
+		case Delete:
+			oldSegments = appendSegment(oldSegments, edit.Line, true)
+		case Insert:
+			newSegments = appendSegment(newSegments, edit.Line, true)
+		}
+	}
+	return oldSegments, newSegments
+}
+
+// appendSegment adds text to the segments, joining it to the last one when both are
+// changed or both are not
+func appendSegment(segments []segment, text string, changed bool) []segment {
+	if n := len(segments); n > 0 && segments[n-1].changed == changed {
+		segments[n-1].text += text
+		return segments
+	}
+	return append(segments, segment{text: text, changed: changed})
+}
+
+// renderLine renders one line of a hunk, marking the changed segments if there are any
+func (opts DisplayOptions) renderLine(prefix byte, content string, segments []segment) string {
+	switch prefix {
+	case '-':
+		return opts.renderText("-", content, segments, ansiRed, "[-", "-]")
+	case '+':
+		return opts.renderText("+", content, segments, ansiGreen, "{+", "+}")
+	}
+	expanded, _ := expandTabs(content, 0, opts.TabWidth)
+	return " " + expanded
+}
+
+// renderText renders a line in the given color after its prefix, marking its changed
+// segments. Tab stops are counted from after the prefix.
+func (opts DisplayOptions) renderText(prefix, text string, segments []segment, color, open, close string) string {
+	if segments == nil {
+		segments = []segment{{text: text}}
+	}
+
+	var b strings.Builder
+	b.WriteString(prefix)
+	column := 0
+	for _, seg := range segments {
+		expanded, next := expandTabs(seg.text, column, opts.TabWidth)
+		column = next
+		switch {
+		case !seg.changed:
+			b.WriteString(expanded)
+		case opts.Color:
+			b.WriteString(ansiReverse + expanded + ansiNoRev)
+		default:
+			b.WriteString(open + expanded + close)
+		}
+	}
+	return opts.paint(color, b.String())
+}
+
+// paint wraps text in an ANSI color when colors are on
+func (opts DisplayOptions) paint(color, text string) string {
+	if !opts.Color {
+		return text
+	}
+	return color + text + ansiReset
+}
+
+// expandTabs expands the tabs in text, which starts at the given column, and returns the
+// column after it
+func expandTabs(text string, column, width int) (string, int) {
+	if width <= 0 || !strings.Contains(text, "\t") {
+		return text, column + utf8.RuneCountInString(text)
+	}
+	var b strings.Builder
+	for _, r := range text {
+		if r == '\t' {
+			spaces := width - column%width
+			b.WriteString(strings.Repeat(" ", spaces))
+			column += spaces
+			continue
+		}
+		b.WriteRune(r)
+		column++
+	}
+	return b.String(), column
+}
+
+// isFileHeader reports whether line i starts a ---/+++ file header pair, which is
+// followed by a hunk header; a removed line starting with "-- " is not one
+func isFileHeader(lines []string, i int) bool {
+	return strings.HasPrefix(lines[i], "--- ") && i+2 < len(lines) &&
+		strings.HasPrefix(lines[i+1], "+++ ") && strings.HasPrefix(lines[i+2], "@@")
+}
+
+// words splits a line into runs of letters, digits, and underscores, runs of
+// whitespace, and single other characters
+func words(line string) []string {
+	var result []string
+	for i := 0; i < len(line); {
+		r, size := utf8.DecodeRuneInString(line[i:])
+		end := i + size
+		if class := runeClass(r); class != 0 {
+			for end < len(line) {
+				next, n := utf8.DecodeRuneInString(line[end:])
+				if runeClass(next) != class {
+					break
+				}
+				end += n
+			}
+		}
+		result = append(result, line[i:end])
+		i = end
+	}
+	return result
+}
+
+// runeClass groups the runes that make up one word: 1 for word characters, 2 for
+// whitespace, and 0 for anything else, which stands alone
+func runeClass(r rune) int {
+	switch {
+	case r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		return 1
+	case unicode.IsSpace(r):
+		return 2
+	}
+	return 0
+}
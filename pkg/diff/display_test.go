@@ -0,0 +1,82 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDisplay(t *testing.T) {
+	unified := "--- a/f.go\n+++ b/f.go\n@@ -1,3 +1,3 @@\n \tfunc main() {\n-\tx := compute(a, b)\n+\tx := compute(a, c)\n--- old comment\n \t}\n"
+
+	tests := []struct {
+		name     string
+		opts     DisplayOptions
+		expected string
+	}{
+		{"unchanged", DisplayOptions{}, unified},
+		{"tabs", DisplayOptions{TabWidth: 4}, "--- a/f.go\n+++ b/f.go\n@@ -1,3 +1,3 @@\n     func main() {\n-    x := compute(a, b)\n+    x := compute(a, c)\n--- old comment\n     }\n"},
+		{"word diff", DisplayOptions{WordDiff: true}, "--- a/f.go\n+++ b/f.go\n@@ -1,3 +1,3 @@\n \tfunc main() {\n-\tx := compute(a, [-b-])\n+\tx := compute(a, {+c+})\n--- old comment\n \t}\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Display(unified, tt.opts); got != tt.expected {
+				t.Errorf("Expected:\n%s\ngot:\n%s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestDisplay_Color(t *testing.T) {
+	got := Display("@@ -1 +1 @@\n-one two\n+one three\n", DisplayOptions{WordDiff: true, Color: true})
+
+	expected := ansiCyan + "@@ -1 +1 @@" + ansiReset + "\n" +
+		ansiRed + "-one " + ansiReverse + "two" + ansiNoRev + ansiReset + "\n" +
+		ansiGreen + "+one " + ansiReverse + "three" + ansiNoRev + ansiReset + "\n"
+	if got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestDisplayPair(t *testing.T) {
+	tests := []struct {
+		old, new                 string
+		expectedOld, expectedNew string
+	}{
+		{"total = price*qty", "total = price*quantity", "total = price*[-qty-]", "total = price*{+quantity+}"},
+		{"alpha", "beta", "alpha", "beta"}, // nothing in common, so nothing to point at
+		{"a\tb", "a\tc", "a   [-b-]", "a   {+c+}"},
+	}
+
+	for _, tt := range tests {
+		old, new := DisplayPair(tt.old, tt.new, DisplayOptions{TabWidth: 4, WordDiff: true})
+		if old != tt.expectedOld || new != tt.expectedNew {
+			t.Errorf("DisplayPair(%q, %q): expected %q and %q, got %q and %q", tt.old, tt.new, tt.expectedOld, tt.expectedNew, old, new)
+		}
+	}
+}
+
+func TestExpandTabs(t *testing.T) {
+	tests := map[string]string{
+		"\tx":     "        x",
+		"ab\tx":   "ab      x",
+		"héllo\t": "héllo   ",
+		"no tabs": "no tabs",
+	}
+	for input, expected := range tests {
+		if got := ExpandTabs(input, 8); got != expected {
+			t.Errorf("ExpandTabs(%q): expected %q, got %q", input, expected, got)
+		}
+	}
+	if got := ExpandTabs("\tx", 0); got != "\tx" {
+		t.Errorf("Expected a width of 0 to keep tabs, got %q", got)
+	}
+}
+
+func TestWords(t *testing.T) {
+	got := strings.Join(words("x := f(a_1,  b)"), "|")
+	expected := "x| |:|=| |f|(|a_1|,|  |b|)"
+	if got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
@@ -0,0 +1,313 @@
+package encrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// BeginMarker starts an armored encrypted deltagram
+	BeginMarker = "-----BEGIN DELTAGRAM ENCRYPTED MESSAGE-----"
+	// EndMarker ends an armored encrypted deltagram
+	EndMarker = "-----END DELTAGRAM ENCRYPTED MESSAGE-----"
+
+	identityPrefix  = "DELTAGRAM-SECRET-KEY-"
+	recipientPrefix = "deltagram-pub-"
+
+	magic         = "DGE\x01"
+	keySize       = 32
+	stanzaSize    = keySize + 16 // wrapped file key plus GCM tag
+	lineLength    = 64
+	wrapInfo      = "deltagram file key"
+	maxRecipients = 255
+)
+
+// Identity is an X25519 private key that can decrypt deltagrams sent to its recipient
+type Identity struct {
+	key *ecdh.PrivateKey
+}
+
+// Recipient is an X25519 public key that deltagrams can be encrypted to
+type Recipient struct {
+	key *ecdh.PublicKey
+}
+
+// GenerateIdentity creates a new random identity
+func GenerateIdentity() (*Identity, error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %v", err)
+	}
+	return &Identity{key: key}, nil
+}
+
+// ParseIdentity parses an identity in the form written by Identity.String
+func ParseIdentity(s string) (*Identity, error) {
+	raw, err := decodeKey(strings.TrimSpace(s), identityPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("invalid identity: %v", err)
+	}
+	key, err := ecdh.X25519().NewPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid identity: %v", err)
+	}
+	return &Identity{key: key}, nil
+}
+
+// String encodes the identity for storage in an identity file
+func (i *Identity) String() string {
+	return identityPrefix + base64.RawURLEncoding.EncodeToString(i.key.Bytes())
+}
+
+// Recipient returns the public key matching the identity
+func (i *Identity) Recipient() *Recipient {
+	return &Recipient{key: i.key.PublicKey()}
+}
+
+// ParseRecipient parses a recipient in the form written by Recipient.String
+func ParseRecipient(s string) (*Recipient, error) {
+	raw, err := decodeKey(strings.TrimSpace(s), recipientPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient %q: %v", s, err)
+	}
+	key, err := ecdh.X25519().NewPublicKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient %q: %v", s, err)
+	}
+	return &Recipient{key: key}, nil
+}
+
+// String encodes the recipient for sharing
+func (r *Recipient) String() string {
+	return recipientPrefix + base64.RawURLEncoding.EncodeToString(r.key.Bytes())
+}
+
+func decodeKey(s, prefix string) ([]byte, error) {
+	if !strings.HasPrefix(s, prefix) {
+		return nil, fmt.Errorf("expected %s prefix", prefix)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(s, prefix))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) != keySize {
+		return nil, fmt.Errorf("expected %d byte key, got %d", keySize, len(raw))
+	}
+	return raw, nil
+}
+
+// IdentityFile returns the default location of the user's identity file
+func IdentityFile() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "deltagram", "identity"), nil
+}
+
+// LoadIdentities reads one identity per line from path, skipping blank lines and
+// lines starting with #
+func LoadIdentities(path string) ([]*Identity, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identity file %s: %v", path, err)
+	}
+
+	var identities []*Identity
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		identity, err := ParseIdentity(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		identities = append(identities, identity)
+	}
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("no identities found in %s", path)
+	}
+	return identities, nil
+}
+
+// IsEncrypted reports whether text is an armored encrypted deltagram
+func IsEncrypted(text string) bool {
+	return strings.HasPrefix(strings.TrimSpace(text), BeginMarker)
+}
+
+// Encrypt encrypts plaintext so any of the recipients can decrypt it, returning the
+// armored text. A fresh file key encrypts the payload and is wrapped once per recipient
+// with a key derived from an ephemeral X25519 exchange.
+func Encrypt(plaintext []byte, recipients []*Recipient) (string, error) {
+	if len(recipients) == 0 {
+		return "", fmt.Errorf("at least one recipient is required")
+	}
+	if len(recipients) > maxRecipients {
+		return "", fmt.Errorf("too many recipients: %d (maximum %d)", len(recipients), maxRecipients)
+	}
+
+	fileKey := make([]byte, keySize)
+	if _, err := rand.Read(fileKey); err != nil {
+		return "", fmt.Errorf("failed to generate file key: %v", err)
+	}
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate ephemeral key: %v", err)
+	}
+
+	var header bytes.Buffer
+	header.WriteString(magic)
+	header.Write(ephemeral.PublicKey().Bytes())
+	header.WriteByte(byte(len(recipients)))
+
+	for _, recipient := range recipients {
+		shared, err := ephemeral.ECDH(recipient.key)
+		if err != nil {
+			return "", fmt.Errorf("key exchange failed: %v", err)
+		}
+		aead, err := newGCM(wrapKey(shared, ephemeral.PublicKey().Bytes(), recipient.key.Bytes()))
+		if err != nil {
+			return "", err
+		}
+		// Each wrapping key is used exactly once, so a zero nonce is safe
+		header.Write(aead.Seal(nil, make([]byte, aead.NonceSize()), fileKey, nil))
+	}
+
+	aead, err := newGCM(fileKey)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %v", err)
+	}
+
+	payload := append(header.Bytes(), nonce...)
+	payload = aead.Seal(payload, nonce, plaintext, header.Bytes())
+
+	return armor(payload), nil
+}
+
+// Decrypt decrypts an armored deltagram using whichever identity it was encrypted to
+func Decrypt(text string, identities []*Identity) ([]byte, error) {
+	payload, err := dearmor(text)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(payload) < len(magic)+keySize+1 || string(payload[:len(magic)]) != magic {
+		return nil, fmt.Errorf("unsupported encrypted deltagram format")
+	}
+	ephemeralBytes := payload[len(magic) : len(magic)+keySize]
+	count := int(payload[len(magic)+keySize])
+	stanzasStart := len(magic) + keySize + 1
+	headerEnd := stanzasStart + count*stanzaSize
+	if len(payload) < headerEnd+12 {
+		return nil, fmt.Errorf("encrypted deltagram is truncated")
+	}
+
+	ephemeral, err := ecdh.X25519().NewPublicKey(ephemeralBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ephemeral key: %v", err)
+	}
+
+	fileKey := unwrapFileKey(payload[stanzasStart:headerEnd], ephemeral, identities)
+	if fileKey == nil {
+		return nil, fmt.Errorf("no identity matches any recipient of this deltagram")
+	}
+
+	aead, err := newGCM(fileKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := payload[headerEnd : headerEnd+aead.NonceSize()]
+	plaintext, err := aead.Open(nil, nonce, payload[headerEnd+aead.NonceSize():], payload[:headerEnd])
+	if err != nil {
+		return nil, fmt.Errorf("encrypted deltagram failed authentication: %v", err)
+	}
+	return plaintext, nil
+}
+
+// unwrapFileKey tries every identity against every stanza, returning nil if none match
+func unwrapFileKey(stanzas []byte, ephemeral *ecdh.PublicKey, identities []*Identity) []byte {
+	for _, identity := range identities {
+		shared, err := identity.key.ECDH(ephemeral)
+		if err != nil {
+			continue
+		}
+		aead, err := newGCM(wrapKey(shared, ephemeral.Bytes(), identity.key.PublicKey().Bytes()))
+		if err != nil {
+			continue
+		}
+		for i := 0; i+stanzaSize <= len(stanzas); i += stanzaSize {
+			if fileKey, err := aead.Open(nil, make([]byte, aead.NonceSize()), stanzas[i:i+stanzaSize], nil); err == nil {
+				return fileKey
+			}
+		}
+	}
+	return nil
+}
+
+// wrapKey derives the key wrapping the file key for one recipient using HKDF-SHA256
+// salted with both public keys
+func wrapKey(shared, ephemeral, recipient []byte) []byte {
+	extract := hmac.New(sha256.New, append(append([]byte{}, ephemeral...), recipient...))
+	extract.Write(shared)
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write([]byte(wrapInfo))
+	expand.Write([]byte{1})
+	return expand.Sum(nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %v", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// armor encodes the payload as base64 between marker lines so it survives chat services
+func armor(payload []byte) string {
+	encoded := base64.StdEncoding.EncodeToString(payload)
+
+	var b strings.Builder
+	b.WriteString(BeginMarker + "\n")
+	for len(encoded) > lineLength {
+		b.WriteString(encoded[:lineLength] + "\n")
+		encoded = encoded[lineLength:]
+	}
+	b.WriteString(encoded + "\n")
+	b.WriteString(EndMarker + "\n")
+	return b.String()
+}
+
+// dearmor extracts the payload, tolerating indentation and line ending changes
+func dearmor(text string) ([]byte, error) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, BeginMarker) {
+		return nil, fmt.Errorf("missing %s line", BeginMarker)
+	}
+	end := strings.Index(text, EndMarker)
+	if end < 0 {
+		return nil, fmt.Errorf("missing %s line", EndMarker)
+	}
+
+	body := strings.Join(strings.Fields(text[len(BeginMarker):end]), "")
+	payload, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encrypted deltagram encoding: %v", err)
+	}
+	return payload, nil
+}
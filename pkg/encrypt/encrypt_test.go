@@ -0,0 +1,136 @@
+package encrypt
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryptDecrypt_RoundTrip(t *testing.T) {
+	alice, err := GenerateIdentity()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	bob, err := GenerateIdentity()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	plaintext := "--====DELTAGRAM_0123456789abcdef====\nsecret patch\n"
+	armored, err := Encrypt([]byte(plaintext), []*Recipient{alice.Recipient(), bob.Recipient()})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if !IsEncrypted(armored) {
+		t.Fatalf("Expected armored output to be detected as encrypted")
+	}
+	if strings.Contains(armored, "secret patch") {
+		t.Errorf("Expected plaintext not to appear in armored output")
+	}
+
+	for _, identity := range []*Identity{alice, bob} {
+		decrypted, err := Decrypt(armored, []*Identity{identity})
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if string(decrypted) != plaintext {
+			t.Errorf("Expected %q, got %q", plaintext, decrypted)
+		}
+	}
+}
+
+func TestDecrypt_WrongIdentity(t *testing.T) {
+	alice, _ := GenerateIdentity()
+	mallory, _ := GenerateIdentity()
+
+	armored, err := Encrypt([]byte("hello"), []*Recipient{alice.Recipient()})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if _, err := Decrypt(armored, []*Identity{mallory}); err == nil {
+		t.Errorf("Expected error decrypting with the wrong identity")
+	}
+}
+
+func TestDecrypt_Tampered(t *testing.T) {
+	alice, _ := GenerateIdentity()
+	armored, err := Encrypt([]byte("hello world, this is a patch"), []*Recipient{alice.Recipient()})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	lines := strings.Split(armored, "\n")
+	body := []byte(lines[1])
+	if body[10] == 'A' {
+		body[10] = 'B'
+	} else {
+		body[10] = 'A'
+	}
+	lines[1] = string(body)
+
+	if _, err := Decrypt(strings.Join(lines, "\n"), []*Identity{alice}); err == nil {
+		t.Errorf("Expected error decrypting tampered payload")
+	}
+}
+
+func TestDecrypt_ReflowedArmor(t *testing.T) {
+	alice, _ := GenerateIdentity()
+	armored, err := Encrypt([]byte("hello"), []*Recipient{alice.Recipient()})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// Chat services may indent lines or switch line endings
+	reflowed := "  " + strings.ReplaceAll(armored, "\n", "\r\n    ")
+	decrypted, err := Decrypt(reflowed, []*Identity{alice})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if string(decrypted) != "hello" {
+		t.Errorf("Expected %q, got %q", "hello", decrypted)
+	}
+}
+
+func TestParseKeys_RoundTrip(t *testing.T) {
+	identity, _ := GenerateIdentity()
+
+	parsed, err := ParseIdentity(identity.String())
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if parsed.Recipient().String() != identity.Recipient().String() {
+		t.Errorf("Expected parsed identity to have the same recipient")
+	}
+
+	recipient, err := ParseRecipient(identity.Recipient().String())
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if recipient.String() != identity.Recipient().String() {
+		t.Errorf("Expected %q, got %q", identity.Recipient().String(), recipient.String())
+	}
+
+	if _, err := ParseRecipient(identity.String()); err == nil {
+		t.Errorf("Expected error parsing an identity as a recipient")
+	}
+}
+
+func TestLoadIdentities(t *testing.T) {
+	identity, _ := GenerateIdentity()
+	path := filepath.Join(t.TempDir(), "identity")
+	content := "# created by deltagram keygen\n# public key: " + identity.Recipient().String() + "\n" + identity.String() + "\n"
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	identities, err := LoadIdentities(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(identities) != 1 || identities[0].String() != identity.String() {
+		t.Errorf("Expected the identity to be loaded, got %v", identities)
+	}
+}
@@ -0,0 +1,121 @@
+package gitignore
+
+import (
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/pathglob"
+)
+
+// rule is a single compiled .gitignore pattern
+type rule struct {
+	base    string // Directory containing the .gitignore, relative to the root ("" for root)
+	negate  bool
+	dirOnly bool
+	regex   *regexp.Regexp
+}
+
+// Matcher evaluates paths against .gitignore rules collected from one or more files
+type Matcher struct {
+	rules []rule
+}
+
+// NewMatcher creates an empty matcher that ignores nothing
+func NewMatcher() *Matcher {
+	return &Matcher{}
+}
+
+// AddPatterns adds the patterns from a .gitignore file located in dir (slash-separated,
+// relative to the root; use "" for the root). Later patterns take precedence.
+func (m *Matcher) AddPatterns(dir, content string) {
+	dir = strings.Trim(path.Clean("/"+dir), "/")
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// Unescaped trailing spaces are not significant
+		for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+			line = strings.TrimSuffix(line, " ")
+		}
+
+		r := rule{base: dir}
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+			line = line[1:]
+		}
+
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		// Patterns containing a slash are anchored to the .gitignore directory
+		anchored := strings.Contains(line, "/")
+		line = strings.TrimPrefix(line, "/")
+
+		expr := pathglob.ToRegex(line)
+		if anchored {
+			expr = "^" + expr + "$"
+		} else {
+			expr = "^(?:.*/)?" + expr + "$"
+		}
+
+		regex, err := regexp.Compile(expr)
+		if err != nil {
+			continue // Malformed patterns are skipped, as git does
+		}
+		r.regex = regex
+
+		m.rules = append(m.rules, r)
+	}
+}
+
+// Match reports whether the slash-separated relative path is ignored. A path is also
+// ignored when any of its parent directories is ignored.
+func (m *Matcher) Match(relPath string, isDir bool) bool {
+	relPath = strings.Trim(path.Clean("/"+relPath), "/")
+	if relPath == "" {
+		return false
+	}
+
+	segments := strings.Split(relPath, "/")
+	for i := 1; i < len(segments); i++ {
+		if m.matchOne(strings.Join(segments[:i], "/"), true) {
+			return true
+		}
+	}
+
+	return m.matchOne(relPath, isDir)
+}
+
+// matchOne evaluates a single path without considering its parents
+func (m *Matcher) matchOne(relPath string, isDir bool) bool {
+	ignored := false
+	for _, r := range m.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+
+		candidate := relPath
+		if r.base != "" {
+			if !strings.HasPrefix(relPath, r.base+"/") {
+				continue
+			}
+			candidate = strings.TrimPrefix(relPath, r.base+"/")
+		}
+
+		if r.regex.MatchString(candidate) {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,40 @@
+package gitignore
+
+import "testing"
+
+func TestMatcher_Match(t *testing.T) {
+	m := NewMatcher()
+	m.AddPatterns("", `# build output
+/bin
+*.log
+!keep.log
+build/
+docs/**/*.tmp
+`)
+	m.AddPatterns("web", "dist\n")
+
+	tests := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{path: "bin/deltagram", ignored: true},
+		{path: "src/bin/tool", ignored: false},
+		{path: "debug.log", ignored: true},
+		{path: "logs/app.log", ignored: true},
+		{path: "keep.log", ignored: false},
+		{path: "build/out.txt", ignored: true},
+		{path: "build", isDir: false, ignored: false},
+		{path: "docs/a/b/c.tmp", ignored: true},
+		{path: "docs/c.tmp", ignored: true},
+		{path: "web/dist/index.html", ignored: true},
+		{path: "dist/index.html", ignored: false},
+		{path: "src/main.go", ignored: false},
+	}
+
+	for _, test := range tests {
+		if got := m.Match(test.path, test.isDir); got != test.ignored {
+			t.Errorf("Match(%q, %v) = %v, expected %v", test.path, test.isDir, got, test.ignored)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+// Package kube treats the keys of a Kubernetes ConfigMap or Secret as files, so that a
+// deltagram can patch configuration stored in a cluster. It uses kubectl, with the
+// user's kubeconfig, to read and write the object.
+package kube
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/developingjames/deltagrams/pkg/operations"
+)
+
+// Root is the base directory under which a FileSystem exposes the object's keys
+var Root = operations.ArchiveRoot
+
+// Object kinds
+const (
+	ConfigMap = "configmap"
+	Secret    = "secret"
+)
+
+// validKey matches the keys Kubernetes allows in a ConfigMap or Secret
+var validKey = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+
+// Object names a ConfigMap or Secret
+type Object struct {
+	Kind      string // ConfigMap or Secret
+	Namespace string // Empty for the context's namespace
+	Name      string
+	Context   string // kubeconfig context; empty for the current one
+}
+
+// ParseObject parses a reference of the form namespace/name or name
+func ParseObject(kind, ref, kubeContext string) (Object, error) {
+	obj := Object{Kind: kind, Name: ref, Context: kubeContext}
+	if namespace, name, ok := strings.Cut(ref, "/"); ok {
+		obj.Namespace, obj.Name = namespace, name
+	}
+	if obj.Name == "" || strings.Contains(obj.Name, "/") {
+		return Object{}, fmt.Errorf("invalid %s %q: use namespace/name or name", kind, ref)
+	}
+	return obj, nil
+}
+
+func (o Object) String() string {
+	if o.Namespace == "" {
+		return o.Kind + " " + o.Name
+	}
+	return o.Kind + " " + o.Namespace + "/" + o.Name
+}
+
+// runFunc runs kubectl with args and stdin, returning its stdout
+type runFunc func(ctx context.Context, args []string, stdin []byte) ([]byte, error)
+
+// FileSystem holds the keys of a ConfigMap or Secret as files under Root
+type FileSystem struct {
+	*operations.MemoryFileSystem
+	obj    Object
+	raw    map[string]interface{} // The object as read, written back with updated data
+	binary map[string]bool        // ConfigMap keys stored under binaryData
+	run    runFunc
+}
+
+// Open reads the object with kubectl get
+func Open(ctx context.Context, obj Object) (*FileSystem, error) {
+	return open(ctx, obj, runKubectl)
+}
+
+func open(ctx context.Context, obj Object, run runFunc) (*FileSystem, error) {
+	out, err := run(ctx, obj.args("get", obj.Kind, obj.Name, "-o", "json"), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", obj, err)
+	}
+
+	f := &FileSystem{MemoryFileSystem: operations.NewMemoryFileSystem(), obj: obj, binary: make(map[string]bool), run: run}
+	if err := json.Unmarshal(out, &f.raw); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", obj, err)
+	}
+
+	files := make(map[string][]byte)
+	for key, value := range stringMap(f.raw["data"]) {
+		if obj.Kind == Secret {
+			decoded, err := base64.StdEncoding.DecodeString(value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode key %s of %s: %v", key, obj, err)
+			}
+			files[key] = decoded
+		} else {
+			files[key] = []byte(value)
+		}
+	}
+	for key, value := range stringMap(f.raw["binaryData"]) {
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode key %s of %s: %v", key, obj, err)
+		}
+		files[key] = decoded
+		f.binary[key] = true
+	}
+
+	for key, data := range files {
+		if err := f.WriteFile(filepath.Join(Root, key), data, 0644); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Save writes the changed keys back with kubectl replace. The object keeps the
+// resourceVersion it was read with, so the write is refused if the object was changed
+// in the meantime.
+func (f *FileSystem) Save(ctx context.Context, changes []operations.FileChange) error {
+	data := stringMap(f.raw["data"])
+	binaryData := stringMap(f.raw["binaryData"])
+	for _, change := range changes {
+		key, err := filepath.Rel(Root, change.Path)
+		if err != nil || !validKey.MatchString(key) {
+			return fmt.Errorf("cannot write %s to %s: keys cannot contain directories and may only use letters, digits, '-', '_', and '.'", change.Path, f.obj)
+		}
+
+		delete(data, key)
+		delete(binaryData, key)
+		if !change.Exists {
+			continue
+		}
+		switch {
+		case f.obj.Kind == Secret:
+			data[key] = base64.StdEncoding.EncodeToString(change.After)
+		case f.binary[key] || !utf8.Valid(change.After):
+			binaryData[key] = base64.StdEncoding.EncodeToString(change.After)
+		default:
+			data[key] = string(change.After)
+		}
+	}
+
+	setOrDelete(f.raw, "data", data)
+	setOrDelete(f.raw, "binaryData", binaryData)
+	body, err := json.Marshal(f.raw)
+	if err != nil {
+		return err
+	}
+	if _, err := f.run(ctx, f.obj.args("replace", "-f", "-"), body); err != nil {
+		return fmt.Errorf("failed to update %s: %v", f.obj, err)
+	}
+	return nil
+}
+
+// args returns kubectl arguments selecting the object's context and namespace
+func (o Object) args(args ...string) []string {
+	var prefix []string
+	if o.Context != "" {
+		prefix = append(prefix, "--context", o.Context)
+	}
+	if o.Namespace != "" {
+		prefix = append(prefix, "--namespace", o.Namespace)
+	}
+	return append(prefix, args...)
+}
+
+// stringMap returns a copy of a JSON object whose values are strings
+func stringMap(value interface{}) map[string]string {
+	result := make(map[string]string)
+	object, _ := value.(map[string]interface{})
+	for key, v := range object {
+		if s, ok := v.(string); ok {
+			result[key] = s
+		}
+	}
+	return result
+}
+
+// setOrDelete sets raw[field] to values, or removes the field when values is empty
+func setOrDelete(raw map[string]interface{}, field string, values map[string]string) {
+	if len(values) == 0 {
+		delete(raw, field)
+		return
+	}
+	raw[field] = values
+}
+
+func runKubectl(ctx context.Context, args []string, stdin []byte) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "kubectl", args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("kubectl: %v: %s", err, message)
+		}
+		return nil, fmt.Errorf("kubectl: %v", err)
+	}
+	return stdout.Bytes(), nil
+}
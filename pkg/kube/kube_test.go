@@ -0,0 +1,125 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/developingjames/deltagrams/pkg/operations"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// fakeKubectl serves object for get and records the body given to replace
+type fakeKubectl struct {
+	object   string
+	args     [][]string
+	replaced map[string]interface{}
+}
+
+func (k *fakeKubectl) run(ctx context.Context, args []string, stdin []byte) ([]byte, error) {
+	k.args = append(k.args, args)
+	if strings.Contains(strings.Join(args, " "), "replace") {
+		return nil, json.Unmarshal(stdin, &k.replaced)
+	}
+	return []byte(k.object), nil
+}
+
+func TestFileSystem_ConfigMap(t *testing.T) {
+	kubectl := &fakeKubectl{object: `{"apiVersion": "v1", "kind": "ConfigMap",
+		"metadata": {"name": "app", "namespace": "prod", "resourceVersion": "42"},
+		"data": {"app.properties": "debug=false\nport=80", "old.conf": "x"},
+		"binaryData": {"logo.bin": "AAEC"}}`}
+	obj, err := ParseObject(ConfigMap, "prod/app", "staging")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	fs, err := open(context.Background(), obj, kubectl.run)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if got := strings.Join(kubectl.args[0], " "); got != "--context staging --namespace prod get configmap app -o json" {
+		t.Errorf("Unexpected kubectl arguments: %s", got)
+	}
+
+	deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{
+		{ContentLocation: "app.properties", ContentType: "text/plain", DeltaOperation: "content", Content: "@@ -1,2 +1,2 @@\n-debug=false\n+debug=true\n port=80"},
+		{ContentLocation: "extra.conf", ContentType: "text/plain", DeltaOperation: "create", Content: "+++ extra.conf\nnew"},
+		{ContentLocation: "old.conf", ContentType: "text/plain", DeltaOperation: "delete"},
+	}}
+	recorder := operations.NewRecordingFileSystem(fs)
+	if err := operations.NewApplier(recorder).Apply(deltagram, Root); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := fs.Save(context.Background(), recorder.Changes()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	data, _ := kubectl.replaced["data"].(map[string]interface{})
+	expected := map[string]string{"app.properties": "debug=true\nport=80", "extra.conf": "new"}
+	if len(data) != len(expected) {
+		t.Errorf("Expected data %v, got %v", expected, data)
+	}
+	for key, value := range expected {
+		if data[key] != value {
+			t.Errorf("Expected %s to be %q, got %q", key, value, data[key])
+		}
+	}
+	if binary, _ := kubectl.replaced["binaryData"].(map[string]interface{}); binary["logo.bin"] != "AAEC" {
+		t.Errorf("Expected binaryData to be kept, got %v", kubectl.replaced["binaryData"])
+	}
+	if metadata, _ := kubectl.replaced["metadata"].(map[string]interface{}); metadata["resourceVersion"] != "42" {
+		t.Errorf("Expected the resourceVersion to be kept, got %v", kubectl.replaced["metadata"])
+	}
+}
+
+func TestFileSystem_Secret(t *testing.T) {
+	kubectl := &fakeKubectl{object: `{"kind": "Secret", "metadata": {"name": "creds"}, "data": {"password": "aHVudGVyMg=="}}`}
+	fs, err := open(context.Background(), Object{Kind: Secret, Name: "creds"}, kubectl.run)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if data, _ := fs.ReadFile(Root + "password"); string(data) != "hunter2" {
+		t.Errorf("Expected the decoded secret, got %q", data)
+	}
+
+	change := operations.FileChange{Path: Root + "password", After: []byte("hunter3"), Existed: true, Exists: true}
+	if err := fs.Save(context.Background(), []operations.FileChange{change}); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if data, _ := kubectl.replaced["data"].(map[string]interface{}); data["password"] != "aHVudGVyMw==" {
+		t.Errorf("Expected the secret to be re-encoded, got %v", kubectl.replaced["data"])
+	}
+
+	nested := operations.FileChange{Path: Root + "dir/key", After: []byte("x"), Exists: true}
+	if err := fs.Save(context.Background(), []operations.FileChange{nested}); err == nil || !strings.Contains(err.Error(), "keys cannot contain directories") {
+		t.Errorf("Expected a key error, got: %v", err)
+	}
+}
+
+func TestParseObject(t *testing.T) {
+	tests := []struct {
+		ref       string
+		namespace string
+		name      string
+		wantErr   bool
+	}{
+		{"prod/app", "prod", "app", false},
+		{"app", "", "app", false},
+		{"prod/", "", "", true},
+		{"a/b/c", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			obj, err := ParseObject(ConfigMap, tt.ref, "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error=%v, got: %v", tt.wantErr, err)
+			}
+			if obj.Namespace != tt.namespace || obj.Name != tt.name {
+				t.Errorf("Expected %s/%s, got %s/%s", tt.namespace, tt.name, obj.Namespace, obj.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+// Package lock keeps two deltagram applies from writing to the same directory at once.
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// File is the lock file, relative to the directory being applied to
+const File = ".deltagram/lock"
+
+// StaleAfter is how old a lock may get before it is considered abandoned even when its
+// owner cannot be checked, for example because it was taken on another host
+var StaleAfter = time.Hour
+
+// ErrLocked is returned, wrapped, when another process holds the lock
+var ErrLocked = errors.New("directory is locked")
+
+// Info identifies the holder of a lock
+type Info struct {
+	PID     int       `json:"pid"`
+	Host    string    `json:"host"`
+	Created time.Time `json:"created"`
+}
+
+// Lock is a held lock on a directory
+type Lock struct {
+	path string
+}
+
+// Acquire takes the lock for dir. A lock left behind by a process that is no longer
+// running on this host, or one older than StaleAfter, is taken over.
+func Acquire(dir string) (*Lock, error) {
+	path := filepath.Join(dir, filepath.FromSlash(File))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %v", err)
+	}
+
+	host, _ := os.Hostname()
+	info := Info{PID: os.Getpid(), Host: host, Created: time.Now().UTC()}
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+
+	// A second attempt follows the removal of a stale lock
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, writeErr := file.Write(data)
+			closeErr := file.Close()
+			if writeErr != nil || closeErr != nil {
+				os.Remove(path)
+				return nil, fmt.Errorf("failed to write lock file: %v", errors.Join(writeErr, closeErr))
+			}
+			return &Lock{path: path}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock file: %v", err)
+		}
+
+		holder, stale := inspect(path, host)
+		if !stale {
+			return nil, fmt.Errorf("%w: %s is held by %s", ErrLocked, File, holder)
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove stale lock file: %v", err)
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s was taken by another process", ErrLocked, File)
+}
+
+// Release removes the lock file, and its directory if nothing else is in it
+func (l *Lock) Release() error {
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove lock file: %v", err)
+	}
+	os.Remove(filepath.Dir(l.path))
+	return nil
+}
+
+// inspect describes the holder of an existing lock and reports whether it is stale
+func inspect(path, host string) (string, bool) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		// Released between our attempt and now
+		return "another process", true
+	}
+
+	data, err := os.ReadFile(path)
+	var info Info
+	if err != nil || json.Unmarshal(data, &info) != nil {
+		// Possibly still being written; only its age can tell
+		return "another process", time.Since(stat.ModTime()) > StaleAfter
+	}
+
+	holder := fmt.Sprintf("pid %d on %s since %s", info.PID, info.Host, info.Created.Local().Format(time.RFC3339))
+	if info.Host == host && !processAlive(info.PID) {
+		return holder, true
+	}
+	return holder, time.Since(info.Created) > StaleAfter
+}
+
+// processAlive reports whether a process with the given pid is running on this host
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	if runtime.GOOS == "windows" {
+		// FindProcess opens the process on Windows, so success means it exists
+		process.Release()
+		return true
+	}
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, os.ErrPermission)
+}
@@ -0,0 +1,85 @@
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeLock(t *testing.T, dir string, info Info) {
+	t.Helper()
+	data, err := json.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, File)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAcquire_Release(t *testing.T) {
+	dir := t.TempDir()
+
+	lock, err := Acquire(dir)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if _, err := Acquire(dir); !errors.Is(err, ErrLocked) {
+		t.Errorf("Expected second acquire to fail with ErrLocked, got: %v", err)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Dir(filepath.Join(dir, File))); !os.IsNotExist(err) {
+		t.Errorf("Expected lock file and its empty directory to be removed")
+	}
+
+	lock, err = Acquire(dir)
+	if err != nil {
+		t.Fatalf("Expected acquire after release to succeed, got: %v", err)
+	}
+	lock.Release()
+}
+
+func TestAcquire_Stale(t *testing.T) {
+	host, _ := os.Hostname()
+
+	tests := []struct {
+		name  string
+		info  Info
+		stale bool
+	}{
+		{"live process on this host", Info{PID: os.Getpid(), Host: host, Created: time.Now()}, false},
+		{"dead process on this host", Info{PID: 1 << 30, Host: host, Created: time.Now()}, true},
+		{"recent lock on another host", Info{PID: 1, Host: "elsewhere.invalid", Created: time.Now()}, false},
+		{"old lock on another host", Info{PID: 1, Host: "elsewhere.invalid", Created: time.Now().Add(-2 * StaleAfter)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeLock(t, dir, tt.info)
+
+			lock, err := Acquire(dir)
+			if tt.stale {
+				if err != nil {
+					t.Fatalf("Expected stale lock to be taken over, got: %v", err)
+				}
+				lock.Release()
+				return
+			}
+			if !errors.Is(err, ErrLocked) {
+				t.Errorf("Expected ErrLocked, got: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+// Package mailbox finds deltagrams in email messages, read from .eml files or mbox
+// mailboxes, for a patches-by-email workflow
+package mailbox
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Message is one email and the deltagram found in it
+type Message struct {
+	Subject   string
+	From      string
+	Date      time.Time // Zero if the message has no valid Date header
+	Deltagram string    // Empty if the message contains no deltagram
+}
+
+// openBoundary matches an opening deltagram boundary, possibly quoted with "> " as in a
+// reply, capturing the quote prefix and the identifier
+var openBoundary = regexp.MustCompile(`^((?:> ?)*)--====DELTAGRAM_([a-zA-Z0-9_-]+)====\s*$`)
+
+// fromLine matches an mboxrd-escaped "From " line in a message body
+var fromLine = regexp.MustCompile(`^>+From `)
+
+// Read reads the messages in r, which holds either a single message as in an .eml file
+// or an mbox mailbox of messages each starting with a "From " line
+func Read(r io.Reader) ([]Message, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+
+	var raw [][]byte
+	if bytes.HasPrefix(data, []byte("From ")) {
+		raw = splitMbox(data)
+	} else {
+		raw = [][]byte{data}
+	}
+
+	messages := make([]Message, 0, len(raw))
+	for i, text := range raw {
+		message, err := parseMessage(text)
+		if err != nil {
+			return nil, fmt.Errorf("message %d: %v", i+1, err)
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// splitMbox divides an mbox into messages at "From " lines, dropping those lines and
+// undoing the mboxrd escaping of "From " lines within bodies
+func splitMbox(data []byte) [][]byte {
+	var messages [][]byte
+	var current []byte
+	started := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), len(data)+1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "From ") {
+			if started {
+				messages = append(messages, current)
+			}
+			started = true
+			current = nil
+			continue
+		}
+		if fromLine.MatchString(line) {
+			line = line[1:]
+		}
+		current = append(current, line...)
+		current = append(current, '\n')
+	}
+	if started {
+		messages = append(messages, current)
+	}
+	return messages
+}
+
+// parseMessage reads the headers of one message and looks through its body for a
+// deltagram
+func parseMessage(text []byte) (Message, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(text))
+	if err != nil {
+		return Message{}, err
+	}
+
+	dec := new(mime.WordDecoder)
+	subject, err := dec.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		subject = msg.Header.Get("Subject")
+	}
+	from, err := dec.DecodeHeader(msg.Header.Get("From"))
+	if err != nil {
+		from = msg.Header.Get("From")
+	}
+	message := Message{Subject: subject, From: from}
+	if date, err := msg.Header.Date(); err == nil {
+		message.Date = date
+	}
+
+	message.Deltagram, err = findInPart(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	return message, err
+}
+
+// findInPart decodes a message body or MIME part and returns the first deltagram in it,
+// descending into multipart bodies
+func findInPart(contentType, encoding string, body io.Reader) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		// A missing or malformed Content-Type is plain text
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return "", nil
+			}
+			if err != nil {
+				return "", fmt.Errorf("failed to read MIME part: %v", err)
+			}
+			found, err := findInPart(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if err != nil || found != "" {
+				return found, err
+			}
+		}
+	}
+
+	if !strings.HasPrefix(mediaType, "text/") && mediaType != "application/octet-stream" {
+		return "", nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode %s body: %v", encoding, err)
+	}
+	return Find(string(content)), nil
+}
+
+// Find returns the first deltagram in text, from its opening boundary to its final
+// boundary, or an empty string if there is none. A deltagram quoted with "> " in a reply
+// is unquoted. Without a final boundary, everything to the end of the text is returned.
+func Find(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		matches := openBoundary.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+		prefix, final := matches[1], "--====DELTAGRAM_"+matches[2]+"====--"
+
+		var b strings.Builder
+		for _, line := range lines[i:] {
+			line = unquote(line, prefix)
+			b.WriteString(line)
+			b.WriteByte('\n')
+			if strings.TrimRight(line, " \t") == final {
+				break
+			}
+		}
+		return b.String()
+	}
+	return ""
+}
+
+// unquote removes prefix from line. Mail clients drop the trailing space of the quote
+// marker on blank lines, so a line that is only the marker becomes blank.
+func unquote(line, prefix string) string {
+	if prefix == "" {
+		return line
+	}
+	if rest, ok := strings.CutPrefix(line, prefix); ok {
+		return rest
+	}
+	if strings.TrimRight(line, " ") == strings.TrimRight(prefix, " ") {
+		return ""
+	}
+	return line
+}
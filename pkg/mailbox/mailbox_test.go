@@ -0,0 +1,106 @@
+package mailbox
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+const deltagram = "--====DELTAGRAM_0123456789abcdef====\n" +
+	"Content-Location: hello.txt\n" +
+	"Content-Type: text/plain\n" +
+	"Delta-Operation: create\n" +
+	"\n" +
+	"+++ hello.txt\n" +
+	"hello\n" +
+	"--====DELTAGRAM_0123456789abcdef====--\n"
+
+func quote(text string) string {
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	for i, line := range lines {
+		if line == "" {
+			lines[i] = ">"
+		} else {
+			lines[i] = "> " + line
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func TestRead(t *testing.T) {
+	headers := "From: Ada <ada@example.com>\nSubject: Add greeting\nDate: Mon, 02 Jan 2006 15:04:05 +0000\n"
+
+	tests := []struct {
+		name      string
+		input     string
+		subjects  []string
+		deltagram []string
+	}{
+		{
+			name:      "plain eml",
+			input:     headers + "\nPlease apply:\n\n" + deltagram + "\nThanks\n",
+			subjects:  []string{"Add greeting"},
+			deltagram: []string{deltagram},
+		},
+		{
+			name:      "crlf line endings",
+			input:     strings.ReplaceAll(headers+"\n"+deltagram, "\n", "\r\n"),
+			subjects:  []string{"Add greeting"},
+			deltagram: []string{deltagram},
+		},
+		{
+			name:      "quoted in a reply",
+			input:     headers + "\nOn Monday Ada wrote:\n" + quote(deltagram),
+			subjects:  []string{"Add greeting"},
+			deltagram: []string{deltagram},
+		},
+		{
+			name: "base64 attachment",
+			input: headers + "MIME-Version: 1.0\nContent-Type: multipart/mixed; boundary=XYZ\n\n" +
+				"--XYZ\nContent-Type: text/plain\n\nSee attached.\n" +
+				"--XYZ\nContent-Type: application/octet-stream; name=change.deltagram\nContent-Transfer-Encoding: base64\n\n" +
+				base64.StdEncoding.EncodeToString([]byte(deltagram)) + "\n--XYZ--\n",
+			subjects:  []string{"Add greeting"},
+			deltagram: []string{deltagram},
+		},
+		{
+			name:      "quoted-printable body",
+			input:     headers + "Content-Type: text/plain; charset=utf-8\nContent-Transfer-Encoding: quoted-printable\n\n" + strings.ReplaceAll(deltagram, "=", "=3D"),
+			subjects:  []string{"Add greeting"},
+			deltagram: []string{deltagram},
+		},
+		{
+			name: "mbox",
+			input: "From ada@example.com Mon Jan  2 15:04:05 2006\n" + headers + "\n" + deltagram + "\n" +
+				"From bob@example.com Mon Jan  2 16:00:00 2006\nFrom: Bob <bob@example.com>\nSubject: =?utf-8?q?Re=3A_greeting?=\n\n>From the team: no deltagram here\n",
+			subjects:  []string{"Add greeting", "Re: greeting"},
+			deltagram: []string{deltagram, ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages, err := Read(strings.NewReader(tt.input))
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if len(messages) != len(tt.subjects) {
+				t.Fatalf("Expected %d messages, got %d", len(tt.subjects), len(messages))
+			}
+			for i, message := range messages {
+				if message.Subject != tt.subjects[i] {
+					t.Errorf("Message %d: expected subject %q, got %q", i, tt.subjects[i], message.Subject)
+				}
+				if message.Deltagram != tt.deltagram[i] {
+					t.Errorf("Message %d: expected deltagram %q, got %q", i, tt.deltagram[i], message.Deltagram)
+				}
+			}
+		})
+	}
+}
+
+func TestFind_NoDeltagram(t *testing.T) {
+	if found := Find("Just a note\n-- \nAda\n"); found != "" {
+		t.Errorf("Expected no deltagram, got %q", found)
+	}
+}
@@ -0,0 +1,252 @@
+// Package objectstore lets deltagrams patch objects in a bucket, such as a static site or
+// a data set, as if the objects under a prefix were a directory of files
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/developingjames/deltagrams/pkg/operations"
+)
+
+// Root is the base directory under which a FileSystem exposes the objects under its prefix
+var Root = operations.ArchiveRoot
+
+// ErrPreconditionFailed is returned, wrapped, when an object was changed by someone else
+// after it was read
+var ErrPreconditionFailed = errors.New("object was changed concurrently")
+
+// Object describes a stored object
+type Object struct {
+	Key         string
+	ETag        string
+	Size        int64
+	ContentType string // Known only once the object has been fetched
+}
+
+// Store is the small part of an object storage API that a FileSystem needs
+type Store interface {
+	// List returns every object whose key starts with prefix
+	List(ctx context.Context, prefix string) ([]Object, error)
+	// Get returns the content of an object, failing with an error that wraps
+	// os.ErrNotExist if it does not exist
+	Get(ctx context.Context, key string) ([]byte, Object, error)
+	// Put stores an object if its current ETag is ifMatch, or, with an empty ifMatch, only
+	// if it does not exist; otherwise it fails with ErrPreconditionFailed
+	Put(ctx context.Context, key string, data []byte, contentType, ifMatch string) error
+	// Delete removes an object if its current ETag is ifMatch
+	Delete(ctx context.Context, key, ifMatch string) error
+}
+
+// FileSystem is an in-memory view of the objects under a prefix. Every object is listed
+// when it is opened, but its content is fetched only when it is first read, so that
+// patching a few files does not download the whole bucket. Save writes the changes back
+// with ETag preconditions.
+type FileSystem struct {
+	mem     *operations.MemoryFileSystem
+	ctx     context.Context
+	store   Store
+	prefix  string
+	objects map[string]Object // By path under Root, as listed or fetched
+	pending map[string]bool   // Paths whose content has not been fetched
+}
+
+// Open lists the objects under prefix. A non-empty prefix is treated as a directory.
+func Open(ctx context.Context, store Store, prefix string) (*FileSystem, error) {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	listed, err := store.List(ctx, prefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %v", err)
+	}
+
+	f := &FileSystem{
+		mem:     operations.NewMemoryFileSystem(),
+		ctx:     ctx,
+		store:   store,
+		prefix:  prefix,
+		objects: make(map[string]Object),
+		pending: make(map[string]bool),
+	}
+	for _, object := range listed {
+		rel := strings.TrimPrefix(object.Key, prefix)
+		if rel == "" || strings.HasSuffix(rel, "/") {
+			continue // Folder placeholder objects
+		}
+		name := filepath.Join(Root, filepath.FromSlash(path.Clean(rel)))
+		if err := f.mem.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			return nil, fmt.Errorf("cannot represent object %s as a file: %v", object.Key, err)
+		}
+		// An empty placeholder makes the object exist until its content is fetched
+		if err := f.mem.WriteFile(name, nil, 0644); err != nil {
+			return nil, fmt.Errorf("cannot represent object %s as a file: %v", object.Key, err)
+		}
+		f.objects[name] = object
+		f.pending[name] = true
+	}
+	return f, nil
+}
+
+// fetch loads the content of name if it has not been fetched yet
+func (f *FileSystem) fetch(name string) error {
+	name = filepath.Clean(name)
+	if !f.pending[name] {
+		return nil
+	}
+	data, object, err := f.store.Get(f.ctx, f.objects[name].Key)
+	if err != nil {
+		return fmt.Errorf("failed to fetch %s: %w", f.objects[name].Key, err)
+	}
+	if err := f.mem.WriteFile(name, data, 0644); err != nil {
+		return err
+	}
+	f.objects[name] = object
+	delete(f.pending, name)
+	return nil
+}
+
+// written records that name was replaced without needing its old content
+func (f *FileSystem) written(name string) {
+	delete(f.pending, filepath.Clean(name))
+}
+
+func (f *FileSystem) ReadFile(filename string) ([]byte, error) {
+	if err := f.fetch(filename); err != nil {
+		return nil, err
+	}
+	return f.mem.ReadFile(filename)
+}
+
+func (f *FileSystem) WriteFile(filename string, data []byte, perm os.FileMode) error {
+	f.written(filename)
+	return f.mem.WriteFile(filename, data, perm)
+}
+
+func (f *FileSystem) Remove(name string) error {
+	f.written(name)
+	return f.mem.Remove(name)
+}
+
+func (f *FileSystem) Rename(oldpath, newpath string) error {
+	if err := f.fetch(oldpath); err != nil {
+		return err
+	}
+	f.written(newpath)
+	return f.mem.Rename(oldpath, newpath)
+}
+
+func (f *FileSystem) MkdirAll(path string, perm os.FileMode) error {
+	return f.mem.MkdirAll(path, perm)
+}
+
+func (f *FileSystem) Stat(name string) (os.FileInfo, error) {
+	if err := f.fetch(name); err != nil {
+		return nil, err
+	}
+	return f.mem.Stat(name)
+}
+
+func (f *FileSystem) Lstat(name string) (os.FileInfo, error) {
+	if err := f.fetch(name); err != nil {
+		return nil, err
+	}
+	return f.mem.Lstat(name)
+}
+
+// ReadDir fetches the objects directly in the directory, so that their sizes are right
+func (f *FileSystem) ReadDir(name string) ([]os.DirEntry, error) {
+	entries, err := f.mem.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if err := f.fetch(filepath.Join(name, entry.Name())); err != nil {
+			return nil, err
+		}
+	}
+	return f.mem.ReadDir(name)
+}
+
+func (f *FileSystem) Open(name string) (io.ReadCloser, error) {
+	if err := f.fetch(name); err != nil {
+		return nil, err
+	}
+	return f.mem.Open(name)
+}
+
+func (f *FileSystem) Create(name string) (io.WriteCloser, error) {
+	f.written(name)
+	return f.mem.Create(name)
+}
+
+func (f *FileSystem) Chtimes(name string, atime, mtime time.Time) error {
+	return f.mem.Chtimes(name, atime, mtime)
+}
+
+func (f *FileSystem) Chmod(name string, mode os.FileMode) error {
+	return f.mem.Chmod(name, mode)
+}
+
+func (f *FileSystem) Truncate(name string, size int64) error {
+	if err := f.fetch(name); err != nil {
+		return err
+	}
+	f.written(name)
+	return f.mem.Truncate(name, size)
+}
+
+// Symlink is refused, since object stores have no symlinks
+func (f *FileSystem) Symlink(oldname, newname string) error {
+	return fmt.Errorf("cannot create symlink %s: object storage has no symlinks", newname)
+}
+
+func (f *FileSystem) EvalSymlinks(path string) (string, error) {
+	return f.mem.EvalSymlinks(path)
+}
+
+// Save writes the changes back: changed objects are replaced only if their ETag is still
+// the one that was read, new objects are created only if they still do not exist, and
+// deleted objects are removed only if unchanged. Objects are written one at a time, so a
+// failure part way leaves the earlier ones written; it returns the keys written so far.
+func (f *FileSystem) Save(ctx context.Context, changes []operations.FileChange) ([]string, error) {
+	var written []string
+	for _, change := range changes {
+		name := filepath.Clean(change.Path)
+		rel, err := filepath.Rel(Root, name)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return written, fmt.Errorf("changed file %s is outside the prefix", change.Path)
+		}
+		key := f.prefix + filepath.ToSlash(rel)
+		object, known := f.objects[name]
+
+		switch {
+		case change.Exists:
+			contentType := object.ContentType
+			if contentType == "" {
+				contentType = contentTypeFor(key)
+			}
+			ifMatch := ""
+			if known {
+				ifMatch = object.ETag
+			}
+			err = f.store.Put(ctx, key, change.After, contentType, ifMatch)
+		case known:
+			err = f.store.Delete(ctx, key, object.ETag)
+		default:
+			continue
+		}
+		if err != nil {
+			return written, fmt.Errorf("failed to write %s: %w", key, err)
+		}
+		written = append(written, key)
+	}
+	return written, nil
+}
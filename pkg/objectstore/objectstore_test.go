@@ -0,0 +1,150 @@
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/developingjames/deltagrams/pkg/operations"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// memoryStore is a Store holding objects in memory
+type memoryStore struct {
+	objects map[string]string
+	etags   map[string]string
+	fetched []string
+	version int
+}
+
+func newMemoryStore(objects map[string]string) *memoryStore {
+	s := &memoryStore{objects: objects, etags: make(map[string]string)}
+	for key := range objects {
+		s.bump(key)
+	}
+	return s
+}
+
+func (s *memoryStore) bump(key string) {
+	s.version++
+	s.etags[key] = fmt.Sprintf(`"%d"`, s.version)
+}
+
+func (s *memoryStore) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	for key, value := range s.objects {
+		if len(key) >= len(prefix) && key[:len(prefix)] == prefix {
+			objects = append(objects, Object{Key: key, ETag: s.etags[key], Size: int64(len(value))})
+		}
+	}
+	return objects, nil
+}
+
+func (s *memoryStore) Get(ctx context.Context, key string) ([]byte, Object, error) {
+	value, ok := s.objects[key]
+	if !ok {
+		return nil, Object{}, os.ErrNotExist
+	}
+	s.fetched = append(s.fetched, key)
+	return []byte(value), Object{Key: key, ETag: s.etags[key], ContentType: "text/plain"}, nil
+}
+
+func (s *memoryStore) Put(ctx context.Context, key string, data []byte, contentType, ifMatch string) error {
+	if _, exists := s.objects[key]; (ifMatch == "" && exists) || (ifMatch != "" && s.etags[key] != ifMatch) {
+		return ErrPreconditionFailed
+	}
+	s.objects[key] = string(data)
+	s.bump(key)
+	return nil
+}
+
+func (s *memoryStore) Delete(ctx context.Context, key, ifMatch string) error {
+	if s.etags[key] != ifMatch {
+		return ErrPreconditionFailed
+	}
+	delete(s.objects, key)
+	delete(s.etags, key)
+	return nil
+}
+
+func TestFileSystem_ApplyAndSave(t *testing.T) {
+	store := newMemoryStore(map[string]string{
+		"site/index.html":    "<h1>Hello</h1>\n<p>old</p>",
+		"site/css/main.css":  "body {}",
+		"site/old.html":      "gone",
+		"site/big/video.mp4": "not fetched",
+		"other/file.txt":     "outside the prefix",
+	})
+	ctx := context.Background()
+
+	fs, err := Open(ctx, store, "site")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{
+		{ContentLocation: "index.html", ContentType: "text/html", DeltaOperation: "content", Content: "@@ -1,2 +1,2 @@\n <h1>Hello</h1>\n-<p>old</p>\n+<p>new</p>"},
+		{ContentLocation: "about.html", ContentType: "text/html", DeltaOperation: "create", Content: "+++ about.html\nabout"},
+		{ContentLocation: "old.html", ContentType: "text/html", DeltaOperation: "delete"},
+	}}
+	recorder := operations.NewRecordingFileSystem(fs)
+	if err := operations.NewApplier(recorder).Apply(deltagram, Root); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	for _, key := range store.fetched {
+		if key == "site/big/video.mp4" {
+			t.Error("Expected untouched objects not to be fetched")
+		}
+	}
+
+	written, err := fs.Save(ctx, recorder.Changes())
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(written) != 3 {
+		t.Errorf("Expected 3 objects written, got %v", written)
+	}
+
+	expected := map[string]string{
+		"site/index.html":    "<h1>Hello</h1>\n<p>new</p>",
+		"site/about.html":    "about",
+		"site/css/main.css":  "body {}",
+		"site/big/video.mp4": "not fetched",
+		"other/file.txt":     "outside the prefix",
+	}
+	if len(store.objects) != len(expected) {
+		t.Errorf("Expected %d objects, got %v", len(expected), store.objects)
+	}
+	for key, value := range expected {
+		if store.objects[key] != value {
+			t.Errorf("Expected %s to be %q, got %q", key, value, store.objects[key])
+		}
+	}
+}
+
+func TestFileSystem_SaveConflict(t *testing.T) {
+	store := newMemoryStore(map[string]string{"index.html": "one"})
+	ctx := context.Background()
+
+	fs, err := Open(ctx, store, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	recorder := operations.NewRecordingFileSystem(fs)
+	if err := recorder.WriteFile(filepath.Join(Root, "index.html"), []byte("mine"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Someone else changes the object after it was read
+	store.objects["index.html"] = "theirs"
+	store.bump("index.html")
+
+	if _, err := fs.Save(ctx, recorder.Changes()); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("Expected a precondition failure, got: %v", err)
+	}
+	if store.objects["index.html"] != "theirs" {
+		t.Errorf("Expected the concurrent change to be kept, got %q", store.objects["index.html"])
+	}
+}
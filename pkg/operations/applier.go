@@ -1,6 +1,7 @@
 package operations
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -10,22 +11,38 @@ import (
 // DefaultApplier implements the Applier interface
 type DefaultApplier struct {
 	fs       FileSystem
+	opts     Options
 	handlers []OperationHandler
+	current  Warning // Part and path that warnings are attributed to
 }
 
 // NewApplier creates a new applier with the given file system
 func NewApplier(fs FileSystem) Applier {
+	return NewApplierWithOptions(fs, Options{})
+}
+
+// NewApplierWithOptions creates a new applier with the given file system and options
+func NewApplierWithOptions(fs FileSystem, opts Options) Applier {
 	applier := &DefaultApplier{
-		fs: fs,
+		fs:   fs,
+		opts: opts,
 	}
 
 	// Register default handlers
 	applier.handlers = []OperationHandler{
-		NewCreateHandler(),
-		NewDeleteHandler(),
-		NewCopyHandler(),
-		NewMoveHandler(),
-		NewContentHandler(),
+		&CreateHandler{report: applier.report},
+		&DeleteHandler{warn: applier.warn, report: applier.report},
+		&TouchHandler{report: applier.report},
+		&TruncateHandler{preserveModTime: opts.PreserveModTime, report: applier.report},
+		&CopyHandler{warn: applier.warn, report: applier.report},
+		&MoveHandler{warn: applier.warn, report: applier.report},
+		&ContentHandler{preserveModTime: opts.PreserveModTime, resolver: opts.ConflictResolver, streamThreshold: opts.StreamThreshold, warn: applier.warn, report: applier.report},
+		&InlineHandler{preserveModTime: opts.PreserveModTime, warn: applier.warn, report: applier.report},
+		&TableHandler{preserveModTime: opts.PreserveModTime, report: applier.report},
+		&LinesHandler{preserveModTime: opts.PreserveModTime, report: applier.report},
+		&KVSetHandler{preserveModTime: opts.PreserveModTime, report: applier.report},
+		&YAMLHandler{preserveModTime: opts.PreserveModTime, report: applier.report},
+		&GoASTHandler{preserveModTime: opts.PreserveModTime, report: applier.report},
 	}
 
 	return applier
@@ -33,13 +50,62 @@ func NewApplier(fs FileSystem) Applier {
 
 // Apply applies a deltagram to the specified base directory
 func (a *DefaultApplier) Apply(deltagram *parser.Deltagram, baseDir string) error {
+	return a.ApplyContext(context.Background(), deltagram, baseDir)
+}
+
+// ApplyContext applies a deltagram to the specified base directory, checking ctx before
+// each part. A part that has started is always finished, so cancellation never leaves a
+// file half written; the returned error wraps the context's error and says how many
+// parts were applied.
+func (a *DefaultApplier) ApplyContext(ctx context.Context, deltagram *parser.Deltagram, baseDir string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	deltagram, err := SanitizePaths(deltagram, a.opts.Sanitize)
+	if err != nil {
+		return &ValidationError{Err: err}
+	}
+	if a.opts.FixWhitespace {
+		deltagram = FixWhitespace(deltagram)
+	}
+
+	// Fail fast when the directory is not what the deltagram's author expected
+	if err := CheckRequirements(a.fs, baseDir, deltagram); err != nil {
+		return err
+	}
+
+	// Validate every part before touching the file system
+	if err := a.validate(deltagram, baseDir); err != nil {
+		return &ValidationError{Err: err}
+	}
+
+	progress, sizes := a.startProgress(deltagram, baseDir)
+	applied, total := 0, countFileParts(deltagram)
+
 	// Process operations in the order they appear
-	for _, part := range deltagram.Parts {
+	for i, part := range deltagram.Parts {
 		// Skip message parts
-		if part.ContentLocation == "mimeogram://message" || part.ContentLocation == "deltagram://message" {
-			fmt.Printf("Message: %s\n", strings.TrimSpace(part.Content))
+		if part.IsNote() {
+			a.report("Note on %s: %s\n", part.NoteTarget(), strings.TrimSpace(part.Content))
 			continue
 		}
+		if part.IsRequirement() {
+			continue // Checked before anything was applied
+		}
+		if part.IsRun() {
+			continue // Run by the caller, if the user allows it
+		}
+		if isMessagePart(part) {
+			a.report("Message: %s\n", strings.TrimSpace(part.Content))
+			continue
+		}
+
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("canceled after applying %d of %d parts: %w", applied, total, err)
+		}
+
+		a.current = Warning{Part: i + 1, Path: part.ContentLocation}
 
 		// Find appropriate handler
 		var handler OperationHandler
@@ -52,13 +118,129 @@ func (a *DefaultApplier) Apply(deltagram *parser.Deltagram, baseDir string) erro
 
 		if handler == nil {
 			// Default to create for backward compatibility
+			a.warn(WarnDefaultCreate, fmt.Sprintf("unknown operation %q for %s, treated as create", part.DeltaOperation, part.ContentLocation))
 			handler = NewCreateHandler()
 		}
 
-		if err := handler.Apply(a.fs, baseDir, part); err != nil {
-			return fmt.Errorf("failed to apply %s operation to %s: %v", part.DeltaOperation, part.ContentLocation, err)
+		err := handler.Apply(a.fs, baseDir, part)
+		if err == nil && a.opts.FixWhitespace {
+			err = a.fixFinalNewline(baseDir, part)
+		}
+		if err != nil {
+			return &parser.PartError{Index: i + 1, Line: part.Line,
+				Err: fmt.Errorf("failed to apply %s operation to %s: %w", part.DeltaOperation, part.ContentLocation, err)}
+		}
+		applied++
+
+		if a.opts.Progress != nil {
+			progress.Done++
+			progress.Bytes += sizes[i]
+			progress.Path = part.ContentLocation
+			a.opts.Progress(progress)
 		}
 	}
 
 	return nil
 }
+
+// startProgress reports the totals of the deltagram before anything is applied and
+// returns the estimated size of each part, measured before earlier parts change the tree
+func (a *DefaultApplier) startProgress(deltagram *parser.Deltagram, baseDir string) (Progress, []int64) {
+	var progress Progress
+	if a.opts.Progress == nil {
+		return progress, nil
+	}
+	sizes := make([]int64, len(deltagram.Parts))
+	for i, part := range deltagram.Parts {
+		if isMessagePart(part) {
+			continue
+		}
+		sizes[i] = estimateWrittenBytes(a.fs, baseDir, part)
+		progress.Total++
+		progress.TotalBytes += sizes[i]
+	}
+	a.opts.Progress(progress)
+	return progress, sizes
+}
+
+// validate checks all parts against the configured safety rails
+func (a *DefaultApplier) validate(deltagram *parser.Deltagram, baseDir string) error {
+	if err := a.checkLimits(deltagram, baseDir); err != nil {
+		return err
+	}
+
+	checker := newIgnoreChecker(a.fs, baseDir)
+	for i, part := range deltagram.Parts {
+		if isMessagePart(part) {
+			continue
+		}
+		a.current = Warning{Part: i + 1, Path: part.ContentLocation}
+		if err := a.validatePart(checker, baseDir, part); err != nil {
+			return &parser.PartError{Index: i + 1, Line: part.Line, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// validatePart checks the body of a delete, touch, truncate, copy, or move and the paths one part
+// reads and writes
+func (a *DefaultApplier) validatePart(checker *ignoreChecker, baseDir string, part parser.DeltagramPart) error {
+	switch part.DeltaOperation {
+	case "delete":
+		if err := checkDeleteBody(part); err != nil {
+			return err
+		}
+	case "touch":
+		if err := checkTouchBody(part); err != nil {
+			return err
+		}
+	case "truncate":
+		if err := checkTruncateBody(part); err != nil {
+			return err
+		}
+	case "copy", "move":
+		sourcePath, _, _, err := transferPaths(part)
+		if err != nil {
+			return err
+		}
+		// Copying from outside the base directory would leak external content
+		if part.DeltaOperation == "copy" {
+			if err := checkWithinBase(a.fs, baseDir, sourcePath, a.opts.AllowSymlinkEscape, a.warn); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, path := range WrittenPaths(part) {
+		if err := checkWithinBase(a.fs, baseDir, path, a.opts.AllowSymlinkEscape, a.warn); err != nil {
+			return err
+		}
+		if err := checkPathPolicy(baseDir, path, a.opts.AllowPaths, a.opts.DenyPaths); err != nil {
+			return err
+		}
+		if a.opts.AllowIgnored {
+			continue
+		}
+		if err := checker.Check(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// countFileParts returns the number of file operations in the deltagram
+func countFileParts(deltagram *parser.Deltagram) int {
+	count := 0
+	for _, part := range deltagram.Parts {
+		if !isMessagePart(part) {
+			count++
+		}
+	}
+	return count
+}
+
+// isMessagePart reports whether the part is a message rather than a file operation
+func isMessagePart(part parser.DeltagramPart) bool {
+	return part.IsMessage()
+}
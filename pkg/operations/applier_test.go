@@ -0,0 +1,397 @@
+package operations
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/developingjames/deltagrams/internal/testutil"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func createPart(location string) parser.DeltagramPart {
+	return parser.DeltagramPart{
+		ContentLocation: location,
+		ContentType:     "application/x-deltagram-fileop; charset=utf-8",
+		DeltaOperation:  "create",
+		Content:         "+++ " + location + "\nnew content",
+	}
+}
+
+func TestApplier_Apply_RefusesIgnoredPaths(t *testing.T) {
+	tests := []struct {
+		name     string
+		part     parser.DeltagramPart
+		expected string
+	}{
+		{
+			name:     "gitignored file",
+			part:     createPart("build/output.txt"),
+			expected: "ignored by .gitignore",
+		},
+		{
+			name:     "git internals",
+			part:     createPart(".git/config"),
+			expected: "protected directory .git",
+		},
+		{
+			name:     "node_modules",
+			part:     createPart("web/node_modules/pkg/index.js"),
+			expected: "protected directory node_modules",
+		},
+		{
+			name: "move into ignored directory",
+			part: parser.DeltagramPart{
+				ContentLocation: "src/main.go",
+				DeltaOperation:  "move",
+				Content:         "--- src/main.go\n+++ build/main.go",
+			},
+			expected: "ignored by .gitignore",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fs := testutil.NewMockFileSystem()
+			fs.AddFile("/base/.gitignore", []byte("build/\n"))
+			fs.AddFile("/base/src/main.go", []byte("package main"))
+			applier := NewApplier(fs)
+
+			deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{createPart("src/ok.txt"), test.part}}
+			err := applier.Apply(deltagram, "/base")
+			if err == nil {
+				t.Fatal("Expected error, got none")
+			}
+			if !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("Expected error containing %q, got: %v", test.expected, err)
+			}
+
+			// Validation happens before any part is applied
+			if fs.FileExists("/base/src/ok.txt") {
+				t.Error("Expected no parts to be applied when validation fails")
+			}
+		})
+	}
+}
+
+func TestApplier_Apply_AllowIgnored(t *testing.T) {
+	fs := testutil.NewMockFileSystem()
+	fs.AddFile("/base/.gitignore", []byte("build/\n"))
+	applier := NewApplierWithOptions(fs, Options{AllowIgnored: true})
+
+	deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{createPart("build/output.txt")}}
+	if err := applier.Apply(deltagram, "/base"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if !fs.FileExists("/base/build/output.txt") {
+		t.Error("Expected ignored file to be created when AllowIgnored is set")
+	}
+}
+
+func TestApplier_Apply_PathPolicy(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     Options
+		location string
+		expected string
+	}{
+		{
+			name:     "allowed directory",
+			opts:     Options{AllowPaths: []string{"src/", "docs"}},
+			location: "src/pkg/main.go",
+		},
+		{
+			name:     "outside allowlist",
+			opts:     Options{AllowPaths: []string{"src/", "docs"}},
+			location: "Makefile",
+			expected: "not covered by the allowed paths",
+		},
+		{
+			name:     "denied beneath allowed",
+			opts:     Options{AllowPaths: []string{"src/"}, DenyPaths: []string{"src/secrets"}},
+			location: "src/secrets/key.pem",
+			expected: "matches denied pattern",
+		},
+		{
+			name:     "denied glob",
+			opts:     Options{DenyPaths: []string{"**/*.lock"}},
+			location: "web/yarn.lock",
+			expected: "matches denied pattern",
+		},
+		{
+			name:     "malformed deny pattern",
+			opts:     Options{DenyPaths: []string{"secrets/[abc"}},
+			location: "secrets/a",
+			expected: `invalid path pattern "secrets/[abc": unclosed character class`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fs := testutil.NewMockFileSystem()
+			applier := NewApplierWithOptions(fs, test.opts)
+
+			deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{createPart(test.location)}}
+			err := applier.Apply(deltagram, "/base")
+
+			if test.expected == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("Expected error containing %q, got: %v", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestApplier_Apply_Limits(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     Options
+		expected string
+	}{
+		{
+			name:     "too many parts",
+			opts:     Options{MaxParts: 1},
+			expected: "exceeds the limit of 1 operations",
+		},
+		{
+			name:     "file too large",
+			opts:     Options{MaxFileSize: 20},
+			expected: "exceeding the file size limit of 20 bytes",
+		},
+		{
+			name:     "total too large",
+			opts:     Options{MaxTotalBytes: 40},
+			expected: "more than the limit of 40 bytes in total",
+		},
+		{
+			name: "within limits",
+			opts: Options{MaxParts: 2, MaxFileSize: 100, MaxTotalBytes: 200},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fs := testutil.NewMockFileSystem()
+			fs.AddFile("/base/existing.txt", []byte("0123456789"))
+			applier := NewApplierWithOptions(fs, test.opts)
+
+			deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{
+				{ContentLocation: "deltagram://message", Content: "message parts are not counted"},
+				{
+					ContentLocation: "new.txt",
+					DeltaOperation:  "create",
+					Content:         "+++ new.txt\n0123456789",
+				},
+				{
+					ContentLocation: "existing.txt",
+					DeltaOperation:  "content",
+					Content:         "@@ -1,1 +1,2 @@\n 0123456789\n+abcdefghijklmnopqrstuvwxyz",
+				},
+			}}
+
+			err := applier.Apply(deltagram, "/base")
+			if test.expected == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Fatalf("Expected error containing %q, got: %v", test.expected, err)
+			}
+			if fs.FileExists("/base/new.txt") {
+				t.Error("Expected limits to be enforced before any part is applied")
+			}
+		})
+	}
+}
+
+func TestApplier_Apply_RefusesSymlinkEscape(t *testing.T) {
+	baseDir := t.TempDir()
+	outsideDir := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(outsideDir, "config.yaml"), []byte("external: true"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outsideDir, "config.yaml"), filepath.Join(baseDir, "config.yaml")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	if err := os.Symlink(outsideDir, filepath.Join(baseDir, "linked")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		part parser.DeltagramPart
+	}{
+		{
+			name: "content through file symlink",
+			part: parser.DeltagramPart{
+				ContentLocation: "config.yaml",
+				DeltaOperation:  "content",
+				Content:         "@@ -1,1 +1,1 @@\n-external: true\n+external: false",
+			},
+		},
+		{
+			name: "create beneath directory symlink",
+			part: createPart("linked/new.txt"),
+		},
+		{
+			name: "parent traversal",
+			part: createPart("../escaped.txt"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			applier := NewApplier(NewRealFileSystem())
+			deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{test.part}}
+
+			err := applier.Apply(deltagram, baseDir)
+			if err == nil || !strings.Contains(err.Error(), "outside the base directory") && !strings.Contains(err.Error(), "escapes the base directory") {
+				t.Fatalf("Expected base directory escape error, got: %v", err)
+			}
+		})
+	}
+
+	content, _ := os.ReadFile(filepath.Join(outsideDir, "config.yaml"))
+	if string(content) != "external: true" {
+		t.Errorf("Expected external file to be untouched, got %q", string(content))
+	}
+}
+
+func TestApplier_Apply_AllowSymlinkEscape(t *testing.T) {
+	baseDir := t.TempDir()
+	outsideDir := t.TempDir()
+
+	if err := os.Symlink(outsideDir, filepath.Join(baseDir, "linked")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	applier := NewApplierWithOptions(NewRealFileSystem(), Options{AllowSymlinkEscape: true})
+	deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{createPart("linked/new.txt")}}
+
+	if err := applier.Apply(deltagram, baseDir); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outsideDir, "new.txt")); err != nil {
+		t.Errorf("Expected file to be written through the symlink: %v", err)
+	}
+}
+
+func TestApplier_Apply_RefusesSymlinkEscape_MockFileSystem(t *testing.T) {
+	fs := testutil.NewMockFileSystem()
+	fs.AddFile("/outside/secret.txt", []byte("secret"))
+	fs.AddDir("/base")
+	fs.AddSymlink("/outside", "/base/link")
+
+	info, err := fs.Lstat("/base/link")
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("Expected Lstat to report a symlink, got %v (%v)", info, err)
+	}
+
+	entries, err := fs.ReadDir("/base")
+	if err != nil || len(entries) != 1 || entries[0].Name() != "link" {
+		t.Fatalf("Expected ReadDir to list the symlink, got %v (%v)", entries, err)
+	}
+
+	applier := NewApplier(fs)
+	deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{createPart("link/secret.txt")}}
+
+	err = applier.Apply(deltagram, "/base")
+	if err == nil || !strings.Contains(err.Error(), "outside the base directory") {
+		t.Fatalf("Expected symlink escape error, got: %v", err)
+	}
+
+	content, _ := fs.ReadFile("/outside/secret.txt")
+	if string(content) != "secret" {
+		t.Errorf("Expected file behind symlink to be untouched, got %q", string(content))
+	}
+}
+
+func TestApplier_Apply_Progress(t *testing.T) {
+	fs := testutil.NewMockFileSystem()
+	deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{
+		{ContentLocation: "deltagram://message", ContentType: "text/plain", Content: "Summary"},
+		createPart("a.txt"),
+		createPart("b.txt"),
+	}}
+
+	var events []Progress
+	applier := NewApplierWithOptions(fs, Options{Progress: func(p Progress) { events = append(events, p) }})
+	if err := applier.Apply(deltagram, "/base"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	size := int64(len("new content"))
+	expected := []Progress{
+		{Done: 0, Total: 2, Bytes: 0, TotalBytes: 2 * size},
+		{Done: 1, Total: 2, Bytes: size, TotalBytes: 2 * size, Path: "a.txt"},
+		{Done: 2, Total: 2, Bytes: 2 * size, TotalBytes: 2 * size, Path: "b.txt"},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected %d progress events, got %d: %+v", len(expected), len(events), events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Errorf("Event %d: expected %+v, got %+v", i, expected[i], events[i])
+		}
+	}
+}
+
+func TestApplier_Apply_Output(t *testing.T) {
+	fs := testutil.NewMockFileSystem()
+	deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{
+		{ContentLocation: "deltagram://message", ContentType: "text/plain", Content: "Summary"},
+		createPart("a.txt"),
+	}}
+
+	var out strings.Builder
+	if err := NewApplierWithOptions(fs, Options{Output: &out}).Apply(deltagram, "/base"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if out.String() != "Message: Summary\nCreated: a.txt\n" {
+		t.Errorf("Expected the message and created file to be reported, got %q", out.String())
+	}
+}
+
+func TestApplier_ApplyContext_Canceled(t *testing.T) {
+	fs := testutil.NewMockFileSystem()
+	deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{
+		createPart("a.txt"),
+		createPart("b.txt"),
+		createPart("c.txt"),
+	}}
+
+	// Cancel once the first part is done
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	applier := NewApplierWithOptions(fs, Options{Progress: func(p Progress) {
+		if p.Done == 1 {
+			cancel()
+		}
+	}})
+
+	err := applier.ApplyContext(ctx, deltagram, "/base")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "after applying 1 of 3 parts") {
+		t.Errorf("Expected partial progress in error, got: %v", err)
+	}
+	if !fs.FileExists(filepath.Join("/base", "a.txt")) {
+		t.Error("Expected the first part to be applied")
+	}
+	if fs.FileExists(filepath.Join("/base", "b.txt")) {
+		t.Error("Expected later parts not to be applied")
+	}
+}
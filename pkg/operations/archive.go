@@ -0,0 +1,331 @@
+package operations
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ArchiveRoot is the base directory under which an ArchiveFileSystem exposes the
+// entries of its archive
+var ArchiveRoot = string(filepath.Separator)
+
+// ArchiveEntry is a file, directory, or symlink stored in an archive
+type ArchiveEntry struct {
+	Path    string // Slash-separated and relative, with no trailing slash
+	Data    []byte // Content of a file
+	Target  string // Target of a symlink
+	Mode    os.FileMode
+	ModTime time.Time
+}
+
+// ArchiveFormat returns "zip", "tar", or "tar.gz" for an archive name
+func ArchiveFormat(name string) (string, error) {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return "zip", nil
+	case strings.HasSuffix(lower, ".tar"):
+		return "tar", nil
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return "tar.gz", nil
+	default:
+		return "", fmt.Errorf("unsupported archive %s: use a .zip, .tar, .tar.gz, or .tgz name", name)
+	}
+}
+
+// ArchiveFileSystem is a MemoryFileSystem loaded from a zip or tar archive, so that a
+// deltagram can be applied to the archive without unpacking it to disk. Save writes the
+// result back to the archive.
+type ArchiveFileSystem struct {
+	*MemoryFileSystem
+	path string
+}
+
+// OpenArchive loads the archive at name into memory
+func OpenArchive(name string) (*ArchiveFileSystem, error) {
+	entries, err := ReadArchive(name)
+	if err != nil {
+		return nil, err
+	}
+	mem, err := LoadArchiveEntries(entries)
+	if err != nil {
+		return nil, err
+	}
+	return &ArchiveFileSystem{MemoryFileSystem: mem, path: name}, nil
+}
+
+// LoadArchiveEntries returns a MemoryFileSystem holding entries under ArchiveRoot
+func LoadArchiveEntries(entries []ArchiveEntry) (*MemoryFileSystem, error) {
+	if err := checkEntryPaths(entries); err != nil {
+		return nil, err
+	}
+
+	mem := NewMemoryFileSystem()
+	for _, entry := range entries {
+		target := filepath.Join(ArchiveRoot, filepath.FromSlash(entry.Path))
+		err := mem.MkdirAll(filepath.Dir(target), 0755)
+		if err != nil {
+			return nil, fmt.Errorf("invalid archive entry %s: %v", entry.Path, err)
+		}
+		switch {
+		case entry.Mode.IsDir():
+			err = mem.MkdirAll(target, entry.Mode.Perm())
+		case entry.Mode&os.ModeSymlink != 0:
+			err = mem.Symlink(entry.Target, target)
+		default:
+			err = mem.WriteFile(target, entry.Data, entry.Mode.Perm())
+		}
+		if err == nil {
+			err = mem.Chtimes(target, entry.ModTime, entry.ModTime)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid archive entry %s: %v", entry.Path, err)
+		}
+	}
+	return mem, nil
+}
+
+// Save replaces the archive with the current contents of the file system
+func (a *ArchiveFileSystem) Save() error {
+	a.mu.RLock()
+	var entries []ArchiveEntry
+	for name, node := range a.nodes {
+		rel, err := filepath.Rel(ArchiveRoot, name)
+		if err != nil || rel == "." {
+			continue
+		}
+		entries = append(entries, ArchiveEntry{
+			Path:    filepath.ToSlash(rel),
+			Data:    bytes.Clone(node.data),
+			Target:  node.target,
+			Mode:    node.mode,
+			ModTime: node.modTime,
+		})
+	}
+	a.mu.RUnlock()
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return WriteArchive(a.path, entries)
+}
+
+// ReadArchive reads every entry of the zip or tar archive at name. Entries with absolute
+// paths or paths leading outside the archive are refused.
+func ReadArchive(name string) ([]ArchiveEntry, error) {
+	format, err := ArchiveFormat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []ArchiveEntry
+	if format == "zip" {
+		entries, err = readZip(name)
+	} else {
+		entries, err = readTarFile(name, format == "tar.gz")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive %s: %v", name, err)
+	}
+	if err := checkEntryPaths(entries); err != nil {
+		return nil, fmt.Errorf("archive %s %v", name, err)
+	}
+	return entries, nil
+}
+
+// checkEntryPaths cleans the path of each entry, refusing absolute paths and paths that
+// lead outside the archive
+func checkEntryPaths(entries []ArchiveEntry) error {
+	for i, entry := range entries {
+		clean := path.Clean(strings.TrimSuffix(entry.Path, "/"))
+		if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return fmt.Errorf("has an entry outside the archive: %s", entry.Path)
+		}
+		entries[i].Path = clean
+	}
+	return nil
+}
+
+func readZip(name string) ([]ArchiveEntry, error) {
+	zr, err := zip.OpenReader(name)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	var entries []ArchiveEntry
+	for _, f := range zr.File {
+		entry := ArchiveEntry{Path: f.Name, Mode: f.Mode(), ModTime: f.Modified}
+		if !entry.Mode.IsDir() {
+			r, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			data, err := io.ReadAll(r)
+			r.Close()
+			if err != nil {
+				return nil, err
+			}
+			if entry.Mode&os.ModeSymlink != 0 {
+				entry.Target = string(data)
+			} else {
+				entry.Data = data
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func readTarFile(name string, gzipped bool) ([]ArchiveEntry, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if gzipped {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return ReadTar(r)
+}
+
+// ReadTar reads the entries of an uncompressed tar stream. Only files, directories, and
+// symlinks are supported.
+func ReadTar(r io.Reader) ([]ArchiveEntry, error) {
+	var entries []ArchiveEntry
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		entry := ArchiveEntry{Path: header.Name, Mode: header.FileInfo().Mode(), ModTime: header.ModTime}
+		switch header.Typeflag {
+		case tar.TypeDir:
+		case tar.TypeSymlink:
+			entry.Target = header.Linkname
+		case tar.TypeReg:
+			if entry.Data, err = io.ReadAll(tr); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unsupported entry %s", header.Name)
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// WriteArchive writes entries to a zip or tar archive at name, chosen by its extension.
+// The archive is written next to name and renamed over it, so a failure leaves any
+// existing archive untouched.
+func WriteArchive(name string, entries []ArchiveEntry) error {
+	format, err := ArchiveFormat(name)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".deltagram-archive-*")
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	switch format {
+	case "zip":
+		err = writeZip(tmp, entries)
+	case "tar.gz":
+		gz := gzip.NewWriter(tmp)
+		if err = WriteTar(gz, entries); err == nil {
+			err = gz.Close()
+		}
+	default:
+		err = WriteTar(tmp, entries)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write archive %s: %v", name, err)
+	}
+
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to write archive %s: %v", name, err)
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return fmt.Errorf("failed to write archive %s: %v", name, err)
+	}
+	return nil
+}
+
+func writeZip(w io.Writer, entries []ArchiveEntry) error {
+	zw := zip.NewWriter(w)
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.Path, Method: zip.Deflate, Modified: entry.ModTime}
+		header.SetMode(entry.Mode)
+		content := entry.Data
+		switch {
+		case entry.Mode.IsDir():
+			header.Name += "/"
+			header.Method = zip.Store
+		case entry.Mode&os.ModeSymlink != 0:
+			content = []byte(entry.Target)
+		}
+
+		fw, err := zw.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(content); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// WriteTar writes entries to w as an uncompressed tar stream
+func WriteTar(w io.Writer, entries []ArchiveEntry) error {
+	tw := tar.NewWriter(w)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.Path, Mode: int64(entry.Mode.Perm()), ModTime: entry.ModTime, Typeflag: tar.TypeReg}
+		switch {
+		case entry.Mode.IsDir():
+			header.Name += "/"
+			header.Typeflag = tar.TypeDir
+		case entry.Mode&os.ModeSymlink != 0:
+			header.Typeflag = tar.TypeSymlink
+			header.Linkname = entry.Target
+		default:
+			header.Size = int64(len(entry.Data))
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if _, err := tw.Write(entry.Data); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
@@ -0,0 +1,95 @@
+package operations
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func TestArchiveFileSystem_ApplyAndSave(t *testing.T) {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	entries := []ArchiveEntry{
+		{Path: "src", Mode: os.ModeDir | 0755, ModTime: modTime},
+		{Path: "src/main.txt", Data: []byte("one\ntwo\nthree"), Mode: 0644, ModTime: modTime},
+		{Path: "run.sh", Data: []byte("#!/bin/sh\n"), Mode: 0755, ModTime: modTime},
+		{Path: "old.txt", Data: []byte("old"), Mode: 0644, ModTime: modTime},
+	}
+
+	for _, name := range []string{"project.zip", "project.tar", "project.tar.gz", "project.tgz"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			if err := WriteArchive(path, entries); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			archive, err := OpenArchive(path)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{
+				{ContentLocation: "src/main.txt", ContentType: "text/plain", DeltaOperation: "content", Content: "@@ -1,3 +1,3 @@\n one\n-two\n+TWO\n three"},
+				{ContentLocation: "docs/new.txt", ContentType: "text/plain", DeltaOperation: "create", Content: "+++ docs/new.txt\nnew"},
+				{ContentLocation: "old.txt", ContentType: "text/plain", DeltaOperation: "delete"},
+			}}
+			if err := NewApplier(archive).Apply(deltagram, ArchiveRoot); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if err := archive.Save(); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			saved, err := ReadArchive(path)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			got := make(map[string]ArchiveEntry)
+			for _, entry := range saved {
+				got[entry.Path] = entry
+			}
+			if string(got["src/main.txt"].Data) != "one\nTWO\nthree" {
+				t.Errorf("Expected src/main.txt to be patched, got %q", got["src/main.txt"].Data)
+			}
+			if string(got["docs/new.txt"].Data) != "new" {
+				t.Errorf("Expected docs/new.txt to be created, got %q", got["docs/new.txt"].Data)
+			}
+			if _, exists := got["old.txt"]; exists {
+				t.Error("Expected old.txt to be deleted")
+			}
+			if got["run.sh"].Mode.Perm() != 0755 || !got["run.sh"].ModTime.Equal(modTime) {
+				t.Errorf("Expected run.sh to keep its mode and time, got %v %v", got["run.sh"].Mode, got["run.sh"].ModTime)
+			}
+		})
+	}
+}
+
+func TestReadArchive_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []ArchiveEntry
+		errText string
+	}{
+		{"parent path", []ArchiveEntry{{Path: "../escape.txt", Mode: 0644}}, "outside the archive"},
+		{"absolute path", []ArchiveEntry{{Path: "/etc/passwd", Mode: 0644}}, "outside the archive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "bad.tar")
+			if err := WriteArchive(path, tt.entries); err != nil {
+				t.Fatal(err)
+			}
+			_, err := ReadArchive(path)
+			if err == nil || !strings.Contains(err.Error(), tt.errText) {
+				t.Errorf("Expected error containing %q, got: %v", tt.errText, err)
+			}
+		})
+	}
+
+	if _, err := OpenArchive("project.rar"); err == nil || !strings.Contains(err.Error(), "unsupported archive") {
+		t.Errorf("Expected an unsupported archive error, got: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package operations
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// HunkStatus describes how a hunk's context lines up with the target file
+type HunkStatus string
+
+const (
+	// HunkExact means the hunk matches at the line given in its header
+	HunkExact HunkStatus = "exact"
+	// HunkFuzzy means the hunk matches only after shifting it by an offset
+	HunkFuzzy HunkStatus = "fuzzy"
+	// HunkFailed means the hunk does not match anywhere in the search window
+	HunkFailed HunkStatus = "failed"
+)
+
+// HunkCheck is the result of checking a single hunk against its target file
+type HunkCheck struct {
+	Index    int // 1-based position of the hunk within its part
+	OldStart int
+	Status   HunkStatus
+	Offset   int   // Lines the hunk had to be shifted by when Status is HunkFuzzy
+	Err      error // Reason for failure when Status is HunkFailed
+}
+
+// CheckContentPart verifies every hunk of a content part against the file it targets
+// without modifying anything
+func CheckContentPart(fs FileSystem, baseDir string, part parser.DeltagramPart) ([]HunkCheck, error) {
+	filePath := ResolveFilePath(baseDir, part.ContentLocation)
+	diffLines := strings.Split(part.Content, "\n")
+
+	if oldPath, _ := parseFileHeaders(diffLines); oldPath == devNull {
+		if _, err := fs.Stat(filePath); err == nil {
+			return nil, fmt.Errorf("cannot create %s from /dev/null diff: file already exists", part.ContentLocation)
+		}
+		return nil, nil
+	}
+
+	if _, err := fs.Stat(filePath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("cannot apply content operation to non-existent file: %s", part.ContentLocation)
+	}
+
+	existingContent, err := fs.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read existing file: %w", err)
+	}
+
+	handler := &ContentHandler{}
+	hunks, err := handler.ParseAllHunks(diffLines)
+	if err != nil {
+		return nil, err
+	}
+
+	_, text := cutByteOrderMark(string(existingContent))
+	originalLines, _ := fileLines(text)
+	results := make([]HunkCheck, 0, len(hunks))
+
+	for i, hunk := range hunks {
+		check := HunkCheck{Index: i + 1, OldStart: hunk.Header.OldStart}
+		suggestedStart := hunk.expectedStart()
+
+		if !inRange(hunk, suggestedStart, len(originalLines)) {
+			check.Status = HunkFailed
+			check.Err = fmt.Errorf("hunk refers to line %d but original file has %d lines", hunk.Header.OldStart, len(originalLines))
+		} else if position, err := handler.findBestHunkPosition(originalLines, hunk, suggestedStart); err != nil {
+			check.Status = HunkFailed
+			check.Err = err
+		} else if position != suggestedStart {
+			check.Status = HunkFuzzy
+			check.Offset = position - suggestedStart
+		} else {
+			check.Status = HunkExact
+		}
+
+		results = append(results, check)
+	}
+
+	return results, nil
+}
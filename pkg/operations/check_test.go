@@ -0,0 +1,69 @@
+package operations
+
+import (
+	"testing"
+
+	"github.com/developingjames/deltagrams/internal/testutil"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func TestCheckContentPart(t *testing.T) {
+	fs := testutil.NewMockFileSystem()
+	fs.AddFile("/base/file.txt", []byte("a\nb\nc\nd\ne\nf\ng\nh"))
+
+	part := parser.DeltagramPart{
+		ContentLocation: "file.txt",
+		ContentType:     "application/x-deltagram-content; charset=utf-8; linesep=LF",
+		DeltaOperation:  "content",
+		Content: `@@ -1,2 +1,2 @@
+ a
+-b
++B
+@@ -3,2 +3,2 @@
+ e
+-f
++F
+@@ -7,1 +7,1 @@
+-missing
++replacement`,
+	}
+
+	results, err := CheckContentPart(fs, "/base", part)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(results) != 3 {
+		t.Fatalf("Expected 3 hunk results, got %d", len(results))
+	}
+
+	if results[0].Status != HunkExact {
+		t.Errorf("Expected first hunk to match exactly, got %s", results[0].Status)
+	}
+	if results[1].Status != HunkFuzzy || results[1].Offset != 2 {
+		t.Errorf("Expected second hunk to need fuzz of +2, got %s (%d)", results[1].Status, results[1].Offset)
+	}
+	if results[2].Status != HunkFailed || results[2].Err == nil {
+		t.Errorf("Expected third hunk to fail with an error, got %s", results[2].Status)
+	}
+
+	// The file must be left untouched
+	content, _ := fs.ReadFile("/base/file.txt")
+	if string(content) != "a\nb\nc\nd\ne\nf\ng\nh" {
+		t.Errorf("Expected file to be unchanged, got %q", string(content))
+	}
+}
+
+func TestCheckContentPart_MissingFile(t *testing.T) {
+	fs := testutil.NewMockFileSystem()
+
+	part := parser.DeltagramPart{
+		ContentLocation: "missing.txt",
+		DeltaOperation:  "content",
+		Content:         "@@ -1,1 +1,1 @@\n-old\n+new",
+	}
+
+	if _, err := CheckContentPart(fs, "/base", part); err == nil {
+		t.Error("Expected error for missing file, got none")
+	}
+}
@@ -0,0 +1,37 @@
+package operations
+
+// Conflict describes a content hunk that does not match the file it targets
+type Conflict struct {
+	Path  string      // Content-Location of the part
+	Index int         // 1-based position of the hunk within the part
+	Hunk  *ParsedHunk // The hunk that failed to match
+	Lines []string    // Lines of the original file
+	Start int         // 0-based line the hunk header points at, clamped to the file
+	Err   error       // Why the hunk did not match
+}
+
+// ResolutionAction is the choice made for a conflicting hunk
+type ResolutionAction int
+
+const (
+	// ResolveAbort fails the apply with the conflict's error
+	ResolveAbort ResolutionAction = iota
+	// ResolveApplyAt applies the hunk at Resolution.Start without checking its context
+	ResolveApplyAt
+	// ResolveSkip leaves the file unchanged for this hunk
+	ResolveSkip
+	// ResolveRetry replaces the hunk with Resolution.Hunk and matches it again
+	ResolveRetry
+)
+
+// Resolution is a ConflictResolver's decision for one conflict
+type Resolution struct {
+	Action ResolutionAction
+	Start  int         // 0-based line in the original file for ResolveApplyAt
+	Hunk   *ParsedHunk // Edited hunk for ResolveRetry
+}
+
+// ConflictResolver decides how to proceed when a content hunk does not match
+type ConflictResolver interface {
+	Resolve(conflict Conflict) (Resolution, error)
+}
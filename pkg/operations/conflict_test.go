@@ -0,0 +1,74 @@
+package operations
+
+import (
+	"strings"
+	"testing"
+)
+
+// scriptedResolver returns its resolutions in order and records the conflicts it saw
+type scriptedResolver struct {
+	resolutions []Resolution
+	conflicts   []Conflict
+}
+
+func (r *scriptedResolver) Resolve(conflict Conflict) (Resolution, error) {
+	r.conflicts = append(r.conflicts, conflict)
+	resolution := r.resolutions[0]
+	r.resolutions = r.resolutions[1:]
+	return resolution, nil
+}
+
+func TestContentHandler_ConflictResolver(t *testing.T) {
+	original := "one\ntwo\nthree\nfour"
+	diff := "@@ -2,1 +2,1 @@\n-TWO\n+2"
+
+	edited, _ := (&ContentHandler{}).ParseAllHunks(strings.Split("@@ -2,1 +2,1 @@\n-two\n+2", "\n"))
+
+	tests := []struct {
+		name       string
+		resolution Resolution
+		expected   string
+		hasError   bool
+	}{
+		{"skip", Resolution{Action: ResolveSkip}, original, false},
+		{"apply at chosen line", Resolution{Action: ResolveApplyAt, Start: 2}, "one\ntwo\n2\nfour", false},
+		{"retry edited hunk", Resolution{Action: ResolveRetry, Hunk: edited[0]}, "one\n2\nthree\nfour", false},
+		{"abort", Resolution{Action: ResolveAbort}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := &scriptedResolver{resolutions: []Resolution{tt.resolution}}
+			handler := &ContentHandler{resolver: resolver}
+
+			result, err := handler.applyUnifiedDiff("file.txt", original, diff)
+			if tt.hasError {
+				if err == nil {
+					t.Fatalf("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+
+			if len(resolver.conflicts) != 1 {
+				t.Fatalf("Expected 1 conflict, got %d", len(resolver.conflicts))
+			}
+			conflict := resolver.conflicts[0]
+			if conflict.Path != "file.txt" || conflict.Index != 1 || conflict.Start != 1 {
+				t.Errorf("Expected conflict for hunk 1 of file.txt at line 2, got %+v", conflict)
+			}
+		})
+	}
+}
+
+func TestContentHandler_NoResolverFails(t *testing.T) {
+	handler := &ContentHandler{}
+	if _, err := handler.applyUnifiedDiff("file.txt", "one\ntwo", "@@ -2,1 +2,1 @@\n-TWO\n+2"); err == nil {
+		t.Errorf("Expected error without a conflict resolver")
+	}
+}
@@ -3,15 +3,26 @@ package operations
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
 
+	"github.com/developingjames/deltagrams/pkg/diff"
 	"github.com/developingjames/deltagrams/pkg/parser"
 )
 
+// devNull is the path unified diffs use to denote a missing side of a file pair
+const devNull = "/dev/null"
+
 // ContentHandler handles content modification operations using unified diff
-type ContentHandler struct{}
+type ContentHandler struct {
+	preserveModTime bool
+	resolver        ConflictResolver
+	streamThreshold int64
+	warn            warnFunc
+	report          reportFunc
+}
 
 // NewContentHandler creates a new content handler
 func NewContentHandler() OperationHandler {
@@ -27,34 +38,139 @@ func (h *ContentHandler) CanHandle(operation string) bool {
 func (h *ContentHandler) Apply(fs FileSystem, baseDir string, part parser.DeltagramPart) error {
 	filePath := ResolveFilePath(baseDir, part.ContentLocation)
 
+	// Git-style file headers using /dev/null denote creation or deletion
+	oldPath, newPath := parseFileHeaders(strings.Split(part.Content, "\n"))
+	if oldPath == devNull {
+		return h.createFromDiff(fs, filePath, part)
+	}
+	if newPath == devNull {
+		return h.deleteFromDiff(fs, filePath, part)
+	}
+
 	// Check if file exists
-	if _, err := fs.Stat(filePath); os.IsNotExist(err) {
-		return fmt.Errorf("cannot apply content operation to non-existent file: %s (use 'create' operation instead)", part.ContentLocation)
+	info, err := fs.Stat(filePath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("cannot apply content operation to %w: %s (use 'create' operation instead)", ErrFileNotFound, part.ContentLocation)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat existing file: %w", err)
 	}
 
-	// Read existing file
-	existingContent, err := fs.ReadFile(filePath)
+	hunks, err := h.ParseAllHunks(strings.Split(part.Content, "\n"))
 	if err != nil {
-		return fmt.Errorf("failed to read existing file: %v", err)
+		return fmt.Errorf("failed to apply diff: %w", err)
+	}
+
+	if h.shouldStream(info.Size(), hunks) {
+		// Huge files are patched without loading them into memory
+		if err := h.applyStreaming(fs, part.ContentLocation, filePath, hunks, info); err != nil {
+			return withFile(err, part.ContentLocation)
+		}
+	} else {
+		// Read existing file
+		existingContent, err := fs.ReadFile(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to read existing file: %w", err)
+		}
+
+		// Apply unified diff
+		modifiedContent, err := h.applyUnifiedDiff(part.ContentLocation, string(existingContent), part.Content)
+		if err != nil {
+			return fmt.Errorf("failed to apply diff: %w", withFile(err, part.ContentLocation))
+		}
+
+		// Write modified content back, keeping the original permission bits
+		if err := fs.WriteFile(filePath, []byte(modifiedContent), info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to write modified file: %w", err)
+		}
+	}
+
+	if h.preserveModTime {
+		if err := fs.Chtimes(filePath, info.ModTime(), info.ModTime()); err != nil {
+			return fmt.Errorf("failed to restore modification time: %w", err)
+		}
+	}
+
+	h.report.printf("Modified: %s\n", part.ContentLocation)
+	return nil
+}
+
+// createFromDiff creates a new file from a diff whose old side is /dev/null
+func (h *ContentHandler) createFromDiff(fs FileSystem, filePath string, part parser.DeltagramPart) error {
+	if _, err := fs.Stat(filePath); err == nil {
+		return fmt.Errorf("cannot create %s from /dev/null diff: file already exists", part.ContentLocation)
 	}
 
-	// Apply unified diff
-	modifiedContent, err := h.applyUnifiedDiff(string(existingContent), part.Content)
+	hunks, err := h.ParseAllHunks(strings.Split(part.Content, "\n"))
 	if err != nil {
-		return fmt.Errorf("failed to apply diff: %v", err)
+		return err
 	}
 
-	// Write modified content back
-	if err := fs.WriteFile(filePath, []byte(modifiedContent), 0644); err != nil {
-		return fmt.Errorf("failed to write modified file: %v", err)
+	var lines []string
+	finalNewline := true
+	for _, hunk := range hunks {
+		for _, op := range hunk.Operations {
+			if op.Type != '+' {
+				return fmt.Errorf("diff from /dev/null may only contain added lines")
+			}
+			lines = append(lines, op.Content)
+		}
+		finalNewline = finalNewline && !hunk.NewNoNewline
 	}
 
-	fmt.Printf("Modified: %s\n", part.ContentLocation)
+	// Ensure directory exists
+	if err := fs.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if err := fs.WriteFile(filePath, []byte(joinFileLines(lines, finalNewline)), 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	h.report.printf("Created: %s\n", part.ContentLocation)
 	return nil
 }
 
-func (h *ContentHandler) applyUnifiedDiff(original, diff string) (string, error) {
-	originalLines := strings.Split(original, "\n")
+// deleteFromDiff removes a file from a diff whose new side is /dev/null
+func (h *ContentHandler) deleteFromDiff(fs FileSystem, filePath string, part parser.DeltagramPart) error {
+	if _, err := fs.Stat(filePath); os.IsNotExist(err) {
+		return fmt.Errorf("cannot delete %w: %s", ErrFileNotFound, part.ContentLocation)
+	}
+
+	if err := fs.Remove(filePath); err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+
+	h.report.printf("Deleted: %s\n", part.ContentLocation)
+	return nil
+}
+
+// parseFileHeaders extracts the paths from the ---/+++ lines preceding the first hunk
+func parseFileHeaders(diffLines []string) (oldPath, newPath string) {
+	for _, line := range diffLines {
+		if strings.HasPrefix(line, "@@") {
+			break
+		}
+		if strings.HasPrefix(line, "--- ") {
+			oldPath = headerPath(strings.TrimPrefix(line, "--- "))
+		} else if strings.HasPrefix(line, "+++ ") {
+			newPath = headerPath(strings.TrimPrefix(line, "+++ "))
+		}
+	}
+	return oldPath, newPath
+}
+
+// headerPath strips the optional tab-separated timestamp from a file header path
+func headerPath(value string) string {
+	if idx := strings.Index(value, "\t"); idx >= 0 {
+		value = value[:idx]
+	}
+	return strings.TrimSpace(value)
+}
+
+func (h *ContentHandler) applyUnifiedDiff(location, original, diff string) (string, error) {
+	bom, original := cutByteOrderMark(original)
+	originalLines, finalNewline := fileLines(original)
 	diffLines := strings.Split(diff, "\n")
 
 	// Parse all hunks first
@@ -63,8 +179,158 @@ func (h *ContentHandler) applyUnifiedDiff(original, diff string) (string, error)
 		return "", err
 	}
 
-	// Apply hunks sequentially with automatic offset calculation
-	// Each hunk references original file line numbers, but we apply to evolving result
+	// Every hunk references original file line numbers, so all of them can be located
+	// before any is applied
+	placements := make([]hunkPlacement, 0, len(hunks))
+	for index, hunk := range hunks {
+		// Find the best position for this hunk in the original file (with fuzzy matching),
+		// consulting the conflict resolver if it does not match
+		originalStart, skip, err := h.locateHunk(location, originalLines, &hunk, index+1)
+		if err != nil {
+			return "", err
+		}
+		if skip {
+			continue
+		}
+		if offset := originalStart - hunk.expectedStart(); offset != 0 {
+			h.warnFuzzy(location, index+1, originalStart, offset)
+		}
+		placements = append(placements, hunkPlacement{start: originalStart, hunk: hunk})
+	}
+
+	finalNewline = endsWithNewline(finalNewline, placements)
+	if inOrder(placements) {
+		return bom + buildResult(originalLines, placements, finalNewline), nil
+	}
+	result, err := h.applySequentially(originalLines, placements, finalNewline)
+	return bom + result, err
+}
+
+// byteOrderMark is U+FEFF in UTF-8, which some editors write at the start of a file
+const byteOrderMark = "\uFEFF"
+
+// cutByteOrderMark splits a byte order mark from the start of a file's text, so that
+// hunks match the first line without it and it can be written back unchanged
+func cutByteOrderMark(text string) (string, string) {
+	if strings.HasPrefix(text, byteOrderMark) {
+		return byteOrderMark, text[len(byteOrderMark):]
+	}
+	return "", text
+}
+
+// fileLines splits the text of a file into lines. A final newline ends the last line
+// rather than starting an empty one, so an empty file has no lines and a file holding
+// only a newline has one empty line. It also reports whether the text ends with a
+// newline, which an empty file is taken to do.
+func fileLines(text string) ([]string, bool) {
+	lines, noFinalNewline := diff.SplitLines(text)
+	return lines, !noFinalNewline
+}
+
+// joinFileLines is the inverse of fileLines
+func joinFileLines(lines []string, finalNewline bool) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	text := strings.Join(lines, "\n")
+	if finalNewline {
+		text += "\n"
+	}
+	return text
+}
+
+// endsWithNewline reports whether a patched file ends with a newline. It does if the
+// original did, unless a hunk marks its new side as having no final newline; a hunk that
+// marks only its old side that way adds one.
+func endsWithNewline(original bool, placements []hunkPlacement) bool {
+	for _, placement := range placements {
+		if placement.hunk.NewNoNewline {
+			return false
+		}
+	}
+	for _, placement := range placements {
+		if placement.hunk.OldNoNewline {
+			return true
+		}
+	}
+	return original
+}
+
+// warnFuzzy reports a hunk that was applied at an offset from the line it names
+func (h *ContentHandler) warnFuzzy(location string, index, start, offset int) {
+	h.warn.warn(WarnFuzzy, "hunk %d of %s applied at line %d (offset %+d)", index, location, start+1, offset)
+}
+
+// hunkPlacement is a hunk and the 0-based line of the original file where it applies
+type hunkPlacement struct {
+	start int
+	hunk  *ParsedHunk
+}
+
+// inOrder reports whether the placements are sorted and do not overlap
+func inOrder(placements []hunkPlacement) bool {
+	for i := 1; i < len(placements); i++ {
+		previous := placements[i-1]
+		if placements[i].start < previous.start+previous.hunk.Header.OldCount {
+			return false
+		}
+	}
+	return true
+}
+
+// buildResult applies ordered, non-overlapping hunks in a single pass over the original
+// lines, ending the result with a newline when finalNewline is set and it has any lines
+func buildResult(originalLines []string, placements []hunkPlacement, finalNewline bool) string {
+	var b strings.Builder
+	b.Grow(resultSize(originalLines, placements))
+
+	first := true
+	emit := func(line string) {
+		if !first {
+			b.WriteByte('\n')
+		}
+		first = false
+		b.WriteString(line)
+	}
+
+	next := 0 // First original line not yet copied or replaced
+	for _, placement := range placements {
+		for ; next < placement.start; next++ {
+			emit(originalLines[next])
+		}
+		forEachReplacementLine(placement.hunk, originalLines[min(placement.start, len(originalLines)):], emit)
+		next = min(placement.start+placement.hunk.Header.OldCount, len(originalLines))
+	}
+	for ; next < len(originalLines); next++ {
+		emit(originalLines[next])
+	}
+	if !first && finalNewline {
+		b.WriteByte('\n')
+	}
+
+	return b.String()
+}
+
+// resultSize estimates the length of the patched text so it can be built without growing
+func resultSize(originalLines []string, placements []hunkPlacement) int {
+	size := len(originalLines)
+	for _, line := range originalLines {
+		size += len(line)
+	}
+	for _, placement := range placements {
+		for _, op := range placement.hunk.Operations {
+			if op.Type == '+' {
+				size += len(op.Content) + 1
+			}
+		}
+	}
+	return size
+}
+
+// applySequentially applies hunks one after another, tracking where each original line
+// has moved to. It handles hunks that are out of order or overlap, which the single-pass
+// builder cannot.
+func (h *ContentHandler) applySequentially(originalLines []string, placements []hunkPlacement, finalNewline bool) (string, error) {
 	result := make([]string, len(originalLines))
 	copy(result, originalLines)
 
@@ -75,38 +341,94 @@ func (h *ContentHandler) applyUnifiedDiff(original, diff string) (string, error)
 		lineMapping[i] = i
 	}
 
-	for _, hunk := range hunks {
-		// Hunk references original file line numbers
-		originalStart := hunk.Header.OldStart - 1 // Convert to 0-based indexing
-		if originalStart < 0 || originalStart >= len(originalLines) {
-			return "", fmt.Errorf("hunk refers to line %d but original file has %d lines", hunk.Header.OldStart, len(originalLines))
-		}
-
-		// Find the best position for this hunk in the original file (with fuzzy matching)
-		bestPosition, err := h.findBestHunkPosition(originalLines, hunk, originalStart)
-		if err != nil {
-			return "", fmt.Errorf("failed to find position for hunk at line %d: %v", hunk.Header.OldStart, err)
-		}
-
-		// Update originalStart to the best position found
-		originalStart = bestPosition
+	for _, placement := range placements {
+		hunk := placement.hunk
 
 		// Find where this original line is now located in the current result
-		currentStart := lineMapping[originalStart]
+		currentStart := len(result)
+		if placement.start < len(lineMapping) {
+			currentStart = lineMapping[placement.start]
+		}
 
 		// Apply the hunk at the current position
 		newResult, netLineChange, err := h.applyHunkAtPosition(result, hunk, currentStart)
 		if err != nil {
-			return "", fmt.Errorf("failed to apply hunk at line %d: %v", hunk.Header.OldStart, err)
+			return "", fmt.Errorf("failed to apply hunk at line %d: %w", hunk.Header.OldStart, err)
 		}
 
 		// Update line mapping for all lines after the affected region
-		h.updateLineMapping(lineMapping, originalStart, hunk.Header.OldCount, netLineChange)
+		h.updateLineMapping(lineMapping, placement.start, hunk.Header.OldCount, netLineChange)
 
 		result = newResult
 	}
 
-	return strings.Join(result, "\n"), nil
+	return joinFileLines(result, finalNewline), nil
+}
+
+// locateHunk returns the 0-based line of the original file where the hunk applies. When
+// the hunk does not match and a resolver is set, the resolver may force a position,
+// skip the hunk, or replace it with an edited hunk that is located again.
+func (h *ContentHandler) locateHunk(location string, originalLines []string, hunk **ParsedHunk, index int) (int, bool, error) {
+	for {
+		// Hunk references original file line numbers
+		originalStart := (*hunk).expectedStart()
+
+		var err error
+		if !inRange(*hunk, originalStart, len(originalLines)) {
+			err = fmt.Errorf("%w: hunk refers to line %d but original file has %d lines", ErrConflict, (*hunk).Header.OldStart, len(originalLines))
+		} else {
+			var bestPosition int
+			bestPosition, err = h.findBestHunkPosition(originalLines, *hunk, originalStart)
+			if err == nil {
+				return bestPosition, false, nil
+			}
+			err = fmt.Errorf("failed to find position for hunk at line %d: %w", (*hunk).Header.OldStart, err)
+		}
+
+		if h.resolver == nil {
+			return 0, false, err
+		}
+
+		resolution, resolveErr := h.resolver.Resolve(Conflict{
+			Path:  location,
+			Index: index,
+			Hunk:  *hunk,
+			Lines: originalLines,
+			Start: max(0, min(originalStart, len(originalLines))),
+			Err:   err,
+		})
+		if resolveErr != nil {
+			return 0, false, resolveErr
+		}
+
+		switch resolution.Action {
+		case ResolveApplyAt:
+			if resolution.Start < 0 || resolution.Start > len(originalLines) {
+				return 0, false, fmt.Errorf("%w: cannot apply hunk at line %d: file has %d lines", ErrConflict, resolution.Start+1, len(originalLines))
+			}
+			return resolution.Start, false, nil
+		case ResolveSkip:
+			h.report.printf("Skipped hunk %d of %s\n", index, location)
+			return 0, true, nil
+		case ResolveRetry:
+			if resolution.Hunk == nil {
+				return 0, false, fmt.Errorf("conflict resolver returned no hunk to retry")
+			}
+			*hunk = resolution.Hunk
+		default:
+			return 0, false, err
+		}
+	}
+}
+
+// inRange reports whether a hunk expected to start at the 0-based line start falls within
+// a file of n lines. Only an insertion may start after the last line, which for an empty
+// file is line 0.
+func inRange(hunk *ParsedHunk, start, n int) bool {
+	if hunk.isInsertion() {
+		return start >= 0 && start <= n
+	}
+	return start >= 0 && start < n
 }
 
 // HunkHeader represents a parsed unified diff hunk header
@@ -127,12 +449,38 @@ type HunkOperation struct {
 type ParsedHunk struct {
 	Header     *HunkHeader
 	Operations []HunkOperation
+	// OldNoNewline and NewNoNewline are set by a "\ No newline at end of file" line after
+	// the last line of the old or new side, which then ends the file without a newline
+	OldNoNewline bool
+	NewNoNewline bool
 }
 
+// expectedStart returns the 0-based line of the original file where the hunk is expected
+// to start. A hunk with no context or removed lines, a pure insertion as diff -U0 writes
+// it, goes after line OldStart, so that OldStart 0 inserts at the top of the file; any
+// other hunk starts at line OldStart.
+func (hunk *ParsedHunk) expectedStart() int {
+	if hunk.isInsertion() {
+		return hunk.Header.OldStart
+	}
+	return hunk.Header.OldStart - 1
+}
+
+// isInsertion reports whether the hunk only adds lines
+func (hunk *ParsedHunk) isInsertion() bool {
+	for _, op := range hunk.Operations {
+		if op.Type != '+' {
+			return false
+		}
+	}
+	return true
+}
+
+// hunkHeaderRegex matches a hunk header such as @@ -1,5 +1,8 @@
+var hunkHeaderRegex = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
 func (h *ContentHandler) parseHunkHeader(line string) (*HunkHeader, error) {
-	// Example: @@ -1,5 +1,8 @@
-	re := regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
-	matches := re.FindStringSubmatch(line)
+	matches := hunkHeaderRegex.FindStringSubmatch(line)
 
 	if len(matches) < 4 {
 		return nil, fmt.Errorf("invalid hunk header format")
@@ -183,11 +531,11 @@ func (h *ContentHandler) ParseAllHunks(diffLines []string) ([]*ParsedHunk, error
 			// Parse hunk header
 			header, err := h.parseHunkHeader(line)
 			if err != nil {
-				return nil, fmt.Errorf("invalid hunk header: %v", err)
+				return nil, fmt.Errorf("%w: %v", ErrInvalidHunk, err)
 			}
 
 			// Parse hunk operations
-			var operations []HunkOperation
+			hunk := &ParsedHunk{Header: header}
 			i++ // Skip hunk header
 			for i < len(diffLines) && !strings.HasPrefix(diffLines[i], "@@") {
 				hunkLine := diffLines[i]
@@ -196,49 +544,102 @@ func (h *ContentHandler) ParseAllHunks(diffLines []string) ([]*ParsedHunk, error
 					continue
 				}
 
-				if hunkLine[0] == '+' || hunkLine[0] == '-' || hunkLine[0] == ' ' {
-					operations = append(operations, HunkOperation{
+				switch hunkLine[0] {
+				case '+', '-', ' ':
+					hunk.Operations = append(hunk.Operations, HunkOperation{
 						Type:    hunkLine[0],
 						Content: hunkLine[1:],
 					})
+				case '\\':
+					// "\ No newline at end of file" applies to the line before it
+					if n := len(hunk.Operations); n > 0 {
+						last := hunk.Operations[n-1].Type
+						hunk.OldNoNewline = hunk.OldNoNewline || last != '+'
+						hunk.NewNoNewline = hunk.NewNoNewline || last != '-'
+					}
 				}
 				i++
 			}
 			i-- // Adjust for outer loop increment
 
-			hunks = append(hunks, &ParsedHunk{
-				Header:     header,
-				Operations: operations,
-			})
+			hunks = append(hunks, hunk)
 		}
 	}
 
 	return hunks, nil
 }
 
+// RecountHunks rewrites the line counts in the hunk headers of a unified diff to match
+// the hunk bodies, keeping the start lines and any section text after the header. Lines
+// are counted as ParseAllHunks reads them, so empty lines are not counted.
+func RecountHunks(diff string) string {
+	lines := strings.Split(diff, "\n")
+	header := -1
+	oldCount, newCount := 0, 0
+
+	rewrite := func() {
+		if header < 0 {
+			return
+		}
+		matches := hunkHeaderRegex.FindStringSubmatch(lines[header])
+		rest := strings.TrimPrefix(lines[header], matches[0])
+		lines[header] = fmt.Sprintf("@@ -%s,%d +%s,%d @@%s", matches[1], oldCount, matches[3], newCount, rest)
+	}
+
+	for i, line := range lines {
+		if strings.HasPrefix(line, "@@") {
+			rewrite()
+			header, oldCount, newCount = -1, 0, 0
+			if hunkHeaderRegex.MatchString(line) {
+				header = i
+			}
+			continue
+		}
+		if header < 0 || line == "" {
+			continue
+		}
+		switch line[0] {
+		case ' ':
+			oldCount++
+			newCount++
+		case '-':
+			oldCount++
+		case '+':
+			newCount++
+		}
+	}
+	rewrite()
+
+	return strings.Join(lines, "\n")
+}
+
 // validateHunkAgainstOriginal validates that hunk context matches the original file
 func (h *ContentHandler) validateHunkAgainstOriginal(originalLines []string, hunk *ParsedHunk, originalStart int) error {
-	originalPos := originalStart
+	return h.validateHunkAt(originalLines, 0, hunk, originalStart)
+}
+
+// validateHunkAt validates the hunk against lines, a window of the original file whose
+// first line is line first (0-based) of the file; first only affects error messages
+func (h *ContentHandler) validateHunkAt(lines []string, first int, hunk *ParsedHunk, start int) error {
+	originalPos := start
 	for _, op := range hunk.Operations {
 		switch op.Type {
 		case ' ':
 			// Context line - must match original file content
-			if originalPos >= len(originalLines) {
-				return fmt.Errorf("context line extends beyond original file")
+			if originalPos >= len(lines) {
+				return fmt.Errorf("%w: context line extends beyond original file", ErrConflict)
 			}
-			if !linesEqual(originalLines[originalPos], op.Content) {
-				return fmt.Errorf("context mismatch at original line %d: expected %q, got %q",
-					originalPos+1, op.Content, originalLines[originalPos])
+			if !linesEqual(lines[originalPos], op.Content) {
+				return &ErrContextMismatch{Line: first + originalPos + 1, Expected: op.Content, Got: lines[originalPos]}
 			}
 			originalPos++
 		case '-':
 			// Line to be removed - must match original file content
-			if originalPos >= len(originalLines) {
-				return fmt.Errorf("line to remove extends beyond original file")
+			if originalPos >= len(lines) {
+				return fmt.Errorf("%w: line to remove extends beyond original file", ErrConflict)
 			}
-			if !linesEqual(originalLines[originalPos], op.Content) {
-				return fmt.Errorf("removal mismatch at original line %d: expected %q, got %q",
-					originalPos+1, op.Content, originalLines[originalPos])
+			if !linesEqual(lines[originalPos], op.Content) {
+				return &ErrContextMismatch{Line: first + originalPos + 1, Expected: op.Content, Got: lines[originalPos], Removal: true}
 			}
 			originalPos++
 		case '+':
@@ -249,8 +650,19 @@ func (h *ContentHandler) validateHunkAgainstOriginal(originalLines []string, hun
 	return nil
 }
 
-// linesEqual compares two lines ignoring line ending differences
+// linesEqual compares two lines ignoring line ending differences and a byte order mark
+// at the start of either
 func linesEqual(line1, line2 string) bool {
+	if line1 == line2 {
+		return true
+	}
+	line1, line2 = strings.TrimPrefix(line1, byteOrderMark), strings.TrimPrefix(line2, byteOrderMark)
+	if line1 == line2 {
+		return true
+	}
+	if !strings.Contains(line1, "\r") && !strings.Contains(line2, "\r") {
+		return false
+	}
 	normalized1 := strings.ReplaceAll(line1, "\r", "")
 	normalized2 := strings.ReplaceAll(line2, "\r", "")
 	return normalized1 == normalized2
@@ -258,49 +670,10 @@ func linesEqual(line1, line2 string) bool {
 
 // applyHunkAtPosition applies a hunk at the specified current position
 func (h *ContentHandler) applyHunkAtPosition(result []string, hunk *ParsedHunk, currentStart int) ([]string, int, error) {
-	// Handle pure insertions (OldCount=0) specially
-	if hunk.Header.OldCount == 0 {
-		insertLines := make([]string, 0)
-		for _, op := range hunk.Operations {
-			if op.Type == '+' {
-				insertLines = append(insertLines, op.Content)
-			}
-		}
-
-		newResult := make([]string, 0, len(result)+len(insertLines))
-		newResult = append(newResult, result[:currentStart]...)
-		newResult = append(newResult, insertLines...)
-		newResult = append(newResult, result[currentStart:]...)
-
-		return newResult, len(insertLines), nil
-	}
-
-	// For replacements, build the new content
-	// Only include lines that should be in the replacement (within OldCount range)
-	replacementLines := make([]string, 0)
-	oldLinesProcessed := 0
-
-	for _, op := range hunk.Operations {
-		switch op.Type {
-		case ' ':
-			// Context line - only include if within OldCount range
-			if oldLinesProcessed < hunk.Header.OldCount {
-				replacementLines = append(replacementLines, op.Content)
-			}
-			oldLinesProcessed++
-		case '+':
-			// Added line - include if we haven't exceeded OldCount yet
-			if oldLinesProcessed < hunk.Header.OldCount {
-				replacementLines = append(replacementLines, op.Content)
-			} else {
-				// This is an addition after the replacement range - include it
-				replacementLines = append(replacementLines, op.Content)
-			}
-		case '-':
-			// Removed line - do NOT include in result but count toward OldCount
-			oldLinesProcessed++
-		}
-	}
+	var replacementLines []string
+	forEachReplacementLine(hunk, result[min(currentStart, len(result)):], func(line string) {
+		replacementLines = append(replacementLines, line)
+	})
 
 	// Replace exactly OldCount lines with the replacement content
 	endPos := currentStart + hunk.Header.OldCount
@@ -319,35 +692,69 @@ func (h *ContentHandler) applyHunkAtPosition(result []string, hunk *ParsedHunk,
 	return newResult, netChange, nil
 }
 
+// forEachReplacementLine calls fn with each line that replaces the hunk's OldCount
+// original lines: its added lines and the context lines within the OldCount range. A
+// pure insertion (OldCount 0) therefore yields only its added lines. Context lines are
+// taken from original, the lines of the file where the hunk applies, so that bytes the
+// hunk matched loosely, such as a carriage return, are kept as they were.
+func forEachReplacementLine(hunk *ParsedHunk, original []string, fn func(string)) {
+	oldLinesProcessed := 0
+	for _, op := range hunk.Operations {
+		switch op.Type {
+		case ' ':
+			if oldLinesProcessed < hunk.Header.OldCount {
+				if oldLinesProcessed < len(original) {
+					fn(original[oldLinesProcessed])
+				} else {
+					fn(op.Content)
+				}
+			}
+			oldLinesProcessed++
+		case '+':
+			fn(op.Content)
+		case '-':
+			// Removed line - do NOT include in result but count toward OldCount
+			oldLinesProcessed++
+		}
+	}
+}
+
+// fuzzSearchRange is how many lines before and after its stated position a hunk is
+// searched for when it does not match there
+const fuzzSearchRange = 5
+
 // findBestHunkPosition finds the best position for a hunk with fuzzy matching
 func (h *ContentHandler) findBestHunkPosition(originalLines []string, hunk *ParsedHunk, suggestedStart int) (int, error) {
+	return h.findBestHunkPositionAt(originalLines, 0, hunk, suggestedStart)
+}
+
+// findBestHunkPositionAt is findBestHunkPosition for a window of the original file whose
+// first line is line first (0-based) of the file; positions are relative to the window
+func (h *ContentHandler) findBestHunkPositionAt(lines []string, first int, hunk *ParsedHunk, suggestedStart int) (int, error) {
 	// Try the suggested position first (exact match)
-	if h.validateHunkAgainstOriginal(originalLines, hunk, suggestedStart) == nil {
+	if h.validateHunkAt(lines, first, hunk, suggestedStart) == nil {
 		return suggestedStart, nil
 	}
 
 	// If exact match fails, try positions within a reasonable range
-	searchRange := 5 // Search +/- 5 lines around the suggested position
-
-	// Try positions before the suggested start
-	for offset := 1; offset <= searchRange; offset++ {
+	for offset := 1; offset <= fuzzSearchRange; offset++ {
 		// Try position before
 		if suggestedStart-offset >= 0 {
-			if h.validateHunkAgainstOriginal(originalLines, hunk, suggestedStart-offset) == nil {
+			if h.validateHunkAt(lines, first, hunk, suggestedStart-offset) == nil {
 				return suggestedStart - offset, nil
 			}
 		}
 
 		// Try position after
-		if suggestedStart+offset < len(originalLines) {
-			if h.validateHunkAgainstOriginal(originalLines, hunk, suggestedStart+offset) == nil {
+		if suggestedStart+offset < len(lines) {
+			if h.validateHunkAt(lines, first, hunk, suggestedStart+offset) == nil {
 				return suggestedStart + offset, nil
 			}
 		}
 	}
 
 	// If no fuzzy match found, return the original error
-	return suggestedStart, h.validateHunkAgainstOriginal(originalLines, hunk, suggestedStart)
+	return suggestedStart, h.validateHunkAt(lines, first, hunk, suggestedStart)
 }
 
 // updateLineMapping updates the mapping after a hunk is applied
@@ -1,10 +1,14 @@
 package operations
 
 import (
+	"errors"
+	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/developingjames/deltagrams/internal/testutil"
+	"github.com/developingjames/deltagrams/pkg/diff"
 	"github.com/developingjames/deltagrams/pkg/parser"
 )
 
@@ -85,6 +89,9 @@ func TestContentHandler_Apply_FileNotExists(t *testing.T) {
 	if !strings.Contains(err.Error(), expectedMsg) {
 		t.Errorf("Expected error message to contain %q, got: %v", expectedMsg, err)
 	}
+	if !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("Expected ErrFileNotFound, got: %v", err)
+	}
 }
 
 func TestContentHandler_Apply_HunkBeyondFileEnd(t *testing.T) {
@@ -139,6 +146,20 @@ func TestContentHandler_Apply_RemoveLineBeyondFileEnd(t *testing.T) {
 	if !strings.Contains(err.Error(), expectedMsg) {
 		t.Errorf("Expected error message to contain %q, got: %v", expectedMsg, err)
 	}
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("Expected ErrConflict, got: %v", err)
+	}
+}
+
+func TestContentHandler_Apply_InvalidHunkHeader(t *testing.T) {
+	fs := testutil.NewMockFileSystem()
+	fs.AddFile("/base/short.txt", []byte("line 1\nline 2"))
+
+	part := parser.DeltagramPart{ContentLocation: "short.txt", DeltaOperation: "content", Content: "@@ -x +1 @@\n-line 1\n+one"}
+	err := NewContentHandler().Apply(fs, "/base", part)
+	if !errors.Is(err, ErrInvalidHunk) {
+		t.Errorf("Expected ErrInvalidHunk, got: %v", err)
+	}
 }
 
 func TestContentHandler_parseHunkHeader(t *testing.T) {
@@ -271,3 +292,342 @@ func TestContentHandler_Apply_MultiHunk(t *testing.T) {
 		t.Errorf("Expected content:\n%q\n\nGot:\n%q", expected, string(content))
 	}
 }
+
+func TestContentHandler_Apply_DevNullCreate(t *testing.T) {
+	handler := NewContentHandler()
+	fs := testutil.NewMockFileSystem()
+
+	part := parser.DeltagramPart{
+		ContentLocation: "src/new.txt",
+		ContentType:     "application/x-deltagram-content; charset=utf-8; linesep=LF",
+		DeltaOperation:  "content",
+		Content:         "--- /dev/null\n+++ b/src/new.txt\n@@ -0,0 +1,2 @@\n+first line\n+second line",
+	}
+
+	if err := handler.Apply(fs, "/base", part); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	content, err := fs.ReadFile("/base/src/new.txt")
+	if err != nil {
+		t.Fatalf("Failed to read created file: %v", err)
+	}
+
+	expected := "first line\nsecond line\n"
+	if string(content) != expected {
+		t.Errorf("Expected content %q, got %q", expected, string(content))
+	}
+}
+
+func TestContentHandler_Apply_DevNullCreateExisting(t *testing.T) {
+	handler := NewContentHandler()
+	fs := testutil.NewMockFileSystem()
+	fs.AddFile("/base/existing.txt", []byte("keep me"))
+
+	part := parser.DeltagramPart{
+		ContentLocation: "existing.txt",
+		ContentType:     "application/x-deltagram-content; charset=utf-8; linesep=LF",
+		DeltaOperation:  "content",
+		Content:         "--- /dev/null\n+++ b/existing.txt\n@@ -0,0 +1 @@\n+replacement",
+	}
+
+	err := handler.Apply(fs, "/base", part)
+	if err == nil {
+		t.Fatal("Expected error when creating over an existing file, got none")
+	}
+	if !strings.Contains(err.Error(), "file already exists") {
+		t.Errorf("Expected already exists error, got: %v", err)
+	}
+}
+
+func TestContentHandler_Apply_DevNullDelete(t *testing.T) {
+	handler := NewContentHandler()
+	fs := testutil.NewMockFileSystem()
+	fs.AddFile("/base/old.txt", []byte("line 1\nline 2"))
+
+	part := parser.DeltagramPart{
+		ContentLocation: "old.txt",
+		ContentType:     "application/x-deltagram-content; charset=utf-8; linesep=LF",
+		DeltaOperation:  "content",
+		Content:         "--- a/old.txt\n+++ /dev/null\n@@ -1,2 +0,0 @@\n-line 1\n-line 2",
+	}
+
+	if err := handler.Apply(fs, "/base", part); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if fs.FileExists("/base/old.txt") {
+		t.Error("Expected file to be deleted")
+	}
+}
+
+// TestContentHandler_Apply_LineEdgeCases applies diffs between texts that differ in how
+// they end, checking that an empty file has no lines, a final newline ends the last line
+// rather than adding one, and "\ No newline at end of file" markers are honored
+func TestContentHandler_Apply_LineEdgeCases(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		modified string
+	}{
+		{"empty file gains a line", "", "x\n"},
+		{"empty file gains a line without newline", "", "x"},
+		{"empty file gains an empty line", "", "\n"},
+		{"only line removed", "x\n", ""},
+		{"only line without newline removed", "x", ""},
+		{"only empty line removed", "\n", ""},
+		{"one line changed", "a\n", "b\n"},
+		{"one line without newline changed", "a", "b"},
+		{"final newline added", "a", "a\n"},
+		{"final newline removed", "a\n", "a"},
+		{"line appended", "a\n", "a\nb\n"},
+		{"line appended without newline", "a\nb", "a\nb\nc"},
+		{"line inserted at top", "a\nb\n", "z\na\nb\n"},
+		{"line inserted in middle", "a\nb\nd\n", "a\nb\nc\nd\n"},
+		{"empty line prepended", "a\n", "\na\n"},
+		{"one of two empty lines removed", "\n\n", "\n"},
+	}
+
+	for _, tt := range tests {
+		for _, context := range []int{diff.DefaultContext, 0} {
+			t.Run(fmt.Sprintf("%s with %d context", tt.name, context), func(t *testing.T) {
+				fs := testutil.NewMockFileSystem()
+				fs.AddFile("/base/file.txt", []byte(tt.original))
+				part := parser.DeltagramPart{
+					ContentLocation: "file.txt",
+					DeltaOperation:  "content",
+					Content:         diff.Unified("a/file.txt", "b/file.txt", tt.original, tt.modified, context),
+				}
+
+				if err := NewContentHandler().Apply(fs, "/base", part); err != nil {
+					t.Fatalf("Expected no error applying:\n%s\ngot: %v", part.Content, err)
+				}
+				if content, _ := fs.ReadFile("/base/file.txt"); string(content) != tt.modified {
+					t.Errorf("Expected %q, got %q after applying:\n%s", tt.modified, content, part.Content)
+				}
+			})
+		}
+	}
+}
+
+func TestContentHandler_Apply_PreservesBytes(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		diff     string
+		expected string
+	}{
+		{
+			name:     "byte order mark",
+			original: "\uFEFFpackage main\n\nfunc main() {}\n",
+			diff:     "@@ -1,3 +1,3 @@\n package main\n \n-func main() {}\n+func main() { run() }",
+			expected: "\uFEFFpackage main\n\nfunc main() { run() }\n",
+		},
+		{
+			name:     "byte order mark copied into the hunk",
+			original: "\uFEFFpackage main\n",
+			diff:     "@@ -1 +1 @@\n-\uFEFFpackage main\n+package app",
+			expected: "\uFEFFpackage app\n",
+		},
+		{
+			name:     "invalid UTF-8",
+			original: "caf\xe9\n\xff\xfe\nend\n",
+			diff:     "@@ -1,3 +1,3 @@\n caf\xe9\n-\xff\xfe\n+\xfe\xff\n end",
+			expected: "caf\xe9\n\xfe\xff\nend\n",
+		},
+		{
+			name:     "context keeps carriage returns",
+			original: "one\r\ntwo\r\nthree\r\n",
+			diff:     "@@ -1,3 +1,3 @@\n one\n-two\n+TWO\n three",
+			expected: "one\r\nTWO\nthree\r\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := testutil.NewMockFileSystem()
+			fs.AddFile("/base/file.txt", []byte(tt.original))
+			part := parser.DeltagramPart{ContentLocation: "file.txt", DeltaOperation: "content", Content: tt.diff}
+
+			results, err := CheckContentPart(fs, "/base", part)
+			if err != nil || len(results) != 1 || results[0].Status != HunkExact {
+				t.Errorf("Expected the hunk to check as exact, got %+v, %v", results, err)
+			}
+			if err := NewContentHandler().Apply(fs, "/base", part); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if content, _ := fs.ReadFile("/base/file.txt"); string(content) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, content)
+			}
+		})
+	}
+}
+
+func TestContentHandler_Apply_DevNullCreateNoNewline(t *testing.T) {
+	fs := testutil.NewMockFileSystem()
+	part := parser.DeltagramPart{
+		ContentLocation: "new.txt",
+		DeltaOperation:  "content",
+		Content:         "--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1 @@\n+hello\n\\ No newline at end of file",
+	}
+
+	if err := NewContentHandler().Apply(fs, "/base", part); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if content, _ := fs.ReadFile("/base/new.txt"); string(content) != "hello" {
+		t.Errorf("Expected %q, got %q", "hello", content)
+	}
+}
+
+func TestContentHandler_Apply_PreservesMetadata(t *testing.T) {
+	fs := testutil.NewMockFileSystem()
+	fs.AddFile("/base/run.sh", []byte("#!/bin/sh\necho old"))
+	fs.SetMode("/base/run.sh", 0755)
+
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := fs.Chtimes("/base/run.sh", modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := &ContentHandler{preserveModTime: true}
+	part := parser.DeltagramPart{
+		ContentLocation: "run.sh",
+		ContentType:     "application/x-deltagram-content; charset=utf-8; linesep=LF",
+		DeltaOperation:  "content",
+		Content:         "@@ -1,2 +1,2 @@\n #!/bin/sh\n-echo old\n+echo new",
+	}
+
+	if err := handler.Apply(fs, "/base", part); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	info, err := fs.Stat("/base/run.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("Expected mode 0755 to be preserved, got %v", info.Mode().Perm())
+	}
+	if !info.ModTime().Equal(modTime) {
+		t.Errorf("Expected modification time %v to be preserved, got %v", modTime, info.ModTime())
+	}
+}
+
+// benchmarkDiff builds a file of the given number of lines and a diff with one hunk
+// every interval lines that replaces a line and inserts another after it
+func benchmarkDiff(lines, interval int) (string, string) {
+	original := make([]string, lines)
+	for i := range original {
+		original[i] = fmt.Sprintf("line %d of the original file", i+1)
+	}
+
+	var diff strings.Builder
+	for start := 1; start+2 < lines; start += interval {
+		fmt.Fprintf(&diff, "@@ -%d,3 +%d,4 @@\n", start, start)
+		fmt.Fprintf(&diff, " %s\n-%s\n+changed %d\n+inserted %d\n %s\n",
+			original[start-1], original[start], start+1, start+1, original[start+1])
+	}
+	return strings.Join(original, "\n"), diff.String()
+}
+
+func BenchmarkApplyUnifiedDiff(b *testing.B) {
+	sizes := []struct {
+		name            string
+		lines, interval int
+	}{
+		{"100 lines, 10 hunks", 100, 10},
+		{"5000 lines, 50 hunks", 5000, 100},
+		{"20000 lines, 500 hunks", 20000, 40},
+	}
+
+	for _, size := range sizes {
+		original, diff := benchmarkDiff(size.lines, size.interval)
+		b.Run(size.name, func(b *testing.B) {
+			h := &ContentHandler{}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := h.applyUnifiedDiff("file.txt", original, diff); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestContentHandler_applyUnifiedDiff_SinglePassMatchesSequential(t *testing.T) {
+	original, diff := benchmarkDiff(200, 7)
+	h := &ContentHandler{}
+
+	hunks, err := h.ParseAllHunks(strings.Split(diff, "\n"))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	originalLines, finalNewline := fileLines(original)
+	placements := make([]hunkPlacement, len(hunks))
+	for i, hunk := range hunks {
+		placements[i] = hunkPlacement{start: hunk.Header.OldStart - 1, hunk: hunk}
+	}
+	if !inOrder(placements) {
+		t.Fatalf("Expected generated hunks to be in order")
+	}
+
+	sequential, err := h.applySequentially(originalLines, placements, finalNewline)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if single := buildResult(originalLines, placements, finalNewline); single != sequential {
+		t.Errorf("Expected single-pass result to match sequential result")
+	}
+}
+
+func TestContentHandler_applyUnifiedDiff_OutOfOrderHunks(t *testing.T) {
+	h := &ContentHandler{}
+	original := "a\nb\nc\nd\ne\nf"
+	diff := "@@ -5,1 +5,1 @@\n-e\n+E\n@@ -1,2 +1,3 @@\n a\n+a2\n b"
+
+	result, err := h.applyUnifiedDiff("file.txt", original, diff)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	expected := "a\na2\nb\nc\nd\nE\nf"
+	if result != expected {
+		t.Errorf("Expected %q, got %q", expected, result)
+	}
+}
+
+func TestRecountHunks(t *testing.T) {
+	tests := []struct {
+		name     string
+		diff     string
+		expected string
+	}{
+		{
+			name:     "wrong counts",
+			diff:     "--- a.txt\n+++ a.txt\n@@ -1,9 +1,9 @@\n one\n-two\n+TWO\n+2\n three",
+			expected: "--- a.txt\n+++ a.txt\n@@ -1,3 +1,4 @@\n one\n-two\n+TWO\n+2\n three",
+		},
+		{
+			name:     "omitted counts and section text",
+			diff:     "@@ -4 +4 @@ func main() {\n-\told()\n+\tnew()",
+			expected: "@@ -4,1 +4,1 @@ func main() {\n-\told()\n+\tnew()",
+		},
+		{
+			name:     "multiple hunks",
+			diff:     "@@ -1,1 +1,1 @@\n a\n+b\n@@ -10,5 +11,5 @@\n-c",
+			expected: "@@ -1,1 +1,2 @@\n a\n+b\n@@ -10,1 +11,0 @@\n-c",
+		},
+		{
+			name:     "malformed header left alone",
+			diff:     "@@ bad @@\n-x",
+			expected: "@@ bad @@\n-x",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RecountHunks(tt.diff); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -3,14 +3,17 @@ package operations
 import (
 	"fmt"
 	"io"
+	"os"
 	"path/filepath"
-	"strings"
 
 	"github.com/developingjames/deltagrams/pkg/parser"
 )
 
 // CopyHandler handles file copy operations
-type CopyHandler struct{}
+type CopyHandler struct {
+	warn   warnFunc
+	report reportFunc
+}
 
 // NewCopyHandler creates a new copy handler
 func NewCopyHandler() OperationHandler {
@@ -25,20 +28,12 @@ func (h *CopyHandler) CanHandle(operation string) bool {
 // Apply copies a file from source to destination
 func (h *CopyHandler) Apply(fs FileSystem, baseDir string, part parser.DeltagramPart) error {
 	// Parse copy operation content to get source and destination
-	lines := strings.Split(part.Content, "\n")
-	var sourcePath, destPath string
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "---") {
-			sourcePath = strings.TrimSpace(strings.TrimPrefix(line, "---"))
-		} else if strings.HasPrefix(line, "+++") {
-			destPath = strings.TrimSpace(strings.TrimPrefix(line, "+++"))
-		}
+	sourcePath, destPath, legacy, err := transferPaths(part)
+	if err != nil {
+		return err
 	}
-
-	if sourcePath == "" || destPath == "" {
-		return fmt.Errorf("invalid copy operation: missing source or destination path")
+	if legacy {
+		h.warn.warnLegacy(part, destPath)
 	}
 
 	sourceFullPath := ResolveFilePath(baseDir, sourcePath)
@@ -46,14 +41,17 @@ func (h *CopyHandler) Apply(fs FileSystem, baseDir string, part parser.Deltagram
 
 	// Ensure destination directory exists
 	if err := fs.MkdirAll(filepath.Dir(destFullPath), 0755); err != nil {
-		return fmt.Errorf("failed to create destination directory: %v", err)
+		return fmt.Errorf("failed to create destination directory: %w", err)
 	}
 
 	if err := h.copyFile(fs, sourceFullPath, destFullPath); err != nil {
-		return fmt.Errorf("failed to copy file: %v", err)
+		if os.IsNotExist(err) {
+			return fmt.Errorf("cannot copy %w: %s", ErrFileNotFound, sourcePath)
+		}
+		return fmt.Errorf("failed to copy file: %w", err)
 	}
 
-	fmt.Printf("Copied: %s -> %s\n", sourcePath, destPath)
+	h.report.printf("Copied: %s -> %s\n", sourcePath, destPath)
 	return nil
 }
 
@@ -9,7 +9,9 @@ import (
 )
 
 // CreateHandler handles file creation operations
-type CreateHandler struct{}
+type CreateHandler struct {
+	report reportFunc
+}
 
 // NewCreateHandler creates a new create handler
 func NewCreateHandler() OperationHandler {
@@ -24,40 +26,43 @@ func (h *CreateHandler) CanHandle(operation string) bool {
 // Apply creates a new file with the specified content
 func (h *CreateHandler) Apply(fs FileSystem, baseDir string, part parser.DeltagramPart) error {
 	filePath := ResolveFilePath(baseDir, part.ContentLocation)
+	content := createContent(part)
+
+	// Ensure directory exists
+	if err := fs.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
 
-	// Parse create operation content
+	// Write file content
+	if err := fs.WriteFile(filePath, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	h.report.printf("Created: %s\n", part.ContentLocation)
+	return nil
+}
+
+// createContent extracts the file content from a create part, skipping the +++ marker
+func createContent(part parser.DeltagramPart) string {
 	lines := strings.Split(part.Content, "\n")
-	var content string
+	var content []string
 	var contentStarted bool
 
 	for _, line := range lines {
-		if strings.HasPrefix(line, "+++") {
+		if strings.HasPrefix(line, "+++") && !contentStarted {
 			contentStarted = true
 			continue
 		}
 		if contentStarted {
-			if content != "" {
-				content += "\n"
-			}
-			content += line
+			// Blank lines at the start are kept, so a file may begin with one
+			content = append(content, line)
 		}
 	}
 
 	// If no +++ marker found, use entire content
 	if !contentStarted {
-		content = part.Content
-	}
-
-	// Ensure directory exists
-	if err := fs.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
-		return fmt.Errorf("failed to create directory: %v", err)
+		return part.Content
 	}
 
-	// Write file content
-	if err := fs.WriteFile(filePath, []byte(content), 0644); err != nil {
-		return fmt.Errorf("failed to write file: %v", err)
-	}
-
-	fmt.Printf("Created: %s\n", part.ContentLocation)
-	return nil
+	return strings.Join(content, "\n")
 }
@@ -96,3 +96,25 @@ func TestCreateHandler_CanHandle(t *testing.T) {
 		}
 	}
 }
+
+func TestCreateContent_MarkerLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"marker only", "+++ a.txt", ""},
+		{"marker and newline", "+++ a.txt\n", ""},
+		{"leading blank line", "+++ a.txt\n\nbody", "\nbody"},
+		{"later marker-like line", "+++ a.diff\n--- x\n+++ y", "--- x\n+++ y"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			part := parser.DeltagramPart{ContentLocation: "a.txt", DeltaOperation: "create", Content: tt.content}
+			if got := createContent(part); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -3,12 +3,16 @@ package operations
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/developingjames/deltagrams/pkg/parser"
 )
 
 // DeleteHandler handles file deletion operations
-type DeleteHandler struct{}
+type DeleteHandler struct {
+	warn   warnFunc
+	report reportFunc
+}
 
 // NewDeleteHandler creates a new delete handler
 func NewDeleteHandler() OperationHandler {
@@ -22,16 +26,43 @@ func (h *DeleteHandler) CanHandle(operation string) bool {
 
 // Apply deletes the specified file
 func (h *DeleteHandler) Apply(fs FileSystem, baseDir string, part parser.DeltagramPart) error {
+	if err := checkDeleteBody(part); err != nil {
+		return err
+	}
 	filePath := ResolveFilePath(baseDir, part.ContentLocation)
 
 	if err := fs.Remove(filePath); err != nil {
 		if os.IsNotExist(err) {
-			fmt.Printf("Warning: File %s does not exist (already deleted)\n", part.ContentLocation)
+			h.warn.warn(WarnAlreadyDeleted, "File %s does not exist (already deleted)", part.ContentLocation)
 			return nil
 		}
-		return fmt.Errorf("failed to delete file: %v", err)
+		return fmt.Errorf("failed to delete file: %w", err)
 	}
 
-	fmt.Printf("Deleted: %s\n", part.ContentLocation)
+	h.report.printf("Deleted: %s\n", part.ContentLocation)
+	return nil
+}
+
+// checkDeleteBody refuses a delete whose body names a different file than its
+// Content-Location or holds anything other than path markers, since it is then unclear
+// which file the author meant to remove. An empty body is accepted.
+func checkDeleteBody(part parser.DeltagramPart) error {
+	for _, line := range strings.Split(part.Content, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "---"):
+			if path := headerPath(strings.TrimPrefix(line, "---")); !sameLocation(path, part.ContentLocation) {
+				return fmt.Errorf("invalid delete operation: body names %s but Content-Location is %s", path, part.ContentLocation)
+			}
+		case strings.HasPrefix(line, "+++"):
+			// A git-style deletion may name /dev/null as the new side
+			if path := headerPath(strings.TrimPrefix(line, "+++")); path != devNull {
+				return fmt.Errorf("invalid delete operation for %s: unexpected +++ %s marker", part.ContentLocation, path)
+			}
+		default:
+			return fmt.Errorf("invalid delete operation for %s: body may only contain a --- marker naming the file", part.ContentLocation)
+		}
+	}
 	return nil
 }
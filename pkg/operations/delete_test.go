@@ -0,0 +1,53 @@
+package operations
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func TestDeleteHandler_Apply_ValidatesBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		errMsg  string
+	}{
+		{"empty body", "", ""},
+		{"matching marker", "--- file1.txt", ""},
+		{"dot prefix", "--- ./file1.txt", ""},
+		{"git prefix and dev null", "--- a/file1.txt\n+++ /dev/null", ""},
+		{"timestamp", "--- file1.txt\t2024-01-01 00:00:00", ""},
+		{"other file", "--- other.txt", "body names other.txt but Content-Location is file1.txt"},
+		{"destination marker", "--- file1.txt\n+++ file2.txt", "unexpected +++ file2.txt marker"},
+		{"stray content", "--- file1.txt\nkeep me", "body may only contain a --- marker"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := NewMemoryFileSystem()
+			fs.MkdirAll("/base", 0755)
+			fs.WriteFile("/base/file1.txt", []byte("content"), 0644)
+
+			part := parser.DeltagramPart{ContentLocation: "file1.txt", ContentType: "text/plain", DeltaOperation: "delete", Content: tt.content}
+			err := NewApplier(fs).Apply(&parser.Deltagram{Parts: []parser.DeltagramPart{part}}, "/base")
+
+			_, statErr := fs.Stat("/base/file1.txt")
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Fatalf("Expected no error, got: %v", err)
+				}
+				if statErr == nil {
+					t.Error("Expected file1.txt to be deleted")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Fatalf("Expected error containing %q, got: %v", tt.errMsg, err)
+			}
+			if statErr != nil {
+				t.Error("Expected file1.txt to be kept")
+			}
+		})
+	}
+}
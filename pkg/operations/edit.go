@@ -0,0 +1,259 @@
+package operations
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// HunkEdit is a hunk EditHunks passes to its edit function
+type HunkEdit struct {
+	Part  int    // 1-based index of the part in the deltagram
+	Path  string // Content-Location of the part
+	Hunk  int    // 1-based index of the hunk in the part
+	Hunks int    // Number of hunks in the part
+	Text  string // The hunk as unified diff text, starting with its @@ header
+}
+
+// EditHunks returns a copy of the deltagram with each hunk of its content parts replaced
+// by the text edit returns for it. Returning the text unchanged keeps the hunk as it was.
+// In the edited text, lines starting with # are ignored, an empty line is taken as an
+// empty context line, and a line holding only @@ starts a new hunk after the one before
+// it, so a hunk can be split without working out its line numbers. Text with no diff
+// lines drops the hunk, and a part left with no hunks is dropped. Hunk line counts are
+// recomputed, so they never need to be edited by hand.
+func EditHunks(deltagram *parser.Deltagram, edit func(HunkEdit) (string, error)) (*parser.Deltagram, error) {
+	return mapHunks(deltagram, func(index int, part parser.DeltagramPart, chunks []string) ([]string, error) {
+		var result []string
+		for i, chunk := range chunks {
+			text := chunk + "\n"
+			edited, err := edit(HunkEdit{Part: index + 1, Path: part.ContentLocation, Hunk: i + 1, Hunks: len(chunks), Text: text})
+			if err != nil {
+				return nil, err
+			}
+			if edited == text {
+				result = append(result, chunk)
+				continue
+			}
+			parsed, err := parseEditedHunk(edited, chunk)
+			if err != nil {
+				return nil, &parser.PartError{Index: index + 1, Line: part.Line, Err: fmt.Errorf("edited hunk %d of %s: %w", i+1, part.ContentLocation, err)}
+			}
+			result = append(result, parsed...)
+		}
+		return result, nil
+	})
+}
+
+// SplitHunks returns a copy of the deltagram with each hunk of its content parts split
+// wherever two or more context lines separate its changes, the context being shared out
+// between the hunks on either side, along with the number of hunks added
+func SplitHunks(deltagram *parser.Deltagram) (*parser.Deltagram, int, error) {
+	added := 0
+	split, err := mapHunks(deltagram, func(index int, part parser.DeltagramPart, chunks []string) ([]string, error) {
+		var result []string
+		for _, chunk := range chunks {
+			hunks, err := (&ContentHandler{}).ParseAllHunks(strings.Split(chunk, "\n"))
+			// A hunk marking a missing final newline is left whole to keep the marker in place
+			if err != nil || len(hunks) != 1 || strings.Contains(chunk, "\n\\") {
+				result = append(result, chunk)
+				continue
+			}
+			pieces := splitHunk(hunks[0])
+			if len(pieces) == 1 {
+				result = append(result, chunk)
+				continue
+			}
+			for _, piece := range pieces {
+				result = append(result, strings.TrimSuffix(FormatHunk(piece), "\n"))
+			}
+			added += len(pieces) - 1
+		}
+		return result, nil
+	})
+	return split, added, err
+}
+
+// FormatHunk renders a parsed hunk as unified diff text
+func FormatHunk(hunk *ParsedHunk) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", hunk.Header.OldStart, hunk.Header.OldCount, hunk.Header.NewStart, hunk.Header.NewCount)
+	for _, op := range hunk.Operations {
+		b.WriteByte(op.Type)
+		b.WriteString(op.Content)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// mapHunks returns a copy of the deltagram with the hunks of each content part replaced
+// by what fn returns for them. Hunks are passed as text without a trailing newline. A
+// part whose hunks are unchanged is kept as written; one left with no hunks is dropped.
+func mapHunks(deltagram *parser.Deltagram, fn func(index int, part parser.DeltagramPart, chunks []string) ([]string, error)) (*parser.Deltagram, error) {
+	result := *deltagram
+	result.Parts = make([]parser.DeltagramPart, 0, len(deltagram.Parts))
+	for i, part := range deltagram.Parts {
+		if part.DeltaOperation != "content" || isMessagePart(part) {
+			result.Parts = append(result.Parts, part)
+			continue
+		}
+		headers, chunks := hunkChunks(part.Content)
+		mapped, err := fn(i, part, chunks)
+		if err != nil {
+			return nil, err
+		}
+		if len(mapped) == 0 {
+			continue
+		}
+		if strings.Join(mapped, "\n") != strings.Join(chunks, "\n") {
+			part.Content = RecountHunks(strings.Join(append(headers, mapped...), "\n"))
+		}
+		result.Parts = append(result.Parts, part)
+	}
+	return &result, nil
+}
+
+// hunkChunks divides the body of a content part into the lines before its first hunk,
+// such as --- and +++ file headers, and the text of each hunk
+func hunkChunks(content string) ([]string, []string) {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	first := len(lines)
+	for i, line := range lines {
+		if strings.HasPrefix(line, "@@") {
+			first = i
+			break
+		}
+	}
+
+	var chunks []string
+	for i := first; i < len(lines); {
+		end := i + 1
+		for end < len(lines) && !strings.HasPrefix(lines[end], "@@") {
+			end++
+		}
+		chunks = append(chunks, strings.Join(lines[i:end], "\n"))
+		i = end
+	}
+	return lines[:first], chunks
+}
+
+// parseEditedHunk checks the edited text of a hunk and returns the hunks it now holds,
+// filling in the header of the original when the text has none and numbering hunks
+// started by a bare @@
+func parseEditedHunk(text, original string) ([]string, error) {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	if !strings.HasPrefix(lines[0], "@@") {
+		header, _, _ := strings.Cut(original, "\n")
+		lines = append([]string{header}, lines...)
+	}
+
+	h := &ContentHandler{}
+	var chunks [][]string
+	var current *HunkHeader
+	oldLines, newLines := 0, 0
+	for number, line := range lines {
+		switch {
+		case line == "@@" || line == "@@ @@":
+			if current == nil {
+				header, _, _ := strings.Cut(original, "\n")
+				parsed, err := h.parseHunkHeader(header)
+				if err != nil {
+					return nil, err
+				}
+				current = parsed
+			} else {
+				current = &HunkHeader{OldStart: current.OldStart + oldLines, NewStart: current.NewStart + newLines}
+			}
+			oldLines, newLines = 0, 0
+			chunks = append(chunks, []string{fmt.Sprintf("@@ -%d,0 +%d,0 @@", current.OldStart, current.NewStart)})
+			continue
+		case strings.HasPrefix(line, "@@"):
+			parsed, err := h.parseHunkHeader(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w %q", number+1, ErrInvalidHunk, line)
+			}
+			current, oldLines, newLines = parsed, 0, 0
+			chunks = append(chunks, []string{line})
+			continue
+		case line == "":
+			line = " "
+		case !strings.ContainsAny(line[:1], " +-\\"):
+			return nil, fmt.Errorf("line %d is not a diff line: %q", number+1, line)
+		}
+		switch line[0] {
+		case ' ':
+			oldLines++
+			newLines++
+		case '-':
+			oldLines++
+		case '+':
+			newLines++
+		}
+		chunks[len(chunks)-1] = append(chunks[len(chunks)-1], line)
+	}
+
+	result := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		result[i] = strings.Join(chunk, "\n")
+	}
+	return result, nil
+}
+
+// splitHunk divides a hunk at each run of two or more context lines between changes,
+// giving the first half of the run to the hunk before it and the rest to the hunk after
+func splitHunk(hunk *ParsedHunk) []*ParsedHunk {
+	ops := hunk.Operations
+	oldLine, newLine := hunk.Header.OldStart, hunk.Header.NewStart
+	var pieces []*ParsedHunk
+	start, changed := 0, false
+	for i := 0; i < len(ops); {
+		if ops[i].Type != ' ' {
+			changed = true
+			i++
+			continue
+		}
+		end := i
+		for end < len(ops) && ops[end].Type == ' ' {
+			end++
+		}
+		if changed && end < len(ops) && end-i >= 2 {
+			cut := i + (end-i+1)/2
+			piece := newHunk(ops[start:cut], oldLine, newLine)
+			pieces = append(pieces, piece)
+			oldLine += piece.Header.OldCount
+			newLine += piece.Header.NewCount
+			start = cut
+		}
+		i = end
+	}
+	return append(pieces, newHunk(ops[start:], oldLine, newLine))
+}
+
+// newHunk returns a hunk of the given operations starting at the given lines
+func newHunk(ops []HunkOperation, oldStart, newStart int) *ParsedHunk {
+	hunk := &ParsedHunk{Header: &HunkHeader{OldStart: oldStart, NewStart: newStart}, Operations: ops}
+	for _, op := range ops {
+		if op.Type != '+' {
+			hunk.Header.OldCount++
+		}
+		if op.Type != '-' {
+			hunk.Header.NewCount++
+		}
+	}
+	return hunk
+}
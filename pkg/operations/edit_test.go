@@ -0,0 +1,142 @@
+package operations
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func editTestDeltagram(content string) *parser.Deltagram {
+	return &parser.Deltagram{UUID: "0123456789abcdef", Parts: []parser.DeltagramPart{
+		{ContentLocation: "deltagram://message", ContentType: "text/plain", Content: "Refactor"},
+		{ContentLocation: "a.txt", ContentType: "text/plain", DeltaOperation: "content", Content: content},
+	}}
+}
+
+func TestSplitHunks(t *testing.T) {
+	content := "--- a/a.txt\n+++ b/a.txt\n@@ -1,8 +1,8 @@\n one\n-two\n+TWO\n three\n four\n five\n six\n-seven\n+SEVEN\n eight"
+	split, added, err := SplitHunks(editTestDeltagram(content))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if added != 1 {
+		t.Errorf("Expected 1 hunk added, got %d", added)
+	}
+	expected := "--- a/a.txt\n+++ b/a.txt\n" +
+		"@@ -1,4 +1,4 @@\n one\n-two\n+TWO\n three\n four\n" +
+		"@@ -5,4 +5,4 @@\n five\n six\n-seven\n+SEVEN\n eight"
+	if got := split.Parts[1].Content; got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+
+	fs := newEditTestFS(t)
+	if err := NewApplier(fs).Apply(split, "/work"); err != nil {
+		t.Fatalf("Expected the split deltagram to apply, got: %v", err)
+	}
+	data, _ := fs.ReadFile("/work/a.txt")
+	if want := "one\nTWO\nthree\nfour\nfive\nsix\nSEVEN\neight\n"; string(data) != want {
+		t.Errorf("Expected %q, got %q", want, string(data))
+	}
+}
+
+func TestSplitHunks_Unsplittable(t *testing.T) {
+	content := "@@ -1,3 +1,3 @@\n one\n-two\n+TWO\n three"
+	split, added, err := SplitHunks(editTestDeltagram(content))
+	if err != nil || added != 0 || split.Parts[1].Content != content {
+		t.Errorf("Expected the hunk unchanged, got %d added, %q, %v", added, split.Parts[1].Content, err)
+	}
+}
+
+func TestEditHunks(t *testing.T) {
+	content := "@@ -1,4 +1,4 @@\n one\n-two\n+TWO\n three\n four"
+	tests := []struct {
+		name     string
+		edited   string
+		expected string
+		parts    int
+		wantErr  string
+	}{
+		{
+			name:     "unchanged",
+			edited:   "@@ -1,4 +1,4 @@\n one\n-two\n+TWO\n three\n four\n",
+			expected: content,
+			parts:    2,
+		},
+		{
+			name:     "fixed context without counting",
+			edited:   "# a comment\n@@ -1,4 +1,4 @@\n one\n-two\n+TWO\n+2\n three\n\n",
+			expected: "@@ -1,3 +1,4 @@\n one\n-two\n+TWO\n+2\n three",
+			parts:    2,
+		},
+		{
+			name:     "header left out",
+			edited:   " one\n-two\n+2\n",
+			expected: "@@ -1,2 +1,2 @@\n one\n-two\n+2",
+			parts:    2,
+		},
+		{
+			name:     "split with a bare @@",
+			edited:   "@@ -1,4 +1,4 @@\n one\n-two\n+TWO\n@@\n three\n-four\n+FOUR\n",
+			expected: "@@ -1,2 +1,2 @@\n one\n-two\n+TWO\n@@ -3,2 +3,2 @@\n three\n-four\n+FOUR",
+			parts:    2,
+		},
+		{
+			name:     "empty line is blank context",
+			edited:   "@@ -1,4 +1,4 @@\n one\n\n-two\n",
+			expected: "@@ -1,3 +1,2 @@\n one\n \n-two",
+			parts:    2,
+		},
+		{
+			name:   "dropped",
+			edited: "# nothing left\n\n",
+			parts:  1,
+		},
+		{
+			name:    "not a diff line",
+			edited:  "@@ -1,4 +1,4 @@\n one\ntwo\n",
+			wantErr: `line 3 is not a diff line: "two"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen HunkEdit
+			edited, err := EditHunks(editTestDeltagram(content), func(hunk HunkEdit) (string, error) {
+				seen = hunk
+				return tt.edited, nil
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got: %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if seen.Part != 2 || seen.Hunk != 1 || seen.Hunks != 1 || seen.Path != "a.txt" || seen.Text != content+"\n" {
+				t.Errorf("Unexpected hunk passed to edit: %+v", seen)
+			}
+			if len(edited.Parts) != tt.parts {
+				t.Fatalf("Expected %d part(s), got %d", tt.parts, len(edited.Parts))
+			}
+			if tt.parts == 2 && edited.Parts[1].Content != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, edited.Parts[1].Content)
+			}
+		})
+	}
+}
+
+// newEditTestFS returns a file system holding /work/a.txt with eight numbered lines
+func newEditTestFS(t *testing.T) FileSystem {
+	t.Helper()
+	fs := NewMemoryFileSystem()
+	if err := fs.MkdirAll("/work", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.WriteFile("/work/a.txt", []byte("one\ntwo\nthree\nfour\nfive\nsix\nseven\neight\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return fs
+}
@@ -0,0 +1,53 @@
+package operations
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrFileNotFound is returned, wrapped, when an operation needs a file that does not exist
+var ErrFileNotFound = errors.New("non-existent file")
+
+// ErrInvalidHunk is returned, wrapped, when a hunk header cannot be parsed
+var ErrInvalidHunk = errors.New("invalid hunk header")
+
+// ErrConflict is returned, wrapped, when a hunk does not fit the file, such as when it
+// refers to lines beyond the end of the file
+var ErrConflict = errors.New("hunk does not fit the file")
+
+// ValidationError is returned when a deltagram is refused before anything is applied, such
+// as for a path outside the base directory or a part over a size limit
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string { return e.Err.Error() }
+
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+// ErrContextMismatch is returned, wrapped, when a context or removed line of a hunk does
+// not match the file at any position the hunk may be applied at
+type ErrContextMismatch struct {
+	File     string `json:"file,omitempty"` // Target of the operation as written in the deltagram, when known
+	Line     int    `json:"line"`           // 1-based line of the original file
+	Expected string `json:"expected"`       // Line from the hunk
+	Got      string `json:"got"`            // Line in the file
+	Removal  bool   `json:"removal"`        // Whether the line was to be removed rather than kept as context
+}
+
+func (e *ErrContextMismatch) Error() string {
+	kind := "context"
+	if e.Removal {
+		kind = "removal"
+	}
+	return fmt.Sprintf("%s mismatch at original line %d: expected %q, got %q", kind, e.Line, e.Expected, e.Got)
+}
+
+// withFile records location as the file of a context mismatch in err, if there is one
+func withFile(err error, location string) error {
+	var mismatch *ErrContextMismatch
+	if errors.As(err, &mismatch) && mismatch.File == "" {
+		mismatch.File = location
+	}
+	return err
+}
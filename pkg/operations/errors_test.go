@@ -0,0 +1,94 @@
+package operations
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func TestApplier_Apply_ErrorKinds(t *testing.T) {
+	tests := []struct {
+		name     string
+		part     parser.DeltagramPart
+		notFound bool
+		mismatch *ErrContextMismatch
+	}{
+		{
+			name:     "content on missing file",
+			part:     parser.DeltagramPart{ContentLocation: "missing.txt", ContentType: "text/plain", DeltaOperation: "content", Content: "@@ -1,1 +1,1 @@\n-a\n+b"},
+			notFound: true,
+		},
+		{
+			name:     "copy from missing file",
+			part:     parser.DeltagramPart{ContentLocation: "copy.txt", ContentType: "text/plain", DeltaOperation: "copy", Content: "--- missing.txt\n+++ copy.txt"},
+			notFound: true,
+		},
+		{
+			name:     "move from missing file",
+			part:     parser.DeltagramPart{ContentLocation: "moved.txt", ContentType: "text/plain", DeltaOperation: "move", Content: "--- missing.txt\n+++ moved.txt"},
+			notFound: true,
+		},
+		{
+			name:     "context mismatch",
+			part:     parser.DeltagramPart{ContentLocation: "file.txt", ContentType: "text/plain", DeltaOperation: "content", Content: "@@ -2,2 +2,2 @@\n two\n-drei\n+THREE"},
+			mismatch: &ErrContextMismatch{File: "file.txt", Line: 3, Expected: "drei", Got: "three", Removal: true},
+		},
+		{
+			name:     "mismatch while moving",
+			part:     parser.DeltagramPart{ContentLocation: "moved.txt", ContentType: "text/plain", DeltaOperation: "move", Content: "--- file.txt\n+++ moved.txt\n@@ -1,1 +1,1 @@\n uno\n+x"},
+			mismatch: &ErrContextMismatch{File: "file.txt", Line: 1, Expected: "uno", Got: "one"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := NewMemoryFileSystem()
+			fs.MkdirAll("/base", 0755)
+			fs.WriteFile("/base/file.txt", []byte("one\ntwo\nthree"), 0644)
+
+			err := NewApplier(fs).Apply(&parser.Deltagram{Parts: []parser.DeltagramPart{tt.part}}, "/base")
+			if err == nil {
+				t.Fatal("Expected error, got none")
+			}
+			if errors.Is(err, ErrFileNotFound) != tt.notFound {
+				t.Errorf("Expected errors.Is(err, ErrFileNotFound) to be %v, got: %v", tt.notFound, err)
+			}
+
+			var mismatch *ErrContextMismatch
+			if !errors.As(err, &mismatch) {
+				if tt.mismatch != nil {
+					t.Fatalf("Expected ErrContextMismatch, got: %v", err)
+				}
+				return
+			}
+			if tt.mismatch == nil {
+				t.Fatalf("Expected no ErrContextMismatch, got: %v", err)
+			}
+			if *mismatch != *tt.mismatch {
+				t.Errorf("Expected %+v, got %+v", *tt.mismatch, *mismatch)
+			}
+		})
+	}
+}
+
+func TestApplier_Apply_PartError(t *testing.T) {
+	fs := NewMemoryFileSystem()
+	deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{
+		{ContentLocation: "deltagram://message", ContentType: "text/plain", Content: "Summary", Line: 2},
+		{ContentLocation: "ok.txt", ContentType: "text/plain", DeltaOperation: "create", Content: "ok", Line: 7},
+		{ContentLocation: "missing.txt", ContentType: "text/plain", DeltaOperation: "content", Content: "@@ -1,1 +1,1 @@\n-a\n+b", Line: 13},
+	}}
+
+	err := NewApplier(fs).Apply(deltagram, "/base")
+	var partErr *parser.PartError
+	if !errors.As(err, &partErr) {
+		t.Fatalf("Expected PartError, got: %v", err)
+	}
+	if partErr.Index != 3 || partErr.Line != 13 {
+		t.Errorf("Expected part 3 at line 13, got part %d at line %d", partErr.Index, partErr.Line)
+	}
+	if !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("Expected the cause to remain visible, got: %v", err)
+	}
+}
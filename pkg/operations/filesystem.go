@@ -3,14 +3,30 @@ package operations
 import (
 	"io"
 	"os"
+	"path/filepath"
+	"time"
 )
 
+// FileSystemOptions configures the RealFileSystem
+type FileSystemOptions struct {
+	// DisableAtomicWrites writes files in place instead of via a temporary file and rename,
+	// for file systems where rename is not atomic or temporary files are not permitted
+	DisableAtomicWrites bool
+}
+
 // RealFileSystem implements FileSystem using actual OS operations
-type RealFileSystem struct{}
+type RealFileSystem struct {
+	opts FileSystemOptions
+}
 
-// NewRealFileSystem creates a new real file system
+// NewRealFileSystem creates a new real file system with atomic writes enabled
 func NewRealFileSystem() FileSystem {
-	return &RealFileSystem{}
+	return NewRealFileSystemWithOptions(FileSystemOptions{})
+}
+
+// NewRealFileSystemWithOptions creates a new real file system with the given options
+func NewRealFileSystemWithOptions(opts FileSystemOptions) FileSystem {
+	return &RealFileSystem{opts: opts}
 }
 
 func (fs *RealFileSystem) ReadFile(filename string) ([]byte, error) {
@@ -18,7 +34,19 @@ func (fs *RealFileSystem) ReadFile(filename string) ([]byte, error) {
 }
 
 func (fs *RealFileSystem) WriteFile(filename string, data []byte, perm os.FileMode) error {
-	return os.WriteFile(filename, data, perm)
+	if fs.opts.DisableAtomicWrites {
+		return os.WriteFile(filename, data, perm)
+	}
+
+	f, err := newAtomicFile(filename, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.abort()
+		return err
+	}
+	return f.Close()
 }
 
 func (fs *RealFileSystem) Remove(name string) error {
@@ -37,10 +65,113 @@ func (fs *RealFileSystem) Stat(name string) (os.FileInfo, error) {
 	return os.Stat(name)
 }
 
+func (fs *RealFileSystem) Lstat(name string) (os.FileInfo, error) {
+	return os.Lstat(name)
+}
+
+func (fs *RealFileSystem) ReadDir(name string) ([]os.DirEntry, error) {
+	return os.ReadDir(name)
+}
+
+func (fs *RealFileSystem) Chmod(name string, mode os.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+func (fs *RealFileSystem) Truncate(name string, size int64) error {
+	return os.Truncate(name, size)
+}
+
+func (fs *RealFileSystem) Symlink(oldname, newname string) error {
+	return os.Symlink(oldname, newname)
+}
+
+func (fs *RealFileSystem) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+func (fs *RealFileSystem) EvalSymlinks(path string) (string, error) {
+	return filepath.EvalSymlinks(path)
+}
+
 func (fs *RealFileSystem) Open(name string) (io.ReadCloser, error) {
 	return os.Open(name)
 }
 
 func (fs *RealFileSystem) Create(name string) (io.WriteCloser, error) {
-	return os.Create(name)
+	if fs.opts.DisableAtomicWrites {
+		return os.Create(name)
+	}
+	return newAtomicFile(name, 0644)
+}
+
+// atomicFile writes to a temporary file in the target directory and renames it over the
+// target on Close, so readers never observe a partially written file
+type atomicFile struct {
+	*os.File
+	target string
+	perm   os.FileMode
+}
+
+func newAtomicFile(target string, perm os.FileMode) (*atomicFile, error) {
+	// Renaming onto a symlink would replace the link, so write to the file it points to
+	target = resolveSymlinks(target)
+
+	// An existing file keeps its permissions, as it would with an in-place write
+	if info, err := os.Stat(target); err == nil {
+		perm = info.Mode().Perm()
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target)+".deltagram-*")
+	if err != nil {
+		return nil, err
+	}
+
+	return &atomicFile{File: tmp, target: target, perm: perm}, nil
+}
+
+// Close flushes the temporary file to disk and moves it into place
+func (f *atomicFile) Close() error {
+	if err := f.File.Sync(); err != nil {
+		f.abort()
+		return err
+	}
+	if err := f.File.Close(); err != nil {
+		os.Remove(f.File.Name())
+		return err
+	}
+	if err := os.Chmod(f.File.Name(), f.perm); err != nil {
+		os.Remove(f.File.Name())
+		return err
+	}
+	if err := os.Rename(f.File.Name(), f.target); err != nil {
+		os.Remove(f.File.Name())
+		return err
+	}
+	return nil
+}
+
+// resolveSymlinks returns the file that path refers to once symlinks are followed. A
+// symlink whose target does not exist yet resolves to that target, as os.WriteFile would
+// create it; any other path that does not exist is returned unchanged.
+func resolveSymlinks(path string) string {
+	for i := 0; i < 255; i++ {
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			return resolved
+		}
+		link, err := os.Readlink(path)
+		if err != nil {
+			return path
+		}
+		if !filepath.IsAbs(link) {
+			link = filepath.Join(filepath.Dir(path), link)
+		}
+		path = link
+	}
+	return path
+}
+
+// abort discards the temporary file without touching the target
+func (f *atomicFile) abort() {
+	f.File.Close()
+	os.Remove(f.File.Name())
 }
@@ -0,0 +1,150 @@
+package operations
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRealFileSystem_WriteFile_Atomic(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "file.txt")
+	fs := NewRealFileSystem()
+
+	if err := fs.WriteFile(target, []byte("first"), 0644); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := os.Chmod(target, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.WriteFile(target, []byte("second"), 0644); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	content, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "second" {
+		t.Errorf("Expected content %q, got %q", "second", string(content))
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("Expected existing permissions 0755 to be kept, got %v", info.Mode().Perm())
+	}
+
+	// No temporary files may be left behind
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Expected only the target file in the directory, got %d entries", len(entries))
+	}
+}
+
+func TestRealFileSystem_Create_Atomic(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "copy.txt")
+	fs := NewRealFileSystem()
+
+	w, err := fs.Create(target)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	io.WriteString(w, "copied")
+
+	// The target must not exist until the writer is closed
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Error("Expected target to be absent before Close")
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	content, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "copied" {
+		t.Errorf("Expected content %q, got %q", "copied", string(content))
+	}
+}
+
+func TestRealFileSystem_WriteFile_NonAtomic(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "file.txt")
+	fs := NewRealFileSystemWithOptions(FileSystemOptions{DisableAtomicWrites: true})
+
+	if err := fs.WriteFile(target, []byte("data"), 0644); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	content, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "data" {
+		t.Errorf("Expected content %q, got %q", "data", string(content))
+	}
+}
+
+func TestRealFileSystem_WriteFile_ThroughSymlink(t *testing.T) {
+	dir := t.TempDir()
+	real := filepath.Join(dir, "real.txt")
+	link := filepath.Join(dir, "link.txt")
+	if err := os.WriteFile(real, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("real.txt", link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	fs := NewRealFileSystem()
+
+	if err := fs.WriteFile(link, []byte("written"), 0644); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	w, err := fs.Create(link)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	io.WriteString(w, "created")
+	if err := w.Close(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	info, err := os.Lstat(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Expected link.txt to stay a symlink, got mode %v", info.Mode())
+	}
+	content, err := os.ReadFile(real)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "created" {
+		t.Errorf("Expected the symlink's target to hold %q, got %q", "created", string(content))
+	}
+
+	// A dangling symlink gets its target created, as an in-place write would
+	if err := os.Symlink("missing.txt", filepath.Join(dir, "dangling.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.WriteFile(filepath.Join(dir, "dangling.txt"), []byte("new"), 0644); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	content, err = os.ReadFile(filepath.Join(dir, "missing.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "new" {
+		t.Errorf("Expected the dangling symlink's target to hold %q, got %q", "new", string(content))
+	}
+}
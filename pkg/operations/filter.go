@@ -0,0 +1,113 @@
+package operations
+
+import (
+	"fmt"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+	"github.com/developingjames/deltagrams/pkg/pathglob"
+)
+
+// PartFilter selects the file operations of a deltagram to apply. Patterns are
+// slash-separated globs that match a path or any of its parent directories.
+type PartFilter struct {
+	// Only keeps operations whose every written path matches one of these patterns; when
+	// empty, every path is kept
+	Only []string
+	// Exclude drops operations that write a path matching one of these patterns
+	Exclude []string
+	// Ops keeps only these operations, such as content and create; when empty, every
+	// operation is kept
+	Ops []string
+}
+
+// filterOperations are the operations PartFilter.Ops may name
+var filterOperations = map[string]bool{"create": true, "delete": true, "copy": true, "move": true, "content": true, "inline": true, "table": true, "lines": true, "kvset": true, "yaml": true, "go-ast": true, "touch": true, "truncate": true}
+
+// IsEmpty reports whether the filter keeps every part
+func (f PartFilter) IsEmpty() bool {
+	return len(f.Only) == 0 && len(f.Exclude) == 0 && len(f.Ops) == 0
+}
+
+// FilterParts returns a copy of the deltagram with only the file operations the filter
+// keeps, along with the number of operations it dropped. Notes about files whose
+// operations were all dropped go too; the message, requirements, and commands to run
+// stay. A filter that drops every file operation is an error.
+func FilterParts(deltagram *parser.Deltagram, filter PartFilter) (*parser.Deltagram, int, error) {
+	if filter.IsEmpty() {
+		return deltagram, 0, nil
+	}
+	ops := make(map[string]bool, len(filter.Ops))
+	for _, op := range filter.Ops {
+		if canonical, ok := parser.CanonicalOperation(op); ok {
+			op = canonical
+		}
+		if !filterOperations[op] {
+			return nil, 0, fmt.Errorf("unknown operation %q: use create, delete, copy, move, or content", op)
+		}
+		ops[op] = true
+	}
+
+	filtered := *deltagram
+	filtered.Parts = nil
+	kept := make(map[string]bool) // Paths written by a kept operation
+	dropped := 0
+	for _, part := range deltagram.Parts {
+		if part.IsMessage() {
+			continue
+		}
+		if !filter.keeps(part, ops) {
+			dropped++
+			continue
+		}
+		for _, path := range WrittenPaths(part) {
+			kept[path] = true
+		}
+	}
+	if len(kept) == 0 {
+		return nil, dropped, fmt.Errorf("no operations match the filters")
+	}
+
+	for _, part := range deltagram.Parts {
+		switch {
+		case part.IsNote():
+			if !kept[part.NoteTarget()] {
+				continue
+			}
+		case part.IsMessage():
+		case !filter.keeps(part, ops):
+			continue
+		}
+		filtered.Parts = append(filtered.Parts, part)
+	}
+	return &filtered, dropped, nil
+}
+
+// keeps reports whether the filter keeps a file operation
+func (f PartFilter) keeps(part parser.DeltagramPart, ops map[string]bool) bool {
+	op := part.DeltaOperation
+	if op == "" {
+		op = "create"
+	}
+	if len(ops) > 0 && !ops[op] {
+		return false
+	}
+	for _, path := range WrittenPaths(part) {
+		if len(f.Only) > 0 && !matchesAny(f.Only, path) {
+			return false
+		}
+		if matchesAny(f.Exclude, path) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesAny reports whether the path or one of its parents matches any of the patterns
+func matchesAny(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if pathglob.MatchPrefix(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package operations
+
+import (
+	"testing"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func TestFilterParts(t *testing.T) {
+	deltagram := &parser.Deltagram{UUID: "0123456789abcdef", Parts: []parser.DeltagramPart{
+		{ContentLocation: "deltagram://message", ContentType: "text/plain", Content: "Change"},
+		{ContentLocation: "src/a.go", ContentType: "text/plain", DeltaOperation: "content", Content: "@@ -1 +1 @@\n-a\n+A"},
+		{ContentLocation: "src/a_test.go", ContentType: "text/plain", DeltaOperation: "create", Content: "test"},
+		{ContentLocation: parser.NotePrefix + "src/a_test.go", ContentType: "text/plain", Content: "new test"},
+		{ContentLocation: "docs/a.md", ContentType: "text/plain", DeltaOperation: "delete", Content: ""},
+		{ContentLocation: "lib/b.go", ContentType: "text/plain", DeltaOperation: "move", Content: "--- src/b.go\n+++ lib/b.go"},
+	}}
+
+	tests := []struct {
+		name    string
+		filter  PartFilter
+		want    []string
+		dropped int
+		wantErr bool
+	}{
+		{name: "empty", filter: PartFilter{}, want: locations(deltagram)},
+		{
+			name:    "only",
+			filter:  PartFilter{Only: []string{"src/**"}},
+			want:    []string{"deltagram://message", "src/a.go", "src/a_test.go", parser.NotePrefix + "src/a_test.go"},
+			dropped: 2, // The move also writes lib/b.go
+		},
+		{
+			name:    "exclude",
+			filter:  PartFilter{Exclude: []string{"**/*_test.go", "docs"}},
+			want:    []string{"deltagram://message", "src/a.go", "lib/b.go"},
+			dropped: 2,
+		},
+		{
+			name:    "ops",
+			filter:  PartFilter{Ops: []string{"content", "rm"}},
+			want:    []string{"deltagram://message", "src/a.go", "docs/a.md"},
+			dropped: 2,
+		},
+		{name: "unknown operation", filter: PartFilter{Ops: []string{"frobnicate"}}, wantErr: true},
+		{name: "nothing left", filter: PartFilter{Only: []string{"vendor"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, dropped, err := FilterParts(deltagram, tt.filter)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got %v", locations(got))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if dropped != tt.dropped {
+				t.Errorf("Expected %d dropped, got %d", tt.dropped, dropped)
+			}
+			gotLocations := locations(got)
+			if len(gotLocations) != len(tt.want) {
+				t.Fatalf("Expected %v, got %v", tt.want, gotLocations)
+			}
+			for i := range gotLocations {
+				if gotLocations[i] != tt.want[i] {
+					t.Fatalf("Expected %v, got %v", tt.want, gotLocations)
+				}
+			}
+		})
+	}
+}
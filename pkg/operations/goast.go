@@ -0,0 +1,362 @@
+package operations
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	goparser "go/parser"
+	"go/token"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// GoASTChange is one change of a go-ast operation
+type GoASTChange struct {
+	Action string   // add-import, remove-import, add-field, remove-field, or rename-func
+	Args   []string // Arguments of the action, unquoted
+	Line   int      // 1-based line of the part body
+}
+
+// GoASTHandler handles go-ast operations, which are experimental. They change a Go file
+// through its syntax tree rather than its lines, so they apply however the file is
+// currently formatted and wherever its declarations have moved. The body lists one
+// change per line:
+//
+//	add-import "errors"
+//	add-import log "github.com/rs/zerolog/log"
+//	remove-import "io/ioutil"
+//	add-field Config Timeout time.Duration `json:"timeout"`
+//	remove-field Config Legacy
+//	rename-func parseArgs parseFlags
+//
+// Adding an import or field that is already there changes nothing, and so does removing
+// one that is not. rename-func renames a function and its uses in the same file only;
+// other files of the package need parts of their own. The file is formatted with gofmt
+// afterwards, keeping its comments.
+type GoASTHandler struct {
+	preserveModTime bool
+	report          reportFunc
+}
+
+// NewGoASTHandler creates a new go-ast handler
+func NewGoASTHandler() OperationHandler {
+	return &GoASTHandler{}
+}
+
+// CanHandle returns true if this handler can process the given operation
+func (h *GoASTHandler) CanHandle(operation string) bool {
+	return operation == "go-ast"
+}
+
+// Apply makes the changes of a go-ast part to its file
+func (h *GoASTHandler) Apply(fs FileSystem, baseDir string, part parser.DeltagramPart) error {
+	filePath := ResolveFilePath(baseDir, part.ContentLocation)
+	info, err := fs.Stat(filePath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("cannot apply go-ast operation to %w: %s", ErrFileNotFound, part.ContentLocation)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat existing file: %w", err)
+	}
+
+	changes, err := ParseGoASTChanges(part.Content)
+	if err != nil {
+		return err
+	}
+	data, err := fs.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read existing file: %w", err)
+	}
+	modified, err := applyGoASTChanges(string(data), changes)
+	if err != nil {
+		return err
+	}
+	if modified == string(data) {
+		h.report.printf("Unchanged: %s\n", part.ContentLocation)
+		return nil
+	}
+
+	if err := fs.WriteFile(filePath, []byte(modified), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write modified file: %w", err)
+	}
+	if h.preserveModTime {
+		if err := fs.Chtimes(filePath, info.ModTime(), info.ModTime()); err != nil {
+			return fmt.Errorf("failed to restore modification time: %w", err)
+		}
+	}
+
+	h.report.printf("Modified: %s\n", part.ContentLocation)
+	return nil
+}
+
+// goASTArity is how many arguments each action takes; add-import and add-field take an
+// optional extra one
+var goASTArity = map[string]int{
+	"add-import":    1,
+	"remove-import": 1,
+	"add-field":     3,
+	"remove-field":  2,
+	"rename-func":   2,
+}
+
+// ParseGoASTChanges parses the body of a go-ast part
+func ParseGoASTChanges(content string) ([]GoASTChange, error) {
+	var changes []GoASTChange
+	for number, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "//") {
+			continue
+		}
+		action, rest, _ := strings.Cut(trimmed, " ")
+		arity, ok := goASTArity[action]
+		if !ok {
+			return nil, fmt.Errorf("line %d: unknown go-ast action %q", number+1, action)
+		}
+
+		var args []string
+		if action == "add-field" {
+			// The type may contain spaces, so it is the rest of the line after the struct
+			// and field names, less a tag at the end
+			structName, rest, _ := strings.Cut(strings.TrimSpace(rest), " ")
+			fieldName, typ, _ := strings.Cut(strings.TrimSpace(rest), " ")
+			args = []string{structName, fieldName}
+			if typ = strings.TrimSpace(typ); strings.HasSuffix(typ, "`") {
+				if at := strings.IndexByte(typ, '`'); at > 0 && at < len(typ)-1 {
+					args = append(args, strings.TrimSpace(typ[:at]), typ[at:])
+					typ = ""
+				}
+			}
+			if typ != "" {
+				args = append(args, typ)
+			}
+			if structName == "" || fieldName == "" {
+				args = args[:0]
+			}
+		} else {
+			for _, field := range strings.Fields(rest) {
+				if unquoted, err := strconv.Unquote(field); err == nil {
+					field = unquoted
+				}
+				args = append(args, field)
+			}
+		}
+
+		extra := action == "add-import" || action == "add-field"
+		if len(args) != arity && !(extra && len(args) == arity+1) {
+			return nil, fmt.Errorf("line %d: %s takes %d argument(s), got %d", number+1, action, arity, len(args))
+		}
+		changes = append(changes, GoASTChange{Action: action, Args: args, Line: number + 1})
+	}
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("go-ast operation has no changes")
+	}
+	return changes, nil
+}
+
+// goSplice replaces the bytes of a file between two offsets
+type goSplice struct {
+	start, end int
+	text       string
+}
+
+// applyGoASTChanges returns the Go source with the changes made and formatted with gofmt.
+// Each change is found in the syntax tree and made as an edit of the text, so that the
+// comments of the file stay where they are.
+func applyGoASTChanges(source string, changes []GoASTChange) (string, error) {
+	changed := false
+	for _, change := range changes {
+		fset := token.NewFileSet()
+		file, err := goparser.ParseFile(fset, "", source, goparser.ParseComments)
+		if err != nil {
+			return "", fmt.Errorf("cannot parse the file as Go: %v", err)
+		}
+		splices, err := goASTSplices(fset, file, source, change)
+		if err != nil {
+			return "", fmt.Errorf("body line %d: %w", change.Line, err)
+		}
+		// Splices are made from the end so that earlier offsets stay valid
+		for i := len(splices) - 1; i >= 0; i-- {
+			s := splices[i]
+			source = source[:s.start] + s.text + source[s.end:]
+			changed = true
+		}
+	}
+	if !changed {
+		return source, nil
+	}
+
+	formatted, err := format.Source([]byte(source))
+	if err != nil {
+		return "", fmt.Errorf("the changed file is not valid Go: %v", err)
+	}
+	return string(formatted), nil
+}
+
+// goASTSplices returns the edits that make one change, in order of their offsets
+func goASTSplices(fset *token.FileSet, file *ast.File, source string, change GoASTChange) ([]goSplice, error) {
+	offset := func(pos token.Pos) int { return fset.Position(pos).Offset }
+	args := change.Args
+
+	switch change.Action {
+	case "add-import":
+		name, path := "", args[0]
+		if len(args) == 2 {
+			name, path = args[0], args[1]
+		}
+		spec := strconv.Quote(path)
+		if name != "" {
+			spec = name + " " + spec
+		}
+		for _, imp := range file.Imports {
+			if existing, _ := strconv.Unquote(imp.Path.Value); existing == path && (name == "" || imp.Name != nil && imp.Name.Name == name) {
+				return nil, nil
+			}
+		}
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.IMPORT {
+				continue
+			}
+			if !gen.Rparen.IsValid() {
+				// A single import becomes a block of two
+				existing := source[offset(gen.Specs[0].Pos()):offset(gen.Specs[0].End())]
+				return []goSplice{{offset(gen.Pos()), offset(gen.End()), "import (\n\t" + existing + "\n\t" + spec + "\n)"}}, nil
+			}
+			return []goSplice{beforeClosing(source, offset(gen.Rparen), spec)}, nil
+		}
+		at := offset(file.Name.End())
+		return []goSplice{{at, at, "\n\nimport " + spec}}, nil
+
+	case "remove-import":
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.IMPORT {
+				continue
+			}
+			for _, s := range gen.Specs {
+				imp := s.(*ast.ImportSpec)
+				if path, _ := strconv.Unquote(imp.Path.Value); path != args[0] {
+					continue
+				}
+				if len(gen.Specs) == 1 {
+					return []goSplice{wholeLines(source, offset(gen.Pos()), offset(gen.End()))}, nil
+				}
+				return []goSplice{wholeLines(source, offset(imp.Pos()), offset(imp.End()))}, nil
+			}
+		}
+		return nil, nil
+
+	case "add-field", "remove-field":
+		fields, err := structFields(file, args[0])
+		if err != nil {
+			return nil, err
+		}
+		for _, field := range fields.List {
+			for i, ident := range field.Names {
+				if ident.Name != args[1] {
+					continue
+				}
+				if change.Action == "add-field" {
+					return nil, nil
+				}
+				if len(field.Names) == 1 {
+					start := offset(field.Pos())
+					if field.Doc != nil {
+						start = offset(field.Doc.Pos())
+					}
+					return []goSplice{wholeLines(source, start, offset(field.End()))}, nil
+				}
+				// One of several names sharing a type, as in "X, Y int"
+				if i+1 < len(field.Names) {
+					return []goSplice{{offset(ident.Pos()), offset(field.Names[i+1].Pos()), ""}}, nil
+				}
+				return []goSplice{{offset(field.Names[i-1].End()), offset(ident.End()), ""}}, nil
+			}
+		}
+		if change.Action == "remove-field" {
+			return nil, nil
+		}
+
+		text := strings.Join(args[1:], " ")
+		check := "package p\n\ntype _ struct {\n" + text + "\n}\n"
+		if _, err := goparser.ParseFile(token.NewFileSet(), "", check, 0); err != nil {
+			return nil, fmt.Errorf("invalid field %q", text)
+		}
+		return []goSplice{beforeClosing(source, offset(fields.Closing), text)}, nil
+
+	case "rename-func":
+		if !token.IsIdentifier(args[1]) {
+			return nil, fmt.Errorf("%q is not a valid name", args[1])
+		}
+		if file.Scope.Lookup(args[1]) != nil {
+			return nil, fmt.Errorf("the file already declares %s", args[1])
+		}
+		object := file.Scope.Lookup(args[0])
+		if object == nil || object.Kind != ast.Fun {
+			return nil, fmt.Errorf("the file has no function %s", args[0])
+		}
+		var splices []goSplice
+		ast.Inspect(file, func(node ast.Node) bool {
+			if ident, ok := node.(*ast.Ident); ok && ident.Obj == object {
+				splices = append(splices, goSplice{offset(ident.Pos()), offset(ident.End()), args[1]})
+			}
+			return true
+		})
+		return splices, nil
+	}
+	return nil, fmt.Errorf("unknown go-ast action %q", change.Action)
+}
+
+// structFields returns the fields of the struct type declared with name
+func structFields(file *ast.File, name string) (*ast.FieldList, error) {
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.TYPE {
+			continue
+		}
+		for _, s := range gen.Specs {
+			spec := s.(*ast.TypeSpec)
+			if spec.Name.Name != name {
+				continue
+			}
+			st, ok := spec.Type.(*ast.StructType)
+			if !ok {
+				return nil, fmt.Errorf("type %s is not a struct", name)
+			}
+			return st.Fields, nil
+		}
+	}
+	return nil, fmt.Errorf("the file has no type %s", name)
+}
+
+// lineStart returns the offset of the start of the line holding offset
+func lineStart(source string, offset int) int {
+	return strings.LastIndexByte(source[:offset], '\n') + 1
+}
+
+// beforeClosing returns a splice adding line as the last line inside the brace or
+// parenthesis at offset
+func beforeClosing(source string, closing int, line string) goSplice {
+	if at := lineStart(source, closing); strings.TrimSpace(source[at:closing]) == "" {
+		return goSplice{at, at, "\t" + line + "\n"}
+	}
+	return goSplice{closing, closing, "\n" + line + "\n"}
+}
+
+// wholeLines returns a splice removing the text between two offsets, along with the rest
+// of their lines when nothing else is on them
+func wholeLines(source string, start, end int) goSplice {
+	first := lineStart(source, start)
+	last := len(source)
+	if at := strings.IndexByte(source[end:], '\n'); at >= 0 {
+		last = end + at + 1
+	}
+	rest := strings.TrimSpace(source[end:last])
+	if strings.TrimSpace(source[first:start]) == "" && (rest == "" || strings.HasPrefix(rest, "//")) {
+		return goSplice{first, last, ""}
+	}
+	return goSplice{start, end, ""}
+}
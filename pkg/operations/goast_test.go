@@ -0,0 +1,148 @@
+package operations
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/developingjames/deltagrams/internal/testutil"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+const goSource = `package config
+
+import (
+	"fmt"
+	"io/ioutil" // deprecated
+	"os"
+)
+
+// Config holds the settings
+type Config struct {
+	Name   string
+	Legacy bool // to be removed
+	X, Y   int
+}
+
+func parseArgs(args []string) Config {
+	return Config{Name: args[0]}
+}
+
+func Load() Config {
+	fmt.Println(ioutil.Discard, os.Args)
+	return parseArgs(os.Args)
+}
+`
+
+func TestGoASTHandler_Apply(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		body     string
+		expected []string // Lines the result must contain
+		absent   []string // Lines the result must not contain
+	}{
+		{"add import", goSource, `add-import "errors"`,
+			[]string{"\t\"errors\"\n\t\"fmt\"\n"}, nil},
+		{"add named import", goSource, `add-import log "github.com/rs/zerolog/log"`,
+			[]string{"\tlog \"github.com/rs/zerolog/log\"\n"}, nil},
+		{"add import to single import", "package p\n\nimport \"fmt\"\n\nvar _ = fmt.Sprint\n", `add-import "os"`,
+			[]string{"import (\n\t\"fmt\"\n\t\"os\"\n)\n"}, nil},
+		{"add first import", "package p\n", `add-import "os"`,
+			[]string{"package p\n\nimport \"os\"\n"}, nil},
+		{"remove import", goSource, `remove-import "io/ioutil"`,
+			[]string{"\t\"fmt\"\n\t\"os\"\n)"}, []string{"ioutil\"", "deprecated"}},
+		{"remove only import", "package p\n\nimport \"os\"\n\nvar x = 1\n", `remove-import "os"`,
+			[]string{"package p\n\nvar x = 1\n"}, []string{"import"}},
+		{"add field", goSource, "add-field Config Timeout time.Duration `json:\"timeout\"`",
+			[]string{"\tTimeout time.Duration `json:\"timeout\"`\n}"}, nil},
+		{"add field with spaced type", goSource, "add-field Config Hook func(name string) error",
+			[]string{"\tHook   func(name string) error\n"}, nil},
+		{"add field to empty struct", "package p\n\ntype T struct{}\n", "add-field T A int",
+			[]string{"type T struct {\n\tA int\n}\n"}, nil},
+		{"remove field", goSource, "remove-field Config Legacy",
+			[]string{"\tName string\n\tX, Y int\n"}, []string{"Legacy", "to be removed"}},
+		{"remove one of several names", goSource, "remove-field Config X",
+			[]string{"\tY      int\n"}, []string{"X,"}},
+		{"rename function", goSource, "rename-func parseArgs parseFlags",
+			[]string{"func parseFlags(args []string) Config {", "return parseFlags(os.Args)"}, []string{"parseArgs"}},
+		{"reformats", "package p\nfunc  f( ) {  }\n", "add-import \"os\"\nadd-import \"fmt\"",
+			[]string{"import (\n\t\"fmt\"\n\t\"os\"\n)\n", "func f() {}\n"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := testutil.NewMockFileSystem()
+			fs.AddFile("/base/config.go", []byte(tt.original))
+
+			part := parser.DeltagramPart{ContentLocation: "config.go", DeltaOperation: "go-ast", Content: tt.body}
+			if err := (&GoASTHandler{report: func(string, ...interface{}) {}}).Apply(fs, "/base", part); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			data, _ := fs.ReadFile("/base/config.go")
+			for _, expected := range tt.expected {
+				if !strings.Contains(string(data), expected) {
+					t.Errorf("Expected the file to contain %q, got:\n%s", expected, data)
+				}
+			}
+			for _, absent := range tt.absent {
+				if strings.Contains(string(data), absent) {
+					t.Errorf("Expected the file not to contain %q, got:\n%s", absent, data)
+				}
+			}
+		})
+	}
+}
+
+func TestGoASTHandler_Apply_Unchanged(t *testing.T) {
+	fs := testutil.NewMockFileSystem()
+	fs.AddFile("/base/config.go", []byte(goSource))
+
+	var reported string
+	handler := &GoASTHandler{report: func(format string, args ...interface{}) { reported = format }}
+	body := "add-import \"fmt\"\nremove-import \"net/http\"\nadd-field Config Name int\nremove-field Config Missing"
+	part := parser.DeltagramPart{ContentLocation: "config.go", DeltaOperation: "go-ast", Content: body}
+	if err := handler.Apply(fs, "/base", part); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if data, _ := fs.ReadFile("/base/config.go"); string(data) != goSource {
+		t.Errorf("Expected the file to be unchanged, got:\n%s", data)
+	}
+	if reported != "Unchanged: %s\n" {
+		t.Errorf("Expected the part to be reported unchanged, got %q", reported)
+	}
+}
+
+func TestGoASTHandler_Apply_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		body     string
+		expected string
+	}{
+		{"unknown action", goSource, "add-method Config String", "unknown go-ast action"},
+		{"wrong arguments", goSource, "rename-func parseArgs", "takes 2 argument(s), got 1"},
+		{"missing type", goSource, "add-field Options A int", "no type Options"},
+		{"not a struct", "package p\n\ntype ID int\n", "add-field ID A int", "is not a struct"},
+		{"invalid field", goSource, "add-field Config A int int", "invalid field"},
+		{"missing function", goSource, "rename-func parse run", "no function parse"},
+		{"rename to existing", goSource, "rename-func parseArgs Load", "already declares Load"},
+		{"not Go", "not go at all", `add-import "os"`, "cannot parse the file as Go"},
+		{"empty body", goSource, "// nothing", "has no changes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := testutil.NewMockFileSystem()
+			fs.AddFile("/base/config.go", []byte(tt.original))
+
+			part := parser.DeltagramPart{ContentLocation: "config.go", DeltaOperation: "go-ast", Content: tt.body}
+			err := NewGoASTHandler().Apply(fs, "/base", part)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Fatalf("Expected error containing %q, got: %v", tt.expected, err)
+			}
+			if data, _ := fs.ReadFile("/base/config.go"); string(data) != tt.original {
+				t.Errorf("Expected the file to be unchanged, got %q", data)
+			}
+		})
+	}
+}
@@ -0,0 +1,300 @@
+package operations
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// inlineExcerpt is how many characters of a long line are shown on either side of a
+// mismatched inline edit, so that an error about minified JSON stays readable
+const inlineExcerpt = 40
+
+// InlineEdit is one character-level edit of an inline operation
+type InlineEdit struct {
+	Line   int    // 1-based line of the original file
+	Column int    // 1-based character where the edit starts, or 0 to find Old in the line
+	End    int    // Last character replaced, inclusive, when a column range is given
+	Old    string // Text replaced, from the - line
+	HasOld bool   // Whether the edit has a - line
+	New    string // Text put in its place, from the + line
+}
+
+// InlineHandler handles inline operations, which change text within lines rather than
+// replacing whole lines. Each edit is a header naming the line, and optionally the
+// column or column range, followed by the text removed and the text added:
+//
+//	@@ 1:17
+//	-"version":"1.2.3"
+//	+"version":"1.2.4"
+//
+// Columns count characters from 1. Without a column, the removed text must appear
+// exactly once in the line; with one, it is expected there but is found anywhere it
+// appears exactly once, with a warning. A column range (@@ 1:17-33) replaces those
+// characters and needs no - line. Everything outside the edited text, including line
+// endings, is kept byte for byte.
+type InlineHandler struct {
+	preserveModTime bool
+	warn            warnFunc
+	report          reportFunc
+}
+
+// NewInlineHandler creates a new inline handler
+func NewInlineHandler() OperationHandler {
+	return &InlineHandler{}
+}
+
+// CanHandle returns true if this handler can process the given operation
+func (h *InlineHandler) CanHandle(operation string) bool {
+	return operation == "inline"
+}
+
+// Apply makes the edits of an inline part to its file
+func (h *InlineHandler) Apply(fs FileSystem, baseDir string, part parser.DeltagramPart) error {
+	filePath := ResolveFilePath(baseDir, part.ContentLocation)
+	info, err := fs.Stat(filePath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("cannot apply inline operation to %w: %s", ErrFileNotFound, part.ContentLocation)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat existing file: %w", err)
+	}
+
+	edits, err := ParseInlineEdits(part.Content)
+	if err != nil {
+		return err
+	}
+	data, err := fs.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read existing file: %w", err)
+	}
+	modified, err := applyInlineEdits(string(data), edits, func(format string, args ...interface{}) {
+		h.warn.warn(WarnFuzzy, "%s: "+format, append([]interface{}{part.ContentLocation}, args...)...)
+	})
+	if err != nil {
+		return withFile(err, part.ContentLocation)
+	}
+
+	if err := fs.WriteFile(filePath, []byte(modified), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write modified file: %w", err)
+	}
+	if h.preserveModTime {
+		if err := fs.Chtimes(filePath, info.ModTime(), info.ModTime()); err != nil {
+			return fmt.Errorf("failed to restore modification time: %w", err)
+		}
+	}
+
+	h.report.printf("Modified: %s\n", part.ContentLocation)
+	return nil
+}
+
+// ParseInlineEdits parses the body of an inline part
+func ParseInlineEdits(content string) ([]InlineEdit, error) {
+	var edits []InlineEdit
+	var current *InlineEdit
+	hasNew := false
+	for number, line := range strings.Split(content, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			if current != nil {
+				if err := checkInlineEdit(*current, hasNew); err != nil {
+					return nil, err
+				}
+				edits = append(edits, *current)
+			}
+			edit, err := parseInlineHeader(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", number+1, err)
+			}
+			current, hasNew = &edit, false
+		case strings.TrimSpace(line) == "":
+			continue
+		case current == nil:
+			return nil, fmt.Errorf("line %d: expected an @@ line header before %q", number+1, line)
+		case line[0] == '-' && !current.HasOld:
+			current.Old, current.HasOld = line[1:], true
+		case line[0] == '+' && !hasNew:
+			current.New, hasNew = line[1:], true
+		default:
+			return nil, fmt.Errorf("line %d: expected one - line and one + line after @@ %d, got %q", number+1, current.Line, line)
+		}
+	}
+	if current == nil {
+		return nil, fmt.Errorf("inline operation has no edits")
+	}
+	if err := checkInlineEdit(*current, hasNew); err != nil {
+		return nil, err
+	}
+	return append(edits, *current), nil
+}
+
+// parseInlineHeader parses an edit header: @@ LINE, @@ LINE:COLUMN, or @@ LINE:START-END
+func parseInlineHeader(header string) (InlineEdit, error) {
+	spec := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(strings.TrimPrefix(header, "@@")), "@@"))
+	lineSpec, columnSpec, hasColumn := strings.Cut(spec, ":")
+
+	var edit InlineEdit
+	var err error
+	if edit.Line, err = strconv.Atoi(lineSpec); err != nil || edit.Line < 1 {
+		return edit, fmt.Errorf("invalid inline edit header %q: expected @@ LINE, @@ LINE:COLUMN, or @@ LINE:START-END", header)
+	}
+	if !hasColumn {
+		return edit, nil
+	}
+	start, end, isRange := strings.Cut(columnSpec, "-")
+	if edit.Column, err = strconv.Atoi(start); err != nil || edit.Column < 1 {
+		return edit, fmt.Errorf("invalid column in inline edit header %q", header)
+	}
+	if isRange {
+		if edit.End, err = strconv.Atoi(end); err != nil || edit.End < edit.Column {
+			return edit, fmt.Errorf("invalid column range in inline edit header %q", header)
+		}
+	}
+	return edit, nil
+}
+
+// checkInlineEdit reports an edit that does not say what to change
+func checkInlineEdit(edit InlineEdit, hasNew bool) error {
+	switch {
+	case !edit.HasOld && !hasNew:
+		return fmt.Errorf("inline edit of line %d has no - or + line", edit.Line)
+	case edit.Column == 0 && edit.Old == "":
+		return fmt.Errorf("inline edit of line %d needs a column or the text it removes", edit.Line)
+	case edit.HasOld && edit.End > 0 && utf8.RuneCountInString(edit.Old) != edit.End-edit.Column+1:
+		return fmt.Errorf("inline edit of line %d removes %d characters but its range %d-%d covers %d",
+			edit.Line, utf8.RuneCountInString(edit.Old), edit.Column, edit.End, edit.End-edit.Column+1)
+	}
+	return nil
+}
+
+// inlineSpan is an edit resolved to the byte offsets it replaces within its line
+type inlineSpan struct {
+	start, end int
+	text       string
+}
+
+// applyInlineEdits returns text with the edits made to it. Line and column numbers refer
+// to the original text, so edits do not shift one another.
+func applyInlineEdits(text string, edits []InlineEdit, warn func(format string, args ...interface{})) (string, error) {
+	bom, rest := cutByteOrderMark(text)
+	lines, finalNewline := fileLines(rest)
+
+	spans := make(map[int][]inlineSpan)
+	for i, edit := range edits {
+		if edit.Line > len(lines) {
+			return "", fmt.Errorf("inline edit %d names line %d, but the file has %d line(s)", i+1, edit.Line, len(lines))
+		}
+		// A carriage return ending the line is not part of the text that can be edited
+		line := strings.TrimSuffix(lines[edit.Line-1], "\r")
+		span, err := resolveInlineEdit(line, edit, i+1, warn)
+		if err != nil {
+			return "", err
+		}
+		spans[edit.Line-1] = append(spans[edit.Line-1], span)
+	}
+
+	for index, lineSpans := range spans {
+		sort.SliceStable(lineSpans, func(i, j int) bool { return lineSpans[i].start < lineSpans[j].start })
+		line := lines[index]
+		var b strings.Builder
+		pos := 0
+		for _, span := range lineSpans {
+			if span.start < pos {
+				return "", fmt.Errorf("inline edits of line %d overlap", index+1)
+			}
+			b.WriteString(line[pos:span.start])
+			b.WriteString(span.text)
+			pos = span.end
+		}
+		b.WriteString(line[pos:])
+		lines[index] = b.String()
+	}
+	return bom + joinFileLines(lines, finalNewline), nil
+}
+
+// resolveInlineEdit finds the bytes of line the edit replaces
+func resolveInlineEdit(line string, edit InlineEdit, index int, warn func(format string, args ...interface{})) (inlineSpan, error) {
+	span := inlineSpan{text: edit.New}
+	if edit.Column > 0 {
+		start, ok := byteOffset(line, edit.Column-1)
+		if !ok {
+			return span, fmt.Errorf("inline edit %d names column %d of line %d, which has %d character(s)",
+				index, edit.Column, edit.Line, utf8.RuneCountInString(line))
+		}
+		span.start, span.end = start, start
+		switch {
+		case edit.End > 0:
+			end, ok := byteOffset(line, edit.End)
+			if !ok {
+				return span, fmt.Errorf("inline edit %d names columns %d-%d of line %d, which has %d character(s)",
+					index, edit.Column, edit.End, edit.Line, utf8.RuneCountInString(line))
+			}
+			span.end = end
+		case edit.HasOld:
+			span.end = start + len(edit.Old)
+		}
+		if !edit.HasOld || (span.end <= len(line) && line[span.start:span.end] == edit.Old) {
+			return span, nil
+		}
+	}
+
+	// The removed text is looked for in the whole line when it is not where the header
+	// says, and is used only where it appears exactly once
+	if strings.Count(line, edit.Old) != 1 {
+		got := line
+		if edit.Column > 0 {
+			got = excerpt(line, span.start)
+		}
+		return span, &ErrContextMismatch{Line: edit.Line, Expected: edit.Old, Got: got, Removal: true}
+	}
+	start := strings.Index(line, edit.Old)
+	if edit.Column > 0 {
+		warn("inline edit %d of line %d matched at column %d instead of %d", index, edit.Line, utf8.RuneCountInString(line[:start])+1, edit.Column)
+	}
+	span.start, span.end = start, start+len(edit.Old)
+	return span, nil
+}
+
+// byteOffset returns the byte offset in line of the character at the 0-based index,
+// which may be the end of the line
+func byteOffset(line string, index int) (int, bool) {
+	offset := 0
+	for i := 0; i < index; i++ {
+		if offset >= len(line) {
+			return 0, false
+		}
+		_, size := utf8.DecodeRuneInString(line[offset:])
+		offset += size
+	}
+	return offset, true
+}
+
+// excerpt returns the part of a long line around the byte offset
+func excerpt(line string, offset int) string {
+	if utf8.RuneCountInString(line) <= 2*inlineExcerpt {
+		return line
+	}
+	start, end := offset, offset
+	for i := 0; i < inlineExcerpt && start > 0; i++ {
+		_, size := utf8.DecodeLastRuneInString(line[:start])
+		start -= size
+	}
+	for i := 0; i < inlineExcerpt && end < len(line); i++ {
+		_, size := utf8.DecodeRuneInString(line[end:])
+		end += size
+	}
+	result := line[start:end]
+	if start > 0 {
+		result = "..." + result
+	}
+	if end < len(line) {
+		result += "..."
+	}
+	return result
+}
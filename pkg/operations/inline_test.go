@@ -0,0 +1,118 @@
+package operations
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/developingjames/deltagrams/internal/testutil"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func TestInlineHandler_Apply(t *testing.T) {
+	minified := `{"name":"app","version":"1.2.3","deps":{"left-pad":"1.0.0"}}`
+
+	tests := []struct {
+		name     string
+		original string
+		body     string
+		expected string
+		fuzzy    bool
+	}{
+		{"column", minified + "\n", "@@ 1:15\n-\"version\":\"1.2.3\"\n+\"version\":\"1.2.4\"", strings.Replace(minified, "1.2.3", "1.2.4", 1) + "\n", false},
+		{"search without column", minified, "@@ 1\n-left-pad\n+right-pad", strings.Replace(minified, "left-pad", "right-pad", 1), false},
+		{"column range", minified + "\n", "@@ 1:10-12\n+web", strings.Replace(minified, "app", "web", 1) + "\n", false},
+		{"insertion", "ab\n", "@@ 1:2\n+X", "aXb\n", false},
+		{"insertion at end of line", "ab\n", "@@ 1:3\n+c", "abc\n", false},
+		{"deletion", "a, b, c\n", "@@ 1:2\n-, b", "a, c\n", false},
+		{"shifted column", minified + "\n", "@@ 1:5\n-\"1.2.3\"\n+\"2.0.0\"", strings.Replace(minified, "1.2.3", "2.0.0", 1) + "\n", true},
+		{"several edits use original columns", "one two three\n", "@@ 1:1\n-one\n+1\n@@ 1:5\n-two\n+2\n@@ 1:9\n-three\n+3", "1 2 3\n", false},
+		{"characters not bytes", "héllo wörld\n", "@@ 1:7\n-wörld\n+world", "héllo world\n", false},
+		{"keeps CRLF and other lines", "first\r\nsecond\r\n", "@@ 2:1\n-second\n+2nd", "first\r\n2nd\r\n", false},
+		{"keeps byte order mark", "\ufeffkey=1\n", "@@ 1:5\n-1\n+2", "\ufeffkey=2\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := testutil.NewMockFileSystem()
+			fs.AddFile("/base/file.json", []byte(tt.original))
+			var warnings []string
+			handler := &InlineHandler{warn: func(kind, message string) { warnings = append(warnings, message) }, report: func(string, ...interface{}) {}}
+
+			part := parser.DeltagramPart{ContentLocation: "file.json", DeltaOperation: "inline", Content: tt.body}
+			if err := handler.Apply(fs, "/base", part); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if data, _ := fs.ReadFile("/base/file.json"); string(data) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, data)
+			}
+			if (len(warnings) > 0) != tt.fuzzy {
+				t.Errorf("Expected fuzzy warning %v, got %v", tt.fuzzy, warnings)
+			}
+		})
+	}
+}
+
+func TestInlineHandler_Apply_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		body     string
+		expected string
+	}{
+		{"text not found", "a b c\n", "@@ 1\n-d\n+e", "removal mismatch"},
+		{"text ambiguous", "a a\n", "@@ 1\n-a\n+b", "removal mismatch"},
+		{"line past end", "a\n", "@@ 3:1\n+b", "the file has 1 line(s)"},
+		{"column past end", "ab\n", "@@ 1:9\n+c", "which has 2 character(s)"},
+		{"overlap", "abcdef\n", "@@ 1:1\n-abc\n+x\n@@ 1:2\n-bcd\n+y", "overlap"},
+		{"range and removed text disagree", "abc\n", "@@ 1:1-2\n-abc\n+x", "covers 2"},
+		{"no header", "a\n", "-a\n+b", "expected an @@ line header"},
+		{"invalid header", "a\n", "@@ x:1\n+b", "invalid inline edit header"},
+		{"no change", "a\n", "@@ 1:1", "has no - or + line"},
+		{"two removed lines", "a\n", "@@ 1\n-a\n-b\n+c", "expected one - line and one + line"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := testutil.NewMockFileSystem()
+			fs.AddFile("/base/file.txt", []byte(tt.original))
+
+			part := parser.DeltagramPart{ContentLocation: "file.txt", DeltaOperation: "inline", Content: tt.body}
+			err := (&InlineHandler{report: func(string, ...interface{}) {}}).Apply(fs, "/base", part)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Fatalf("Expected error containing %q, got: %v", tt.expected, err)
+			}
+			if data, _ := fs.ReadFile("/base/file.txt"); string(data) != tt.original {
+				t.Errorf("Expected the file to be unchanged, got %q", data)
+			}
+		})
+	}
+}
+
+func TestInlineHandler_Apply_MismatchExcerpt(t *testing.T) {
+	line := strings.Repeat("x", 100) + "needle" + strings.Repeat("y", 100)
+	fs := testutil.NewMockFileSystem()
+	fs.AddFile("/base/min.js", []byte(line))
+
+	part := parser.DeltagramPart{ContentLocation: "min.js", DeltaOperation: "inline", Content: "@@ 1:101\n-haystack\n+pin"}
+	err := NewInlineHandler().Apply(fs, "/base", part)
+
+	var mismatch *ErrContextMismatch
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Expected a context mismatch, got: %v", err)
+	}
+	if mismatch.File != "min.js" || mismatch.Line != 1 {
+		t.Errorf("Expected the mismatch to name min.js line 1, got %+v", mismatch)
+	}
+	if !strings.HasPrefix(mismatch.Got, "...") || !strings.Contains(mismatch.Got, "needle") || len(mismatch.Got) > 90 {
+		t.Errorf("Expected an excerpt around column 101, got %q", mismatch.Got)
+	}
+}
+
+func TestInlineHandler_Apply_MissingFile(t *testing.T) {
+	part := parser.DeltagramPart{ContentLocation: "missing.txt", DeltaOperation: "inline", Content: "@@ 1\n-a\n+b"}
+	err := NewInlineHandler().Apply(testutil.NewMockFileSystem(), "/base", part)
+	if !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("Expected ErrFileNotFound, got: %v", err)
+	}
+}
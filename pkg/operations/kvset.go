@@ -0,0 +1,307 @@
+package operations
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// KeyValueChange is one change of a kvset operation
+type KeyValueChange struct {
+	Section string // INI section, or "" for keys before any section
+	Key     string
+	Value   string
+	Remove  bool
+}
+
+// KVSetHandler handles kvset operations, which set and remove keys in .env, .ini, and
+// .properties files by name instead of by line number. The body lists one change per
+// line:
+//
+//	[database]
+//	host=db.internal
+//	-password
+//
+// KEY=value (or KEY: value) sets a key, replacing its value where it is or adding it after
+// the last key of its section, and -KEY removes it. A [section] line makes the changes
+// after it apply to that INI section, which is added at the end if the file has none.
+// Comments, blank lines, the order of keys, and the spacing and separator of each line
+// are kept; an export prefix in a .env file is kept too. The file is created if it does
+// not exist.
+type KVSetHandler struct {
+	preserveModTime bool
+	report          reportFunc
+}
+
+// NewKVSetHandler creates a new kvset handler
+func NewKVSetHandler() OperationHandler {
+	return &KVSetHandler{}
+}
+
+// CanHandle returns true if this handler can process the given operation
+func (h *KVSetHandler) CanHandle(operation string) bool {
+	return operation == "kvset"
+}
+
+// Apply sets and removes the keys of a kvset part in its file
+func (h *KVSetHandler) Apply(fs FileSystem, baseDir string, part parser.DeltagramPart) error {
+	changes, err := ParseKeyValueChanges(part.Content)
+	if err != nil {
+		return err
+	}
+
+	filePath := ResolveFilePath(baseDir, part.ContentLocation)
+	info, err := fs.Stat(filePath)
+	exists := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to stat existing file: %w", err)
+	}
+
+	var original string
+	perm := os.FileMode(0644)
+	if exists {
+		data, err := fs.ReadFile(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to read existing file: %w", err)
+		}
+		original, perm = string(data), info.Mode().Perm()
+	} else if err := fs.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	modified := setKeyValues(original, changes, kvSyntaxFor(part.ContentLocation))
+	if exists && modified == original {
+		h.report.printf("Unchanged: %s\n", part.ContentLocation)
+		return nil
+	}
+	if err := fs.WriteFile(filePath, []byte(modified), perm); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if exists && h.preserveModTime {
+		if err := fs.Chtimes(filePath, info.ModTime(), info.ModTime()); err != nil {
+			return fmt.Errorf("failed to restore modification time: %w", err)
+		}
+	}
+
+	if exists {
+		h.report.printf("Modified: %s\n", part.ContentLocation)
+	} else {
+		h.report.printf("Created: %s\n", part.ContentLocation)
+	}
+	return nil
+}
+
+// ParseKeyValueChanges parses the body of a kvset part
+func ParseKeyValueChanges(content string) ([]KeyValueChange, error) {
+	var changes []KeyValueChange
+	section := ""
+	for number, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";"):
+			continue
+		case isSectionHeader(trimmed):
+			section = strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+		case strings.HasPrefix(trimmed, "-"):
+			key := strings.TrimSpace(trimmed[1:])
+			if key == "" || strings.ContainsAny(key, "=:") {
+				return nil, fmt.Errorf("line %d: expected -KEY to remove a key, got %q", number+1, trimmed)
+			}
+			changes = append(changes, KeyValueChange{Section: section, Key: key, Remove: true})
+		default:
+			entry := strings.TrimPrefix(strings.TrimPrefix(trimmed, "+"), "export ")
+			at := strings.IndexAny(entry, "=:")
+			if at <= 0 {
+				return nil, fmt.Errorf("line %d: expected KEY=value or -KEY, got %q", number+1, trimmed)
+			}
+			value := strings.TrimSpace(entry[at+1:])
+			changes = append(changes, KeyValueChange{Section: section, Key: strings.TrimSpace(entry[:at]), Value: value})
+		}
+	}
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("kvset operation has no keys to set or remove")
+	}
+	return changes, nil
+}
+
+// kvSyntax describes how a kind of key-value file separates keys from values
+type kvSyntax struct {
+	separators   string // Characters that may end a key
+	continuation bool   // Whether a trailing backslash continues a value on the next line
+}
+
+// kvSyntaxFor returns the syntax of the file at location, judged by its name
+func kvSyntaxFor(location string) kvSyntax {
+	if strings.EqualFold(filepath.Ext(location), ".properties") {
+		return kvSyntax{separators: "=:", continuation: true}
+	}
+	return kvSyntax{separators: "="}
+}
+
+// kvLine is a line of a key-value file as setKeyValues sees it
+type kvLine struct {
+	section string
+	key     string // "" for comments, blank lines, and section headers
+	prefix  string // Everything before the value: indentation, export, key, and separator
+	header  bool
+	lines   int // Lines the entry spans, counting continuation lines
+}
+
+// setKeyValues returns text with the changes made to it
+func setKeyValues(text string, changes []KeyValueChange, syntax kvSyntax) string {
+	bom, rest := cutByteOrderMark(text)
+	lines, finalNewline := fileLines(rest)
+	ending := ""
+	if len(lines) > 0 && strings.HasSuffix(lines[0], "\r") {
+		ending = "\r"
+	}
+
+	for _, change := range changes {
+		parsed := parseKeyValueLines(lines, syntax)
+		var matches []int
+		for i, line := range parsed {
+			if line.key == change.Key && line.section == change.Section {
+				matches = append(matches, i)
+			}
+		}
+
+		// Later copies of a key are removed, since only one value can be meant
+		for j := len(matches) - 1; j >= 0; j-- {
+			if j == 0 && !change.Remove {
+				break
+			}
+			i := matches[j]
+			lines = append(lines[:i], lines[i+parsed[i].lines:]...)
+		}
+		if change.Remove {
+			continue
+		}
+
+		if len(matches) > 0 {
+			i := matches[0]
+			entry := parsed[i].prefix + change.Value + ending
+			lines = append(lines[:i], append([]string{entry}, lines[i+parsed[i].lines:]...)...)
+			continue
+		}
+		entry := change.Key + separatorOf(parsed) + change.Value + ending
+		at, newSection := insertPosition(parsed, change.Section)
+		if newSection {
+			if at > 0 && strings.TrimSpace(lines[at-1]) != "" {
+				lines = append(lines, ending)
+			}
+			lines = append(lines, "["+change.Section+"]"+ending)
+			at = len(lines)
+		}
+		lines = append(lines[:at], append([]string{entry}, lines[at:]...)...)
+	}
+	return bom + joinFileLines(lines, finalNewline)
+}
+
+// parseKeyValueLines classifies each line of a key-value file. Continuation lines of an
+// entry are given the entry's section and no key.
+func parseKeyValueLines(lines []string, syntax kvSyntax) []kvLine {
+	parsed := make([]kvLine, len(lines))
+	section := ""
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSuffix(lines[i], "\r")
+		trimmed := strings.TrimSpace(line)
+		parsed[i] = kvLine{section: section, lines: 1}
+		switch {
+		case trimmed == "" || strings.ContainsAny(trimmed[:1], "#;!"):
+			continue
+		case isSectionHeader(trimmed):
+			section = strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+			parsed[i] = kvLine{section: section, header: true, lines: 1}
+			continue
+		}
+
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		body := line[len(indent):]
+		export := ""
+		if strings.HasPrefix(body, "export ") {
+			export, body = "export ", strings.TrimPrefix(body, "export ")
+		}
+		at := strings.IndexAny(body, syntax.separators)
+		if at <= 0 {
+			continue
+		}
+		// The separator keeps the spaces around it, as in "key = value"
+		end := at + 1
+		for end < len(body) && (body[end] == ' ' || body[end] == '\t') {
+			end++
+		}
+		parsed[i].key = strings.TrimSpace(body[:at])
+		parsed[i].prefix = indent + export + body[:end]
+
+		for syntax.continuation && continues(strings.TrimSuffix(lines[i+parsed[i].lines-1], "\r")) && i+parsed[i].lines < len(lines) {
+			parsed[i].lines++
+		}
+		for j := 1; j < parsed[i].lines; j++ {
+			parsed[i+j] = kvLine{section: section, lines: 1}
+		}
+		i += parsed[i].lines - 1
+	}
+	return parsed
+}
+
+// continues reports whether a .properties line ends with an odd number of backslashes,
+// which continues its value on the next line
+func continues(line string) bool {
+	count := len(line) - len(strings.TrimRight(line, "\\"))
+	return count%2 == 1
+}
+
+// insertPosition returns where a new key of section goes: after the last key of the
+// section, or after its header. For keys outside any section that is before the first
+// header. It reports true if the section does not exist and must be added at the end.
+func insertPosition(parsed []kvLine, section string) (int, bool) {
+	position, found := -1, section == ""
+	for i, line := range parsed {
+		if line.header && section == "" {
+			break
+		}
+		if line.section != section {
+			continue
+		}
+		found = true
+		if line.header || line.key != "" {
+			position = i + line.lines
+		}
+	}
+	if !found {
+		return len(parsed), true
+	}
+	if position < 0 {
+		// No keys outside a section yet: go before the first header, or at the end
+		for i, line := range parsed {
+			if line.header {
+				return i, false
+			}
+		}
+		return len(parsed), false
+	}
+	return position, false
+}
+
+// separatorOf returns the separator of the first entry, with its spacing, or "=" if the
+// file has no entries
+func separatorOf(parsed []kvLine) string {
+	for _, line := range parsed {
+		if line.key != "" {
+			rest := strings.TrimPrefix(strings.TrimLeft(line.prefix, " \t"), "export ")
+			rest = strings.TrimPrefix(rest, line.key)
+			if strings.TrimSpace(rest) != "" {
+				return rest
+			}
+		}
+	}
+	return "="
+}
+
+// isSectionHeader reports whether a trimmed line is an INI section header such as [name]
+func isSectionHeader(trimmed string) bool {
+	return len(trimmed) >= 2 && trimmed[0] == '[' && trimmed[len(trimmed)-1] == ']'
+}
@@ -0,0 +1,76 @@
+package operations
+
+import (
+	"testing"
+
+	"github.com/developingjames/deltagrams/internal/testutil"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func TestKVSetHandler_Apply(t *testing.T) {
+	tests := []struct {
+		name     string
+		location string
+		original string
+		body     string
+		expected string
+	}{
+		{"env replace and add", ".env", "# Local settings\nPORT=3000\nDEBUG=false\n", "DEBUG=true\nLOG_LEVEL=info",
+			"# Local settings\nPORT=3000\nDEBUG=true\nLOG_LEVEL=info\n"},
+		{"env keeps export", ".env", "export TOKEN=abc\n", "TOKEN=xyz", "export TOKEN=xyz\n"},
+		{"env remove", ".env", "A=1\n# keep me\nB=2\n", "-A", "# keep me\nB=2\n"},
+		{"remove duplicates on set", ".env", "A=1\nB=2\nA=3\n", "A=4", "A=4\nB=2\n"},
+		{"remove missing key", ".env", "A=1\n", "-B", "A=1\n"},
+		{"value with separator", ".env", "URL=http://old\n", "URL=postgres://u:p@host/db?a=b", "URL=postgres://u:p@host/db?a=b\n"},
+		{"ini section", "app.ini", "; app\nname = demo\n\n[database]\nhost = localhost\nport = 5432\n\n[cache]\nttl = 60\n",
+			"[database]\nhost=db.internal\nuser=app\n[cache]\n-ttl",
+			"; app\nname = demo\n\n[database]\nhost = db.internal\nport = 5432\nuser = app\n\n[cache]\n"},
+		{"ini new section", "app.ini", "[a]\nx = 1\n", "[b]\ny=2", "[a]\nx = 1\n\n[b]\ny = 2\n"},
+		{"ini global key before first section", "app.ini", "; top\n[a]\nx=1\n", "debug=true", "; top\ndebug=true\n[a]\nx=1\n"},
+		{"properties colon separator", "app.properties", "! comment\ngreeting: hello\n", "greeting=hi\nfarewell=bye", "! comment\ngreeting: hi\nfarewell: bye\n"},
+		{"properties continuation", "app.properties", "list=a,\\\n  b,\\\n  c\nnext=1\n", "list=x", "list=x\nnext=1\n"},
+		{"keeps CRLF", ".env", "A=1\r\nB=2\r\n", "B=3\nC=4", "A=1\r\nB=3\r\nC=4\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := testutil.NewMockFileSystem()
+			fs.AddFile("/base/"+tt.location, []byte(tt.original))
+
+			part := parser.DeltagramPart{ContentLocation: tt.location, DeltaOperation: "kvset", Content: tt.body}
+			if err := (&KVSetHandler{report: func(string, ...interface{}) {}}).Apply(fs, "/base", part); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if data, _ := fs.ReadFile("/base/" + tt.location); string(data) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, data)
+			}
+		})
+	}
+}
+
+func TestKVSetHandler_Apply_CreatesFile(t *testing.T) {
+	fs := testutil.NewMockFileSystem()
+
+	part := parser.DeltagramPart{ContentLocation: "config/.env", DeltaOperation: "kvset", Content: "A=1\nB=2"}
+	if err := (&KVSetHandler{report: func(string, ...interface{}) {}}).Apply(fs, "/base", part); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if data, _ := fs.ReadFile("/base/config/.env"); string(data) != "A=1\nB=2\n" {
+		t.Errorf("Expected a new file with both keys, got %q", data)
+	}
+}
+
+func TestParseKeyValueChanges_Errors(t *testing.T) {
+	tests := map[string]string{
+		"no separator":    "JUSTAKEY",
+		"empty key":       "=value",
+		"remove with =":   "-A=1",
+		"nothing to do":   "# only a comment\n",
+		"bare minus sign": "-",
+	}
+	for name, body := range tests {
+		if _, err := ParseKeyValueChanges(body); err == nil {
+			t.Errorf("%s: expected an error for %q, got none", name, body)
+		}
+	}
+}
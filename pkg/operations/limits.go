@@ -0,0 +1,134 @@
+package operations
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// checkLimits enforces the configured part count and size limits before anything is written
+func (a *DefaultApplier) checkLimits(deltagram *parser.Deltagram, baseDir string) error {
+	if a.opts.MaxParts <= 0 && a.opts.MaxFileSize <= 0 && a.opts.MaxTotalBytes <= 0 {
+		return nil
+	}
+
+	var parts int
+	var totalBytes int64
+	for _, part := range deltagram.Parts {
+		if isMessagePart(part) {
+			continue
+		}
+
+		parts++
+		if a.opts.MaxParts > 0 && parts > a.opts.MaxParts {
+			return fmt.Errorf("deltagram exceeds the limit of %d operations", a.opts.MaxParts)
+		}
+
+		size := estimateWrittenBytes(a.fs, baseDir, part)
+		if a.opts.MaxFileSize > 0 && size > a.opts.MaxFileSize {
+			return fmt.Errorf("%s operation on %s would write %d bytes, exceeding the file size limit of %d bytes",
+				part.DeltaOperation, part.ContentLocation, size, a.opts.MaxFileSize)
+		}
+
+		totalBytes += size
+		if a.opts.MaxTotalBytes > 0 && totalBytes > a.opts.MaxTotalBytes {
+			return fmt.Errorf("deltagram would write more than the limit of %d bytes in total", a.opts.MaxTotalBytes)
+		}
+	}
+
+	return nil
+}
+
+// estimateWrittenBytes returns an upper bound on the number of bytes a part writes
+func estimateWrittenBytes(fs FileSystem, baseDir string, part parser.DeltagramPart) int64 {
+	switch part.DeltaOperation {
+	case "create", "":
+		return int64(len(createContent(part)))
+	case "copy":
+		sourcePath, _, _, _ := transferPaths(part)
+		if info, err := fs.Stat(ResolveFilePath(baseDir, sourcePath)); err == nil {
+			return info.Size()
+		}
+	case "move":
+		sourcePath, _, _, _ := transferPaths(part)
+		added := addedBytes(part.Content)
+		if added == 0 {
+			return 0 // A plain rename writes no new data
+		}
+		if info, err := fs.Stat(ResolveFilePath(baseDir, sourcePath)); err == nil {
+			return info.Size() + added
+		}
+		return added
+	case "content":
+		oldPath, newPath := parseFileHeaders(strings.Split(part.Content, "\n"))
+		if newPath == devNull {
+			return 0
+		}
+		added := addedBytes(part.Content)
+		if oldPath == devNull {
+			return added
+		}
+		if info, err := fs.Stat(ResolveFilePath(baseDir, part.ContentLocation)); err == nil {
+			return info.Size() + added
+		}
+		return added
+	case "inline":
+		var added int64
+		if edits, err := ParseInlineEdits(part.Content); err == nil {
+			for _, edit := range edits {
+				added += int64(len(edit.New))
+			}
+		}
+		if info, err := fs.Stat(ResolveFilePath(baseDir, part.ContentLocation)); err == nil {
+			return info.Size() + added
+		}
+		return added
+	case "table", "lines", "kvset":
+		var added int64
+		for _, line := range strings.Split(part.Content, "\n") {
+			if strings.HasPrefix(line, "+") || (part.DeltaOperation == "kvset" && !strings.HasPrefix(line, "-")) {
+				added += int64(len(line))
+			}
+		}
+		if info, err := fs.Stat(ResolveFilePath(baseDir, part.ContentLocation)); err == nil {
+			return info.Size() + added
+		}
+		return added
+	case "go-ast":
+		if info, err := fs.Stat(ResolveFilePath(baseDir, part.ContentLocation)); err == nil {
+			return info.Size() + int64(len(part.Content))
+		}
+		return int64(len(part.Content))
+	case "yaml":
+		var added int64
+		if changes, err := ParseYAMLChanges(part.Content); err == nil {
+			for _, change := range changes {
+				added += int64(len(change.Path) + len(change.Value))
+			}
+		}
+		if info, err := fs.Stat(ResolveFilePath(baseDir, part.ContentLocation)); err == nil {
+			return info.Size() + added
+		}
+		return added
+	}
+	return 0
+}
+
+// addedBytes sums the size of every added hunk line, including its newline
+func addedBytes(diff string) int64 {
+	hunks, err := (&ContentHandler{}).ParseAllHunks(strings.Split(diff, "\n"))
+	if err != nil {
+		return 0
+	}
+
+	var total int64
+	for _, hunk := range hunks {
+		for _, op := range hunk.Operations {
+			if op.Type == '+' {
+				total += int64(len(op.Content)) + 1
+			}
+		}
+	}
+	return total
+}
@@ -0,0 +1,193 @@
+package operations
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// LinesHandler handles lines operations on files that are sets of lines, such as
+// .gitignore or requirements.txt. The body lists lines to add or remove, prefixed like
+// diff lines and without any line numbers:
+//
+//	+node_modules/
+//	-*.tmp
+//
+// An added line goes before the first line that sorts after it, ignoring case, so a
+// sorted file stays sorted; a line the file already has is not added again. A removed
+// line is taken out wherever it appears. Blank lines and lines starting with # are not
+// used to place added lines. The file is created if it does not exist.
+type LinesHandler struct {
+	preserveModTime bool
+	report          reportFunc
+}
+
+// NewLinesHandler creates a new lines handler
+func NewLinesHandler() OperationHandler {
+	return &LinesHandler{}
+}
+
+// CanHandle returns true if this handler can process the given operation
+func (h *LinesHandler) CanHandle(operation string) bool {
+	return operation == "lines"
+}
+
+// Apply adds and removes the lines of a lines part in its file
+func (h *LinesHandler) Apply(fs FileSystem, baseDir string, part parser.DeltagramPart) error {
+	added, removed, err := ParseLineSet(part.Content)
+	if err != nil {
+		return err
+	}
+
+	filePath := ResolveFilePath(baseDir, part.ContentLocation)
+	info, err := fs.Stat(filePath)
+	exists := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to stat existing file: %w", err)
+	}
+
+	var original string
+	perm := os.FileMode(0644)
+	if exists {
+		data, err := fs.ReadFile(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to read existing file: %w", err)
+		}
+		original, perm = string(data), info.Mode().Perm()
+	} else if err := fs.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	modified, _, _ := mergeLineSet(original, added, removed)
+	if exists && modified == original {
+		h.report.printf("Unchanged: %s\n", part.ContentLocation)
+		return nil
+	}
+	if err := fs.WriteFile(filePath, []byte(modified), perm); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if exists && h.preserveModTime {
+		if err := fs.Chtimes(filePath, info.ModTime(), info.ModTime()); err != nil {
+			return fmt.Errorf("failed to restore modification time: %w", err)
+		}
+	}
+
+	if exists {
+		h.report.printf("Modified: %s\n", part.ContentLocation)
+	} else {
+		h.report.printf("Created: %s\n", part.ContentLocation)
+	}
+	return nil
+}
+
+// ParseLineSet parses the body of a lines part into the lines it adds and removes
+func ParseLineSet(content string) ([]string, []string, error) {
+	var added, removed []string
+	for number, line := range strings.Split(content, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		switch {
+		case strings.TrimSpace(line) == "":
+			continue
+		case setKey(line[1:]) == "":
+			return nil, nil, fmt.Errorf("line %d: a lines operation cannot add or remove blank lines", number+1)
+		case line[0] == '+':
+			added = append(added, line[1:])
+		case line[0] == '-':
+			removed = append(removed, line[1:])
+		default:
+			return nil, nil, fmt.Errorf("line %d: expected a line starting with + or -, got %q", number+1, line)
+		}
+	}
+	if len(added) == 0 && len(removed) == 0 {
+		return nil, nil, fmt.Errorf("lines operation has no lines to add or remove")
+	}
+	return added, removed, nil
+}
+
+// mergeLineSet returns text with the lines added and removed, along with how many lines
+// it actually added and removed. Lines are compared without trailing whitespace or
+// carriage returns, and new lines get the file's line ending.
+func mergeLineSet(text string, added, removed []string) (string, int, int) {
+	bom, rest := cutByteOrderMark(text)
+	lines, finalNewline := fileLines(rest)
+	crlf := len(lines) > 0 && strings.HasSuffix(lines[0], "\r")
+
+	drop := make(map[string]bool)
+	for _, line := range removed {
+		drop[setKey(line)] = true
+	}
+	kept := lines[:0:0]
+	removedCount := 0
+	for _, line := range lines {
+		if drop[setKey(line)] {
+			removedCount++
+			continue
+		}
+		kept = append(kept, line)
+	}
+	lines = kept
+
+	present := make(map[string]bool)
+	for _, line := range lines {
+		present[setKey(line)] = true
+	}
+	addedCount := 0
+	for _, line := range added {
+		key := setKey(line)
+		if present[key] {
+			continue
+		}
+		present[key] = true
+		if crlf {
+			line += "\r"
+		}
+		at := sortedPosition(lines, line)
+		lines = append(lines[:at], append([]string{line}, lines[at:]...)...)
+		addedCount++
+	}
+
+	return bom + joinFileLines(lines, finalNewline), addedCount, removedCount
+}
+
+// sortedPosition returns where line goes among lines: before the first entry that sorts
+// after it, or after the last entry. Blank lines and # comments are not entries.
+func sortedPosition(lines []string, line string) int {
+	position := len(lines)
+	lastEntry := -1
+	for i, existing := range lines {
+		if !isSetEntry(existing) {
+			continue
+		}
+		if lessFold(setKey(line), setKey(existing)) {
+			return i
+		}
+		lastEntry = i
+	}
+	if lastEntry >= 0 {
+		position = lastEntry + 1
+	}
+	return position
+}
+
+// isSetEntry reports whether a line is an entry of the set rather than a blank line or a
+// comment
+func isSetEntry(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return trimmed != "" && !strings.HasPrefix(trimmed, "#")
+}
+
+// setKey returns the line as it is compared with other lines of the set
+func setKey(line string) string {
+	return strings.TrimRight(line, " \t\r")
+}
+
+// lessFold orders lines ignoring case, then by case so that the order is total
+func lessFold(a, b string) bool {
+	if la, lb := strings.ToLower(a), strings.ToLower(b); la != lb {
+		return la < lb
+	}
+	return a < b
+}
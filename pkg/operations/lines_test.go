@@ -0,0 +1,75 @@
+package operations
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/developingjames/deltagrams/internal/testutil"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func TestLinesHandler_Apply(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		body     string
+		expected string
+	}{
+		{"sorted position", "flask==2.0\nnumpy==1.26\nrequests==2.31\n", "+pandas==2.1", "flask==2.0\nnumpy==1.26\npandas==2.1\nrequests==2.31\n"},
+		{"ignores case", "Django\nflask\n", "+celery", "celery\nDjango\nflask\n"},
+		{"after last entry", "a\nb\n", "+c", "a\nb\nc\n"},
+		{"already present", "a\nb  \n", "+b", "a\nb  \n"},
+		{"dedupes added lines", "a\n", "+b\n+b", "a\nb\n"},
+		{"removes every copy", "a\nb\na\nc\n", "-a", "b\nc\n"},
+		{"remove missing line", "a\n", "-z\n+b", "a\nb\n"},
+		{"skips comments and blank lines", "# build output\nbin/\n\n# deps\nvendor/\n", "+dist/", "# build output\nbin/\n\n# deps\ndist/\nvendor/\n"},
+		{"keeps CRLF", "a\r\nc\r\n", "+b", "a\r\nb\r\nc\r\n"},
+		{"keeps missing final newline", "a\nc", "+b", "a\nb\nc"},
+		{"unsorted file", "zebra\napple\n", "+mango", "mango\nzebra\napple\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := testutil.NewMockFileSystem()
+			fs.AddFile("/base/set.txt", []byte(tt.original))
+
+			part := parser.DeltagramPart{ContentLocation: "set.txt", DeltaOperation: "lines", Content: tt.body}
+			if err := (&LinesHandler{report: func(string, ...interface{}) {}}).Apply(fs, "/base", part); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if data, _ := fs.ReadFile("/base/set.txt"); string(data) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, data)
+			}
+		})
+	}
+}
+
+func TestLinesHandler_Apply_CreatesFile(t *testing.T) {
+	fs := testutil.NewMockFileSystem()
+	var reported []string
+	handler := &LinesHandler{report: func(format string, args ...interface{}) { reported = append(reported, format) }}
+
+	part := parser.DeltagramPart{ContentLocation: "sub/.gitignore", DeltaOperation: "lines", Content: "+node_modules/\n+.env"}
+	if err := handler.Apply(fs, "/base", part); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if data, _ := fs.ReadFile("/base/sub/.gitignore"); string(data) != ".env\nnode_modules/\n" {
+		t.Errorf("Expected a new sorted file, got %q", data)
+	}
+	if len(reported) != 1 || !strings.HasPrefix(reported[0], "Created") {
+		t.Errorf("Expected the file to be reported as created, got %v", reported)
+	}
+}
+
+func TestParseLineSet_Errors(t *testing.T) {
+	tests := map[string]string{
+		"no prefix": "node_modules/",
+		"blank":     "+  ",
+		"empty":     "\n\n",
+	}
+	for name, body := range tests {
+		if _, _, err := ParseLineSet(body); err == nil {
+			t.Errorf("%s: expected an error for %q, got none", name, body)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package operations
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// Lint rule IDs
+const (
+	LintHunkCount       = "hunk_count"       // A hunk header's line counts do not match its body
+	LintMissingMessage  = "missing_message"  // The deltagram has no message part
+	LintAbsolutePath    = "absolute_path"    // A path is absolute and will be applied relative to the base directory
+	LintPathTraversal   = "path_traversal"   // A path contains a .. segment
+	LintCreateOverwrite = "create_overwrite" // A create targets a file that already exists
+	LintDuplicateTarget = "duplicate_target" // More than one part writes the same path
+	LintLargeHunk       = "large_hunk"       // A hunk is too large to review or match reliably
+)
+
+// LargeHunkLines is the number of lines above which a hunk is reported as large
+const LargeHunkLines = 300
+
+// LintIssue is a likely mistake in a deltagram, typically one made by the model that
+// wrote it
+type LintIssue struct {
+	Rule    string `json:"rule"`
+	Part    int    `json:"part,omitempty"` // 1-based index of the part, 0 for the whole deltagram
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+	Fixable bool   `json:"fixable"` // Whether FixLint corrects it
+}
+
+// Lint checks a deltagram for likely mistakes, consulting fs only to find creates that
+// would overwrite existing files
+func Lint(fs FileSystem, baseDir string, d *parser.Deltagram) []LintIssue {
+	var issues []LintIssue
+	add := func(issue LintIssue) {
+		issues = append(issues, issue)
+	}
+
+	hasMessage := false
+	writers := make(map[string][]int)
+	var order []string
+
+	for i, part := range d.Parts {
+		index := i + 1
+		if part.IsMessage() {
+			hasMessage = hasMessage || part.IsPlainMessage()
+			continue
+		}
+
+		paths := []string{part.ContentLocation}
+		if part.DeltaOperation == "copy" || part.DeltaOperation == "move" {
+			if source, _, _, err := transferPaths(part); err == nil {
+				paths = append(paths, source)
+			}
+		}
+		for _, p := range paths {
+			if filepath.IsAbs(p) || strings.HasPrefix(p, "/") {
+				add(LintIssue{Rule: LintAbsolutePath, Part: index, Path: p,
+					Fixable: p == part.ContentLocation && strings.HasPrefix(p, "/"),
+					Message: fmt.Sprintf("%s is absolute; apply treats it as relative to the directory", p)})
+			}
+			for _, segment := range strings.Split(filepath.ToSlash(p), "/") {
+				if segment == ".." {
+					add(LintIssue{Rule: LintPathTraversal, Part: index, Path: p,
+						Message: fmt.Sprintf("%s contains a .. segment", p)})
+					break
+				}
+			}
+		}
+
+		for _, p := range WrittenPaths(part) {
+			key := path.Clean(strings.TrimPrefix(filepath.ToSlash(p), "/"))
+			if writers[key] == nil {
+				order = append(order, key)
+			}
+			writers[key] = append(writers[key], index)
+		}
+
+		switch part.DeltaOperation {
+		case "create":
+			if _, err := fs.Stat(ResolveFilePath(baseDir, part.ContentLocation)); err == nil {
+				add(LintIssue{Rule: LintCreateOverwrite, Part: index, Path: part.ContentLocation,
+					Message: fmt.Sprintf("create would overwrite existing file %s", part.ContentLocation)})
+			}
+		case "content":
+			for _, issue := range lintHunks(part) {
+				issue.Part = index
+				add(issue)
+			}
+		}
+	}
+
+	for _, key := range order {
+		if parts := writers[key]; len(parts) > 1 {
+			add(LintIssue{Rule: LintDuplicateTarget, Part: parts[1], Path: key,
+				Message: fmt.Sprintf("%s is written by parts %s", key, joinInts(parts))})
+		}
+	}
+
+	if !hasMessage {
+		add(LintIssue{Rule: LintMissingMessage, Message: "deltagram has no deltagram://message part describing the change"})
+	}
+	return issues
+}
+
+// lintHunks checks the hunks of a content part
+func lintHunks(part parser.DeltagramPart) []LintIssue {
+	handler := &ContentHandler{}
+	hunks, err := handler.ParseAllHunks(strings.Split(part.Content, "\n"))
+	if err != nil {
+		return nil // Reported when the deltagram is checked or applied
+	}
+
+	var issues []LintIssue
+	for i, hunk := range hunks {
+		oldCount, newCount := 0, 0
+		for _, op := range hunk.Operations {
+			if op.Type != '+' {
+				oldCount++
+			}
+			if op.Type != '-' {
+				newCount++
+			}
+		}
+
+		if oldCount != hunk.Header.OldCount || newCount != hunk.Header.NewCount {
+			issues = append(issues, LintIssue{Rule: LintHunkCount, Path: part.ContentLocation, Fixable: true,
+				Message: fmt.Sprintf("hunk %d header says -%d +%d but its body has -%d +%d",
+					i+1, hunk.Header.OldCount, hunk.Header.NewCount, oldCount, newCount)})
+		}
+		if len(hunk.Operations) > LargeHunkLines {
+			issues = append(issues, LintIssue{Rule: LintLargeHunk, Path: part.ContentLocation,
+				Message: fmt.Sprintf("hunk %d has %d lines; consider splitting it or using create", i+1, len(hunk.Operations))})
+		}
+	}
+	return issues
+}
+
+// FixLint returns a copy of the deltagram with the fixable lint issues corrected: hunk
+// headers are recounted and absolute Content-Locations are made relative, as apply
+// would treat them anyway
+func FixLint(d *parser.Deltagram) *parser.Deltagram {
+	result := *d
+	result.Parts = make([]parser.DeltagramPart, len(d.Parts))
+	for i, part := range d.Parts {
+		if !part.IsMessage() {
+			part.ContentLocation = strings.TrimPrefix(part.ContentLocation, "/")
+		}
+		if part.DeltaOperation == "content" {
+			part.Content = RecountHunks(part.Content)
+		}
+		result.Parts[i] = part
+	}
+	return &result
+}
+
+// RepairHunkCounts recounts the hunk headers of content parts whose line counts do not
+// match their bodies, recording each fix in d.Repairs
+func RepairHunkCounts(d *parser.Deltagram) {
+	for i := range d.Parts {
+		part := &d.Parts[i]
+		if part.DeltaOperation != "content" {
+			continue
+		}
+		var mismatched []string
+		for _, issue := range lintHunks(*part) {
+			if issue.Rule == LintHunkCount {
+				mismatched = append(mismatched, issue.Message)
+			}
+		}
+		if mismatched == nil {
+			continue
+		}
+		part.Content = RecountHunks(part.Content)
+		for _, message := range mismatched {
+			d.Repairs = append(d.Repairs, parser.Repair{Line: part.Line,
+				Message: fmt.Sprintf("%s: %s; recounted it", part.ContentLocation, message)})
+		}
+	}
+}
+
+// joinInts formats part indexes as "1, 3 and 4"
+func joinInts(values []int) string {
+	text := make([]string, len(values))
+	for i, v := range values {
+		text[i] = fmt.Sprint(v)
+	}
+	if len(text) == 1 {
+		return text[0]
+	}
+	return strings.Join(text[:len(text)-1], ", ") + " and " + text[len(text)-1]
+}
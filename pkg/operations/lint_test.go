@@ -0,0 +1,123 @@
+package operations
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func TestLint(t *testing.T) {
+	message := parser.DeltagramPart{ContentLocation: "deltagram://message", ContentType: "text/plain", Content: "Summary"}
+
+	tests := []struct {
+		name  string
+		parts []parser.DeltagramPart
+		rules []string
+	}{
+		{
+			name:  "clean",
+			parts: []parser.DeltagramPart{message, {ContentLocation: "new.txt", DeltaOperation: "create", Content: "hi"}},
+		},
+		{
+			name:  "missing message",
+			parts: []parser.DeltagramPart{{ContentLocation: "new.txt", DeltaOperation: "create", Content: "hi"}},
+			rules: []string{LintMissingMessage},
+		},
+		{
+			name:  "hunk count",
+			parts: []parser.DeltagramPart{message, {ContentLocation: "file.txt", DeltaOperation: "content", Content: "@@ -1,3 +1,3 @@\n one\n-two\n+TWO"}},
+			rules: []string{LintHunkCount},
+		},
+		{
+			name:  "absolute path",
+			parts: []parser.DeltagramPart{message, {ContentLocation: "/etc/new.txt", DeltaOperation: "create", Content: "hi"}},
+			rules: []string{LintAbsolutePath},
+		},
+		{
+			name:  "path traversal",
+			parts: []parser.DeltagramPart{message, {ContentLocation: "src/../../x.txt", DeltaOperation: "create", Content: "hi"}},
+			rules: []string{LintPathTraversal},
+		},
+		{
+			name:  "create overwrite",
+			parts: []parser.DeltagramPart{message, {ContentLocation: "file.txt", DeltaOperation: "create", Content: "hi"}},
+			rules: []string{LintCreateOverwrite},
+		},
+		{
+			name: "duplicate target",
+			parts: []parser.DeltagramPart{message,
+				{ContentLocation: "new.txt", DeltaOperation: "create", Content: "hi"},
+				{ContentLocation: "./new.txt", DeltaOperation: "delete"},
+			},
+			rules: []string{LintDuplicateTarget},
+		},
+		{
+			name: "large hunk",
+			parts: []parser.DeltagramPart{message, {ContentLocation: "file.txt", DeltaOperation: "content",
+				Content: "@@ -1,1 +1,301 @@\n one" + strings.Repeat("\n+x", 300)}},
+			rules: []string{LintLargeHunk},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := NewMemoryFileSystem()
+			fs.MkdirAll("/base", 0755)
+			fs.WriteFile("/base/file.txt", []byte("one\ntwo"), 0644)
+
+			issues := Lint(fs, "/base", &parser.Deltagram{Parts: tt.parts})
+			var rules []string
+			for _, issue := range issues {
+				rules = append(rules, issue.Rule)
+			}
+			if strings.Join(rules, ",") != strings.Join(tt.rules, ",") {
+				t.Errorf("Expected rules %v, got %+v", tt.rules, issues)
+			}
+		})
+	}
+}
+
+func TestFixLint(t *testing.T) {
+	fs := NewMemoryFileSystem()
+	fs.MkdirAll("/base/src", 0755)
+	fs.WriteFile("/base/src/a.txt", []byte("one\ntwo"), 0644)
+
+	deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{
+		{ContentLocation: "deltagram://message", ContentType: "text/plain", Content: "Summary"},
+		{ContentLocation: "/src/a.txt", DeltaOperation: "content", Content: "@@ -1,7 +1,7 @@\n one\n-two\n+TWO"},
+	}}
+	if issues := Lint(fs, "/base", deltagram); len(issues) != 2 || !issues[0].Fixable || !issues[1].Fixable {
+		t.Fatalf("Expected two fixable issues, got %+v", issues)
+	}
+
+	fixed := FixLint(deltagram)
+	if issues := Lint(fs, "/base", fixed); len(issues) != 0 {
+		t.Errorf("Expected no issues after fixing, got %+v", issues)
+	}
+	if deltagram.Parts[1].ContentLocation != "/src/a.txt" {
+		t.Errorf("Expected the original deltagram to be left unchanged")
+	}
+	if fixed.Parts[1].Content != "@@ -1,2 +1,2 @@\n one\n-two\n+TWO" {
+		t.Errorf("Expected recounted hunk, got %q", fixed.Parts[1].Content)
+	}
+}
+
+func TestRepairHunkCounts(t *testing.T) {
+	deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{
+		{ContentLocation: "a.txt", DeltaOperation: "content", Line: 4, Content: "@@ -1,7 +1,7 @@\n one\n-two\n+TWO"},
+		{ContentLocation: "b.txt", DeltaOperation: "content", Line: 12, Content: "@@ -3 +3 @@\n-x\n+y"},
+	}}
+
+	RepairHunkCounts(deltagram)
+
+	if deltagram.Parts[0].Content != "@@ -1,2 +1,2 @@\n one\n-two\n+TWO" {
+		t.Errorf("Expected recounted hunk, got %q", deltagram.Parts[0].Content)
+	}
+	if deltagram.Parts[1].Content != "@@ -3 +3 @@\n-x\n+y" {
+		t.Errorf("Expected correct hunk to be left alone, got %q", deltagram.Parts[1].Content)
+	}
+	if len(deltagram.Repairs) != 1 || deltagram.Repairs[0].Line != 4 {
+		t.Errorf("Expected one repair at line 4, got %+v", deltagram.Repairs)
+	}
+}
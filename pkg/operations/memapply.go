@@ -0,0 +1,86 @@
+package operations
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"sort"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// MemoryResult is the outcome of applying a deltagram with ApplyToMemoryFS. Paths are
+// slash-separated and relative to the root of the tree.
+type MemoryResult struct {
+	Files    map[string][]byte // Every file in the tree after the apply
+	Changes  []FileChange      // Files created, modified, or deleted
+	Diff     string            // Unified diff of the changes
+	Warnings []Warning
+}
+
+// LoadMemoryFiles returns a MemoryFileSystem holding files, keyed by slash-separated
+// relative path, under ArchiveRoot. Paths leading outside the tree are refused.
+func LoadMemoryFiles(files map[string][]byte) (*MemoryFileSystem, error) {
+	entries := make([]ArchiveEntry, 0, len(files))
+	for name, data := range files {
+		entries = append(entries, ArchiveEntry{Path: name, Data: data, Mode: 0644})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return LoadArchiveEntries(entries)
+}
+
+// PlanMemoryFS builds the plan of a deltagram against a tree held in memory
+func PlanMemoryFS(deltagram *parser.Deltagram, files map[string][]byte) (*Plan, error) {
+	mem, err := LoadMemoryFiles(files)
+	if err != nil {
+		return nil, err
+	}
+	return BuildPlan(mem, ArchiveRoot, deltagram)
+}
+
+// ApplyToMemoryFS applies a deltagram to a tree held entirely in memory, with the same
+// validation as an apply to disk. It touches no file system, so it also works where there
+// is none, such as in a browser. Progress lines are discarded unless opts.Output is set.
+func ApplyToMemoryFS(ctx context.Context, deltagram *parser.Deltagram, files map[string][]byte, opts Options) (*MemoryResult, error) {
+	mem, err := LoadMemoryFiles(files)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Output == nil {
+		opts.Output = io.Discard
+	}
+	result := &MemoryResult{Files: make(map[string][]byte)}
+	warn := opts.Warn
+	opts.Warn = func(w Warning) {
+		result.Warnings = append(result.Warnings, w)
+		if warn != nil {
+			warn(w)
+		}
+	}
+
+	recorder := NewRecordingFileSystem(mem)
+	if err := NewApplierWithOptions(recorder, opts).ApplyContext(ctx, deltagram, ArchiveRoot); err != nil {
+		return nil, err
+	}
+
+	changes := recorder.Changes()
+	result.Diff = FormatChanges(changes, ArchiveRoot)
+	for _, change := range changes {
+		change.Path = memoryPath(change.Path)
+		result.Changes = append(result.Changes, change)
+	}
+	for name, data := range mem.Files() {
+		result.Files[memoryPath(name)] = data
+	}
+	return result, nil
+}
+
+// memoryPath returns the slash-separated path of name relative to ArchiveRoot
+func memoryPath(name string) string {
+	rel, err := filepath.Rel(ArchiveRoot, name)
+	if err != nil {
+		return filepath.ToSlash(name)
+	}
+	return filepath.ToSlash(rel)
+}
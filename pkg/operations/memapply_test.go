@@ -0,0 +1,94 @@
+package operations
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func TestApplyToMemoryFS(t *testing.T) {
+	files := map[string][]byte{
+		"src/main.txt": []byte("one\ntwo\nthree"),
+		"old.txt":      []byte("old"),
+	}
+	deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{
+		{ContentLocation: "src/main.txt", ContentType: "text/plain", DeltaOperation: "content", Content: "@@ -1,3 +1,3 @@\n one\n-two\n+TWO\n three"},
+		{ContentLocation: "docs/new.txt", ContentType: "text/plain", DeltaOperation: "create", Content: "+++ docs/new.txt\nnew"},
+		{ContentLocation: "old.txt", ContentType: "text/plain", DeltaOperation: "delete"},
+	}}
+
+	result, err := ApplyToMemoryFS(context.Background(), deltagram, files, Options{})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if string(result.Files["src/main.txt"]) != "one\nTWO\nthree" {
+		t.Errorf("Expected src/main.txt to be patched, got %q", result.Files["src/main.txt"])
+	}
+	if string(result.Files["docs/new.txt"]) != "new" {
+		t.Errorf("Expected docs/new.txt to be created, got %q", result.Files["docs/new.txt"])
+	}
+	if _, exists := result.Files["old.txt"]; exists {
+		t.Error("Expected old.txt to be deleted")
+	}
+	if len(result.Changes) != 3 || result.Changes[0].Path != "src/main.txt" {
+		t.Errorf("Expected 3 changes starting with src/main.txt, got %+v", result.Changes)
+	}
+	if !strings.Contains(result.Diff, "+++ b/src/main.txt") || !strings.Contains(result.Diff, "+TWO") {
+		t.Errorf("Expected a diff of src/main.txt, got:\n%s", result.Diff)
+	}
+	if string(files["src/main.txt"]) != "one\ntwo\nthree" {
+		t.Error("Expected the input files to be left unchanged")
+	}
+}
+
+func TestApplyToMemoryFS_Errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     map[string][]byte
+		deltagram *parser.Deltagram
+	}{
+		{
+			name:  "mismatched hunk",
+			files: map[string][]byte{"a.txt": []byte("one\n")},
+			deltagram: &parser.Deltagram{Parts: []parser.DeltagramPart{
+				{ContentLocation: "a.txt", ContentType: "text/plain", DeltaOperation: "content", Content: "@@ -1,1 +1,1 @@\n-two\n+three"},
+			}},
+		},
+		{
+			name:  "path outside the tree",
+			files: map[string][]byte{"../a.txt": []byte("one\n")},
+			deltagram: &parser.Deltagram{Parts: []parser.DeltagramPart{
+				{ContentLocation: "b.txt", ContentType: "text/plain", DeltaOperation: "create", Content: "+++ b.txt\nb"},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ApplyToMemoryFS(context.Background(), tt.deltagram, tt.files, Options{}); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestPlanMemoryFS(t *testing.T) {
+	files := map[string][]byte{"a.txt": []byte("one\n")}
+	deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{
+		{ContentLocation: "a.txt", ContentType: "text/plain", DeltaOperation: "delete"},
+	}}
+
+	plan, err := PlanMemoryFS(deltagram, files)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(plan.Steps) != 1 || len(plan.Steps[0].Preconditions) != 1 {
+		t.Fatalf("Expected one step with one precondition, got %+v", plan.Steps)
+	}
+	pre := plan.Steps[0].Preconditions[0]
+	if pre.Path != "a.txt" || !pre.Exists || pre.SHA256 == "" {
+		t.Errorf("Expected a.txt to be recorded as existing with a hash, got %+v", pre)
+	}
+}
@@ -0,0 +1,463 @@
+package operations
+
+import (
+	"bytes"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxSymlinkDepth bounds symlink resolution so that loops are reported instead of hanging
+const maxSymlinkDepth = 40
+
+// memNode is a file, directory, or symlink stored in a MemoryFileSystem
+type memNode struct {
+	data    []byte
+	target  string // Symlink target when mode has os.ModeSymlink
+	mode    os.FileMode
+	modTime time.Time
+}
+
+// MemoryFileSystem is an in-memory FileSystem, safe for concurrent use. It allows
+// deltagrams to be applied to virtual trees, for example to preview results without
+// touching the disk. Paths are cleaned with filepath.Clean; the root and "." always exist.
+type MemoryFileSystem struct {
+	mu    sync.RWMutex
+	nodes map[string]*memNode
+	now   func() time.Time
+}
+
+// NewMemoryFileSystem creates an empty in-memory file system
+func NewMemoryFileSystem() *MemoryFileSystem {
+	return &MemoryFileSystem{
+		nodes: make(map[string]*memNode),
+		now:   time.Now,
+	}
+}
+
+// Files returns a copy of every regular file's content keyed by path
+func (m *MemoryFileSystem) Files() map[string][]byte {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	files := make(map[string][]byte)
+	for path, node := range m.nodes {
+		if node.mode.IsRegular() {
+			files[path] = bytes.Clone(node.data)
+		}
+	}
+	return files
+}
+
+func (m *MemoryFileSystem) ReadFile(filename string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	node, _, err := m.lookup("open", filename, true)
+	if err != nil {
+		return nil, err
+	}
+	if node.mode.IsDir() {
+		return nil, &iofs.PathError{Op: "read", Path: filename, Err: errIsDir}
+	}
+	return bytes.Clone(node.data), nil
+}
+
+func (m *MemoryFileSystem) WriteFile(filename string, data []byte, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.writeFile(filename, data, perm)
+}
+
+func (m *MemoryFileSystem) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path, err := m.resolveParent("remove", name)
+	if err != nil {
+		return err
+	}
+	node, exists := m.nodes[path]
+	if !exists {
+		return &iofs.PathError{Op: "remove", Path: name, Err: iofs.ErrNotExist}
+	}
+	if node.mode.IsDir() && m.hasChildren(path) {
+		return &iofs.PathError{Op: "remove", Path: name, Err: errNotEmpty}
+	}
+
+	delete(m.nodes, path)
+	return nil
+}
+
+func (m *MemoryFileSystem) Rename(oldpath, newpath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	from, err := m.resolveParent("rename", oldpath)
+	if err != nil {
+		return err
+	}
+	node, exists := m.nodes[from]
+	if !exists {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: iofs.ErrNotExist}
+	}
+
+	to, err := m.resolveParent("rename", newpath)
+	if err != nil {
+		return err
+	}
+	if existing, exists := m.nodes[to]; exists && existing.mode.IsDir() {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: iofs.ErrExist}
+	}
+
+	// Directories move together with everything beneath them
+	prefix := from + string(filepath.Separator)
+	for path, child := range m.nodes {
+		if strings.HasPrefix(path, prefix) {
+			delete(m.nodes, path)
+			m.nodes[to+string(filepath.Separator)+strings.TrimPrefix(path, prefix)] = child
+		}
+	}
+	delete(m.nodes, from)
+	m.nodes[to] = node
+	return nil
+}
+
+func (m *MemoryFileSystem) MkdirAll(path string, perm os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	resolved, err := m.resolve("mkdir", path, true)
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	for current := resolved; !isRoot(current); current = filepath.Dir(current) {
+		if node, exists := m.nodes[current]; exists {
+			if !node.mode.IsDir() {
+				return &iofs.PathError{Op: "mkdir", Path: current, Err: errNotDir}
+			}
+			break
+		}
+		missing = append(missing, current)
+	}
+
+	for i := len(missing) - 1; i >= 0; i-- {
+		m.nodes[missing[i]] = &memNode{mode: os.ModeDir | perm.Perm(), modTime: m.now()}
+	}
+	return nil
+}
+
+func (m *MemoryFileSystem) Stat(name string) (os.FileInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	node, _, err := m.lookup("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return &memFileInfo{name: filepath.Base(name), node: node}, nil
+}
+
+func (m *MemoryFileSystem) Lstat(name string) (os.FileInfo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	node, _, err := m.lookup("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return &memFileInfo{name: filepath.Base(name), node: node}, nil
+}
+
+func (m *MemoryFileSystem) ReadDir(name string) ([]os.DirEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	node, dir, err := m.lookup("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if !node.mode.IsDir() {
+		return nil, &iofs.PathError{Op: "readdir", Path: name, Err: errNotDir}
+	}
+
+	var entries []os.DirEntry
+	for path, child := range m.nodes {
+		if path != dir && filepath.Dir(path) == dir {
+			entries = append(entries, iofs.FileInfoToDirEntry(&memFileInfo{name: filepath.Base(path), node: child}))
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+func (m *MemoryFileSystem) Open(name string) (io.ReadCloser, error) {
+	data, err := m.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *MemoryFileSystem) Create(name string) (io.WriteCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Truncate immediately, as os.Create does, so missing directories fail early
+	if err := m.writeFile(name, nil, 0644); err != nil {
+		return nil, err
+	}
+	return &memWriter{fs: m, name: name}, nil
+}
+
+func (m *MemoryFileSystem) Chtimes(name string, atime, mtime time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, _, err := m.lookup("chtimes", name, true)
+	if err != nil {
+		return err
+	}
+	node.modTime = mtime
+	return nil
+}
+
+func (m *MemoryFileSystem) Chmod(name string, mode os.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, _, err := m.lookup("chmod", name, true)
+	if err != nil {
+		return err
+	}
+	node.mode = node.mode&os.ModeType | mode.Perm()
+	return nil
+}
+
+func (m *MemoryFileSystem) Truncate(name string, size int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	node, _, err := m.lookup("truncate", name, true)
+	if err != nil {
+		return err
+	}
+	if node.mode.IsDir() {
+		return &iofs.PathError{Op: "truncate", Path: name, Err: errIsDir}
+	}
+	if size < 0 {
+		return &iofs.PathError{Op: "truncate", Path: name, Err: iofs.ErrInvalid}
+	}
+	// Like os.Truncate, growing a file pads it with zero bytes
+	data := make([]byte, size)
+	copy(data, node.data)
+	node.data = data
+	node.modTime = m.now()
+	return nil
+}
+
+func (m *MemoryFileSystem) Symlink(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path, err := m.resolveParent("symlink", newname)
+	if err != nil {
+		return err
+	}
+	if _, exists := m.nodes[path]; exists {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: iofs.ErrExist}
+	}
+	if err := m.checkParentDir("symlink", path); err != nil {
+		return err
+	}
+
+	m.nodes[path] = &memNode{target: oldname, mode: os.ModeSymlink | 0777, modTime: m.now()}
+	return nil
+}
+
+func (m *MemoryFileSystem) EvalSymlinks(path string) (string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, resolved, err := m.lookup("lstat", path, true)
+	if err != nil {
+		return "", err
+	}
+	return resolved, nil
+}
+
+// writeFile stores data at filename; callers must hold the write lock
+func (m *MemoryFileSystem) writeFile(filename string, data []byte, perm os.FileMode) error {
+	path, err := m.resolve("open", filename, true)
+	if err != nil {
+		return err
+	}
+
+	if node, exists := m.nodes[path]; exists {
+		if node.mode.IsDir() {
+			return &iofs.PathError{Op: "open", Path: filename, Err: errIsDir}
+		}
+		// Like os.WriteFile, existing files keep their permissions
+		node.data = bytes.Clone(data)
+		node.modTime = m.now()
+		return nil
+	}
+
+	if err := m.checkParentDir("open", path); err != nil {
+		return err
+	}
+
+	m.nodes[path] = &memNode{data: bytes.Clone(data), mode: perm.Perm(), modTime: m.now()}
+	return nil
+}
+
+// lookup resolves name and returns its node; callers must hold the lock
+func (m *MemoryFileSystem) lookup(op, name string, followFinal bool) (*memNode, string, error) {
+	path, err := m.resolve(op, name, followFinal)
+	if err != nil {
+		return nil, "", err
+	}
+	if isRoot(path) {
+		return &memNode{mode: os.ModeDir | 0755}, path, nil
+	}
+
+	node, exists := m.nodes[path]
+	if !exists {
+		return nil, "", &iofs.PathError{Op: op, Path: name, Err: iofs.ErrNotExist}
+	}
+	return node, path, nil
+}
+
+// resolveParent resolves symlinks in every component except the last
+func (m *MemoryFileSystem) resolveParent(op, name string) (string, error) {
+	return m.resolve(op, name, false)
+}
+
+// resolve follows symlinks in the components of name, including the final component
+// when followFinal is set; callers must hold the lock
+func (m *MemoryFileSystem) resolve(op, name string, followFinal bool) (string, error) {
+	path := filepath.Clean(name)
+
+	for depth := 0; depth < maxSymlinkDepth; depth++ {
+		resolved, changed := m.resolveOnce(path, followFinal)
+		if !changed {
+			return resolved, nil
+		}
+		path = resolved
+	}
+	return "", &iofs.PathError{Op: op, Path: name, Err: errSymlinkLoop}
+}
+
+// resolveOnce replaces the first symlink found along path with its target
+func (m *MemoryFileSystem) resolveOnce(path string, followFinal bool) (string, bool) {
+	components := strings.Split(path, string(filepath.Separator))
+	for i := 1; i <= len(components); i++ {
+		if i == len(components) && !followFinal {
+			break
+		}
+
+		prefix := strings.Join(components[:i], string(filepath.Separator))
+		if prefix == "" {
+			continue
+		}
+		node, exists := m.nodes[prefix]
+		if !exists || node.mode&os.ModeSymlink == 0 {
+			continue
+		}
+
+		target := node.target
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(prefix), target)
+		}
+		rest := components[i:]
+		return filepath.Join(append([]string{target}, rest...)...), true
+	}
+	return path, false
+}
+
+// checkParentDir verifies that the directory containing path exists
+func (m *MemoryFileSystem) checkParentDir(op, path string) error {
+	parent := filepath.Dir(path)
+	if isRoot(parent) {
+		return nil
+	}
+	node, exists := m.nodes[parent]
+	if !exists {
+		return &iofs.PathError{Op: op, Path: path, Err: iofs.ErrNotExist}
+	}
+	if !node.mode.IsDir() {
+		return &iofs.PathError{Op: op, Path: path, Err: errNotDir}
+	}
+	return nil
+}
+
+// hasChildren reports whether any node lives beneath dir
+func (m *MemoryFileSystem) hasChildren(dir string) bool {
+	prefix := dir + string(filepath.Separator)
+	for path := range m.nodes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRoot reports whether a cleaned path denotes the root or current directory
+func isRoot(path string) bool {
+	return path == "." || path == string(filepath.Separator) || filepath.Dir(path) == path
+}
+
+// memErr is a sentinel error message for MemoryFileSystem failures without an fs equivalent
+type memErr string
+
+func (e memErr) Error() string { return string(e) }
+
+const (
+	errIsDir       memErr = "is a directory"
+	errNotDir      memErr = "not a directory"
+	errNotEmpty    memErr = "directory not empty"
+	errSymlinkLoop memErr = "too many levels of symbolic links"
+)
+
+// memFileInfo implements os.FileInfo for a memNode
+type memFileInfo struct {
+	name string
+	node *memNode
+}
+
+func (fi *memFileInfo) Name() string { return fi.name }
+func (fi *memFileInfo) Size() int64 {
+	if fi.node.mode&os.ModeSymlink != 0 {
+		return int64(len(fi.node.target))
+	}
+	return int64(len(fi.node.data))
+}
+func (fi *memFileInfo) Mode() os.FileMode  { return fi.node.mode }
+func (fi *memFileInfo) ModTime() time.Time { return fi.node.modTime }
+func (fi *memFileInfo) IsDir() bool        { return fi.node.mode.IsDir() }
+func (fi *memFileInfo) Sys() interface{}   { return nil }
+
+// memWriter buffers writes and stores them in the file system on Close
+type memWriter struct {
+	fs     *MemoryFileSystem
+	name   string
+	buffer bytes.Buffer
+}
+
+func (w *memWriter) Write(p []byte) (int, error) {
+	return w.buffer.Write(p)
+}
+
+func (w *memWriter) Close() error {
+	w.fs.mu.Lock()
+	defer w.fs.mu.Unlock()
+	return w.fs.writeFile(w.name, w.buffer.Bytes(), 0644)
+}
@@ -0,0 +1,124 @@
+package operations
+
+import (
+	"os"
+	"testing"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func TestMemoryFileSystem_Basics(t *testing.T) {
+	fs := NewMemoryFileSystem()
+
+	if err := fs.WriteFile("/base/missing/file.txt", []byte("x"), 0644); !os.IsNotExist(err) {
+		t.Errorf("Expected not-exist error writing into a missing directory, got: %v", err)
+	}
+
+	if err := fs.MkdirAll("/base/src", 0755); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := fs.WriteFile("/base/src/a.txt", []byte("hello"), 0600); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	info, err := fs.Stat("/base/src/a.txt")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if info.Size() != 5 || info.Mode().Perm() != 0600 || info.IsDir() {
+		t.Errorf("Unexpected file info: size=%d mode=%v dir=%v", info.Size(), info.Mode(), info.IsDir())
+	}
+
+	if err := fs.Remove("/base/src"); err == nil {
+		t.Error("Expected error removing a non-empty directory")
+	}
+
+	if err := fs.Rename("/base/src", "/base/lib"); err != nil {
+		t.Fatalf("Expected no error renaming a directory, got: %v", err)
+	}
+	content, err := fs.ReadFile("/base/lib/a.txt")
+	if err != nil || string(content) != "hello" {
+		t.Errorf("Expected file to move with its directory, got %q (%v)", string(content), err)
+	}
+
+	entries, err := fs.ReadDir("/base")
+	if err != nil || len(entries) != 1 || entries[0].Name() != "lib" || !entries[0].IsDir() {
+		t.Errorf("Expected ReadDir to list lib/, got %v (%v)", entries, err)
+	}
+
+	if _, err := fs.Stat("/base/src/a.txt"); !os.IsNotExist(err) {
+		t.Errorf("Expected old path to be gone, got: %v", err)
+	}
+
+	if err := fs.Truncate("/base/lib/a.txt", 2); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	info, err = fs.Stat("/base/lib/a.txt")
+	if err != nil || info.Size() != 2 || info.Mode().Perm() != 0600 {
+		t.Errorf("Expected truncate to keep 2 bytes and the mode, got %v (%v)", info, err)
+	}
+	if err := fs.Truncate("/base/lib", 0); err == nil {
+		t.Error("Expected error truncating a directory")
+	}
+}
+
+func TestMemoryFileSystem_Symlinks(t *testing.T) {
+	fs := NewMemoryFileSystem()
+	fs.MkdirAll("/base/real", 0755)
+	fs.WriteFile("/base/real/file.txt", []byte("data"), 0644)
+
+	if err := fs.Symlink("real", "/base/link"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	content, err := fs.ReadFile("/base/link/file.txt")
+	if err != nil || string(content) != "data" {
+		t.Errorf("Expected to read through symlink, got %q (%v)", string(content), err)
+	}
+
+	info, err := fs.Lstat("/base/link")
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Expected Lstat to report a symlink, got %v (%v)", info, err)
+	}
+
+	resolved, err := fs.EvalSymlinks("/base/link/file.txt")
+	if err != nil || resolved != "/base/real/file.txt" {
+		t.Errorf("Expected /base/real/file.txt, got %q (%v)", resolved, err)
+	}
+
+	fs.Symlink("loop", "/base/loop")
+	if _, err := fs.Stat("/base/loop"); err == nil {
+		t.Error("Expected error resolving a symlink loop")
+	}
+}
+
+func TestMemoryFileSystem_ApplyDeltagram(t *testing.T) {
+	fs := NewMemoryFileSystem()
+	fs.MkdirAll("/project", 0755)
+	fs.WriteFile("/project/main.go", []byte("package main\n\nfunc main() {}"), 0644)
+
+	deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{
+		{ContentLocation: "pkg/util.go", DeltaOperation: "create", Content: "+++ pkg/util.go\npackage pkg"},
+		{ContentLocation: "main.go", DeltaOperation: "content", Content: "@@ -1,1 +1,1 @@\n-package main\n+package app"},
+		{ContentLocation: "pkg/copy.go", DeltaOperation: "copy", Content: "--- pkg/util.go\n+++ pkg/copy.go"},
+	}}
+
+	if err := NewApplier(fs).Apply(deltagram, "/project"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	files := fs.Files()
+	expected := map[string]string{
+		"/project/main.go":     "package app\n\nfunc main() {}",
+		"/project/pkg/util.go": "package pkg",
+		"/project/pkg/copy.go": "package pkg",
+	}
+	if len(files) != len(expected) {
+		t.Errorf("Expected %d files, got %d", len(expected), len(files))
+	}
+	for path, content := range expected {
+		if string(files[path]) != content {
+			t.Errorf("Expected %s to contain %q, got %q", path, content, string(files[path]))
+		}
+	}
+}
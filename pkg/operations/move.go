@@ -2,6 +2,7 @@ package operations
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 
@@ -9,7 +10,10 @@ import (
 )
 
 // MoveHandler handles file move/rename operations
-type MoveHandler struct{}
+type MoveHandler struct {
+	warn   warnFunc
+	report reportFunc
+}
 
 // NewMoveHandler creates a new move handler
 func NewMoveHandler() OperationHandler {
@@ -24,34 +28,65 @@ func (h *MoveHandler) CanHandle(operation string) bool {
 // Apply moves/renames a file from source to destination
 func (h *MoveHandler) Apply(fs FileSystem, baseDir string, part parser.DeltagramPart) error {
 	// Parse move operation content to get source and destination
-	lines := strings.Split(part.Content, "\n")
-	var sourcePath, destPath string
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "---") {
-			sourcePath = strings.TrimSpace(strings.TrimPrefix(line, "---"))
-		} else if strings.HasPrefix(line, "+++") {
-			destPath = strings.TrimSpace(strings.TrimPrefix(line, "+++"))
-		}
+	sourcePath, destPath, legacy, err := transferPaths(part)
+	if err != nil {
+		return err
 	}
-
-	if sourcePath == "" || destPath == "" {
-		return fmt.Errorf("invalid move operation: missing source or destination path")
+	if legacy {
+		h.warn.warnLegacy(part, destPath)
 	}
 
 	sourceFullPath := ResolveFilePath(baseDir, sourcePath)
 	destFullPath := ResolveFilePath(baseDir, destPath)
 
+	// Compute edited content up front so a bad hunk leaves the source untouched
+	contentHandler := &ContentHandler{warn: h.warn}
+	hunks, err := contentHandler.ParseAllHunks(strings.Split(part.Content, "\n"))
+	if err != nil {
+		return err
+	}
+
+	var modifiedContent string
+	hasHunks := len(hunks) > 0
+	if hasHunks {
+		existingContent, err := fs.ReadFile(sourceFullPath)
+		if os.IsNotExist(err) {
+			return fmt.Errorf("cannot move %w: %s", ErrFileNotFound, sourcePath)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read source file: %w", err)
+		}
+
+		modifiedContent, err = contentHandler.applyUnifiedDiff(part.ContentLocation, string(existingContent), part.Content)
+		if err != nil {
+			return fmt.Errorf("failed to apply diff: %w", withFile(err, sourcePath))
+		}
+	}
+
 	// Ensure destination directory exists
 	if err := fs.MkdirAll(filepath.Dir(destFullPath), 0755); err != nil {
-		return fmt.Errorf("failed to create destination directory: %v", err)
+		return fmt.Errorf("failed to create destination directory: %w", err)
 	}
 
 	if err := fs.Rename(sourceFullPath, destFullPath); err != nil {
-		return fmt.Errorf("failed to move file: %v", err)
+		if os.IsNotExist(err) {
+			return fmt.Errorf("cannot move %w: %s", ErrFileNotFound, sourcePath)
+		}
+		return fmt.Errorf("failed to move file: %w", err)
+	}
+
+	if hasHunks {
+		perm := os.FileMode(0644)
+		if info, err := fs.Stat(destFullPath); err == nil {
+			perm = info.Mode().Perm()
+		}
+		if err := fs.WriteFile(destFullPath, []byte(modifiedContent), perm); err != nil {
+			return fmt.Errorf("failed to write modified file: %w", err)
+		}
+		h.report.printf("Moved and modified: %s -> %s\n", sourcePath, destPath)
+		return nil
 	}
 
-	fmt.Printf("Moved: %s -> %s\n", sourcePath, destPath)
+	h.report.printf("Moved: %s -> %s\n", sourcePath, destPath)
 	return nil
 }
@@ -0,0 +1,98 @@
+package operations
+
+import (
+	"testing"
+
+	"github.com/developingjames/deltagrams/internal/testutil"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func TestMoveHandler_Apply(t *testing.T) {
+	handler := NewMoveHandler()
+	fs := testutil.NewMockFileSystem()
+
+	fs.AddFile("/base/old.txt", []byte("Original content"))
+
+	part := parser.DeltagramPart{
+		ContentLocation: "old.txt",
+		ContentType:     "application/x-deltagram-fileop; charset=utf-8",
+		DeltaOperation:  "move",
+		Content:         "--- old.txt\n+++ renamed/new.txt",
+	}
+
+	err := handler.Apply(fs, "/base", part)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if fs.FileExists("/base/old.txt") {
+		t.Error("Expected source file to be removed")
+	}
+
+	content, err := fs.ReadFile("/base/renamed/new.txt")
+	if err != nil {
+		t.Fatalf("Failed to read moved file: %v", err)
+	}
+
+	if string(content) != "Original content" {
+		t.Errorf("Expected content %q, got %q", "Original content", string(content))
+	}
+}
+
+func TestMoveHandler_Apply_WithHunks(t *testing.T) {
+	handler := NewMoveHandler()
+	fs := testutil.NewMockFileSystem()
+
+	fs.AddFile("/base/old.go", []byte("package old\n\nfunc Hello() {}"))
+
+	part := parser.DeltagramPart{
+		ContentLocation: "old.go",
+		ContentType:     "application/x-deltagram-fileop; charset=utf-8",
+		DeltaOperation:  "move",
+		Content:         "--- old.go\n+++ pkg/new.go\n@@ -1,3 +1,3 @@\n-package old\n+package new\n \n func Hello() {}",
+	}
+
+	err := handler.Apply(fs, "/base", part)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if fs.FileExists("/base/old.go") {
+		t.Error("Expected source file to be removed")
+	}
+
+	content, err := fs.ReadFile("/base/pkg/new.go")
+	if err != nil {
+		t.Fatalf("Failed to read moved file: %v", err)
+	}
+
+	expected := "package new\n\nfunc Hello() {}"
+	if string(content) != expected {
+		t.Errorf("Expected content %q, got %q", expected, string(content))
+	}
+}
+
+func TestMoveHandler_Apply_WithBadHunkLeavesSource(t *testing.T) {
+	handler := NewMoveHandler()
+	fs := testutil.NewMockFileSystem()
+
+	fs.AddFile("/base/old.txt", []byte("line 1\nline 2"))
+
+	part := parser.DeltagramPart{
+		ContentLocation: "old.txt",
+		ContentType:     "application/x-deltagram-fileop; charset=utf-8",
+		DeltaOperation:  "move",
+		Content:         "--- old.txt\n+++ new.txt\n@@ -1,1 +1,1 @@\n-something else\n+replacement",
+	}
+
+	if err := handler.Apply(fs, "/base", part); err == nil {
+		t.Fatal("Expected error for mismatched hunk, got none")
+	}
+
+	if !fs.FileExists("/base/old.txt") {
+		t.Error("Expected source file to remain after failed move")
+	}
+	if fs.FileExists("/base/new.txt") {
+		t.Error("Expected destination file not to be created")
+	}
+}
@@ -0,0 +1,275 @@
+package operations
+
+import (
+	"io"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// OverlayRoot is the base directory under which an OverlayFileSystem exposes its fs.FS
+var OverlayRoot = string(filepath.Separator)
+
+// Overlay describes the result of applying a deltagram without writing it to disk
+type Overlay struct {
+	// Files maps slash-separated paths, relative to the base, to their new content
+	Files map[string][]byte
+	// Deleted lists slash-separated paths of base files that no longer exist
+	Deleted []string
+}
+
+// OverlayFileSystem is a FileSystem that reads from a read-only fs.FS and keeps every
+// change in memory. Files are copied from the base lazily the first time they are touched.
+// Paths are resolved relative to OverlayRoot.
+type OverlayFileSystem struct {
+	base         iofs.FS
+	upper        *MemoryFileSystem
+	materialized map[string]bool
+	written      map[string]bool
+	deleted      map[string]bool
+}
+
+// NewOverlayFileSystem creates an overlay on top of the given read-only file system
+func NewOverlayFileSystem(base iofs.FS) *OverlayFileSystem {
+	return &OverlayFileSystem{
+		base:         base,
+		upper:        NewMemoryFileSystem(),
+		materialized: make(map[string]bool),
+		written:      make(map[string]bool),
+		deleted:      make(map[string]bool),
+	}
+}
+
+// ApplyToOverlay applies a deltagram to the files in base and returns the resulting
+// changes as an overlay instead of writing them anywhere
+func ApplyToOverlay(deltagram *parser.Deltagram, base iofs.FS, opts Options) (*Overlay, error) {
+	overlay := NewOverlayFileSystem(base)
+	if err := NewApplierWithOptions(overlay, opts).Apply(deltagram, OverlayRoot); err != nil {
+		return nil, err
+	}
+	return overlay.Overlay(), nil
+}
+
+// Overlay returns the changes made so far relative to the base file system
+func (o *OverlayFileSystem) Overlay() *Overlay {
+	result := &Overlay{Files: make(map[string][]byte)}
+
+	for path := range o.written {
+		if content, err := o.upper.ReadFile(path); err == nil {
+			result.Files[o.relPath(path)] = content
+		}
+	}
+	for path := range o.deleted {
+		result.Deleted = append(result.Deleted, o.relPath(path))
+	}
+	sort.Strings(result.Deleted)
+
+	return result
+}
+
+func (o *OverlayFileSystem) ReadFile(filename string) ([]byte, error) {
+	o.materialize(filename)
+	return o.upper.ReadFile(filename)
+}
+
+func (o *OverlayFileSystem) WriteFile(filename string, data []byte, perm os.FileMode) error {
+	o.materialize(filename)
+	if err := o.upper.WriteFile(filename, data, perm); err != nil {
+		return err
+	}
+	o.markWritten(filename)
+	return nil
+}
+
+func (o *OverlayFileSystem) Remove(name string) error {
+	o.materialize(name)
+	if err := o.upper.Remove(name); err != nil {
+		return err
+	}
+	o.markRemoved(name)
+	return nil
+}
+
+func (o *OverlayFileSystem) Rename(oldpath, newpath string) error {
+	o.materialize(oldpath)
+	o.materialize(newpath)
+	if err := o.upper.Rename(oldpath, newpath); err != nil {
+		return err
+	}
+	o.markRemoved(oldpath)
+	o.markWritten(newpath)
+	return nil
+}
+
+func (o *OverlayFileSystem) MkdirAll(path string, perm os.FileMode) error {
+	o.materialize(path)
+	return o.upper.MkdirAll(path, perm)
+}
+
+func (o *OverlayFileSystem) Stat(name string) (os.FileInfo, error) {
+	o.materialize(name)
+	return o.upper.Stat(name)
+}
+
+func (o *OverlayFileSystem) Lstat(name string) (os.FileInfo, error) {
+	o.materialize(name)
+	return o.upper.Lstat(name)
+}
+
+func (o *OverlayFileSystem) ReadDir(name string) ([]os.DirEntry, error) {
+	o.materialize(name)
+
+	// Bring every base entry into the upper layer so the listing reflects both
+	if entries, err := iofs.ReadDir(o.base, o.basePath(name)); err == nil {
+		for _, entry := range entries {
+			o.materialize(filepath.Join(name, entry.Name()))
+		}
+	}
+	return o.upper.ReadDir(name)
+}
+
+func (o *OverlayFileSystem) Open(name string) (io.ReadCloser, error) {
+	o.materialize(name)
+	return o.upper.Open(name)
+}
+
+func (o *OverlayFileSystem) Create(name string) (io.WriteCloser, error) {
+	o.materialize(name)
+	w, err := o.upper.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	o.markWritten(name)
+	return w, nil
+}
+
+func (o *OverlayFileSystem) Chtimes(name string, atime, mtime time.Time) error {
+	o.materialize(name)
+	return o.upper.Chtimes(name, atime, mtime)
+}
+
+func (o *OverlayFileSystem) Chmod(name string, mode os.FileMode) error {
+	o.materialize(name)
+	if err := o.upper.Chmod(name, mode); err != nil {
+		return err
+	}
+	o.markWritten(name)
+	return nil
+}
+
+func (o *OverlayFileSystem) Truncate(name string, size int64) error {
+	o.materialize(name)
+	if err := o.upper.Truncate(name, size); err != nil {
+		return err
+	}
+	o.markWritten(name)
+	return nil
+}
+
+func (o *OverlayFileSystem) Symlink(oldname, newname string) error {
+	o.materialize(newname)
+	if err := o.upper.Symlink(oldname, newname); err != nil {
+		return err
+	}
+	// The link's target must be in the upper layer for reads through the link to work
+	target := oldname
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(newname), target)
+	}
+	o.materialize(target)
+	o.markWritten(newname)
+	return nil
+}
+
+func (o *OverlayFileSystem) EvalSymlinks(path string) (string, error) {
+	o.materialize(path)
+	return o.upper.EvalSymlinks(path)
+}
+
+// materialize copies path and its parent directories from the base into the upper layer
+// the first time they are accessed, unless they were deleted in the overlay
+func (o *OverlayFileSystem) materialize(path string) {
+	path = filepath.Clean(path)
+
+	var chain []string
+	for current := path; !isRoot(current); current = filepath.Dir(current) {
+		chain = append(chain, current)
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		current := chain[i]
+		if o.materialized[current] {
+			continue
+		}
+		o.materialized[current] = true
+		if o.deleted[current] {
+			return
+		}
+
+		basePath := o.basePath(current)
+		if basePath == "" {
+			return
+		}
+		info, err := iofs.Stat(o.base, basePath)
+		if err != nil {
+			return
+		}
+
+		if info.IsDir() {
+			o.upper.MkdirAll(current, info.Mode().Perm())
+			continue
+		}
+
+		data, err := iofs.ReadFile(o.base, basePath)
+		if err != nil {
+			return
+		}
+		o.upper.MkdirAll(filepath.Dir(current), 0755)
+		o.upper.WriteFile(current, data, info.Mode().Perm())
+		o.upper.Chtimes(current, info.ModTime(), info.ModTime())
+		return
+	}
+}
+
+// markWritten records that path holds new content
+func (o *OverlayFileSystem) markWritten(path string) {
+	path = filepath.Clean(path)
+	o.written[path] = true
+	delete(o.deleted, path)
+}
+
+// markRemoved records that path no longer exists, reporting it as deleted only when the
+// base file system has it
+func (o *OverlayFileSystem) markRemoved(path string) {
+	path = filepath.Clean(path)
+	delete(o.written, path)
+	if basePath := o.basePath(path); basePath != "" {
+		if _, err := iofs.Stat(o.base, basePath); err == nil {
+			o.deleted[path] = true
+		}
+	}
+}
+
+// basePath converts an overlay path to a path in the base fs.FS, or "" when it lies
+// outside OverlayRoot
+func (o *OverlayFileSystem) basePath(path string) string {
+	rel := o.relPath(path)
+	if rel == ".." || strings.HasPrefix(rel, "../") || !iofs.ValidPath(rel) {
+		return ""
+	}
+	return rel
+}
+
+// relPath returns the slash-separated path relative to OverlayRoot
+func (o *OverlayFileSystem) relPath(path string) string {
+	rel, err := filepath.Rel(OverlayRoot, filepath.Clean(path))
+	if err != nil {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
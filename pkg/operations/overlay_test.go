@@ -0,0 +1,87 @@
+package operations
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func TestApplyToOverlay(t *testing.T) {
+	base := fstest.MapFS{
+		"main.go":       {Data: []byte("package main\n\nfunc main() {}")},
+		"old/notes.txt": {Data: []byte("notes")},
+		"obsolete.txt":  {Data: []byte("remove me")},
+		"untouched.txt": {Data: []byte("same")},
+	}
+
+	deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{
+		{ContentLocation: "deltagram://message", Content: "Overlay test"},
+		{ContentLocation: "pkg/util.go", DeltaOperation: "create", Content: "+++ pkg/util.go\npackage pkg"},
+		{ContentLocation: "main.go", DeltaOperation: "content", Content: "@@ -1,1 +1,1 @@\n-package main\n+package app"},
+		{ContentLocation: "old/notes.txt", DeltaOperation: "move", Content: "--- old/notes.txt\n+++ docs/notes.txt"},
+		{ContentLocation: "obsolete.txt", DeltaOperation: "delete"},
+	}}
+
+	overlay, err := ApplyToOverlay(deltagram, base, Options{})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expectedFiles := map[string]string{
+		"pkg/util.go":    "package pkg",
+		"main.go":        "package app\n\nfunc main() {}",
+		"docs/notes.txt": "notes",
+	}
+	if len(overlay.Files) != len(expectedFiles) {
+		t.Errorf("Expected %d changed files, got %d: %v", len(expectedFiles), len(overlay.Files), overlay.Files)
+	}
+	for path, content := range expectedFiles {
+		if string(overlay.Files[path]) != content {
+			t.Errorf("Expected %s to contain %q, got %q", path, content, string(overlay.Files[path]))
+		}
+	}
+
+	expectedDeleted := []string{"obsolete.txt", "old/notes.txt"}
+	if !reflect.DeepEqual(overlay.Deleted, expectedDeleted) {
+		t.Errorf("Expected deleted %v, got %v", expectedDeleted, overlay.Deleted)
+	}
+
+	// The base file system is never modified
+	if string(base["main.go"].Data) != "package main\n\nfunc main() {}" {
+		t.Error("Expected base file system to be untouched")
+	}
+}
+
+func TestApplyToOverlay_Error(t *testing.T) {
+	base := fstest.MapFS{"main.go": {Data: []byte("package main")}}
+
+	deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{
+		{ContentLocation: "main.go", DeltaOperation: "content", Content: "@@ -1,1 +1,1 @@\n-package other\n+package app"},
+	}}
+
+	if _, err := ApplyToOverlay(deltagram, base, Options{}); err == nil {
+		t.Error("Expected error for mismatched hunk, got none")
+	}
+}
+
+func TestOverlayFileSystem_SymlinkAndTruncate(t *testing.T) {
+	base := fstest.MapFS{
+		"target.txt": {Data: []byte("target")},
+		"app.log":    {Data: []byte("one\ntwo\n")},
+	}
+	overlay := NewOverlayFileSystem(base)
+
+	if err := overlay.Symlink("target.txt", OverlayRoot+"link.txt"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := overlay.Truncate(OverlayRoot+"app.log", 4); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := map[string][]byte{"link.txt": []byte("target"), "app.log": []byte("one\n")}
+	if files := overlay.Overlay().Files; !reflect.DeepEqual(files, expected) {
+		t.Errorf("Expected the overlay to hold %q, got %q", expected, files)
+	}
+}
@@ -0,0 +1,67 @@
+package operations
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// PatchID returns a fingerprint of what a deltagram changes, so that two deltagrams that
+// make the same changes are recognized as duplicates. It is the hex SHA-256 of the file
+// operations taken in sorted order, and leaves out everything that does not change a
+// file: the boundary identifier, metadata, message parts, the order of the parts, line
+// endings, and the line numbers and file headers of content hunks.
+func PatchID(deltagram *parser.Deltagram) string {
+	var parts []string
+	for _, part := range deltagram.Parts {
+		if isMessagePart(part) {
+			continue
+		}
+		sum := sha256.Sum256([]byte(canonicalPart(part)))
+		parts = append(parts, hex.EncodeToString(sum[:]))
+	}
+	sort.Strings(parts)
+
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// canonicalPart writes a part in the form PatchID hashes
+func canonicalPart(part parser.DeltagramPart) string {
+	operation := part.DeltaOperation
+	if operation == "" {
+		operation = "create"
+	}
+	if canonical, ok := parser.CanonicalOperation(operation); ok {
+		operation = canonical
+	}
+
+	var b strings.Builder
+	b.WriteString(operation + "\x00" + part.ContentLocation + "\x00")
+	names := make([]string, 0, len(part.Headers))
+	for name := range part.Headers {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value, _ := part.Header(name)
+		b.WriteString(name + ": " + value + "\x00")
+	}
+
+	content := strings.ReplaceAll(part.Content, "\r\n", "\n")
+	hunks, err := (&ContentHandler{}).ParseAllHunks(strings.Split(content, "\n"))
+	if err != nil || len(hunks) == 0 {
+		b.WriteString(content)
+		return b.String()
+	}
+	for _, hunk := range hunks {
+		b.WriteString("@@\n")
+		for _, op := range hunk.Operations {
+			b.WriteString(string(op.Type) + op.Content + "\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,34 @@
+package operations
+
+import (
+	"testing"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func TestPatchID(t *testing.T) {
+	base := &parser.Deltagram{UUID: "0123456789abcdef", Parts: []parser.DeltagramPart{
+		{ContentLocation: "deltagram://message", ContentType: "text/plain", Content: "Refactor"},
+		{ContentLocation: "a.go", ContentType: "text/plain", DeltaOperation: "content", Content: "--- a/a.go\n+++ b/a.go\n@@ -1,2 +1,2 @@\n x\n-a\n+A"},
+		{ContentLocation: "b.go", ContentType: "text/plain", DeltaOperation: "create", Content: "b\n"},
+	}}
+	same := &parser.Deltagram{UUID: "fedcba9876543210", Parts: []parser.DeltagramPart{
+		{ContentLocation: "b.go", ContentType: "text/plain", Content: "b\r\n"},
+		{ContentLocation: "a.go", ContentType: "text/plain", DeltaOperation: "content", Content: "@@ -7,2 +9,2 @@\n x\n-a\n+A"},
+	}}
+	different := &parser.Deltagram{UUID: "0123456789abcdef", Parts: []parser.DeltagramPart{
+		{ContentLocation: "a.go", ContentType: "text/plain", DeltaOperation: "content", Content: "@@ -1,2 +1,2 @@\n x\n-a\n+B"},
+		{ContentLocation: "b.go", ContentType: "text/plain", DeltaOperation: "create", Content: "b\n"},
+	}}
+
+	id := PatchID(base)
+	if len(id) != 64 {
+		t.Fatalf("Expected a hex SHA-256, got %q", id)
+	}
+	if got := PatchID(same); got != id {
+		t.Errorf("Expected reordered parts, another boundary, and moved hunks to keep patch-id %s, got %s", id, got)
+	}
+	if got := PatchID(different); got == id {
+		t.Errorf("Expected a different change to have another patch-id")
+	}
+}
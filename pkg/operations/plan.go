@@ -0,0 +1,168 @@
+package operations
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// PlanVersion is the version of the plan JSON schema
+const PlanVersion = 1
+
+// ErrPreconditionFailed is returned, wrapped, when a file has changed since the plan for
+// it was made
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// Plan lists the file operations a deltagram will perform along with the state of the
+// tree they expect. It carries the deltagram itself so that the reviewed plan, and only
+// that plan, can be executed later.
+type Plan struct {
+	Version   int               `json:"version"`
+	Deltagram string            `json:"deltagram"`           // Source text of the deltagram
+	Variables map[string]string `json:"variables,omitempty"` // Template variable values used
+	Steps     []PlanStep        `json:"steps"`
+}
+
+// PlanStep is one file operation of a plan
+type PlanStep struct {
+	Part          int            `json:"part"` // 1-based index of the part in the deltagram
+	Operation     string         `json:"operation"`
+	Source        string         `json:"source,omitempty"` // Source path of a copy or move
+	Target        string         `json:"target"`
+	Hunks         int            `json:"hunks"`
+	Preconditions []Precondition `json:"preconditions"`
+}
+
+// Precondition is the state a path must be in before the plan is executed
+type Precondition struct {
+	Path   string `json:"path"`
+	Exists bool   `json:"exists"`
+	SHA256 string `json:"sha256,omitempty"` // Content hash of an existing file
+}
+
+// BuildPlan lists the operations of a deltagram and records the current state of every
+// path they read or write under baseDir, including the content hash of existing files,
+// as preconditions. A path is recorded only for the first step that touches it, since
+// later steps see the result of earlier ones.
+func BuildPlan(fs FileSystem, baseDir string, deltagram *parser.Deltagram) (*Plan, error) {
+	plan := &Plan{Version: PlanVersion}
+	seen := make(map[string]bool)
+
+	for i, part := range deltagram.Parts {
+		if part.IsMessage() {
+			continue
+		}
+
+		step := PlanStep{Part: i + 1, Operation: part.DeltaOperation, Target: part.ContentLocation}
+		if step.Operation == "" {
+			step.Operation = "create"
+		}
+		paths := []string{step.Target}
+
+		switch step.Operation {
+		case "copy", "move":
+			source, dest, _, err := transferPaths(part)
+			if err != nil {
+				return nil, fmt.Errorf("part %d: %v", i+1, err)
+			}
+			step.Source, step.Target = source, dest
+			paths = []string{source, dest}
+			fallthrough
+		case "content":
+			stat := PartStat{}
+			countHunkLines(part.Content, &stat)
+			if stat.Problem != "" {
+				return nil, fmt.Errorf("invalid hunks in part %d: %s", i+1, stat.Problem)
+			}
+			step.Hunks = stat.Hunks
+		}
+
+		step.Preconditions = []Precondition{}
+		for _, path := range paths {
+			key := filepath.Clean(ResolveFilePath(baseDir, path))
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			pre, err := observe(fs, key)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check %s: %v", path, err)
+			}
+			pre.Path = path
+			step.Preconditions = append(step.Preconditions, pre)
+		}
+
+		plan.Steps = append(plan.Steps, step)
+	}
+
+	return plan, nil
+}
+
+// CheckPlan verifies that plan describes deltagram and that every precondition still
+// holds for the files under baseDir, so that files changed since planning are detected
+func CheckPlan(fs FileSystem, baseDir string, plan *Plan, deltagram *parser.Deltagram) error {
+	if plan.Version != PlanVersion {
+		return fmt.Errorf("unsupported plan version %d (expected %d)", plan.Version, PlanVersion)
+	}
+
+	current, err := BuildPlan(fs, baseDir, deltagram)
+	if err != nil {
+		return err
+	}
+	if len(current.Steps) != len(plan.Steps) {
+		return fmt.Errorf("plan lists %d steps but the deltagram has %d", len(plan.Steps), len(current.Steps))
+	}
+
+	for i, step := range plan.Steps {
+		actual := current.Steps[i]
+		if step.Part != actual.Part || step.Operation != actual.Operation || step.Source != actual.Source ||
+			step.Target != actual.Target || step.Hunks != actual.Hunks {
+			return fmt.Errorf("plan step %d (%s %s) does not match the deltagram (%s %s)",
+				i+1, step.Operation, step.Target, actual.Operation, actual.Target)
+		}
+
+		for _, pre := range step.Preconditions {
+			state, err := observe(fs, ResolveFilePath(baseDir, pre.Path))
+			if err != nil {
+				return fmt.Errorf("failed to check %s: %v", pre.Path, err)
+			}
+			switch {
+			case state.Exists != pre.Exists && pre.Exists:
+				return fmt.Errorf("%w: %s no longer exists", ErrPreconditionFailed, pre.Path)
+			case state.Exists != pre.Exists:
+				return fmt.Errorf("%w: %s exists but did not when planned", ErrPreconditionFailed, pre.Path)
+			case pre.SHA256 != "" && state.SHA256 != pre.SHA256:
+				return fmt.Errorf("%w: %s changed since the plan was made", ErrPreconditionFailed, pre.Path)
+			}
+		}
+	}
+
+	return nil
+}
+
+// observe returns whether the file at path exists and, if it is a regular file, the
+// hash of its content
+func observe(fs FileSystem, path string) (Precondition, error) {
+	info, err := fs.Stat(path)
+	if os.IsNotExist(err) {
+		return Precondition{}, nil
+	}
+	if err != nil {
+		return Precondition{}, err
+	}
+	if !info.Mode().IsRegular() {
+		return Precondition{Exists: true}, nil
+	}
+
+	data, err := fs.ReadFile(path)
+	if err != nil {
+		return Precondition{}, err
+	}
+	sum := sha256.Sum256(data)
+	return Precondition{Exists: true, SHA256: hex.EncodeToString(sum[:])}, nil
+}
@@ -0,0 +1,113 @@
+package operations
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/developingjames/deltagrams/internal/testutil"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func planDeltagram() *parser.Deltagram {
+	return &parser.Deltagram{Parts: []parser.DeltagramPart{
+		{ContentLocation: "deltagram://message", ContentType: "text/plain", Content: "Summary"},
+		{ContentLocation: "new.txt", DeltaOperation: "create", Content: "+++ new.txt\nhello"},
+		{ContentLocation: "file.txt", DeltaOperation: "content", Content: "@@ -1,1 +1,1 @@\n-a\n+A\n@@ -3,1 +3,1 @@\n-c\n+C"},
+		{ContentLocation: "new.txt", DeltaOperation: "content", Content: "@@ -1,1 +1,1 @@\n-hello\n+hi"},
+		{ContentLocation: "dst.txt", DeltaOperation: "move", Content: "--- file.txt\n+++ dst.txt"},
+	}}
+}
+
+func TestBuildPlan(t *testing.T) {
+	fs := testutil.NewMockFileSystem()
+	fs.AddFile("/base/file.txt", []byte("a\nb\nc"))
+
+	plan, err := BuildPlan(fs, "/base", planDeltagram())
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	sum := sha256.Sum256([]byte("a\nb\nc"))
+	fileHash := hex.EncodeToString(sum[:])
+
+	if plan.Version != PlanVersion {
+		t.Errorf("Expected version %d, got %d", PlanVersion, plan.Version)
+	}
+	if len(plan.Steps) != 4 {
+		t.Fatalf("Expected 4 steps, got %d", len(plan.Steps))
+	}
+
+	tests := []struct {
+		name          string
+		step          PlanStep
+		part          int
+		operation     string
+		source        string
+		target        string
+		hunks         int
+		preconditions []Precondition
+	}{
+		{"create", plan.Steps[0], 2, "create", "", "new.txt", 0, []Precondition{{Path: "new.txt"}}},
+		{"content", plan.Steps[1], 3, "content", "", "file.txt", 2, []Precondition{{Path: "file.txt", Exists: true, SHA256: fileHash}}},
+		{"content of created file", plan.Steps[2], 4, "content", "", "new.txt", 1, []Precondition{}},
+		{"move", plan.Steps[3], 5, "move", "file.txt", "dst.txt", 0, []Precondition{{Path: "dst.txt"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step := tt.step
+			if step.Part != tt.part || step.Operation != tt.operation || step.Source != tt.source ||
+				step.Target != tt.target || step.Hunks != tt.hunks {
+				t.Errorf("Unexpected step: %+v", step)
+			}
+			if len(step.Preconditions) != len(tt.preconditions) {
+				t.Fatalf("Expected preconditions %+v, got %+v", tt.preconditions, step.Preconditions)
+			}
+			for i, pre := range tt.preconditions {
+				if step.Preconditions[i] != pre {
+					t.Errorf("Expected precondition %+v, got %+v", pre, step.Preconditions[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCheckPlan(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(fs *testutil.MockFileSystem, plan *Plan)
+		expected string
+	}{
+		{"unchanged", func(*testutil.MockFileSystem, *Plan) {}, ""},
+		{"file removed", func(fs *testutil.MockFileSystem, _ *Plan) { fs.Remove("/base/file.txt") }, "file.txt no longer exists"},
+		{"file edited", func(fs *testutil.MockFileSystem, _ *Plan) { fs.AddFile("/base/file.txt", []byte("a\nB\nc")) }, "file.txt changed since the plan was made"},
+		{"file appeared", func(fs *testutil.MockFileSystem, _ *Plan) { fs.AddFile("/base/new.txt", []byte("x")) }, "new.txt exists but did not"},
+		{"step edited", func(_ *testutil.MockFileSystem, plan *Plan) { plan.Steps[3].Target = "other.txt" }, "does not match the deltagram"},
+		{"step dropped", func(_ *testutil.MockFileSystem, plan *Plan) { plan.Steps = plan.Steps[:3] }, "plan lists 3 steps"},
+		{"wrong version", func(_ *testutil.MockFileSystem, plan *Plan) { plan.Version = 99 }, "unsupported plan version"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := testutil.NewMockFileSystem()
+			fs.AddFile("/base/file.txt", []byte("a\nb\nc"))
+
+			plan, err := BuildPlan(fs, "/base", planDeltagram())
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			tt.modify(fs, plan)
+
+			err = CheckPlan(fs, "/base", plan, planDeltagram())
+			if tt.expected == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected error containing %q, got: %v", tt.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package operations
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/diff"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// RebasedHunk reports a content hunk that Rebase moved or whose context it refreshed
+type RebasedHunk struct {
+	Part    int    // 1-based index of the part in the deltagram
+	Path    string // Content-Location of the part
+	Hunk    int    // 1-based index of the hunk in the part
+	OldLine int    // Line the hunk named
+	NewLine int    // Line the hunk applies at in the current file
+	Context int    // Context lines replaced with the current text of the file
+}
+
+// Rebase re-anchors the hunks of every content part against the files under baseDir and
+// returns a copy of the deltagram whose hunks name the current line numbers and context.
+// A hunk is found where it is expected, as apply would find it, or else anywhere in the
+// file where the lines it removes still match and at least half of its context does.
+// Each part is applied to fs after it is rebased so that later parts see the result of
+// earlier ones; pass an overlay to leave the files untouched.
+func Rebase(fs FileSystem, baseDir string, deltagram *parser.Deltagram) (*parser.Deltagram, []RebasedHunk, error) {
+	applier := NewApplierWithOptions(fs, Options{AllowIgnored: true, Output: io.Discard, Warn: func(Warning) {}})
+
+	rebased := *deltagram
+	rebased.Parts = make([]parser.DeltagramPart, len(deltagram.Parts))
+	var moved []RebasedHunk
+	for i, part := range deltagram.Parts {
+		if part.DeltaOperation == "content" && !isMessagePart(part) {
+			oldPath, newPath := parseFileHeaders(strings.Split(part.Content, "\n"))
+			if oldPath != devNull && newPath != devNull {
+				content, hunks, err := rebasePart(fs, baseDir, part)
+				if err != nil {
+					return nil, nil, &parser.PartError{Index: i + 1, Line: part.Line, Err: err}
+				}
+				part.Content = content
+				for _, hunk := range hunks {
+					hunk.Part, hunk.Path = i+1, part.ContentLocation
+					moved = append(moved, hunk)
+				}
+			}
+		}
+		rebased.Parts[i] = part
+
+		if isMessagePart(part) {
+			continue
+		}
+		single := &parser.Deltagram{UUID: deltagram.UUID, Parts: []parser.DeltagramPart{part}}
+		if err := applier.Apply(single, baseDir); err != nil {
+			return nil, nil, &parser.PartError{Index: i + 1, Line: part.Line, Err: err}
+		}
+	}
+	return &rebased, moved, nil
+}
+
+// rebasePart returns the body of a content part rewritten against the current text of
+// its file, along with the hunks that moved or had their context refreshed
+func rebasePart(fs FileSystem, baseDir string, part parser.DeltagramPart) (string, []RebasedHunk, error) {
+	data, err := fs.ReadFile(ResolveFilePath(baseDir, part.ContentLocation))
+	if os.IsNotExist(err) {
+		return "", nil, fmt.Errorf("cannot rebase %w: %s", ErrFileNotFound, part.ContentLocation)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to read %s: %v", part.ContentLocation, err)
+	}
+	original := string(data)
+	bom, text := cutByteOrderMark(original)
+	lines, finalNewline := fileLines(text)
+
+	h := &ContentHandler{}
+	diffLines := strings.Split(part.Content, "\n")
+	hunks, err := h.ParseAllHunks(diffLines)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var placements []hunkPlacement
+	var moved []RebasedHunk
+	for index, hunk := range hunks {
+		start, context, err := anchorHunk(h, lines, hunk)
+		if err != nil {
+			return "", nil, fmt.Errorf("cannot find hunk %d of %s in the current file: %w", index+1, part.ContentLocation, err)
+		}
+		anchored := refreshContext(lines, hunk, start)
+		placements = append(placements, hunkPlacement{start: start, hunk: anchored})
+		if start != hunk.expectedStart() || context > 0 {
+			moved = append(moved, RebasedHunk{Hunk: index + 1, OldLine: hunk.Header.OldStart, NewLine: start + 1, Context: context})
+		}
+	}
+
+	sort.SliceStable(placements, func(i, j int) bool { return placements[i].start < placements[j].start })
+	if !inOrder(placements) {
+		return "", nil, fmt.Errorf("hunks of %s overlap in the current file", part.ContentLocation)
+	}
+	result := bom + buildResult(lines, placements, endsWithNewline(finalNewline, placements))
+
+	unified := diff.Unified("a", "b", original, result, diff.DefaultContext)
+	if unified == "" {
+		return "", nil, fmt.Errorf("%s already contains the changes of this part", part.ContentLocation)
+	}
+	// Keep the part's own file headers, if any, in place of the ones diff.Unified writes
+	var headers []string
+	for _, line := range diffLines {
+		if strings.HasPrefix(line, "@@") {
+			break
+		}
+		headers = append(headers, line)
+	}
+	body := unified[strings.Index(unified, "@@"):]
+	return strings.Join(append(headers, strings.TrimSuffix(body, "\n")), "\n"), moved, nil
+}
+
+// anchorHunk returns the 0-based line where the hunk applies in lines and how many of its
+// context lines differ from the file there
+func anchorHunk(h *ContentHandler, lines []string, hunk *ParsedHunk) (int, int, error) {
+	expected := max(0, min(hunk.expectedStart(), len(lines)))
+	if start, err := h.findBestHunkPosition(lines, hunk, expected); err == nil {
+		return start, 0, nil
+	}
+
+	oldSide := 0
+	for _, op := range hunk.Operations {
+		if op.Type != '+' {
+			oldSide++
+		}
+	}
+	if oldSide == 0 {
+		return expected, 0, nil
+	}
+
+	best, bestMatched := -1, 0
+	for start := 0; start+oldSide <= len(lines); start++ {
+		matched, ok := matchOldSide(lines, hunk, start)
+		if !ok || matched*2 < oldSide {
+			continue
+		}
+		if matched > bestMatched || (matched == bestMatched && abs(start-expected) < abs(best-expected)) {
+			best, bestMatched = start, matched
+		}
+	}
+	if best < 0 {
+		return 0, 0, fmt.Errorf("the lines it removes no longer appear together with enough of its context")
+	}
+	return best, oldSide - bestMatched, nil
+}
+
+// matchOldSide counts the old-side lines of the hunk that equal the file at start. It
+// reports false if any removed line differs, since only context may be refreshed.
+func matchOldSide(lines []string, hunk *ParsedHunk, start int) (int, bool) {
+	matched, pos := 0, start
+	for _, op := range hunk.Operations {
+		if op.Type == '+' {
+			continue
+		}
+		if linesEqual(lines[pos], op.Content) {
+			matched++
+		} else if op.Type == '-' {
+			return 0, false
+		}
+		pos++
+	}
+	return matched, true
+}
+
+// refreshContext returns a copy of the hunk anchored at start, with its context lines
+// taken from the file and its header counts matching its body
+func refreshContext(lines []string, hunk *ParsedHunk, start int) *ParsedHunk {
+	anchored := &ParsedHunk{Header: &HunkHeader{OldStart: start + 1, NewStart: start + 1}}
+	pos := start
+	for _, op := range hunk.Operations {
+		switch op.Type {
+		case ' ':
+			op.Content = lines[pos]
+			fallthrough
+		case '-':
+			anchored.Header.OldCount++
+			pos++
+		}
+		if op.Type != '-' {
+			anchored.Header.NewCount++
+		}
+		anchored.Operations = append(anchored.Operations, op)
+	}
+	return anchored
+}
+
+// abs returns the absolute value of n
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,79 @@
+package operations
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/developingjames/deltagrams/internal/testutil"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func TestRebase(t *testing.T) {
+	fs := testutil.NewMockFileSystem()
+	// Three lines were added at the top and the context line "two" was reworded since
+	// the deltagram was written
+	fs.AddFile("/base/main.txt", []byte("new1\nnew2\nnew3\none\nTWO\nthree\nfour\nfive\n"))
+
+	deltagram := &parser.Deltagram{UUID: "0123456789abcdef", Parts: []parser.DeltagramPart{
+		{ContentLocation: "main.txt", ContentType: "text/plain", DeltaOperation: "content",
+			Content: "@@ -1,4 +1,4 @@\n one\n two\n-three\n+THREE\n four"},
+	}}
+
+	rebased, moved, err := Rebase(fs, "/base", deltagram)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	want := "@@ -3,6 +3,6 @@\n new3\n one\n TWO\n-three\n+THREE\n four\n five"
+	if rebased.Parts[0].Content != want {
+		t.Errorf("Expected rebased hunk:\n%s\ngot:\n%s", want, rebased.Parts[0].Content)
+	}
+	if len(moved) != 1 || moved[0].OldLine != 1 || moved[0].NewLine != 4 || moved[0].Context != 1 {
+		t.Errorf("Expected hunk 1 moved from line 1 to 4 with 1 context line refreshed, got %+v", moved)
+	}
+	if deltagram.Parts[0].Content == rebased.Parts[0].Content {
+		t.Error("Expected the original deltagram to be left unchanged")
+	}
+
+	// The rebased deltagram applies where the original did not
+	content, _ := fs.ReadFile("/base/main.txt")
+	if !strings.Contains(string(content), "THREE") {
+		t.Errorf("Expected the rebased part to be applied to the file system, got %q", content)
+	}
+}
+
+func TestRebase_RemovedLinesChanged(t *testing.T) {
+	fs := testutil.NewMockFileSystem()
+	fs.AddFile("/base/main.txt", []byte("one\ntwo\n3\nfour"))
+
+	deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{
+		{ContentLocation: "main.txt", ContentType: "text/plain", DeltaOperation: "content",
+			Content: "@@ -1,3 +1,3 @@\n one\n two\n-three\n+THREE"},
+	}}
+
+	if _, _, err := Rebase(fs, "/base", deltagram); err == nil || !strings.Contains(err.Error(), "cannot find hunk 1") {
+		t.Errorf("Expected a hunk whose removed line changed to fail, got %v", err)
+	}
+}
+
+func TestRebase_LaterPartsSeeEarlierOnes(t *testing.T) {
+	fs := testutil.NewMockFileSystem()
+	fs.AddFile("/base/main.txt", []byte("a\nb\nc"))
+
+	deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{
+		{ContentLocation: "main.txt", ContentType: "text/plain", DeltaOperation: "content",
+			Content: "@@ -1,1 +1,2 @@\n a\n+inserted"},
+		{ContentLocation: "main.txt", ContentType: "text/plain", DeltaOperation: "content",
+			Content: "@@ -2,2 +2,2 @@\n b\n-c\n+C"},
+	}}
+
+	rebased, moved, err := Rebase(fs, "/base", deltagram)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(moved) != 1 || moved[0].Part != 2 || moved[0].NewLine != 3 {
+		t.Errorf("Expected part 2 to move to line 3, got %+v", moved)
+	}
+	if !strings.HasPrefix(rebased.Parts[1].Content, "@@ -1,4 +1,4 @@") {
+		t.Errorf("Expected part 2 rebased onto the result of part 1, got:\n%s", rebased.Parts[1].Content)
+	}
+}
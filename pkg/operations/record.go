@@ -0,0 +1,159 @@
+package operations
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/diff"
+)
+
+// FileChange is the state of a file before and after an apply
+type FileChange struct {
+	Path    string // Path as passed to the file system
+	Before  []byte
+	After   []byte
+	Existed bool // Whether the file existed before the apply
+	Exists  bool // Whether the file exists after the apply
+}
+
+// RecordingFileSystem wraps a FileSystem and captures the pre-image of every file the
+// first time it is modified, so the changes made by an apply can be reported afterwards
+type RecordingFileSystem struct {
+	FileSystem
+	order   []string
+	before  map[string][]byte
+	existed map[string]bool
+}
+
+// NewRecordingFileSystem creates a recording wrapper around fs
+func NewRecordingFileSystem(fs FileSystem) *RecordingFileSystem {
+	return &RecordingFileSystem{
+		FileSystem: fs,
+		before:     make(map[string][]byte),
+		existed:    make(map[string]bool),
+	}
+}
+
+func (r *RecordingFileSystem) WriteFile(filename string, data []byte, perm os.FileMode) error {
+	r.capture(filename)
+	return r.FileSystem.WriteFile(filename, data, perm)
+}
+
+func (r *RecordingFileSystem) Remove(name string) error {
+	r.capture(name)
+	return r.FileSystem.Remove(name)
+}
+
+func (r *RecordingFileSystem) Rename(oldpath, newpath string) error {
+	r.capture(oldpath)
+	r.capture(newpath)
+	return r.FileSystem.Rename(oldpath, newpath)
+}
+
+func (r *RecordingFileSystem) Create(name string) (io.WriteCloser, error) {
+	r.capture(name)
+	return r.FileSystem.Create(name)
+}
+
+func (r *RecordingFileSystem) Truncate(name string, size int64) error {
+	r.capture(name)
+	return r.FileSystem.Truncate(name, size)
+}
+
+func (r *RecordingFileSystem) Chmod(name string, mode os.FileMode) error {
+	r.capture(name)
+	return r.FileSystem.Chmod(name, mode)
+}
+
+func (r *RecordingFileSystem) Symlink(oldname, newname string) error {
+	r.capture(newname)
+	return r.FileSystem.Symlink(oldname, newname)
+}
+
+// Changes returns every recorded file whose content or existence changed, in the order
+// the files were first modified
+func (r *RecordingFileSystem) Changes() []FileChange {
+	var changes []FileChange
+	for _, path := range r.order {
+		after, err := r.FileSystem.ReadFile(path)
+		exists := err == nil
+
+		change := FileChange{
+			Path:    path,
+			Before:  r.before[path],
+			After:   after,
+			Existed: r.existed[path],
+			Exists:  exists,
+		}
+		if change.Existed == change.Exists && bytes.Equal(change.Before, change.After) {
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// capture stores the current content of path unless it was captured before
+func (r *RecordingFileSystem) capture(path string) {
+	path = filepath.Clean(path)
+	if _, seen := r.existed[path]; seen {
+		return
+	}
+
+	r.order = append(r.order, path)
+	content, err := r.FileSystem.ReadFile(path)
+	r.existed[path] = err == nil
+	r.before[path] = content
+}
+
+// FormatChanges renders changes as a unified diff with a/ and b/ prefixed paths relative
+// to baseDir
+func FormatChanges(changes []FileChange, baseDir string) string {
+	var b strings.Builder
+	for _, change := range changes {
+		name := change.Path
+		if rel, err := filepath.Rel(baseDir, change.Path); err == nil {
+			name = filepath.ToSlash(rel)
+		}
+
+		oldName, newName := "a/"+name, "b/"+name
+		if !change.Existed {
+			oldName = devNull
+		}
+		if !change.Exists {
+			newName = devNull
+		}
+
+		b.WriteString(diff.Unified(oldName, newName, string(change.Before), string(change.After), diff.DefaultContext))
+	}
+	return b.String()
+}
+
+// RestoreChanges puts every file in changes back as it was before the apply, in reverse
+// order, removing the files the apply created
+func RestoreChanges(fs FileSystem, changes []FileChange) error {
+	for i := len(changes) - 1; i >= 0; i-- {
+		change := changes[i]
+		if !change.Existed {
+			if err := fs.Remove(change.Path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+
+		if err := fs.MkdirAll(filepath.Dir(change.Path), 0755); err != nil {
+			return err
+		}
+		perm := os.FileMode(0644)
+		if info, err := fs.Stat(change.Path); err == nil {
+			perm = info.Mode().Perm()
+		}
+		if err := fs.WriteFile(change.Path, change.Before, perm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package operations
+
+import (
+	"testing"
+
+	"github.com/developingjames/deltagrams/internal/testutil"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func TestRecordingFileSystem_FormatChanges(t *testing.T) {
+	mock := testutil.NewMockFileSystem()
+	mock.AddFile("/base/main.txt", []byte("one\ntwo\n"))
+	mock.AddFile("/base/old.txt", []byte("bye\n"))
+
+	fs := NewRecordingFileSystem(mock)
+	deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{
+		{ContentLocation: "main.txt", DeltaOperation: "content", Content: "@@ -1,2 +1,2 @@\n one\n-two\n+three"},
+		{ContentLocation: "new.txt", DeltaOperation: "create", Content: "+++ new.txt\nhello"},
+		{ContentLocation: "old.txt", DeltaOperation: "delete"},
+	}}
+
+	if err := NewApplier(fs).Apply(deltagram, "/base"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	changes := fs.Changes()
+	if len(changes) != 3 {
+		t.Fatalf("Expected 3 changes, got %d", len(changes))
+	}
+
+	expected := `--- a/main.txt
++++ b/main.txt
+@@ -1,2 +1,2 @@
+ one
+-two
++three
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1 @@
++hello
+\ No newline at end of file
+--- a/old.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+`
+	if got := FormatChanges(changes, "/base"); got != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, got)
+	}
+}
+
+func TestRecordingFileSystem_Truncate(t *testing.T) {
+	mock := testutil.NewMockFileSystem()
+	mock.AddFile("/base/app.log", []byte("one\ntwo\nthree\n"))
+
+	fs := NewRecordingFileSystem(mock)
+	deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{
+		{ContentLocation: "app.log", DeltaOperation: "truncate", Content: "--- app.log",
+			Headers: map[string]string{TruncateLinesHeader: "1"}},
+	}}
+	if err := NewApplier(fs).Apply(deltagram, "/base"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	changes := fs.Changes()
+	if len(changes) != 1 || string(changes[0].Before) != "one\ntwo\nthree\n" || string(changes[0].After) != "one\n" {
+		t.Fatalf("Expected the truncate to be recorded, got %+v", changes)
+	}
+
+	if err := RestoreChanges(mock, changes); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if data, _ := mock.ReadFile("/base/app.log"); string(data) != "one\ntwo\nthree\n" {
+		t.Errorf("Expected rollback to restore app.log, got %q", data)
+	}
+}
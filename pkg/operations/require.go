@@ -0,0 +1,169 @@
+package operations
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// ErrRequirementNotMet is returned, wrapped, when the target directory does not meet a
+// precondition listed in a deltagram://require part
+var ErrRequirementNotMet = errors.New("requirement not met")
+
+// Requirement is one precondition from a deltagram://require part, such as "exists go.mod"
+type Requirement struct {
+	Kind string   // exists, missing, contains, git, or branch
+	Args []string // Path for exists, missing, and contains; text for contains; name for branch
+	Text string   // The line as written
+}
+
+// ParseRequirements reads the requirements of a deltagram://require part, one per line.
+// Blank lines and lines starting with # are ignored.
+func ParseRequirements(content string) ([]Requirement, error) {
+	var requirements []Requirement
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		kind, rest, _ := strings.Cut(line, " ")
+		rest = strings.TrimSpace(rest)
+		requirement := Requirement{Kind: kind, Text: line}
+		switch kind {
+		case "exists", "missing":
+			if rest == "" {
+				return nil, fmt.Errorf("requirement %q needs a path", line)
+			}
+			requirement.Args = []string{rest}
+		case "contains":
+			path, text, _ := strings.Cut(rest, " ")
+			if path == "" || strings.TrimSpace(text) == "" {
+				return nil, fmt.Errorf("requirement %q needs a path and the text to find", line)
+			}
+			requirement.Args = []string{path, strings.TrimSpace(text)}
+		case "git":
+			if rest != "" {
+				return nil, fmt.Errorf("requirement %q takes no arguments", line)
+			}
+		case "branch":
+			name := strings.TrimSpace(strings.TrimPrefix(rest, "=="))
+			if name == "" || strings.ContainsAny(name, " \t") {
+				return nil, fmt.Errorf("requirement %q needs a single branch name", line)
+			}
+			requirement.Args = []string{name}
+		default:
+			return nil, fmt.Errorf("unknown requirement %q (expected exists, missing, contains, git, or branch)", line)
+		}
+		requirements = append(requirements, requirement)
+	}
+	return requirements, nil
+}
+
+// CheckRequirements evaluates the requirements of every deltagram://require part against
+// baseDir, returning an error for the first one that is not met
+func CheckRequirements(fs FileSystem, baseDir string, deltagram *parser.Deltagram) error {
+	for i, part := range deltagram.Parts {
+		if !part.IsRequirement() {
+			continue
+		}
+		requirements, err := ParseRequirements(part.Content)
+		if err != nil {
+			return &parser.PartError{Index: i + 1, Line: part.Line, Err: err}
+		}
+		for _, requirement := range requirements {
+			if err := checkRequirement(fs, baseDir, requirement); err != nil {
+				return &parser.PartError{Index: i + 1, Line: part.Line, Err: err}
+			}
+		}
+	}
+	return nil
+}
+
+// checkRequirement evaluates a single requirement
+func checkRequirement(fs FileSystem, baseDir string, r Requirement) error {
+	notMet := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%w: %q: %s", ErrRequirementNotMet, r.Text, fmt.Sprintf(format, args...))
+	}
+
+	switch r.Kind {
+	case "exists":
+		if _, err := fs.Stat(ResolveFilePath(baseDir, r.Args[0])); err != nil {
+			return notMet("%s does not exist", r.Args[0])
+		}
+	case "missing":
+		if _, err := fs.Stat(ResolveFilePath(baseDir, r.Args[0])); err == nil {
+			return notMet("%s exists", r.Args[0])
+		}
+	case "contains":
+		content, err := fs.ReadFile(ResolveFilePath(baseDir, r.Args[0]))
+		if err != nil {
+			return notMet("cannot read %s", r.Args[0])
+		}
+		if !strings.Contains(string(content), r.Args[1]) {
+			return notMet("%s does not contain %q", r.Args[0], r.Args[1])
+		}
+	case "git":
+		if _, err := findGitDir(fs, baseDir); err != nil {
+			return notMet("%v", err)
+		}
+	case "branch":
+		branch, err := currentBranch(fs, baseDir)
+		if err != nil {
+			return notMet("%v", err)
+		}
+		if branch != r.Args[0] {
+			return notMet("current branch is %s", branch)
+		}
+	}
+	return nil
+}
+
+// findGitDir returns the git directory of the repository containing dir, following the
+// "gitdir:" file that worktrees and submodules use in place of a .git directory
+func findGitDir(fs FileSystem, dir string) (string, error) {
+	for current := dir; ; current = filepath.Dir(current) {
+		candidate := filepath.Join(current, ".git")
+		if info, err := fs.Stat(candidate); err == nil {
+			if info.IsDir() {
+				return candidate, nil
+			}
+			data, err := fs.ReadFile(candidate)
+			if err != nil {
+				return "", fmt.Errorf("failed to read %s: %v", candidate, err)
+			}
+			gitDir, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+			if !ok {
+				return "", fmt.Errorf("%s is not a git directory or gitdir file", candidate)
+			}
+			gitDir = strings.TrimSpace(gitDir)
+			if !filepath.IsAbs(gitDir) {
+				gitDir = filepath.Join(current, gitDir)
+			}
+			return gitDir, nil
+		}
+		if filepath.Dir(current) == current {
+			return "", fmt.Errorf("not a git repository")
+		}
+	}
+}
+
+// currentBranch returns the branch checked out in the repository containing dir
+func currentBranch(fs FileSystem, dir string) (string, error) {
+	gitDir, err := findGitDir(fs, dir)
+	if err != nil {
+		return "", err
+	}
+	head, err := fs.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read HEAD: %v", err)
+	}
+	ref, ok := strings.CutPrefix(strings.TrimSpace(string(head)), "ref: refs/heads/")
+	if !ok {
+		return "", fmt.Errorf("HEAD is detached")
+	}
+	return ref, nil
+}
@@ -0,0 +1,105 @@
+package operations
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func TestParseRequirements(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		kinds   []string
+		errMsg  string
+	}{
+		{"all kinds", "# workspace\nexists go.mod\n\nmissing old.go\ncontains go.mod module example.com\ngit\nbranch == main", []string{"exists", "missing", "contains", "git", "branch"}, ""},
+		{"unknown", "exist go.mod", nil, "unknown requirement"},
+		{"missing path", "exists", nil, "needs a path"},
+		{"contains without text", "contains go.mod", nil, "needs a path and the text"},
+		{"git with arguments", "git repo", nil, "takes no arguments"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requirements, err := ParseRequirements(tt.content)
+			if tt.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("Expected error containing %q, got: %v", tt.errMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			var kinds []string
+			for _, r := range requirements {
+				kinds = append(kinds, r.Kind)
+			}
+			if strings.Join(kinds, ",") != strings.Join(tt.kinds, ",") {
+				t.Errorf("Expected kinds %v, got %v", tt.kinds, kinds)
+			}
+		})
+	}
+}
+
+func TestCheckRequirements(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		errMsg  string
+	}{
+		{"met", "exists go.mod\nmissing old.go\ncontains go.mod module example.com\ngit\nbranch main", ""},
+		{"file missing", "exists Cargo.toml", "Cargo.toml does not exist"},
+		{"file present", "missing go.mod", "go.mod exists"},
+		{"text absent", "contains go.mod module other.com", "does not contain"},
+		{"wrong branch", "branch release", "current branch is main"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := NewMemoryFileSystem()
+			fs.MkdirAll("/repo/.git", 0755)
+			fs.WriteFile("/repo/.git/HEAD", []byte("ref: refs/heads/main\n"), 0644)
+			fs.MkdirAll("/repo/app", 0755)
+			fs.WriteFile("/repo/app/go.mod", []byte("module example.com/app\n"), 0644)
+
+			deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{
+				{ContentLocation: parser.RequireLocation, ContentType: "text/plain", Content: tt.content},
+			}}
+			err := CheckRequirements(fs, "/repo/app", deltagram)
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Fatalf("Expected error containing %q, got: %v", tt.errMsg, err)
+			}
+			if !errors.Is(err, ErrRequirementNotMet) {
+				t.Errorf("Expected ErrRequirementNotMet, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestApplier_Apply_RequirementNotMet(t *testing.T) {
+	fs := NewMemoryFileSystem()
+	fs.MkdirAll("/base", 0755)
+
+	deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{
+		{ContentLocation: "new.txt", ContentType: "text/plain", DeltaOperation: "create", Content: "hello"},
+		{ContentLocation: parser.RequireLocation, ContentType: "text/plain", Content: "exists go.mod"},
+	}}
+
+	err := NewApplier(fs).Apply(deltagram, "/base")
+	if !errors.Is(err, ErrRequirementNotMet) {
+		t.Fatalf("Expected ErrRequirementNotMet, got: %v", err)
+	}
+	if _, err := fs.Stat("/base/new.txt"); err == nil {
+		t.Errorf("Expected nothing to be applied when a requirement is not met")
+	}
+}
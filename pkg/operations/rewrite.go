@@ -0,0 +1,201 @@
+package operations
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// PathRewrite replaces a leading directory prefix of the paths in a deltagram, so that a
+// deltagram written against a slightly different layout can be applied unchanged
+type PathRewrite struct {
+	Old string // Prefix to replace; empty matches every path
+	New string // Replacement; empty removes the prefix
+}
+
+// ParsePathRewrite parses a rule written as old=>new, such as "=>src/" to put every path
+// under src/ or "lib/=>pkg/" to move paths from lib/ to pkg/
+func ParsePathRewrite(rule string) (PathRewrite, error) {
+	oldPrefix, newPrefix, ok := strings.Cut(rule, "=>")
+	if !ok {
+		return PathRewrite{}, fmt.Errorf("invalid path rewrite %q: use old-prefix=>new-prefix", rule)
+	}
+	r := PathRewrite{Old: cleanPrefix(oldPrefix), New: cleanPrefix(newPrefix)}
+	if r.Old == r.New {
+		return PathRewrite{}, fmt.Errorf("invalid path rewrite %q: the prefixes are the same", rule)
+	}
+	return r, nil
+}
+
+// cleanPrefix returns a prefix without leading "./" or "/" and trailing slashes
+func cleanPrefix(prefix string) string {
+	prefix = strings.TrimSpace(prefix)
+	if prefix == "" {
+		return ""
+	}
+	prefix = path.Clean(strings.ReplaceAll(prefix, "\\", "/"))
+	prefix = strings.TrimLeft(prefix, "/")
+	if prefix == "." {
+		return ""
+	}
+	return prefix
+}
+
+// apply rewrites p if the rule matches it, matching whole path components
+func (r PathRewrite) apply(p string) (string, bool) {
+	rel := strings.TrimLeft(strings.TrimPrefix(p, "./"), "/")
+	var rest string
+	switch {
+	case r.Old == "":
+		rest = rel
+	case rel == r.Old:
+		rest = ""
+	case strings.HasPrefix(rel, r.Old+"/"):
+		rest = strings.TrimPrefix(rel, r.Old+"/")
+	default:
+		return p, false
+	}
+	switch {
+	case r.New == "":
+		return rest, true
+	case rest == "":
+		return r.New, true
+	}
+	return r.New + "/" + rest, true
+}
+
+// rewritePath applies the first matching rule to p
+func rewritePath(p string, rules []PathRewrite) string {
+	for _, r := range rules {
+		if rewritten, ok := r.apply(p); ok {
+			return rewritten
+		}
+	}
+	return p
+}
+
+// RewritePaths returns a copy of the deltagram with the first matching rule applied to
+// each Content-Location, to the --- and +++ paths of copy and move parts, to the files
+// notes are about, and to the paths in requirements
+func RewritePaths(deltagram *parser.Deltagram, rules []PathRewrite) *parser.Deltagram {
+	if len(rules) == 0 {
+		return deltagram
+	}
+	location := func(p string) (string, error) { return rewritePath(p, rules), nil }
+	marker := func(p string) (string, error) {
+		// The a/ and b/ prefixes of git-style diffs are kept in front of the rewritten path
+		if !matchesPrefix(p, rules) && (strings.HasPrefix(p, "a/") || strings.HasPrefix(p, "b/")) {
+			return p[:2] + rewritePath(p[2:], rules), nil
+		}
+		return rewritePath(p, rules), nil
+	}
+	rewritten, _ := mapPaths(deltagram, location, marker)
+	return rewritten
+}
+
+// StripPaths returns a copy of the deltagram with n leading components removed from
+// every path RewritePaths rewrites, like patch -p. The a/ and b/ prefixes of git-style
+// diffs are components too, so a deltagram made from git diff output needs n of 1. A path
+// with no more than n components is an error.
+func StripPaths(deltagram *parser.Deltagram, n int) (*parser.Deltagram, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("invalid strip count %d", n)
+	}
+	if n == 0 {
+		return deltagram, nil
+	}
+	strip := func(p string) (string, error) {
+		components := strings.Split(strings.TrimLeft(strings.ReplaceAll(p, "\\", "/"), "/"), "/")
+		if len(components) <= n {
+			return "", fmt.Errorf("cannot strip %d leading component(s) from %s", n, p)
+		}
+		return strings.Join(components[n:], "/"), nil
+	}
+	return mapPaths(deltagram, strip, strip)
+}
+
+// mapPaths returns a copy of the deltagram with location applied to each Content-Location
+// and to the paths of notes and requirements, and marker applied to the --- and +++ paths
+// of copy and move parts
+func mapPaths(deltagram *parser.Deltagram, location, marker func(string) (string, error)) (*parser.Deltagram, error) {
+	mapped := *deltagram
+	mapped.Parts = make([]parser.DeltagramPart, len(deltagram.Parts))
+	for i, part := range deltagram.Parts {
+		var err error
+		switch {
+		case part.IsNote():
+			var target string
+			target, err = location(part.NoteTarget())
+			part.ContentLocation = parser.NotePrefix + target
+		case part.IsRequirement():
+			part.Content, err = mapRequirements(part.Content, location)
+		case part.IsMessage():
+		default:
+			part.ContentLocation, err = location(part.ContentLocation)
+			if err == nil && (part.DeltaOperation == "copy" || part.DeltaOperation == "move") {
+				part.Content, err = mapMarkers(part.Content, marker)
+			}
+		}
+		if err != nil {
+			return nil, &parser.PartError{Index: i + 1, Line: part.Line, Err: err}
+		}
+		mapped.Parts[i] = part
+	}
+	return &mapped, nil
+}
+
+// mapMarkers maps the --- and +++ paths that precede the hunks of a copy or move body
+func mapMarkers(content string, fn func(string) (string, error)) (string, error) {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "@@") {
+			break
+		}
+		if !strings.HasPrefix(trimmed, "---") && !strings.HasPrefix(trimmed, "+++") {
+			continue
+		}
+		p, err := fn(strings.TrimSpace(trimmed[3:]))
+		if err != nil {
+			return "", err
+		}
+		lines[i] = trimmed[:3] + " " + p
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// matchesPrefix reports whether a rule other than a catch-all matches p
+func matchesPrefix(p string, rules []PathRewrite) bool {
+	for _, r := range rules {
+		if _, ok := r.apply(p); ok && r.Old != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// mapRequirements maps the path of exists, missing, and contains requirements
+func mapRequirements(content string, fn func(string) (string, error)) (string, error) {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		kind, rest, _ := strings.Cut(strings.TrimSpace(line), " ")
+		rest = strings.TrimSpace(rest)
+		text := ""
+		switch kind {
+		case "exists", "missing":
+		case "contains":
+			rest, text, _ = strings.Cut(rest, " ")
+			text = " " + text
+		default:
+			continue
+		}
+		p, err := fn(rest)
+		if err != nil {
+			return "", err
+		}
+		lines[i] = kind + " " + p + text
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,121 @@
+package operations
+
+import (
+	"testing"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func TestParsePathRewrite(t *testing.T) {
+	tests := []struct {
+		rule    string
+		want    PathRewrite
+		wantErr bool
+	}{
+		{rule: "=>src/", want: PathRewrite{New: "src"}},
+		{rule: "lib/=>pkg/", want: PathRewrite{Old: "lib", New: "pkg"}},
+		{rule: "./app=>", want: PathRewrite{Old: "app"}},
+		{rule: "/a/b/=>c", want: PathRewrite{Old: "a/b", New: "c"}},
+		{rule: "lib", wantErr: true},
+		{rule: "src/=>src", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			got, err := ParsePathRewrite(tt.rule)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPathRewrite_Apply(t *testing.T) {
+	rules := []PathRewrite{{Old: "lib", New: "pkg"}, {Old: "app"}, {New: "src"}}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "lib/util.go", want: "pkg/util.go"},
+		{path: "./lib/util.go", want: "pkg/util.go"},
+		{path: "lib", want: "pkg"},
+		{path: "library/x.go", want: "src/library/x.go"},
+		{path: "app/main.go", want: "main.go"},
+		{path: "/README.md", want: "src/README.md"},
+	}
+
+	for _, tt := range tests {
+		if got := rewritePath(tt.path, rules); got != tt.want {
+			t.Errorf("rewritePath(%q) = %q, expected %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRewritePaths(t *testing.T) {
+	deltagram := &parser.Deltagram{UUID: "0123456789abcdef", Parts: []parser.DeltagramPart{
+		{ContentLocation: "deltagram://message", ContentType: "text/plain", Content: "lib/a.go is moved"},
+		{ContentLocation: parser.RequireLocation, ContentType: "text/plain", Content: "exists lib/a.go\ncontains go.mod module example\ngit"},
+		{ContentLocation: "new/a.go", ContentType: "text/plain", DeltaOperation: "move", Content: "--- a/lib/a.go\n+++ b/new/a.go\n@@ -1 +1 @@\n--- old\n+++ new"},
+		{ContentLocation: parser.NotePrefix + "lib/a.go", ContentType: "text/plain", Content: "note"},
+	}}
+	rules := []PathRewrite{{Old: "lib", New: "internal/lib"}, {New: "src"}}
+
+	got := RewritePaths(deltagram, rules)
+	want := []parser.DeltagramPart{
+		{ContentLocation: "deltagram://message", Content: "lib/a.go is moved"},
+		{ContentLocation: parser.RequireLocation, Content: "exists internal/lib/a.go\ncontains src/go.mod module example\ngit"},
+		{ContentLocation: "src/new/a.go", Content: "--- a/internal/lib/a.go\n+++ b/src/new/a.go\n@@ -1 +1 @@\n--- old\n+++ new"},
+		{ContentLocation: parser.NotePrefix + "internal/lib/a.go", Content: "note"},
+	}
+	for i, part := range got.Parts {
+		if part.ContentLocation != want[i].ContentLocation || part.Content != want[i].Content {
+			t.Errorf("Part %d: expected %q %q, got %q %q", i+1, want[i].ContentLocation, want[i].Content, part.ContentLocation, part.Content)
+		}
+	}
+	if deltagram.Parts[2].ContentLocation != "new/a.go" {
+		t.Error("Expected the original deltagram to be left unchanged")
+	}
+}
+
+func TestStripPaths(t *testing.T) {
+	deltagram := &parser.Deltagram{UUID: "0123456789abcdef", Parts: []parser.DeltagramPart{
+		{ContentLocation: "b/src/new.go", ContentType: "text/plain", DeltaOperation: "move", Content: "--- a/src/old.go\n+++ b/src/new.go"},
+		{ContentLocation: parser.RequireLocation, ContentType: "text/plain", Content: "contains a/go.mod module example"},
+		{ContentLocation: parser.NotePrefix + "b/src/new.go", ContentType: "text/plain", Content: "note"},
+	}}
+
+	got, err := StripPaths(deltagram, 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	want := []parser.DeltagramPart{
+		{ContentLocation: "src/new.go", Content: "--- src/old.go\n+++ src/new.go"},
+		{ContentLocation: parser.RequireLocation, Content: "contains go.mod module example"},
+		{ContentLocation: parser.NotePrefix + "src/new.go", Content: "note"},
+	}
+	for i, part := range got.Parts {
+		if part.ContentLocation != want[i].ContentLocation || part.Content != want[i].Content {
+			t.Errorf("Part %d: expected %q %q, got %q %q", i+1, want[i].ContentLocation, want[i].Content, part.ContentLocation, part.Content)
+		}
+	}
+
+	if _, err := StripPaths(deltagram, 2); err == nil {
+		t.Error("Expected an error stripping every component of go.mod")
+	}
+	if _, err := StripPaths(deltagram, -1); err == nil {
+		t.Error("Expected an error for a negative strip count")
+	}
+	if same, err := StripPaths(deltagram, 0); err != nil || same != deltagram {
+		t.Errorf("Expected the deltagram unchanged for 0, got %v", err)
+	}
+}
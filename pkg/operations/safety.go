@@ -0,0 +1,191 @@
+package operations
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/gitignore"
+	"github.com/developingjames/deltagrams/pkg/parser"
+	"github.com/developingjames/deltagrams/pkg/pathglob"
+)
+
+// ProtectedDirs lists directory names that deltagrams may not write into unless
+// ignored paths are explicitly allowed
+var ProtectedDirs = []string{".git", ".hg", ".svn", ".deltagram", "node_modules", ".venv", "__pycache__"}
+
+// WrittenPaths returns the paths a part creates, modifies, or removes, as written in the
+// deltagram. Sources that are only read (such as the source of a copy) are not included.
+func WrittenPaths(part parser.DeltagramPart) []string {
+	switch part.DeltaOperation {
+	case "copy":
+		if _, destPath, _, err := transferPaths(part); err == nil {
+			return []string{destPath}
+		}
+	case "move":
+		if sourcePath, destPath, _, err := transferPaths(part); err == nil {
+			return []string{sourcePath, destPath}
+		}
+	}
+	return []string{part.ContentLocation}
+}
+
+// ignoreChecker refuses paths inside protected directories or matched by .gitignore files,
+// loading .gitignore files lazily from the directories along each checked path
+type ignoreChecker struct {
+	fs      FileSystem
+	baseDir string
+	matcher *gitignore.Matcher
+	loaded  map[string]bool
+}
+
+func newIgnoreChecker(fs FileSystem, baseDir string) *ignoreChecker {
+	return &ignoreChecker{
+		fs:      fs,
+		baseDir: baseDir,
+		matcher: gitignore.NewMatcher(),
+		loaded:  make(map[string]bool),
+	}
+}
+
+// Check returns an error if the deltagram path must not be modified
+func (c *ignoreChecker) Check(location string) error {
+	relPath, err := relativePath(c.baseDir, location)
+	if err != nil {
+		return err
+	}
+
+	segments := strings.Split(relPath, "/")
+	for _, segment := range segments[:len(segments)-1] {
+		for _, protected := range ProtectedDirs {
+			if segment == protected {
+				return fmt.Errorf("refusing to modify %s: path is inside protected directory %s (use --allow-ignored to override)", location, protected)
+			}
+		}
+	}
+
+	// Load .gitignore files from the base directory down to the file's parent
+	dir := ""
+	for i := 0; i < len(segments); i++ {
+		if !c.loaded[dir] {
+			c.loaded[dir] = true
+			if content, err := c.fs.ReadFile(filepath.Join(c.baseDir, filepath.FromSlash(dir), ".gitignore")); err == nil {
+				c.matcher.AddPatterns(dir, string(content))
+			}
+		}
+		if i < len(segments)-1 {
+			dir = strings.TrimPrefix(dir+"/"+segments[i], "/")
+		}
+	}
+
+	if c.matcher.Match(relPath, false) {
+		return fmt.Errorf("refusing to modify %s: path is ignored by .gitignore (use --allow-ignored to override)", location)
+	}
+
+	return nil
+}
+
+// checkPathPolicy enforces allowlist and denylist glob patterns for a deltagram path.
+// Deny patterns take precedence; a non-empty allowlist must match the path or a parent.
+func checkPathPolicy(baseDir, location string, allow, deny []string) error {
+	if len(allow) == 0 && len(deny) == 0 {
+		return nil
+	}
+
+	// A malformed pattern matches nothing, so a mistyped deny entry would protect nothing
+	for _, pattern := range append(append([]string(nil), allow...), deny...) {
+		if err := pathglob.Validate(pattern); err != nil {
+			return fmt.Errorf("invalid path pattern %q: %v", pattern, err)
+		}
+	}
+
+	relPath, err := relativePath(baseDir, location)
+	if err != nil {
+		return err
+	}
+
+	for _, pattern := range deny {
+		if pathglob.MatchPrefix(pattern, relPath) {
+			return fmt.Errorf("refusing to modify %s: path matches denied pattern %q", location, pattern)
+		}
+	}
+
+	if len(allow) == 0 {
+		return nil
+	}
+	for _, pattern := range allow {
+		if pathglob.MatchPrefix(pattern, relPath) {
+			return nil
+		}
+	}
+	return fmt.Errorf("refusing to modify %s: path is not covered by the allowed paths %v", location, allow)
+}
+
+// relativePath resolves a deltagram path to a slash-separated path relative to baseDir
+func relativePath(baseDir, location string) (string, error) {
+	relPath, err := filepath.Rel(baseDir, ResolveFilePath(baseDir, location))
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve %s relative to base directory: %v", location, err)
+	}
+	return filepath.ToSlash(relPath), nil
+}
+
+// checkWithinBase refuses paths whose real location, after resolving `..` segments and
+// symlinks in the existing part of the path, lies outside the base directory
+func checkWithinBase(fs FileSystem, baseDir, location string, allowSymlinkEscape bool, warn warnFunc) error {
+	fullPath := ResolveFilePath(baseDir, location)
+	if rel, err := filepath.Rel(baseDir, fullPath); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("refusing to modify %s: path escapes the base directory", location)
+	}
+
+	realBase, err := resolveExisting(fs, baseDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve base directory: %v", err)
+	}
+	realPath, err := resolveExisting(fs, fullPath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %v", location, err)
+	}
+
+	rel, err := filepath.Rel(realBase, realPath)
+	if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil
+	}
+
+	if allowSymlinkEscape {
+		warn.warn(WarnSymlinkEscape, "%s resolves outside the base directory to %s", location, realPath)
+		return nil
+	}
+	return fmt.Errorf("refusing to modify %s: path resolves through a symlink to %s outside the base directory", location, realPath)
+}
+
+// resolveExisting resolves symlinks in the longest existing prefix of path and appends
+// the remaining, not yet created, components unchanged
+func resolveExisting(fs FileSystem, path string) (string, error) {
+	path = filepath.Clean(path)
+	var missing []string
+
+	for {
+		resolved, err := fs.EvalSymlinks(path)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, reverse(missing)...)...), nil
+		}
+
+		parent := filepath.Dir(path)
+		if parent == path {
+			// Nothing along the path exists; there are no symlinks to resolve
+			return filepath.Join(append([]string{path}, reverse(missing)...)...), nil
+		}
+		missing = append(missing, filepath.Base(path))
+		path = parent
+	}
+}
+
+// reverse returns the elements of s in reverse order
+func reverse(s []string) []string {
+	out := make([]string, len(s))
+	for i, v := range s {
+		out[len(s)-1-i] = v
+	}
+	return out
+}
@@ -0,0 +1,114 @@
+package operations
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// SanitizePolicy controls how the paths in a deltagram are cleaned before they are
+// applied. Control characters and invisible formatting characters, such as zero-width
+// spaces and bidirectional overrides, are always refused.
+type SanitizePolicy struct {
+	// Normalize is "nfc" to compose a letter followed by a combining accent into the
+	// single accented letter, so that é typed either way names the same file; empty
+	// leaves paths as written
+	Normalize string
+	// ASCII refuses paths with characters outside ASCII, such as letters from other
+	// scripts that look like Latin ones
+	ASCII bool
+	// Lowercase changes every path to lowercase
+	Lowercase bool
+}
+
+// SanitizePaths returns a copy of the deltagram with the policy applied to every path
+// RewritePaths rewrites. A path the policy refuses is an error.
+func SanitizePaths(deltagram *parser.Deltagram, policy SanitizePolicy) (*parser.Deltagram, error) {
+	switch policy.Normalize {
+	case "", "nfc":
+	default:
+		return nil, fmt.Errorf("unknown path normalization %q: use nfc", policy.Normalize)
+	}
+	sanitize := func(p string) (string, error) { return policy.sanitize(p) }
+	return mapPaths(deltagram, sanitize, sanitize)
+}
+
+// sanitize applies the policy to one path
+func (policy SanitizePolicy) sanitize(p string) (string, error) {
+	if !utf8.ValidString(p) {
+		return "", fmt.Errorf("refusing path %q: it is not valid UTF-8", p)
+	}
+	for _, r := range p {
+		switch {
+		case unicode.IsControl(r):
+			return "", fmt.Errorf("refusing path %q: it contains control character %U", p, r)
+		case unicode.Is(unicode.Cf, r):
+			return "", fmt.Errorf("refusing path %q: it contains invisible character %U", p, r)
+		}
+	}
+	if policy.Normalize == "nfc" {
+		p = composeAccents(p)
+	}
+	if policy.Lowercase {
+		p = strings.ToLower(p)
+	}
+	if policy.ASCII {
+		for _, r := range p {
+			if r > unicode.MaxASCII {
+				return "", fmt.Errorf("refusing path %q: it contains non-ASCII character %q (%U)", p, r, r)
+			}
+		}
+	}
+	return p, nil
+}
+
+// composeAccents replaces each ASCII letter followed by a combining accent with the
+// precomposed letter from Latin-1 or Latin Extended-A, as Unicode NFC would. Other
+// combining sequences are left as they are.
+func composeAccents(s string) string {
+	runes := []rune(s)
+	out := make([]rune, 0, len(runes))
+	for i := 0; i < len(runes); i++ {
+		if i+1 < len(runes) && runes[i] <= unicode.MaxASCII {
+			if composed, ok := precomposed[runes[i+1]][runes[i]]; ok {
+				out = append(out, composed)
+				i++
+				continue
+			}
+		}
+		out = append(out, runes[i])
+	}
+	return string(out)
+}
+
+// precomposed maps a combining accent and an ASCII letter to the accented letter
+var precomposed = func() map[rune]map[rune]rune {
+	// Each string lists letters followed by their accented form
+	pairs := map[rune]string{
+		0x0300: "AÀEÈIÌOÒUÙaàeèiìoòuù",                             // grave accent
+		0x0301: "AÁEÉIÍOÓUÚYÝaáeéiíoóuúyýCĆcćLĹlĺNŃnńRŔrŕSŚsśZŹzź", // acute accent
+		0x0302: "AÂEÊIÎOÔUÛaâeêiîoôuûCĈcĉGĜgĝHĤhĥJĴjĵSŜsŝWŴwŵYŶyŷ", // circumflex accent
+		0x0303: "AÃNÑOÕaãnñoõIĨiĩUŨuũ",                             // tilde
+		0x0304: "AĀaāEĒeēIĪiīOŌoōUŪuū",                             // macron
+		0x0306: "AĂaăEĔeĕGĞgğIĬiĭOŎoŏUŬuŭ",                         // breve
+		0x0307: "CĊcċEĖeėGĠgġIİZŻzż",                               // dot above
+		0x0308: "AÄEËIÏOÖUÜaäeëiïoöuüyÿYŸ",                         // diaeresis
+		0x030A: "AÅaåUŮuů",                                         // ring above
+		0x030B: "OŐoőUŰuű",                                         // double acute accent
+		0x030C: "CČcčDĎdďEĚeěLĽlľNŇnňRŘrřSŠsšTŤtťZŽzž",             // caron
+		0x0327: "CÇcçGĢgģKĶkķLĻlļNŅnņRŖrŗSŞsşTŢtţ",                 // cedilla
+		0x0328: "AĄaąEĘeęIĮiįUŲuų",                                 // ogonek
+	}
+	table := make(map[rune]map[rune]rune, len(pairs))
+	for accent, letters := range pairs {
+		runes := []rune(letters)
+		table[accent] = make(map[rune]rune, len(runes)/2)
+		for i := 0; i+1 < len(runes); i += 2 {
+			table[accent][runes[i]] = runes[i+1]
+		}
+	}
+	return table
+}()
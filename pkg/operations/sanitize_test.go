@@ -0,0 +1,88 @@
+package operations
+
+import (
+	"io"
+	"testing"
+
+	"github.com/developingjames/deltagrams/internal/testutil"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func TestSanitizePolicy_Sanitize(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  SanitizePolicy
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{name: "plain", path: "src/main.go", want: "src/main.go"},
+		{name: "accents kept", path: "docs/résumé.md", want: "docs/résumé.md"},
+		{name: "control character", path: "src/\x1b[31mmain.go", wantErr: true},
+		{name: "bidi override", path: "src/evil\u202egnp.exe", wantErr: true},
+		{name: "zero-width space", path: "src/ma\u200bin.go", wantErr: true},
+		{name: "invalid UTF-8", path: "src/\xffmain.go", wantErr: true},
+		{name: "nfc", policy: SanitizePolicy{Normalize: "nfc"}, path: "docs/re\u0301sume\u0301.md", want: "docs/r\u00e9sum\u00e9.md"},
+		{name: "lowercase", policy: SanitizePolicy{Lowercase: true}, path: "Src/README.md", want: "src/readme.md"},
+		{name: "ascii", policy: SanitizePolicy{ASCII: true}, path: "src/m\u0430in.go", wantErr: true},
+		{name: "ascii after nfc", policy: SanitizePolicy{Normalize: "nfc", ASCII: true}, path: "cafe\u0301.txt", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.policy.sanitize(tt.path)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected an error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSanitizePaths(t *testing.T) {
+	deltagram := &parser.Deltagram{UUID: "0123456789abcdef", Parts: []parser.DeltagramPart{
+		{ContentLocation: "deltagram://message", ContentType: "text/plain", Content: "Rename"},
+		{ContentLocation: "Docs/New.md", ContentType: "text/plain", DeltaOperation: "move", Content: "--- Docs/Old.md\n+++ Docs/New.md"},
+	}}
+
+	got, err := SanitizePaths(deltagram, SanitizePolicy{Lowercase: true})
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if got.Parts[1].ContentLocation != "docs/new.md" || got.Parts[1].Content != "--- docs/old.md\n+++ docs/new.md" {
+		t.Errorf("Expected lowercase paths, got %q %q", got.Parts[1].ContentLocation, got.Parts[1].Content)
+	}
+	if got.Parts[0].ContentLocation != "deltagram://message" {
+		t.Errorf("Expected the message part unchanged, got %q", got.Parts[0].ContentLocation)
+	}
+
+	if _, err := SanitizePaths(deltagram, SanitizePolicy{Normalize: "nfd"}); err == nil {
+		t.Error("Expected an unknown normalization to be rejected")
+	}
+}
+
+func TestApplier_Apply_Sanitize(t *testing.T) {
+	fs := testutil.NewMockFileSystem()
+	deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{createPart("README.md")}}
+
+	applier := NewApplierWithOptions(fs, Options{Sanitize: SanitizePolicy{Lowercase: true}, Output: io.Discard})
+	if err := applier.Apply(deltagram, "/base"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, err := fs.ReadFile("/base/readme.md"); err != nil {
+		t.Errorf("Expected readme.md to be created: %v", err)
+	}
+
+	deltagram.Parts[0].ContentLocation = "read\u200bme.md"
+	if err := applier.Apply(deltagram, "/base"); err == nil {
+		t.Error("Expected a path with a zero-width space to be refused")
+	}
+}
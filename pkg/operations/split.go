@@ -0,0 +1,87 @@
+package operations
+
+import (
+	"fmt"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// Ways Split can group the file operations of a deltagram
+const (
+	SplitByFile      = "file"      // One deltagram per target path
+	SplitByOperation = "operation" // One deltagram per kind of operation
+)
+
+// SplitPiece is one of the deltagrams Split produces
+type SplitPiece struct {
+	Key       string // Target path or operation the piece holds
+	Deltagram *parser.Deltagram
+}
+
+// Split divides a deltagram into smaller ones, each holding the file operations for one
+// target path or of one kind, in the order they first appear. Operations keep their
+// relative order within a piece. A note goes with the operations on the file it is
+// about; the message and any requirements are repeated in every piece, and the commands
+// to run go with the last piece.
+func Split(deltagram *parser.Deltagram, by string) ([]SplitPiece, error) {
+	if by != SplitByFile && by != SplitByOperation {
+		return nil, fmt.Errorf("unknown split %q: use %s or %s", by, SplitByFile, SplitByOperation)
+	}
+
+	var shared, notes, run []parser.DeltagramPart
+	var pieces []SplitPiece
+	index := make(map[string]int)
+	keyOf := make(map[string]string) // Target path to the key of the first piece writing it
+	for _, part := range deltagram.Parts {
+		switch {
+		case part.IsNote():
+			notes = append(notes, part)
+			continue
+		case part.IsRun():
+			run = append(run, part)
+			continue
+		case part.IsMessage():
+			shared = append(shared, part)
+			continue
+		}
+
+		key := part.ContentLocation
+		if by == SplitByOperation {
+			key = part.DeltaOperation
+			if key == "" {
+				key = "create"
+			}
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(pieces)
+			index[key] = i
+			pieces = append(pieces, SplitPiece{Key: key, Deltagram: &parser.Deltagram{UUID: deltagram.UUID, Version: deltagram.Version}})
+		}
+		pieces[i].Deltagram.Parts = append(pieces[i].Deltagram.Parts, part)
+		for _, path := range WrittenPaths(part) {
+			if _, seen := keyOf[path]; !seen {
+				keyOf[path] = key
+			}
+		}
+	}
+	if len(pieces) == 0 {
+		return nil, fmt.Errorf("the deltagram has no file operations to split")
+	}
+
+	for i := range pieces {
+		parts := append(append([]parser.DeltagramPart{}, shared...), pieces[i].Deltagram.Parts...)
+		for _, note := range notes {
+			key, ok := keyOf[note.NoteTarget()]
+			if (ok && key == pieces[i].Key) || (!ok && i == 0) {
+				parts = append(parts, note)
+			}
+		}
+		if i == len(pieces)-1 {
+			parts = append(parts, run...)
+		}
+		pieces[i].Deltagram.Parts = parts
+		pieces[i].Deltagram.Metadata = deltagram.Metadata
+	}
+	return pieces, nil
+}
@@ -0,0 +1,92 @@
+package operations
+
+import (
+	"testing"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func splitTestDeltagram() *parser.Deltagram {
+	return &parser.Deltagram{UUID: "0123456789abcdef", Parts: []parser.DeltagramPart{
+		{ContentLocation: "deltagram://message", ContentType: "text/plain", Content: "Refactor"},
+		{ContentLocation: "a.go", ContentType: "text/plain", DeltaOperation: "content", Content: "@@ -1 +1 @@\n-a\n+A"},
+		{ContentLocation: "b.go", ContentType: "text/plain", DeltaOperation: "create", Content: "b"},
+		{ContentLocation: parser.NotePrefix + "b.go", ContentType: "text/plain", Content: "new file"},
+		{ContentLocation: "a.go", ContentType: "text/plain", DeltaOperation: "content", Content: "@@ -2 +2 @@\n-c\n+C"},
+		{ContentLocation: "c.go", ContentType: "text/plain", DeltaOperation: "create", Content: "c"},
+		{ContentLocation: parser.RunLocation, ContentType: "text/plain", Content: "go test ./..."},
+	}}
+}
+
+// locations returns the Content-Location of each part of a deltagram
+func locations(deltagram *parser.Deltagram) []string {
+	var result []string
+	for _, part := range deltagram.Parts {
+		result = append(result, part.ContentLocation)
+	}
+	return result
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		by   string
+		keys []string
+		want [][]string
+	}{
+		{
+			by:   SplitByFile,
+			keys: []string{"a.go", "b.go", "c.go"},
+			want: [][]string{
+				{"deltagram://message", "a.go", "a.go"},
+				{"deltagram://message", "b.go", parser.NotePrefix + "b.go"},
+				{"deltagram://message", "c.go", parser.RunLocation},
+			},
+		},
+		{
+			by:   SplitByOperation,
+			keys: []string{"content", "create"},
+			want: [][]string{
+				{"deltagram://message", "a.go", "a.go"},
+				{"deltagram://message", "b.go", "c.go", parser.NotePrefix + "b.go", parser.RunLocation},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.by, func(t *testing.T) {
+			pieces, err := Split(splitTestDeltagram(), tt.by)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if len(pieces) != len(tt.keys) {
+				t.Fatalf("Expected %d pieces, got %d", len(tt.keys), len(pieces))
+			}
+			for i, piece := range pieces {
+				if piece.Key != tt.keys[i] {
+					t.Errorf("Piece %d: expected key %q, got %q", i+1, tt.keys[i], piece.Key)
+				}
+				got := locations(piece.Deltagram)
+				if len(got) != len(tt.want[i]) {
+					t.Errorf("Piece %d: expected parts %v, got %v", i+1, tt.want[i], got)
+					continue
+				}
+				for j := range got {
+					if got[j] != tt.want[i][j] {
+						t.Errorf("Piece %d: expected parts %v, got %v", i+1, tt.want[i], got)
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSplit_Errors(t *testing.T) {
+	if _, err := Split(splitTestDeltagram(), "size"); err == nil {
+		t.Error("Expected an unknown split to be rejected")
+	}
+	messageOnly := &parser.Deltagram{Parts: []parser.DeltagramPart{{ContentLocation: "deltagram://message", Content: "hi"}}}
+	if _, err := Split(messageOnly, SplitByFile); err == nil {
+		t.Error("Expected a deltagram without file operations to be rejected")
+	}
+}
@@ -0,0 +1,355 @@
+package operations
+
+import (
+	"os"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// PartStat summarizes one file operation of a deltagram
+type PartStat struct {
+	Path      string `json:"path"`
+	Source    string `json:"source,omitempty"` // Source path of a copy or move
+	Operation string `json:"operation"`
+	Added     int    `json:"added"`
+	Removed   int    `json:"removed"`
+	Hunks     int    `json:"hunks,omitempty"`
+	NewFile   bool   `json:"new_file,omitempty"`
+	Fuzzy     int    `json:"fuzzy,omitempty"`   // Hunks that match only at an offset
+	Failed    int    `json:"failed,omitempty"`  // Hunks that do not match
+	Missing   bool   `json:"missing,omitempty"` // The file the operation needs does not exist
+	Problem   string `json:"problem,omitempty"`
+}
+
+// Stats summarizes a deltagram and the risk of applying it to a tree
+type Stats struct {
+	Operations map[string]int `json:"operations"`
+	Parts      []PartStat     `json:"parts"`
+	Added      int            `json:"added"`
+	Removed    int            `json:"removed"`
+	NewFiles   int            `json:"new_files"`
+	Fuzzy      int            `json:"fuzzy"`
+	Failed     int            `json:"failed"`
+	Missing    int            `json:"missing"`
+	PatchID    string         `json:"patch_id"` // Fingerprint of the changes; see PatchID
+}
+
+// Risk estimates how likely the deltagram is to apply as intended: "high" when hunks
+// fail or targets are missing, "medium" when hunks need fuzzy matching, otherwise "low"
+func (s *Stats) Risk() string {
+	switch {
+	case s.Failed > 0 || s.Missing > 0:
+		return "high"
+	case s.Fuzzy > 0:
+		return "medium"
+	default:
+		return "low"
+	}
+}
+
+// Summarize counts the operations and changed lines of a deltagram and checks each part
+// against the files under baseDir without modifying anything. Every part is checked
+// against the tree as it is now, so a part that depends on an earlier one may be
+// reported as missing its target.
+func Summarize(fs FileSystem, baseDir string, deltagram *parser.Deltagram) *Stats {
+	stats := &Stats{Operations: make(map[string]int), PatchID: PatchID(deltagram)}
+
+	for _, part := range deltagram.Parts {
+		if part.IsMessage() {
+			continue
+		}
+
+		operation := part.DeltaOperation
+		if operation == "" {
+			operation = "create"
+		}
+		stat := PartStat{Path: part.ContentLocation, Operation: operation}
+
+		switch operation {
+		case "create":
+			stat.Added = countLines(createContent(part))
+			stat.NewFile = !exists(fs, ResolveFilePath(baseDir, part.ContentLocation))
+		case "touch":
+			stat.NewFile = !exists(fs, ResolveFilePath(baseDir, part.ContentLocation))
+		case "truncate":
+			summarizeTruncate(fs, baseDir, part, &stat)
+		case "delete":
+			data, err := fs.ReadFile(ResolveFilePath(baseDir, part.ContentLocation))
+			if err != nil {
+				stat.Missing = true
+			} else {
+				stat.Removed = countLines(string(data))
+			}
+		case "content":
+			summarizeContent(fs, baseDir, part, &stat)
+		case "inline":
+			summarizeInline(fs, baseDir, part, &stat)
+		case "table":
+			summarizeTable(fs, baseDir, part, &stat)
+		case "lines":
+			summarizeLines(fs, baseDir, part, &stat)
+		case "kvset":
+			summarizeKeyValues(fs, baseDir, part, &stat)
+		case "yaml":
+			summarizeYAML(fs, baseDir, part, &stat)
+		case "go-ast":
+			summarizeGoAST(fs, baseDir, part, &stat)
+		case "copy", "move":
+			source, dest, _, err := transferPaths(part)
+			if err != nil {
+				stat.Problem = err.Error()
+				break
+			}
+			stat.Source, stat.Path = source, dest
+			stat.NewFile = true
+			if !exists(fs, ResolveFilePath(baseDir, source)) {
+				stat.Missing = true
+			}
+			countHunkLines(part.Content, &stat)
+		}
+
+		stats.Operations[operation]++
+		stats.Added += stat.Added
+		stats.Removed += stat.Removed
+		stats.Fuzzy += stat.Fuzzy
+		stats.Failed += stat.Failed
+		if stat.NewFile {
+			stats.NewFiles++
+		}
+		if stat.Missing {
+			stats.Missing++
+		}
+		stats.Parts = append(stats.Parts, stat)
+	}
+
+	return stats
+}
+
+// summarizeInline counts the lines an inline part changes, each of which a diff would
+// show as removed and added, and checks its edits
+func summarizeInline(fs FileSystem, baseDir string, part parser.DeltagramPart, stat *PartStat) {
+	edits, err := ParseInlineEdits(part.Content)
+	if err != nil {
+		stat.Problem = err.Error()
+		return
+	}
+	lines := make(map[int]bool)
+	for _, edit := range edits {
+		lines[edit.Line] = true
+	}
+	stat.Hunks, stat.Added, stat.Removed = len(edits), len(lines), len(lines)
+
+	data, err := fs.ReadFile(ResolveFilePath(baseDir, part.ContentLocation))
+	if err != nil {
+		stat.Missing = os.IsNotExist(err)
+		stat.Problem = err.Error()
+		return
+	}
+	_, err = applyInlineEdits(string(data), edits, func(string, ...interface{}) { stat.Fuzzy++ })
+	if err != nil {
+		stat.Failed++
+		stat.Problem = err.Error()
+	}
+}
+
+// summarizeTable counts the rows a table part adds and removes and checks them against
+// the file
+func summarizeTable(fs FileSystem, baseDir string, part parser.DeltagramPart, stat *PartStat) {
+	changes, err := ParseTableChanges(part.Content, TableDelimiter(part))
+	if err != nil {
+		stat.Problem = err.Error()
+		return
+	}
+	for _, change := range changes {
+		if change.Remove {
+			stat.Removed++
+		} else {
+			stat.Added++
+		}
+	}
+
+	data, err := fs.ReadFile(ResolveFilePath(baseDir, part.ContentLocation))
+	if err != nil {
+		stat.Missing = os.IsNotExist(err)
+		stat.Problem = err.Error()
+		return
+	}
+	if _, err := applyTablePart(string(data), part); err != nil {
+		stat.Failed++
+		stat.Problem = err.Error()
+	}
+}
+
+// summarizeLines counts the lines a lines part would actually add and remove, leaving out
+// lines the file already has or lacks
+func summarizeLines(fs FileSystem, baseDir string, part parser.DeltagramPart, stat *PartStat) {
+	added, removed, err := ParseLineSet(part.Content)
+	if err != nil {
+		stat.Problem = err.Error()
+		return
+	}
+	data, err := fs.ReadFile(ResolveFilePath(baseDir, part.ContentLocation))
+	if err != nil && !os.IsNotExist(err) {
+		stat.Problem = err.Error()
+		return
+	}
+	stat.NewFile = os.IsNotExist(err)
+	_, stat.Added, stat.Removed = mergeLineSet(string(data), added, removed)
+}
+
+// summarizeKeyValues counts the keys a kvset part sets as added lines and the keys it
+// removes as removed lines
+func summarizeKeyValues(fs FileSystem, baseDir string, part parser.DeltagramPart, stat *PartStat) {
+	changes, err := ParseKeyValueChanges(part.Content)
+	if err != nil {
+		stat.Problem = err.Error()
+		return
+	}
+	for _, change := range changes {
+		if change.Remove {
+			stat.Removed++
+		} else {
+			stat.Added++
+		}
+	}
+	stat.NewFile = !exists(fs, ResolveFilePath(baseDir, part.ContentLocation))
+}
+
+// summarizeYAML counts a yaml part's sets as added lines and its removes as removed lines,
+// and checks that its paths can be reached
+func summarizeYAML(fs FileSystem, baseDir string, part parser.DeltagramPart, stat *PartStat) {
+	changes, err := ParseYAMLChanges(part.Content)
+	if err != nil {
+		stat.Problem = err.Error()
+		return
+	}
+	for _, change := range changes {
+		if change.Remove {
+			stat.Removed++
+		} else {
+			stat.Added++
+		}
+	}
+
+	data, err := fs.ReadFile(ResolveFilePath(baseDir, part.ContentLocation))
+	if err != nil {
+		stat.Missing = os.IsNotExist(err)
+		stat.Problem = err.Error()
+		return
+	}
+	if _, err := applyYAMLChanges(string(data), changes); err != nil {
+		stat.Failed++
+		stat.Problem = err.Error()
+	}
+}
+
+// summarizeGoAST counts a go-ast part's removals as removed lines and its other changes as
+// added lines, and checks that they can be made
+func summarizeGoAST(fs FileSystem, baseDir string, part parser.DeltagramPart, stat *PartStat) {
+	changes, err := ParseGoASTChanges(part.Content)
+	if err != nil {
+		stat.Problem = err.Error()
+		return
+	}
+	for _, change := range changes {
+		if strings.HasPrefix(change.Action, "remove-") {
+			stat.Removed++
+		} else {
+			stat.Added++
+		}
+	}
+
+	data, err := fs.ReadFile(ResolveFilePath(baseDir, part.ContentLocation))
+	if err != nil {
+		stat.Missing = os.IsNotExist(err)
+		stat.Problem = err.Error()
+		return
+	}
+	if _, err := applyGoASTChanges(string(data), changes); err != nil {
+		stat.Failed++
+		stat.Problem = err.Error()
+	}
+}
+
+// summarizeTruncate counts the lines a truncate part would cut as removed lines
+func summarizeTruncate(fs FileSystem, baseDir string, part parser.DeltagramPart, stat *PartStat) {
+	keep, err := TruncateLines(part)
+	if err != nil {
+		stat.Problem = err.Error()
+		return
+	}
+	data, err := fs.ReadFile(ResolveFilePath(baseDir, part.ContentLocation))
+	if err != nil {
+		stat.Missing = os.IsNotExist(err)
+		stat.Problem = err.Error()
+		return
+	}
+	_, stat.Removed = truncateText(string(data), keep)
+}
+
+// summarizeContent counts a content part's changed lines and checks its hunks
+func summarizeContent(fs FileSystem, baseDir string, part parser.DeltagramPart, stat *PartStat) {
+	countHunkLines(part.Content, stat)
+
+	results, err := CheckContentPart(fs, baseDir, part)
+	if err != nil {
+		stat.Problem = err.Error()
+		if os.IsNotExist(statErr(fs, ResolveFilePath(baseDir, part.ContentLocation))) {
+			stat.Missing = true
+		} else {
+			stat.Failed = stat.Hunks
+		}
+		return
+	}
+	if results == nil {
+		stat.NewFile = true
+		return
+	}
+	for _, result := range results {
+		switch result.Status {
+		case HunkFuzzy:
+			stat.Fuzzy++
+		case HunkFailed:
+			stat.Failed++
+		}
+	}
+}
+
+// countHunkLines adds the hunks and added and removed lines of a diff body to stat
+func countHunkLines(content string, stat *PartStat) {
+	hunks, err := (&ContentHandler{}).ParseAllHunks(strings.Split(content, "\n"))
+	if err != nil {
+		stat.Problem = err.Error()
+		return
+	}
+	stat.Hunks = len(hunks)
+	for _, hunk := range hunks {
+		for _, op := range hunk.Operations {
+			switch op.Type {
+			case '+':
+				stat.Added++
+			case '-':
+				stat.Removed++
+			}
+		}
+	}
+}
+
+// countLines returns the number of lines in content, not counting a trailing newline
+func countLines(content string) int {
+	content = strings.TrimSuffix(content, "\n")
+	if content == "" {
+		return 0
+	}
+	return strings.Count(content, "\n") + 1
+}
+
+func exists(fs FileSystem, path string) bool {
+	return statErr(fs, path) == nil
+}
+
+func statErr(fs FileSystem, path string) error {
+	_, err := fs.Stat(path)
+	return err
+}
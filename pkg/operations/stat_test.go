@@ -0,0 +1,101 @@
+package operations
+
+import (
+	"testing"
+
+	"github.com/developingjames/deltagrams/internal/testutil"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func TestSummarize(t *testing.T) {
+	fs := testutil.NewMockFileSystem()
+	fs.AddFile("/base/file.txt", []byte("a\nb\nc\nd\ne\nf"))
+	fs.AddFile("/base/old.txt", []byte("one\ntwo\n"))
+
+	deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{
+		{ContentLocation: "deltagram://message", ContentType: "text/plain", Content: "Summary"},
+		{ContentLocation: "new.txt", DeltaOperation: "create", Content: "+++ new.txt\nx\ny\nz"},
+		{ContentLocation: "file.txt", DeltaOperation: "content", Content: "@@ -1,2 +1,2 @@\n a\n-b\n+B\n@@ -2,2 +2,3 @@\n d\n-e\n+E\n+E2"},
+		{ContentLocation: "old.txt", DeltaOperation: "delete"},
+		{ContentLocation: "gone.txt", DeltaOperation: "delete"},
+		{ContentLocation: "moved.txt", DeltaOperation: "move", Content: "--- missing.txt\n+++ moved.txt"},
+		{ContentLocation: "file.txt", DeltaOperation: "inline", Content: "@@ 3:1\n-c\n+C\n@@ 4:1\n+D"},
+	}}
+
+	stats := Summarize(fs, "/base", deltagram)
+
+	expectedOps := map[string]int{"create": 1, "content": 1, "delete": 2, "move": 1, "inline": 1}
+	for op, count := range expectedOps {
+		if stats.Operations[op] != count {
+			t.Errorf("Expected %d %s operations, got %d", count, op, stats.Operations[op])
+		}
+	}
+	if len(stats.Parts) != 6 {
+		t.Fatalf("Expected 6 parts, got %d", len(stats.Parts))
+	}
+
+	tests := []struct {
+		name    string
+		stat    PartStat
+		added   int
+		removed int
+		newFile bool
+		missing bool
+	}{
+		{"create", stats.Parts[0], 3, 0, true, false},
+		{"content", stats.Parts[1], 3, 2, false, false},
+		{"delete", stats.Parts[2], 0, 2, false, false},
+		{"delete missing", stats.Parts[3], 0, 0, false, true},
+		{"move missing source", stats.Parts[4], 0, 0, true, true},
+		{"inline", stats.Parts[5], 2, 2, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.stat.Added != tt.added || tt.stat.Removed != tt.removed {
+				t.Errorf("Expected +%d -%d, got +%d -%d", tt.added, tt.removed, tt.stat.Added, tt.stat.Removed)
+			}
+			if tt.stat.NewFile != tt.newFile {
+				t.Errorf("Expected new file %v, got %v", tt.newFile, tt.stat.NewFile)
+			}
+			if tt.stat.Missing != tt.missing {
+				t.Errorf("Expected missing %v, got %v", tt.missing, tt.stat.Missing)
+			}
+		})
+	}
+
+	if stats.Parts[1].Hunks != 2 || stats.Parts[1].Fuzzy != 1 {
+		t.Errorf("Expected 2 hunks with 1 fuzzy match, got %d hunks and %d fuzzy", stats.Parts[1].Hunks, stats.Parts[1].Fuzzy)
+	}
+	if stats.Parts[4].Source != "missing.txt" || stats.Parts[4].Path != "moved.txt" {
+		t.Errorf("Expected move from missing.txt to moved.txt, got %s -> %s", stats.Parts[4].Source, stats.Parts[4].Path)
+	}
+	if stats.Parts[5].Hunks != 2 || stats.Parts[5].Failed != 0 {
+		t.Errorf("Expected 2 inline edits that apply, got %+v", stats.Parts[5])
+	}
+	if stats.Added != 8 || stats.Removed != 6 || stats.NewFiles != 2 || stats.Missing != 2 {
+		t.Errorf("Unexpected totals: %+v", stats)
+	}
+	if stats.Risk() != "high" {
+		t.Errorf("Expected high risk, got %s", stats.Risk())
+	}
+}
+
+func TestStats_Risk(t *testing.T) {
+	tests := []struct {
+		name     string
+		stats    Stats
+		expected string
+	}{
+		{"clean", Stats{}, "low"},
+		{"fuzzy", Stats{Fuzzy: 1}, "medium"},
+		{"failed", Stats{Fuzzy: 1, Failed: 1}, "high"},
+		{"missing", Stats{Missing: 1}, "high"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if risk := tt.stats.Risk(); risk != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, risk)
+			}
+		})
+	}
+}
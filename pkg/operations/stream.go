@@ -0,0 +1,195 @@
+package operations
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// DefaultStreamThreshold is the file size above which content operations stream the
+// file instead of loading it into memory
+const DefaultStreamThreshold = 16 << 20
+
+// shouldStream reports whether a content operation on a file of the given size should be
+// streamed. Streaming needs hunks in file order and cannot consult a conflict resolver,
+// which is shown the whole file.
+func (h *ContentHandler) shouldStream(size int64, hunks []*ParsedHunk) bool {
+	threshold := h.streamThreshold
+	if threshold == 0 {
+		threshold = DefaultStreamThreshold
+	}
+	if threshold < 0 || size <= threshold || h.resolver != nil {
+		return false
+	}
+
+	for i := 1; i < len(hunks); i++ {
+		previous := hunks[i-1].Header
+		if hunks[i].Header.OldStart < previous.OldStart+previous.OldCount {
+			return false
+		}
+	}
+	return true
+}
+
+// applyStreaming patches the file by reading it line by line, keeping only a window of
+// lines around the current hunk in memory. The result is written next to the file and
+// renamed over it once every hunk has matched, so a failure leaves the file untouched.
+func (h *ContentHandler) applyStreaming(fs FileSystem, location, filePath string, hunks []*ParsedHunk, info os.FileInfo) error {
+	src, err := fs.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read existing file: %w", err)
+	}
+	defer src.Close()
+
+	tmpPath := filePath + ".deltagram-stream"
+	dst, err := fs.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+
+	err = streamHunks(h, location, bufio.NewReader(src), dst, hunks)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	src.Close()
+	if err != nil {
+		fs.Remove(tmpPath)
+		return fmt.Errorf("failed to apply diff: %w", err)
+	}
+
+	if err := fs.Rename(tmpPath, filePath); err != nil {
+		fs.Remove(tmpPath)
+		return fmt.Errorf("failed to write modified file: %w", err)
+	}
+	if err := fs.Chmod(filePath, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to restore permissions: %w", err)
+	}
+	return nil
+}
+
+// lineWindow buffers the lines of a file that have been read but not yet written out
+type lineWindow struct {
+	r     *bufio.Reader
+	lines []string // Lines of the file starting at line base (0-based)
+	base  int
+	eof   bool
+	// noFinalNewline is set at the end of a file whose last line has no newline
+	noFinalNewline bool
+}
+
+// fill reads until the window holds line index last or the file ends
+func (w *lineWindow) fill(last int) error {
+	for !w.eof && w.base+len(w.lines) <= last {
+		line, err := w.r.ReadString('\n')
+		if err == io.EOF {
+			// As in fileLines, a final newline ends the last line rather than starting one
+			if line != "" {
+				w.lines = append(w.lines, line)
+				w.noFinalNewline = true
+			}
+			w.eof = true
+			break
+		}
+		if err != nil {
+			return err
+		}
+		w.lines = append(w.lines, strings.TrimSuffix(line, "\n"))
+	}
+	return nil
+}
+
+// drop discards the first n buffered lines
+func (w *lineWindow) drop(n int) {
+	w.lines = w.lines[n:]
+	w.base += n
+}
+
+// streamHunks copies r to out, applying hunks that are in file order. It produces the
+// same result as applyUnifiedDiff for the same input.
+func streamHunks(h *ContentHandler, location string, r *bufio.Reader, out io.Writer, hunks []*ParsedHunk) error {
+	w := bufio.NewWriter(out)
+	window := &lineWindow{r: r}
+	// As in applyUnifiedDiff, a byte order mark is kept out of the first line
+	if start, _ := r.Peek(len(byteOrderMark)); string(start) == byteOrderMark {
+		r.Discard(len(byteOrderMark))
+		w.WriteString(byteOrderMark)
+	}
+
+	first := true
+	emit := func(line string) {
+		if !first {
+			w.WriteByte('\n')
+		}
+		first = false
+		w.WriteString(line)
+	}
+	// flush writes out buffered lines before line index end
+	flush := func(end int) {
+		n := min(end-window.base, len(window.lines))
+		for _, line := range window.lines[:max(n, 0)] {
+			emit(line)
+		}
+		if n > 0 {
+			window.drop(n)
+		}
+	}
+
+	var placements []hunkPlacement
+	for index, hunk := range hunks {
+		suggested := hunk.expectedStart()
+		oldLines := 0
+		for _, op := range hunk.Operations {
+			if op.Type != '+' {
+				oldLines++
+			}
+		}
+
+		// Lines before the search window will not be touched by this or any later hunk
+		flush(suggested - fuzzSearchRange)
+		if err := window.fill(suggested + fuzzSearchRange + oldLines); err != nil {
+			return err
+		}
+
+		total := window.base + len(window.lines)
+		if suggested < 0 || (window.eof && !inRange(hunk, suggested, total)) {
+			return fmt.Errorf("%w: hunk refers to line %d but original file has %d lines", ErrConflict, hunk.Header.OldStart, total)
+		}
+		if suggested < window.base {
+			return fmt.Errorf("%w: hunk at line %d overlaps the previous hunk", ErrConflict, hunk.Header.OldStart)
+		}
+
+		position, err := h.findBestHunkPositionAt(window.lines, window.base, hunk, suggested-window.base)
+		if err != nil {
+			return fmt.Errorf("failed to find position for hunk at line %d: %w", hunk.Header.OldStart, err)
+		}
+
+		if window.base+position != suggested {
+			h.warnFuzzy(location, index+1, window.base+position, window.base+position-suggested)
+		}
+		placements = append(placements, hunkPlacement{start: window.base + position, hunk: hunk})
+		flush(window.base + position)
+		if err := window.fill(window.base + hunk.Header.OldCount); err != nil {
+			return err
+		}
+		forEachReplacementLine(hunk, window.lines, emit)
+		window.drop(min(hunk.Header.OldCount, len(window.lines)))
+	}
+
+	// Copy the rest of the file without holding it in memory
+	for {
+		flush(window.base + len(window.lines))
+		if window.eof {
+			break
+		}
+		if err := window.fill(window.base + 1024); err != nil {
+			return err
+		}
+	}
+	if !first && endsWithNewline(!window.noFinalNewline, placements) {
+		w.WriteByte('\n')
+	}
+
+	return w.Flush()
+}
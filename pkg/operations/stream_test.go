@@ -0,0 +1,155 @@
+package operations
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+
+	"github.com/developingjames/deltagrams/internal/testutil"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func TestStreamHunks_MatchesInMemory(t *testing.T) {
+	large, largeDiff := benchmarkDiff(3000, 37)
+
+	tests := []struct {
+		name     string
+		original string
+		diff     string
+	}{
+		{"single replacement", "a\nb\nc", "@@ -2,1 +2,1 @@\n-b\n+B"},
+		{"trailing newline", "a\nb\nc\n", "@@ -3,1 +3,2 @@\n c\n+d"},
+		{"fuzzy match", "x\nx\na\nb\nc", "@@ -1,2 +1,2 @@\n a\n-b\n+B"},
+		{"insertion", "a\nb", "@@ -1,0 +2,1 @@\n+inserted"},
+		{"delete everything", "a\nb", "@@ -1,2 +0,0 @@\n-a\n-b"},
+		{"hunk at end", "a\nb\nc\nd", "@@ -4,1 +4,1 @@\n-d\n+D"},
+		{"many hunks", large, largeDiff},
+		{"insertion into empty file", "", "@@ -0,0 +1,2 @@\n+a\n+b"},
+		{"insertion at end", "a\nb\n", "@@ -2,0 +3 @@\n+c"},
+		{"final newline added", "a\nb", "@@ -2 +2 @@\n-b\n\\ No newline at end of file\n+b"},
+		{"final newline removed", "a\nb\n", "@@ -2 +2 @@\n-b\n+b\n\\ No newline at end of file"},
+		{"byte order mark", "\uFEFFa\nb\n", "@@ -1,2 +1,2 @@\n a\n-b\n+B"},
+		{"carriage returns", "a\r\nb\r\nc\r\n", "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &ContentHandler{}
+			expected, err := h.applyUnifiedDiff("file.txt", tt.original, tt.diff)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+
+			hunks, err := h.ParseAllHunks(strings.Split(tt.diff, "\n"))
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			var out strings.Builder
+			if err := streamHunks(h, "file.txt", bufio.NewReaderSize(strings.NewReader(tt.original), 16), &out, hunks); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if out.String() != expected {
+				t.Errorf("Expected %q, got %q", expected, out.String())
+			}
+		})
+	}
+}
+
+func TestStreamHunks_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		diff     string
+		expected string
+	}{
+		{"beyond end", "a\nb", "@@ -5,1 +5,1 @@\n-e\n+E", "hunk refers to line 5 but original file has 2 lines"},
+		{"mismatch", strings.Repeat("line\n", 20) + "a\nb", "@@ -21,2 +21,2 @@\n a\n-c\n+C", "removal mismatch at original line 22"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &ContentHandler{}
+			hunks, err := h.ParseAllHunks(strings.Split(tt.diff, "\n"))
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			var out strings.Builder
+			err = streamHunks(h, "file.txt", bufio.NewReader(strings.NewReader(tt.original)), &out, hunks)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected error containing %q, got: %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestContentHandler_Apply_Streaming(t *testing.T) {
+	tests := []struct {
+		name     string
+		diff     string
+		expected string
+		err      string
+	}{
+		{"applies", "@@ -2,1 +2,1 @@\n-b\n+B", "a\nB\nc\n", ""},
+		{"leaves file on failure", "@@ -2,1 +2,1 @@\n-x\n+X", "a\nb\nc\n", "removal mismatch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := testutil.NewMockFileSystem()
+			fs.AddFile("/base/big.txt", []byte("a\nb\nc\n"))
+			fs.SetMode("/base/big.txt", 0600)
+
+			part := parser.DeltagramPart{ContentLocation: "big.txt", DeltaOperation: "content", Content: tt.diff}
+			handler := &ContentHandler{streamThreshold: 1}
+			err := handler.Apply(fs, "/base", part)
+			if tt.err == "" && err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("Expected error containing %q, got: %v", tt.err, err)
+			}
+
+			content, _ := fs.ReadFile("/base/big.txt")
+			if string(content) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, content)
+			}
+			if fs.FileExists("/base/big.txt.deltagram-stream") {
+				t.Errorf("Expected temporary file to be removed")
+			}
+			if info, _ := fs.Stat("/base/big.txt"); info.Mode().Perm() != 0600 {
+				t.Errorf("Expected permissions 0600, got %v", info.Mode().Perm())
+			}
+		})
+	}
+}
+
+func TestContentHandler_shouldStream(t *testing.T) {
+	ordered := []*ParsedHunk{
+		{Header: &HunkHeader{OldStart: 1, OldCount: 2}},
+		{Header: &HunkHeader{OldStart: 3, OldCount: 1}},
+	}
+	unordered := []*ParsedHunk{ordered[1], ordered[0]}
+
+	tests := []struct {
+		name     string
+		handler  *ContentHandler
+		size     int64
+		hunks    []*ParsedHunk
+		expected bool
+	}{
+		{"small file", &ContentHandler{}, 1024, ordered, false},
+		{"default threshold", &ContentHandler{}, DefaultStreamThreshold + 1, ordered, true},
+		{"custom threshold", &ContentHandler{streamThreshold: 10}, 11, ordered, true},
+		{"disabled", &ContentHandler{streamThreshold: -1}, DefaultStreamThreshold + 1, ordered, false},
+		{"unordered hunks", &ContentHandler{streamThreshold: 10}, 11, unordered, false},
+		{"conflict resolver", &ContentHandler{streamThreshold: 10, resolver: &scriptedResolver{}}, 11, ordered, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.handler.shouldStream(tt.size, tt.hunks); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package operations
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+)
+
+// ErrSyntax is returned, wrapped, when a changed file no longer parses
+var ErrSyntax = errors.New("syntax error in changed file")
+
+// syntaxCheckers parse a file by extension and return the first error found. Only
+// languages with a parser in the standard library are checked.
+var syntaxCheckers = map[string]func(path string, content []byte) error{
+	".go":   checkGoSyntax,
+	".json": checkJSONSyntax,
+}
+
+// CheckSyntax parses each changed file that still exists and has a checker for its
+// extension, returning a warning for every file that no longer parses. A file that did
+// not parse before the apply is not reported, since the deltagram did not break it.
+func CheckSyntax(changes []FileChange, baseDir string) []Warning {
+	var warnings []Warning
+	for _, change := range changes {
+		check := syntaxCheckers[strings.ToLower(filepath.Ext(change.Path))]
+		if check == nil || !change.Exists {
+			continue
+		}
+
+		name := change.Path
+		if rel, err := filepath.Rel(baseDir, change.Path); err == nil {
+			name = filepath.ToSlash(rel)
+		}
+		err := check(name, change.After)
+		if err == nil || (change.Existed && check(name, change.Before) != nil) {
+			continue
+		}
+		warnings = append(warnings, Warning{Path: name, Kind: WarnSyntax, Message: fmt.Sprintf("%s no longer parses: %v", name, err)})
+	}
+	return warnings
+}
+
+func checkGoSyntax(path string, content []byte) error {
+	_, err := parser.ParseFile(token.NewFileSet(), path, content, parser.AllErrors)
+	return err
+}
+
+func checkJSONSyntax(path string, content []byte) error {
+	var value interface{}
+	return json.Unmarshal(content, &value)
+}
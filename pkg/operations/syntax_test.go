@@ -0,0 +1,58 @@
+package operations
+
+import (
+	"testing"
+)
+
+func TestCheckSyntax(t *testing.T) {
+	tests := []struct {
+		name   string
+		change FileChange
+		broken bool
+	}{
+		{"valid go", FileChange{Path: "/base/main.go", After: []byte("package main\n"), Exists: true}, false},
+		{"broken go", FileChange{Path: "/base/main.go", After: []byte("package main\nfunc {\n"), Exists: true}, true},
+		{"valid json", FileChange{Path: "/base/data.json", After: []byte(`{"a": 1}`), Exists: true}, false},
+		{"broken json", FileChange{Path: "/base/data.json", After: []byte(`{"a": 1,}`), Exists: true}, true},
+		{"already broken", FileChange{Path: "/base/data.json", Before: []byte("{"), After: []byte("{{"), Existed: true, Exists: true}, false},
+		{"deleted", FileChange{Path: "/base/data.json", Before: []byte("{}"), Existed: true}, false},
+		{"unchecked extension", FileChange{Path: "/base/notes.txt", After: []byte("{"), Exists: true}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings := CheckSyntax([]FileChange{tt.change}, "/base")
+			if broken := len(warnings) > 0; broken != tt.broken {
+				t.Fatalf("Expected broken=%v, got warnings: %+v", tt.broken, warnings)
+			}
+			if tt.broken && (warnings[0].Kind != WarnSyntax || warnings[0].Path == tt.change.Path) {
+				t.Errorf("Expected a syntax warning with a relative path, got %+v", warnings[0])
+			}
+		})
+	}
+}
+
+func TestRestoreChanges(t *testing.T) {
+	fs := NewMemoryFileSystem()
+	fs.MkdirAll("/base", 0755)
+	fs.WriteFile("/base/kept.txt", []byte("original"), 0644)
+	fs.WriteFile("/base/gone.txt", []byte("deleted"), 0644)
+
+	recorder := NewRecordingFileSystem(fs)
+	recorder.WriteFile("/base/kept.txt", []byte("changed"), 0644)
+	recorder.Remove("/base/gone.txt")
+	recorder.WriteFile("/base/new.txt", []byte("created"), 0644)
+
+	if err := RestoreChanges(fs, recorder.Changes()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if data, _ := fs.ReadFile("/base/kept.txt"); string(data) != "original" {
+		t.Errorf("Expected kept.txt to be restored, got %q", data)
+	}
+	if data, _ := fs.ReadFile("/base/gone.txt"); string(data) != "deleted" {
+		t.Errorf("Expected gone.txt to be restored, got %q", data)
+	}
+	if _, err := fs.ReadFile("/base/new.txt"); err == nil {
+		t.Error("Expected new.txt to be removed")
+	}
+}
@@ -0,0 +1,308 @@
+package operations
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// TableKeyHeader names the column, by its name in the header row or its 1-based number,
+// that identifies the rows of a table operation; the first column is used without it
+const TableKeyHeader = "Table-Key"
+
+// TableChange is one row of a table operation
+type TableChange struct {
+	Remove bool     // Whether the row is removed (-) rather than added (+)
+	Fields []string // Fields of the row; a removed row may give only its key
+	Line   int      // 1-based line of the part body
+}
+
+// TableHandler handles table operations on CSV and TSV files, which change rows by the
+// value of a key column rather than by line number, so that they still apply after the
+// rows of the file have been sorted or reordered. The body lists rows in the file's own
+// format, each prefixed like a diff line:
+//
+//	-2,bob,bob@example.com
+//	+2,bob,bob@example.org
+//	+4,dave,dave@example.com
+//	-3
+//
+// A - row deletes the row with its key; any other fields it gives must match the file.
+// A + row with the key of a deleted row takes that row's place, and any other + row is
+// added at the end of the file. The first row of the file is its header. Rows that are
+// not changed keep their bytes, quoting, and line endings.
+type TableHandler struct {
+	preserveModTime bool
+	report          reportFunc
+}
+
+// NewTableHandler creates a new table handler
+func NewTableHandler() OperationHandler {
+	return &TableHandler{}
+}
+
+// CanHandle returns true if this handler can process the given operation
+func (h *TableHandler) CanHandle(operation string) bool {
+	return operation == "table"
+}
+
+// Apply makes the row changes of a table part to its file
+func (h *TableHandler) Apply(fs FileSystem, baseDir string, part parser.DeltagramPart) error {
+	filePath := ResolveFilePath(baseDir, part.ContentLocation)
+	info, err := fs.Stat(filePath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("cannot apply table operation to %w: %s", ErrFileNotFound, part.ContentLocation)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat existing file: %w", err)
+	}
+
+	data, err := fs.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read existing file: %w", err)
+	}
+	modified, err := applyTablePart(string(data), part)
+	if err != nil {
+		return withFile(err, part.ContentLocation)
+	}
+
+	if err := fs.WriteFile(filePath, []byte(modified), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write modified file: %w", err)
+	}
+	if h.preserveModTime {
+		if err := fs.Chtimes(filePath, info.ModTime(), info.ModTime()); err != nil {
+			return fmt.Errorf("failed to restore modification time: %w", err)
+		}
+	}
+
+	h.report.printf("Modified: %s\n", part.ContentLocation)
+	return nil
+}
+
+// TableDelimiter returns the field separator of a table part: a tab for
+// text/tab-separated-values or a .tsv or .tab file, and a comma otherwise
+func TableDelimiter(part parser.DeltagramPart) rune {
+	if strings.Contains(strings.ToLower(part.ContentType), "tab-separated-values") {
+		return '\t'
+	}
+	switch strings.ToLower(filepath.Ext(part.ContentLocation)) {
+	case ".tsv", ".tab":
+		return '\t'
+	}
+	return ','
+}
+
+// ParseTableChanges parses the body of a table part
+func ParseTableChanges(content string, delimiter rune) ([]TableChange, error) {
+	var changes []TableChange
+	for number, line := range strings.Split(content, "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if line[0] != '-' && line[0] != '+' {
+			return nil, fmt.Errorf("line %d: expected a row starting with - or +, got %q", number+1, line)
+		}
+		fields, err := parseRecord(line[1:], delimiter)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", number+1, err)
+		}
+		changes = append(changes, TableChange{Remove: line[0] == '-', Fields: fields, Line: number + 1})
+	}
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("table operation has no rows")
+	}
+	return changes, nil
+}
+
+// parseRecord parses one line of delimited fields
+func parseRecord(line string, delimiter rune) ([]string, error) {
+	reader := csv.NewReader(strings.NewReader(line))
+	reader.Comma = delimiter
+	reader.LazyQuotes = true
+	fields, err := reader.Read()
+	if err == io.EOF {
+		return []string{""}, nil
+	}
+	return fields, err
+}
+
+// tableRow is a record of a table file with the exact text it was read from
+type tableRow struct {
+	raw    string
+	fields []string
+	line   int
+}
+
+// applyTablePart returns the text of a table file with the rows of the part changed
+func applyTablePart(text string, part parser.DeltagramPart) (string, error) {
+	delimiter := TableDelimiter(part)
+	changes, err := ParseTableChanges(part.Content, delimiter)
+	if err != nil {
+		return "", err
+	}
+	bom, rest := cutByteOrderMark(text)
+	rows, tail, err := readTable(rest, delimiter)
+	if err != nil {
+		return "", err
+	}
+	if len(rows) == 0 {
+		return "", fmt.Errorf("table has no header row")
+	}
+	key, err := tableKey(part, rows[0].fields)
+	if err != nil {
+		return "", err
+	}
+
+	index := make(map[string][]int)
+	for i, row := range rows[1:] {
+		if key < len(row.fields) {
+			index[row.fields[key]] = append(index[row.fields[key]], i+1)
+		}
+	}
+
+	// Removed rows are found first, so that a + row replaces the - row with its key
+	// wherever the two appear in the body
+	removed := make(map[string]int)
+	for _, change := range changes {
+		if !change.Remove {
+			continue
+		}
+		value := change.Fields[0]
+		if len(change.Fields) > 1 {
+			if key >= len(change.Fields) {
+				return "", fmt.Errorf("body line %d has no key column %d", change.Line, key+1)
+			}
+			value = change.Fields[key]
+		}
+		matches := index[value]
+		if len(matches) != 1 {
+			return "", fmt.Errorf("body line %d removes the row with key %q, but the table has %d such row(s)", change.Line, value, len(matches))
+		}
+		if _, ok := removed[value]; ok {
+			return "", fmt.Errorf("body line %d removes the row with key %q twice", change.Line, value)
+		}
+		row := rows[matches[0]]
+		if len(change.Fields) > 1 && strings.Join(change.Fields, "\x00") != strings.Join(row.fields, "\x00") {
+			return "", &ErrContextMismatch{Line: row.line, Expected: formatRecord(change.Fields, delimiter, ""), Got: strings.TrimRight(row.raw, "\r\n"), Removal: true}
+		}
+		removed[value] = matches[0]
+	}
+
+	newline := "\n"
+	if strings.HasSuffix(rows[0].raw, "\r\n") {
+		newline = "\r\n"
+	}
+	replaced := make(map[int]string)
+	added := make(map[string]bool)
+	var appended []string
+	for _, change := range changes {
+		if change.Remove {
+			continue
+		}
+		if len(change.Fields) != len(rows[0].fields) {
+			return "", fmt.Errorf("body line %d has %d field(s), but the table has %d column(s)", change.Line, len(change.Fields), len(rows[0].fields))
+		}
+		value := change.Fields[key]
+		if added[value] {
+			return "", fmt.Errorf("body line %d adds the row with key %q twice", change.Line, value)
+		}
+		added[value] = true
+		record := formatRecord(change.Fields, delimiter, newline)
+		if row, ok := removed[value]; ok {
+			replaced[row] = record
+			continue
+		}
+		if len(index[value]) > 0 {
+			return "", fmt.Errorf("body line %d adds a row with key %q, which the table already has", change.Line, value)
+		}
+		appended = append(appended, record)
+	}
+
+	deleted := make(map[int]bool)
+	for _, row := range removed {
+		deleted[row] = true
+	}
+	var b strings.Builder
+	b.WriteString(bom)
+	for i, row := range rows {
+		switch record, ok := replaced[i]; {
+		case ok && !strings.HasSuffix(row.raw, "\n"):
+			b.WriteString(strings.TrimSuffix(record, newline)) // The last row had no line ending
+		case ok:
+			b.WriteString(record)
+		case !deleted[i]:
+			b.WriteString(row.raw)
+		}
+	}
+	if len(appended) > 0 && b.Len() > 0 && !strings.HasSuffix(b.String(), "\n") {
+		b.WriteString(newline)
+	}
+	for i, record := range appended {
+		if i == len(appended)-1 && tail == "" && !strings.HasSuffix(rest, "\n") {
+			record = strings.TrimSuffix(record, newline) // Keep the file without a final newline
+		}
+		b.WriteString(record)
+	}
+	b.WriteString(tail)
+	return b.String(), nil
+}
+
+// readTable reads the records of a table along with the text after the last one
+func readTable(text string, delimiter rune) ([]tableRow, string, error) {
+	reader := csv.NewReader(strings.NewReader(text))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	var rows []tableRow
+	offset := 0
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read table: %v", err)
+		}
+		line, _ := reader.FieldPos(0)
+		end := int(reader.InputOffset())
+		rows = append(rows, tableRow{raw: text[offset:end], fields: fields, line: line})
+		offset = end
+	}
+	return rows, text[offset:], nil
+}
+
+// tableKey returns the 0-based index of the key column named by the part
+func tableKey(part parser.DeltagramPart, header []string) (int, error) {
+	name, ok := part.Header(TableKeyHeader)
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return 0, nil
+	}
+	for i, column := range header {
+		if strings.TrimSpace(column) == name {
+			return i, nil
+		}
+	}
+	if number, err := strconv.Atoi(name); err == nil && number >= 1 && number <= len(header) {
+		return number - 1, nil
+	}
+	return 0, fmt.Errorf("%s %q is not a column of the table", TableKeyHeader, name)
+}
+
+// formatRecord writes fields as one delimited record ending with newline
+func formatRecord(fields []string, delimiter rune, newline string) string {
+	var b strings.Builder
+	writer := csv.NewWriter(&b)
+	writer.Comma = delimiter
+	writer.Write(fields)
+	writer.Flush()
+	return strings.TrimSuffix(b.String(), "\n") + newline
+}
@@ -0,0 +1,123 @@
+package operations
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/developingjames/deltagrams/internal/testutil"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func TestTableHandler_Apply(t *testing.T) {
+	users := "id,name,email\n1,alice,alice@example.com\n2,bob,bob@example.com\n3,carol,carol@example.com\n"
+
+	tests := []struct {
+		name        string
+		location    string
+		contentType string
+		headers     map[string]string
+		original    string
+		body        string
+		expected    string
+	}{
+		{"update in place", "users.csv", "", nil, users,
+			"-2,bob,bob@example.com\n+2,bob,bob@example.org",
+			"id,name,email\n1,alice,alice@example.com\n2,bob,bob@example.org\n3,carol,carol@example.com\n"},
+		{"survives reordering", "users.csv", "", nil, "id,name,email\n3,carol,carol@example.com\n2,bob,bob@example.com\n1,alice,alice@example.com\n",
+			"-2,bob,bob@example.com\n+2,bob,bob@example.org",
+			"id,name,email\n3,carol,carol@example.com\n2,bob,bob@example.org\n1,alice,alice@example.com\n"},
+		{"insert and delete by key alone", "users.csv", "", nil, users,
+			"-1\n+4,dave,dave@example.com",
+			"id,name,email\n2,bob,bob@example.com\n3,carol,carol@example.com\n4,dave,dave@example.com\n"},
+		{"added row before removed row", "users.csv", "", nil, users,
+			"+3,carol,carol@example.org\n-3",
+			"id,name,email\n1,alice,alice@example.com\n2,bob,bob@example.com\n3,carol,carol@example.org\n"},
+		{"key column by name", "users.csv", "", map[string]string{"Table-Key": "email"}, users,
+			"-bob@example.com\n+2,robert,bob@example.com",
+			"id,name,email\n1,alice,alice@example.com\n2,robert,bob@example.com\n3,carol,carol@example.com\n"},
+		{"tsv by extension", "users.tsv", "", nil, "id\tname\n1\talice\n",
+			"-1\n+1\talice smith",
+			"id\tname\n1\talice smith\n"},
+		{"tsv by content type", "users.txt", "text/tab-separated-values", nil, "id\tname\n1\talice\n",
+			"+2\tbob",
+			"id\tname\n1\talice\n2\tbob\n"},
+		{"keeps quoting of other rows and CRLF", "quotes.csv", "", nil, "id,note\r\n1,\"a, b\"\r\n2,\"plain\"\r\n",
+			"-1\n+1,\"c, d\"",
+			"id,note\r\n1,\"c, d\"\r\n2,\"plain\"\r\n"},
+		{"no final newline", "users.csv", "", nil, "id,name\n1,alice",
+			"+2,bob",
+			"id,name\n1,alice\n2,bob"},
+		{"keeps byte order mark", "users.csv", "", nil, "\ufeffid,name\n1,alice\n",
+			"-1\n+1,alicia",
+			"\ufeffid,name\n1,alicia\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := testutil.NewMockFileSystem()
+			fs.AddFile("/base/"+tt.location, []byte(tt.original))
+
+			part := parser.DeltagramPart{ContentLocation: tt.location, ContentType: tt.contentType, DeltaOperation: "table", Content: tt.body, Headers: tt.headers}
+			if err := (&TableHandler{report: func(string, ...interface{}) {}}).Apply(fs, "/base", part); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if data, _ := fs.ReadFile("/base/" + tt.location); string(data) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, data)
+			}
+		})
+	}
+}
+
+func TestTableHandler_Apply_Errors(t *testing.T) {
+	users := "id,name\n1,alice\n2,bob\n2,bobby\n"
+
+	tests := []struct {
+		name     string
+		body     string
+		headers  map[string]string
+		expected string
+	}{
+		{"missing key", "-9\n+9,x", nil, "the table has 0 such row(s)"},
+		{"duplicate key", "-2", nil, "the table has 2 such row(s)"},
+		{"removed row differs", "-1,alicia", nil, "removal mismatch"},
+		{"added key exists", "+1,alice", nil, "which the table already has"},
+		{"wrong field count", "+5,eve,extra", nil, "has 3 field(s), but the table has 2 column(s)"},
+		{"added twice", "+5,eve\n+5,eva", nil, "adds the row with key \"5\" twice"},
+		{"unknown key column", "-1", map[string]string{"Table-Key": "email"}, "is not a column"},
+		{"not a row", " 1,alice", nil, "expected a row starting with - or +"},
+		{"empty body", "\n", nil, "has no rows"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := testutil.NewMockFileSystem()
+			fs.AddFile("/base/users.csv", []byte(users))
+
+			part := parser.DeltagramPart{ContentLocation: "users.csv", DeltaOperation: "table", Content: tt.body, Headers: tt.headers}
+			err := NewTableHandler().Apply(fs, "/base", part)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Fatalf("Expected error containing %q, got: %v", tt.expected, err)
+			}
+			if data, _ := fs.ReadFile("/base/users.csv"); string(data) != users {
+				t.Errorf("Expected the file to be unchanged, got %q", data)
+			}
+		})
+	}
+}
+
+func TestTableHandler_Apply_MismatchNamesRow(t *testing.T) {
+	fs := testutil.NewMockFileSystem()
+	fs.AddFile("/base/users.csv", []byte("id,name\n1,alice\n2,bob\n"))
+
+	part := parser.DeltagramPart{ContentLocation: "users.csv", DeltaOperation: "table", Content: "-2,robert"}
+	err := NewTableHandler().Apply(fs, "/base", part)
+
+	var mismatch *ErrContextMismatch
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Expected a context mismatch, got: %v", err)
+	}
+	if mismatch.File != "users.csv" || mismatch.Line != 3 || mismatch.Got != "2,bob" {
+		t.Errorf("Expected the mismatch at users.csv line 3, got %+v", mismatch)
+	}
+}
@@ -0,0 +1,76 @@
+package operations
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// TouchHandler handles touch operations, which create an empty file or, when the file
+// exists, set its modification time to now without changing its content. Unlike an empty
+// create part, a touch never truncates an existing file.
+type TouchHandler struct {
+	report reportFunc
+}
+
+// NewTouchHandler creates a new touch handler
+func NewTouchHandler() OperationHandler {
+	return &TouchHandler{}
+}
+
+// CanHandle returns true if this handler can process the given operation
+func (h *TouchHandler) CanHandle(operation string) bool {
+	return operation == "touch"
+}
+
+// Apply creates the file of a touch part or updates its modification time
+func (h *TouchHandler) Apply(fs FileSystem, baseDir string, part parser.DeltagramPart) error {
+	if err := checkTouchBody(part); err != nil {
+		return err
+	}
+	filePath := ResolveFilePath(baseDir, part.ContentLocation)
+
+	_, err := fs.Stat(filePath)
+	if err == nil {
+		now := time.Now()
+		if err := fs.Chtimes(filePath, now, now); err != nil {
+			return fmt.Errorf("failed to update modification time: %w", err)
+		}
+		h.report.printf("Touched: %s\n", part.ContentLocation)
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to stat existing file: %w", err)
+	}
+
+	if err := fs.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := fs.WriteFile(filePath, nil, 0644); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	h.report.printf("Created: %s\n", part.ContentLocation)
+	return nil
+}
+
+// checkTouchBody refuses a touch whose body holds anything other than a +++ marker naming
+// its file, since content there would be silently dropped
+func checkTouchBody(part parser.DeltagramPart) error {
+	for _, line := range strings.Split(part.Content, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "+++"):
+			if path := headerPath(strings.TrimPrefix(line, "+++")); !sameLocation(path, part.ContentLocation) {
+				return fmt.Errorf("invalid touch operation: body names %s but Content-Location is %s", path, part.ContentLocation)
+			}
+		default:
+			return fmt.Errorf("invalid touch operation for %s: the body must be empty; use create to write content", part.ContentLocation)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package operations
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/developingjames/deltagrams/internal/testutil"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func TestTouchHandler_Apply_CreatesEmptyFile(t *testing.T) {
+	fs := testutil.NewMockFileSystem()
+
+	part := parser.DeltagramPart{ContentLocation: "pkg/.keep", DeltaOperation: "touch"}
+	if err := (&TouchHandler{report: func(string, ...interface{}) {}}).Apply(fs, "/base", part); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	data, err := fs.ReadFile("/base/pkg/.keep")
+	if err != nil {
+		t.Fatalf("Expected the file to be created, got: %v", err)
+	}
+	if len(data) != 0 {
+		t.Errorf("Expected an empty file, got %q", data)
+	}
+}
+
+func TestTouchHandler_Apply_KeepsContent(t *testing.T) {
+	fs := testutil.NewMockFileSystem()
+	fs.AddFile("/base/notes.txt", []byte("keep me\n"))
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	fs.Chtimes("/base/notes.txt", old, old)
+
+	var reported string
+	handler := &TouchHandler{report: func(format string, args ...interface{}) { reported = format }}
+	part := parser.DeltagramPart{ContentLocation: "notes.txt", DeltaOperation: "touch", Content: "+++ notes.txt"}
+	if err := handler.Apply(fs, "/base", part); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if data, _ := fs.ReadFile("/base/notes.txt"); string(data) != "keep me\n" {
+		t.Errorf("Expected the content to be kept, got %q", data)
+	}
+	if info, _ := fs.Stat("/base/notes.txt"); !info.ModTime().After(old) {
+		t.Errorf("Expected the modification time to be updated, got %v", info.ModTime())
+	}
+	if reported != "Touched: %s\n" {
+		t.Errorf("Expected the file to be reported touched, got %q", reported)
+	}
+}
+
+func TestTouchHandler_Apply_RejectsContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		errMsg  string
+	}{
+		{"content", "hello", "the body must be empty"},
+		{"marker and content", "+++ notes.txt\nhello", "the body must be empty"},
+		{"other file", "+++ other.txt", "body names other.txt but Content-Location is notes.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := testutil.NewMockFileSystem()
+			part := parser.DeltagramPart{ContentLocation: "notes.txt", DeltaOperation: "touch", Content: tt.content}
+			err := NewTouchHandler().Apply(fs, "/base", part)
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Fatalf("Expected error containing %q, got: %v", tt.errMsg, err)
+			}
+			if fs.FileExists("/base/notes.txt") {
+				t.Error("Expected no file to be created")
+			}
+		})
+	}
+}
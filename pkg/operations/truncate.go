@@ -0,0 +1,122 @@
+package operations
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// TruncateLinesHeader gives the number of lines a truncate operation keeps from the start
+// of the file; without it the file is emptied
+const TruncateLinesHeader = "Truncate-Lines"
+
+// TruncateHandler handles truncate operations, which empty a file or cut it down to its
+// first lines without deleting it, so that its mode and any hard links stay as they are.
+// This suits generated files and logs that a patch clears. A file that is already short
+// enough is left alone.
+type TruncateHandler struct {
+	preserveModTime bool
+	report          reportFunc
+}
+
+// NewTruncateHandler creates a new truncate handler
+func NewTruncateHandler() OperationHandler {
+	return &TruncateHandler{}
+}
+
+// CanHandle returns true if this handler can process the given operation
+func (h *TruncateHandler) CanHandle(operation string) bool {
+	return operation == "truncate"
+}
+
+// Apply truncates the file of a truncate part
+func (h *TruncateHandler) Apply(fs FileSystem, baseDir string, part parser.DeltagramPart) error {
+	if err := checkTruncateBody(part); err != nil {
+		return err
+	}
+	keep, err := TruncateLines(part)
+	if err != nil {
+		return err
+	}
+
+	filePath := ResolveFilePath(baseDir, part.ContentLocation)
+	info, err := fs.Stat(filePath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("cannot apply truncate operation to %w: %s", ErrFileNotFound, part.ContentLocation)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat existing file: %w", err)
+	}
+	data, err := fs.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read existing file: %w", err)
+	}
+
+	truncated, _ := truncateText(string(data), keep)
+	if truncated == string(data) {
+		h.report.printf("Unchanged: %s\n", part.ContentLocation)
+		return nil
+	}
+	// The kept lines are a prefix of the file, so it is cut in place rather than rewritten,
+	// and a process appending to it keeps writing to the same file
+	if err := fs.Truncate(filePath, int64(len(truncated))); err != nil {
+		return fmt.Errorf("failed to truncate file: %w", err)
+	}
+	if h.preserveModTime {
+		if err := fs.Chtimes(filePath, info.ModTime(), info.ModTime()); err != nil {
+			return fmt.Errorf("failed to restore modification time: %w", err)
+		}
+	}
+
+	h.report.printf("Truncated: %s\n", part.ContentLocation)
+	return nil
+}
+
+// TruncateLines returns how many lines a truncate part keeps
+func TruncateLines(part parser.DeltagramPart) (int, error) {
+	value, ok := part.Header(TruncateLinesHeader)
+	if !ok || strings.TrimSpace(value) == "" {
+		return 0, nil
+	}
+	lines, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || lines < 0 {
+		return 0, fmt.Errorf("invalid %s header %q: expected a number of lines", TruncateLinesHeader, value)
+	}
+	return lines, nil
+}
+
+// truncateText returns the first keep lines of text, each with its line ending, along
+// with how many lines were cut. A file cut to no lines is empty, without a byte order
+// mark.
+func truncateText(text string, keep int) (string, int) {
+	bom, rest := cutByteOrderMark(text)
+	lines, _ := fileLines(rest)
+	if keep >= len(lines) {
+		return text, 0
+	}
+	if keep == 0 {
+		return "", len(lines)
+	}
+	return bom + joinFileLines(lines[:keep], true), len(lines) - keep
+}
+
+// checkTruncateBody refuses a truncate whose body holds anything other than --- or +++
+// markers naming its file
+func checkTruncateBody(part parser.DeltagramPart) error {
+	for _, line := range strings.Split(part.Content, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "---") || strings.HasPrefix(line, "+++"):
+			if path := headerPath(line[3:]); !sameLocation(path, part.ContentLocation) {
+				return fmt.Errorf("invalid truncate operation: body names %s but Content-Location is %s", path, part.ContentLocation)
+			}
+		default:
+			return fmt.Errorf("invalid truncate operation for %s: the body may only contain markers naming the file", part.ContentLocation)
+		}
+	}
+	return nil
+}
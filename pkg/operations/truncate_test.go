@@ -0,0 +1,125 @@
+package operations
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/developingjames/deltagrams/internal/testutil"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func TestTruncateHandler_Apply(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		lines    string
+		expected string
+		report   string
+	}{
+		{"empty", "one\ntwo\n", "", "", "Truncated: %s\n"},
+		{"keep lines", "one\ntwo\nthree\n", "2", "one\ntwo\n", "Truncated: %s\n"},
+		{"keep lines without final newline", "one\ntwo\nthree", "1", "one\n", "Truncated: %s\n"},
+		{"keeps CRLF and byte order mark", "\ufeffone\r\ntwo\r\n", "1", "\ufeffone\r\n", "Truncated: %s\n"},
+		{"already short", "one\ntwo\n", "5", "one\ntwo\n", "Unchanged: %s\n"},
+		{"already empty", "", "", "", "Unchanged: %s\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := testutil.NewMockFileSystem()
+			fs.AddFile("/base/build.log", []byte(tt.original))
+
+			var reported string
+			handler := &TruncateHandler{report: func(format string, args ...interface{}) { reported = format }}
+			part := parser.DeltagramPart{ContentLocation: "build.log", DeltaOperation: "truncate", Content: "--- build.log"}
+			if tt.lines != "" {
+				part.Headers = map[string]string{TruncateLinesHeader: tt.lines}
+			}
+			if err := handler.Apply(fs, "/base", part); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if data, _ := fs.ReadFile("/base/build.log"); string(data) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, data)
+			}
+			if reported != tt.report {
+				t.Errorf("Expected report %q, got %q", tt.report, reported)
+			}
+		})
+	}
+}
+
+func TestTruncateHandler_Apply_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		headers map[string]string
+		errMsg  string
+	}{
+		{"content", "keep me", nil, "may only contain markers naming the file"},
+		{"other file", "+++ other.log", nil, "body names other.log but Content-Location is build.log"},
+		{"bad line count", "", map[string]string{TruncateLinesHeader: "-1"}, "invalid Truncate-Lines header"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := testutil.NewMockFileSystem()
+			fs.AddFile("/base/build.log", []byte("one\n"))
+
+			part := parser.DeltagramPart{ContentLocation: "build.log", DeltaOperation: "truncate", Content: tt.content, Headers: tt.headers}
+			err := NewTruncateHandler().Apply(fs, "/base", part)
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Fatalf("Expected error containing %q, got: %v", tt.errMsg, err)
+			}
+			if data, _ := fs.ReadFile("/base/build.log"); string(data) != "one\n" {
+				t.Errorf("Expected the file to be unchanged, got %q", data)
+			}
+		})
+	}
+}
+
+func TestTruncateHandler_Apply_MissingFile(t *testing.T) {
+	fs := testutil.NewMockFileSystem()
+	part := parser.DeltagramPart{ContentLocation: "build.log", DeltaOperation: "truncate"}
+	if err := NewTruncateHandler().Apply(fs, "/base", part); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("Expected ErrFileNotFound, got: %v", err)
+	}
+}
+
+func TestTruncateHandler_Apply_KeepsHardLinks(t *testing.T) {
+	baseDir := t.TempDir()
+	logPath := filepath.Join(baseDir, "app.log")
+	if err := os.WriteFile(logPath, []byte("one\ntwo\nthree\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	linkPath := filepath.Join(baseDir, "app.log.link")
+	if err := os.Link(logPath, linkPath); err != nil {
+		t.Skipf("hard links not supported: %v", err)
+	}
+
+	handler := &TruncateHandler{}
+	part := parser.DeltagramPart{ContentLocation: "app.log", DeltaOperation: "truncate", Content: "--- app.log",
+		Headers: map[string]string{TruncateLinesHeader: "1"}}
+	if err := handler.Apply(NewRealFileSystem(), baseDir, part); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	// The file is cut in place, so the hard link sees the same content
+	data, err := os.ReadFile(linkPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "one\n" {
+		t.Errorf("Expected the hard link to hold %q, got %q", "one\n", data)
+	}
+	info, err := os.Stat(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600 to be kept, got %v", info.Mode().Perm())
+	}
+}
@@ -1,8 +1,10 @@
 package operations
 
 import (
+	"context"
 	"io"
 	"os"
+	"time"
 
 	"github.com/developingjames/deltagrams/pkg/parser"
 )
@@ -15,13 +17,76 @@ type FileSystem interface {
 	Rename(oldpath, newpath string) error
 	MkdirAll(path string, perm os.FileMode) error
 	Stat(name string) (os.FileInfo, error)
+	Lstat(name string) (os.FileInfo, error)
+	ReadDir(name string) ([]os.DirEntry, error)
 	Open(name string) (io.ReadCloser, error)
 	Create(name string) (io.WriteCloser, error)
+	Chtimes(name string, atime, mtime time.Time) error
+	Chmod(name string, mode os.FileMode) error
+	// Truncate changes the size of a file in place, keeping its mode and hard links
+	Truncate(name string, size int64) error
+	Symlink(oldname, newname string) error
+	EvalSymlinks(path string) (string, error)
+}
+
+// Options configures optional behavior of the default Applier
+type Options struct {
+	// AllowIgnored permits writes to paths matched by .gitignore or inside ProtectedDirs
+	AllowIgnored bool
+	// AllowPaths lists glob patterns that every written path must match or be beneath;
+	// when empty, all paths not otherwise refused are allowed
+	AllowPaths []string
+	// DenyPaths lists glob patterns that written paths may never match or be beneath
+	DenyPaths []string
+	// MaxParts limits the number of file operations in a deltagram (0 for no limit)
+	MaxParts int
+	// MaxFileSize limits the size in bytes of any single file written (0 for no limit)
+	MaxFileSize int64
+	// MaxTotalBytes limits the total bytes written by a deltagram (0 for no limit)
+	MaxTotalBytes int64
+	// PreserveModTime restores a file's modification time after a content operation
+	PreserveModTime bool
+	// AllowSymlinkEscape warns instead of refusing when a path resolves outside the base
+	// directory through a symlink
+	AllowSymlinkEscape bool
+	// ConflictResolver decides what to do with content hunks that do not match the file;
+	// when nil, a mismatched hunk fails the apply
+	ConflictResolver ConflictResolver
+	// StreamThreshold is the file size in bytes above which content operations stream the
+	// file line by line instead of loading it into memory; 0 uses DefaultStreamThreshold
+	// and a negative value never streams
+	StreamThreshold int64
+	// Progress, when set, is called once before the first file operation and again after
+	// each one completes
+	Progress func(Progress)
+	// Warn, when set, receives warnings such as fuzzy hunk offsets instead of them being
+	// printed to stdout
+	Warn func(Warning)
+	// Output, when set, receives the lines reporting each file operation and message part
+	// instead of them being printed to stdout
+	Output io.Writer
+	// Sanitize cleans or refuses unusual characters in every path before anything is
+	// applied
+	Sanitize SanitizePolicy
+	// FixWhitespace strips trailing whitespace from the lines that create, content, copy,
+	// and move parts add and ends each file they write with exactly one newline
+	FixWhitespace bool
+}
+
+// Progress reports how far an apply has got
+type Progress struct {
+	Done       int    // File operations completed
+	Total      int    // File operations in the deltagram
+	Bytes      int64  // Estimated bytes written so far
+	TotalBytes int64  // Estimated bytes the whole deltagram writes
+	Path       string // Target of the operation just completed
 }
 
 // Applier defines the interface for applying deltagram operations
 type Applier interface {
 	Apply(deltagram *parser.Deltagram, baseDir string) error
+	// ApplyContext is like Apply but stops before the next part once ctx is done
+	ApplyContext(ctx context.Context, deltagram *parser.Deltagram, baseDir string) error
 }
 
 // OperationHandler handles specific types of operations
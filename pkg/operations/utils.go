@@ -1,8 +1,12 @@
 package operations
 
 import (
+	"fmt"
+	"path"
 	"path/filepath"
 	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
 )
 
 // ResolveFilePath converts various path formats to a resolved file path
@@ -19,3 +23,57 @@ func ResolveFilePath(baseDir, filePath string) string {
 
 	return filepath.Join(baseDir, filePath)
 }
+
+// parsePathMarkers extracts the source (---) and destination (+++) paths that precede
+// any hunks in a copy or move body
+func parsePathMarkers(content string) (sourcePath, destPath string) {
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "@@") {
+			break // Hunks to apply after the path markers follow
+		}
+		if strings.HasPrefix(line, "---") {
+			sourcePath = strings.TrimSpace(strings.TrimPrefix(line, "---"))
+		} else if strings.HasPrefix(line, "+++") {
+			destPath = strings.TrimSpace(strings.TrimPrefix(line, "+++"))
+		}
+	}
+	return sourcePath, destPath
+}
+
+// transferPaths returns the source and destination of a copy or move part. The body's
+// --- marker is the source and Content-Location is the destination; a +++ marker may
+// repeat the destination but must not contradict it. Deltagrams written to an older
+// description of the format give the source as Content-Location and the destination only
+// in +++; that form is still accepted and reported as legacy.
+func transferPaths(part parser.DeltagramPart) (source, dest string, legacy bool, err error) {
+	source, marked := parsePathMarkers(part.Content)
+	location := part.ContentLocation
+	if source == "" {
+		return "", "", false, fmt.Errorf("invalid %s operation for %s: missing --- source path", part.DeltaOperation, location)
+	}
+
+	dest = location
+	if marked != "" && !sameLocation(marked, location) {
+		if !sameLocation(location, source) {
+			return "", "", false, fmt.Errorf("invalid %s operation: Content-Location %s is the destination but +++ names %s; "+
+				"remove the +++ line or make it match", part.DeltaOperation, location, marked)
+		}
+		dest, legacy = marked, true
+	}
+
+	if sameLocation(source, dest) {
+		return "", "", false, fmt.Errorf("invalid %s operation: source and destination are both %s", part.DeltaOperation, dest)
+	}
+	return source, dest, legacy, nil
+}
+
+// sameLocation reports whether a path marker refers to the given location, allowing for
+// "./" and the "a/" and "b/" prefixes of git-style diffs
+func sameLocation(marker, location string) bool {
+	clean := func(p string) string {
+		return path.Clean(filepath.ToSlash(p))
+	}
+	marker, location = clean(marker), clean(location)
+	return marker == location || marker == "a/"+location || marker == "b/"+location
+}
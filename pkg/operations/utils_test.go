@@ -0,0 +1,67 @@
+package operations
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func TestTransferPaths(t *testing.T) {
+	tests := []struct {
+		name     string
+		location string
+		content  string
+		source   string
+		dest     string
+		legacy   bool
+		errMsg   string
+	}{
+		{"destination from Content-Location", "new.txt", "--- old.txt", "old.txt", "new.txt", false, ""},
+		{"matching +++", "new.txt", "--- old.txt\n+++ new.txt", "old.txt", "new.txt", false, ""},
+		{"git prefixes", "dir/new.txt", "--- a/old.txt\n+++ b/dir/new.txt", "a/old.txt", "dir/new.txt", false, ""},
+		{"legacy source location", "old.txt", "--- old.txt\n+++ new.txt", "old.txt", "new.txt", true, ""},
+		{"conflicting +++", "new.txt", "--- old.txt\n+++ other.txt", "", "", false, "Content-Location new.txt is the destination but +++ names other.txt"},
+		{"missing source", "new.txt", "+++ new.txt", "", "", false, "missing --- source path"},
+		{"same path", "old.txt", "--- old.txt", "", "", false, "source and destination are both old.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			part := parser.DeltagramPart{ContentLocation: tt.location, DeltaOperation: "move", Content: tt.content}
+			source, dest, legacy, err := transferPaths(part)
+			if tt.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Fatalf("Expected error containing %q, got: %v", tt.errMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if source != tt.source || dest != tt.dest || legacy != tt.legacy {
+				t.Errorf("Expected (%s, %s, %v), got (%s, %s, %v)", tt.source, tt.dest, tt.legacy, source, dest, legacy)
+			}
+		})
+	}
+}
+
+func TestApplier_Apply_LegacyMoveWarns(t *testing.T) {
+	fs := NewMemoryFileSystem()
+	fs.MkdirAll("/base", 0755)
+	fs.WriteFile("/base/old.txt", []byte("content"), 0644)
+
+	var warnings []Warning
+	part := parser.DeltagramPart{ContentLocation: "old.txt", ContentType: "text/plain", DeltaOperation: "move", Content: "--- old.txt\n+++ new.txt"}
+	applier := NewApplierWithOptions(fs, Options{Warn: func(w Warning) { warnings = append(warnings, w) }})
+	if err := applier.Apply(&parser.Deltagram{Parts: []parser.DeltagramPart{part}}, "/base"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if _, err := fs.Stat("/base/new.txt"); err != nil {
+		t.Errorf("Expected new.txt to exist: %v", err)
+	}
+	if len(warnings) != 1 || warnings[0].Kind != WarnLegacyLocation {
+		t.Errorf("Expected one legacy_location warning, got %+v", warnings)
+	}
+}
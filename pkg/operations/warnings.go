@@ -0,0 +1,78 @@
+package operations
+
+import (
+	"fmt"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// Warning kinds
+const (
+	WarnFuzzy          = "fuzzy"           // A hunk matched only at an offset from the line it names
+	WarnAlreadyDeleted = "already_deleted" // A file to delete did not exist
+	WarnDefaultCreate  = "default_create"  // An unknown operation was treated as create
+	WarnSymlinkEscape  = "symlink_escape"  // A path resolves outside the base directory
+	WarnLegacyLocation = "legacy_location" // A copy or move gave its source as Content-Location
+	WarnSyntax         = "syntax"          // A changed file no longer parses
+)
+
+// Warning is something that did not stop an apply but may mean the result is not what
+// the author of the deltagram intended
+type Warning struct {
+	Part    int    `json:"part"` // 1-based index of the part in the deltagram
+	Path    string `json:"path"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// warnFunc receives warnings from the operation handlers; a nil warnFunc prints them
+type warnFunc func(kind, message string)
+
+func (w warnFunc) warn(kind, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	if w == nil {
+		fmt.Printf("Warning: %s\n", message)
+		return
+	}
+	w(kind, message)
+}
+
+// reportFunc receives the lines reporting each file operation; a nil reportFunc prints them
+type reportFunc func(format string, args ...interface{})
+
+func (r reportFunc) printf(format string, args ...interface{}) {
+	if r == nil {
+		fmt.Printf(format, args...)
+		return
+	}
+	r(format, args...)
+}
+
+// warnLegacy reports a copy or move whose Content-Location names the source rather than
+// the destination
+func (w warnFunc) warnLegacy(part parser.DeltagramPart, dest string) {
+	w.warn(WarnLegacyLocation, "%s of %s gives the source as Content-Location; it should be the destination %s",
+		part.DeltaOperation, part.ContentLocation, dest)
+}
+
+// report prints a line about a part to the Output option, or to stdout when that is not set
+func (a *DefaultApplier) report(format string, args ...interface{}) {
+	if a.opts.Output == nil {
+		fmt.Printf(format, args...)
+		return
+	}
+	fmt.Fprintf(a.opts.Output, format, args...)
+}
+
+// warn reports a warning about the part being validated or applied to the Warn option,
+// printing it to the Output option when that is not set
+func (a *DefaultApplier) warn(kind, message string) {
+	warning := a.current
+	warning.Kind = kind
+	warning.Message = message
+	if a.opts.Warn == nil {
+		a.report("Warning: %s\n", message)
+		return
+	}
+	a.opts.Warn(warning)
+}
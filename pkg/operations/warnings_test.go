@@ -0,0 +1,48 @@
+package operations
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func TestApplier_Apply_Warnings(t *testing.T) {
+	fs := NewMemoryFileSystem()
+	fs.MkdirAll("/base", 0755)
+	fs.WriteFile("/base/file.txt", []byte("zero\none\ntwo\nthree"), 0644)
+
+	deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{
+		{ContentLocation: "deltagram://message", ContentType: "text/plain", Content: "Summary"},
+		// The hunk names line 1 but "one" is on line 2
+		{ContentLocation: "file.txt", ContentType: "text/plain", DeltaOperation: "content", Content: "@@ -1,2 +1,2 @@\n one\n-two\n+TWO"},
+		{ContentLocation: "gone.txt", ContentType: "text/plain", DeltaOperation: "delete"},
+		{ContentLocation: "new.txt", ContentType: "text/plain", DeltaOperation: "upsert", Content: "+++ new.txt\nnew"},
+	}}
+
+	var warnings []Warning
+	applier := NewApplierWithOptions(fs, Options{Warn: func(w Warning) { warnings = append(warnings, w) }})
+	if err := applier.Apply(deltagram, "/base"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := []struct {
+		part    int
+		path    string
+		kind    string
+		message string
+	}{
+		{2, "file.txt", WarnFuzzy, "hunk 1 of file.txt applied at line 2 (offset +1)"},
+		{3, "gone.txt", WarnAlreadyDeleted, "gone.txt does not exist"},
+		{4, "new.txt", WarnDefaultCreate, `unknown operation "upsert"`},
+	}
+	if len(warnings) != len(expected) {
+		t.Fatalf("Expected %d warnings, got %d: %+v", len(expected), len(warnings), warnings)
+	}
+	for i, want := range expected {
+		got := warnings[i]
+		if got.Part != want.part || got.Path != want.path || got.Kind != want.kind || !strings.Contains(got.Message, want.message) {
+			t.Errorf("Warning %d: expected part %d %s %s %q, got %+v", i, want.part, want.path, want.kind, want.message, got)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package operations
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// FixWhitespace returns a copy of the deltagram with trailing spaces and tabs removed
+// from the lines that its create, content, copy, and move parts add. Context and removed
+// lines are left alone so that hunks still match, and a carriage return ending a line is
+// kept.
+func FixWhitespace(deltagram *parser.Deltagram) *parser.Deltagram {
+	fixed := *deltagram
+	fixed.Parts = make([]parser.DeltagramPart, len(deltagram.Parts))
+	for i, part := range deltagram.Parts {
+		switch part.DeltaOperation {
+		case "create":
+			part.Content = fixCreateWhitespace(part.Content)
+		case "content", "copy", "move":
+			part.Content = fixHunkWhitespace(part.Content)
+		}
+		fixed.Parts[i] = part
+	}
+	return &fixed
+}
+
+// fixCreateWhitespace trims the content lines of a create body, which follow its first
+// +++ marker
+func fixCreateWhitespace(content string) string {
+	lines := strings.Split(content, "\n")
+	marker := -1 // Without a marker the whole body is the content
+	for i, line := range lines {
+		if strings.HasPrefix(line, "+++") {
+			marker = i
+			break
+		}
+	}
+	for i := marker + 1; i < len(lines); i++ {
+		lines[i] = trimTrailingSpace(lines[i])
+	}
+	return strings.Join(lines, "\n")
+}
+
+// fixHunkWhitespace trims the added lines of the hunks in a content, copy, or move body
+func fixHunkWhitespace(content string) string {
+	lines := strings.Split(content, "\n")
+	inHunks := false
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "@@"):
+			inHunks = true
+		case inHunks && strings.HasPrefix(line, "+"):
+			lines[i] = trimTrailingSpace(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// trimTrailingSpace removes spaces and tabs from the end of a line, before its carriage
+// return if it has one
+func trimTrailingSpace(line string) string {
+	if body, ok := strings.CutSuffix(line, "\r"); ok {
+		return strings.TrimRight(body, " \t") + "\r"
+	}
+	return strings.TrimRight(line, " \t")
+}
+
+// fixFinalNewline makes the file a create, content, copy, or move part wrote end with
+// exactly one newline, in the line ending the file already uses. Empty files are left
+// empty.
+func (a *DefaultApplier) fixFinalNewline(baseDir string, part parser.DeltagramPart) error {
+	switch part.DeltaOperation {
+	case "create", "content", "copy", "move":
+	default:
+		return nil
+	}
+	paths := WrittenPaths(part)
+	filePath := ResolveFilePath(baseDir, paths[len(paths)-1])
+
+	info, err := a.fs.Stat(filePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat file: %w", err)
+	}
+	data, err := a.fs.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	fixed := singleFinalNewline(string(data))
+	if fixed == string(data) {
+		return nil
+	}
+	if err := a.fs.WriteFile(filePath, []byte(fixed), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+	if a.opts.PreserveModTime {
+		if err := a.fs.Chtimes(filePath, info.ModTime(), info.ModTime()); err != nil {
+			return fmt.Errorf("failed to restore modification time: %w", err)
+		}
+	}
+	return nil
+}
+
+// singleFinalNewline returns text ending with exactly one line ending, CRLF if the text
+// uses CRLF. Text that is empty or holds only line endings becomes empty.
+func singleFinalNewline(text string) string {
+	trimmed := strings.TrimRight(text, "\r\n")
+	if trimmed == "" || trimmed == byteOrderMark {
+		return trimmed
+	}
+	if strings.Contains(text, "\r\n") {
+		return trimmed + "\r\n"
+	}
+	return trimmed + "\n"
+}
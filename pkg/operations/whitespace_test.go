@@ -0,0 +1,90 @@
+package operations
+
+import (
+	"io"
+	"testing"
+
+	"github.com/developingjames/deltagrams/internal/testutil"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func TestFixWhitespace(t *testing.T) {
+	tests := []struct {
+		name      string
+		operation string
+		content   string
+		expected  string
+	}{
+		{"create", "create", "+++ a.txt  \nline  \n\tindented\t\nwindows \r\n", "+++ a.txt  \nline\n\tindented\nwindows\r\n"},
+		{"create without marker", "create", "one \ntwo\t", "one\ntwo"},
+		{"content added lines", "content", "--- a.txt\n+++ a.txt\n@@ -1,2 +1,2 @@\n context  \n-old  \n+new  \n+  \n", "--- a.txt\n+++ a.txt\n@@ -1,2 +1,2 @@\n context  \n-old  \n+new\n+\n"},
+		{"move hunks", "move", "--- old.txt\n+++ new.txt\n@@ -1 +1 @@\n-a\n+b \r", "--- old.txt\n+++ new.txt\n@@ -1 +1 @@\n-a\n+b\r"},
+		{"other operations untouched", "kv-set", "key = value  ", "key = value  "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{{ContentLocation: "a.txt", DeltaOperation: tt.operation, Content: tt.content}}}
+			fixed := FixWhitespace(deltagram)
+			if got := fixed.Parts[0].Content; got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+			if deltagram.Parts[0].Content != tt.content {
+				t.Errorf("Expected the original deltagram to be left alone, got %q", deltagram.Parts[0].Content)
+			}
+		})
+	}
+}
+
+func TestSingleFinalNewline(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{"missing", "a\nb", "a\nb\n"},
+		{"one", "a\n", "a\n"},
+		{"several", "a\n\n\n", "a\n"},
+		{"crlf", "a\r\nb\r\n\r\n", "a\r\nb\r\n"},
+		{"crlf missing", "a\r\nb", "a\r\nb\r\n"},
+		{"empty", "", ""},
+		{"only newlines", "\n\n", ""},
+		{"byte order mark only", "\ufeff\n", "\ufeff"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := singleFinalNewline(tt.text); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestApplier_Apply_FixWhitespace(t *testing.T) {
+	fs := testutil.NewMockFileSystem()
+	fs.AddFile("/base/main.txt", []byte("one\ntwo\n"))
+	fs.AddFile("/base/touched.txt", []byte("kept  \n\n\n"))
+
+	deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{
+		{ContentLocation: "new.txt", DeltaOperation: "create", Content: "+++ new.txt\nhello  \n\n\n"},
+		{ContentLocation: "main.txt", DeltaOperation: "content", Content: "@@ -1,2 +1,2 @@\n one\n-two\n+TWO \n"},
+		{ContentLocation: "touched.txt", DeltaOperation: "touch"},
+	}}
+
+	applier := NewApplierWithOptions(fs, Options{FixWhitespace: true, Output: io.Discard})
+	if err := applier.Apply(deltagram, "/base"); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := map[string]string{
+		"/base/new.txt":     "hello\n",
+		"/base/main.txt":    "one\nTWO\n",
+		"/base/touched.txt": "kept  \n\n\n", // Only files the deltagram writes are fixed
+	}
+	for path, content := range expected {
+		if data, _ := fs.ReadFile(path); string(data) != content {
+			t.Errorf("Expected %s to be %q, got %q", path, content, data)
+		}
+	}
+}
@@ -0,0 +1,613 @@
+package operations
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// YAMLChange is one change of a yaml operation
+type YAMLChange struct {
+	Document int    // 1-based document of a multi-document file
+	Path     string // Path such as spec.containers[0].image
+	Value    string // YAML text of the new value, written as given
+	Remove   bool
+	Line     int // 1-based line of the part body
+}
+
+// YAMLHandler handles yaml operations, which set and remove values addressed by paths
+// such as spec.template.spec.containers[0].image instead of by line number. The body
+// lists one change per line:
+//
+//	spec.replicas: 3
+//	spec.template.spec.containers[0].image: nginx:1.25
+//	-metadata.annotations.deprecated
+//
+// A set replaces the value where it is, keeping the comment after it, or adds the key at
+// the end of its mapping, creating the mappings along the path that are missing. An
+// index one past the end of a sequence appends an item. A line --- N makes the changes
+// after it apply to the Nth document of the file. Only the edited values change; every
+// other line, comment, and indentation stays as it was. The module has no dependencies,
+// so this works on block-style YAML as Kubernetes manifests and CI configuration write
+// it rather than through a full YAML library: flow collections and multi-line scalars
+// can be replaced as a whole but not edited inside.
+type YAMLHandler struct {
+	preserveModTime bool
+	report          reportFunc
+}
+
+// NewYAMLHandler creates a new yaml handler
+func NewYAMLHandler() OperationHandler {
+	return &YAMLHandler{}
+}
+
+// CanHandle returns true if this handler can process the given operation
+func (h *YAMLHandler) CanHandle(operation string) bool {
+	return operation == "yaml"
+}
+
+// Apply makes the changes of a yaml part to its file
+func (h *YAMLHandler) Apply(fs FileSystem, baseDir string, part parser.DeltagramPart) error {
+	filePath := ResolveFilePath(baseDir, part.ContentLocation)
+	info, err := fs.Stat(filePath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("cannot apply yaml operation to %w: %s", ErrFileNotFound, part.ContentLocation)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to stat existing file: %w", err)
+	}
+
+	changes, err := ParseYAMLChanges(part.Content)
+	if err != nil {
+		return err
+	}
+	data, err := fs.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read existing file: %w", err)
+	}
+	modified, err := applyYAMLChanges(string(data), changes)
+	if err != nil {
+		return err
+	}
+
+	if err := fs.WriteFile(filePath, []byte(modified), info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write modified file: %w", err)
+	}
+	if h.preserveModTime {
+		if err := fs.Chtimes(filePath, info.ModTime(), info.ModTime()); err != nil {
+			return fmt.Errorf("failed to restore modification time: %w", err)
+		}
+	}
+
+	h.report.printf("Modified: %s\n", part.ContentLocation)
+	return nil
+}
+
+// ParseYAMLChanges parses the body of a yaml part
+func ParseYAMLChanges(content string) ([]YAMLChange, error) {
+	var changes []YAMLChange
+	document := 1
+	for number, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+			continue
+		case trimmed == "---" || strings.HasPrefix(trimmed, "--- "):
+			n, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(trimmed, "---")))
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("line %d: expected --- followed by a document number, got %q", number+1, trimmed)
+			}
+			document = n
+		case strings.HasPrefix(trimmed, "-"):
+			path := strings.TrimSpace(trimmed[1:])
+			if _, err := parseYAMLPath(path); err != nil {
+				return nil, fmt.Errorf("line %d: %v", number+1, err)
+			}
+			changes = append(changes, YAMLChange{Document: document, Path: path, Remove: true, Line: number + 1})
+		default:
+			at := yamlKeyEnd(trimmed)
+			if at < 0 || strings.TrimSpace(trimmed[at+1:]) == "" {
+				return nil, fmt.Errorf("line %d: expected PATH: VALUE or -PATH, got %q", number+1, trimmed)
+			}
+			path := strings.TrimSpace(trimmed[:at])
+			if _, err := parseYAMLPath(path); err != nil {
+				return nil, fmt.Errorf("line %d: %v", number+1, err)
+			}
+			changes = append(changes, YAMLChange{Document: document, Path: path, Value: strings.TrimSpace(trimmed[at+1:]), Line: number + 1})
+		}
+	}
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("yaml operation has no changes")
+	}
+	return changes, nil
+}
+
+// yamlSegment is one step of a YAML path: a mapping key or a sequence index
+type yamlSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+func (s yamlSegment) String() string {
+	if s.isIndex {
+		return fmt.Sprintf("[%d]", s.index)
+	}
+	return s.key
+}
+
+// parseYAMLPath splits a path such as a.b[0]."c.d" into its segments
+func parseYAMLPath(path string) ([]yamlSegment, error) {
+	var segments []yamlSegment
+	for i := 0; i < len(path); {
+		switch c := path[i]; {
+		case c == '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: unclosed [", path)
+			}
+			index, err := strconv.Atoi(path[i+1 : i+end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid path %q: bad index %q", path, path[i+1:i+end])
+			}
+			segments = append(segments, yamlSegment{index: index, isIndex: true})
+			i += end + 1
+		case c == '.' && i > 0:
+			i++
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(path[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: unclosed quote", path)
+			}
+			segments = append(segments, yamlSegment{key: path[i+1 : i+1+end]})
+			i += end + 2
+		default:
+			end := i
+			for end < len(path) && path[end] != '.' && path[end] != '[' {
+				end++
+			}
+			if end == i {
+				return nil, fmt.Errorf("invalid path %q: empty key", path)
+			}
+			segments = append(segments, yamlSegment{key: path[i:end]})
+			i = end
+		}
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("empty path")
+	}
+	return segments, nil
+}
+
+// yamlKeyEnd returns the offset of the colon ending the key at the start of text, or -1.
+// The colon must be followed by a space or end the text, and may not be inside quotes or
+// brackets.
+func yamlKeyEnd(text string) int {
+	var quote byte
+	depth := 0
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		case c == ':' && depth == 0 && (i+1 == len(text) || text[i+1] == ' ' || text[i+1] == '\t'):
+			return i
+		}
+	}
+	return -1
+}
+
+// yamlKind is the kind of a YAML node
+type yamlKind int
+
+const (
+	yamlNull     yamlKind = iota // A key or item with no value
+	yamlScalar                   // A value on the line of its key or item
+	yamlMapping                  // Block mapping
+	yamlSequence                 // Block sequence
+)
+
+// yamlNode is a value in a YAML document with the lines it occupies
+type yamlNode struct {
+	kind   yamlKind
+	indent int // Column of the keys or dashes of a collection, or of the children of a null
+	end    int // Line after the node's last content line
+	// Scalar and null nodes: where the value is, or goes, on its line
+	line, start, stop int
+	entries           []yamlEntry // Mapping entries, or sequence items with an empty key
+}
+
+// yamlEntry is a key of a mapping or an item of a sequence
+type yamlEntry struct {
+	key       string
+	line, col int // Where the key or dash starts
+	value     *yamlNode
+}
+
+// yamlDocument is the text of a YAML file being edited
+type yamlDocument struct {
+	lines  []string
+	ending string // Line ending for new lines
+}
+
+// text returns line i without a carriage return
+func (d *yamlDocument) text(i int) string {
+	return strings.TrimSuffix(d.lines[i], "\r")
+}
+
+// isContent reports whether line i holds something other than whitespace or a comment
+func (d *yamlDocument) isContent(i int) bool {
+	trimmed := strings.TrimSpace(d.text(i))
+	return trimmed != "" && !strings.HasPrefix(trimmed, "#")
+}
+
+// nextContent returns the first content line at or after i and before end, or end
+func (d *yamlDocument) nextContent(i, end int) int {
+	for i < end && !d.isContent(i) {
+		i++
+	}
+	return i
+}
+
+// indentOf returns the number of spaces line i starts with
+func (d *yamlDocument) indentOf(i int) int {
+	text := d.text(i)
+	return len(text) - len(strings.TrimLeft(text, " "))
+}
+
+// isDash reports whether text starts a sequence item
+func isDash(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ") || strings.HasPrefix(text, "-\t")
+}
+
+// documents returns the line ranges of the documents of the file, which are separated by
+// --- lines. Comments before the first --- are not a document of their own.
+func (d *yamlDocument) documents() [][2]int {
+	var ranges [][2]int
+	start := 0
+	for i := range d.lines {
+		text := d.text(i)
+		if text == "---" || strings.HasPrefix(text, "--- ") || text == "..." {
+			if i > start && d.nextContent(start, i) < i {
+				ranges = append(ranges, [2]int{start, i})
+			}
+			start = i + 1
+		}
+	}
+	if start < len(d.lines) || len(ranges) == 0 {
+		ranges = append(ranges, [2]int{start, len(d.lines)})
+	}
+	return ranges
+}
+
+// parse reads the node whose content starts at the given line and column
+func (d *yamlDocument) parse(line, col, end int) (*yamlNode, error) {
+	text := d.text(line)[col:]
+	if isDash(text) {
+		return d.parseSequence(line, col, end)
+	}
+	if !strings.HasPrefix(text, "[") && !strings.HasPrefix(text, "{") && yamlKeyEnd(text) > 0 {
+		return d.parseMapping(line, col, end)
+	}
+	return d.parseScalar(line, col, col, end), nil
+}
+
+// parseSequence reads a block sequence whose first dash is at the given line and column
+func (d *yamlDocument) parseSequence(line, col, end int) (*yamlNode, error) {
+	node := &yamlNode{kind: yamlSequence, indent: col}
+	for {
+		value, err := d.parseValue(line, col, col+1, end, false)
+		if err != nil {
+			return nil, err
+		}
+		node.entries = append(node.entries, yamlEntry{line: line, col: col, value: value})
+		node.end = value.end
+
+		next := d.nextContent(node.end, end)
+		if next == end || d.indentOf(next) != col || !isDash(d.text(next)[col:]) {
+			break
+		}
+		line = next
+	}
+	return node, nil
+}
+
+// parseMapping reads a block mapping whose first key is at the given line and column
+func (d *yamlDocument) parseMapping(line, col, end int) (*yamlNode, error) {
+	node := &yamlNode{kind: yamlMapping, indent: col}
+	for {
+		text := d.text(line)[col:]
+		colon := yamlKeyEnd(text)
+		if colon <= 0 {
+			return nil, fmt.Errorf("line %d is not a key: %q", line+1, strings.TrimSpace(text))
+		}
+		key := strings.TrimSpace(text[:colon])
+		if len(key) >= 2 && (key[0] == '"' || key[0] == '\'') && key[len(key)-1] == key[0] {
+			key = key[1 : len(key)-1]
+		}
+		value, err := d.parseValue(line, col, col+colon+1, end, true)
+		if err != nil {
+			return nil, err
+		}
+		node.entries = append(node.entries, yamlEntry{key: key, line: line, col: col, value: value})
+		node.end = value.end
+
+		next := d.nextContent(node.end, end)
+		if next == end || d.indentOf(next) < col {
+			break
+		}
+		if d.indentOf(next) > col || isDash(d.text(next)[col:]) {
+			return nil, fmt.Errorf("line %d is indented unexpectedly", next+1)
+		}
+		line = next
+	}
+	return node, nil
+}
+
+// parseValue reads the value of a key or item whose text continues at column after on
+// the line. A key's value may be a sequence at the key's own indentation.
+func (d *yamlDocument) parseValue(line, col, after, end int, isKey bool) (*yamlNode, error) {
+	text := d.text(line)
+	start := after
+	for start < len(text) && (text[start] == ' ' || text[start] == '\t') {
+		start++
+	}
+	if start < len(text) && text[start] != '#' {
+		if !isKey {
+			// An item's value starts on the dash line, as in "- name: web"
+			return d.parse(line, start, end)
+		}
+		return d.parseScalar(line, start, col, end), nil
+	}
+
+	next := d.nextContent(line+1, end)
+	if next < end && (d.indentOf(next) > col || (isKey && d.indentOf(next) == col && isDash(d.text(next)[col:]))) {
+		return d.parse(next, d.indentOf(next), end)
+	}
+	return &yamlNode{kind: yamlNull, indent: col + 2, end: line + 1, line: line, start: after, stop: after}, nil
+}
+
+// parseScalar reads a value starting at the given column, along with any lines of a block
+// scalar or multi-line plain scalar after it, which are more indented than its key
+func (d *yamlDocument) parseScalar(line, start, keyCol, end int) *yamlNode {
+	text := d.text(line)
+	node := &yamlNode{kind: yamlScalar, line: line, start: start, stop: scalarEnd(text, start), end: line + 1}
+	for i := line + 1; i < end; i++ {
+		if !d.isContent(i) {
+			if strings.TrimSpace(d.text(i)) == "" {
+				continue
+			}
+			break
+		}
+		if d.indentOf(i) <= keyCol {
+			break
+		}
+		node.end = i + 1
+	}
+	return node
+}
+
+// scalarEnd returns where the scalar starting at start ends, before any comment
+func scalarEnd(text string, start int) int {
+	switch text[start] {
+	case '"':
+		for i := start + 1; i < len(text); i++ {
+			if text[i] == '\\' {
+				i++
+			} else if text[i] == '"' {
+				return i + 1
+			}
+		}
+	case '\'':
+		for i := start + 1; i < len(text); i++ {
+			if text[i] == '\'' {
+				if i+1 < len(text) && text[i+1] == '\'' {
+					i++
+					continue
+				}
+				return i + 1
+			}
+		}
+	}
+	end := len(text)
+	if at := strings.Index(text[start:], " #"); at >= 0 {
+		end = start + at
+	}
+	return len(strings.TrimRight(text[:end], " \t"))
+}
+
+// applyYAMLChanges returns text with the changes made to it
+func applyYAMLChanges(text string, changes []YAMLChange) (string, error) {
+	bom, rest := cutByteOrderMark(text)
+	lines, finalNewline := fileLines(rest)
+	doc := &yamlDocument{lines: lines}
+	if len(lines) > 0 && strings.HasSuffix(lines[0], "\r") {
+		doc.ending = "\r"
+	}
+
+	for _, change := range changes {
+		if err := doc.apply(change); err != nil {
+			return "", fmt.Errorf("body line %d: %w", change.Line, err)
+		}
+	}
+	return bom + joinFileLines(doc.lines, finalNewline), nil
+}
+
+// apply makes one change to the document
+func (d *yamlDocument) apply(change YAMLChange) error {
+	segments, err := parseYAMLPath(change.Path)
+	if err != nil {
+		return err
+	}
+	ranges := d.documents()
+	if change.Document > len(ranges) {
+		return fmt.Errorf("the file has %d document(s), not %d", len(ranges), change.Document)
+	}
+	start, end := ranges[change.Document-1][0], ranges[change.Document-1][1]
+
+	root := &yamlNode{kind: yamlNull, end: start, line: -1}
+	if first := d.nextContent(start, end); first < end {
+		if root, err = d.parse(first, d.indentOf(first), end); err != nil {
+			return err
+		}
+	}
+
+	node := root
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		path := yamlPathString(segments[:i+1])
+		switch node.kind {
+		case yamlScalar:
+			return fmt.Errorf("cannot reach %s: %s is a scalar", change.Path, yamlPathString(segments[:i]))
+		case yamlNull:
+			if change.Remove {
+				return nil
+			}
+			if segment.isIndex && segment.index != 0 {
+				return fmt.Errorf("cannot set %s: the sequence is empty", path)
+			}
+			d.insert(node.end, d.newLines(segments[i:], change.Value, node.indent))
+			return nil
+		}
+
+		if segment.isIndex != (node.kind == yamlSequence) {
+			kind := "a mapping"
+			if node.kind == yamlSequence {
+				kind = "a sequence"
+			}
+			return fmt.Errorf("cannot reach %s: %s is %s", change.Path, yamlPathString(segments[:i]), kind)
+		}
+		found := -1
+		for j, entry := range node.entries {
+			if (segment.isIndex && j == segment.index) || (!segment.isIndex && entry.key == segment.key) {
+				found = j
+				break
+			}
+		}
+		if found < 0 {
+			switch {
+			case change.Remove:
+				return nil
+			case segment.isIndex && segment.index != len(node.entries):
+				return fmt.Errorf("cannot set %s: the sequence has %d item(s)", path, len(node.entries))
+			}
+			d.insert(node.end, d.newLines(segments[i:], change.Value, node.indent))
+			return nil
+		}
+
+		if !last {
+			node = node.entries[found].value
+			continue
+		}
+		if change.Remove {
+			return d.remove(node, found)
+		}
+		return d.set(node.entries[found].value, path, change.Value)
+	}
+	return nil
+}
+
+// set replaces a scalar or null value, keeping the comment after it
+func (d *yamlDocument) set(node *yamlNode, path, value string) error {
+	switch node.kind {
+	case yamlMapping, yamlSequence:
+		return fmt.Errorf("cannot set %s: it is a collection; set the values inside it instead", path)
+	case yamlNull:
+		value = " " + value
+	}
+	text := d.lines[node.line]
+	d.lines[node.line] = text[:node.start] + value + text[node.stop:]
+	// The rest of a multi-line scalar goes with it
+	d.lines = append(d.lines[:node.line+1], d.lines[node.end:]...)
+	return nil
+}
+
+// remove deletes an entry of a mapping or an item of a sequence
+func (d *yamlDocument) remove(node *yamlNode, index int) error {
+	entry := node.entries[index]
+	end := entry.value.end
+	if entry.col == d.indentOf(entry.line) {
+		d.lines = append(d.lines[:entry.line], d.lines[end:]...)
+		return nil
+	}
+
+	// The first key of an item shares the dash line, as in "- name: web", so the next
+	// key moves up onto that line
+	prefix := d.lines[entry.line][:entry.col]
+	if index+1 == len(node.entries) {
+		empty := "{}"
+		if node.kind == yamlSequence {
+			empty = "[]"
+		}
+		d.lines[entry.line] = prefix + empty + d.ending
+		d.lines = append(d.lines[:entry.line+1], d.lines[end:]...)
+		return nil
+	}
+	next := node.entries[index+1]
+	d.lines[next.line] = prefix + d.lines[next.line][next.col:]
+	d.lines = append(d.lines[:entry.line], d.lines[next.line:]...)
+	return nil
+}
+
+// newLines returns the lines that add the value at the path of segments, starting at the
+// given indentation
+func (d *yamlDocument) newLines(segments []yamlSegment, value string, indent int) []string {
+	pad := strings.Repeat(" ", indent)
+	segment := segments[0]
+	if len(segments) == 1 {
+		if segment.isIndex {
+			return []string{pad + "- " + value + d.ending}
+		}
+		return []string{pad + yamlKey(segment.key) + ": " + value + d.ending}
+	}
+	child := d.newLines(segments[1:], value, indent+2)
+	if segment.isIndex {
+		child[0] = pad + "- " + child[0][indent+2:]
+		return child
+	}
+	return append([]string{pad + yamlKey(segment.key) + ":" + d.ending}, child...)
+}
+
+// insert puts lines before line at
+func (d *yamlDocument) insert(at int, lines []string) {
+	d.lines = append(d.lines[:at], append(lines, d.lines[at:]...)...)
+}
+
+// yamlKey quotes a key that would not read back as the same plain key
+func yamlKey(key string) string {
+	for _, c := range key {
+		if !(c == '_' || c == '-' || c == '.' || c == '/' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			return strconv.Quote(key)
+		}
+	}
+	if key == "" || key[0] == '-' {
+		return strconv.Quote(key)
+	}
+	return key
+}
+
+// yamlPathString formats path segments for messages
+func yamlPathString(segments []yamlSegment) string {
+	var b strings.Builder
+	for i, segment := range segments {
+		if i > 0 && !segment.isIndex {
+			b.WriteByte('.')
+		}
+		b.WriteString(segment.String())
+	}
+	if b.Len() == 0 {
+		return "the document"
+	}
+	return b.String()
+}
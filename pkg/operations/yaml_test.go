@@ -0,0 +1,154 @@
+package operations
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/developingjames/deltagrams/internal/testutil"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+const deployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web # the public site
+  labels:
+    app: web
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+      - name: web
+        image: nginx:1.24  # pinned
+        args:
+          - --port=80
+      - name: sidecar
+        image: envoy:1.28
+`
+
+func TestYAMLHandler_Apply(t *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		body     string
+		expected string
+	}{
+		{"set scalar keeps comment", deployment,
+			"spec.template.spec.containers[0].image: nginx:1.25",
+			strings.Replace(deployment, "nginx:1.24  # pinned", "nginx:1.25  # pinned", 1)},
+		{"set several", deployment,
+			"spec.replicas: 3\nmetadata.name: \"site\"",
+			strings.Replace(strings.Replace(deployment, "replicas: 2", "replicas: 3", 1), "name: web # the", "name: \"site\" # the", 1)},
+		{"add key to mapping", deployment,
+			"metadata.labels.tier: frontend",
+			strings.Replace(deployment, "    app: web\n", "    app: web\n    tier: frontend\n", 1)},
+		{"add key to sequence item", deployment,
+			"spec.template.spec.containers[1].ports[0].containerPort: 9901",
+			deployment + "        ports:\n          - containerPort: 9901\n"},
+		{"add missing mappings", "kind: ConfigMap\n",
+			"metadata.annotations.\"app.kubernetes.io/name\": web",
+			"kind: ConfigMap\nmetadata:\n  annotations:\n    app.kubernetes.io/name: web\n"},
+		{"append to sequence", deployment,
+			"spec.template.spec.containers[0].args[1]: --verbose",
+			strings.Replace(deployment, "          - --port=80\n", "          - --port=80\n          - --verbose\n", 1)},
+		{"set empty key", "env:\nname: x\n",
+			"env: []",
+			"env: []\nname: x\n"},
+		{"fill empty mapping", "metadata:\n  labels:\nspec: {}\n",
+			"metadata.labels.app: web",
+			"metadata:\n  labels:\n    app: web\nspec: {}\n"},
+		{"remove key", deployment,
+			"-metadata.labels",
+			strings.Replace(deployment, "  labels:\n    app: web\n", "", 1)},
+		{"remove sequence item", deployment,
+			"-spec.template.spec.containers[1]",
+			strings.Replace(deployment, "      - name: sidecar\n        image: envoy:1.28\n", "", 1)},
+		{"remove first key of item", deployment,
+			"-spec.template.spec.containers[1].name",
+			strings.Replace(deployment, "      - name: sidecar\n        image: envoy:1.28\n", "      - image: envoy:1.28\n", 1)},
+		{"remove missing key", deployment,
+			"-metadata.annotations.old",
+			deployment},
+		{"replace block scalar", "script: |\n  make\n  make test\nafter: 1\n",
+			"script: make all",
+			"script: make all\nafter: 1\n"},
+		{"quoted value with hash", "a: \"x # y\" # note\n",
+			"a: z",
+			"a: z # note\n"},
+		{"second document", "kind: Service\n---\nkind: Deployment\nspec:\n  replicas: 1\n",
+			"--- 2\nspec.replicas: 4",
+			"kind: Service\n---\nkind: Deployment\nspec:\n  replicas: 4\n"},
+		{"leading separator", "# header\n---\nkind: Service\n",
+			"kind: Deployment",
+			"# header\n---\nkind: Deployment\n"},
+		{"CRLF", "a:\r\n  b: 1\r\n",
+			"a.c: 2",
+			"a:\r\n  b: 1\r\n  c: 2\r\n"},
+		{"keeps byte order mark", "\ufeffa: 1\n",
+			"a: 2",
+			"\ufeffa: 2\n"},
+		{"no final newline", "a: 1",
+			"b: 2",
+			"a: 1\nb: 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := testutil.NewMockFileSystem()
+			fs.AddFile("/base/deploy.yaml", []byte(tt.original))
+
+			part := parser.DeltagramPart{ContentLocation: "deploy.yaml", DeltaOperation: "yaml", Content: tt.body}
+			if err := (&YAMLHandler{report: func(string, ...interface{}) {}}).Apply(fs, "/base", part); err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if data, _ := fs.ReadFile("/base/deploy.yaml"); string(data) != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, data)
+			}
+		})
+	}
+}
+
+func TestYAMLHandler_Apply_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{"through scalar", "kind.name: x", "kind is a scalar"},
+		{"index into mapping", "metadata[0]: x", "metadata is a mapping"},
+		{"key into sequence", "spec.template.spec.containers.name: x", "containers is a sequence"},
+		{"index past end", "spec.template.spec.containers[5].image: x", "the sequence has 2 item(s)"},
+		{"set collection", "metadata.labels: x", "it is a collection"},
+		{"missing document", "--- 3\nkind: x", "has 1 document(s)"},
+		{"no value", "spec.replicas:", "expected PATH: VALUE"},
+		{"bad index", "spec.x[a]: 1", "bad index"},
+		{"bad document", "--- two", "expected --- followed by a document number"},
+		{"empty body", "# nothing\n", "has no changes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := testutil.NewMockFileSystem()
+			fs.AddFile("/base/deploy.yaml", []byte(deployment))
+
+			part := parser.DeltagramPart{ContentLocation: "deploy.yaml", DeltaOperation: "yaml", Content: tt.body}
+			err := NewYAMLHandler().Apply(fs, "/base", part)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Fatalf("Expected error containing %q, got: %v", tt.expected, err)
+			}
+			if data, _ := fs.ReadFile("/base/deploy.yaml"); string(data) != deployment {
+				t.Errorf("Expected the file to be unchanged, got %q", data)
+			}
+		})
+	}
+}
+
+func TestYAMLHandler_Apply_MissingFile(t *testing.T) {
+	fs := testutil.NewMockFileSystem()
+	part := parser.DeltagramPart{ContentLocation: "deploy.yaml", DeltaOperation: "yaml", Content: "a: 1"}
+	if err := NewYAMLHandler().Apply(fs, "/base", part); !errors.Is(err, ErrFileNotFound) {
+		t.Errorf("Expected ErrFileNotFound, got: %v", err)
+	}
+}
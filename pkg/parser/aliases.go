@@ -0,0 +1,52 @@
+package parser
+
+import "strings"
+
+// operationAliases maps operation names models commonly guess to the operation they mean
+var operationAliases = map[string]string{
+	"modify":   "content",
+	"modified": "content",
+	"edit":     "content",
+	"patch":    "content",
+	"update":   "content",
+	"diff":     "content",
+	"add":      "create",
+	"new":      "create",
+	"write":    "create",
+	"remove":   "delete",
+	"rm":       "delete",
+	"rename":   "move",
+	"mv":       "move",
+	"cp":       "copy",
+}
+
+// knownOperations are the operations a Delta-Operation value may be canonicalized to
+var knownOperations = map[string]bool{
+	"create":   true,
+	"content":  true,
+	"delete":   true,
+	"move":     true,
+	"copy":     true,
+	"inline":   true,
+	"table":    true,
+	"lines":    true,
+	"kvset":    true,
+	"yaml":     true,
+	"go-ast":   true,
+	"touch":    true,
+	"truncate": true,
+}
+
+// CanonicalOperation returns the operation a Delta-Operation value stands for when it is
+// an alias such as "modify" or "rm", or a known operation written in another case. The
+// boolean is false when the value is already canonical or is not recognized.
+func CanonicalOperation(operation string) (string, bool) {
+	canonical := strings.ToLower(strings.TrimSpace(operation))
+	if alias, ok := operationAliases[canonical]; ok {
+		canonical = alias
+	}
+	if canonical == operation || !knownOperations[canonical] {
+		return "", false
+	}
+	return canonical, true
+}
@@ -0,0 +1,63 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCanonicalOperation(t *testing.T) {
+	tests := []struct {
+		operation string
+		expected  string
+		ok        bool
+	}{
+		{"content", "", false},
+		{"modify", "content", true},
+		{"update", "content", true},
+		{"edit", "content", true},
+		{"rename", "move", true},
+		{"remove", "delete", true},
+		{"rm", "delete", true},
+		{"add", "create", true},
+		{"new", "create", true},
+		{"Content", "content", true},
+		{"DELETE", "delete", true},
+		{"frobnicate", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.operation, func(t *testing.T) {
+			got, ok := CanonicalOperation(tt.operation)
+			if got != tt.expected || ok != tt.ok {
+				t.Errorf("Expected (%q, %v), got (%q, %v)", tt.expected, tt.ok, got, ok)
+			}
+		})
+	}
+}
+
+func TestParser_Parse_OperationAliases(t *testing.T) {
+	content := "--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: old.txt\n" +
+		"Content-Type: text/plain\n" +
+		"Delta-Operation: rm\n" +
+		"--====DELTAGRAM_0123456789abcdef====--"
+
+	deltagram, err := NewParser().Parse(content)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if deltagram.Parts[0].DeltaOperation != "delete" {
+		t.Errorf("Expected rm to be read as delete, got %q", deltagram.Parts[0].DeltaOperation)
+	}
+	if len(deltagram.Repairs) != 0 {
+		t.Errorf("Expected aliases to be accepted without listing repairs, got %+v", deltagram.Repairs)
+	}
+
+	_, err = NewParserWithOptions(Options{StrictOperations: true}).Parse(content)
+	if err == nil {
+		t.Fatalf("Expected strict operations to reject the alias")
+	}
+	if !strings.Contains(err.Error(), `use "delete"`) {
+		t.Errorf("Expected error suggesting delete, got: %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package parser
+
+import "strings"
+
+// boundaryPrefix starts every boundary line, whatever its identifier
+const boundaryPrefix = "--====DELTAGRAM_"
+
+// EscapeContent escapes lines of part content that could be read as a boundary, so that
+// a deltagram can carry text containing boundary lines (such as another deltagram, or
+// documentation of the format) without a Content-Length header. Like mboxrd quoting of
+// "From " lines, a backslash is added to every line that is a boundary prefix preceded by
+// zero or more backslashes, which keeps the escaping reversible.
+func EscapeContent(content string) string {
+	if !strings.Contains(content, boundaryPrefix) {
+		return content
+	}
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if isEscapedBoundary(line) {
+			lines[i] = `\` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// UnescapeContent reverses EscapeContent, removing one backslash from every line that is
+// backslashes followed by a boundary prefix
+func UnescapeContent(content string) string {
+	if !strings.Contains(content, `\`+boundaryPrefix) {
+		return content
+	}
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, `\`) && isEscapedBoundary(line) {
+			lines[i] = line[1:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// isEscapedBoundary reports whether line is zero or more backslashes followed by the
+// boundary prefix
+func isEscapedBoundary(line string) bool {
+	return strings.HasPrefix(strings.TrimLeft(line, `\`), boundaryPrefix)
+}
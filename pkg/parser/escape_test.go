@@ -0,0 +1,56 @@
+package parser
+
+import "testing"
+
+func TestEscapeContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		escaped string
+	}{
+		{"no boundary", "+hello\nworld", "+hello\nworld"},
+		{"boundary line", "intro\n--====DELTAGRAM_0123456789abcdef====\nend", "intro\n\\--====DELTAGRAM_0123456789abcdef====\nend"},
+		{"final boundary", "--====DELTAGRAM_0123456789abcdef====--", "\\--====DELTAGRAM_0123456789abcdef====--"},
+		{"already escaped", "\\--====DELTAGRAM_x====\n\\\\--====DELTAGRAM_x====", "\\\\--====DELTAGRAM_x====\n\\\\\\--====DELTAGRAM_x===="},
+		{"mid-line boundary", "see --====DELTAGRAM_x====", "see --====DELTAGRAM_x===="},
+		{"backslash line", "\\n\\", "\\n\\"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			escaped := EscapeContent(tt.content)
+			if escaped != tt.escaped {
+				t.Errorf("Expected escaped %q, got %q", tt.escaped, escaped)
+			}
+			if unescaped := UnescapeContent(escaped); unescaped != tt.content {
+				t.Errorf("Expected round trip to give %q, got %q", tt.content, unescaped)
+			}
+		})
+	}
+}
+
+func TestParser_Parse_EscapedBoundary(t *testing.T) {
+	body := "Example:\n--====DELTAGRAM_0123456789abcdef====\n--====DELTAGRAM_0123456789abcdef====--"
+	content := "--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: docs/format.md\n" +
+		"Content-Type: text/markdown\n" +
+		"Delta-Operation: create\n" +
+		"\n" +
+		EscapeContent(body) + "\n" +
+		"--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: old.txt\n" +
+		"Content-Type: text/plain\n" +
+		"Delta-Operation: delete\n" +
+		"--====DELTAGRAM_0123456789abcdef====--"
+
+	deltagram, err := NewParser().Parse(content)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(deltagram.Parts) != 2 {
+		t.Fatalf("Expected 2 parts, got %d", len(deltagram.Parts))
+	}
+	if deltagram.Parts[0].Content != body {
+		t.Errorf("Expected content %q, got %q", body, deltagram.Parts[0].Content)
+	}
+}
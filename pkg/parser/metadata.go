@@ -0,0 +1,84 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Metadata is the structured description of a deltagram, taken from optional headers of
+// its message part
+type Metadata struct {
+	Author      string
+	Date        time.Time // Zero when the Date header is absent
+	Ticket      string
+	Description string // The Description header, or else the message text
+}
+
+// dateLayouts are the formats accepted in a message part's Date header
+var dateLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02", time.RFC1123Z, time.RFC1123}
+
+// parseMetadata reads the metadata headers of a message part
+func parseMetadata(part *DeltagramPart) (*Metadata, error) {
+	metadata := &Metadata{Description: strings.TrimSpace(part.Content)}
+	metadata.Author, _ = part.Header("Author")
+	metadata.Ticket, _ = part.Header("Ticket")
+	if description, ok := part.Header("Description"); ok {
+		metadata.Description = description
+	}
+
+	if value, ok := part.Header("Date"); ok {
+		date, err := parseDate(value)
+		if err != nil {
+			return nil, err
+		}
+		metadata.Date = date
+	}
+	return metadata, nil
+}
+
+// parseDate parses a Date header in any of dateLayouts
+func parseDate(value string) (time.Time, error) {
+	for _, layout := range dateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid Date header %q: use YYYY-MM-DD or RFC 3339", value)
+}
+
+// SetAuthor sets the Author header of the deltagram's message part, adding an empty
+// message part at the start when it has none, so that the deltagram is attributed to
+// author wherever it is applied
+func (d *Deltagram) SetAuthor(author string) {
+	index := -1
+	for i := range d.Parts {
+		if d.Parts[i].IsPlainMessage() {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		message := DeltagramPart{ContentLocation: "deltagram://message", ContentType: "text/plain"}
+		d.Parts = append([]DeltagramPart{message}, d.Parts...)
+		index = 0
+	}
+
+	part := &d.Parts[index]
+	headers := make(map[string]string, len(part.Headers)+1)
+	for name, value := range part.Headers {
+		if !strings.EqualFold(name, "Author") {
+			headers[name] = value
+		}
+	}
+	headers["Author"] = author
+	part.Headers = headers
+
+	if d.Metadata == nil {
+		d.Metadata = &Metadata{Description: strings.TrimSpace(part.Content)}
+	} else {
+		metadata := *d.Metadata
+		d.Metadata = &metadata
+	}
+	d.Metadata.Author = author
+}
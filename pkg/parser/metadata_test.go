@@ -0,0 +1,132 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParser_Parse_Metadata(t *testing.T) {
+	tests := []struct {
+		name     string
+		headers  string
+		expected Metadata
+	}{
+		{
+			name:     "message text only",
+			expected: Metadata{Description: "Add logging"},
+		},
+		{
+			name:    "all fields",
+			headers: "Author: Sam Lee <sam@example.com>\nDate: 2024-05-01\nTicket: APP-142\nDescription: Logging for the API\n",
+			expected: Metadata{Author: "Sam Lee <sam@example.com>", Date: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+				Ticket: "APP-142", Description: "Logging for the API"},
+		},
+		{
+			name:     "RFC 3339 date",
+			headers:  "date: 2024-05-01T10:30:00Z\n",
+			expected: Metadata{Date: time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC), Description: "Add logging"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := "--====DELTAGRAM_0123456789abcdef====\n" +
+				"Content-Location: deltagram://message\n" +
+				"Content-Type: text/plain\n" +
+				tt.headers +
+				"\n" +
+				"Add logging\n" +
+				"--====DELTAGRAM_0123456789abcdef====--"
+
+			deltagram, err := NewParser().Parse(content)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if deltagram.Metadata == nil {
+				t.Fatalf("Expected metadata")
+			}
+			got := *deltagram.Metadata
+			if got.Author != tt.expected.Author || !got.Date.Equal(tt.expected.Date) ||
+				got.Ticket != tt.expected.Ticket || got.Description != tt.expected.Description {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParser_Parse_MetadataErrors(t *testing.T) {
+	content := "--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: deltagram://message\n" +
+		"Content-Type: text/plain\n" +
+		"Date: last Tuesday\n" +
+		"--====DELTAGRAM_0123456789abcdef====--"
+
+	_, err := NewParser().Parse(content)
+	if err == nil || !strings.Contains(err.Error(), "invalid Date header") {
+		t.Errorf("Expected invalid Date header error, got: %v", err)
+	}
+
+	noMessage := "--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: a.txt\n" +
+		"Content-Type: text/plain\n" +
+		"Delta-Operation: delete\n" +
+		"--====DELTAGRAM_0123456789abcdef====--"
+	deltagram, err := NewParser().Parse(noMessage)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if deltagram.Metadata != nil {
+		t.Errorf("Expected no metadata without a message part, got %+v", deltagram.Metadata)
+	}
+}
+
+func TestDeltagram_SetAuthor(t *testing.T) {
+	create := "--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: a.txt\n" +
+		"Content-Type: text/plain\n" +
+		"\n" +
+		"a\n"
+	tests := []struct {
+		name    string
+		content string
+		parts   int
+	}{
+		{name: "no message part", content: create + "--====DELTAGRAM_0123456789abcdef====--", parts: 2},
+		{
+			name: "existing author",
+			content: "--====DELTAGRAM_0123456789abcdef====\n" +
+				"Content-Location: deltagram://message\n" +
+				"Content-Type: text/plain\n" +
+				"author: Someone Else\n" +
+				"Ticket: APP-1\n" +
+				"\n" +
+				"Add a\n" + create + "--====DELTAGRAM_0123456789abcdef====--",
+			parts: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deltagram, err := NewParser().Parse(tt.content)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			deltagram.SetAuthor("Sam Lee <sam@example.com>")
+
+			reparsed, err := NewParser().Parse(Serialize(deltagram))
+			if err != nil {
+				t.Fatalf("Expected the stamped deltagram to parse, got: %v", err)
+			}
+			if len(reparsed.Parts) != tt.parts {
+				t.Errorf("Expected %d parts, got %d", tt.parts, len(reparsed.Parts))
+			}
+			if reparsed.Metadata == nil || reparsed.Metadata.Author != "Sam Lee <sam@example.com>" {
+				t.Errorf("Expected the author to be stamped, got %+v", reparsed.Metadata)
+			}
+			if deltagram.Metadata.Author != "Sam Lee <sam@example.com>" || strings.Contains(Serialize(deltagram), "Someone Else") {
+				t.Errorf("Expected the old author to be replaced")
+			}
+		})
+	}
+}
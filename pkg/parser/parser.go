@@ -1,70 +1,128 @@
 package parser
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
+// boundaryLineRegex matches a whole boundary line, capturing its identifier
+var boundaryLineRegex = regexp.MustCompile(`^--====DELTAGRAM_([a-zA-Z0-9_-]+)====(?:--)?$`)
+
+// headerRegex matches a "Name: value" header line
+var headerRegex = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9-]*):\s*(.*)$`)
+
+// ErrInvalidBoundary is returned, wrapped, when a deltagram has no boundary line or its
+// boundary identifier is not valid
+var ErrInvalidBoundary = errors.New("missing or malformed boundary")
+
+// Options configures a parser
+type Options struct {
+	// Repair fixes recoverable formatting mistakes instead of rejecting or misreading
+	// them, listing each fix in Deltagram.Repairs
+	Repair bool
+	// StrictOperations rejects operation aliases such as "modify" instead of reading them
+	// as the operation they stand for
+	StrictOperations bool
+}
+
 // DefaultParser implements the Parser interface
-type DefaultParser struct{}
+type DefaultParser struct {
+	opts Options
+}
 
 // NewParser creates a new default parser
 func NewParser() Parser {
-	return &DefaultParser{}
+	return NewParserWithOptions(Options{})
+}
+
+// NewParserWithOptions creates a new parser with the given options
+func NewParserWithOptions(opts Options) Parser {
+	return &DefaultParser{opts: opts}
 }
 
 // Parse parses a deltagram string into a Deltagram struct
 func (p *DefaultParser) Parse(content string) (*Deltagram, error) {
+	return p.ParseContext(context.Background(), content)
+}
+
+// ParseContext parses a deltagram string, checking ctx between parts so that parsing a
+// very large deltagram can be canceled
+func (p *DefaultParser) ParseContext(ctx context.Context, content string) (*Deltagram, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	// Normalize line endings to LF
 	content = strings.ReplaceAll(content, "\r\n", "\n")
 	content = strings.ReplaceAll(content, "\r", "\n")
 
-	// Extract boundary identifier from first boundary (more flexible than strict UUID)
-	boundaryRegex := regexp.MustCompile(`--====DELTAGRAM_([a-zA-Z0-9_-]+)====`)
-	matches := boundaryRegex.FindStringSubmatch(content)
-	if len(matches) < 2 {
-		return nil, fmt.Errorf("invalid deltagram format: missing or malformed boundary")
+	// Extract boundary identifier from the first boundary line (more flexible than strict UUID)
+	identifier := ""
+	for _, line := range strings.Split(content, "\n") {
+		if matches := boundaryLineRegex.FindStringSubmatch(strings.TrimRight(line, " \t")); matches != nil {
+			identifier = matches[1]
+			break
+		}
+	}
+	if identifier == "" {
+		return nil, fmt.Errorf("invalid deltagram format: %w", ErrInvalidBoundary)
 	}
-
-	identifier := matches[1]
 
 	// Validate identifier format (alphanumeric, underscore, dash, at least 8 characters for reasonable uniqueness)
 	if !regexp.MustCompile(`^[a-zA-Z0-9_-]{8,}$`).MatchString(identifier) {
-		return nil, fmt.Errorf("invalid boundary identifier format: %s (must be at least 8 characters using alphanumeric, underscore, or dash)", identifier)
+		return nil, fmt.Errorf("%w: identifier %s must be at least 8 characters using alphanumeric, underscore, or dash", ErrInvalidBoundary, identifier)
 	}
 
-	// Split by boundary markers
-	boundaryPattern := fmt.Sprintf(`--====DELTAGRAM_%s====`, identifier)
-	parts := strings.Split(content, boundaryPattern)
+	var repairs []Repair
+	if p.opts.Repair {
+		content, repairs = repairBoundaries(content, identifier)
+	}
 
-	if len(parts) < 2 {
+	// Split on lines that exactly match this deltagram's boundary, so boundary-like text
+	// inside content (such as another deltagram's boundary) is left alone
+	preamble, parts, err := splitParts(content, identifier)
+	if err != nil {
+		return nil, err
+	}
+	if parts == nil {
 		return nil, fmt.Errorf("invalid deltagram format: no parts found")
 	}
 
-	// Remove empty first part (before first boundary)
-	if strings.TrimSpace(parts[0]) == "" {
-		parts = parts[1:]
+	// The text before the first boundary is the envelope; it may carry a version header
+	version, err := parseEnvelope(preamble)
+	if err != nil {
+		return nil, err
 	}
 
 	deltagram := &Deltagram{
-		UUID:  identifier,
-		Parts: make([]DeltagramPart, 0),
+		UUID:    identifier,
+		Version: version,
+		Parts:   make([]DeltagramPart, 0),
+		Repairs: repairs,
 	}
 
 	for i, part := range parts {
-		// Check if this is the final boundary (ends with --)
-		if strings.HasSuffix(strings.TrimSpace(part), "--") {
-			// Remove the trailing -- and process if there's content
-			part = strings.TrimSuffix(strings.TrimSpace(part), "--")
-			if strings.TrimSpace(part) == "" {
-				break // Final boundary with no content
-			}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if !part.sized && strings.TrimSpace(part.text) == "" {
+			continue // Empty part, such as a stray boundary before the final one
 		}
 
-		parsedPart, err := p.parsePart(part)
+		parsedPart, err := p.parsePart(part, &deltagram.Repairs)
 		if err != nil {
-			return nil, fmt.Errorf("error parsing part %d: %v", i+1, err)
+			return nil, &PartError{Index: i + 1, Line: part.line, Err: err}
+		}
+		parsedPart.Line = part.line
+		if parsedPart.IsPlainMessage() && deltagram.Metadata == nil {
+			// The first message part describes the deltagram
+			if deltagram.Metadata, err = parseMetadata(parsedPart); err != nil {
+				return nil, &PartError{Index: i + 1, Line: part.line, Err: err}
+			}
 		}
 
 		deltagram.Parts = append(deltagram.Parts, *parsedPart)
@@ -73,29 +131,258 @@ func (p *DefaultParser) Parse(content string) (*Deltagram, error) {
 	return deltagram, nil
 }
 
-func (p *DefaultParser) parsePart(partContent string) (*DeltagramPart, error) {
-	// Trim leading/trailing whitespace
-	partContent = strings.TrimSpace(partContent)
-	lines := strings.Split(partContent, "\n")
+// rawPart is the unparsed text of a single part. When the part declares a
+// Content-Length, text holds only its headers and body holds the exact content.
+type rawPart struct {
+	text  string
+	body  string
+	sized bool
+	line  int // 1-based line of the first header, 0 for a part with no text
+}
 
-	var contentLocation, contentType, deltaOperation string
-	var contentStartIndex int
+// splitParts divides the content at boundary lines for the given identifier, returning
+// the preamble before the first boundary and each part. Parts with a Content-Length
+// header take their content by byte count, so boundary-like lines inside it are never
+// split on. Everything after the final boundary is ignored as an epilogue. A nil slice
+// means no boundary was found.
+func splitParts(content, identifier string) (string, []rawPart, error) {
+	open := "--====DELTAGRAM_" + identifier + "===="
+	final := open + "--"
 
-	// Parse headers
-	for i, line := range lines {
+	var preamble []string
+	var parts []rawPart
+	var current []string
+	started := false
+	inHeaders := false
+	length := -1
+	lineNo, partLine := 0, 0
+
+	for pos := 0; pos < len(content); {
+		line := content[pos:]
+		next := len(content)
+		if end := strings.IndexByte(line, '\n'); end >= 0 {
+			line = line[:end]
+			next = pos + end + 1
+		}
+		pos = next
+		lineNo++
+
+		trimmed := strings.TrimRight(line, " \t")
+		if trimmed == open || trimmed == final {
+			if started {
+				parts = append(parts, rawPart{text: strings.Join(current, "\n"), line: partLine})
+			}
+			started = true
+			inHeaders = true
+			length = -1
+			current = nil
+			partLine = 0
+			if trimmed == final {
+				return strings.Join(preamble, "\n"), parts, nil
+			}
+			if parts == nil {
+				parts = []rawPart{}
+			}
+			continue
+		}
+
+		if !started {
+			preamble = append(preamble, line)
+			continue
+		}
+
+		if inHeaders {
+			if strings.TrimSpace(line) == "" {
+				if len(current) == 0 {
+					continue // Leading blank lines before the headers
+				}
+				inHeaders = false
+				if length >= 0 {
+					body, rest, err := sliceContent(content, pos, length, open, final)
+					if err != nil {
+						return "", nil, &PartError{Index: len(parts) + 1, Line: partLine, Err: err}
+					}
+					parts = append(parts, rawPart{text: strings.Join(current, "\n"), body: body, sized: true, line: partLine})
+					current = nil
+					started = false
+					lineNo += strings.Count(content[pos:rest], "\n")
+					pos = rest
+					continue
+				}
+			} else if line[0] != ' ' && line[0] != '\t' {
+				if matches := headerRegex.FindStringSubmatch(strings.TrimSpace(line)); matches != nil && strings.EqualFold(matches[1], "Content-Length") {
+					n, err := strconv.Atoi(strings.TrimSpace(matches[2]))
+					if err != nil || n < 0 {
+						return "", nil, &PartError{Index: len(parts) + 1, Line: partLine, Err: fmt.Errorf("invalid Content-Length header: %q", strings.TrimSpace(matches[2]))}
+					}
+					length = n
+				}
+			}
+		}
+		if len(current) == 0 {
+			partLine = lineNo
+		}
+		current = append(current, line)
+	}
+
+	// Tolerate a missing final boundary by treating the trailing text as the last part
+	if started {
+		parts = append(parts, rawPart{text: strings.Join(current, "\n"), line: partLine})
+	}
+	return strings.Join(preamble, "\n"), parts, nil
+}
+
+// sliceContent takes length bytes of content starting at pos and checks that a boundary
+// follows, returning the content and the offset of that boundary line
+func sliceContent(content string, pos, length int, open, final string) (string, int, error) {
+	if pos+length > len(content) {
+		return "", 0, fmt.Errorf("Content-Length %d exceeds the remaining %d bytes", length, len(content)-pos)
+	}
+	body := content[pos : pos+length]
+	rest := pos + length
+
+	// The boundary may follow directly or on the line after the content
+	isBoundary := func(at int) bool {
+		line := content[at:]
+		if end := strings.IndexByte(line, '\n'); end >= 0 {
+			line = line[:end]
+		}
+		line = strings.TrimRight(line, " \t")
+		return line == open || line == final
+	}
+	switch {
+	case rest == len(content) || isBoundary(rest):
+	case content[rest] == '\n' && (rest+1 == len(content) || isBoundary(rest+1)):
+		rest++
+	default:
+		return "", 0, fmt.Errorf("Content-Length %d does not end at a boundary", length)
+	}
+	return body, rest, nil
+}
+
+// parseEnvelope reads the Deltagram-Version header from the text preceding the first
+// boundary, ignoring any other preamble text as MIME does
+func parseEnvelope(preamble string) (int, error) {
+	for _, line := range strings.Split(preamble, "\n") {
 		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "Deltagram-Version:") {
+			continue
+		}
+
+		value := strings.TrimSpace(strings.TrimPrefix(line, "Deltagram-Version:"))
+		version, err := strconv.Atoi(value)
+		if err != nil || version < 1 {
+			return 0, fmt.Errorf("invalid Deltagram-Version header: %q", value)
+		}
+		if version > CurrentVersion {
+			return 0, fmt.Errorf("unsupported deltagram version %d (this build supports up to version %d; please upgrade deltagram)", version, CurrentVersion)
+		}
+		return version, nil
+	}
+	return 0, nil
+}
 
-		if line == "" {
+// headerField is a single unfolded header
+type headerField struct {
+	name  string
+	value string
+}
+
+// unfoldHeaders reads the header block at the start of a part, joining folded
+// continuation lines, and returns the index of the first content line. Lines starting
+// with whitespace continue the previous header (RFC 822 folding). A Content-Location
+// wrapped onto an unindented line that cannot be a header is rejoined as well.
+func unfoldHeaders(lines []string) ([]headerField, int) {
+	var fields []headerField
+	// Without a blank line the whole part is headers and the content is empty
+	contentStartIndex := len(lines)
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
 			contentStartIndex = i + 1
 			break
 		}
 
-		if strings.HasPrefix(line, "Content-Location:") {
-			contentLocation = strings.TrimSpace(strings.TrimPrefix(line, "Content-Location:"))
-		} else if strings.HasPrefix(line, "Content-Type:") {
-			contentType = strings.TrimSpace(strings.TrimPrefix(line, "Content-Type:"))
-		} else if strings.HasPrefix(line, "Delta-Operation:") {
-			deltaOperation = strings.TrimSpace(strings.TrimPrefix(line, "Delta-Operation:"))
+		folded := line[0] == ' ' || line[0] == '\t'
+		if len(fields) > 0 {
+			last := &fields[len(fields)-1]
+			if last.name == "Content-Location" && (folded || isWrappedPath(trimmed)) {
+				// Paths are wrapped mid-token, so no separator is reinserted
+				last.value += trimmed
+				continue
+			}
+			if folded {
+				last.value = strings.TrimSpace(last.value + " " + trimmed)
+				continue
+			}
+		}
+
+		if matches := headerRegex.FindStringSubmatch(trimmed); matches != nil {
+			fields = append(fields, headerField{name: matches[1], value: strings.TrimSpace(matches[2])})
+		}
+	}
+
+	return fields, contentStartIndex
+}
+
+// isWrappedPath reports whether an unindented header-block line looks like the rest of
+// a wrapped path rather than a header or the start of content
+func isWrappedPath(line string) bool {
+	if headerRegex.MatchString(line) || strings.ContainsAny(line, " \t") {
+		return false
+	}
+	return !strings.HasPrefix(line, "+") && !strings.HasPrefix(line, "-") && !strings.HasPrefix(line, "@")
+}
+
+// trimLeadingBlankLines removes the lines holding only whitespace from the start of text
+func trimLeadingBlankLines(text string) string {
+	for {
+		line, rest, found := strings.Cut(text, "\n")
+		if !found || strings.TrimSpace(line) != "" {
+			return text
+		}
+		text = rest
+	}
+}
+
+// parsePart parses the headers and content of a part, adding any repairs it makes in
+// repair mode to repairs
+func (p *DefaultParser) parsePart(raw rawPart, repairs *[]Repair) (*DeltagramPart, error) {
+	// The line break before the boundary was already dropped, so only blank lines before
+	// the headers and the carriage return of a CRLF break remain to trim; blank lines that
+	// end the content are kept
+	partContent := strings.TrimSuffix(trimLeadingBlankLines(raw.text), "\r")
+	lines := strings.Split(partContent, "\n")
+
+	var contentLocation, contentType, deltaOperation string
+	var headers map[string]string
+
+	fields, contentStartIndex := unfoldHeaders(lines)
+	if p.opts.Repair && !raw.sized {
+		if start, ok := missingBlankLine(lines, contentStartIndex); ok {
+			fields, _ = unfoldHeaders(lines[:start])
+			contentStartIndex = start
+			*repairs = append(*repairs, Repair{Line: raw.line + start,
+				Message: "added the missing blank line between headers and content"})
+		}
+	}
+	for _, field := range fields {
+		switch field.name {
+		case "Content-Location":
+			contentLocation = field.value
+		case "Content-Type":
+			contentType = field.value
+		case "Delta-Operation":
+			deltaOperation = field.value
+		case "Content-Length":
+			// Already used to delimit the content
+		default:
+			// Keep unrecognized headers so extensions can round-trip custom metadata
+			if headers == nil {
+				headers = make(map[string]string)
+			}
+			headers[field.name] = field.value
 		}
 	}
 
@@ -107,8 +394,28 @@ func (p *DefaultParser) parsePart(partContent string) (*DeltagramPart, error) {
 		return nil, fmt.Errorf("missing Content-Type header")
 	}
 
-	// For message parts, Delta-Operation is optional
-	isMessage := contentLocation == "deltagram://message"
+	// For message and note parts, Delta-Operation is optional
+	isMessage := contentLocation == "deltagram://message" || contentLocation == RequireLocation ||
+		contentLocation == RunLocation || strings.HasPrefix(contentLocation, NotePrefix)
+	if strings.HasPrefix(contentLocation, "deltagram://") && !isMessage {
+		return nil, fmt.Errorf("unknown location %s: only deltagram://message, %s, %s, and %s<path> are defined",
+			contentLocation, RequireLocation, RunLocation, NotePrefix)
+	}
+	if contentLocation == NotePrefix {
+		return nil, fmt.Errorf("note location %s names no file", contentLocation)
+	}
+	if !isMessage && deltaOperation != "" {
+		if canonical, ok := CanonicalOperation(deltaOperation); ok {
+			if p.opts.StrictOperations {
+				return nil, fmt.Errorf("Delta-Operation %q is not a valid operation; use %q", deltaOperation, canonical)
+			}
+			if p.opts.Repair {
+				*repairs = append(*repairs, Repair{Line: raw.line,
+					Message: fmt.Sprintf("read Delta-Operation %q as %q", deltaOperation, canonical)})
+			}
+			deltaOperation = canonical
+		}
+	}
 	if !isMessage && deltaOperation == "" {
 		// Default to CREATE for backward compatibility
 		deltaOperation = "create"
@@ -116,8 +423,11 @@ func (p *DefaultParser) parsePart(partContent string) (*DeltagramPart, error) {
 
 	// Extract content
 	var content string
-	if contentStartIndex < len(lines) {
-		content = strings.Join(lines[contentStartIndex:], "\n")
+	if raw.sized {
+		content = raw.body
+	} else if contentStartIndex < len(lines) {
+		// Content delimited by boundaries may escape lines that would be read as one
+		content = UnescapeContent(strings.Join(lines[contentStartIndex:], "\n"))
 	}
 
 	return &DeltagramPart{
@@ -125,5 +435,6 @@ func (p *DefaultParser) parsePart(partContent string) (*DeltagramPart, error) {
 		ContentType:     contentType,
 		DeltaOperation:  deltaOperation,
 		Content:         content,
+		Headers:         headers,
 	}, nil
 }
@@ -1,6 +1,9 @@
 package parser
 
 import (
+	"context"
+	"errors"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -135,8 +138,8 @@ Hello, World!
 				if err == nil {
 					t.Errorf("Expected error for invalid identifier '%s', got none", test.identifier)
 				}
-				if !strings.Contains(err.Error(), "invalid boundary identifier format") && !strings.Contains(err.Error(), "missing or malformed boundary") {
-					t.Errorf("Expected identifier format or boundary error, got: %v", err)
+				if !errors.Is(err, ErrInvalidBoundary) {
+					t.Errorf("Expected ErrInvalidBoundary, got: %v", err)
 				}
 			}
 		})
@@ -176,4 +179,465 @@ Hello, World!`
 	if !strings.Contains(err.Error(), "missing or malformed boundary") {
 		t.Errorf("Expected boundary error, got: %v", err)
 	}
+	if !errors.Is(err, ErrInvalidBoundary) {
+		t.Errorf("Expected ErrInvalidBoundary, got: %v", err)
+	}
+}
+
+func TestParser_ParseContext_Canceled(t *testing.T) {
+	content := `--====DELTAGRAM_0123456789abcdef====
+Content-Location: test/file.txt
+Content-Type: text/plain; charset=utf-8; linesep=LF
+
+Hello, World!
+--====DELTAGRAM_0123456789abcdef====--`
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := NewParser().ParseContext(ctx, content)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got: %v", err)
+	}
+
+	if _, err := NewParser().ParseContext(context.Background(), content); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+}
+
+func TestParser_Parse_Version(t *testing.T) {
+	tests := []struct {
+		name     string
+		envelope string
+		version  int
+		errMsg   string
+	}{
+		{name: "no envelope", envelope: "", version: 0},
+		{name: "version 1", envelope: "Deltagram-Version: 1\n", version: 1},
+		{name: "preamble text is ignored", envelope: "Here is your deltagram:\nDeltagram-Version: 1\n\n", version: 1},
+		{name: "future version", envelope: "Deltagram-Version: 99\n", errMsg: "unsupported deltagram version 99"},
+		{name: "malformed version", envelope: "Deltagram-Version: one\n", errMsg: "invalid Deltagram-Version header"},
+	}
+
+	parser := NewParser()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			content := test.envelope + `--====DELTAGRAM_0123456789abcdef====
+Content-Location: test/file.txt
+Content-Type: text/plain; charset=utf-8; linesep=LF
+
+Hello, World!
+--====DELTAGRAM_0123456789abcdef====--`
+
+			deltagram, err := parser.Parse(content)
+			if test.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), test.errMsg) {
+					t.Fatalf("Expected error containing %q, got: %v", test.errMsg, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if deltagram.Version != test.version {
+				t.Errorf("Expected version %d, got %d", test.version, deltagram.Version)
+			}
+			if deltagram.EffectiveVersion() != 1 || !deltagram.AtLeast(1) || deltagram.AtLeast(2) {
+				t.Errorf("Expected effective version 1, got %d", deltagram.EffectiveVersion())
+			}
+			if len(deltagram.Parts) != 1 {
+				t.Errorf("Expected 1 part, got %d", len(deltagram.Parts))
+			}
+		})
+	}
+}
+
+func TestParser_Parse_UnknownHeaders(t *testing.T) {
+	parser := NewParser()
+
+	content := `--====DELTAGRAM_0123456789abcdef====
+Content-Location: test/file.txt
+Content-Type: text/plain; charset=utf-8; linesep=LF
+Delta-Operation: create
+X-Checksum: sha256:abc123
+File-Mode: 0755
+
++++ test/file.txt
+Hello, World!
+--====DELTAGRAM_0123456789abcdef====--`
+
+	deltagram, err := parser.Parse(content)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	part := deltagram.Parts[0]
+	if len(part.Headers) != 2 {
+		t.Errorf("Expected 2 unrecognized headers, got %v", part.Headers)
+	}
+	if part.Headers["X-Checksum"] != "sha256:abc123" {
+		t.Errorf("Expected X-Checksum header, got %q", part.Headers["X-Checksum"])
+	}
+	if value, ok := part.Header("file-mode"); !ok || value != "0755" {
+		t.Errorf("Expected case-insensitive lookup of File-Mode, got %q (%v)", value, ok)
+	}
+	if _, ok := part.Header("Content-Type"); ok {
+		t.Error("Expected recognized headers not to be stored in Headers")
+	}
+}
+
+func TestParser_Parse_FoldedHeaders(t *testing.T) {
+	parser := NewParser()
+
+	content := "--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: src/components/very/deeply/nested/\n" +
+		"  directory/structure/Component.tsx\n" +
+		"Content-Type: application/x-deltagram-content;\n" +
+		"\tcharset=utf-8; linesep=LF\n" +
+		"Delta-Operation: content\n" +
+		"X-Description: a long description\n" +
+		" that continues here\n" +
+		"\n" +
+		"@@ -1,1 +1,1 @@\n" +
+		"-old\n" +
+		"+new\n" +
+		"--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: src/another/long/path/that/the/model/wrapped/\n" +
+		"without-indentation.go\n" +
+		"Content-Type: text/plain\n" +
+		"Delta-Operation: delete\n" +
+		"--====DELTAGRAM_0123456789abcdef====--"
+
+	deltagram, err := parser.Parse(content)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	part := deltagram.Parts[0]
+	if part.ContentLocation != "src/components/very/deeply/nested/directory/structure/Component.tsx" {
+		t.Errorf("Expected unfolded Content-Location, got %q", part.ContentLocation)
+	}
+	if part.ContentType != "application/x-deltagram-content; charset=utf-8; linesep=LF" {
+		t.Errorf("Expected unfolded Content-Type, got %q", part.ContentType)
+	}
+	if part.Headers["X-Description"] != "a long description that continues here" {
+		t.Errorf("Expected unfolded custom header, got %q", part.Headers["X-Description"])
+	}
+	if part.Content != "@@ -1,1 +1,1 @@\n-old\n+new" {
+		t.Errorf("Expected content to be unaffected, got %q", part.Content)
+	}
+
+	if deltagram.Parts[1].ContentLocation != "src/another/long/path/that/the/model/wrapped/without-indentation.go" {
+		t.Errorf("Expected wrapped Content-Location to be rejoined, got %q", deltagram.Parts[1].ContentLocation)
+	}
+}
+
+func TestParser_Parse_NestedBoundaries(t *testing.T) {
+	parser := NewParser()
+	content := "--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: docs/example.md\n" +
+		"Content-Type: text/plain\n" +
+		"Delta-Operation: create\n" +
+		"\n" +
+		"--- /dev/null\n" +
+		"+++ docs/example.md\n" +
+		"+--====DELTAGRAM_exampleboundary====\n" +
+		"+Inline marker --====DELTAGRAM_0123456789abcdef==== stays put\n" +
+		"+--====DELTAGRAM_exampleboundary====--\n" +
+		"--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: src/b.go\n" +
+		"Content-Type: text/plain\n" +
+		"Delta-Operation: delete\n" +
+		"--====DELTAGRAM_0123456789abcdef====--\n" +
+		"epilogue text that is ignored\n" +
+		"--====DELTAGRAM_0123456789abcdef====\n"
+
+	deltagram, err := parser.Parse(content)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(deltagram.Parts) != 2 {
+		t.Fatalf("Expected 2 parts, got %d", len(deltagram.Parts))
+	}
+	if !strings.Contains(deltagram.Parts[0].Content, "+--====DELTAGRAM_exampleboundary====--") {
+		t.Errorf("Expected foreign boundary to remain in content, got %q", deltagram.Parts[0].Content)
+	}
+	if !strings.Contains(deltagram.Parts[0].Content, "Inline marker --====DELTAGRAM_0123456789abcdef==== stays put") {
+		t.Errorf("Expected mid-line boundary to remain in content, got %q", deltagram.Parts[0].Content)
+	}
+	if deltagram.Parts[1].ContentLocation != "src/b.go" {
+		t.Errorf("Expected second part src/b.go, got %q", deltagram.Parts[1].ContentLocation)
+	}
+}
+
+func TestParser_Parse_ContentLength(t *testing.T) {
+	parser := NewParser()
+	body := "+line one\n--====DELTAGRAM_0123456789abcdef====\n+trailing spaces   \n"
+	content := "--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: notes.txt\n" +
+		"Content-Type: text/plain\n" +
+		"Delta-Operation: create\n" +
+		"Content-Length: " + strconv.Itoa(len(body)) + "\n" +
+		"\n" +
+		body +
+		"--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: src/b.go\n" +
+		"Content-Type: text/plain\n" +
+		"Delta-Operation: delete\n" +
+		"--====DELTAGRAM_0123456789abcdef====--"
+
+	deltagram, err := parser.Parse(content)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(deltagram.Parts) != 2 {
+		t.Fatalf("Expected 2 parts, got %d", len(deltagram.Parts))
+	}
+	if deltagram.Parts[0].Content != body {
+		t.Errorf("Expected exact content %q, got %q", body, deltagram.Parts[0].Content)
+	}
+	if _, ok := deltagram.Parts[0].Headers["Content-Length"]; ok {
+		t.Errorf("Expected Content-Length not to be kept as a custom header")
+	}
+	if deltagram.Parts[1].ContentLocation != "src/b.go" {
+		t.Errorf("Expected second part src/b.go, got %q", deltagram.Parts[1].ContentLocation)
+	}
+}
+
+func TestParser_Parse_ContentLengthErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		length string
+		errMsg string
+	}{
+		{"invalid", "abc", "invalid Content-Length header"},
+		{"too short", "3", "does not end at a boundary"},
+		{"too long", "500", "exceeds the remaining"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := "--====DELTAGRAM_0123456789abcdef====\n" +
+				"Content-Location: notes.txt\n" +
+				"Content-Type: text/plain\n" +
+				"Content-Length: " + tt.length + "\n" +
+				"\n" +
+				"+hello\n" +
+				"--====DELTAGRAM_0123456789abcdef====--"
+
+			_, err := NewParser().Parse(content)
+			if err == nil {
+				t.Fatalf("Expected error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Expected error containing %q, got: %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestParser_Parse_PartLines(t *testing.T) {
+	content := "Deltagram-Version: 1\n" +
+		"--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: deltagram://message\n" +
+		"Content-Type: text/plain\n" +
+		"\n" +
+		"Summary\n" +
+		"--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: sized.txt\n" +
+		"Content-Type: text/plain\n" +
+		"Content-Length: 4\n" +
+		"\n" +
+		"a\nb\n" +
+		"--====DELTAGRAM_0123456789abcdef====\n" +
+		"\n" +
+		"Content-Location: last.txt\n" +
+		"Content-Type: text/plain\n" +
+		"\n" +
+		"+++ last.txt\n" +
+		"--====DELTAGRAM_0123456789abcdef====--\n"
+
+	deltagram, err := NewParser().Parse(content)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	expected := []int{3, 8, 16}
+	if len(deltagram.Parts) != len(expected) {
+		t.Fatalf("Expected %d parts, got %d", len(expected), len(deltagram.Parts))
+	}
+	for i, line := range expected {
+		if deltagram.Parts[i].Line != line {
+			t.Errorf("Part %d: expected line %d, got %d", i+1, line, deltagram.Parts[i].Line)
+		}
+	}
+}
+
+func TestParser_Parse_PartError(t *testing.T) {
+	content := "--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: ok.txt\n" +
+		"Content-Type: text/plain\n" +
+		"\n" +
+		"ok\n" +
+		"--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: broken.txt\n" +
+		"\n" +
+		"no type\n" +
+		"--====DELTAGRAM_0123456789abcdef====--\n"
+
+	_, err := NewParser().Parse(content)
+	var partErr *PartError
+	if !errors.As(err, &partErr) {
+		t.Fatalf("Expected PartError, got: %v", err)
+	}
+	if partErr.Index != 2 || partErr.Line != 7 {
+		t.Errorf("Expected part 2 at line 7, got part %d at line %d", partErr.Index, partErr.Line)
+	}
+	if !strings.Contains(err.Error(), "error in part 2 (starting at line 7): missing Content-Type header") {
+		t.Errorf("Unexpected error message: %v", err)
+	}
+}
+
+func TestParser_Parse_EmptyBody(t *testing.T) {
+	content := "--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: obsolete.txt\n" +
+		"Content-Type: text/plain\n" +
+		"Delta-Operation: delete\n" +
+		"\n" +
+		"--====DELTAGRAM_0123456789abcdef====--\n"
+
+	deltagram, err := NewParser().Parse(content)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if deltagram.Parts[0].Content != "" {
+		t.Errorf("Expected empty content, got %q", deltagram.Parts[0].Content)
+	}
+}
+
+func TestParser_Parse_TrailingBlankLines(t *testing.T) {
+	// Clipboard text is passed through untrimmed, so a final part without a closing
+	// boundary keeps the blank lines its Content-Length counts
+	body := "+hello\n\n\n"
+	content := "\n--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: notes.txt\n" +
+		"Content-Type: text/plain\n" +
+		"Delta-Operation: create\n" +
+		"Content-Length: " + strconv.Itoa(len(body)) + "\n" +
+		"\n" +
+		body
+
+	deltagram, err := NewParser().Parse(content)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(deltagram.Parts) != 1 {
+		t.Fatalf("Expected 1 part, got %d", len(deltagram.Parts))
+	}
+	if deltagram.Parts[0].Content != body {
+		t.Errorf("Expected exact content %q, got %q", body, deltagram.Parts[0].Content)
+	}
+
+	if _, err := NewParser().Parse(strings.TrimSpace(content)); err == nil {
+		t.Errorf("Expected trimmed content to fail the Content-Length check")
+	}
+}
+
+func TestParser_Parse_Notes(t *testing.T) {
+	content := "--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: deltagram://note/src/foo.go\n" +
+		"Content-Type: text/plain\n" +
+		"\n" +
+		"Renamed the helper for clarity\n" +
+		"--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: deltagram://message\n" +
+		"Content-Type: text/plain\n" +
+		"\n" +
+		"Tidy foo\n" +
+		"--====DELTAGRAM_0123456789abcdef====--"
+
+	deltagram, err := NewParser().Parse(content)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	note := deltagram.Parts[0]
+	if !note.IsNote() || !note.IsMessage() || note.NoteTarget() != "src/foo.go" || note.DeltaOperation != "" {
+		t.Errorf("Expected a note on src/foo.go without an operation, got %+v", note)
+	}
+	if deltagram.Parts[1].IsNote() {
+		t.Errorf("Expected the message part not to be a note")
+	}
+	if deltagram.Metadata == nil || deltagram.Metadata.Description != "Tidy foo" {
+		t.Errorf("Expected metadata from the message part rather than the note, got %+v", deltagram.Metadata)
+	}
+}
+
+func TestParser_Parse_UnknownDeltagramLocation(t *testing.T) {
+	tests := []struct {
+		name     string
+		location string
+		errMsg   string
+	}{
+		{"unknown scheme path", "deltagram://review/src/foo.go", "unknown location"},
+		{"note without path", "deltagram://note/", "names no file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := "--====DELTAGRAM_0123456789abcdef====\n" +
+				"Content-Location: " + tt.location + "\n" +
+				"Content-Type: text/plain\n" +
+				"\n" +
+				"text\n" +
+				"--====DELTAGRAM_0123456789abcdef====--"
+
+			_, err := NewParser().Parse(content)
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Expected error containing %q, got: %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestParser_Parse_TrailingBlankLinesWithoutContentLength(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{"blank lines", "+++ a.txt\nline\n\n\n", "+++ a.txt\nline\n\n"},
+		{"CRLF blank lines", "+++ a.txt\r\nline\r\n\r\n\r\n", "+++ a.txt\nline\n\n"},
+		{"no blank lines", "+++ a.txt\nline\n", "+++ a.txt\nline"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Blank lines before the headers are still skipped
+			content := "--====DELTAGRAM_0123456789abcdef====\n\n\n" +
+				"Content-Location: a.txt\n" +
+				"Content-Type: text/plain\n" +
+				"Delta-Operation: create\n" +
+				"\n" +
+				tt.body +
+				"--====DELTAGRAM_0123456789abcdef====--\n"
+
+			deltagram, err := NewParser().Parse(content)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if len(deltagram.Parts) != 1 {
+				t.Fatalf("Expected 1 part, got %d", len(deltagram.Parts))
+			}
+			if deltagram.Parts[0].ContentLocation != "a.txt" {
+				t.Errorf("Expected Content-Location a.txt, got %q", deltagram.Parts[0].ContentLocation)
+			}
+			if deltagram.Parts[0].Content != tt.expected {
+				t.Errorf("Expected content %q, got %q", tt.expected, deltagram.Parts[0].Content)
+			}
+		})
+	}
 }
@@ -0,0 +1,67 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Repair is a formatting mistake that a parser in repair mode fixed
+type Repair struct {
+	Line    int    `json:"line,omitempty"` // 1-based line of the deltagram text, 0 if unknown
+	Message string `json:"message"`
+}
+
+// missingBlankLine finds where the content of a part starts when its headers run into
+// it without a blank line: at the first line of the header block, which ends at index
+// end, that is neither a header nor a continuation of one
+func missingBlankLine(lines []string, end int) (int, bool) {
+	last := ""
+	for i := 0; i < end && i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			break
+		}
+		if last != "" && (line[0] == ' ' || line[0] == '\t') {
+			continue
+		}
+		if last == "Content-Location" && isWrappedPath(trimmed) {
+			continue
+		}
+		if matches := headerRegex.FindStringSubmatch(trimmed); matches != nil {
+			last = matches[1]
+			continue
+		}
+		return i, true
+	}
+	return 0, false
+}
+
+// repairBoundaries rewrites boundary lines whose identifier differs from the deltagram's
+// to use its identifier, when no other boundary line shares theirs. A deltagram nested in
+// the content has at least an opening and a closing boundary, so a lone identifier is
+// taken to be a mistyped marker of this deltagram.
+func repairBoundaries(content, identifier string) (string, []Repair) {
+	lines := strings.Split(content, "\n")
+	counts := make(map[string]int)
+	for _, line := range lines {
+		if matches := boundaryLineRegex.FindStringSubmatch(strings.TrimRight(line, " \t")); matches != nil {
+			counts[matches[1]]++
+		}
+	}
+
+	var repairs []Repair
+	for i, line := range lines {
+		matches := boundaryLineRegex.FindStringSubmatch(strings.TrimRight(line, " \t"))
+		if matches == nil || matches[1] == identifier || counts[matches[1]] > 1 {
+			continue
+		}
+		lines[i] = strings.Replace(strings.TrimRight(line, " \t"), matches[1], identifier, 1)
+		repairs = append(repairs, Repair{Line: i + 1,
+			Message: fmt.Sprintf("boundary identifier %s does not match %s; treated it as %s", matches[1], identifier, identifier)})
+	}
+	if repairs == nil {
+		return content, nil
+	}
+	return strings.Join(lines, "\n"), repairs
+}
@@ -0,0 +1,114 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParser_Parse_Repair(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		operation string
+		body      string
+		repair    string
+	}{
+		{
+			name: "missing blank line",
+			content: "--====DELTAGRAM_0123456789abcdef====\n" +
+				"Content-Location: a.txt\n" +
+				"Content-Type: text/plain\n" +
+				"Delta-Operation: content\n" +
+				"@@ -1,1 +1,1 @@\n" +
+				"-old\n" +
+				"+new\n" +
+				"--====DELTAGRAM_0123456789abcdef====--",
+			operation: "content",
+			body:      "@@ -1,1 +1,1 @@\n-old\n+new",
+			repair:    "line 5: added the missing blank line",
+		},
+		{
+			name: "operation alias",
+			content: "--====DELTAGRAM_0123456789abcdef====\n" +
+				"Content-Location: a.txt\n" +
+				"Content-Type: text/plain\n" +
+				"Delta-Operation: Modify\n" +
+				"\n" +
+				"@@ -1,1 +1,1 @@\n" +
+				"-old\n" +
+				"+new\n" +
+				"--====DELTAGRAM_0123456789abcdef====--",
+			operation: "content",
+			body:      "@@ -1,1 +1,1 @@\n-old\n+new",
+			repair:    `line 2: read Delta-Operation "Modify" as "content"`,
+		},
+		{
+			name: "closing identifier mismatch",
+			content: "--====DELTAGRAM_0123456789abcdef====\n" +
+				"Content-Location: a.txt\n" +
+				"Content-Type: text/plain\n" +
+				"Delta-Operation: create\n" +
+				"\n" +
+				"+++ a.txt\n" +
+				"hello\n" +
+				"--====DELTAGRAM_0123456789abcdeg====--\n" +
+				"trailing chatter",
+			operation: "create",
+			body:      "+++ a.txt\nhello",
+			repair:    "line 8: boundary identifier 0123456789abcdeg does not match",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deltagram, err := NewParserWithOptions(Options{Repair: true}).Parse(tt.content)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if len(deltagram.Parts) != 1 {
+				t.Fatalf("Expected 1 part, got %d", len(deltagram.Parts))
+			}
+			part := deltagram.Parts[0]
+			if part.DeltaOperation != tt.operation || part.Content != tt.body {
+				t.Errorf("Expected %s with %q, got %s with %q", tt.operation, tt.body, part.DeltaOperation, part.Content)
+			}
+			if len(deltagram.Repairs) != 1 {
+				t.Fatalf("Expected 1 repair, got %+v", deltagram.Repairs)
+			}
+			repair := deltagram.Repairs[0]
+			if got := fmt.Sprintf("line %d: %s", repair.Line, repair.Message); !strings.HasPrefix(got, tt.repair) {
+				t.Errorf("Expected repair starting %q, got %q", tt.repair, got)
+			}
+
+			// Without repair mode, and with aliases rejected, the same input is not read as intended
+			strict, err := NewParserWithOptions(Options{StrictOperations: true}).Parse(tt.content)
+			if err == nil && len(strict.Repairs) == 0 && strict.Parts[0].DeltaOperation == tt.operation && strict.Parts[0].Content == tt.body {
+				t.Errorf("Expected strict parsing to differ")
+			}
+		})
+	}
+}
+
+func TestParser_Parse_RepairKeepsNestedDeltagrams(t *testing.T) {
+	content := "--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: example.txt\n" +
+		"Content-Type: text/plain\n" +
+		"Delta-Operation: create\n" +
+		"\n" +
+		"--====DELTAGRAM_nested-example====\n" +
+		"Content-Location: x\n" +
+		"--====DELTAGRAM_nested-example====--\n" +
+		"--====DELTAGRAM_0123456789abcdef====--"
+
+	deltagram, err := NewParserWithOptions(Options{Repair: true}).Parse(content)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(deltagram.Repairs) != 0 {
+		t.Errorf("Expected no repairs, got %+v", deltagram.Repairs)
+	}
+	if !strings.Contains(deltagram.Parts[0].Content, "DELTAGRAM_nested-example====--") {
+		t.Errorf("Expected the nested deltagram to stay in the content, got %q", deltagram.Parts[0].Content)
+	}
+}
@@ -0,0 +1,66 @@
+package parser
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Serialize returns the canonical text of the deltagram, which parses back to the same
+// parts. The deltagram's UUID is used as the boundary identifier and must be valid.
+// Headers are written in a fixed order with unrecognized ones sorted by name. Content
+// that the boundary-delimited form cannot carry exactly, such as content with trailing
+// whitespace, is given a Content-Length; other content has boundary-like lines escaped.
+func Serialize(d *Deltagram) string {
+	open := boundaryPrefix + d.UUID + "===="
+
+	var b strings.Builder
+	if d.Version != 0 {
+		fmt.Fprintf(&b, "Deltagram-Version: %d\n", d.Version)
+	}
+	for _, part := range d.Parts {
+		b.WriteString(open + "\n")
+		writePart(&b, part)
+	}
+	b.WriteString(open + "--\n")
+	return b.String()
+}
+
+// String returns the canonical text of the deltagram; see Serialize
+func (d *Deltagram) String() string {
+	return Serialize(d)
+}
+
+// writePart writes the headers and content of a part, ending with a newline
+func writePart(b *strings.Builder, part DeltagramPart) {
+	fmt.Fprintf(b, "Content-Location: %s\n", part.ContentLocation)
+	fmt.Fprintf(b, "Content-Type: %s\n", part.ContentType)
+	if part.DeltaOperation != "" {
+		fmt.Fprintf(b, "Delta-Operation: %s\n", part.DeltaOperation)
+	}
+
+	names := make([]string, 0, len(part.Headers))
+	for name := range part.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(b, "%s: %s\n", name, part.Headers[name])
+	}
+
+	if part.Content == "" {
+		return
+	}
+	if needsLength(part.Content) {
+		fmt.Fprintf(b, "Content-Length: %d\n\n%s\n", len(part.Content), part.Content)
+		return
+	}
+	fmt.Fprintf(b, "\n%s\n", EscapeContent(part.Content))
+}
+
+// needsLength reports whether content would not survive being delimited by boundaries,
+// because the parser trims whitespace at the end of a part
+func needsLength(content string) bool {
+	return strings.TrimRightFunc(content, unicode.IsSpace) != content
+}
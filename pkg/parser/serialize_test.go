@@ -0,0 +1,112 @@
+package parser
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestSerialize_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name  string
+		parts []DeltagramPart
+	}{
+		{"message and create", []DeltagramPart{
+			{ContentLocation: "deltagram://message", ContentType: "text/plain", Content: "Add a greeting"},
+			{ContentLocation: "hello.txt", ContentType: "text/plain", DeltaOperation: "create", Content: "+++ hello.txt\nHello"},
+		}},
+		{"headers only", []DeltagramPart{
+			{ContentLocation: "old.txt", ContentType: "text/plain", DeltaOperation: "delete"},
+		}},
+		{"trailing whitespace", []DeltagramPart{
+			{ContentLocation: "a.txt", ContentType: "text/plain", DeltaOperation: "create", Content: "+++ a.txt\nline  \n\n"},
+			{ContentLocation: "b.txt", ContentType: "text/plain", DeltaOperation: "delete"},
+		}},
+		{"diff with blank context", []DeltagramPart{
+			{ContentLocation: "a.go", ContentType: "text/x-go", DeltaOperation: "content", Content: "@@ -1,3 +1,3 @@\n func a() {}\n \n-var x = 1\n+var x = 2"},
+		}},
+		{"leading blank lines", []DeltagramPart{
+			{ContentLocation: "a.txt", ContentType: "text/plain", DeltaOperation: "create", Content: "\n\nafter blanks"},
+		}},
+		{"boundary lines", []DeltagramPart{
+			{ContentLocation: "doc.md", ContentType: "text/markdown", DeltaOperation: "create",
+				Content: "--====DELTAGRAM_0123456789abcdef====\n\\--====DELTAGRAM_0123456789abcdef====--\nend"},
+		}},
+		{"custom headers", []DeltagramPart{
+			{ContentLocation: "a.txt", ContentType: "text/plain", DeltaOperation: "create", Content: "x",
+				Headers: map[string]string{"X-Reviewer": "sam", "Author": "kim"}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := &Deltagram{UUID: "0123456789abcdef", Version: 1, Parts: tt.parts}
+			text := Serialize(original)
+
+			parsed, err := NewParser().Parse(text)
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v\n%s", err, text)
+			}
+			assertSameDeltagram(t, original, parsed)
+
+			if again := Serialize(parsed); again != text {
+				t.Errorf("Expected serializing again to be stable, got:\n%s\nwant:\n%s", again, text)
+			}
+		})
+	}
+}
+
+func TestSerialize_Format(t *testing.T) {
+	d := &Deltagram{UUID: "0123456789abcdef", Parts: []DeltagramPart{
+		{ContentLocation: "a.txt", ContentType: "text/plain", DeltaOperation: "create", Content: "+++ a.txt\nhi",
+			Headers: map[string]string{"Z-Last": "2", "A-First": "1"}},
+	}}
+
+	expected := "--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: a.txt\n" +
+		"Content-Type: text/plain\n" +
+		"Delta-Operation: create\n" +
+		"A-First: 1\n" +
+		"Z-Last: 2\n" +
+		"\n" +
+		"+++ a.txt\nhi\n" +
+		"--====DELTAGRAM_0123456789abcdef====--\n"
+	if got := d.String(); got != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestSerialize_Example(t *testing.T) {
+	content, err := os.ReadFile("../../examples/example.deltagram")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	original, err := NewParser().Parse(string(content))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	parsed, err := NewParser().Parse(Serialize(original))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	assertSameDeltagram(t, original, parsed)
+}
+
+// assertSameDeltagram compares deltagrams ignoring where each part started in the text
+func assertSameDeltagram(t *testing.T, expected, got *Deltagram) {
+	t.Helper()
+	if got.UUID != expected.UUID || got.Version != expected.Version {
+		t.Errorf("Expected identifier %s version %d, got %s version %d", expected.UUID, expected.Version, got.UUID, got.Version)
+	}
+	if len(got.Parts) != len(expected.Parts) {
+		t.Fatalf("Expected %d parts, got %d", len(expected.Parts), len(got.Parts))
+	}
+	for i := range expected.Parts {
+		want, part := expected.Parts[i], got.Parts[i]
+		want.Line, part.Line = 0, 0
+		if !reflect.DeepEqual(want, part) {
+			t.Errorf("Part %d: expected %+v, got %+v", i+1, want, part)
+		}
+	}
+}
@@ -1,20 +1,127 @@
 package parser
 
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
 // DeltagramPart represents a single part of a deltagram
 type DeltagramPart struct {
 	ContentLocation string
 	ContentType     string
 	DeltaOperation  string
 	Content         string
+	Headers         map[string]string // Unrecognized headers, preserved for extensions
+	Line            int               // 1-based line of the deltagram text where the part's headers start, 0 if unknown
+}
+
+// Header returns the value of an unrecognized header, matching the name case-insensitively
+func (p *DeltagramPart) Header(name string) (string, bool) {
+	if value, ok := p.Headers[name]; ok {
+		return value, true
+	}
+	for key, value := range p.Headers {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// NotePrefix starts the location of a note part, followed by the path of the file the
+// note is about
+const NotePrefix = "deltagram://note/"
+
+// RequireLocation is the location of a part listing preconditions the target directory
+// must meet before anything is applied
+const RequireLocation = "deltagram://require"
+
+// RunLocation is the location of a part listing commands to run after the deltagram is
+// applied, which the user must allow
+const RunLocation = "deltagram://run"
+
+// IsMessage reports whether the part is a message rather than a file operation. Notes,
+// requirements, and commands to run are messages too.
+func (p *DeltagramPart) IsMessage() bool {
+	return p.IsPlainMessage() || p.IsNote() || p.IsRequirement() || p.IsRun()
 }
 
+// IsPlainMessage reports whether the part is the message describing the deltagram
+func (p *DeltagramPart) IsPlainMessage() bool {
+	return p.ContentLocation == "mimeogram://message" || p.ContentLocation == "deltagram://message"
+}
+
+// IsRequirement reports whether the part lists preconditions of the deltagram
+func (p *DeltagramPart) IsRequirement() bool {
+	return p.ContentLocation == RequireLocation
+}
+
+// IsRun reports whether the part lists commands to run after the deltagram is applied
+func (p *DeltagramPart) IsRun() bool {
+	return p.ContentLocation == RunLocation
+}
+
+// IsNote reports whether the part is a note for reviewers about the change to one file
+func (p *DeltagramPart) IsNote() bool {
+	return strings.HasPrefix(p.ContentLocation, NotePrefix)
+}
+
+// NoteTarget returns the path of the file a note part is about
+func (p *DeltagramPart) NoteTarget() string {
+	return strings.TrimPrefix(p.ContentLocation, NotePrefix)
+}
+
+// PartError is an error in one part of a deltagram, located so that it can be shown
+// next to the deltagram text
+type PartError struct {
+	Index int // 1-based index of the part in the deltagram
+	Line  int // 1-based line of the deltagram text where the part starts, 0 if unknown
+	Err   error
+}
+
+func (e *PartError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("error in part %d (starting at line %d): %v", e.Index, e.Line, e.Err)
+	}
+	return fmt.Sprintf("error in part %d: %v", e.Index, e.Err)
+}
+
+func (e *PartError) Unwrap() error {
+	return e.Err
+}
+
+// CurrentVersion is the newest deltagram format version this package understands
+const CurrentVersion = 1
+
 // Deltagram represents a complete deltagram with all its parts
 type Deltagram struct {
-	UUID  string // Boundary identifier (historically UUID, now more flexible alphanumeric)
-	Parts []DeltagramPart
+	UUID    string // Boundary identifier (historically UUID, now more flexible alphanumeric)
+	Version int    // Format version from the Deltagram-Version envelope header (0 when absent)
+	Parts   []DeltagramPart
+	Repairs []Repair // Mistakes fixed when parsing in repair mode
+	// Metadata describes the deltagram from its first message part, nil when it has none
+	Metadata *Metadata
+}
+
+// EffectiveVersion returns the format version the deltagram should be processed with;
+// deltagrams without a version header are treated as version 1
+func (d *Deltagram) EffectiveVersion() int {
+	if d.Version == 0 {
+		return 1
+	}
+	return d.Version
+}
+
+// AtLeast reports whether the deltagram declares at least the given format version, so
+// that behavior introduced by later versions can be gated
+func (d *Deltagram) AtLeast(version int) bool {
+	return d.EffectiveVersion() >= version
 }
 
 // Parser defines the interface for parsing deltagrams
 type Parser interface {
 	Parse(content string) (*Deltagram, error)
+	// ParseContext is like Parse but stops early with the context's error once ctx is done
+	ParseContext(ctx context.Context, content string) (*Deltagram, error)
 }
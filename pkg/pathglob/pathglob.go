@@ -0,0 +1,108 @@
+package pathglob
+
+import (
+	"errors"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ToRegex converts a slash-separated glob into an unanchored regular expression.
+// `*` and `?` never cross a slash, `**` matches any number of directories, and
+// character classes may be negated with `!`.
+func ToRegex(glob string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case c == '*' && strings.HasPrefix(glob[i:], "**/"):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case c == '*' && strings.HasPrefix(glob[i:], "**") && i+2 == len(glob):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(regexp.QuoteMeta("["))
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + class + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	return b.String()
+}
+
+// Compile converts a glob into a regular expression matching whole paths
+func Compile(glob string) (*regexp.Regexp, error) {
+	return regexp.Compile("^" + ToRegex(strings.TrimSuffix(glob, "/")) + "$")
+}
+
+// Validate returns an error for a glob that Match and MatchPrefix would not read as
+// written: one with a `[` that is never closed, or one that does not compile. Patterns
+// that guard paths should be validated, since a malformed one matches nothing.
+func Validate(glob string) error {
+	for i := 0; i < len(glob); i++ {
+		switch glob[i] {
+		case '\\':
+			i++
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return errors.New("unclosed character class")
+			}
+			i += end + 1
+		}
+	}
+	_, err := Compile(glob)
+	return err
+}
+
+// Match reports whether the slash-separated path matches the glob exactly.
+// Malformed patterns match nothing.
+func Match(glob, name string) bool {
+	re, err := Compile(glob)
+	if err != nil {
+		return false
+	}
+	return re.MatchString(clean(name))
+}
+
+// MatchPrefix reports whether the path or any of its parent directories matches the glob,
+// so that `src` and `src/` both cover everything beneath src
+func MatchPrefix(glob, name string) bool {
+	re, err := Compile(glob)
+	if err != nil {
+		return false
+	}
+
+	name = clean(name)
+	for name != "" && name != "." {
+		if re.MatchString(name) {
+			return true
+		}
+		name = path.Dir(name)
+	}
+	return false
+}
+
+// clean normalizes a path to slash-separated form without leading or trailing slashes
+func clean(name string) string {
+	return strings.Trim(path.Clean("/"+strings.ReplaceAll(name, "\\", "/")), "/")
+}
@@ -0,0 +1,65 @@
+package pathglob
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		glob    string
+		name    string
+		matches bool
+	}{
+		{glob: "*.go", name: "main.go", matches: true},
+		{glob: "*.go", name: "cmd/main.go", matches: false},
+		{glob: "**/*.go", name: "cmd/main.go", matches: true},
+		{glob: "**/*.go", name: "main.go", matches: true},
+		{glob: "src/**", name: "src/a/b.txt", matches: true},
+		{glob: "src/?.txt", name: "src/a.txt", matches: true},
+		{glob: "src/[!a].txt", name: "src/a.txt", matches: false},
+		{glob: "**/*_test.go", name: "pkg/parser/parser_test.go", matches: true},
+	}
+
+	for _, test := range tests {
+		if got := Match(test.glob, test.name); got != test.matches {
+			t.Errorf("Match(%q, %q) = %v, expected %v", test.glob, test.name, got, test.matches)
+		}
+	}
+}
+
+func TestMatchPrefix(t *testing.T) {
+	tests := []struct {
+		glob    string
+		name    string
+		matches bool
+	}{
+		{glob: "src", name: "src/pkg/main.go", matches: true},
+		{glob: "src/", name: "src/main.go", matches: true},
+		{glob: "docs/*.md", name: "docs/guide.md", matches: true},
+		{glob: "src", name: "srcs/main.go", matches: false},
+		{glob: "src", name: "other/src.go", matches: false},
+	}
+
+	for _, test := range tests {
+		if got := MatchPrefix(test.glob, test.name); got != test.matches {
+			t.Errorf("MatchPrefix(%q, %q) = %v, expected %v", test.glob, test.name, got, test.matches)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		glob  string
+		valid bool
+	}{
+		{glob: "src/**/*.go", valid: true},
+		{glob: "[!a-c]?.txt", valid: true},
+		{glob: `\[literal`, valid: true},
+		{glob: "secrets/[abc", valid: false},
+		{glob: "[z-a].txt", valid: false},
+	}
+
+	for _, test := range tests {
+		if err := Validate(test.glob); (err == nil) != test.valid {
+			t.Errorf("Validate(%q) = %v, expected valid %v", test.glob, err, test.valid)
+		}
+	}
+}
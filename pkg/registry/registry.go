@@ -0,0 +1,234 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxDeltagramSize bounds how much a pull may read
+const maxDeltagramSize = 200 << 20
+
+var nameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*(/[A-Za-z0-9][A-Za-z0-9._-]*)*$`)
+
+// Entry describes one pushed version of a named deltagram
+type Entry struct {
+	Version int       `json:"version"`
+	SHA256  string    `json:"sha256"`
+	Created time.Time `json:"created"`
+}
+
+// Index lists every version of a named deltagram
+type Index struct {
+	Versions []Entry `json:"versions"`
+}
+
+// Latest returns the highest version, or false if there are none
+func (i *Index) Latest() (Entry, bool) {
+	var latest Entry
+	for _, entry := range i.Versions {
+		if entry.Version > latest.Version {
+			latest = entry
+		}
+	}
+	return latest, latest.Version > 0
+}
+
+// Find returns the entry for a version
+func (i *Index) Find(version int) (Entry, bool) {
+	for _, entry := range i.Versions {
+		if entry.Version == version {
+			return entry, true
+		}
+	}
+	return Entry{}, false
+}
+
+// Client talks to a deltagram registry. The registry is a plain HTTP store supporting
+// GET and PUT: each name has an index at <endpoint>/<name>/index.json and each version
+// is stored at <endpoint>/<name>/<version>.deltagram, so a static file server with
+// uploads (WebDAV, object storage) can act as a registry.
+type Client struct {
+	Endpoint string
+	// Token is sent as a bearer token when set
+	Token string
+	// HTTP performs the requests; a client with a timeout is used when nil
+	HTTP *http.Client
+}
+
+// Checksum returns the hex SHA-256 of content as recorded in the index
+func Checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// Versions returns the index for name; a name that was never pushed has an empty index
+func (c *Client) Versions(ctx context.Context, name string) (*Index, error) {
+	if err := validateName(name); err != nil {
+		return nil, err
+	}
+
+	data, status, err := c.get(ctx, c.url(name, "index.json"))
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound {
+		return &Index{}, nil
+	}
+
+	var index Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("invalid registry index for %s: %v", name, err)
+	}
+	return &index, nil
+}
+
+// Push uploads content as the next version of name. Pushing content identical to the
+// latest version returns that version without uploading.
+func (c *Client) Push(ctx context.Context, name string, content []byte) (Entry, error) {
+	index, err := c.Versions(ctx, name)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	checksum := Checksum(content)
+	latest, ok := index.Latest()
+	if ok && latest.SHA256 == checksum {
+		return latest, nil
+	}
+
+	entry := Entry{Version: latest.Version + 1, SHA256: checksum, Created: time.Now().UTC()}
+	if err := c.put(ctx, c.url(name, fmt.Sprintf("%d.deltagram", entry.Version)), content); err != nil {
+		return Entry{}, err
+	}
+
+	index.Versions = append(index.Versions, entry)
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return Entry{}, err
+	}
+	if err := c.put(ctx, c.url(name, "index.json"), data); err != nil {
+		return Entry{}, err
+	}
+	return entry, nil
+}
+
+// Pull downloads a version of name, or the latest when version is 0, and verifies its
+// checksum against the index
+func (c *Client) Pull(ctx context.Context, name string, version int) ([]byte, Entry, error) {
+	index, err := c.Versions(ctx, name)
+	if err != nil {
+		return nil, Entry{}, err
+	}
+
+	var entry Entry
+	var ok bool
+	if version == 0 {
+		entry, ok = index.Latest()
+	} else {
+		entry, ok = index.Find(version)
+	}
+	if !ok {
+		if version == 0 {
+			return nil, Entry{}, fmt.Errorf("deltagram %s not found in registry", name)
+		}
+		return nil, Entry{}, fmt.Errorf("deltagram %s has no version %d", name, version)
+	}
+
+	content, status, err := c.get(ctx, c.url(name, fmt.Sprintf("%d.deltagram", entry.Version)))
+	if err != nil {
+		return nil, Entry{}, err
+	}
+	if status == http.StatusNotFound {
+		return nil, Entry{}, fmt.Errorf("deltagram %s version %d is listed but missing from the registry", name, entry.Version)
+	}
+
+	if checksum := Checksum(content); checksum != entry.SHA256 {
+		return nil, Entry{}, fmt.Errorf("checksum mismatch for %s version %d: expected %s, got %s", name, entry.Version, entry.SHA256, checksum)
+	}
+	return content, entry, nil
+}
+
+func validateName(name string) error {
+	if !nameRegex.MatchString(name) || strings.Contains(name, "..") {
+		return fmt.Errorf("invalid deltagram name: %s", name)
+	}
+	return nil
+}
+
+func (c *Client) url(name, file string) string {
+	return strings.TrimSuffix(c.Endpoint, "/") + "/" + name + "/" + file
+}
+
+func (c *Client) client() *http.Client {
+	if c.HTTP != nil {
+		return c.HTTP
+	}
+	return &http.Client{Timeout: 60 * time.Second}
+}
+
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	resp, err := c.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("registry request failed: %w", err)
+	}
+	return resp, nil
+}
+
+// get fetches url, returning the body for 200 responses and only the status for 404
+func (c *Client) get(ctx context.Context, url string) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, resp.StatusCode, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode, fmt.Errorf("registry GET %s: %s", url, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxDeltagramSize+1))
+	if err != nil {
+		return nil, resp.StatusCode, fmt.Errorf("registry GET %s: %v", url, err)
+	}
+	if len(data) > maxDeltagramSize {
+		return nil, resp.StatusCode, fmt.Errorf("registry GET %s: response exceeds %d bytes", url, maxDeltagramSize)
+	}
+	return data, resp.StatusCode, nil
+}
+
+func (c *Client) put(ctx context.Context, url string, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Checksum-Sha256", Checksum(data))
+	resp, err := c.do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("registry PUT %s: %s", url, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newTestRegistry serves an in-memory GET/PUT store and records the last Authorization header
+func newTestRegistry(t *testing.T) (*httptest.Server, map[string][]byte, *string) {
+	var mu sync.Mutex
+	store := make(map[string][]byte)
+	auth := new(string)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		*auth = r.Header.Get("Authorization")
+
+		switch r.Method {
+		case http.MethodGet:
+			data, ok := store[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(data)
+		case http.MethodPut:
+			data, _ := io.ReadAll(r.Body)
+			store[r.URL.Path] = data
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, store, auth
+}
+
+func TestPushPull(t *testing.T) {
+	server, _, auth := newTestRegistry(t)
+	client := &Client{Endpoint: server.URL + "/grams", Token: "secret"}
+
+	first, err := client.Push(context.Background(), "team/migrate-logging", []byte("version one"))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if first.Version != 1 {
+		t.Errorf("Expected version 1, got %d", first.Version)
+	}
+	if *auth != "Bearer secret" {
+		t.Errorf("Expected bearer token to be sent, got %q", *auth)
+	}
+
+	again, err := client.Push(context.Background(), "team/migrate-logging", []byte("version one"))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if again.Version != 1 {
+		t.Errorf("Expected unchanged content to keep version 1, got %d", again.Version)
+	}
+
+	second, err := client.Push(context.Background(), "team/migrate-logging", []byte("version two"))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if second.Version != 2 {
+		t.Errorf("Expected version 2, got %d", second.Version)
+	}
+
+	content, entry, err := client.Pull(context.Background(), "team/migrate-logging", 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if string(content) != "version two" || entry.Version != 2 {
+		t.Errorf("Expected latest version two, got %q (v%d)", content, entry.Version)
+	}
+
+	content, _, err = client.Pull(context.Background(), "team/migrate-logging", 1)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if string(content) != "version one" {
+		t.Errorf("Expected version one, got %q", content)
+	}
+}
+
+func TestPull_ChecksumMismatch(t *testing.T) {
+	server, store, _ := newTestRegistry(t)
+	client := &Client{Endpoint: server.URL}
+
+	if _, err := client.Push(context.Background(), "gram", []byte("original")); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	store["/gram/1.deltagram"] = []byte("tampered")
+
+	_, _, err := client.Pull(context.Background(), "gram", 0)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Expected checksum mismatch error, got: %v", err)
+	}
+}
+
+func TestPull_NotFound(t *testing.T) {
+	server, _, _ := newTestRegistry(t)
+	client := &Client{Endpoint: server.URL}
+
+	tests := []struct {
+		name    string
+		gram    string
+		version int
+		errMsg  string
+	}{
+		{"unknown name", "missing", 0, "not found"},
+		{"invalid name", "../escape", 0, "invalid deltagram name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := client.Pull(context.Background(), tt.gram, tt.version)
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Expected error containing %q, got: %v", tt.errMsg, err)
+			}
+		})
+	}
+
+	if _, err := client.Push(context.Background(), "gram", []byte("x")); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, _, err := client.Pull(context.Background(), "gram", 5); err == nil || !strings.Contains(err.Error(), "no version 5") {
+		t.Errorf("Expected missing version error, got: %v", err)
+	}
+}
+
+func TestPull_Canceled(t *testing.T) {
+	server, _, _ := newTestRegistry(t)
+	client := &Client{Endpoint: server.URL}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := client.Pull(ctx, "gram", 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got: %v", err)
+	}
+}
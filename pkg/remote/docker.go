@@ -0,0 +1,15 @@
+package remote
+
+import (
+	"context"
+	"io"
+	"os/exec"
+)
+
+// Docker returns a Shell that runs scripts inside a running container with docker exec.
+// The container needs sh and tar.
+func Docker(container string) Shell {
+	return func(ctx context.Context, script string, stdin io.Reader, stdout io.Writer) error {
+		return run(exec.CommandContext(ctx, "docker", "exec", "-i", container, "sh", "-c", script), stdin, stdout)
+	}
+}
@@ -0,0 +1,89 @@
+// Package remote applies deltagrams to directories on other systems, reached through a
+// shell such as ssh. The directory is copied into memory with tar, so that the deltagram
+// is validated and applied exactly as it is locally, and only the files that changed are
+// sent back.
+package remote
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/operations"
+)
+
+// Root is the base directory under which a FileSystem exposes the remote directory
+var Root = operations.ArchiveRoot
+
+// Shell runs a POSIX shell script on a remote system with stdin and stdout connected
+type Shell func(ctx context.Context, script string, stdin io.Reader, stdout io.Writer) error
+
+// FileSystem is an in-memory copy of a remote directory
+type FileSystem struct {
+	*operations.MemoryFileSystem
+	shell Shell
+	dir   string
+}
+
+// Open copies the remote directory dir into memory. The .git directory is left out. The
+// remote system needs sh and tar.
+func Open(ctx context.Context, shell Shell, dir string) (*FileSystem, error) {
+	var out bytes.Buffer
+	if err := shell(ctx, "cd "+Quote(dir)+" && tar --exclude=./.git -cf - .", nil, &out); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", dir, err)
+	}
+	entries, err := operations.ReadTar(&out)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", dir, err)
+	}
+	mem, err := operations.LoadArchiveEntries(entries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", dir, err)
+	}
+	return &FileSystem{MemoryFileSystem: mem, shell: shell, dir: dir}, nil
+}
+
+// Push writes the changed files back to the remote directory and removes the files that
+// were deleted, in a single round trip
+func (f *FileSystem) Push(ctx context.Context, changes []operations.FileChange) error {
+	var entries []operations.ArchiveEntry
+	var removed []string
+	for _, change := range changes {
+		rel, err := filepath.Rel(Root, change.Path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return fmt.Errorf("changed file %s is outside %s", change.Path, f.dir)
+		}
+		rel = filepath.ToSlash(rel)
+
+		if !change.Exists {
+			removed = append(removed, Quote(rel))
+			continue
+		}
+		info, err := f.Stat(change.Path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, operations.ArchiveEntry{Path: rel, Data: change.After, Mode: info.Mode().Perm(), ModTime: info.ModTime()})
+	}
+
+	var archive bytes.Buffer
+	if err := operations.WriteTar(&archive, entries); err != nil {
+		return err
+	}
+	script := "cd " + Quote(f.dir) + " && tar -xf -"
+	if len(removed) > 0 {
+		script += " && rm -f -- " + strings.Join(removed, " ")
+	}
+	if err := f.shell(ctx, script, &archive, io.Discard); err != nil {
+		return fmt.Errorf("failed to write changes to %s: %v", f.dir, err)
+	}
+	return nil
+}
+
+// Quote quotes s for a POSIX shell
+func Quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,127 @@
+package remote
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/developingjames/deltagrams/pkg/operations"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// localShell runs scripts with the local sh, standing in for a remote system
+func localShell(t *testing.T) Shell {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a POSIX shell")
+	}
+	if _, err := exec.LookPath("tar"); err != nil {
+		t.Skip("needs tar")
+	}
+	return func(ctx context.Context, script string, stdin io.Reader, stdout io.Writer) error {
+		return run(exec.CommandContext(ctx, "sh", "-c", script), stdin, stdout)
+	}
+}
+
+func TestFileSystem_OpenAndPush(t *testing.T) {
+	shell := localShell(t)
+	dir := filepath.Join(t.TempDir(), "it's here")
+	os.MkdirAll(filepath.Join(dir, "src"), 0755)
+	os.MkdirAll(filepath.Join(dir, ".git"), 0755)
+	os.WriteFile(filepath.Join(dir, "src", "main.txt"), []byte("one\ntwo\nthree"), 0644)
+	os.WriteFile(filepath.Join(dir, "old.txt"), []byte("old"), 0644)
+	os.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("ref: refs/heads/main\n"), 0644)
+
+	fs, err := Open(context.Background(), shell, dir)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, err := fs.ReadFile(filepath.Join(Root, ".git", "HEAD")); err == nil {
+		t.Error("Expected .git to be left out")
+	}
+
+	deltagram := &parser.Deltagram{Parts: []parser.DeltagramPart{
+		{ContentLocation: "src/main.txt", ContentType: "text/plain", DeltaOperation: "content", Content: "@@ -1,3 +1,3 @@\n one\n-two\n+TWO\n three"},
+		{ContentLocation: "docs/new.txt", ContentType: "text/plain", DeltaOperation: "create", Content: "+++ docs/new.txt\nnew"},
+		{ContentLocation: "old.txt", ContentType: "text/plain", DeltaOperation: "delete"},
+	}}
+	recorder := operations.NewRecordingFileSystem(fs)
+	if err := operations.NewApplier(recorder).Apply(deltagram, Root); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if err := fs.Push(context.Background(), recorder.Changes()); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	tests := []struct {
+		path    string
+		content string // Empty for a file that should not exist
+	}{
+		{"src/main.txt", "one\nTWO\nthree"},
+		{"docs/new.txt", "new"},
+		{"old.txt", ""},
+		{".git/HEAD", "ref: refs/heads/main\n"},
+	}
+	for _, tt := range tests {
+		data, err := os.ReadFile(filepath.Join(dir, tt.path))
+		if tt.content == "" {
+			if !os.IsNotExist(err) {
+				t.Errorf("Expected %s to be removed", tt.path)
+			}
+			continue
+		}
+		if string(data) != tt.content {
+			t.Errorf("Expected %s to contain %q, got %q (%v)", tt.path, tt.content, data, err)
+		}
+	}
+}
+
+func TestOpen_MissingDirectory(t *testing.T) {
+	shell := localShell(t)
+	if _, err := Open(context.Background(), shell, filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected an error for a missing directory")
+	}
+}
+
+func TestSSH(t *testing.T) {
+	tests := []struct {
+		name    string
+		target  string
+		dir     string
+		wantErr bool
+	}{
+		{"user and port", "ssh://deploy@example.com:2222/srv/app", "/srv/app", false},
+		{"host only", "ssh://example.com/srv/app", "/srv/app", false},
+		{"no directory", "ssh://example.com", "", true},
+		{"no host", "ssh:///srv/app", "", true},
+		{"wrong scheme", "sftp://example.com/srv/app", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shell, dir, err := SSH(tt.target)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("Expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if shell == nil || dir != tt.dir {
+				t.Errorf("Expected directory %s, got %s", tt.dir, dir)
+			}
+		})
+	}
+}
+
+func TestQuote(t *testing.T) {
+	if got := Quote("it's"); got != `'it'\''s'` {
+		t.Errorf("Unexpected quoting: %s", got)
+	}
+}
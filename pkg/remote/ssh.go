@@ -0,0 +1,55 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os/exec"
+	"strings"
+)
+
+// SSH parses a target of the form ssh://[user@]host[:port]/path and returns a Shell that
+// runs scripts on the host with the ssh command, along with the directory the target
+// names. The path is absolute; authentication is left to ssh and its configuration.
+func SSH(target string) (Shell, string, error) {
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme != "ssh" || u.Hostname() == "" {
+		return nil, "", fmt.Errorf("invalid SSH target %s: use ssh://[user@]host[:port]/path", target)
+	}
+	if u.Path == "" || u.Path == "/" {
+		return nil, "", fmt.Errorf("invalid SSH target %s: no directory given", target)
+	}
+
+	var args []string
+	if port := u.Port(); port != "" {
+		args = append(args, "-p", port)
+	}
+	destination := u.Hostname()
+	if u.User != nil {
+		destination = u.User.Username() + "@" + destination
+	}
+	args = append(args, "--", destination)
+
+	shell := func(ctx context.Context, script string, stdin io.Reader, stdout io.Writer) error {
+		// ssh joins its arguments into one command line for the remote login shell
+		return run(exec.CommandContext(ctx, "ssh", append(args, "sh -c "+Quote(script))...), stdin, stdout)
+	}
+	return shell, u.Path, nil
+}
+
+// run runs cmd, including what it wrote to stderr in the error if it fails
+func run(cmd *exec.Cmd, stdin io.Reader, stdout io.Writer) error {
+	var stderr bytes.Buffer
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return fmt.Errorf("%s: %v: %s", cmd.Args[0], err, message)
+		}
+		return fmt.Errorf("%s: %v", cmd.Args[0], err)
+	}
+	return nil
+}
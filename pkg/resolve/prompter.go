@@ -0,0 +1,186 @@
+package resolve
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/diff"
+	"github.com/developingjames/deltagrams/pkg/operations"
+)
+
+// contextLines is how many file lines are shown around a conflicting hunk
+const contextLines = 3
+
+// Prompter resolves hunk conflicts by showing the expected and actual lines and asking
+// the user what to do
+type Prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+	// Edit lets the user change the hunk text, returning the edited text; it defaults to
+	// opening $EDITOR on a temporary file
+	Edit func(text string) (string, error)
+	// Display sets the tab width used to show lines and whether the words that differ
+	// between an expected line and the actual one are marked
+	Display diff.DisplayOptions
+}
+
+// NewPrompter creates a prompter reading answers from in and writing to out
+func NewPrompter(in io.Reader, out io.Writer) *Prompter {
+	return &Prompter{in: bufio.NewReader(in), out: out, Edit: EditInEditor}
+}
+
+// Resolve shows the conflict and asks how to proceed
+func (p *Prompter) Resolve(conflict operations.Conflict) (operations.Resolution, error) {
+	p.show(conflict)
+
+	for {
+		fmt.Fprint(p.out, "[a]pply here anyway, apply at [l]ine, [s]kip, [e]dit hunk, [q]uit? ")
+		answer, err := p.readLine()
+		if err != nil {
+			return operations.Resolution{Action: operations.ResolveAbort}, nil
+		}
+
+		switch strings.ToLower(answer) {
+		case "a":
+			return operations.Resolution{Action: operations.ResolveApplyAt, Start: conflict.Start}, nil
+		case "l":
+			fmt.Fprintf(p.out, "Line number (1-%d): ", len(conflict.Lines)+1)
+			answer, err := p.readLine()
+			if err != nil {
+				return operations.Resolution{Action: operations.ResolveAbort}, nil
+			}
+			line, err := strconv.Atoi(answer)
+			if err != nil || line < 1 || line > len(conflict.Lines)+1 {
+				fmt.Fprintf(p.out, "Invalid line number: %s\n", answer)
+				continue
+			}
+			return operations.Resolution{Action: operations.ResolveApplyAt, Start: line - 1}, nil
+		case "s":
+			return operations.Resolution{Action: operations.ResolveSkip}, nil
+		case "e":
+			hunk, err := p.edit(conflict.Hunk)
+			if err != nil {
+				fmt.Fprintf(p.out, "Edit failed: %v\n", err)
+				continue
+			}
+			return operations.Resolution{Action: operations.ResolveRetry, Hunk: hunk}, nil
+		case "q":
+			return operations.Resolution{Action: operations.ResolveAbort}, nil
+		default:
+			fmt.Fprintf(p.out, "Unknown choice: %s\n", answer)
+		}
+	}
+}
+
+// show prints the hunk's expected lines next to the file's actual lines at the target
+func (p *Prompter) show(conflict operations.Conflict) {
+	fmt.Fprintf(p.out, "\nConflict in %s, hunk %d: %v\n", conflict.Path, conflict.Index, conflict.Err)
+
+	// Each expected line that differs from the actual line in its place is shown with the
+	// changed words marked on both sides
+	actual := make(map[int]string)
+	fmt.Fprintln(p.out, "\nExpected:")
+	line := conflict.Hunk.Header.OldStart
+	pos := conflict.Start
+	for _, op := range conflict.Hunk.Operations {
+		switch op.Type {
+		case ' ', '-':
+			text := diff.ExpandTabs(op.Content, p.Display.TabWidth)
+			if pos < len(conflict.Lines) && conflict.Lines[pos] != op.Content {
+				text, actual[pos] = diff.DisplayPair(op.Content, conflict.Lines[pos], p.Display)
+			}
+			fmt.Fprintf(p.out, "  %5d %c%s\n", line, op.Type, text)
+			line++
+			pos++
+		case '+':
+			fmt.Fprintf(p.out, "        +%s\n", diff.ExpandTabs(op.Content, p.Display.TabWidth))
+		}
+	}
+
+	fmt.Fprintln(p.out, "\nActual:")
+	start := max(0, conflict.Start-contextLines)
+	end := min(len(conflict.Lines), conflict.Start+conflict.Hunk.Header.OldCount+contextLines)
+	for i := start; i < end; i++ {
+		marker := " "
+		if i >= conflict.Start && i < conflict.Start+conflict.Hunk.Header.OldCount {
+			marker = ">"
+		}
+		text, ok := actual[i]
+		if !ok {
+			text = diff.ExpandTabs(conflict.Lines[i], p.Display.TabWidth)
+		}
+		fmt.Fprintf(p.out, "%s %5d  %s\n", marker, i+1, text)
+	}
+	fmt.Fprintln(p.out)
+}
+
+// edit lets the user change the hunk and parses the result
+func (p *Prompter) edit(hunk *operations.ParsedHunk) (*operations.ParsedHunk, error) {
+	edited, err := p.Edit(operations.FormatHunk(hunk))
+	if err != nil {
+		return nil, err
+	}
+
+	hunks, err := (&operations.ContentHandler{}).ParseAllHunks(strings.Split(edited, "\n"))
+	if err != nil {
+		return nil, err
+	}
+	if len(hunks) != 1 {
+		return nil, fmt.Errorf("expected exactly one hunk, got %d", len(hunks))
+	}
+	return hunks[0], nil
+}
+
+func (p *Prompter) readLine() (string, error) {
+	line, err := p.in.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// EditInEditor opens text in $VISUAL or $EDITOR (vi by default) and returns the result
+func EditInEditor(text string) (string, error) {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	file, err := os.CreateTemp("", "deltagram-hunk-*.diff")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.WriteString(text); err != nil {
+		file.Close()
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", err
+	}
+
+	// The editor setting may include arguments, as in "code --wait"
+	fields := strings.Fields(editor)
+	cmd := exec.Command(fields[0], append(fields[1:], file.Name())...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("editor %s failed: %v", editor, err)
+	}
+
+	data, err := os.ReadFile(file.Name())
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,121 @@
+package resolve
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/developingjames/deltagrams/pkg/diff"
+	"github.com/developingjames/deltagrams/pkg/operations"
+)
+
+func testConflict(t *testing.T) operations.Conflict {
+	hunks, err := (&operations.ContentHandler{}).ParseAllHunks(strings.Split("@@ -2,1 +2,1 @@\n-TWO\n+2", "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return operations.Conflict{
+		Path:  "file.txt",
+		Index: 1,
+		Hunk:  hunks[0],
+		Lines: []string{"one", "two", "three"},
+		Start: 1,
+		Err:   fmt.Errorf("removal mismatch"),
+	}
+}
+
+func TestPrompter_Choices(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected operations.Resolution
+	}{
+		{"apply here", "a\n", operations.Resolution{Action: operations.ResolveApplyAt, Start: 1}},
+		{"apply at line", "l\n3\n", operations.Resolution{Action: operations.ResolveApplyAt, Start: 2}},
+		{"invalid line then skip", "l\n99\ns\n", operations.Resolution{Action: operations.ResolveSkip}},
+		{"unknown then quit", "x\nq\n", operations.Resolution{Action: operations.ResolveAbort}},
+		{"end of input", "", operations.Resolution{Action: operations.ResolveAbort}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			prompter := NewPrompter(strings.NewReader(tt.input), &out)
+
+			resolution, err := prompter.Resolve(testConflict(t))
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if resolution.Action != tt.expected.Action || resolution.Start != tt.expected.Start {
+				t.Errorf("Expected %+v, got %+v", tt.expected, resolution)
+			}
+		})
+	}
+}
+
+func TestPrompter_ShowsExpectedAndActual(t *testing.T) {
+	var out bytes.Buffer
+	prompter := NewPrompter(strings.NewReader("s\n"), &out)
+
+	if _, err := prompter.Resolve(testConflict(t)); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	output := out.String()
+	for _, expected := range []string{"Conflict in file.txt, hunk 1", "2 -TWO", ">     2  two", "      3  three"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, output)
+		}
+	}
+}
+
+func TestPrompter_ShowsChangedWords(t *testing.T) {
+	hunks, err := (&operations.ContentHandler{}).ParseAllHunks(strings.Split("@@ -1,1 +1,1 @@\n-\ttotal := price * qty\n+\ttotal := price * count", "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conflict := operations.Conflict{
+		Path:  "file.go",
+		Index: 1,
+		Hunk:  hunks[0],
+		Lines: []string{"\ttotal := price * quantity"},
+		Err:   fmt.Errorf("removal mismatch"),
+	}
+
+	var out bytes.Buffer
+	prompter := NewPrompter(strings.NewReader("s\n"), &out)
+	prompter.Display = diff.DisplayOptions{TabWidth: 4, WordDiff: true}
+	if _, err := prompter.Resolve(conflict); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	output := out.String()
+	for _, expected := range []string{"1 -    total := price * [-qty-]", "1      total := price * {+quantity+}", "+    total := price * count"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, output)
+		}
+	}
+}
+
+func TestPrompter_Edit(t *testing.T) {
+	var out bytes.Buffer
+	prompter := NewPrompter(strings.NewReader("e\n"), &out)
+	prompter.Edit = func(text string) (string, error) {
+		if !strings.Contains(text, "-TWO") {
+			t.Errorf("Expected hunk text to be offered for editing, got %q", text)
+		}
+		return strings.Replace(text, "-TWO", "-two", 1), nil
+	}
+
+	resolution, err := prompter.Resolve(testConflict(t))
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if resolution.Action != operations.ResolveRetry {
+		t.Fatalf("Expected retry, got %+v", resolution)
+	}
+	if resolution.Hunk.Operations[0].Content != "two" {
+		t.Errorf("Expected edited hunk, got %+v", resolution.Hunk.Operations)
+	}
+}
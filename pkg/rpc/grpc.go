@@ -0,0 +1,262 @@
+// Package rpc serves the deltagram engine over gRPC so that systems not written in Go
+// can parse, validate, plan, and apply deltagrams. The service is defined in
+// deltagram.proto. To keep the module free of dependencies, the protocol buffer encoding
+// and the gRPC framing are implemented here on top of net/http's HTTP/2 support rather
+// than generated with protoc.
+package rpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServiceName is the fully qualified name of the service in deltagram.proto
+const ServiceName = "deltagram.v1.Deltagrams"
+
+// maxMessageSize bounds the size of a request message
+const maxMessageSize = 64 << 20
+
+// Code is a gRPC status code
+type Code int
+
+// Status codes used by the service
+const (
+	OK                 Code = 0
+	Canceled           Code = 1
+	Unknown            Code = 2
+	InvalidArgument    Code = 3
+	DeadlineExceeded   Code = 4
+	NotFound           Code = 5
+	FailedPrecondition Code = 9
+	Aborted            Code = 10
+	Unimplemented      Code = 12
+	Internal           Code = 13
+	Unavailable        Code = 14
+	Unauthenticated    Code = 16
+)
+
+// Error is an error with a gRPC status code. Kind, when set, is sent in the
+// deltagram-error-kind trailer so that clients can branch on it without matching messages.
+type Error struct {
+	Code Code
+	Kind string
+	Err  error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+func (e *Error) Unwrap() error { return e.Err }
+
+// Service implements the methods of deltagram.proto
+type Service interface {
+	Parse(ctx context.Context, req *Request) (*ParseResponse, error)
+	Validate(ctx context.Context, req *Request) (*ValidateResponse, error)
+	Plan(ctx context.Context, req *Request) (*PlanResponse, error)
+	// Apply sends progress and warnings as they happen, then the result
+	Apply(ctx context.Context, req *Request, send func(*ApplyEvent) error) error
+}
+
+// NewHandler returns an http.Handler serving s over gRPC to any caller. It must be served
+// over HTTP/2.
+func NewHandler(s Service) http.Handler {
+	return NewHandlerWithToken(s, "")
+}
+
+// NewHandlerWithToken is like NewHandler, but when token is not empty every call must send
+// it in the authorization metadata as "Bearer <token>"; other calls fail with
+// Unauthenticated before the service sees them
+func NewHandlerWithToken(s Service, token string) http.Handler {
+	return &handler{service: s, token: token}
+}
+
+type handler struct {
+	service Service
+	token   string
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "expected a gRPC request", http.StatusUnsupportedMediaType)
+		return
+	}
+	method := strings.TrimPrefix(r.URL.Path, "/"+ServiceName+"/")
+
+	ctx := r.Context()
+	if timeout, ok := parseTimeout(r.Header.Get("Grpc-Timeout")); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	w.Header().Set("Content-Type", "application/grpc+proto")
+	w.WriteHeader(http.StatusOK)
+	err := h.authenticate(r)
+	if err == nil {
+		err = h.call(ctx, method, r.Body, w)
+	}
+	writeStatus(w, err)
+}
+
+// authenticate checks the bearer token of a call, when the handler has one
+func (h *handler) authenticate(r *http.Request) error {
+	if h.token == "" {
+		return nil
+	}
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+		return &Error{Code: Unauthenticated, Err: errors.New("missing or invalid bearer token")}
+	}
+	return nil
+}
+
+// call reads the request message, runs method, and writes its response messages
+func (h *handler) call(ctx context.Context, method string, body io.Reader, w http.ResponseWriter) error {
+	send := func(m marshaler) error {
+		if err := writeMessage(w, marshal(m)); err != nil {
+			return err
+		}
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	switch method {
+	case "Parse", "Validate", "Plan", "Apply":
+	default:
+		return &Error{Code: Unimplemented, Err: fmt.Errorf("unknown method %s", method)}
+	}
+	data, err := readMessage(body)
+	if err != nil {
+		return err
+	}
+	req, err := unmarshalRequest(data)
+	if err != nil {
+		return &Error{Code: InvalidArgument, Err: fmt.Errorf("invalid request: %v", err)}
+	}
+
+	switch method {
+	case "Parse":
+		resp, err := h.service.Parse(ctx, req)
+		if err != nil {
+			return err
+		}
+		return send(resp)
+	case "Validate":
+		resp, err := h.service.Validate(ctx, req)
+		if err != nil {
+			return err
+		}
+		return send(resp)
+	case "Plan":
+		resp, err := h.service.Plan(ctx, req)
+		if err != nil {
+			return err
+		}
+		return send(resp)
+	default:
+		return h.service.Apply(ctx, req, func(event *ApplyEvent) error { return send(event) })
+	}
+}
+
+// readMessage reads one length-prefixed message from a request body
+func readMessage(r io.Reader) ([]byte, error) {
+	var prefix [5]byte
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		return nil, &Error{Code: InvalidArgument, Err: fmt.Errorf("missing request message: %v", err)}
+	}
+	if prefix[0] != 0 {
+		return nil, &Error{Code: Unimplemented, Err: errors.New("compressed messages are not supported")}
+	}
+	size := binary.BigEndian.Uint32(prefix[1:])
+	if size > maxMessageSize {
+		return nil, &Error{Code: InvalidArgument, Err: fmt.Errorf("request message of %d bytes exceeds the limit of %d", size, maxMessageSize)}
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, &Error{Code: InvalidArgument, Err: fmt.Errorf("truncated request message: %v", err)}
+	}
+	return data, nil
+}
+
+// writeMessage writes one length-prefixed, uncompressed message
+func writeMessage(w io.Writer, data []byte) error {
+	var prefix [5]byte
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(data)))
+	if _, err := w.Write(prefix[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// writeStatus sends the status of the call as trailers
+func writeStatus(w http.ResponseWriter, err error) {
+	code, message, kind := OK, "", ""
+	if err != nil {
+		code, message = Unknown, err.Error()
+		var rpcErr *Error
+		switch {
+		case errors.As(err, &rpcErr):
+			code, kind = rpcErr.Code, rpcErr.Kind
+		case errors.Is(err, context.DeadlineExceeded):
+			code = DeadlineExceeded
+		case errors.Is(err, context.Canceled):
+			code = Canceled
+		}
+	}
+
+	header := w.Header()
+	header.Set(http.TrailerPrefix+"Grpc-Status", strconv.Itoa(int(code)))
+	if message != "" {
+		header.Set(http.TrailerPrefix+"Grpc-Message", encodeMessage(message))
+	}
+	if kind != "" {
+		header.Set(http.TrailerPrefix+"Deltagram-Error-Kind", kind)
+	}
+}
+
+// encodeMessage percent-encodes a status message as the gRPC protocol requires
+func encodeMessage(message string) string {
+	var b strings.Builder
+	for i := 0; i < len(message); i++ {
+		c := message[i]
+		if c < 0x20 || c > 0x7e || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// parseTimeout reads a grpc-timeout header such as "30S" or "500m"
+func parseTimeout(value string) (time.Duration, bool) {
+	if len(value) < 2 {
+		return 0, false
+	}
+	units := map[byte]time.Duration{
+		'H': time.Hour,
+		'M': time.Minute,
+		'S': time.Second,
+		'm': time.Millisecond,
+		'u': time.Microsecond,
+		'n': time.Nanosecond,
+	}
+	unit, ok := units[value[len(value)-1]]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
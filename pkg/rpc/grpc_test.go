@@ -0,0 +1,225 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeService answers Parse with the deltagram text as the UUID and streams one progress
+// event before the result of Apply
+type fakeService struct{}
+
+func (fakeService) Parse(ctx context.Context, req *Request) (*ParseResponse, error) {
+	if req.Deltagram == "" {
+		return nil, &Error{Code: InvalidArgument, Kind: "invalid_boundary", Err: errors.New("missing boundary: 100%")}
+	}
+	return &ParseResponse{UUID: req.Deltagram, Version: 1, Parts: []Part{{Path: "a.txt", Operation: "create"}}}, nil
+}
+
+func (fakeService) Validate(ctx context.Context, req *Request) (*ValidateResponse, error) {
+	return &ValidateResponse{Valid: len(req.Files) > 0}, nil
+}
+
+func (fakeService) Plan(ctx context.Context, req *Request) (*PlanResponse, error) {
+	return &PlanResponse{PlanJSON: "{}"}, nil
+}
+
+func (fakeService) Apply(ctx context.Context, req *Request, send func(*ApplyEvent) error) error {
+	if err := send(&ApplyEvent{Progress: &Progress{Done: 1, Total: 1, Path: "a.txt"}}); err != nil {
+		return err
+	}
+	return send(&ApplyEvent{Result: &ApplyResult{Changes: []Change{{Path: "a.txt", Action: "created"}}}})
+}
+
+// callResult is what a client receives for one call
+type callResult struct {
+	messages [][]byte
+	trailer  http.Header
+}
+
+// call makes a gRPC call over HTTP/2 and reads every response message
+func call(t *testing.T, server *httptest.Server, method string, req *Request) callResult {
+	t.Helper()
+	return callWithAuthorization(t, server, method, req, "")
+}
+
+// callWithAuthorization is like call, sending authorization as the authorization metadata
+// when it is not empty
+func callWithAuthorization(t *testing.T, server *httptest.Server, method string, req *Request, authorization string) callResult {
+	t.Helper()
+	var body bytes.Buffer
+	if err := writeMessage(&body, marshal(req)); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, server.URL+"/"+ServiceName+"/"+method, &body)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/grpc")
+	httpReq.Header.Set("TE", "trailers")
+	if authorization != "" {
+		httpReq.Header.Set("Authorization", authorization)
+	}
+
+	resp, err := server.Client().Do(httpReq)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Fatalf("Expected HTTP/2, got %s", resp.Proto)
+	}
+
+	var result callResult
+	for {
+		var prefix [5]byte
+		if _, err := io.ReadFull(resp.Body, prefix[:]); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Expected a message prefix, got: %v", err)
+		}
+		data := make([]byte, binary.BigEndian.Uint32(prefix[1:]))
+		if _, err := io.ReadFull(resp.Body, data); err != nil {
+			t.Fatalf("Expected a message, got: %v", err)
+		}
+		result.messages = append(result.messages, data)
+	}
+	result.trailer = resp.Trailer
+	return result
+}
+
+func newTestServer(t *testing.T) *httptest.Server {
+	return newTestServerWithHandler(t, NewHandler(fakeService{}))
+}
+
+func newTestServerWithHandler(t *testing.T, handler http.Handler) *httptest.Server {
+	server := httptest.NewUnstartedServer(handler)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHandler_Unary(t *testing.T) {
+	server := newTestServer(t)
+
+	result := call(t, server, "Parse", &Request{Deltagram: "abc12345"})
+	if status := result.trailer.Get("Grpc-Status"); status != "0" {
+		t.Fatalf("Expected status 0, got %q (%s)", status, result.trailer.Get("Grpc-Message"))
+	}
+	if len(result.messages) != 1 {
+		t.Fatalf("Expected one message, got %d", len(result.messages))
+	}
+	got := fields(t, result.messages[0])
+	if string(got[1][0]) != "abc12345" || got[2][0][0] != 1 || len(got[3]) != 1 {
+		t.Errorf("Expected the parse response, got %v", got)
+	}
+}
+
+func TestHandler_Stream(t *testing.T) {
+	server := newTestServer(t)
+
+	result := call(t, server, "Apply", &Request{Deltagram: "text"})
+	if status := result.trailer.Get("Grpc-Status"); status != "0" {
+		t.Fatalf("Expected status 0, got %q (%s)", status, result.trailer.Get("Grpc-Message"))
+	}
+	if len(result.messages) != 2 {
+		t.Fatalf("Expected two events, got %d", len(result.messages))
+	}
+	if _, ok := fields(t, result.messages[0])[1]; !ok {
+		t.Error("Expected a progress event first")
+	}
+	if _, ok := fields(t, result.messages[1])[3]; !ok {
+		t.Error("Expected the result event last")
+	}
+}
+
+func TestHandler_Errors(t *testing.T) {
+	server := newTestServer(t)
+
+	tests := []struct {
+		name    string
+		method  string
+		status  string
+		message string
+		kind    string
+	}{
+		{name: "service error", method: "Parse", status: "3", message: "missing boundary: 100%25", kind: "invalid_boundary"},
+		{name: "unknown method", method: "Frobnicate", status: "12", message: "unknown method Frobnicate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := call(t, server, tt.method, &Request{})
+			if len(result.messages) != 0 {
+				t.Errorf("Expected no messages, got %d", len(result.messages))
+			}
+			if got := result.trailer.Get("Grpc-Status"); got != tt.status {
+				t.Errorf("Expected status %s, got %s", tt.status, got)
+			}
+			if got := result.trailer.Get("Grpc-Message"); got != tt.message {
+				t.Errorf("Expected message %q, got %q", tt.message, got)
+			}
+			if got := result.trailer.Get("Deltagram-Error-Kind"); got != tt.kind {
+				t.Errorf("Expected kind %q, got %q", tt.kind, got)
+			}
+		})
+	}
+}
+
+func TestHandler_Token(t *testing.T) {
+	server := newTestServerWithHandler(t, NewHandlerWithToken(fakeService{}, "s3cret"))
+
+	tests := []struct {
+		name          string
+		authorization string
+		status        string
+	}{
+		{name: "missing", authorization: "", status: "16"},
+		{name: "wrong token", authorization: "Bearer other", status: "16"},
+		{name: "wrong scheme", authorization: "Basic s3cret", status: "16"},
+		{name: "valid", authorization: "Bearer s3cret", status: "0"},
+		{name: "lowercase scheme", authorization: "bearer s3cret", status: "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := callWithAuthorization(t, server, "Apply", &Request{Deltagram: "text"}, tt.authorization)
+			if got := result.trailer.Get("Grpc-Status"); got != tt.status {
+				t.Errorf("Expected status %s, got %s (%s)", tt.status, got, result.trailer.Get("Grpc-Message"))
+			}
+			if tt.status != "0" && len(result.messages) != 0 {
+				t.Errorf("Expected an unauthenticated call to reach no method, got %d messages", len(result.messages))
+			}
+		})
+	}
+}
+
+func TestParseTimeout(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+		ok    bool
+	}{
+		{value: "30S", want: "30s", ok: true},
+		{value: "500m", want: "500ms", ok: true},
+		{value: "2H", want: "2h0m0s", ok: true},
+		{value: "", ok: false},
+		{value: "5", ok: false},
+		{value: "5x", ok: false},
+		{value: "-1S", ok: false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseTimeout(tt.value)
+		if ok != tt.ok || (ok && got.String() != tt.want) {
+			t.Errorf("parseTimeout(%q) = %v, %v; expected %s, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+//go:build go1.24
+
+package rpc
+
+import "net/http"
+
+// enableCleartextHTTP2 makes srv accept HTTP/2 without TLS
+func enableCleartextHTTP2(srv *http.Server) error {
+	srv.Protocols = new(http.Protocols)
+	srv.Protocols.SetUnencryptedHTTP2(true)
+	return nil
+}
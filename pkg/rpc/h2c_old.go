@@ -0,0 +1,13 @@
+//go:build !go1.24
+
+package rpc
+
+import (
+	"errors"
+	"net/http"
+)
+
+// enableCleartextHTTP2 fails, since net/http supports HTTP/2 without TLS only from Go 1.24
+func enableCleartextHTTP2(srv *http.Server) error {
+	return errors.New("serving gRPC without TLS needs deltagram built with Go 1.24 or later; use a TLS certificate and key")
+}
@@ -0,0 +1,248 @@
+package rpc
+
+// Request is any of the request messages in deltagram.proto. They share field numbers,
+// so one type decodes them all; fields a method does not use are ignored.
+type Request struct {
+	Deltagram string            // 1: Deltagram text
+	Repair    bool              // 2: Fix common formatting mistakes while parsing
+	Files     map[string][]byte // 3: A tree to use instead of the server's directory
+	DryRun    bool              // 4: Apply without writing anything
+	InMemory  bool              // 5: Use Files, even when empty, instead of the server's directory
+}
+
+// UsesFiles reports whether the request works on the files it carries rather than on the
+// server's directory. An empty map is not sent on the wire, so a request for an empty tree
+// must set InMemory.
+func (r *Request) UsesFiles() bool {
+	return r.InMemory || len(r.Files) > 0
+}
+
+func (r *Request) encode(e *encoder) {
+	e.string(1, r.Deltagram)
+	e.bool(2, r.Repair)
+	e.bytesMap(3, r.Files)
+	e.bool(4, r.DryRun)
+	e.bool(5, r.InMemory)
+}
+
+// unmarshalRequest decodes a request message
+func unmarshalRequest(data []byte) (*Request, error) {
+	r := &Request{}
+	d := decoder{data}
+	for {
+		field, wireType, err := d.next()
+		if err != nil {
+			return nil, err
+		}
+		if field == 0 {
+			return r, nil
+		}
+		switch {
+		case field == 1 && wireType == wireBytes:
+			r.Deltagram, err = d.string()
+		case field == 2 && wireType == wireVarint:
+			r.Repair, err = d.bool()
+		case field == 3 && wireType == wireBytes:
+			var key string
+			var value []byte
+			if key, value, err = d.entry(); err == nil {
+				if r.Files == nil {
+					r.Files = make(map[string][]byte)
+				}
+				r.Files[key] = value
+			}
+		case field == 4 && wireType == wireVarint:
+			r.DryRun, err = d.bool()
+		case field == 5 && wireType == wireVarint:
+			r.InMemory, err = d.bool()
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ParseResponse describes a parsed deltagram
+type ParseResponse struct {
+	UUID    string
+	Version int
+	Parts   []Part
+	Repairs []string // Formatting mistakes fixed with Repair
+}
+
+func (r *ParseResponse) encode(e *encoder) {
+	e.string(1, r.UUID)
+	e.int(2, int64(r.Version))
+	for i := range r.Parts {
+		e.message(3, &r.Parts[i])
+	}
+	for _, repair := range r.Repairs {
+		e.string(4, repair)
+	}
+}
+
+// Part is one part of a deltagram
+type Part struct {
+	Path        string
+	Operation   string // create, content, delete, copy, move, or message
+	ContentType string
+	Line        int // Line of the deltagram text where the part's headers start
+}
+
+func (p *Part) encode(e *encoder) {
+	e.string(1, p.Path)
+	e.string(2, p.Operation)
+	e.string(3, p.ContentType)
+	e.int(4, int64(p.Line))
+}
+
+// ValidateResponse reports whether a deltagram applies cleanly. A deltagram that does
+// not apply is a successful call with Valid false.
+type ValidateResponse struct {
+	Valid     bool
+	Error     string
+	ErrorKind string // The kind reported by the CLI's --json output, such as context_mismatch
+	Part      int    // 1-based index of the failing part, when known
+	Warnings  []Warning
+	Changes   []Change // Files the deltagram would change
+}
+
+func (r *ValidateResponse) encode(e *encoder) {
+	e.bool(1, r.Valid)
+	e.string(2, r.Error)
+	e.string(3, r.ErrorKind)
+	e.int(4, int64(r.Part))
+	for i := range r.Warnings {
+		e.message(5, &r.Warnings[i])
+	}
+	for i := range r.Changes {
+		e.message(6, &r.Changes[i])
+	}
+}
+
+// PlanResponse is the plan of a deltagram, both as messages and as the JSON document
+// that `deltagram apply --plan` accepts
+type PlanResponse struct {
+	Steps    []PlanStep
+	PlanJSON string
+}
+
+func (r *PlanResponse) encode(e *encoder) {
+	for i := range r.Steps {
+		e.message(1, &r.Steps[i])
+	}
+	e.string(2, r.PlanJSON)
+}
+
+// PlanStep is one file operation of a plan
+type PlanStep struct {
+	Part          int
+	Operation     string
+	Source        string
+	Target        string
+	Hunks         int
+	Preconditions []Precondition
+}
+
+func (s *PlanStep) encode(e *encoder) {
+	e.int(1, int64(s.Part))
+	e.string(2, s.Operation)
+	e.string(3, s.Source)
+	e.string(4, s.Target)
+	e.int(5, int64(s.Hunks))
+	for i := range s.Preconditions {
+		e.message(6, &s.Preconditions[i])
+	}
+}
+
+// Precondition is the state a path must be in before a plan is executed
+type Precondition struct {
+	Path   string
+	Exists bool
+	SHA256 string
+}
+
+func (p *Precondition) encode(e *encoder) {
+	e.string(1, p.Path)
+	e.bool(2, p.Exists)
+	e.string(3, p.SHA256)
+}
+
+// ApplyEvent is one message of the Apply stream; exactly one field is set. The last
+// event of a successful apply carries the Result.
+type ApplyEvent struct {
+	Progress *Progress
+	Warning  *Warning
+	Result   *ApplyResult
+}
+
+func (a *ApplyEvent) encode(e *encoder) {
+	switch {
+	case a.Progress != nil:
+		e.message(1, a.Progress)
+	case a.Warning != nil:
+		e.message(2, a.Warning)
+	case a.Result != nil:
+		e.message(3, a.Result)
+	}
+}
+
+// Progress reports how far an apply has got
+type Progress struct {
+	Done       int
+	Total      int
+	Bytes      int64
+	TotalBytes int64
+	Path       string // Target of the operation just completed
+}
+
+func (p *Progress) encode(e *encoder) {
+	e.int(1, int64(p.Done))
+	e.int(2, int64(p.Total))
+	e.int(3, p.Bytes)
+	e.int(4, p.TotalBytes)
+	e.string(5, p.Path)
+}
+
+// Warning is something that did not stop an apply but may need attention
+type Warning struct {
+	Part    int
+	Path    string
+	Kind    string
+	Message string
+}
+
+func (w *Warning) encode(e *encoder) {
+	e.int(1, int64(w.Part))
+	e.string(2, w.Path)
+	e.string(3, w.Kind)
+	e.string(4, w.Message)
+}
+
+// ApplyResult lists what an apply changed
+type ApplyResult struct {
+	Changes []Change
+	Diff    string
+	Files   map[string][]byte // The resulting tree, when the request gave Files
+}
+
+func (r *ApplyResult) encode(e *encoder) {
+	for i := range r.Changes {
+		e.message(1, &r.Changes[i])
+	}
+	e.string(2, r.Diff)
+	e.bytesMap(3, r.Files)
+}
+
+// Change is a file created, modified, or deleted
+type Change struct {
+	Path   string
+	Action string
+}
+
+func (c *Change) encode(e *encoder) {
+	e.string(1, c.Path)
+	e.string(2, c.Action)
+}
@@ -0,0 +1,60 @@
+package rpc
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ServeOptions configures Serve
+type ServeOptions struct {
+	// TLSConfig, when set, serves HTTP/2 over TLS instead of cleartext HTTP/2
+	TLSConfig *tls.Config
+	// Token, when set, must be sent by every call; see NewHandlerWithToken
+	Token string
+}
+
+// Serve serves s on l until ctx is done. With a TLS configuration it speaks HTTP/2 over
+// TLS; without one it speaks cleartext HTTP/2, as gRPC clients using insecure credentials
+// expect.
+func Serve(ctx context.Context, l net.Listener, s Service, opts ServeOptions) error {
+	tlsConfig := opts.TLSConfig
+	srv := &http.Server{Handler: NewHandlerWithToken(s, opts.Token), ReadHeaderTimeout: 10 * time.Second}
+	if tlsConfig == nil {
+		if err := enableCleartextHTTP2(srv); err != nil {
+			return err
+		}
+	} else {
+		srv.TLSConfig = tlsConfig.Clone()
+		srv.TLSConfig.NextProtos = []string{"h2"}
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			// Give running calls a moment to finish; canceling them stops an apply between parts
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if srv.Shutdown(shutdownCtx) != nil {
+				srv.Close()
+			}
+		case <-done:
+		}
+	}()
+
+	var err error
+	if tlsConfig == nil {
+		err = srv.Serve(l)
+	} else {
+		err = srv.ServeTLS(l, "", "")
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
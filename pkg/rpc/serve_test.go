@@ -0,0 +1,46 @@
+//go:build go1.24
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+)
+
+func TestServe_Cleartext(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() { served <- Serve(ctx, l, fakeService{}, ServeOptions{}) }()
+
+	// Cleartext HTTP/2 with prior knowledge, as gRPC clients with insecure credentials use
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+
+	var body bytes.Buffer
+	writeMessage(&body, marshal(&Request{Deltagram: "abc12345"}))
+	req, _ := http.NewRequest(http.MethodPost, "http://"+l.Addr().String()+"/"+ServiceName+"/Parse", &body)
+	req.Header.Set("Content-Type", "application/grpc")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 || resp.Trailer.Get("Grpc-Status") != "0" {
+		t.Errorf("Expected a successful HTTP/2 call, got %s with status %q", resp.Proto, resp.Trailer.Get("Grpc-Status"))
+	}
+
+	cancel()
+	if err := <-served; err != nil {
+		t.Errorf("Expected Serve to stop cleanly, got: %v", err)
+	}
+}
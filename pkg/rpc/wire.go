@@ -0,0 +1,197 @@
+package rpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Protocol buffer wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("truncated message")
+
+// encoder appends fields in the protocol buffer wire format. Fields holding their zero
+// value are omitted, as proto3 does.
+type encoder struct {
+	buf []byte
+}
+
+// marshaler is a message that can be encoded
+type marshaler interface {
+	encode(e *encoder)
+}
+
+func marshal(m marshaler) []byte {
+	var e encoder
+	m.encode(&e)
+	return e.buf
+}
+
+func (e *encoder) tag(field, wireType int) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(field)<<3|uint64(wireType))
+}
+
+func (e *encoder) bytes(field int, b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	e.tag(field, wireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) string(field int, s string) {
+	e.bytes(field, []byte(s))
+}
+
+func (e *encoder) int(field int, v int64) {
+	if v == 0 {
+		return
+	}
+	e.tag(field, wireVarint)
+	e.buf = binary.AppendUvarint(e.buf, uint64(v))
+}
+
+func (e *encoder) bool(field int, v bool) {
+	if v {
+		e.int(field, 1)
+	}
+}
+
+// message encodes m even when it is empty, so that a oneof field records which case is set
+func (e *encoder) message(field int, m marshaler) {
+	inner := marshal(m)
+	e.tag(field, wireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(inner)))
+	e.buf = append(e.buf, inner...)
+}
+
+// bytesMap encodes a map<string, bytes> field as entries sorted by key
+func (e *encoder) bytesMap(field int, m map[string][]byte) {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var entry encoder
+		entry.string(1, key)
+		entry.bytes(2, m[key])
+		e.tag(field, wireBytes)
+		e.buf = binary.AppendUvarint(e.buf, uint64(len(entry.buf)))
+		e.buf = append(e.buf, entry.buf...)
+	}
+}
+
+// decoder reads fields in the protocol buffer wire format
+type decoder struct {
+	buf []byte
+}
+
+// next returns the number and wire type of the next field, or a field number of 0 at the
+// end of the message
+func (d *decoder) next() (int, int, error) {
+	if len(d.buf) == 0 {
+		return 0, 0, nil
+	}
+	key, err := d.varint()
+	if err != nil {
+		return 0, 0, err
+	}
+	field := int(key >> 3)
+	if field == 0 {
+		return 0, 0, fmt.Errorf("invalid field number 0")
+	}
+	return field, int(key & 7), nil
+}
+
+func (d *decoder) varint() (uint64, error) {
+	v, n := binary.Uvarint(d.buf)
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	d.buf = d.buf[n:]
+	return v, nil
+}
+
+func (d *decoder) bytes() ([]byte, error) {
+	n, err := d.varint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.buf)) {
+		return nil, errTruncated
+	}
+	b := d.buf[:n]
+	d.buf = d.buf[n:]
+	return b, nil
+}
+
+func (d *decoder) string() (string, error) {
+	b, err := d.bytes()
+	return string(b), err
+}
+
+func (d *decoder) bool() (bool, error) {
+	v, err := d.varint()
+	return v != 0, err
+}
+
+// skip discards the value of a field this package does not know
+func (d *decoder) skip(wireType int) error {
+	var size int
+	switch wireType {
+	case wireVarint:
+		_, err := d.varint()
+		return err
+	case wireBytes:
+		_, err := d.bytes()
+		return err
+	case wireFixed64:
+		size = 8
+	case wireFixed32:
+		size = 4
+	default:
+		return fmt.Errorf("unsupported wire type %d", wireType)
+	}
+	if len(d.buf) < size {
+		return errTruncated
+	}
+	d.buf = d.buf[size:]
+	return nil
+}
+
+// entry decodes one entry of a map<string, bytes> field
+func (d *decoder) entry() (string, []byte, error) {
+	data, err := d.bytes()
+	if err != nil {
+		return "", nil, err
+	}
+	entry := decoder{data}
+	var key string
+	var value []byte
+	for {
+		field, wireType, err := entry.next()
+		if err != nil || field == 0 {
+			return key, value, err
+		}
+		switch {
+		case field == 1 && wireType == wireBytes:
+			key, err = entry.string()
+		case field == 2 && wireType == wireBytes:
+			value, err = entry.bytes()
+		default:
+			err = entry.skip(wireType)
+		}
+		if err != nil {
+			return "", nil, err
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package rpc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRequest_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		req  Request
+	}{
+		{name: "empty", req: Request{}},
+		{name: "deltagram only", req: Request{Deltagram: "--====DELTAGRAM_abc12345===="}},
+		{
+			name: "all fields",
+			req: Request{
+				Deltagram: "text",
+				Repair:    true,
+				Files:     map[string][]byte{"a.txt": []byte("a"), "dir/b.txt": []byte("b\n"), "empty.txt": nil},
+				DryRun:    true,
+				InMemory:  true,
+			},
+		},
+		{name: "empty tree in memory", req: Request{Deltagram: "text", InMemory: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := unmarshalRequest(marshal(&tt.req))
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			if got.Deltagram != tt.req.Deltagram || got.Repair != tt.req.Repair || got.DryRun != tt.req.DryRun || got.InMemory != tt.req.InMemory {
+				t.Errorf("Expected %+v, got %+v", tt.req, got)
+			}
+			if len(got.Files) != len(tt.req.Files) {
+				t.Fatalf("Expected %d files, got %d", len(tt.req.Files), len(got.Files))
+			}
+			if got.UsesFiles() != tt.req.UsesFiles() {
+				t.Errorf("Expected UsesFiles %v, got %v", tt.req.UsesFiles(), got.UsesFiles())
+			}
+			for name, data := range tt.req.Files {
+				if string(got.Files[name]) != string(data) {
+					t.Errorf("Expected %s to be %q, got %q", name, data, got.Files[name])
+				}
+			}
+		})
+	}
+}
+
+func TestUnmarshalRequest_SkipsUnknownFields(t *testing.T) {
+	var e encoder
+	e.string(1, "text")
+	e.int(9, 42)
+	e.string(10, "unknown")
+	e.tag(11, wireFixed32)
+	e.buf = append(e.buf, 1, 2, 3, 4)
+	e.tag(12, wireFixed64)
+	e.buf = append(e.buf, 1, 2, 3, 4, 5, 6, 7, 8)
+	e.bool(2, true)
+
+	got, err := unmarshalRequest(e.buf)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if got.Deltagram != "text" || !got.Repair {
+		t.Errorf("Expected the known fields to be read, got %+v", got)
+	}
+}
+
+func TestUnmarshalRequest_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "truncated string", data: []byte{0x0a, 0x05, 'a'}},
+		{name: "truncated varint", data: []byte{0x10, 0x80}},
+		{name: "field zero", data: []byte{0x00, 0x01}},
+		{name: "group wire type", data: []byte{0x1b}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := unmarshalRequest(tt.data); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+// fields decodes a message into the raw values of each field, for checking responses
+func fields(t *testing.T, data []byte) map[int][][]byte {
+	t.Helper()
+	result := make(map[int][][]byte)
+	d := decoder{data}
+	for {
+		field, wireType, err := d.next()
+		if err != nil {
+			t.Fatalf("Expected a valid message, got: %v", err)
+		}
+		if field == 0 {
+			return result
+		}
+		var value []byte
+		switch wireType {
+		case wireBytes:
+			value, err = d.bytes()
+		case wireVarint:
+			var v uint64
+			v, err = d.varint()
+			value = []byte{byte(v)}
+		default:
+			t.Fatalf("Unexpected wire type %d", wireType)
+		}
+		if err != nil {
+			t.Fatalf("Expected a valid message, got: %v", err)
+		}
+		result[field] = append(result[field], value)
+	}
+}
+
+func TestApplyEvent_Encode(t *testing.T) {
+	event := &ApplyEvent{Result: &ApplyResult{
+		Changes: []Change{{Path: "a.txt", Action: "modified"}},
+		Diff:    "diff",
+	}}
+	got := fields(t, marshal(event))
+	if len(got) != 1 || len(got[3]) != 1 {
+		t.Fatalf("Expected only the result field, got %v", got)
+	}
+
+	result := fields(t, got[3][0])
+	change := fields(t, result[1][0])
+	want := map[int][][]byte{1: {[]byte("a.txt")}, 2: {[]byte("modified")}}
+	if !reflect.DeepEqual(change, want) {
+		t.Errorf("Expected change %v, got %v", want, change)
+	}
+	if string(result[2][0]) != "diff" {
+		t.Errorf("Expected diff, got %q", result[2][0])
+	}
+
+	// An empty message in a oneof still records which case is set
+	got = fields(t, marshal(&ApplyEvent{Progress: &Progress{}}))
+	if len(got[1]) != 1 || len(got[1][0]) != 0 {
+		t.Errorf("Expected an empty progress field, got %v", got)
+	}
+}
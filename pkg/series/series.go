@@ -0,0 +1,303 @@
+package series
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/developingjames/deltagrams/pkg/operations"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// SeriesFile lists the deltagrams of the stack, one path per line relative to the base
+// directory, in the order they apply
+const SeriesFile = ".deltagram/series"
+
+// StateFile records which deltagrams of the series are applied and how to revert them
+const StateFile = ".deltagram/state"
+
+// Change is a file modified by an applied deltagram, with enough to restore it
+type Change struct {
+	Path        string `json:"path"` // Slash-separated, relative to the base directory
+	Existed     bool   `json:"existed"`
+	Before      []byte `json:"before,omitempty"`
+	Exists      bool   `json:"exists"`
+	AfterSHA256 string `json:"after_sha256,omitempty"`
+}
+
+// Applied is a deltagram of the series that has been applied
+type Applied struct {
+	Name      string    `json:"name"`
+	SHA256    string    `json:"sha256"`
+	AppliedAt time.Time `json:"applied_at"`
+	Changes   []Change  `json:"changes"`
+}
+
+// State is the content of the state file
+type State struct {
+	Applied []Applied `json:"applied"`
+}
+
+// Status describes one entry of the series
+type Status struct {
+	Name     string
+	Applied  bool
+	Modified bool // Applied files were changed since the deltagram was applied
+	Changed  bool // The deltagram file changed since it was applied
+}
+
+// Stack applies and reverts the deltagrams of a series in order
+type Stack struct {
+	fs      operations.FileSystem
+	baseDir string
+	opts    operations.Options
+}
+
+// NewStack creates a stack for the series in baseDir, applying with the given options
+func NewStack(fs operations.FileSystem, baseDir string, opts operations.Options) *Stack {
+	return &Stack{fs: fs, baseDir: baseDir, opts: opts}
+}
+
+// Series returns the deltagram paths listed in the series file, skipping blank lines
+// and comments
+func (s *Stack) Series() ([]string, error) {
+	data, err := s.fs.ReadFile(s.path(SeriesFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read series file %s: %v", SeriesFile, err)
+	}
+
+	var names []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		names = append(names, line)
+	}
+	return names, nil
+}
+
+// State returns the recorded state; a missing state file means nothing is applied
+func (s *Stack) State() (*State, error) {
+	data, err := s.fs.ReadFile(s.path(StateFile))
+	if os.IsNotExist(err) {
+		return &State{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file %s: %v", StateFile, err)
+	}
+
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %v", StateFile, err)
+	}
+	return &state, nil
+}
+
+// Status reports every entry of the series and whether it is applied
+func (s *Stack) Status() ([]Status, error) {
+	names, state, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(names))
+	for i, name := range names {
+		statuses[i].Name = name
+		if i >= len(state.Applied) {
+			continue
+		}
+
+		applied := state.Applied[i]
+		statuses[i].Applied = true
+		statuses[i].Modified = len(s.modified(applied)) > 0
+		if data, err := s.fs.ReadFile(s.path(name)); err != nil || checksum(data) != applied.SHA256 {
+			statuses[i].Changed = true
+		}
+	}
+	return statuses, nil
+}
+
+// Push applies the next unapplied deltagram of the series and returns its name. If the
+// apply fails part way, the files it changed are restored.
+func (s *Stack) Push() (string, error) {
+	return s.PushContext(context.Background())
+}
+
+// PushContext is like Push but stops once ctx is done, restoring the files already
+// changed just as for a failed apply
+func (s *Stack) PushContext(ctx context.Context) (string, error) {
+	names, state, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	if len(state.Applied) == len(names) {
+		return "", fmt.Errorf("all %d deltagrams in the series are applied", len(names))
+	}
+
+	name := names[len(state.Applied)]
+	data, err := s.fs.ReadFile(s.path(name))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %v", name, err)
+	}
+	deltagram, err := parser.NewParser().ParseContext(ctx, string(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s: %v", name, err)
+	}
+
+	recorder := operations.NewRecordingFileSystem(s.fs)
+	if err := operations.NewApplierWithOptions(recorder, s.opts).ApplyContext(ctx, deltagram, s.baseDir); err != nil {
+		if restoreErr := s.restore(s.toChanges(recorder.Changes())); restoreErr != nil {
+			return "", fmt.Errorf("failed to apply %s: %v (rollback also failed: %v)", name, err, restoreErr)
+		}
+		return "", fmt.Errorf("failed to apply %s: %w", name, err)
+	}
+
+	state.Applied = append(state.Applied, Applied{
+		Name:      name,
+		SHA256:    checksum(data),
+		AppliedAt: time.Now().UTC(),
+		Changes:   s.toChanges(recorder.Changes()),
+	})
+	if err := s.save(state); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// Pop reverts the most recently applied deltagram and returns its name. Unless force is
+// set, it refuses when files it changed were modified afterwards.
+func (s *Stack) Pop(force bool) (string, error) {
+	state, err := s.State()
+	if err != nil {
+		return "", err
+	}
+	if len(state.Applied) == 0 {
+		return "", fmt.Errorf("no deltagrams in the series are applied")
+	}
+
+	top := state.Applied[len(state.Applied)-1]
+	if modified := s.modified(top); len(modified) > 0 && !force {
+		return "", fmt.Errorf("files changed since %s was applied: %s (use --force to revert anyway)", top.Name, strings.Join(modified, ", "))
+	}
+
+	if err := s.restore(top.Changes); err != nil {
+		return "", fmt.Errorf("failed to revert %s: %v", top.Name, err)
+	}
+
+	state.Applied = state.Applied[:len(state.Applied)-1]
+	if err := s.save(state); err != nil {
+		return "", err
+	}
+	return top.Name, nil
+}
+
+// load reads the series and state, checking that the applied deltagrams are still the
+// leading entries of the series
+func (s *Stack) load() ([]string, *State, error) {
+	names, err := s.Series()
+	if err != nil {
+		return nil, nil, err
+	}
+	state, err := s.State()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(state.Applied) > len(names) {
+		return nil, nil, fmt.Errorf("state lists %d applied deltagrams but the series has %d", len(state.Applied), len(names))
+	}
+	for i, applied := range state.Applied {
+		if names[i] != applied.Name {
+			return nil, nil, fmt.Errorf("series entry %d is %s but %s is applied; pop before reordering the series", i+1, names[i], applied.Name)
+		}
+	}
+	return names, state, nil
+}
+
+// modified returns the paths changed by applied that no longer hold the applied content
+func (s *Stack) modified(applied Applied) []string {
+	var paths []string
+	for _, change := range applied.Changes {
+		data, err := s.fs.ReadFile(s.path(change.Path))
+		exists := err == nil
+		if exists != change.Exists || (exists && checksum(data) != change.AfterSHA256) {
+			paths = append(paths, change.Path)
+		}
+	}
+	return paths
+}
+
+// restore puts the files back as they were before the changes, newest first
+func (s *Stack) restore(changes []Change) error {
+	for i := len(changes) - 1; i >= 0; i-- {
+		change := changes[i]
+		path := s.path(change.Path)
+
+		if !change.Existed {
+			if err := s.fs.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+
+		if err := s.fs.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		perm := os.FileMode(0644)
+		if info, err := s.fs.Stat(path); err == nil {
+			perm = info.Mode().Perm()
+		}
+		if err := s.fs.WriteFile(path, change.Before, perm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Stack) toChanges(changes []operations.FileChange) []Change {
+	result := make([]Change, 0, len(changes))
+	for _, change := range changes {
+		rel := change.Path
+		if r, err := filepath.Rel(s.baseDir, change.Path); err == nil {
+			rel = filepath.ToSlash(r)
+		}
+
+		c := Change{Path: rel, Existed: change.Existed, Before: change.Before, Exists: change.Exists}
+		if change.Exists {
+			c.AfterSHA256 = checksum(change.After)
+		}
+		result = append(result, c)
+	}
+	return result
+}
+
+func (s *Stack) save(state *State) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := s.path(StateFile)
+	if err := s.fs.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to write state file %s: %v", StateFile, err)
+	}
+	if err := s.fs.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write state file %s: %v", StateFile, err)
+	}
+	return nil
+}
+
+func (s *Stack) path(rel string) string {
+	return filepath.Join(s.baseDir, filepath.FromSlash(rel))
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,180 @@
+package series
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/developingjames/deltagrams/pkg/operations"
+)
+
+const baseDir = "/project"
+
+func gram(parts ...string) string {
+	return "--====DELTAGRAM_0123456789abcdef====\n" +
+		strings.Join(parts, "\n--====DELTAGRAM_0123456789abcdef====\n") +
+		"\n--====DELTAGRAM_0123456789abcdef====--\n"
+}
+
+func newSeriesFS(t *testing.T) *operations.MemoryFileSystem {
+	fs := operations.NewMemoryFileSystem()
+	files := map[string]string{
+		"/project/main.go":           "package main\n",
+		"/project/.deltagram/series": "# migration steps\n01-add-util.txt\n\n02-edit-main.txt\n",
+		"/project/01-add-util.txt":   gram("Content-Location: util.go\nContent-Type: text/plain\nDelta-Operation: create\n\n+++ util.go\npackage main\n"),
+		"/project/02-edit-main.txt":  gram("Content-Location: main.go\nContent-Type: text/plain\nDelta-Operation: content\n\n@@ -1,1 +1,2 @@\n package main\n+// edited"),
+	}
+	for path, content := range files {
+		if err := fs.MkdirAll(path[:strings.LastIndex(path, "/")], 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := fs.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return fs
+}
+
+func TestStack_PushPop(t *testing.T) {
+	fs := newSeriesFS(t)
+	stack := NewStack(fs, baseDir, operations.Options{})
+
+	for _, expected := range []string{"01-add-util.txt", "02-edit-main.txt"} {
+		name, err := stack.Push()
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if name != expected {
+			t.Errorf("Expected %s to be applied, got %s", expected, name)
+		}
+	}
+
+	if _, err := stack.Push(); err == nil {
+		t.Errorf("Expected error when every deltagram is applied")
+	}
+
+	content, _ := fs.ReadFile("/project/main.go")
+	if string(content) != "package main\n// edited\n" {
+		t.Errorf("Expected edited main.go, got %q", content)
+	}
+
+	statuses, err := stack.Status()
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	for _, status := range statuses {
+		if !status.Applied || status.Modified || status.Changed {
+			t.Errorf("Expected %s to be cleanly applied, got %+v", status.Name, status)
+		}
+	}
+
+	for _, expected := range []string{"02-edit-main.txt", "01-add-util.txt"} {
+		name, err := stack.Pop(false)
+		if err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+		if name != expected {
+			t.Errorf("Expected %s to be reverted, got %s", expected, name)
+		}
+	}
+
+	content, _ = fs.ReadFile("/project/main.go")
+	if string(content) != "package main\n" {
+		t.Errorf("Expected original main.go, got %q", content)
+	}
+	if _, err := fs.Stat("/project/util.go"); err == nil {
+		t.Errorf("Expected util.go to be removed by pop")
+	}
+	if _, err := stack.Pop(false); err == nil {
+		t.Errorf("Expected error when nothing is applied")
+	}
+}
+
+func TestStack_PopRefusesModifiedFiles(t *testing.T) {
+	fs := newSeriesFS(t)
+	stack := NewStack(fs, baseDir, operations.Options{})
+
+	if _, err := stack.Push(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	fs.WriteFile("/project/util.go", []byte("hand edited\n"), 0644)
+
+	statuses, _ := stack.Status()
+	if !statuses[0].Modified {
+		t.Errorf("Expected status to report the modified file")
+	}
+
+	if _, err := stack.Pop(false); err == nil || !strings.Contains(err.Error(), "util.go") {
+		t.Fatalf("Expected error naming util.go, got: %v", err)
+	}
+	if _, err := stack.Pop(true); err != nil {
+		t.Fatalf("Expected forced pop to succeed, got: %v", err)
+	}
+	if _, err := fs.Stat("/project/util.go"); err == nil {
+		t.Errorf("Expected util.go to be removed by forced pop")
+	}
+}
+
+func TestStack_PushRollsBackOnFailure(t *testing.T) {
+	fs := newSeriesFS(t)
+	fs.WriteFile("/project/01-add-util.txt", []byte(gram(
+		"Content-Location: util.go\nContent-Type: text/plain\nDelta-Operation: create\n\n+++ util.go\npackage main\n",
+		"Content-Location: main.go\nContent-Type: text/plain\nDelta-Operation: content\n\n@@ -1,1 +1,1 @@\n-no such line\n+x",
+	)), 0644)
+	stack := NewStack(fs, baseDir, operations.Options{})
+
+	if _, err := stack.Push(); err == nil {
+		t.Fatalf("Expected error applying a failing deltagram")
+	}
+	if _, err := fs.Stat("/project/util.go"); err == nil {
+		t.Errorf("Expected util.go to be rolled back")
+	}
+
+	statuses, _ := stack.Status()
+	if statuses[0].Applied {
+		t.Errorf("Expected failed deltagram not to be recorded as applied")
+	}
+}
+
+func TestStack_PushContextRollsBackOnCancel(t *testing.T) {
+	fs := newSeriesFS(t)
+	fs.WriteFile("/project/01-add-util.txt", []byte(gram(
+		"Content-Location: util.go\nContent-Type: text/plain\nDelta-Operation: create\n\n+++ util.go\npackage main\n",
+		"Content-Location: extra.go\nContent-Type: text/plain\nDelta-Operation: create\n\n+++ extra.go\npackage main\n",
+	)), 0644)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stack := NewStack(fs, baseDir, operations.Options{Progress: func(p operations.Progress) {
+		if p.Done == 1 {
+			cancel()
+		}
+	}})
+
+	if _, err := stack.PushContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got: %v", err)
+	}
+	if _, err := fs.Stat("/project/util.go"); err == nil {
+		t.Errorf("Expected util.go to be rolled back")
+	}
+
+	statuses, _ := stack.Status()
+	if statuses[0].Applied {
+		t.Errorf("Expected canceled deltagram not to be recorded as applied")
+	}
+}
+
+func TestStack_ReorderedSeries(t *testing.T) {
+	fs := newSeriesFS(t)
+	stack := NewStack(fs, baseDir, operations.Options{})
+
+	if _, err := stack.Push(); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	fs.WriteFile("/project/.deltagram/series", []byte("02-edit-main.txt\n01-add-util.txt\n"), 0644)
+
+	if _, err := stack.Status(); err == nil || !strings.Contains(err.Error(), "pop before reordering") {
+		t.Errorf("Expected reorder error, got: %v", err)
+	}
+}
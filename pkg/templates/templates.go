@@ -0,0 +1,98 @@
+package templates
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Extension is the file extension of a named template
+const Extension = ".deltagram"
+
+// maxTemplateSize bounds how much a template download may read
+const maxTemplateSize = 50 << 20
+
+var nameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._/-]*$`)
+
+// Source locates template deltagrams by name
+type Source struct {
+	// Dir holds local templates as <name>.deltagram
+	Dir string
+	// Registry is a base URL serving templates as <registry>/<name>.deltagram
+	Registry string
+	// Client performs registry and URL downloads; http.DefaultClient with a timeout is
+	// used when nil
+	Client *http.Client
+}
+
+// DefaultDir returns the per-user template directory
+func DefaultDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "deltagram", "templates"), nil
+}
+
+// Fetch returns the text of a template. The reference may be an http(s) URL, a path to
+// an existing file, or a name looked up in Dir and then the Registry.
+func (s *Source) Fetch(ref string) (string, error) {
+	if strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://") {
+		return s.download(ref)
+	}
+
+	if data, err := os.ReadFile(ref); err == nil {
+		return string(data), nil
+	}
+
+	if !nameRegex.MatchString(ref) || strings.Contains(ref, "..") {
+		return "", fmt.Errorf("invalid template name: %s", ref)
+	}
+
+	if s.Dir != "" {
+		data, err := os.ReadFile(filepath.Join(s.Dir, filepath.FromSlash(ref)+Extension))
+		if err == nil {
+			return string(data), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to read template %s: %v", ref, err)
+		}
+	}
+
+	if s.Registry != "" {
+		return s.download(strings.TrimSuffix(s.Registry, "/") + "/" + ref + Extension)
+	}
+
+	return "", fmt.Errorf("template %s not found in %s and no registry is configured", ref, s.Dir)
+}
+
+func (s *Source) download(url string) (string, error) {
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("failed to download template: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download template %s: %s", url, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxTemplateSize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to download template: %v", err)
+	}
+	if len(data) > maxTemplateSize {
+		return "", fmt.Errorf("template %s exceeds %d bytes", url, maxTemplateSize)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,98 @@
+package templates
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFetch_LocalDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "go"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go", "service.deltagram"), []byte("local template"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	source := &Source{Dir: dir}
+	content, err := source.Fetch("go/service")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if content != "local template" {
+		t.Errorf("Expected %q, got %q", "local template", content)
+	}
+}
+
+func TestFetch_FilePath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "custom.txt")
+	if err := os.WriteFile(path, []byte("file template"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := (&Source{}).Fetch(path)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if content != "file template" {
+		t.Errorf("Expected %q, got %q", "file template", content)
+	}
+}
+
+func TestFetch_Registry(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/templates/cli.deltagram" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("registry template"))
+	}))
+	defer server.Close()
+
+	source := &Source{Dir: t.TempDir(), Registry: server.URL + "/templates/"}
+	content, err := source.Fetch("cli")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if content != "registry template" {
+		t.Errorf("Expected %q, got %q", "registry template", content)
+	}
+
+	if _, err := source.Fetch("missing"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Expected 404 error, got: %v", err)
+	}
+
+	content, err = (&Source{}).Fetch(server.URL + "/templates/cli.deltagram")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if content != "registry template" {
+		t.Errorf("Expected %q, got %q", "registry template", content)
+	}
+}
+
+func TestFetch_NotFound(t *testing.T) {
+	source := &Source{Dir: t.TempDir()}
+
+	tests := []struct {
+		name   string
+		ref    string
+		errMsg string
+	}{
+		{"missing", "nothing", "not found"},
+		{"traversal", "../etc/passwd", "invalid template name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := source.Fetch(tt.ref)
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Expected error containing %q, got: %v", tt.errMsg, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package variables
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// SectionHeader starts the variable declarations inside a message part
+const SectionHeader = "Deltagram-Variables:"
+
+// EnvPrefix is prepended to a variable name to find its value in the environment
+const EnvPrefix = "DELTAGRAM_VAR_"
+
+var nameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// placeholderRegex matches ${NAME} and the escaped form $${NAME}
+var placeholderRegex = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// Variable is a template variable declared by a deltagram
+type Variable struct {
+	Name       string
+	Default    string
+	HasDefault bool
+}
+
+// Declared returns the variables declared in the deltagram's message parts. Each
+// declaration is an indented NAME or NAME=default line following a
+// "Deltagram-Variables:" line.
+func Declared(deltagram *parser.Deltagram) ([]Variable, error) {
+	var vars []Variable
+	seen := make(map[string]bool)
+
+	for _, part := range deltagram.Parts {
+		if !part.IsMessage() {
+			continue
+		}
+
+		inSection := false
+		for _, line := range strings.Split(part.Content, "\n") {
+			if strings.TrimSpace(line) == SectionHeader {
+				inSection = true
+				continue
+			}
+			if !inSection {
+				continue
+			}
+			if strings.TrimSpace(line) == "" || (line[0] != ' ' && line[0] != '\t') {
+				inSection = false
+				continue
+			}
+
+			name, value, hasDefault := strings.Cut(strings.TrimSpace(line), "=")
+			name = strings.TrimSpace(name)
+			if !nameRegex.MatchString(name) {
+				return nil, fmt.Errorf("invalid variable name %q", name)
+			}
+			if seen[name] {
+				return nil, fmt.Errorf("variable %s declared more than once", name)
+			}
+			seen[name] = true
+			vars = append(vars, Variable{Name: name, Default: strings.TrimSpace(value), HasDefault: hasDefault})
+		}
+	}
+
+	return vars, nil
+}
+
+// Resolve picks a value for each declared variable from the explicit values, then the
+// environment (EnvPrefix + name), then the declared default. It fails if a required
+// variable has no value or an explicit value names an undeclared variable.
+func Resolve(vars []Variable, values map[string]string, lookupEnv func(string) (string, bool)) (map[string]string, error) {
+	declared := make(map[string]bool, len(vars))
+	for _, v := range vars {
+		declared[v.Name] = true
+	}
+	for name := range values {
+		if !declared[name] {
+			return nil, fmt.Errorf("variable %s is not declared by the deltagram", name)
+		}
+	}
+
+	resolved := make(map[string]string, len(vars))
+	var missing []string
+	for _, v := range vars {
+		if value, ok := values[v.Name]; ok {
+			resolved[v.Name] = value
+		} else if value, ok := lookupEnv(EnvPrefix + v.Name); ok {
+			resolved[v.Name] = value
+		} else if v.HasDefault {
+			resolved[v.Name] = v.Default
+		} else {
+			missing = append(missing, v.Name)
+		}
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("missing value for variable(s): %s (use --var NAME=value or %sNAME)", strings.Join(missing, ", "), EnvPrefix)
+	}
+	return resolved, nil
+}
+
+// Expand replaces ${NAME} placeholders for the given variables in the location and
+// content of every file part. Placeholders for other names are left untouched, and
+// $${NAME} produces a literal ${NAME}.
+func Expand(deltagram *parser.Deltagram, values map[string]string) *parser.Deltagram {
+	if len(values) == 0 {
+		return deltagram
+	}
+
+	expanded := *deltagram
+	expanded.Parts = make([]parser.DeltagramPart, len(deltagram.Parts))
+	for i, part := range deltagram.Parts {
+		if !part.IsMessage() {
+			part.ContentLocation = expandString(part.ContentLocation, values)
+			part.Content = expandString(part.Content, values)
+		}
+		expanded.Parts[i] = part
+	}
+	return &expanded
+}
+
+func expandString(s string, values map[string]string) string {
+	return placeholderRegex.ReplaceAllStringFunc(s, func(match string) string {
+		name := placeholderRegex.FindStringSubmatch(match)[1]
+		value, ok := values[name]
+		if !ok {
+			return match
+		}
+		if strings.HasPrefix(match, "$$") {
+			return match[1:]
+		}
+		return value
+	})
+}
@@ -0,0 +1,128 @@
+package variables
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func scaffold() *parser.Deltagram {
+	return &parser.Deltagram{
+		UUID: "0123456789abcdef",
+		Parts: []parser.DeltagramPart{
+			{
+				ContentLocation: "deltagram://message",
+				ContentType:     "text/plain",
+				Content:         "Scaffold a Go service.\n\nDeltagram-Variables:\n  PROJECT_NAME\n  MODULE_PATH=example.com/app\n\nEnjoy.",
+			},
+			{
+				ContentLocation: "${PROJECT_NAME}/go.mod",
+				ContentType:     "text/plain",
+				DeltaOperation:  "create",
+				Content:         "+++ ${PROJECT_NAME}/go.mod\nmodule ${MODULE_PATH}\n// $${MODULE_PATH} ${HOME}",
+			},
+		},
+	}
+}
+
+func TestDeclared(t *testing.T) {
+	vars, err := Declared(scaffold())
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	if len(vars) != 2 {
+		t.Fatalf("Expected 2 variables, got %d", len(vars))
+	}
+	if vars[0].Name != "PROJECT_NAME" || vars[0].HasDefault {
+		t.Errorf("Expected required PROJECT_NAME, got %+v", vars[0])
+	}
+	if vars[1].Name != "MODULE_PATH" || vars[1].Default != "example.com/app" {
+		t.Errorf("Expected MODULE_PATH with default, got %+v", vars[1])
+	}
+}
+
+func TestDeclared_Invalid(t *testing.T) {
+	d := scaffold()
+	d.Parts[0].Content = "Deltagram-Variables:\n  1BAD\n"
+
+	if _, err := Declared(d); err == nil {
+		t.Errorf("Expected error for invalid variable name")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	vars, _ := Declared(scaffold())
+	env := map[string]string{"DELTAGRAM_VAR_MODULE_PATH": "github.com/me/app"}
+	lookupEnv := func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+
+	tests := []struct {
+		name     string
+		values   map[string]string
+		expected map[string]string
+		errMsg   string
+	}{
+		{
+			name:     "flag and env",
+			values:   map[string]string{"PROJECT_NAME": "svc"},
+			expected: map[string]string{"PROJECT_NAME": "svc", "MODULE_PATH": "github.com/me/app"},
+		},
+		{
+			name:     "flag overrides env",
+			values:   map[string]string{"PROJECT_NAME": "svc", "MODULE_PATH": "x.io/y"},
+			expected: map[string]string{"PROJECT_NAME": "svc", "MODULE_PATH": "x.io/y"},
+		},
+		{
+			name:   "missing required",
+			values: map[string]string{},
+			errMsg: "missing value for variable(s): PROJECT_NAME",
+		},
+		{
+			name:   "undeclared",
+			values: map[string]string{"PROJECT_NAME": "svc", "OTHER": "1"},
+			errMsg: "OTHER is not declared",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolved, err := Resolve(vars, tt.values, lookupEnv)
+			if tt.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Fatalf("Expected error containing %q, got: %v", tt.errMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got: %v", err)
+			}
+			for name, value := range tt.expected {
+				if resolved[name] != value {
+					t.Errorf("Expected %s=%q, got %q", name, value, resolved[name])
+				}
+			}
+		})
+	}
+}
+
+func TestExpand(t *testing.T) {
+	d := scaffold()
+	expanded := Expand(d, map[string]string{"PROJECT_NAME": "svc", "MODULE_PATH": "x.io/svc"})
+
+	part := expanded.Parts[1]
+	if part.ContentLocation != "svc/go.mod" {
+		t.Errorf("Expected expanded location svc/go.mod, got %q", part.ContentLocation)
+	}
+	expected := "+++ svc/go.mod\nmodule x.io/svc\n// ${MODULE_PATH} ${HOME}"
+	if part.Content != expected {
+		t.Errorf("Expected content %q, got %q", expected, part.Content)
+	}
+
+	if d.Parts[1].ContentLocation != "${PROJECT_NAME}/go.mod" {
+		t.Errorf("Expected original deltagram to be unchanged, got %q", d.Parts[1].ContentLocation)
+	}
+}
@@ -0,0 +1,281 @@
+// Package workspace applies one deltagram to several directories at once. A mapping file
+// routes Content-Locations by their leading directories, such as backend/ and frontend/,
+// to directories on disk, so that a change spanning several repositories can be made in
+// a single apply.
+package workspace
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/developingjames/deltagrams/pkg/operations"
+)
+
+// Root is the base directory under which a FileSystem exposes the workspace
+var Root = operations.OverlayRoot
+
+// Mapping routes path prefixes to directories
+type Mapping struct {
+	roots []root // Sorted by prefix
+}
+
+type root struct {
+	prefix string // Slash-separated, without leading or trailing slashes
+	dir    string // Absolute directory on disk
+}
+
+// mappingFile is the JSON form of a mapping file
+type mappingFile struct {
+	// Roots maps a prefix such as "backend" or "services/api" to a directory, which is
+	// relative to the mapping file when not absolute
+	Roots map[string]string `json:"roots"`
+}
+
+// Load reads a mapping file
+func Load(file string) (*Mapping, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workspace mapping: %v", err)
+	}
+	var parsed mappingFile
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid workspace mapping %s: %v", file, err)
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	m, err := NewMapping(filepath.Dir(abs), parsed.Roots)
+	if err != nil {
+		return nil, fmt.Errorf("invalid workspace mapping %s: %v", file, err)
+	}
+	return m, nil
+}
+
+// NewMapping creates a mapping from prefixes to directories, resolving relative
+// directories against dir. Prefixes may not be nested in one another.
+func NewMapping(dir string, roots map[string]string) (*Mapping, error) {
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("no roots are mapped")
+	}
+	m := &Mapping{}
+	for prefix, target := range roots {
+		clean := path.Clean(strings.Trim(filepath.ToSlash(prefix), "/"))
+		if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") || !iofs.ValidPath(clean) {
+			return nil, fmt.Errorf("invalid prefix %q", prefix)
+		}
+		if target == "" {
+			return nil, fmt.Errorf("prefix %q has no directory", prefix)
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(dir, target)
+		}
+		m.roots = append(m.roots, root{prefix: clean, dir: filepath.Clean(target)})
+	}
+	sort.Slice(m.roots, func(i, j int) bool { return m.roots[i].prefix < m.roots[j].prefix })
+
+	for i := 1; i < len(m.roots); i++ {
+		prev, cur := m.roots[i-1].prefix, m.roots[i].prefix
+		if prev == cur || strings.HasPrefix(cur, prev+"/") {
+			return nil, fmt.Errorf("prefix %q is inside prefix %q", cur, prev)
+		}
+	}
+	return m, nil
+}
+
+// Resolve returns the directory on disk and the path within it of a slash-separated path
+// relative to the workspace, or ok false when no prefix covers it
+func (m *Mapping) Resolve(name string) (dir, rel string, ok bool) {
+	for _, r := range m.roots {
+		if name == r.prefix {
+			return r.dir, ".", true
+		}
+		if rest, found := strings.CutPrefix(name, r.prefix+"/"); found {
+			return r.dir, rest, true
+		}
+	}
+	return "", "", false
+}
+
+// Prefixes returns the mapped prefixes in order
+func (m *Mapping) Prefixes() []string {
+	prefixes := make([]string, len(m.roots))
+	for i, r := range m.roots {
+		prefixes[i] = r.prefix
+	}
+	return prefixes
+}
+
+// Check returns an error naming the first path that no prefix covers. Paths are
+// deltagram Content-Locations.
+func (m *Mapping) Check(paths []string) error {
+	for _, p := range paths {
+		name := path.Clean(strings.TrimPrefix(filepath.ToSlash(p), "/"))
+		if _, _, ok := m.Resolve(name); !ok {
+			return fmt.Errorf("%s is not under any workspace root; mapped prefixes are %s", p, strings.Join(m.Prefixes(), ", "))
+		}
+	}
+	return nil
+}
+
+// FS returns a read-only view of the workspace, with each mapped directory at its prefix
+// and the directories leading to the prefixes in between
+func (m *Mapping) FS() iofs.FS {
+	return mappedFS{m}
+}
+
+type mappedFS struct {
+	m *Mapping
+}
+
+func (f mappedFS) Open(name string) (iofs.File, error) {
+	if !iofs.ValidPath(name) {
+		return nil, &iofs.PathError{Op: "open", Path: name, Err: iofs.ErrInvalid}
+	}
+	if dir, rel, ok := f.m.Resolve(name); ok {
+		return os.DirFS(dir).Open(rel)
+	}
+
+	// Directories above the prefixes list the next component of each prefix below them
+	seen := make(map[string]bool)
+	var entries []iofs.DirEntry
+	for _, r := range f.m.roots {
+		rest := r.prefix
+		if name != "." {
+			var found bool
+			if rest, found = strings.CutPrefix(r.prefix, name+"/"); !found {
+				continue
+			}
+		}
+		child, _, _ := strings.Cut(rest, "/")
+		if !seen[child] {
+			seen[child] = true
+			entries = append(entries, iofs.FileInfoToDirEntry(virtualDirInfo(child)))
+		}
+	}
+	if name != "." && len(entries) == 0 {
+		return nil, &iofs.PathError{Op: "open", Path: name, Err: iofs.ErrNotExist}
+	}
+	return &virtualDir{name: path.Base(name), entries: entries}, nil
+}
+
+// virtualDir is a directory of the workspace that is not on disk
+type virtualDir struct {
+	name    string
+	entries []iofs.DirEntry
+}
+
+func (d *virtualDir) Stat() (iofs.FileInfo, error) { return virtualDirInfo(d.name), nil }
+func (d *virtualDir) Read([]byte) (int, error) {
+	return 0, &iofs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+func (d *virtualDir) Close() error { return nil }
+
+func (d *virtualDir) ReadDir(n int) ([]iofs.DirEntry, error) {
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(d.entries))
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+type virtualDirInfo string
+
+func (i virtualDirInfo) Name() string        { return string(i) }
+func (i virtualDirInfo) Size() int64         { return 0 }
+func (i virtualDirInfo) Mode() iofs.FileMode { return iofs.ModeDir | 0755 }
+func (i virtualDirInfo) ModTime() time.Time  { return time.Time{} }
+func (i virtualDirInfo) IsDir() bool         { return true }
+func (i virtualDirInfo) Sys() interface{}    { return nil }
+
+// FileSystem holds the workspace in memory under Root, reading files from disk as they
+// are first touched, until Save writes the changes back to the mapped directories
+type FileSystem struct {
+	*operations.OverlayFileSystem
+	m *Mapping
+}
+
+// Open returns a FileSystem over the directories of m
+func Open(m *Mapping) *FileSystem {
+	return &FileSystem{OverlayFileSystem: operations.NewOverlayFileSystem(m.FS()), m: m}
+}
+
+// Save writes the changed files to their mapped directories. A file whose directory
+// resolves through a symlink to outside its mapped directory is refused. It returns the
+// paths already written when it fails part way.
+func (f *FileSystem) Save(changes []operations.FileChange) ([]string, error) {
+	disk := operations.NewRealFileSystem()
+	var written []string
+	for _, change := range changes {
+		rel, err := filepath.Rel(Root, change.Path)
+		if err != nil {
+			return written, err
+		}
+		dir, inner, ok := f.m.Resolve(filepath.ToSlash(rel))
+		if !ok || inner == "." {
+			return written, fmt.Errorf("cannot write %s: not under a workspace root", filepath.ToSlash(rel))
+		}
+		target := filepath.Join(dir, filepath.FromSlash(inner))
+		if err := checkWithin(dir, filepath.Dir(target)); err != nil {
+			return written, fmt.Errorf("cannot write %s: %v", filepath.ToSlash(rel), err)
+		}
+
+		if !change.Exists {
+			if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+				return written, fmt.Errorf("failed to delete %s: %v", target, err)
+			}
+		} else {
+			perm := os.FileMode(0644)
+			if info, err := f.Stat(change.Path); err == nil {
+				perm = info.Mode().Perm()
+			}
+			if err := disk.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return written, fmt.Errorf("failed to create directory for %s: %v", target, err)
+			}
+			if err := disk.WriteFile(target, change.After, perm); err != nil {
+				return written, fmt.Errorf("failed to write %s: %v", target, err)
+			}
+		}
+		written = append(written, filepath.ToSlash(rel))
+	}
+	return written, nil
+}
+
+// checkWithin refuses a directory whose existing part resolves outside root
+func checkWithin(root, dir string) error {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %v", root, err)
+	}
+	existing := dir
+	for {
+		if _, err := os.Lstat(existing); err == nil || filepath.Dir(existing) == existing {
+			break
+		}
+		existing = filepath.Dir(existing)
+	}
+	realDir, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %v", existing, err)
+	}
+	if rel, err := filepath.Rel(realRoot, realDir); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("%s resolves outside %s", dir, root)
+	}
+	return nil
+}
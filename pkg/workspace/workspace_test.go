@@ -0,0 +1,190 @@
+package workspace
+
+import (
+	"io"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/developingjames/deltagrams/pkg/operations"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+// newWorkspace creates two repositories and a mapping file routing backend/ and
+// services/web/ to them
+func newWorkspace(t *testing.T) (*Mapping, string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	api, web := filepath.Join(dir, "api"), filepath.Join(dir, "web")
+	os.MkdirAll(filepath.Join(api, "cmd"), 0755)
+	os.MkdirAll(web, 0755)
+	os.WriteFile(filepath.Join(api, "cmd", "main.go"), []byte("package main\n"), 0644)
+	os.WriteFile(filepath.Join(web, "index.html"), []byte("<p>old</p>\n"), 0644)
+
+	file := filepath.Join(dir, "workspace.json")
+	os.WriteFile(file, []byte(`{"roots": {"backend": "api", "services/web/": "`+filepath.ToSlash(web)+`"}}`), 0644)
+	m, err := Load(file)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	return m, api, web
+}
+
+func TestNewMapping_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		roots map[string]string
+	}{
+		{name: "empty", roots: map[string]string{}},
+		{name: "root prefix", roots: map[string]string{"/": "a"}},
+		{name: "parent prefix", roots: map[string]string{"../x": "a"}},
+		{name: "no directory", roots: map[string]string{"a": ""}},
+		{name: "nested", roots: map[string]string{"a": "x", "a/b": "y"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewMapping(t.TempDir(), tt.roots); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestMapping_Resolve(t *testing.T) {
+	m, api, web := newWorkspace(t)
+
+	tests := []struct {
+		name string
+		dir  string
+		rel  string
+		ok   bool
+	}{
+		{name: "backend/cmd/main.go", dir: api, rel: "cmd/main.go", ok: true},
+		{name: "backend", dir: api, rel: ".", ok: true},
+		{name: "services/web/index.html", dir: web, rel: "index.html", ok: true},
+		{name: "backendx/a", ok: false},
+		{name: "services/other", ok: false},
+	}
+
+	for _, tt := range tests {
+		dir, rel, ok := m.Resolve(tt.name)
+		if dir != tt.dir || rel != tt.rel || ok != tt.ok {
+			t.Errorf("Resolve(%q) = %q, %q, %v; expected %q, %q, %v", tt.name, dir, rel, ok, tt.dir, tt.rel, tt.ok)
+		}
+	}
+
+	if err := m.Check([]string{"/backend/a.go", "services/web/b.css"}); err != nil {
+		t.Errorf("Expected mapped paths to pass, got: %v", err)
+	}
+	if err := m.Check([]string{"README.md"}); err == nil || !strings.Contains(err.Error(), "backend, services/web") {
+		t.Errorf("Expected an error listing the prefixes, got: %v", err)
+	}
+}
+
+func TestMapping_FS(t *testing.T) {
+	m, _, _ := newWorkspace(t)
+	fsys := m.FS()
+
+	names := func(dir string) []string {
+		entries, err := iofs.ReadDir(fsys, dir)
+		if err != nil {
+			t.Fatalf("Expected no error reading %s, got: %v", dir, err)
+		}
+		var result []string
+		for _, entry := range entries {
+			result = append(result, entry.Name())
+		}
+		sort.Strings(result)
+		return result
+	}
+	if got := strings.Join(names("."), ","); got != "backend,services" {
+		t.Errorf("Expected the top-level prefixes, got %s", got)
+	}
+	if got := strings.Join(names("services"), ","); got != "web" {
+		t.Errorf("Expected web under services, got %s", got)
+	}
+	if got := strings.Join(names("backend"), ","); got != "cmd" {
+		t.Errorf("Expected the repository contents, got %s", got)
+	}
+
+	data, err := iofs.ReadFile(fsys, "backend/cmd/main.go")
+	if err != nil || string(data) != "package main\n" {
+		t.Errorf("Expected main.go, got %q, %v", data, err)
+	}
+	if _, err := iofs.Stat(fsys, "frontend"); !os.IsNotExist(err) {
+		t.Errorf("Expected an unmapped directory not to exist, got: %v", err)
+	}
+}
+
+func TestFileSystem_ApplyAndSave(t *testing.T) {
+	m, api, web := newWorkspace(t)
+
+	deltagram, err := parser.NewParser().Parse("--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: backend/internal/log.go\n" +
+		"Content-Type: text/plain\n" +
+		"\n" +
+		"package internal\n" +
+		"--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: services/web/index.html\n" +
+		"Content-Type: text/html\n" +
+		"Delta-Operation: content\n" +
+		"\n" +
+		"@@ -1,1 +1,1 @@\n" +
+		"-<p>old</p>\n" +
+		"+<p>new</p>\n" +
+		"--====DELTAGRAM_0123456789abcdef====\n" +
+		"Content-Location: backend/cmd/main.go\n" +
+		"Content-Type: text/plain\n" +
+		"Delta-Operation: delete\n" +
+		"--====DELTAGRAM_0123456789abcdef====--")
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	fs := Open(m)
+	recorder := operations.NewRecordingFileSystem(fs)
+	if err := operations.NewApplierWithOptions(recorder, operations.Options{Output: io.Discard}).Apply(deltagram, Root); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(api, "internal")); !os.IsNotExist(err) {
+		t.Fatal("Expected nothing written before Save")
+	}
+
+	written, err := fs.Save(recorder.Changes())
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	if len(written) != 3 {
+		t.Errorf("Expected three files written, got %v", written)
+	}
+	if data, _ := os.ReadFile(filepath.Join(api, "internal", "log.go")); string(data) != "package internal" {
+		t.Errorf("Expected log.go created in the api repository, got %q", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(web, "index.html")); string(data) != "<p>new</p>\n" {
+		t.Errorf("Expected index.html patched in the web repository, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(api, "cmd", "main.go")); !os.IsNotExist(err) {
+		t.Error("Expected main.go deleted from the api repository")
+	}
+}
+
+func TestFileSystem_SaveRefusesSymlinkEscape(t *testing.T) {
+	m, api, _ := newWorkspace(t)
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(api, "link")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	fs := Open(m)
+	change := operations.FileChange{Path: filepath.Join(Root, "backend", "link", "a.txt"), After: []byte("a"), Exists: true}
+	if _, err := fs.Save([]operations.FileChange{change}); err == nil {
+		t.Error("Expected a write through a symlink out of the repository to be refused")
+	}
+	if _, err := os.Stat(filepath.Join(outside, "a.txt")); !os.IsNotExist(err) {
+		t.Error("Expected nothing written outside the repository")
+	}
+}
@@ -0,0 +1,15 @@
+// Package deltagrams embeds the deltagram format specification and a worked example
+// so that tools can print them without access to the source tree.
+package deltagrams
+
+import _ "embed"
+
+// Spec is the canonical deltagram format specification, written as instructions for LLMs
+//
+//go:embed deltagram_prompt.md
+var Spec string
+
+// Example is a worked deltagram that creates, edits, moves, and deletes files
+//
+//go:embed examples/example.deltagram
+var Example string
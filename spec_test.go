@@ -0,0 +1,58 @@
+package deltagrams
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/developingjames/deltagrams/pkg/operations"
+	"github.com/developingjames/deltagrams/pkg/parser"
+)
+
+func TestSpec_Embedded(t *testing.T) {
+	if !strings.Contains(Spec, "DELTAGRAM_") {
+		t.Errorf("Expected the embedded specification to describe boundaries")
+	}
+}
+
+func TestExample_Applies(t *testing.T) {
+	deltagram, err := parser.NewParser().Parse(Example)
+	if err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+
+	fs := operations.NewMemoryFileSystem()
+	files := map[string]string{
+		"/project/src/main.py":   "def main():\n    print(\"Hello, world!\")\n    return 0\n",
+		"/project/src/legacy.py": "pass\n",
+		"/project/README.md":     "# Project\n",
+	}
+	if err := fs.MkdirAll("/project/src", 0755); err != nil {
+		t.Fatalf("Expected no error, got: %v", err)
+	}
+	for path, content := range files {
+		if err := fs.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Expected no error, got: %v", err)
+		}
+	}
+
+	if err := operations.NewApplier(fs).Apply(deltagram, "/project"); err != nil {
+		t.Fatalf("Expected example to apply, got: %v", err)
+	}
+
+	result := fs.Files()
+	expectedMain := "from logger import Logger\n\ndef main():\n    Logger().info(\"Starting application\")\n    print(\"Hello, world!\")\n    return True\n"
+	if string(result["/project/src/main.py"]) != expectedMain {
+		t.Errorf("Expected main.py %q, got %q", expectedMain, result["/project/src/main.py"])
+	}
+	if _, ok := result["/project/src/logger.py"]; !ok {
+		t.Errorf("Expected logger.py to be created")
+	}
+	if string(result["/project/docs/README.md"]) != "# Project\n" {
+		t.Errorf("Expected README.md to be moved to docs/")
+	}
+	for _, path := range []string{"/project/README.md", "/project/src/legacy.py"} {
+		if _, ok := result[path]; ok {
+			t.Errorf("Expected %s to be removed", path)
+		}
+	}
+}